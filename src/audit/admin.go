@@ -0,0 +1,140 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package audit
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"xbase"
+)
+
+const (
+	// The max number of the admin events kept in memory.
+	maxAdminEvents = 1024
+
+	redacted = "******"
+)
+
+var (
+	// The parameter names contain one of the words are redacted.
+	secretWords = []string{"password", "passwd", "secret", "token"}
+)
+
+// AdminEvent tuple.
+type AdminEvent struct {
+	Start             time.Time `json:"start"`
+	End               time.Time `json:"end"`
+	User              string    `json:"user"`
+	UserHost          string    `json:"user_host"`
+	Action            string    `json:"action"`
+	Params            string    `json:"params"`
+	MetaVersionBefore int64     `json:"meta_version_before"`
+	MetaVersionAfter  int64     `json:"meta_version_after"`
+	Status            uint16    `json:"status"`
+	Error             string    `json:"error,omitempty"`
+}
+
+func isSecret(name string) bool {
+	name = strings.ToLower(name)
+	for _, word := range secretWords {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+func redact(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, sub := range v {
+			if isSecret(k) {
+				v[k] = redacted
+			} else {
+				v[k] = redact(sub)
+			}
+		}
+	case []interface{}:
+		for i, sub := range v {
+			v[i] = redact(sub)
+		}
+	}
+	return v
+}
+
+// RedactJSON returns the JSON with the values of the secret fields redacted.
+func RedactJSON(b []byte) string {
+	if len(strings.TrimSpace(string(b))) == 0 {
+		return ""
+	}
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		// The secrets may be in the malformed body.
+		return redacted
+	}
+	out, err := json.Marshal(redact(v))
+	if err != nil {
+		return redacted
+	}
+	return string(out)
+}
+
+// RedactParams returns the JSON of the params with the values of the secret fields redacted.
+func RedactParams(params interface{}) string {
+	b, err := json.Marshal(params)
+	if err != nil {
+		return redacted
+	}
+	return RedactJSON(b)
+}
+
+// LogAdminEvent used to handle the administrative action, it's always logged whatever the mode and the filters are.
+func (a *Audit) LogAdminEvent(e *AdminEvent) {
+	if e.End.IsZero() {
+		e.End = time.Now().UTC()
+	}
+
+	a.mu.Lock()
+	a.admins = append(a.admins, e)
+	if len(a.admins) > maxAdminEvents {
+		a.admins = a.admins[len(a.admins)-maxAdminEvents:]
+	}
+	a.mu.Unlock()
+
+	a.enqueue(&event{
+		Start:             e.Start,
+		End:               e.End,
+		Cost:              e.End.Sub(e.Start),
+		User:              e.User,
+		UserHost:          e.UserHost,
+		CommandType:       xbase.ADMIN,
+		Argument:          e.Action,
+		Status:            e.Status,
+		Params:            e.Params,
+		MetaVersionBefore: e.MetaVersionBefore,
+		MetaVersionAfter:  e.MetaVersionAfter,
+		Error:             e.Error,
+	})
+}
+
+// AdminEvents returns the latest admin events in time order, all the kept events if the limit is 0.
+func (a *Audit) AdminEvents(limit int) []*AdminEvent {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	events := a.admins
+	if limit > 0 && len(events) > limit {
+		events = events[len(events)-limit:]
+	}
+	out := make([]*AdminEvent, len(events))
+	copy(out, events)
+	return out
+}
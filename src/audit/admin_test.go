@@ -0,0 +1,108 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package audit
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"config"
+	"fakedb"
+
+	"github.com/fortytw2/leaktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestRedactJSON(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{``, ``},
+		{`{"name":"node1","password":"123"}`, `{"name":"node1","password":"******"}`},
+		{`{"user":"u1","Passwd":"123","nodes":[{"db-password":"x","address":"127.0.0.1:3306"}]}`, `{"Passwd":"******","nodes":[{"address":"127.0.0.1:3306","db-password":"******"}],"user":"u1"}`},
+		{`{"readonly":true}`, `{"readonly":true}`},
+		{`{"password":"123"`, `******`},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.want, RedactJSON([]byte(test.in)), test.in)
+	}
+
+	p := struct {
+		User     string `json:"user"`
+		Password string `json:"password"`
+	}{"u1", "123"}
+	assert.Equal(t, `{"password":"******","user":"u1"}`, RedactParams(p))
+}
+
+func TestAuditAdminEvents(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	tmpDir := fakedb.GetTmpDir("", "radon_audit_", log)
+	defer os.RemoveAll(tmpDir)
+	conf := config.DefaultAuditConfig()
+	// The admin events are logged whatever the mode and the filters are.
+	conf.Mode = NULL
+	conf.LogDir = tmpDir
+	conf.Sink = SinkCSV
+	conf.Filters = []*config.AuditFilterConfig{{Users: []string{"u2"}}}
+
+	audit := NewAudit(log, conf)
+	err := audit.Init()
+	assert.Nil(t, err)
+
+	start := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+	for i := 0; i < maxAdminEvents+2; i++ {
+		// The events are dropped if the queue is full.
+		for audit.Status().Queued > cap(audit.queue)/2 {
+			time.Sleep(time.Millisecond)
+		}
+		audit.LogAdminEvent(&AdminEvent{
+			Start:             start,
+			User:              "u1",
+			UserHost:          "127.0.0.1:8899",
+			Action:            "POST /v1/radon/backend",
+			Params:            `{"name":"node1","password":"******"}`,
+			MetaVersionBefore: 1,
+			MetaVersionAfter:  2,
+		})
+	}
+	audit.LogAdminEvent(&AdminEvent{
+		Start:             start,
+		End:               start.Add(time.Second),
+		User:              "u1",
+		UserHost:          "127.0.0.1:8899",
+		Action:            "DELETE /v1/radon/backend/node1",
+		MetaVersionBefore: 2,
+		MetaVersionAfter:  2,
+		Status:            1,
+		Error:             "backend.not.exists",
+	})
+	audit.Close()
+
+	events := audit.AdminEvents(2)
+	assert.Equal(t, 2, len(events))
+	assert.Equal(t, "POST /v1/radon/backend", events[0].Action)
+	assert.Equal(t, "DELETE /v1/radon/backend/node1", events[1].Action)
+	assert.Equal(t, maxAdminEvents, len(audit.AdminEvents(0)))
+	assert.EqualValues(t, maxAdminEvents+3, audit.Status().Logged)
+
+	files, err := filepath.Glob(filepath.Join(tmpDir, prefix+"*"+csvExtension))
+	assert.Nil(t, err)
+	records, err := csv.NewReader(strings.NewReader(readFiles(t, files))).ReadAll()
+	assert.Nil(t, err)
+	assert.Equal(t, maxAdminEvents+3, len(records))
+	assert.Equal(t, []string{"u1", "127.0.0.1:8899", "", "0", "ADMIN", "POST /v1/radon/backend", "0", "0", "", `{"name":"node1","password":"******"}`, "1", "2", ""}, records[0][3:])
+	assert.Equal(t, []string{"1000000000", "u1", "127.0.0.1:8899", "", "0", "ADMIN", "DELETE /v1/radon/backend/node1", "1", "0", "", "", "2", "2", "backend.not.exists"}, records[maxAdminEvents+2][2:])
+}
@@ -16,6 +16,7 @@ import (
 
 	"config"
 	"xbase"
+	"xbase/sync2"
 
 	"github.com/xelabs/go-mysqlstack/xlog"
 )
@@ -23,6 +24,9 @@ import (
 const (
 	prefix    = "audit-"
 	extension = ".log"
+
+	// The extension of the csv sink files.
+	csvExtension = ".csv"
 )
 
 const (
@@ -43,16 +47,24 @@ const (
 // NOTE:
 // if the event changes, we must re-generate the audit_easyjson.go file by 'easyjson src/audit/audit.go' command.
 type event struct {
-	Start       time.Time     `json:"start"`        // Time the query was start.
-	End         time.Time     `json:"end"`          // Time the query was end.
-	Cost        time.Duration `json:"cost"`         // Cost.
-	User        string        `json:"user"`         // User.
-	UserHost    string        `json:"user_host"`    // User and host combination.
-	ThreadID    uint32        `json:"thread_id"`    // Thread id.
-	CommandType string        `json:"command_type"` // Type of command.
-	Argument    string        `json:"argument"`     // Full query.
-	Status      uint16        `json:"status"`       // Status of results, if 0 success, else failure.
-	QueryRows   uint64        `json:"query_rows"`   // Query rows.
+	Start       time.Time     `json:"start"`          // Time the query was start.
+	End         time.Time     `json:"end"`            // Time the query was end.
+	Cost        time.Duration `json:"cost"`           // Cost.
+	User        string        `json:"user"`           // User.
+	UserHost    string        `json:"user_host"`      // User and host combination.
+	DB          string        `json:"db,omitempty"`   // Current database.
+	ThreadID    uint32        `json:"thread_id"`      // Thread id.
+	CommandType string        `json:"command_type"`   // Type of command.
+	Argument    string        `json:"argument"`       // Full query.
+	Status      uint16        `json:"status"`         // Status of results, if 0 success, else failure.
+	QueryRows   uint64        `json:"query_rows"`     // Query rows.
+	Rule        string        `json:"rule,omitempty"` // Firewall rule.
+
+	// The admin event fields.
+	Params            string `json:"params,omitempty"`              // Parameters with the secrets redacted.
+	MetaVersionBefore int64  `json:"meta_version_before,omitempty"` // Meta version before the action.
+	MetaVersionAfter  int64  `json:"meta_version_after,omitempty"`  // Meta version after the action.
+	Error             string `json:"error,omitempty"`               // Error of the action.
 }
 
 // Audit tuple.
@@ -63,19 +75,54 @@ type Audit struct {
 	queue  chan *event
 	done   chan bool
 	rfile  xbase.RotateFile
+	sink   sink
 	wg     sync.WaitGroup
+
+	mu          sync.RWMutex
+	filters     []*filter
+	filterConfs []*config.AuditFilterConfig
+	admins      []*AdminEvent
+
+	logged   sync2.AtomicInt64
+	filtered sync2.AtomicInt64
+	failed   sync2.AtomicInt64
+	dropped  sync2.AtomicInt64
+}
+
+// Status tuple, the chain head is the last event of the hash chain, used to anchor the chain outside the files.
+type Status struct {
+	Mode      string                      `json:"mode"`
+	Sink      string                      `json:"sink"`
+	HashChain bool                        `json:"hash-chain"`
+	ChainHead *ChainHead                  `json:"chain-head,omitempty"`
+	File      string                      `json:"file"`
+	Filters   []*config.AuditFilterConfig `json:"filters"`
+	Queued    int                         `json:"queued"`
+	Logged    int64                       `json:"logged"`
+	Filtered  int64                       `json:"filtered"`
+	Failed    int64                       `json:"failed"`
+	Dropped   int64                       `json:"dropped"`
 }
 
 // NewAudit creates the new audit.
 func NewAudit(log *xlog.Log, conf *config.AuditConfig) *Audit {
-	return &Audit{
+	a := &Audit{
 		log:    log,
 		conf:   conf,
 		done:   make(chan bool),
 		queue:  make(chan *event, 1024),
 		ticker: time.NewTicker(time.Duration(time.Second * 300)), // 5 minutes
-		rfile:  xbase.NewRotateFile(conf.LogDir, prefix, extension, conf.MaxSize),
 	}
+	a.rfile = xbase.NewRotateFile(conf.LogDir, prefix, a.extension(), conf.MaxSize)
+	return a
+}
+
+// extension returns the extension of the files.
+func (a *Audit) extension() string {
+	if a.conf.Sink == SinkCSV {
+		return csvExtension
+	}
+	return extension
 }
 
 // Init used to create the log dir, if EXISTS we do onthing.
@@ -83,9 +130,19 @@ func (a *Audit) Init() error {
 	log := a.log
 
 	log.Info("audit.init.conf:%+v", a.conf)
-	if err := os.MkdirAll(a.conf.LogDir, 0744); err != nil {
+	if err := a.SetFilters(a.conf.Filters); err != nil {
+		return err
+	}
+	if a.isFileSink() {
+		if err := os.MkdirAll(a.conf.LogDir, 0744); err != nil {
+			return err
+		}
+	}
+	sink, err := a.newSink()
+	if err != nil {
 		return err
 	}
+	a.sink = sink
 
 	a.wg.Add(1)
 	go func(audit *Audit) {
@@ -102,8 +159,90 @@ func (a *Audit) Init() error {
 	return nil
 }
 
+func (a *Audit) isFileSink() bool {
+	switch a.conf.Sink {
+	case "", SinkFile, SinkCSV:
+		return true
+	}
+	return false
+}
+
+// SetFilters used to set the filter rules, all the events are logged if no rules.
+func (a *Audit) SetFilters(confs []*config.AuditFilterConfig) error {
+	filters, err := newFilters(confs)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.filters = filters
+	a.filterConfs = confs
+	return nil
+}
+
+// Status returns the status of the audit.
+func (a *Audit) Status() *Status {
+	a.mu.RLock()
+	filters := a.filterConfs
+	a.mu.RUnlock()
+
+	status := &Status{
+		Mode:      a.conf.Mode,
+		Sink:      a.conf.Sink,
+		HashChain: a.conf.HashChain,
+		Filters:   filters,
+		Queued:    len(a.queue),
+		Logged:    a.logged.Get(),
+		Filtered:  a.filtered.Get(),
+		Failed:    a.failed.Get(),
+		Dropped:   a.dropped.Get(),
+	}
+	if status.Sink == "" {
+		status.Sink = SinkFile
+	}
+	if a.isFileSink() {
+		status.File = a.rfile.Name()
+	}
+	if s, ok := a.sink.(*fileSink); ok && s.key != nil {
+		head := s.head()
+		status.ChainHead = &head
+	}
+	return status
+}
+
+// match returns true if the event matches one of the filters, the firewall and admin events are always matched.
+func (a *Audit) match(e *event) bool {
+	switch e.CommandType {
+	case xbase.FIREWALL, xbase.ADMIN:
+		return true
+	}
+
+	a.mu.RLock()
+	filters := a.filters
+	a.mu.RUnlock()
+	if len(filters) == 0 {
+		return true
+	}
+
+	var tables []string
+	var resolved bool
+	tablesFn := func() []string {
+		if !resolved {
+			tables = tablesOf(e.DB, e.Argument)
+			resolved = true
+		}
+		return tables
+	}
+	for _, f := range filters {
+		if f.match(e, tablesFn) {
+			return true
+		}
+	}
+	return false
+}
+
 // LogReadEvent used to handle the read-only event.
-func (a *Audit) LogReadEvent(t, user, host string, threadID uint32, query string, status uint16, affected uint64, startTime time.Time) {
+func (a *Audit) LogReadEvent(t, user, host, db string, threadID uint32, query string, status uint16, affected uint64, startTime time.Time) {
 	if a.conf.Mode == ALL || a.conf.Mode == READ {
 		e := &event{
 			Start:       startTime,
@@ -111,18 +250,19 @@ func (a *Audit) LogReadEvent(t, user, host string, threadID uint32, query string
 			Cost:        time.Since(startTime),
 			User:        user,
 			UserHost:    host,
+			DB:          db,
 			ThreadID:    threadID,
 			CommandType: t,
 			Argument:    query,
 			Status:      status,
 			QueryRows:   affected,
 		}
-		a.queue <- e
+		a.enqueue(e)
 	}
 }
 
 // LogWriteEvent used to handle the write event.
-func (a *Audit) LogWriteEvent(t, user, host string, threadID uint32, query string, status uint16, affected uint64, startTime time.Time) {
+func (a *Audit) LogWriteEvent(t, user, host, db string, threadID uint32, query string, status uint16, affected uint64, startTime time.Time) {
 	if a.conf.Mode == ALL || a.conf.Mode == WRITE {
 		e := &event{
 			Start:       startTime,
@@ -130,13 +270,41 @@ func (a *Audit) LogWriteEvent(t, user, host string, threadID uint32, query strin
 			Cost:        time.Since(startTime),
 			User:        user,
 			UserHost:    host,
+			DB:          db,
 			ThreadID:    threadID,
 			CommandType: t,
 			Argument:    query,
 			Status:      status,
 			QueryRows:   affected,
 		}
-		a.queue <- e
+		a.enqueue(e)
+	}
+}
+
+// LogFirewallEvent used to handle the statement denied by the firewall, it's always logged whatever the mode is.
+func (a *Audit) LogFirewallEvent(user, host string, threadID uint32, query string, rule string, startTime time.Time) {
+	e := &event{
+		Start:       startTime,
+		End:         time.Now(),
+		Cost:        time.Since(startTime),
+		User:        user,
+		UserHost:    host,
+		ThreadID:    threadID,
+		CommandType: xbase.FIREWALL,
+		Argument:    query,
+		Status:      1,
+		Rule:        rule,
+	}
+	a.enqueue(e)
+}
+
+// enqueue sends the event to the consumer, the event is dropped if the queue is full,
+// a stalled sink never blocks the queries.
+func (a *Audit) enqueue(e *event) {
+	select {
+	case a.queue <- e:
+	default:
+		a.dropped.Add(1)
 	}
 }
 
@@ -146,8 +314,9 @@ func (a *Audit) Close() {
 	close(a.done)
 	close(a.queue)
 	a.wg.Wait()
-	a.rfile.Sync()
-	a.rfile.Close()
+	if a.sink != nil {
+		a.sink.close()
+	}
 	a.log.Info("audit.closed")
 }
 
@@ -159,17 +328,18 @@ func (a *Audit) eventConsumer() {
 
 func (a *Audit) writeEvent(e *event) {
 	log := a.log
-	b, err := e.MarshalJSON()
-	if err != nil {
-		b = []byte(err.Error())
+	if !a.match(e) {
+		a.filtered.Add(1)
+		return
 	}
-	b = append(b, '\n')
 
 	// write
-	_, err = a.rfile.Write(b)
-	if err != nil {
-		log.Error("audit.write.file.error:%v", err)
+	if err := a.sink.write(e); err != nil {
+		a.failed.Add(1)
+		log.Error("audit.write.%s.error:%v", a.conf.Sink, err)
+		return
 	}
+	a.logged.Add(1)
 }
 
 func (a *Audit) purge() {
@@ -186,7 +356,7 @@ func (a *Audit) purge() {
 
 func (a *Audit) doPurge() {
 	log := a.log
-	if a.conf.ExpireHours == 0 {
+	if a.conf.ExpireHours == 0 || !a.isFileSink() {
 		return
 	}
 
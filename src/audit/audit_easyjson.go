@@ -51,6 +51,14 @@ func easyjsonF2c44427EncodeAudit(out *jwriter.Writer, in event) {
 	first = false
 	out.RawString("\"user_host\":")
 	out.String(string(in.UserHost))
+	if in.DB != "" {
+		if !first {
+			out.RawByte(',')
+		}
+		first = false
+		out.RawString("\"db\":")
+		out.String(string(in.DB))
+	}
 	if !first {
 		out.RawByte(',')
 	}
@@ -81,6 +89,46 @@ func easyjsonF2c44427EncodeAudit(out *jwriter.Writer, in event) {
 	first = false
 	out.RawString("\"query_rows\":")
 	out.Uint64(uint64(in.QueryRows))
+	if in.Rule != "" {
+		if !first {
+			out.RawByte(',')
+		}
+		first = false
+		out.RawString("\"rule\":")
+		out.String(string(in.Rule))
+	}
+	if in.Params != "" {
+		if !first {
+			out.RawByte(',')
+		}
+		first = false
+		out.RawString("\"params\":")
+		out.String(string(in.Params))
+	}
+	if in.MetaVersionBefore != 0 {
+		if !first {
+			out.RawByte(',')
+		}
+		first = false
+		out.RawString("\"meta_version_before\":")
+		out.Int64(int64(in.MetaVersionBefore))
+	}
+	if in.MetaVersionAfter != 0 {
+		if !first {
+			out.RawByte(',')
+		}
+		first = false
+		out.RawString("\"meta_version_after\":")
+		out.Int64(int64(in.MetaVersionAfter))
+	}
+	if in.Error != "" {
+		if !first {
+			out.RawByte(',')
+		}
+		first = false
+		out.RawString("\"error\":")
+		out.String(string(in.Error))
+	}
 	out.RawByte('}')
 }
 
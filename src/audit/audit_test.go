@@ -10,8 +10,10 @@ package audit
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -49,9 +51,9 @@ func TestAudit(t *testing.T) {
 		threadID := uint32(i)
 		query := "select a,b,cd from table1 where a=b and c=d and e=d group by id order\n by desc"
 		if i%2 == 0 {
-			audit.LogWriteEvent(typ, user, host, threadID, query, 0, 0, time.Now())
+			audit.LogWriteEvent(typ, user, host, "db1", threadID, query, 0, 0, time.Now())
 		} else {
-			audit.LogReadEvent(typ, user, host, threadID, query, 0, 0, time.Now())
+			audit.LogReadEvent(typ, user, host, "db1", threadID, query, 0, 0, time.Now())
 		}
 	}
 }
@@ -85,9 +87,9 @@ func TestAuditMultiThread(t *testing.T) {
 				threadID := uint32(i)
 				query := "select a,b,cd from table1 where a=b and c=d and e=d group by id order\n by desc"
 				if i%2 == 0 {
-					a.LogWriteEvent(typ, user, host, threadID, query, 0, 0, time.Now())
+					a.LogWriteEvent(typ, user, host, "db1", threadID, query, 0, 0, time.Now())
 				} else {
-					a.LogReadEvent(typ, user, host, threadID, query, 0, 0, time.Now())
+					a.LogReadEvent(typ, user, host, "db1", threadID, query, 0, 0, time.Now())
 				}
 			}
 			wait.Done()
@@ -112,7 +114,6 @@ func TestPurge(t *testing.T) {
 	audit := NewAudit(log, conf)
 	err := audit.Init()
 	assert.Nil(t, err)
-	defer audit.Close()
 
 	n := 10000
 	for i := 0; i < n; i++ {
@@ -122,12 +123,13 @@ func TestPurge(t *testing.T) {
 		threadID := uint32(i)
 		query := "select a,b,cd from table1 where a=b and c=d and e=d group by id order\n by desc"
 		if i%2 == 0 {
-			audit.LogWriteEvent(typ, user, host, threadID, query, 0, 0, time.Now())
+			audit.LogWriteEvent(typ, user, host, "db1", threadID, query, 0, 0, time.Now())
 		} else {
-			audit.LogReadEvent(typ, user, host, threadID, query, 0, 0, time.Now())
+			audit.LogReadEvent(typ, user, host, "db1", threadID, query, 0, 0, time.Now())
 		}
 	}
 	// first the close the audit to stop the event writing.
+	audit.Close()
 
 	logs, _ := audit.rfile.GetOldLogInfos()
 	// purge the old log.
@@ -170,9 +172,42 @@ func TestAuditBench(t *testing.T) {
 			host := "127.0.0.1:8899"
 			threadID := uint32(i)
 			query := "select a,b,cd from table1 where a=b and c=d and e=d group by id order\n by desc"
-			audit.LogWriteEvent(typ, user, host, threadID, query, 0, 0, time.Now())
+			audit.LogWriteEvent(typ, user, host, "db1", threadID, query, 0, 0, time.Now())
 		}
 		took := time.Since(now)
 		fmt.Printf(" LOOP\t%v COST %v, avg:%v/s\n", N, took, (int64(N)/(took.Nanoseconds()/1e6))*1000)
 	}
 }
+
+func TestAuditFirewall(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	tmpDir := fakedb.GetTmpDir("", "radon_audit_", log)
+	defer os.RemoveAll(tmpDir)
+	conf := &config.AuditConfig{
+		Mode:        NULL,
+		MaxSize:     102400,
+		ExpireHours: 1,
+		LogDir:      tmpDir,
+	}
+
+	audit := NewAudit(log, conf)
+	err := audit.Init()
+	assert.Nil(t, err)
+
+	audit.LogReadEvent("SELECT", "u1", "127.0.0.1:8899", "db1", 1, "select 1", 0, 0, time.Now())
+	audit.LogFirewallEvent("u1", "127.0.0.1:8899", 1, "delete from t1", "scatter-delete", time.Now())
+	audit.Close()
+
+	files, err := filepath.Glob(filepath.Join(tmpDir, prefix+"*"))
+	assert.Nil(t, err)
+	var data []byte
+	for _, file := range files {
+		b, err := ioutil.ReadFile(file)
+		assert.Nil(t, err)
+		data = append(data, b...)
+	}
+	got := string(data)
+	assert.Equal(t, 1, strings.Count(got, "\n"))
+	assert.Contains(t, got, `"command_type":"FIREWALL","argument":"delete from t1","status":1,"query_rows":0,"rule":"scatter-delete"}`)
+}
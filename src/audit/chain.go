@@ -0,0 +1,245 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// The hash chain of the audit files:
+// hash(n) = hex(hmac-sha256(key, hash(n-1) + event(n))), the event(n) ends with the sequence number n.
+// The chain continues across the files, the first event of a file is chained to the last event of the previous file,
+// the hash of the event before the first one is empty.
+// The JSON event is appended with the '"seq"' and '"hash"' fields, the CSV record is appended with the seq and hash columns.
+
+const (
+	hashLen = sha256.Size * 2
+
+	// The max line of the JSON events.
+	maxLineSize = 64 * 1024 * 1024
+)
+
+var (
+	jsonSeqPrefix  = []byte(`,"seq":`)
+	jsonHashPrefix = []byte(`,"hash":"`)
+	jsonHashSuffix = []byte(`"}`)
+)
+
+// ChainHead tuple, the last event of the hash chain.
+type ChainHead struct {
+	Hash string `json:"hash"`
+	Seq  uint64 `json:"seq"`
+}
+
+// loadHashKey reads the HMAC key from the file.
+func loadHashKey(path string) ([]byte, error) {
+	if path == "" {
+		return nil, errors.New("audit.hash.chain.requires.the.hash-key-file")
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key := bytes.TrimSpace(b)
+	if len(key) == 0 {
+		return nil, errors.Errorf("audit.hash.key.file[%s].is.empty", path)
+	}
+	return key, nil
+}
+
+func chainHash(key []byte, prev string, b []byte) string {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(prev))
+	h.Write(b)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// appendSeq returns the event with the sequence number.
+func appendSeq(b []byte, seq uint64, csv bool) []byte {
+	if csv {
+		return strconv.AppendUint(append(b, ','), seq, 10)
+	}
+	// The JSON object ends with '}'.
+	out := make([]byte, 0, len(b)+len(jsonSeqPrefix)+20)
+	out = append(out, b[:len(b)-1]...)
+	out = append(out, jsonSeqPrefix...)
+	out = strconv.AppendUint(out, seq, 10)
+	return append(out, '}')
+}
+
+// splitSeq returns the sequence number of the event.
+func splitSeq(b []byte, csv bool) (uint64, bool) {
+	var i int
+	if csv {
+		i = bytes.LastIndexByte(b, ',') + 1
+	} else {
+		if !bytes.HasSuffix(b, []byte{'}'}) {
+			return 0, false
+		}
+		b = b[:len(b)-1]
+		if i = bytes.LastIndex(b, jsonSeqPrefix); i < 0 {
+			return 0, false
+		}
+		i += len(jsonSeqPrefix)
+	}
+	seq, err := strconv.ParseUint(string(b[i:]), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return seq, true
+}
+
+// appendHash returns the event with the hash.
+func appendHash(b []byte, hash string, csv bool) []byte {
+	if csv {
+		return append(append(b, ','), hash...)
+	}
+	// The JSON object ends with '}'.
+	out := make([]byte, 0, len(b)+len(jsonHashPrefix)+hashLen+len(jsonHashSuffix)-1)
+	out = append(out, b[:len(b)-1]...)
+	out = append(out, jsonHashPrefix...)
+	out = append(out, hash...)
+	return append(out, jsonHashSuffix...)
+}
+
+// splitHash returns the event without the hash and the hash.
+func splitHash(line []byte, csv bool) ([]byte, string, bool) {
+	if csv {
+		if len(line) <= hashLen || line[len(line)-hashLen-1] != ',' {
+			return nil, "", false
+		}
+		return line[:len(line)-hashLen-1], string(line[len(line)-hashLen:]), true
+	}
+	n := len(line) - len(jsonHashSuffix) - hashLen - len(jsonHashPrefix)
+	if n <= 0 || !bytes.HasSuffix(line, jsonHashSuffix) || !bytes.Equal(line[n:n+len(jsonHashPrefix)], jsonHashPrefix) {
+		return nil, "", false
+	}
+	hash := string(line[n+len(jsonHashPrefix) : len(line)-len(jsonHashSuffix)])
+	b := make([]byte, 0, n+1)
+	b = append(b, line[:n]...)
+	return append(b, '}'), hash, true
+}
+
+// VerifyFile used to verify the hash chain of the audit file, the file of the csv sink has the '.csv' extension.
+// The head is the last event of the previous file, or the zero head for the first file of the chain.
+// Returns the head of the verified events, and the error if the file was edited.
+// The files must be verified in order, a removed file breaks the chain of the next one,
+// the events cut off from the end are found by comparing the head with the one anchored from the 'SHOW AUDIT STATUS'.
+func VerifyFile(path string, key []byte, head ChainHead) (ChainHead, error) {
+	csv := strings.HasSuffix(path, csvExtension)
+	err := scanFile(path, csv, func(n int, line []byte) error {
+		b, hash, ok := splitHash(line, csv)
+		if !ok {
+			return errors.Errorf("audit.verify.event[%d].hash.not.found", n)
+		}
+		seq, ok := splitSeq(b, csv)
+		if !ok {
+			return errors.Errorf("audit.verify.event[%d].seq.not.found", n)
+		}
+		if seq != head.Seq+1 {
+			return errors.Errorf("audit.verify.event[%d].seq[%d].want[%d]", n, seq, head.Seq+1)
+		}
+		if want := chainHash(key, head.Hash, b); !hmac.Equal([]byte(want), []byte(hash)) {
+			return errors.Errorf("audit.verify.event[%d].hash.mismatch", n)
+		}
+		head = ChainHead{Hash: hash, Seq: seq}
+		return nil
+	})
+	return head, err
+}
+
+// lastHead returns the head of the last file in the dir, the zero head if no file or no hash.
+func lastHead(dir string, ext string) (ChainHead, error) {
+	head := ChainHead{}
+	files, err := filepath.Glob(filepath.Join(dir, prefix+"*"+ext))
+	if err != nil || len(files) == 0 {
+		return head, err
+	}
+	// The files are named by the timestamp.
+	sort.Strings(files)
+	csv := ext == csvExtension
+	err = scanFile(files[len(files)-1], csv, func(n int, line []byte) error {
+		head = ChainHead{}
+		if b, hash, ok := splitHash(line, csv); ok {
+			if seq, ok := splitSeq(b, csv); ok {
+				head = ChainHead{Hash: hash, Seq: seq}
+			}
+		}
+		return nil
+	})
+	return head, err
+}
+
+// scanFile calls the fn with each event of the file.
+func scanFile(path string, csv bool, fn func(n int, line []byte) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if csv {
+		return scanCSV(f, fn)
+	}
+	return scanJSON(f, fn)
+}
+
+func scanJSON(r io.Reader, fn func(n int, line []byte) error) error {
+	n := 0
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		n++
+		if err := fn(n, scanner.Bytes()); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// scanCSV scans the raw bytes of the records, a record ends with the line break outside the quotes.
+func scanCSV(r io.Reader, fn func(n int, line []byte) error) error {
+	n := 0
+	reader := bufio.NewReader(r)
+	var record []byte
+	for {
+		line, err := reader.ReadBytes('\n')
+		record = append(record, line...)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		// The quotes are escaped by doubling, the odd count means the line break is in the quoted field.
+		if bytes.Count(record, []byte{'"'})%2 == 0 || err == io.EOF {
+			b := bytes.TrimSuffix(record, []byte("\n"))
+			record = nil
+			if len(b) > 0 {
+				n++
+				if err := fn(n, b); err != nil {
+					return err
+				}
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
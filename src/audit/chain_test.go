@@ -0,0 +1,213 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package audit
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"config"
+	"fakedb"
+
+	"github.com/fortytw2/leaktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestAppendHash(t *testing.T) {
+	key := []byte("key")
+	b := appendSeq([]byte(`{"a":1}`), 12, false)
+	assert.Equal(t, `{"a":1,"seq":12}`, string(b))
+	seq, ok := splitSeq(b, false)
+	assert.True(t, ok)
+	assert.EqualValues(t, 12, seq)
+
+	hash := chainHash(key, "", b)
+	assert.NotEqual(t, hash, chainHash([]byte("other"), "", b))
+	line := appendHash(b, hash, false)
+	assert.Equal(t, `{"a":1,"seq":12,"hash":"`+hash+`"}`, string(line))
+
+	got, gotHash, ok := splitHash(line, false)
+	assert.True(t, ok)
+	assert.Equal(t, string(b), string(got))
+	assert.Equal(t, hash, gotHash)
+
+	_, _, ok = splitHash([]byte(`{"a":1}`), false)
+	assert.False(t, ok)
+	_, ok = splitSeq([]byte(`{"a":1}`), false)
+	assert.False(t, ok)
+
+	b = appendSeq([]byte(`a,"b""c"`), 3, true)
+	line = appendHash(b, hash, true)
+	assert.Equal(t, `a,"b""c",3,`+hash, string(line))
+	got, gotHash, ok = splitHash(line, true)
+	assert.True(t, ok)
+	assert.Equal(t, hash, gotHash)
+	seq, ok = splitSeq(got, true)
+	assert.True(t, ok)
+	assert.EqualValues(t, 3, seq)
+}
+
+func testHashChain(t *testing.T, sink string, ext string) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	tmpDir := fakedb.GetTmpDir("", "radon_audit_", log)
+	defer os.RemoveAll(tmpDir)
+	keyFile := filepath.Join(tmpDir, "audit.key")
+	err := ioutil.WriteFile(keyFile, []byte("secret\n"), 0600)
+	assert.Nil(t, err)
+	key := []byte("secret")
+
+	conf := config.DefaultAuditConfig()
+	conf.Mode = ALL
+	conf.LogDir = filepath.Join(tmpDir, "audit")
+	conf.MaxSize = 4096
+	conf.Sink = sink
+	conf.HashChain = true
+	conf.HashKeyFile = keyFile
+
+	logEvents := func(n int) *ChainHead {
+		audit := NewAudit(log, conf)
+		err := audit.Init()
+		assert.Nil(t, err)
+		for i := 0; i < n; i++ {
+			audit.LogWriteEvent("INSERT", "u1", "127.0.0.1:8899", "db1", uint32(i), "insert into t1 values(1, 'a,\"b\"\nc')", 0, 1, time.Now())
+			// The rotated file is named by the millisecond.
+			time.Sleep(time.Millisecond * 2)
+		}
+		for audit.Status().Logged < int64(n) {
+			time.Sleep(time.Millisecond * 10)
+		}
+		head := audit.Status().ChainHead
+		audit.Close()
+		return head
+	}
+
+	// The chain continues across the files and the restart.
+	n := 100
+	logEvents(n / 2)
+	head := logEvents(n / 2)
+	assert.EqualValues(t, n, head.Seq)
+
+	files, err := filepath.Glob(filepath.Join(conf.LogDir, prefix+"*"+ext))
+	assert.Nil(t, err)
+	assert.True(t, len(files) > 2)
+	sort.Strings(files)
+	got := ChainHead{}
+	for _, file := range files {
+		got, err = VerifyFile(file, key, got)
+		assert.Nil(t, err, file)
+	}
+	assert.Equal(t, *head, got)
+
+	// The wrong key.
+	_, err = VerifyFile(files[0], []byte("other"), ChainHead{})
+	assert.Equal(t, "audit.verify.event[1].hash.mismatch", err.Error())
+
+	// The file removed, the next file doesn't chain to the head.
+	first, err := VerifyFile(files[0], key, ChainHead{})
+	assert.Nil(t, err)
+	_, err = VerifyFile(files[2], key, first)
+	assert.NotNil(t, err)
+
+	// Edit the event.
+	file := files[0]
+	b, err := ioutil.ReadFile(file)
+	assert.Nil(t, err)
+	err = ioutil.WriteFile(file, []byte(strings.Replace(string(b), "thread_id\":1,", "thread_id\":2,", 1)), 0644)
+	assert.Nil(t, err)
+	if ext == csvExtension {
+		err = ioutil.WriteFile(file, []byte(strings.Replace(string(b), "127.0.0.1:8899,db1,1,", "127.0.0.1:8899,db1,2,", 1)), 0644)
+		assert.Nil(t, err)
+	}
+	got, err = VerifyFile(file, key, ChainHead{})
+	assert.Equal(t, "audit.verify.event[2].hash.mismatch", err.Error())
+	assert.EqualValues(t, 1, got.Seq)
+
+	// Remove the first event.
+	lines := strings.SplitN(string(b), "\n", 2)
+	if ext == csvExtension {
+		// The first record has a line break in the quoted field.
+		lines = strings.SplitN(string(b), "\n", 3)
+		lines = []string{"", lines[2]}
+	}
+	err = ioutil.WriteFile(file, []byte(lines[1]), 0644)
+	assert.Nil(t, err)
+	_, err = VerifyFile(file, key, ChainHead{})
+	assert.Equal(t, "audit.verify.event[1].seq[2].want[1]", err.Error())
+}
+
+func TestHashChainJSON(t *testing.T) {
+	testHashChain(t, SinkFile, extension)
+}
+
+func TestHashChainCSV(t *testing.T) {
+	testHashChain(t, SinkCSV, csvExtension)
+}
+
+func TestVerifyFileError(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	tmpDir := fakedb.GetTmpDir("", "radon_audit_", log)
+	defer os.RemoveAll(tmpDir)
+	key := []byte("secret")
+
+	_, err := VerifyFile(filepath.Join(tmpDir, "not-exists.log"), key, ChainHead{})
+	assert.NotNil(t, err)
+
+	// No hash.
+	file := filepath.Join(tmpDir, "audit-1.log")
+	err = ioutil.WriteFile(file, []byte("{\"a\":1}\n"), 0644)
+	assert.Nil(t, err)
+	_, err = VerifyFile(file, key, ChainHead{})
+	assert.Equal(t, "audit.verify.event[1].hash.not.found", err.Error())
+
+	file = filepath.Join(tmpDir, "audit-1.csv")
+	err = ioutil.WriteFile(file, []byte("a,b\n"), 0644)
+	assert.Nil(t, err)
+	_, err = VerifyFile(file, key, ChainHead{})
+	assert.Equal(t, "audit.verify.event[1].hash.not.found", err.Error())
+
+	// No seq.
+	file = filepath.Join(tmpDir, "audit-2.log")
+	line := appendHash([]byte(`{"a":1}`), chainHash(key, "", []byte(`{"a":1}`)), false)
+	err = ioutil.WriteFile(file, append(line, '\n'), 0644)
+	assert.Nil(t, err)
+	_, err = VerifyFile(file, key, ChainHead{})
+	assert.Equal(t, "audit.verify.event[1].seq.not.found", err.Error())
+}
+
+func TestHashKeyError(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	tmpDir := fakedb.GetTmpDir("", "radon_audit_", log)
+	defer os.RemoveAll(tmpDir)
+
+	conf := config.DefaultAuditConfig()
+	conf.LogDir = tmpDir
+	conf.HashChain = true
+	{
+		audit := NewAudit(log, conf)
+		err := audit.Init()
+		assert.Equal(t, "audit.hash.chain.requires.the.hash-key-file", err.Error())
+	}
+
+	{
+		conf.HashKeyFile = filepath.Join(tmpDir, "audit.key")
+		err := ioutil.WriteFile(conf.HashKeyFile, []byte(" \n"), 0600)
+		assert.Nil(t, err)
+		audit := NewAudit(log, conf)
+		err = audit.Init()
+		assert.Equal(t, "audit.hash.key.file["+conf.HashKeyFile+"].is.empty", err.Error())
+	}
+}
@@ -0,0 +1,141 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package audit
+
+import (
+	"strings"
+
+	"config"
+
+	"github.com/pkg/errors"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+)
+
+const (
+	// FilterStatusOK enum, matches the successful events.
+	FilterStatusOK = "ok"
+
+	// FilterStatusError enum, matches the failed events.
+	FilterStatusError = "error"
+)
+
+// filter tuple, the compiled filter rule.
+type filter struct {
+	users  map[string]bool
+	dbs    map[string]bool
+	tables map[string]bool
+	types  map[string]bool
+	status string
+}
+
+func toSet(list []string, upper bool) map[string]bool {
+	if len(list) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(list))
+	for _, v := range list {
+		if upper {
+			v = strings.ToUpper(v)
+		}
+		set[v] = true
+	}
+	return set
+}
+
+// newFilters used to compile the filter rules.
+func newFilters(confs []*config.AuditFilterConfig) ([]*filter, error) {
+	var filters []*filter
+	for i, conf := range confs {
+		if conf == nil {
+			return nil, errors.Errorf("audit.filter[%d].can't.be.null", i)
+		}
+		status := strings.ToLower(conf.Status)
+		switch status {
+		case "", FilterStatusOK, FilterStatusError:
+		default:
+			return nil, errors.Errorf("audit.filter[%d].unsupported.status[%s]", i, conf.Status)
+		}
+		filters = append(filters, &filter{
+			users:  toSet(conf.Users, false),
+			dbs:    toSet(conf.DBs, false),
+			tables: toSet(conf.Tables, false),
+			types:  toSet(conf.Types, true),
+			status: status,
+		})
+	}
+	return filters, nil
+}
+
+// match returns true if the event matches all the non-empty fields of the filter,
+// the tables are resolved only if the filter has the tables.
+func (f *filter) match(e *event, tables func() []string) bool {
+	if f.users != nil && !f.users[e.User] {
+		return false
+	}
+	if f.dbs != nil && !f.dbs[e.DB] {
+		return false
+	}
+	if f.types != nil && !f.types[e.CommandType] {
+		return false
+	}
+	switch f.status {
+	case FilterStatusOK:
+		if e.Status != 0 {
+			return false
+		}
+	case FilterStatusError:
+		if e.Status == 0 {
+			return false
+		}
+	}
+	if f.tables != nil {
+		for _, table := range tables() {
+			if f.tables[table] {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// tablesOf returns the tables of the query, each table is in both the 'table' and the 'db.table' forms,
+// the unqualified table is qualified by the database of the session.
+func tablesOf(db string, query string) []string {
+	node, err := sqlparser.Parse(query)
+	if err != nil {
+		return nil
+	}
+
+	var tables []string
+	add := func(table sqlparser.TableName) {
+		if table.Name.IsEmpty() || (table.Qualifier.IsEmpty() && strings.EqualFold(table.Name.String(), "dual")) {
+			return
+		}
+		qualifier := db
+		if !table.Qualifier.IsEmpty() {
+			qualifier = table.Qualifier.String()
+		}
+		tables = append(tables, table.Name.String())
+		if qualifier != "" {
+			tables = append(tables, qualifier+"."+table.Name.String())
+		}
+	}
+	sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+		switch node := node.(type) {
+		case sqlparser.TableName:
+			add(node)
+		case *sqlparser.ColName:
+			// The qualifier of the column is the table alias.
+			return false, nil
+		}
+		return true, nil
+	}, node)
+	return tables
+}
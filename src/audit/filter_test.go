@@ -0,0 +1,130 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"config"
+	"fakedb"
+
+	"github.com/fortytw2/leaktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestTablesOf(t *testing.T) {
+	tests := []struct {
+		db    string
+		query string
+		want  []string
+	}{
+		{"db1", "select a.id from t1 as a join db2.t2 on a.id=t2.id", []string{"t1", "db1.t1", "t2", "db2.t2"}},
+		{"", "insert into t1(a) values(1)", []string{"t1"}},
+		{"db1", "update t1 set a=1 where id in (select id from t2)", []string{"t1", "db1.t1", "t2", "db1.t2"}},
+		{"db1", "delete from db3.t3 where id=1", []string{"t3", "db3.t3"}},
+		{"db1", "drop table t1, t2", []string{"t1", "db1.t1", "t2", "db1.t2"}},
+		{"db1", "select 1", nil},
+		{"db1", "not a sql", nil},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.want, tablesOf(test.db, test.query), test.query)
+	}
+}
+
+func TestFilterMatch(t *testing.T) {
+	filters, err := newFilters([]*config.AuditFilterConfig{
+		{Users: []string{"u1"}, Types: []string{"delete", "UPDATE"}},
+		{DBs: []string{"db2"}, Status: "ERROR"},
+		{Tables: []string{"db1.secret"}},
+	})
+	assert.Nil(t, err)
+
+	tests := []struct {
+		e    *event
+		want []bool
+	}{
+		{&event{User: "u1", DB: "db1", CommandType: "DELETE", Argument: "delete from t1"}, []bool{true, false, false}},
+		{&event{User: "u2", DB: "db1", CommandType: "DELETE", Argument: "delete from t1"}, []bool{false, false, false}},
+		{&event{User: "u1", DB: "db1", CommandType: "SELECT", Argument: "select * from t1"}, []bool{false, false, false}},
+		{&event{User: "u2", DB: "db2", CommandType: "SELECT", Argument: "select * from t1", Status: 1}, []bool{false, true, false}},
+		{&event{User: "u2", DB: "db2", CommandType: "SELECT", Argument: "select * from t1"}, []bool{false, false, false}},
+		{&event{User: "u2", DB: "db1", CommandType: "SELECT", Argument: "select * from secret"}, []bool{false, false, true}},
+		{&event{User: "u2", DB: "db2", CommandType: "SELECT", Argument: "select * from db1.secret"}, []bool{false, false, true}},
+	}
+	for _, test := range tests {
+		for i, f := range filters {
+			got := f.match(test.e, func() []string { return tablesOf(test.e.DB, test.e.Argument) })
+			assert.Equal(t, test.want[i], got, "%+v.filter[%d]", test.e, i)
+		}
+	}
+}
+
+func TestFilterError(t *testing.T) {
+	_, err := newFilters([]*config.AuditFilterConfig{{Status: "failed"}})
+	assert.Equal(t, "audit.filter[0].unsupported.status[failed]", err.Error())
+
+	_, err = newFilters([]*config.AuditFilterConfig{{}, nil})
+	assert.Equal(t, "audit.filter[1].can't.be.null", err.Error())
+}
+
+func TestAuditFilters(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	tmpDir := fakedb.GetTmpDir("", "radon_audit_", log)
+	defer os.RemoveAll(tmpDir)
+	conf := config.DefaultAuditConfig()
+	conf.Mode = ALL
+	conf.LogDir = tmpDir
+	conf.Filters = []*config.AuditFilterConfig{
+		{Tables: []string{"t2"}},
+	}
+
+	audit := NewAudit(log, conf)
+	err := audit.Init()
+	assert.Nil(t, err)
+
+	audit.LogReadEvent("SELECT", "u1", "127.0.0.1:8899", "db1", 1, "select * from t1", 0, 0, time.Now())
+	audit.LogReadEvent("SELECT", "u1", "127.0.0.1:8899", "db1", 1, "select * from t2", 0, 0, time.Now())
+	// The firewall events are always logged.
+	audit.LogFirewallEvent("u1", "127.0.0.1:8899", 1, "delete from t1", "scatter-delete", time.Now())
+	for audit.Status().Logged+audit.Status().Filtered < 3 {
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	// Change the filters at runtime.
+	err = audit.SetFilters([]*config.AuditFilterConfig{{Types: []string{"delete"}}})
+	assert.Nil(t, err)
+	audit.LogWriteEvent("DELETE", "u1", "127.0.0.1:8899", "db1", 1, "delete from t1", 0, 0, time.Now())
+	audit.LogReadEvent("SELECT", "u1", "127.0.0.1:8899", "db1", 1, "select * from t2", 0, 0, time.Now())
+
+	err = audit.SetFilters([]*config.AuditFilterConfig{{Status: "unknown"}})
+	assert.NotNil(t, err)
+	audit.Close()
+
+	status := audit.Status()
+	assert.Equal(t, SinkFile, status.Sink)
+	assert.Equal(t, []*config.AuditFilterConfig{{Types: []string{"delete"}}}, status.Filters)
+	assert.EqualValues(t, 3, status.Logged)
+	assert.EqualValues(t, 2, status.Filtered)
+	assert.EqualValues(t, 0, status.Failed)
+	assert.True(t, strings.HasPrefix(status.File, prefix))
+
+	files, err := filepath.Glob(filepath.Join(tmpDir, prefix+"*"))
+	assert.Nil(t, err)
+	got := readFiles(t, files)
+	assert.Equal(t, 3, strings.Count(got, "\n"))
+	assert.Contains(t, got, `"user_host":"127.0.0.1:8899","db":"db1","thread_id":1,"command_type":"SELECT","argument":"select * from t2"`)
+	assert.Contains(t, got, `"command_type":"FIREWALL"`)
+	assert.Contains(t, got, `"command_type":"DELETE"`)
+}
@@ -0,0 +1,254 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package audit
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"log/syslog"
+	"net"
+	"sync"
+	"time"
+
+	"xbase"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// SinkFile enum, JSON lines to the rotate files.
+	SinkFile = "file"
+
+	// SinkCSV enum, CSV records to the rotate files.
+	SinkCSV = "csv"
+
+	// SinkSyslog enum, JSON events to the syslog.
+	SinkSyslog = "syslog"
+
+	// SinkSocket enum, JSON lines to the socket.
+	SinkSocket = "socket"
+)
+
+const (
+	syslogTag = "radon-audit"
+
+	// The dial and write timeout of the socket sink.
+	socketTimeout = 5 * time.Second
+)
+
+// sink interface.
+type sink interface {
+	write(e *event) error
+	close()
+}
+
+// encodeCSV returns the CSV record of the event without the line break.
+func encodeCSV(e *event) []byte {
+	return encodeCSVRecord([]string{
+		e.Start.Format(time.RFC3339Nano),
+		e.End.Format(time.RFC3339Nano),
+		fmt.Sprintf("%d", e.Cost),
+		e.User,
+		e.UserHost,
+		e.DB,
+		fmt.Sprintf("%d", e.ThreadID),
+		e.CommandType,
+		e.Argument,
+		fmt.Sprintf("%d", e.Status),
+		fmt.Sprintf("%d", e.QueryRows),
+		e.Rule,
+		e.Params,
+		fmt.Sprintf("%d", e.MetaVersionBefore),
+		fmt.Sprintf("%d", e.MetaVersionAfter),
+		e.Error,
+	})
+}
+
+func encodeCSVRecord(record []string) []byte {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write(record)
+	w.Flush()
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+}
+
+// fileSink writes the events to the rotate files, the JSON lines or the CSV records.
+type fileSink struct {
+	rfile xbase.RotateFile
+	csv   bool
+	// The HMAC key of the hash chain, nil if the hash chain is disabled.
+	key []byte
+
+	mu sync.RWMutex
+	// The last event of the hash chain.
+	last ChainHead
+}
+
+func newFileSink(rfile xbase.RotateFile, csv bool, key []byte, head ChainHead) *fileSink {
+	return &fileSink{
+		rfile: rfile,
+		csv:   csv,
+		key:   key,
+		last:  head,
+	}
+}
+
+func (s *fileSink) write(e *event) error {
+	var b []byte
+	if s.csv {
+		b = encodeCSV(e)
+	} else {
+		var err error
+		if b, err = e.MarshalJSON(); err != nil {
+			b = []byte(err.Error())
+		}
+	}
+
+	// The chain continues after the file is rotated, the first event of the new file is chained to the last one.
+	if s.key != nil {
+		s.mu.Lock()
+		seq := s.last.Seq + 1
+		b = appendSeq(b, seq, s.csv)
+		hash := chainHash(s.key, s.last.Hash, b)
+		b = appendHash(b, hash, s.csv)
+		s.last = ChainHead{Hash: hash, Seq: seq}
+		s.mu.Unlock()
+	}
+	_, err := s.rfile.Write(append(b, '\n'))
+	return err
+}
+
+// head returns the last event of the hash chain.
+func (s *fileSink) head() ChainHead {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.last
+}
+
+func (s *fileSink) close() {
+	s.rfile.Sync()
+	s.rfile.Close()
+}
+
+// syslogSink writes the JSON events to the syslog.
+type syslogSink struct {
+	writer *syslog.Writer
+}
+
+func newSyslogSink(network, address string) (*syslogSink, error) {
+	writer, err := syslog.Dial(network, address, syslog.LOG_INFO|syslog.LOG_LOCAL0, syslogTag)
+	if err != nil {
+		return nil, err
+	}
+	return &syslogSink{writer: writer}, nil
+}
+
+func (s *syslogSink) write(e *event) error {
+	b, err := e.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	return s.writer.Info(string(b))
+}
+
+func (s *syslogSink) close() {
+	s.writer.Close()
+}
+
+// socketSink writes the JSON lines to the socket, reconnects once if the write fails.
+// The write has a deadline, a stalled peer fails the event instead of blocking the consumer.
+type socketSink struct {
+	network string
+	address string
+	conn    net.Conn
+}
+
+func newSocketSink(network, address string) (*socketSink, error) {
+	if network == "" {
+		network = "unix"
+	}
+	s := &socketSink{
+		network: network,
+		address: address,
+	}
+	if err := s.dial(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *socketSink) dial() error {
+	conn, err := net.DialTimeout(s.network, s.address, socketTimeout)
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	return nil
+}
+
+func (s *socketSink) write(e *event) error {
+	b, err := e.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	if s.conn != nil {
+		if err = s.writeConn(b); err == nil {
+			return nil
+		}
+		s.conn.Close()
+		s.conn = nil
+	}
+	if err = s.dial(); err != nil {
+		return err
+	}
+	return s.writeConn(b)
+}
+
+func (s *socketSink) writeConn(b []byte) error {
+	if err := s.conn.SetWriteDeadline(time.Now().Add(socketTimeout)); err != nil {
+		return err
+	}
+	_, err := s.conn.Write(b)
+	return err
+}
+
+func (s *socketSink) close() {
+	if s.conn != nil {
+		s.conn.Close()
+	}
+}
+
+// newSink creates the sink of the config, the rotate file is used by the file and csv sinks.
+func (a *Audit) newSink() (sink, error) {
+	conf := a.conf
+	switch conf.Sink {
+	case "", SinkFile, SinkCSV:
+		var key []byte
+		var head ChainHead
+		if conf.HashChain {
+			var err error
+			if key, err = loadHashKey(conf.HashKeyFile); err != nil {
+				return nil, err
+			}
+			// The chain continues from the last file of the previous run.
+			if head, err = lastHead(conf.LogDir, a.extension()); err != nil {
+				return nil, err
+			}
+		}
+		return newFileSink(a.rfile, conf.Sink == SinkCSV, key, head), nil
+	case SinkSyslog:
+		return newSyslogSink(conf.Network, conf.Address)
+	case SinkSocket:
+		return newSocketSink(conf.Network, conf.Address)
+	}
+	return nil, errors.Errorf("audit.unsupported.sink[%s]", conf.Sink)
+}
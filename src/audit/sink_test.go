@@ -0,0 +1,204 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package audit
+
+import (
+	"bufio"
+	"encoding/csv"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"config"
+	"fakedb"
+
+	"github.com/fortytw2/leaktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func readFiles(t *testing.T, files []string) string {
+	var data []byte
+	for _, file := range files {
+		b, err := ioutil.ReadFile(file)
+		assert.Nil(t, err)
+		data = append(data, b...)
+	}
+	return string(data)
+}
+
+func TestAuditCSVSink(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	tmpDir := fakedb.GetTmpDir("", "radon_audit_", log)
+	defer os.RemoveAll(tmpDir)
+	conf := config.DefaultAuditConfig()
+	conf.Mode = ALL
+	conf.LogDir = tmpDir
+	conf.Sink = SinkCSV
+
+	audit := NewAudit(log, conf)
+	err := audit.Init()
+	assert.Nil(t, err)
+	start := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+	audit.LogWriteEvent("INSERT", "u1", "127.0.0.1:8899", "db1", 7, "insert into t1 values(1, 'a,\"b\"\nc')", 0, 1, start)
+	audit.Close()
+
+	files, err := filepath.Glob(filepath.Join(tmpDir, prefix+"*"+csvExtension))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(files))
+	records, err := csv.NewReader(strings.NewReader(readFiles(t, files))).ReadAll()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(records))
+	record := records[0]
+	assert.Equal(t, 16, len(record))
+	assert.Equal(t, "2018-01-02T03:04:05Z", record[0])
+	assert.Equal(t, []string{"u1", "127.0.0.1:8899", "db1", "7", "INSERT", "insert into t1 values(1, 'a,\"b\"\nc')", "0", "1", "", "", "0", "0", ""}, record[3:])
+}
+
+func TestAuditSyslogSink(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	conf := config.DefaultAuditConfig()
+	conf.Mode = ALL
+	conf.Sink = SinkSyslog
+	conf.Network = "udp"
+	conf.Address = conn.LocalAddr().String()
+
+	audit := NewAudit(log, conf)
+	err = audit.Init()
+	assert.Nil(t, err)
+	audit.LogReadEvent("SELECT", "u1", "127.0.0.1:8899", "db1", 1, "select 1", 0, 0, time.Now())
+	audit.Close()
+	assert.EqualValues(t, 1, audit.Status().Logged)
+	assert.Equal(t, "", audit.Status().File)
+
+	buf := make([]byte, 4096)
+	conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+	n, _, err := conn.ReadFrom(buf)
+	assert.Nil(t, err)
+	got := string(buf[:n])
+	assert.Contains(t, got, syslogTag)
+	assert.Contains(t, got, `"user":"u1","user_host":"127.0.0.1:8899","db":"db1","thread_id":1,"command_type":"SELECT","argument":"select 1"`)
+}
+
+func TestAuditSocketSink(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	tmpDir := fakedb.GetTmpDir("", "radon_audit_", log)
+	defer os.RemoveAll(tmpDir)
+	path := filepath.Join(tmpDir, "audit.sock")
+	l, err := net.Listen("unix", path)
+	assert.Nil(t, err)
+	defer l.Close()
+
+	lines := make(chan string, 4)
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			scanner := bufio.NewScanner(c)
+			for scanner.Scan() {
+				lines <- scanner.Text()
+			}
+			c.Close()
+		}
+	}()
+
+	conf := config.DefaultAuditConfig()
+	conf.Mode = ALL
+	conf.Sink = SinkSocket
+	conf.Address = path
+
+	audit := NewAudit(log, conf)
+	err = audit.Init()
+	assert.Nil(t, err)
+	audit.LogReadEvent("SELECT", "u1", "127.0.0.1:8899", "db1", 1, "select 1", 0, 0, time.Now())
+	audit.LogWriteEvent("DELETE", "u1", "127.0.0.1:8899", "db1", 1, "delete from t1", 0, 0, time.Now())
+	audit.Close()
+
+	assert.Contains(t, <-lines, `"command_type":"SELECT"`)
+	assert.Contains(t, <-lines, `"command_type":"DELETE"`)
+}
+
+func TestAuditSinkError(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+
+	// Unsupported sink.
+	{
+		conf := config.DefaultAuditConfig()
+		conf.Sink = "kafka"
+		audit := NewAudit(log, conf)
+		err := audit.Init()
+		assert.Equal(t, "audit.unsupported.sink[kafka]", err.Error())
+	}
+
+	// Socket can't connect.
+	{
+		conf := config.DefaultAuditConfig()
+		conf.Sink = SinkSocket
+		conf.Address = "/tmp/radon_audit_not_exists.sock"
+		audit := NewAudit(log, conf)
+		err := audit.Init()
+		assert.NotNil(t, err)
+	}
+
+	// Invalid filters.
+	{
+		conf := config.DefaultAuditConfig()
+		conf.Filters = []*config.AuditFilterConfig{{Status: "x"}}
+		audit := NewAudit(log, conf)
+		err := audit.Init()
+		assert.Equal(t, "audit.filter[0].unsupported.status[x]", err.Error())
+	}
+}
+
+func TestAuditSocketSinkStalled(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	// The peer never reads, the write fails after the deadline.
+	client, server := net.Pipe()
+	defer server.Close()
+	s := &socketSink{
+		network: "unix",
+		address: "/tmp/radon_audit_not_exists.sock",
+		conn:    client,
+	}
+	err := s.write(&event{User: "u1"})
+	assert.NotNil(t, err)
+	s.close()
+}
+
+func TestAuditQueueFull(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	conf := config.DefaultAuditConfig()
+	conf.Mode = ALL
+
+	// No consumer, the events are dropped instead of blocking if the queue is full.
+	audit := NewAudit(log, conf)
+	defer audit.ticker.Stop()
+	n := cap(audit.queue) + 3
+	for i := 0; i < n; i++ {
+		audit.LogReadEvent("SELECT", "u1", "127.0.0.1:8899", "db1", 1, "select 1", 0, 0, time.Now())
+	}
+	status := audit.Status()
+	assert.Equal(t, cap(audit.queue), status.Queued)
+	assert.EqualValues(t, 3, status.Dropped)
+}
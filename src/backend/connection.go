@@ -41,6 +41,7 @@ type Connection interface {
 	LastErr() error
 	UseDB(string) error
 	Kill(string) error
+	KillQuery(string) error
 	Recycle()
 	Address() string
 	SetTimestamp(int64)
@@ -48,6 +49,8 @@ type Connection interface {
 	Execute(string) (*sqltypes.Result, error)
 	ExecuteStreamFetch(string) (driver.Rows, error)
 	ExecuteWithLimits(query string, timeout int, maxmem int) (*sqltypes.Result, error)
+	SetTxnID(uint64)
+	TxnID() uint64
 }
 
 type connection struct {
@@ -61,9 +64,13 @@ type connection struct {
 	pool         *Pool
 	lastErr      error // If lastErr is not nil, this connection should be closed.
 	killed       sync2.AtomicBool
+	txnID        sync2.AtomicInt64 // The id of the txn which holds the connection, 0 if none.
 	driver       driver.Conn
 	timestamp    int64 // Recycle timestamp, in seconds.
 	counters     *stats.Counters
+
+	// 1 if the connection is counted in the pool opened connections.
+	opened sync2.AtomicInt32
 }
 
 // NewConnection creates a new connection.
@@ -85,7 +92,13 @@ func (c *connection) Dial() error {
 	var err error
 	defer mysqlStats.Record("conn.dial", time.Now())
 
-	if c.driver, err = driver.NewConn(c.user, c.password, c.address, "", c.charset); err != nil {
+	if err = c.pool.tlsErr; err != nil {
+		c.log.Error("conn[%s].dial.tls.error:%+v", c.address, err)
+		c.counters.Add(poolCounterBackendDialError, 1)
+		c.Close()
+		return err
+	}
+	if c.driver, err = driver.NewTLSConn(c.user, c.password, c.address, "", c.charset, c.pool.tlsConfig); err != nil {
 		c.log.Error("conn[%s].dial.error:%+v", c.address, err)
 		c.counters.Add(poolCounterBackendDialError, 1)
 		c.Close()
@@ -93,6 +106,8 @@ func (c *connection) Dial() error {
 	}
 	c.connectionID = c.driver.ConnectionID()
 	monitor.BackendConnectionInc(c.address)
+	c.opened.Set(1)
+	c.pool.connOpened()
 	return nil
 }
 
@@ -106,6 +121,16 @@ func (c *connection) ID() uint32 {
 	return c.connectionID
 }
 
+// SetTxnID used to bind the connection to the txn.
+func (c *connection) SetTxnID(id uint64) {
+	c.txnID.Set(int64(id))
+}
+
+// TxnID returns the id of the txn which holds the connection.
+func (c *connection) TxnID() uint64 {
+	return uint64(c.txnID.Get())
+}
+
 // UseDB used to send a 'use database' query to MySQL.
 // This is SQLCOM_CHANGE_DB command not COM_INIT_DB.
 func (c *connection) UseDB(db string) error {
@@ -242,11 +267,31 @@ func (c *connection) Kill(reason string) error {
 	return nil
 }
 
+// KillQuery used to kill the running query of current connection, the connection is kept.
+func (c *connection) KillQuery(reason string) error {
+	kill, err := c.pool.Get()
+	if err != nil {
+		return err
+	}
+	defer kill.Recycle()
+
+	c.log.Warning("conn[%s, ID:%v].query.be.killed.by[%v].reason[%s]", c.address, c.ID(), kill.ID(), reason)
+	query := fmt.Sprintf("KILL QUERY %d", c.connectionID)
+	if _, err = kill.Execute(query); err != nil {
+		c.log.Warning("conn[%s, ID:%v].kill.query.error:%+v", c.address, c.ID(), err)
+		return err
+	}
+	return nil
+}
+
 // Recycle used to put current to pool.
 func (c *connection) Recycle() {
 	defer mysqlStats.Record("conn.recycle", time.Now())
+	c.txnID.Set(0)
 	if !c.driver.Closed() {
 		c.pool.Put(c)
+	} else if c.opened.CompareAndSwap(1, 0) {
+		c.pool.connClosed()
 	}
 }
 
@@ -263,6 +308,9 @@ func (c *connection) Close() {
 		c.driver.Close()
 		monitor.BackendConnectionDec(c.address)
 	}
+	if c.opened.CompareAndSwap(1, 0) {
+		c.pool.connClosed()
+	}
 }
 
 func (c *connection) Closed() bool {
@@ -10,10 +10,15 @@ package backend
 
 import (
 	"errors"
+	"fmt"
+	"os"
+	"path"
 	"sync"
 	"testing"
 
+	"config"
 	"fakedb"
+	"xbase"
 
 	"github.com/fortytw2/leaktest"
 	"github.com/stretchr/testify/assert"
@@ -90,7 +95,11 @@ func TestConnectionRecyle(t *testing.T) {
 		fakedb.AddQuery("USE MOCKDB", result2)
 		err := conn.UseDB("MOCKDB")
 		assert.Nil(t, err)
+		conn.SetTxnID(7)
+		assert.EqualValues(t, 7, conn.TxnID())
 		conn.Recycle()
+		// The txn id is reset when the connection returns to the pool.
+		assert.EqualValues(t, 0, conn.TxnID())
 	}
 }
 
@@ -143,6 +152,40 @@ func TestConnectionKillError(t *testing.T) {
 	}
 }
 
+func TestConnectionKillQuery(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	// MySQL Server starts...
+	fakedb := fakedb.New(log, 1)
+	defer fakedb.Close()
+	addr := fakedb.Addrs()[0]
+
+	// Connection
+	conn, cleanup := MockClient(log, addr)
+	defer cleanup()
+
+	// kill query
+	{
+		err := conn.KillQuery("kill.query")
+		assert.Nil(t, err)
+		assert.Equal(t, 1, fakedb.GetQueryCalledNum(fmt.Sprintf("kill query %d", conn.ID())))
+	}
+
+	// The connection is still alive.
+	{
+		fakedb.AddQuery("USE MOCKDB", result2)
+		err := conn.UseDB("MOCKDB")
+		assert.Nil(t, err)
+	}
+
+	// kill query error
+	{
+		fakedb.AddQueryError(fmt.Sprintf("kill query %d", conn.ID()), errors.New("mock.kill.query.error"))
+		err := conn.KillQuery("kill.query")
+		assert.Equal(t, "mock.kill.query.error (errno 1105) (sqlstate HY000)", err.Error())
+	}
+}
+
 func TestConnectionExecuteTimeout(t *testing.T) {
 	defer leaktest.Check(t)()
 	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
@@ -261,3 +304,59 @@ func TestTruncateQueryLog(t *testing.T) {
 		log.Debug("execute[%s].len[%d]", query, len(query))
 	}
 }
+
+func TestConnectionTLS(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fdb := fakedb.New(log, 1)
+	defer fdb.Close()
+	addr := fdb.Addrs()[0]
+
+	tmpDir := fakedb.GetTmpDir("", "backend_tls_", log)
+	defer os.RemoveAll(tmpDir)
+	ca, cert, key, err := xbase.MockTLSFiles(tmpDir)
+	assert.Nil(t, err)
+
+	newConf := func() *config.BackendConfig {
+		conf := *fdb.BackendConfs()[0]
+		conf.TLS = true
+		conf.TLSCA = ca
+		return &conf
+	}
+
+	// The backend doesn't support TLS.
+	{
+		pool := NewPool(log, newConf(), addr)
+		conn := NewConnection(log, pool)
+		err := conn.Dial()
+		assert.NotNil(t, err)
+	}
+
+	serverConf, err := xbase.NewServerTLSConfig(cert, key, ca, false)
+	assert.Nil(t, err)
+	fdb.SetTLSConfig(serverConf)
+
+	// Dial with TLS.
+	{
+		fdb.AddQuery("SELECT1", result1)
+		pool := NewPool(log, newConf(), addr)
+		conn := NewConnection(log, pool)
+		err := conn.Dial()
+		assert.Nil(t, err)
+		defer conn.Close()
+
+		qr, err := conn.Execute("SELECT1")
+		assert.Nil(t, err)
+		assert.Equal(t, result1, qr)
+	}
+
+	// The tls config error.
+	{
+		conf := newConf()
+		conf.TLSCA = path.Join(tmpDir, "not.exists.pem")
+		pool := NewPool(log, conf, addr)
+		conn := NewConnection(log, pool)
+		err := conn.Dial()
+		assert.NotNil(t, err)
+	}
+}
@@ -0,0 +1,404 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package backend
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"config"
+
+	"github.com/xelabs/go-mysqlstack/sqldb"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+var (
+	txnCounterDeadlockCheck      = "#deadlock.check"
+	txnCounterDeadlockCheckError = "#deadlock.check.error"
+	txnCounterDeadlockVictim     = "#deadlock.victim"
+)
+
+// The lock waits of one backend: the waiting thread id and the blocking thread id.
+var deadlockLockWaitsQuerys = []string{
+	// MySQL 8.0.
+	"SELECT r.trx_mysql_thread_id, b.trx_mysql_thread_id FROM performance_schema.data_lock_waits w " +
+		"INNER JOIN information_schema.innodb_trx r ON r.trx_id = w.REQUESTING_ENGINE_TRANSACTION_ID " +
+		"INNER JOIN information_schema.innodb_trx b ON b.trx_id = w.BLOCKING_ENGINE_TRANSACTION_ID",
+	// MySQL 5.7.
+	"SELECT r.trx_mysql_thread_id, b.trx_mysql_thread_id FROM information_schema.innodb_lock_waits w " +
+		"INNER JOIN information_schema.innodb_trx r ON r.trx_id = w.requesting_trx_id " +
+		"INNER JOIN information_schema.innodb_trx b ON b.trx_id = w.blocking_trx_id",
+}
+
+// deadlockError returns the same error as the MySQL deadlock.
+func deadlockError() error {
+	return sqldb.NewSQLError1(1213, "40001", "Deadlock found when trying to get lock; try restarting transaction")
+}
+
+// connKey identifies the backend connection by the address and the thread id.
+type connKey struct {
+	address string
+	id      uint32
+}
+
+// lockWait tuple, the waiter thread waits for the lock held by the blocker thread on the backend.
+type lockWait struct {
+	address string
+	waiter  uint32
+	blocker uint32
+}
+
+// DeadlockDetector tuple.
+// The deadlock across the backends, e.g. txn A waits for B on backend1 and B waits for A on backend2,
+// can't be detected by any backend, it's only resolved by the innodb_lock_wait_timeout.
+// The detector collects the lock waits of all the backends periodically, maps the backend threads
+// to the txns and aborts one txn of every cycle in the global wait-for graph.
+type DeadlockDetector struct {
+	log      *xlog.Log
+	scatter  *Scatter
+	interval int
+	// The wait-for graph of the last check.
+	last map[uint64]map[uint64]bool
+	done chan bool
+	wg   sync.WaitGroup
+}
+
+// NewDeadlockDetector creates the DeadlockDetector tuple.
+func NewDeadlockDetector(scatter *Scatter, conf *config.ScatterConfig) *DeadlockDetector {
+	return &DeadlockDetector{
+		log:      scatter.log,
+		scatter:  scatter,
+		interval: conf.DeadlockCheckInterval,
+		done:     make(chan bool),
+	}
+}
+
+// Init used to start the detector goroutine, does nothing if the interval is 0.
+func (dd *DeadlockDetector) Init() {
+	if dd.interval <= 0 {
+		return
+	}
+
+	dd.wg.Add(1)
+	go func() {
+		defer dd.wg.Done()
+		dd.detect()
+	}()
+	dd.log.Info("deadlock.detector.init.done.interval[%vms]", dd.interval)
+}
+
+// Close used to stop the detector goroutine.
+func (dd *DeadlockDetector) Close() {
+	close(dd.done)
+	dd.wg.Wait()
+}
+
+func (dd *DeadlockDetector) detect() {
+	ticker := time.NewTicker(time.Duration(dd.interval) * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			dd.Check()
+		case <-dd.done:
+			return
+		}
+	}
+}
+
+// Check used to do one round of the detection, returns the ids of the aborted txns.
+// The lock waits of the backends aren't sampled at the same time, one sample may show a phantom cycle,
+// only the cycle seen in two consecutive checks is broken.
+func (dd *DeadlockDetector) Check() []uint64 {
+	log := dd.log
+	txnCounters.Add(txnCounterDeadlockCheck, 1)
+
+	waits := dd.lockWaits()
+	if len(waits) == 0 {
+		dd.last = nil
+		return nil
+	}
+	txns := make(map[uint64]*Txn)
+	graph := waitForGraph(waits, txnOwners(), txns)
+	confirmed := confirmedGraph(dd.last, graph)
+	dd.last = graph
+
+	var aborted []uint64
+	for _, id := range deadlockVictims(confirmed) {
+		txn := txns[id]
+		if txn.abortDeadlock() {
+			txnCounters.Add(txnCounterDeadlockVictim, 1)
+			log.Warning("deadlock.detector.abort.victim.txn[%v].xid[%v].sessid[%v]", id, txn.xid, txn.sessionID)
+			aborted = append(aborted, id)
+		}
+	}
+	return aborted
+}
+
+// lockWaits used to collect the lock waits from all the normal backends concurrently.
+func (dd *DeadlockDetector) lockWaits() []lockWait {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var waits []lockWait
+
+	for _, p := range dd.scatter.PoolzClone() {
+		poolz := p
+		if poolz.conf.Role != config.NormalBackend {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w, err := dd.backendLockWaits(poolz)
+			if err != nil {
+				txnCounters.Add(txnCounterDeadlockCheckError, 1)
+				dd.log.Error("deadlock.detector.lock.waits.on[%s].error:%v", poolz.conf.Address, err)
+				return
+			}
+			mu.Lock()
+			waits = append(waits, w...)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return waits
+}
+
+func (dd *DeadlockDetector) backendLockWaits(poolz *Poolz) ([]lockWait, error) {
+	conn, err := poolz.normal.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	var waits []lockWait
+	for _, query := range deadlockLockWaitsQuerys {
+		qr, x := conn.Execute(query)
+		if err = x; err != nil {
+			// Try the lock waits table of the other MySQL version.
+			continue
+		}
+		for _, row := range qr.Rows {
+			if len(row) != 2 {
+				continue
+			}
+			waiter, x1 := strconv.ParseUint(row[0].ToString(), 10, 32)
+			blocker, x2 := strconv.ParseUint(row[1].ToString(), 10, 32)
+			if x1 != nil || x2 != nil {
+				continue
+			}
+			waits = append(waits, lockWait{address: poolz.conf.Address, waiter: uint32(waiter), blocker: uint32(blocker)})
+		}
+		break
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.Recycle()
+	return waits, nil
+}
+
+// txnOwners returns the txns which hold the backend connections.
+func txnOwners() map[connKey]*Txn {
+	owners := make(map[connKey]*Txn)
+	tz.mu.RLock()
+	defer tz.mu.RUnlock()
+	for _, td := range tz.txnDetails {
+		txn, ok := td.txn.(*Txn)
+		if !ok {
+			continue
+		}
+		for _, key := range txn.connectionKeys() {
+			owners[key] = txn
+		}
+	}
+	return owners
+}
+
+// waitForGraph used to build the wait-for graph of the txns, the edge A->B means txn A waits for txn B.
+// The waits on the threads which don't belong to any txn are ignored, the backend resolves them itself.
+func waitForGraph(waits []lockWait, owners map[connKey]*Txn, txns map[uint64]*Txn) map[uint64]map[uint64]bool {
+	graph := make(map[uint64]map[uint64]bool)
+	for _, w := range waits {
+		waiter, ok1 := owners[connKey{address: w.address, id: w.waiter}]
+		blocker, ok2 := owners[connKey{address: w.address, id: w.blocker}]
+		if !ok1 || !ok2 || waiter == blocker {
+			continue
+		}
+		txns[waiter.id] = waiter
+		txns[blocker.id] = blocker
+		if graph[waiter.id] == nil {
+			graph[waiter.id] = make(map[uint64]bool)
+		}
+		graph[waiter.id][blocker.id] = true
+	}
+	return graph
+}
+
+// confirmedGraph returns the edges in both the last and the current wait-for graphs.
+func confirmedGraph(last, current map[uint64]map[uint64]bool) map[uint64]map[uint64]bool {
+	graph := make(map[uint64]map[uint64]bool)
+	for waiter, blockers := range current {
+		for blocker := range blockers {
+			if !last[waiter][blocker] {
+				continue
+			}
+			if graph[waiter] == nil {
+				graph[waiter] = make(map[uint64]bool)
+			}
+			graph[waiter][blocker] = true
+		}
+	}
+	return graph
+}
+
+// findCycle returns one cycle of the graph, nil if there is no cycle.
+func findCycle(graph map[uint64]map[uint64]bool) []uint64 {
+	const (
+		white = iota
+		gray
+		black
+	)
+	color := make(map[uint64]int)
+
+	// Sort the nodes to make the result stable.
+	sorted := func(set map[uint64]bool) []uint64 {
+		ids := make([]uint64, 0, len(set))
+		for id := range set {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		return ids
+	}
+	nodes := make(map[uint64]bool)
+	for id := range graph {
+		nodes[id] = true
+	}
+
+	var path []uint64
+	var visit func(id uint64) []uint64
+	visit = func(id uint64) []uint64 {
+		color[id] = gray
+		path = append(path, id)
+		for _, next := range sorted(graph[id]) {
+			switch color[next] {
+			case gray:
+				// The back edge, the cycle is the path from the next.
+				for i := range path {
+					if path[i] == next {
+						return append([]uint64{}, path[i:]...)
+					}
+				}
+			case white:
+				if cycle := visit(next); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		color[id] = black
+		path = path[:len(path)-1]
+		return nil
+	}
+
+	for _, id := range sorted(nodes) {
+		if color[id] == white {
+			if cycle := visit(id); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
+
+// deadlockVictims returns the victims to break all the cycles of the graph.
+// The youngest txn of the cycle is chosen, it has done the least work probably.
+func deadlockVictims(graph map[uint64]map[uint64]bool) []uint64 {
+	var victims []uint64
+	for {
+		cycle := findCycle(graph)
+		if cycle == nil {
+			return victims
+		}
+
+		victim := cycle[0]
+		for _, id := range cycle {
+			if id > victim {
+				victim = id
+			}
+		}
+		victims = append(victims, victim)
+
+		// Remove the victim from the graph.
+		delete(graph, victim)
+		for _, edges := range graph {
+			delete(edges, victim)
+		}
+	}
+}
+
+// connectionKeys returns the backend connections held by the txn.
+func (txn *Txn) connectionKeys() []connKey {
+	var keys []connKey
+
+	txn.twopcConnMu.RLock()
+	for _, conn := range txn.twopcConnections {
+		keys = append(keys, connKey{address: conn.Address(), id: conn.ID()})
+	}
+	txn.twopcConnMu.RUnlock()
+
+	txn.normalConnMu.RLock()
+	for _, conn := range txn.normalConnections {
+		keys = append(keys, connKey{address: conn.Address(), id: conn.ID()})
+	}
+	txn.normalConnMu.RUnlock()
+	return keys
+}
+
+// abortDeadlock used to abort the txn as the deadlock victim.
+// All the connections of the txn are killed, the backends roll back the branches and release the locks,
+// the waiting statement returns the deadlock error to the client.
+// Returns false if the txn is finished, already aborted or in the commit/rollback phase.
+func (txn *Txn) abortDeadlock() bool {
+	// Serialize with the Finish, the killed connections mustn't be recycled.
+	txn.mu.Lock()
+	defer txn.mu.Unlock()
+
+	switch txnState(txn.state.Get()) {
+	case txnStateFinshing, txnStateAborting, txnStateCommitting, txnStateRollbacking:
+		return false
+	}
+	// The txnStateExecutingTwoPC is also the state of the statements, the XA state tells
+	// whether the txn is in the XA END/PREPARE/COMMIT/ROLLBACK.
+	switch txnXAState(txn.xaState.Get()) {
+	case txnXAStateEnd, txnXAStateEndFinished,
+		txnXAStatePrepare, txnXAStatePrepareFinished,
+		txnXAStateCommit, txnXAStateCommitFinished,
+		txnXAStateRollback, txnXAStateRollbackFinished:
+		return false
+	}
+	if txn.deadlocked.Get() {
+		return false
+	}
+	txn.deadlocked.Set(true)
+
+	txn.twopcConnMu.RLock()
+	for _, conn := range txn.twopcConnections {
+		conn.Kill("txn.deadlock.victim")
+	}
+	txn.twopcConnMu.RUnlock()
+
+	txn.normalConnMu.RLock()
+	for _, conn := range txn.normalConnections {
+		conn.Kill("txn.deadlock.victim")
+	}
+	txn.normalConnMu.RUnlock()
+	return true
+}
@@ -0,0 +1,224 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package backend
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	"xcontext"
+
+	"config"
+
+	"github.com/fortytw2/leaktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/sqldb"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestDeadlockVictims(t *testing.T) {
+	edges := func(pairs ...uint64) map[uint64]map[uint64]bool {
+		graph := make(map[uint64]map[uint64]bool)
+		for i := 0; i < len(pairs); i += 2 {
+			if graph[pairs[i]] == nil {
+				graph[pairs[i]] = make(map[uint64]bool)
+			}
+			graph[pairs[i]][pairs[i+1]] = true
+		}
+		return graph
+	}
+
+	tests := []struct {
+		graph map[uint64]map[uint64]bool
+		want  []uint64
+	}{
+		// No cycle.
+		{edges(1, 2, 2, 3, 1, 3), nil},
+		// 1->2->1.
+		{edges(1, 2, 2, 1), []uint64{2}},
+		// 1->2->3->1.
+		{edges(1, 2, 2, 3, 3, 1, 4, 1), []uint64{3}},
+		// Two cycles share the node 3.
+		{edges(1, 3, 3, 1, 2, 3, 3, 2), []uint64{3}},
+		// Two disjoint cycles.
+		{edges(1, 2, 2, 1, 5, 6, 6, 7, 7, 5), []uint64{2, 7}},
+	}
+	for _, test := range tests {
+		got := deadlockVictims(test.graph)
+		assert.Equal(t, test.want, got)
+	}
+
+	// The cycle must be in two consecutive samples.
+	confirms := []struct {
+		last    map[uint64]map[uint64]bool
+		current map[uint64]map[uint64]bool
+		want    []uint64
+	}{
+		{nil, edges(1, 2, 2, 1), nil},
+		// The phantom cycle: 1->2 in the last, 2->1 in the current.
+		{edges(1, 2), edges(1, 2, 2, 1), nil},
+		{edges(1, 2, 2, 1, 3, 1), edges(1, 2, 2, 1), []uint64{2}},
+	}
+	for _, test := range confirms {
+		got := deadlockVictims(confirmedGraph(test.last, test.current))
+		assert.Equal(t, test.want, got)
+	}
+}
+
+func TestDeadlockDetector(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedb, txnMgr, backends, addrs, scatter, cleanup := MockTxnMgrScatter(log, 2)
+	defer cleanup()
+
+	fakedb.AddQueryPattern("XA .*", result1)
+	fakedb.AddQueryPattern("update .*", result1)
+	fakedb.AddQueryPattern("kill .*", &sqltypes.Result{})
+
+	begin := func() *Txn {
+		txn, err := txnMgr.CreateTxn(backends)
+		assert.Nil(t, err)
+		txn.SetMultiStmtTxn()
+		err = txn.BeginScatter()
+		assert.Nil(t, err)
+		return txn
+	}
+	update := func(txn *Txn) error {
+		rctx := &xcontext.RequestContext{
+			Mode:    xcontext.ReqNormal,
+			TxnMode: xcontext.TxnWrite,
+			Querys: []xcontext.QueryTuple{
+				{Query: "update t1 set b=1", Backend: addrs[0]},
+				{Query: "update t2 set b=1", Backend: addrs[1]},
+			},
+		}
+		_, err := txn.Execute(rctx)
+		return err
+	}
+
+	txn1 := begin()
+	defer txn1.Finish()
+	txn2 := begin()
+	defer txn2.Finish()
+	txn3 := begin()
+	defer txn3.Finish()
+	assert.Nil(t, update(txn1))
+	assert.Nil(t, update(txn2))
+	assert.Nil(t, update(txn3))
+
+	// The txn1 waits for txn2 on backend0, txn2 waits for txn1 on backend1, txn3 waits for txn1.
+	// Only the MySQL 5.7 lock waits table exists.
+	id := func(txn *Txn, back string) uint32 {
+		return txn.twopcConnections[back].ID()
+	}
+	row := func(waiter, blocker uint32) []sqltypes.Value {
+		return []sqltypes.Value{
+			sqltypes.MakeTrusted(querypb.Type_UINT64, []byte(fmt.Sprintf("%d", waiter))),
+			sqltypes.MakeTrusted(querypb.Type_UINT64, []byte(fmt.Sprintf("%d", blocker))),
+		}
+	}
+	waits := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "trx_mysql_thread_id", Type: querypb.Type_UINT64},
+			{Name: "trx_mysql_thread_id", Type: querypb.Type_UINT64},
+		},
+		Rows: [][]sqltypes.Value{
+			row(id(txn1, addrs[0]), id(txn2, addrs[0])),
+			row(id(txn2, addrs[1]), id(txn1, addrs[1])),
+			row(id(txn3, addrs[0]), id(txn1, addrs[0])),
+		},
+	}
+	fakedb.AddQuery(strings.ToLower(deadlockLockWaitsQuerys[1]), waits)
+
+	dd := NewDeadlockDetector(scatter, config.DefaultScatterConfig())
+	// The cycle is confirmed by the second check.
+	aborted := dd.Check()
+	assert.Equal(t, 0, len(aborted))
+	aborted = dd.Check()
+	assert.Equal(t, []uint64{txn2.TxID()}, aborted)
+	assert.True(t, fakedb.GetQueryCalledNum(fmt.Sprintf("kill %d", id(txn2, addrs[0]))) > 0)
+	assert.True(t, fakedb.GetQueryCalledNum(fmt.Sprintf("kill %d", id(txn2, addrs[1]))) > 0)
+
+	// The victim isn't aborted again.
+	aborted = dd.Check()
+	assert.Equal(t, 0, len(aborted))
+
+	// The victim gets the deadlock error.
+	{
+		err := update(txn2)
+		assert.NotNil(t, err)
+		assert.Equal(t, uint16(1213), err.(*sqldb.SQLError).Num)
+
+		err = txn2.CommitScatter()
+		assert.NotNil(t, err)
+		assert.Equal(t, uint16(1213), err.(*sqldb.SQLError).Num)
+
+		xaRollbacks := fakedb.GetQueryCalledNum(fmt.Sprintf("xa rollback '%s'", txn2.XID()))
+		err = txn2.RollbackScatter()
+		assert.Nil(t, err)
+		assert.Equal(t, xaRollbacks, fakedb.GetQueryCalledNum(fmt.Sprintf("xa rollback '%s'", txn2.XID())))
+	}
+
+	// The others go on.
+	{
+		assert.Nil(t, update(txn1))
+		assert.Nil(t, txn1.CommitScatter())
+		assert.Nil(t, txn3.CommitScatter())
+	}
+}
+
+func TestDeadlockAbortInXA(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedb, txnMgr, backends, _, cleanup := MockTxnMgr(log, 2)
+	defer cleanup()
+
+	fakedb.AddQueryPattern("XA .*", result1)
+	txn, err := txnMgr.CreateTxn(backends)
+	assert.Nil(t, err)
+	defer txn.Finish()
+	txn.SetMultiStmtTxn()
+	err = txn.BeginScatter()
+	assert.Nil(t, err)
+
+	// The txn in the XA PREPARE/COMMIT isn't the victim.
+	for _, state := range []txnXAState{txnXAStatePrepare, txnXAStatePrepareFinished, txnXAStateCommit} {
+		txn.xaState.Set(int32(state))
+		assert.False(t, txn.abortDeadlock())
+	}
+	txn.xaState.Set(int32(txnXAStateStartFinished))
+	txn.state.Set(int32(txnStateCommitting))
+	assert.False(t, txn.abortDeadlock())
+
+	// The txn executing the statement can be the victim.
+	txn.state.Set(int32(txnStateExecutingTwoPC))
+	assert.True(t, txn.abortDeadlock())
+	assert.False(t, txn.abortDeadlock())
+}
+
+func TestDeadlockDetectorInit(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedb, _, _, _, scatter, cleanup := MockTxnMgrScatter(log, 2)
+	defer cleanup()
+
+	conf := MockScatterDefault(log)
+	conf.DeadlockCheckInterval = 10
+	err := scatter.Init(conf)
+	assert.Nil(t, err)
+
+	// The lock waits querys are sent periodically.
+	query := strings.ToLower(deadlockLockWaitsQuerys[0])
+	fakedb.AddQuery(query, &sqltypes.Result{})
+	time.Sleep(100 * time.Millisecond)
+	assert.True(t, fakedb.GetQueryCalledNum(query) > 1)
+}
@@ -116,18 +116,20 @@ func MockBackendConfigReplica(name, addr, replica string) *config.BackendConfig
 func MockScatterDefault(log *xlog.Log) *config.ScatterConfig {
 	dir := fakedb.GetTmpDir("/tmp", "xacheck", log)
 	return &config.ScatterConfig{
-		XaCheckInterval: 1,
-		XaCheckDir:      dir,
-		XaCheckRetrys:   10,
+		XaCheckInterval:  1,
+		XaCheckDir:       dir,
+		XaCheckRetrys:    10,
+		XaLogSegmentSize: 1024 * 1024,
 	}
 }
 
 // MockScatterDefault2 mocks new xacheck config with dir.
 func MockScatterDefault2(dir string) *config.ScatterConfig {
 	return &config.ScatterConfig{
-		XaCheckInterval: 1,
-		XaCheckDir:      dir,
-		XaCheckRetrys:   10,
+		XaCheckInterval:  1,
+		XaCheckDir:       dir,
+		XaCheckRetrys:    10,
+		XaLogSegmentSize: 1024 * 1024,
 	}
 }
 
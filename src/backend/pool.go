@@ -10,13 +10,17 @@ package backend
 
 import (
 	"bytes"
+	"crypto/tls"
 	"errors"
 	"fmt"
+	"net"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"config"
+	"monitor"
+	"xbase"
 	"xbase/stats"
 
 	"github.com/xelabs/go-mysqlstack/xlog"
@@ -93,6 +97,13 @@ type Pool struct {
 
 	// If maxIdleTime reached, the connection will be closed by get.
 	maxIdleTime int64
+
+	// The number of the opened connections, both idle and in use.
+	opened int64
+
+	// The TLS config of the backend connections, nil if the TLS is disabled.
+	tlsConfig *tls.Config
+	tlsErr    error
 }
 
 // NewPool creates the new Pool.
@@ -100,7 +111,7 @@ func NewPool(log *xlog.Log, conf *config.BackendConfig, address string) *Pool {
 	if address == "" {
 		return nil
 	}
-	return &Pool{
+	p := &Pool{
 		log:         log,
 		address:     address,
 		conf:        conf,
@@ -108,6 +119,17 @@ func NewPool(log *xlog.Log, conf *config.BackendConfig, address string) *Pool {
 		counters:    stats.NewCounters(conf.Name + "@" + address),
 		maxIdleTime: int64(maxIdleTime),
 	}
+	if conf.TLS {
+		// The error is returned by the dial, the pool is always created as the backend down.
+		host, _, _ := net.SplitHostPort(address)
+		if host == "" {
+			host = "localhost"
+		}
+		if p.tlsConfig, p.tlsErr = xbase.NewClientTLSConfig(conf.TLSCA, conf.TLSCert, conf.TLSKey, host, conf.TLSSkipVerify); p.tlsErr != nil {
+			log.Error("pool[%s].tls.config.error:%+v", address, p.tlsErr)
+		}
+	}
+	return p
 }
 
 func (p *Pool) reconnect() (Connection, error) {
@@ -125,6 +147,7 @@ func (p *Pool) reconnect() (Connection, error) {
 func (p *Pool) Get() (Connection, error) {
 	counters := p.counters
 	counters.Add(poolCounterGet, 1)
+	defer p.recordWait(time.Now())
 
 	conns := p.getConns()
 	if conns == nil {
@@ -182,6 +205,7 @@ func (p *Pool) put(conn Connection, updateTs bool) {
 	default:
 		conn.Close()
 	}
+	p.setMetrics(len(p.connections))
 }
 
 // Close used to close the pool.
@@ -197,6 +221,36 @@ func (p *Pool) Close() {
 		conn.Close()
 	}
 	p.connections = nil
+	monitor.BackendPoolDelete(p.conf.Name, p.address)
+}
+
+// connOpened used to count the connection dialed by the pool.
+func (p *Pool) connOpened() {
+	atomic.AddInt64(&p.opened, 1)
+}
+
+// connClosed used to count the connection closed.
+func (p *Pool) connClosed() {
+	atomic.AddInt64(&p.opened, -1)
+}
+
+func (p *Pool) recordWait(start time.Time) {
+	// The metrics of the closed pool are deleted.
+	conns := p.getConns()
+	if conns == nil {
+		return
+	}
+	monitor.BackendPoolWaitObserve(p.conf.Name, p.address, time.Since(start).Seconds())
+	p.setMetrics(len(conns))
+}
+
+// setMetrics used to set the idle and in-use connections of the pool to the monitor.
+func (p *Pool) setMetrics(idle int) {
+	inUse := int(atomic.LoadInt64(&p.opened)) - idle
+	if inUse < 0 {
+		inUse = 0
+	}
+	monitor.BackendPoolConnectionsSet(p.conf.Name, p.address, float64(idle), float64(inUse))
 }
 
 func (p *Pool) getConns() chan Connection {
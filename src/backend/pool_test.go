@@ -210,3 +210,38 @@ func TestPoolTimeout(t *testing.T) {
 	close(ch2)
 	wg.Wait()
 }
+
+func TestPoolOpened(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+
+	// MySQL Server starts...
+	th := driver.NewTestHandler(log)
+	svr, err := driver.MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+	addr := svr.Addr()
+
+	conf := MockBackendConfigDefault("node1", addr)
+	conf.MaxConnections = 1
+	pool := NewPool(log, conf, addr)
+
+	conn1, err := pool.Get()
+	assert.Nil(t, err)
+	conn2, err := pool.Get()
+	assert.Nil(t, err)
+	assert.EqualValues(t, 2, atomic.LoadInt64(&pool.opened))
+
+	// The pool is full, conn2 is closed by the put.
+	conn1.Recycle()
+	conn2.Recycle()
+	assert.EqualValues(t, 1, atomic.LoadInt64(&pool.opened))
+	assert.Equal(t, 1, len(pool.getConns()))
+
+	// Close twice only counts once.
+	conn2.Close()
+	assert.EqualValues(t, 1, atomic.LoadInt64(&pool.opened))
+
+	pool.Close()
+	assert.EqualValues(t, 0, atomic.LoadInt64(&pool.opened))
+}
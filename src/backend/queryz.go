@@ -11,6 +11,7 @@ package backend
 
 import (
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -21,15 +22,24 @@ import (
 type QueryDetail struct {
 	ID     uint64
 	connID uint32
+	txnID  uint64
 	query  string
 	conn   Connection
 	start  time.Time
+	// xa is true if the query is the XA statement, which isn't killed by the KILL QUERY,
+	// the XA COMMIT after the decision must not be interrupted.
+	xa bool
+
+	// mu protects the done, the query isn't removed while it's being killed.
+	mu   sync.Mutex
+	done bool
 }
 
 // NewQueryDetail creates a new QueryDetail
 func NewQueryDetail(conn Connection, query string) *QueryDetail {
 	q := xbase.TruncateQuery(query, 256)
-	return &QueryDetail{conn: conn, connID: conn.ID(), query: q, start: time.Now()}
+	xa := len(query) > 3 && strings.EqualFold(query[:3], "XA ")
+	return &QueryDetail{conn: conn, connID: conn.ID(), txnID: conn.TxnID(), query: q, start: time.Now(), xa: xa}
 }
 
 // Queryz holds a thread safe list of QueryDetails
@@ -55,16 +65,67 @@ func (qz *Queryz) Add(qd *QueryDetail) {
 
 // Remove removes a QueryDetail from Queryz
 func (qz *Queryz) Remove(qd *QueryDetail) {
+	// Wait for the running kill, the conn goes back to the pool after the remove.
+	qd.mu.Lock()
+	qd.done = true
+	qd.mu.Unlock()
+
 	qz.mu.Lock()
 	defer qz.mu.Unlock()
 	delete(qz.queryDetails, qd.ID)
 }
 
+// KillTxnQuerys kills the running queries of the txn on the backends, returns the number of the killed queries.
+// The XA statements of the two phase commit are skipped.
+func (qz *Queryz) KillTxnQuerys(txnID uint64, reason string) int {
+	// 0 means the queries not in any txn.
+	if txnID == 0 {
+		return 0
+	}
+
+	var qds []*QueryDetail
+	qz.mu.RLock()
+	for _, qd := range qz.queryDetails {
+		if qd.txnID == txnID && !qd.xa {
+			qds = append(qds, qd)
+		}
+	}
+	qz.mu.RUnlock()
+
+	// Kill outside the lock, the killed queries remove themselves.
+	killed := 0
+	for _, qd := range qds {
+		if qz.killQuery(qd, reason) {
+			killed++
+		}
+	}
+	return killed
+}
+
+// killQuery kills the query if it's still running on the same conn, returns true if killed.
+// The query may finish after it's collected, then the conn is back to the pool and runs the query of another session.
+func (qz *Queryz) killQuery(qd *QueryDetail, reason string) bool {
+	qd.mu.Lock()
+	defer qd.mu.Unlock()
+	if qd.done {
+		return false
+	}
+
+	qz.mu.RLock()
+	cur, ok := qz.queryDetails[qd.ID]
+	qz.mu.RUnlock()
+	if !ok || cur != qd || cur.conn != qd.conn {
+		return false
+	}
+	return qd.conn.KillQuery(reason) == nil
+}
+
 // QueryDetailzRow is used for rendering QueryDetail in a template
 type QueryDetailzRow struct {
 	Start    time.Time
 	Duration time.Duration
 	ConnID   uint32
+	TxnID    uint64
 	Query    string
 	Address  string
 	Color    string
@@ -87,6 +148,7 @@ func (qz *Queryz) GetQueryzRows() byStartTime {
 			Start:    qd.start,
 			Duration: time.Since(qd.start),
 			ConnID:   qd.connID,
+			TxnID:    qd.txnID,
 		}
 		if row.Duration < 10*time.Millisecond {
 			row.Color = "low"
@@ -9,6 +9,7 @@
 package backend
 
 import (
+	"fmt"
 	"testing"
 	"time"
 
@@ -41,6 +42,8 @@ func TestQueryz(t *testing.T) {
 	conn2 := NewConnection(log, pool)
 	err = conn2.Dial()
 	assert.Nil(t, err)
+	// conn2 is held by the txn.
+	conn2.SetTxnID(7)
 
 	// set conds
 	fakedb.AddQueryDelay(querys[0], result1, 200)
@@ -65,9 +68,69 @@ func TestQueryz(t *testing.T) {
 		rows := qz.GetQueryzRows()
 		assert.Equal(t, querys[0], rows[0].Query)
 		assert.Equal(t, querys[1], rows[1].Query)
+		assert.EqualValues(t, 0, rows[0].TxnID)
+		assert.EqualValues(t, 7, rows[1].TxnID)
 		// Test byStartTime.Swap() funciton to improve test coverage.
 		rows.Swap(0, 1)
 		assert.Equal(t, querys[0], rows[1].Query)
 		assert.Equal(t, querys[1], rows[0].Query)
 	}
 }
+
+func TestQueryzKillTxnQuerys(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	// MySQL Server starts...
+	fakedb := fakedb.New(log, 1)
+	defer fakedb.Close()
+	addr := fakedb.Addrs()[0]
+	conf := MockBackendConfigDefault(addr, addr)
+	pool := NewPool(log, conf, addr)
+	defer pool.Close()
+
+	// conn1 is not in txn.
+	conn1 := NewConnection(log, pool)
+	err := conn1.Dial()
+	assert.Nil(t, err)
+	defer conn1.Close()
+
+	// conn2 is held by the txn.
+	conn2 := NewConnection(log, pool)
+	err = conn2.Dial()
+	assert.Nil(t, err)
+	defer conn2.Close()
+	conn2.SetTxnID(8)
+
+	fakedb.AddQueryDelay("SELECT3", result1, 300)
+	fakedb.AddQueryDelay("SELECT4", result1, 300)
+	done := make(chan struct{}, 2)
+	e := func(conn Connection, q string) {
+		conn.Execute(q)
+		done <- struct{}{}
+	}
+	go e(conn1, "SELECT3")
+	go e(conn2, "SELECT4")
+	time.Sleep(100 * time.Millisecond)
+
+	assert.Equal(t, 0, qz.KillTxnQuerys(0, "kill.query"))
+	assert.Equal(t, 0, qz.KillTxnQuerys(9, "kill.query"))
+	assert.Equal(t, 1, qz.KillTxnQuerys(8, "kill.query"))
+	assert.Equal(t, 0, fakedb.GetQueryCalledNum(fmt.Sprintf("kill query %d", conn1.ID())))
+	assert.Equal(t, 1, fakedb.GetQueryCalledNum(fmt.Sprintf("kill query %d", conn2.ID())))
+	<-done
+	<-done
+
+	// The removed query isn't killed, the conn may run the query of another session.
+	qd := NewQueryDetail(conn2, "SELECT5")
+	qz.Add(qd)
+	qz.Remove(qd)
+	assert.False(t, qz.killQuery(qd, "kill.query"))
+	assert.Equal(t, 0, qz.KillTxnQuerys(8, "kill.query"))
+	assert.Equal(t, 1, fakedb.GetQueryCalledNum(fmt.Sprintf("kill query %d", conn2.ID())))
+
+	// The XA statement isn't killed.
+	qd = NewQueryDetail(conn2, "XA COMMIT 'xid1'")
+	qz.Add(qd)
+	assert.Equal(t, 0, qz.KillTxnQuerys(8, "kill.query"))
+	qz.Remove(qd)
+	assert.Equal(t, 1, fakedb.GetQueryCalledNum(fmt.Sprintf("kill query %d", conn2.ID())))
+}
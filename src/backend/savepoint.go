@@ -0,0 +1,166 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package backend
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/golang/sync/errgroup"
+	"github.com/xelabs/go-mysqlstack/sqldb"
+)
+
+var (
+	txnCounterSavepoint      = "#savepoint"
+	txnCounterSavepointError = "#savepoint.error"
+)
+
+// savepointQuery returns the savepoint statement with the quoted name.
+func savepointQuery(prefix string, name string) string {
+	return fmt.Sprintf("%s `%s`", prefix, strings.Replace(name, "`", "``", -1))
+}
+
+func savepointNotExist(name string) error {
+	return sqldb.NewSQLError1(1305, "42000", "SAVEPOINT %s does not exist", name)
+}
+
+// savepointIndex returns the index of the savepoint, -1 if not found.
+// The savepoint name is case-insensitive as MySQL.
+// Must be called with the savepointMu held.
+func (txn *Txn) savepointIndex(name string) int {
+	for i, sp := range txn.savepoints {
+		if strings.EqualFold(sp, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Savepoint used to set a savepoint in the multiple-statement transaction.
+// The savepoint is sent to the backends which have joined the txn, the others
+// will set it when they join, see joinSavepoints.
+func (txn *Txn) Savepoint(name string) error {
+	txn.savepointMu.Lock()
+	defer txn.savepointMu.Unlock()
+
+	txnCounters.Add(txnCounterSavepoint, 1)
+	if err := txn.executeSavepoint(savepointQuery("SAVEPOINT", name)); err != nil {
+		return err
+	}
+
+	// The older savepoint with the same name is deleted.
+	if i := txn.savepointIndex(name); i >= 0 {
+		txn.savepoints = append(txn.savepoints[:i], txn.savepoints[i+1:]...)
+	}
+	txn.savepoints = append(txn.savepoints, name)
+	return nil
+}
+
+// RollbackToSavepoint used to rollback the txn to the savepoint without terminating it.
+// The savepoints set after the savepoint are deleted.
+func (txn *Txn) RollbackToSavepoint(name string) error {
+	txn.savepointMu.Lock()
+	defer txn.savepointMu.Unlock()
+
+	i := txn.savepointIndex(name)
+	if i < 0 {
+		return savepointNotExist(name)
+	}
+	txnCounters.Add(txnCounterSavepoint, 1)
+	if err := txn.executeSavepoint(savepointQuery("ROLLBACK TO SAVEPOINT", txn.savepoints[i])); err != nil {
+		return err
+	}
+	txn.savepoints = txn.savepoints[:i+1]
+	return nil
+}
+
+// ReleaseSavepoint used to delete the savepoint and the savepoints set after it.
+func (txn *Txn) ReleaseSavepoint(name string) error {
+	txn.savepointMu.Lock()
+	defer txn.savepointMu.Unlock()
+
+	i := txn.savepointIndex(name)
+	if i < 0 {
+		return savepointNotExist(name)
+	}
+	txnCounters.Add(txnCounterSavepoint, 1)
+	if err := txn.executeSavepoint(savepointQuery("RELEASE SAVEPOINT", txn.savepoints[i])); err != nil {
+		return err
+	}
+	txn.savepoints = txn.savepoints[:i]
+	return nil
+}
+
+// Savepoints returns the savepoints of the txn in order.
+func (txn *Txn) Savepoints() []string {
+	txn.savepointMu.Lock()
+	defer txn.savepointMu.Unlock()
+	return append([]string{}, txn.savepoints...)
+}
+
+// executeSavepoint used to send the savepoint statement to the joined backends.
+// Must be called with the savepointMu held.
+func (txn *Txn) executeSavepoint(query string) error {
+	var eg errgroup.Group
+	log := txn.log
+
+	backends := make([]string, 0, len(txn.joined))
+	for back := range txn.joined {
+		backends = append(backends, back)
+	}
+	sort.Strings(backends)
+	for _, b := range backends {
+		back := b
+		eg.Go(func() error {
+			c, err := txn.twopcConnection(back)
+			if err != nil {
+				log.Error("txn.savepoint.fetch.connection.on[%s].query[%v].error:%+v", back, query, err)
+				return err
+			}
+			log.Debug("conn[%v].txn.sessid[%v].savepoint.execute[%v]", c.ID(), txn.sessionID, query)
+			if _, err := c.Execute(query); err != nil {
+				log.Error("txn.savepoint.execute[%v].on[%v].error:%+v", query, c.Address(), err)
+				return err
+			}
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		txnCounters.Add(txnCounterSavepointError, 1)
+		txn.incErrors()
+		return err
+	}
+	return nil
+}
+
+// joinSavepoints used to mark the backend joined before it executes the first statement of the txn.
+// The backend has no work in the txn yet, so the savepoints set before it joins are all equal to
+// the beginning of its work, they are set in order on it, then 'ROLLBACK TO SAVEPOINT' undoes its whole work.
+func (txn *Txn) joinSavepoints(back string, c Connection) error {
+	if !txn.isMultiStmtTxn {
+		return nil
+	}
+
+	txn.savepointMu.Lock()
+	defer txn.savepointMu.Unlock()
+	if txn.joined[back] {
+		return nil
+	}
+	for _, sp := range txn.savepoints {
+		query := savepointQuery("SAVEPOINT", sp)
+		if _, err := c.Execute(query); err != nil {
+			txn.log.Error("txn.savepoint.join.execute[%v].on[%v].error:%+v", query, c.Address(), err)
+			txnCounters.Add(txnCounterSavepointError, 1)
+			return err
+		}
+	}
+	txn.joined[back] = true
+	return nil
+}
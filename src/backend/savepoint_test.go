@@ -0,0 +1,165 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package backend
+
+import (
+	"errors"
+	"testing"
+	"xcontext"
+
+	"github.com/fortytw2/leaktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/sqldb"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestTxnSavepoint(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedb, txnMgr, backends, addrs, cleanup := MockTxnMgr(log, 2)
+	defer cleanup()
+
+	fakedb.AddQueryPattern("XA .*", result1)
+	fakedb.AddQuery("update", result1)
+	fakedb.AddQueryPattern("savepoint .*", &sqltypes.Result{})
+	fakedb.AddQueryPattern("rollback to savepoint .*", &sqltypes.Result{})
+	fakedb.AddQueryPattern("release savepoint .*", &sqltypes.Result{})
+
+	txn, err := txnMgr.CreateTxn(backends)
+	assert.Nil(t, err)
+	defer txn.Finish()
+	txn.SetMultiStmtTxn()
+
+	err = txn.BeginScatter()
+	assert.Nil(t, err)
+
+	execute := func(back string) {
+		rctx := &xcontext.RequestContext{
+			Mode:    xcontext.ReqNormal,
+			TxnMode: xcontext.TxnWrite,
+			Querys:  []xcontext.QueryTuple{{Query: "update", Backend: back}},
+		}
+		_, err := txn.Execute(rctx)
+		assert.Nil(t, err)
+	}
+
+	// No backend joined, nothing is sent.
+	{
+		err = txn.Savepoint("sp0")
+		assert.Nil(t, err)
+		assert.Equal(t, 0, fakedb.GetQueryCalledNum("savepoint `sp0`"))
+	}
+
+	// The backend0 joins, the sp0 is set on it.
+	{
+		execute(addrs[0])
+		assert.Equal(t, 1, fakedb.GetQueryCalledNum("savepoint `sp0`"))
+
+		err = txn.Savepoint("sp1")
+		assert.Nil(t, err)
+		assert.Equal(t, 1, fakedb.GetQueryCalledNum("savepoint `sp1`"))
+	}
+
+	// The backend1 joins after the sp1 was set.
+	{
+		execute(addrs[1])
+		assert.Equal(t, 2, fakedb.GetQueryCalledNum("savepoint `sp0`"))
+		assert.Equal(t, 2, fakedb.GetQueryCalledNum("savepoint `sp1`"))
+
+		err = txn.Savepoint("sp2")
+		assert.Nil(t, err)
+		assert.Equal(t, 2, fakedb.GetQueryCalledNum("savepoint `sp2`"))
+		execute(addrs[1])
+		assert.Equal(t, 2, fakedb.GetQueryCalledNum("savepoint `sp2`"))
+	}
+
+	// Set the sp0 again, it's moved to the last.
+	{
+		err = txn.Savepoint("sp0")
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"sp1", "sp2", "sp0"}, txn.Savepoints())
+	}
+
+	// Rollback to.
+	{
+		err = txn.RollbackToSavepoint("SP1")
+		assert.Nil(t, err)
+		assert.Equal(t, 2, fakedb.GetQueryCalledNum("rollback to savepoint `sp1`"))
+		assert.Equal(t, []string{"sp1"}, txn.Savepoints())
+
+		err = txn.RollbackToSavepoint("sp2")
+		assert.NotNil(t, err)
+		assert.Equal(t, uint16(1305), err.(*sqldb.SQLError).Num)
+	}
+
+	// Release.
+	{
+		err = txn.ReleaseSavepoint("sp2")
+		assert.NotNil(t, err)
+
+		err = txn.ReleaseSavepoint("sp1")
+		assert.Nil(t, err)
+		assert.Equal(t, 2, fakedb.GetQueryCalledNum("release savepoint `sp1`"))
+		assert.Equal(t, 0, len(txn.Savepoints()))
+	}
+
+	err = txn.CommitScatter()
+	assert.Nil(t, err)
+}
+
+func TestTxnSavepointError(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedb, txnMgr, backends, addrs, cleanup := MockTxnMgr(log, 2)
+	defer cleanup()
+
+	fakedb.AddQueryPattern("XA .*", result1)
+	fakedb.AddQuery("update", result1)
+	fakedb.AddQueryErrorPattern("savepoint .*", errors.New("mock.savepoint.error"))
+
+	txn, err := txnMgr.CreateTxn(backends)
+	assert.Nil(t, err)
+	defer txn.Finish()
+	txn.SetMultiStmtTxn()
+
+	err = txn.BeginScatter()
+	assert.Nil(t, err)
+
+	// Set on the joined backend error.
+	{
+		rctx := &xcontext.RequestContext{
+			Mode:    xcontext.ReqNormal,
+			TxnMode: xcontext.TxnWrite,
+			Querys:  []xcontext.QueryTuple{{Query: "update", Backend: addrs[0]}},
+		}
+		_, err = txn.Execute(rctx)
+		assert.Nil(t, err)
+
+		err = txn.Savepoint("sp1")
+		assert.NotNil(t, err)
+		assert.Equal(t, 0, len(txn.Savepoints()))
+	}
+
+	// Set on the joining backend error.
+	{
+		txn.savepoints = []string{"sp1"}
+		rctx := &xcontext.RequestContext{
+			Mode:    xcontext.ReqNormal,
+			TxnMode: xcontext.TxnWrite,
+			Querys:  []xcontext.QueryTuple{{Query: "update", Backend: addrs[1]}},
+		}
+		_, err = txn.Execute(rctx)
+		assert.NotNil(t, err)
+		assert.Equal(t, 0, fakedb.GetQueryCalledNum("update")-1)
+	}
+
+	err = txn.RollbackScatter()
+	assert.Nil(t, err)
+}
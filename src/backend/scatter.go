@@ -250,6 +250,21 @@ func (scatter *Scatter) BackendConfigsClone() []*config.BackendConfig {
 	return beConfigs
 }
 
+// XaInDoubts returns the in-doubt XA transactions left by the recovery.
+func (scatter *Scatter) XaInDoubts() []*XaInDoubt {
+	return scatter.txnMgr.XaInDoubts()
+}
+
+// XaRecover used to rerun the XA recovery.
+func (scatter *Scatter) XaRecover() error {
+	return scatter.txnMgr.XaRecover()
+}
+
+// XaResolve used to resolve the in-doubt XA transaction by commit or rollback.
+func (scatter *Scatter) XaResolve(xaid string, action string) error {
+	return scatter.txnMgr.XaResolve(xaid, action)
+}
+
 // CreateTransaction used to create a transaction.
 func (scatter *Scatter) CreateTransaction() (*Txn, error) {
 	return scatter.txnMgr.CreateTxn(scatter.PoolzClone())
@@ -0,0 +1,102 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package backend
+
+import (
+	"xcontext"
+
+	"github.com/golang/sync/errgroup"
+	"github.com/xelabs/go-mysqlstack/sqldb"
+)
+
+var (
+	txnCounterSnapshotBegin    = "#snapshot.begin"
+	txnCounterSnapshotError    = "#snapshot.error"
+	txnCounterSnapshotCommit   = "#snapshot.commit"
+	txnCounterSnapshotRollback = "#snapshot.rollback"
+)
+
+const (
+	snapshotStart    = "START TRANSACTION WITH CONSISTENT SNAPSHOT, READ ONLY"
+	snapshotCommit   = "COMMIT"
+	snapshotRollback = "ROLLBACK"
+)
+
+// BeginSnapshot used to start a read-only transaction with a globally consistent
+// read view in the multiple-statement transaction.
+// The commit lock is held while the snapshots are opened on all the backends,
+// so no distributed txn is committed on some backends but not yet on others.
+func (txn *Txn) BeginSnapshot() error {
+	txnCounters.Add(txnCounterTxnBegin, 1)
+	txnCounters.Add(txnCounterSnapshotBegin, 1)
+	txn.twopc = true
+	txn.snapshot = true
+
+	txn.req = xcontext.NewRequestContext()
+	txn.req.Mode = xcontext.ReqScatter
+
+	// The barrier: waits the in-flight 'XA COMMIT/ROLLBACK' and blocks the new ones, see executeXA.
+	txn.mgr.CommitLock()
+	defer txn.mgr.CommitUnlock()
+	return txn.executeSnapshot(snapshotStart)
+}
+
+// IsSnapshot returns true if the txn is started by BeginSnapshot.
+func (txn *Txn) IsSnapshot() bool {
+	return txn.snapshot
+}
+
+func (txn *Txn) commitSnapshot() error {
+	txnCounters.Add(txnCounterSnapshotCommit, 1)
+	return txn.executeSnapshot(snapshotCommit)
+}
+
+func (txn *Txn) rollbackSnapshot() error {
+	txnCounters.Add(txnCounterSnapshotRollback, 1)
+	return txn.executeSnapshot(snapshotRollback)
+}
+
+// checkSnapshot used to reject the write in the snapshot txn.
+func (txn *Txn) checkSnapshot(req *xcontext.RequestContext) error {
+	if txn.snapshot && req.TxnMode == xcontext.TxnWrite {
+		return sqldb.NewSQLError1(1792, "25006", "Cannot execute statement in a READ ONLY transaction.")
+	}
+	return nil
+}
+
+// executeSnapshot used to execute the snapshot txn statements on all the backends.
+// If it fails, the connections are closed instead of recycled by the Finish,
+// because some of them maybe still in the transaction.
+func (txn *Txn) executeSnapshot(query string) error {
+	var eg errgroup.Group
+	log := txn.log
+
+	for b := range txn.backends {
+		back := b
+		eg.Go(func() error {
+			c, err := txn.twopcConnection(back)
+			if err != nil {
+				log.Error("txn.snapshot.fetch.connection.on[%s].query[%v].error:%+v", back, query, err)
+				return err
+			}
+			log.Debug("conn[%v].txn.sessid[%v].snapshot.execute[%v]", c.ID(), txn.sessionID, query)
+			if _, err := c.Execute(query); err != nil {
+				log.Error("txn.snapshot.execute[%v].on[%v].error:%+v", query, c.Address(), err)
+				return err
+			}
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		txnCounters.Add(txnCounterSnapshotError, 1)
+		txn.incErrors()
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package backend
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"xcontext"
+
+	"github.com/fortytw2/leaktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/sqldb"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestTxnSnapshot(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedb, txnMgr, backends, addrs, cleanup := MockTxnMgr(log, 2)
+	defer cleanup()
+
+	querys := []xcontext.QueryTuple{
+		xcontext.QueryTuple{Query: "select * from t", Backend: addrs[0]},
+		xcontext.QueryTuple{Query: "select * from t", Backend: addrs[1]},
+	}
+	fakedb.AddQuery(querys[0].Query, result1)
+	fakedb.AddQuery(snapshotStart, &sqltypes.Result{})
+	fakedb.AddQuery(snapshotCommit, &sqltypes.Result{})
+	fakedb.AddQuery(snapshotRollback, &sqltypes.Result{})
+
+	// Commit.
+	{
+		txn, err := txnMgr.CreateTxn(backends)
+		assert.Nil(t, err)
+		defer txn.Finish()
+		txn.SetMultiStmtTxn()
+
+		err = txn.BeginSnapshot()
+		assert.Nil(t, err)
+		assert.True(t, txn.IsSnapshot())
+		assert.Equal(t, 2, fakedb.GetQueryCalledNum("start transaction with consistent snapshot, read only"))
+
+		// Read.
+		rctx := &xcontext.RequestContext{
+			Mode:    xcontext.ReqNormal,
+			TxnMode: xcontext.TxnRead,
+			Querys:  querys,
+		}
+		_, err = txn.Execute(rctx)
+		assert.Nil(t, err)
+
+		// Write is rejected.
+		rctx = &xcontext.RequestContext{
+			Mode:    xcontext.ReqNormal,
+			TxnMode: xcontext.TxnWrite,
+			Querys:  querys,
+		}
+		_, err = txn.Execute(rctx)
+		assert.NotNil(t, err)
+		assert.Equal(t, uint16(1792), err.(*sqldb.SQLError).Num)
+
+		err = txn.CommitScatter()
+		assert.Nil(t, err)
+		assert.Equal(t, 2, fakedb.GetQueryCalledNum("commit"))
+		assert.Equal(t, 0, fakedb.GetQueryCalledNum("xa start"))
+	}
+
+	// Rollback.
+	{
+		txn, err := txnMgr.CreateTxn(backends)
+		assert.Nil(t, err)
+		defer txn.Finish()
+		txn.SetMultiStmtTxn()
+
+		err = txn.BeginSnapshot()
+		assert.Nil(t, err)
+		err = txn.RollbackScatter()
+		assert.Nil(t, err)
+		assert.Equal(t, 2, fakedb.GetQueryCalledNum("rollback"))
+	}
+}
+
+func TestTxnSnapshotWaitCommitLock(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedb, txnMgr, backends, _, cleanup := MockTxnMgr(log, 2)
+	defer cleanup()
+
+	fakedb.AddQuery(snapshotStart, &sqltypes.Result{})
+	fakedb.AddQuery(snapshotCommit, &sqltypes.Result{})
+
+	txn, err := txnMgr.CreateTxn(backends)
+	assert.Nil(t, err)
+	defer txn.Finish()
+	txn.SetMultiStmtTxn()
+
+	// The distributed commit is in-flight.
+	txnMgr.CommitLock()
+	done := make(chan error)
+	go func() {
+		done <- txn.BeginSnapshot()
+	}()
+
+	select {
+	case <-done:
+		assert.Fail(t, "snapshot.must.wait.the.commit.lock")
+	case <-time.After(100 * time.Millisecond):
+	}
+	assert.Equal(t, 0, fakedb.GetQueryCalledNum("start transaction with consistent snapshot, read only"))
+
+	txnMgr.CommitUnlock()
+	err = <-done
+	assert.Nil(t, err)
+	assert.Equal(t, 2, fakedb.GetQueryCalledNum("start transaction with consistent snapshot, read only"))
+
+	err = txn.CommitScatter()
+	assert.Nil(t, err)
+}
+
+func TestTxnSnapshotError(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedb, txnMgr, backends, _, cleanup := MockTxnMgr(log, 2)
+	defer cleanup()
+
+	fakedb.AddQueryError(snapshotStart, errors.New("mock.snapshot.error"))
+
+	txn, err := txnMgr.CreateTxn(backends)
+	assert.Nil(t, err)
+	defer txn.Finish()
+	txn.SetMultiStmtTxn()
+
+	err = txn.BeginSnapshot()
+	assert.NotNil(t, err)
+	// The connections must be closed by the finish.
+	assert.True(t, txn.errors > 0)
+}
@@ -15,7 +15,9 @@ import (
 	"xcontext"
 
 	"config"
+	"monitor"
 	"xbase/sync2"
+	"xtrace"
 
 	"github.com/golang/sync/errgroup"
 	"github.com/pkg/errors"
@@ -48,6 +50,10 @@ const (
 	txnStateRecovering
 )
 
+// ScatterGate used to enter the execution of the querys to multiple backends,
+// returns the function to leave, or error if the execution is rejected.
+type ScatterGate func() (func(), error)
+
 // Transaction interface.
 type Transaction interface {
 	XID() string
@@ -62,6 +68,11 @@ type Transaction interface {
 	Finish() error
 
 	BeginScatter() error
+	BeginSnapshot() error
+	IsSnapshot() bool
+	Savepoint(name string) error
+	RollbackToSavepoint(name string) error
+	ReleaseSavepoint(name string) error
 	CommitScatter() error
 	RollbackScatter() error
 	SetMultiStmtTxn()
@@ -72,6 +83,9 @@ type Transaction interface {
 	SetMaxResult(max int)
 	SetMaxJoinRows(max int)
 	MaxJoinRows() int
+	SetScatterGate(gate ScatterGate)
+	SetExecStats(stats *xcontext.ExecStats)
+	SetSpan(span *xtrace.Span)
 
 	Execute(req *xcontext.RequestContext) (*sqltypes.Result, error)
 	ExecuteRaw(database string, query string) (*sqltypes.Result, error)
@@ -90,6 +104,8 @@ type Txn struct {
 	twopc              bool
 	isExecOnRep        bool
 	isMultiStmtTxn     bool
+	snapshot           bool
+	deadlocked         sync2.AtomicBool
 	start              time.Time
 	state              sync2.AtomicInt32
 	xaState            sync2.AtomicInt32
@@ -97,13 +113,19 @@ type Txn struct {
 	timeout            int
 	maxResult          int
 	maxJoinRows        int
+	scatterGate        ScatterGate
+	stats              *xcontext.ExecStats
+	span               *xtrace.Span
 	errors             int
 	twopcConnections   map[string]Connection
 	normalConnections  []Connection
 	replicaConnections []Connection
+	savepoints         []string
+	joined             map[string]bool
 	twopcConnMu        sync.RWMutex
 	normalConnMu       sync.RWMutex
 	replicaConnMu      sync.RWMutex
+	savepointMu        sync.Mutex
 }
 
 // NewTxn creates the new Txn.
@@ -117,6 +139,7 @@ func NewTxn(log *xlog.Log, txid uint64, mgr *TxnManager, backends map[string]*Po
 		twopcConnections:   make(map[string]Connection),
 		normalConnections:  make([]Connection, 0, 8),
 		replicaConnections: make([]Connection, 0, 8),
+		joined:             make(map[string]bool),
 		state:              sync2.NewAtomicInt32(int32(txnStateLive)),
 	}
 	txnd := NewTxnDetail(txn)
@@ -146,6 +169,21 @@ func (txn *Txn) SetMaxJoinRows(max int) {
 	txn.maxJoinRows = max
 }
 
+// SetScatterGate used to set the gate of the querys to multiple backends.
+func (txn *Txn) SetScatterGate(gate ScatterGate) {
+	txn.scatterGate = gate
+}
+
+// SetExecStats used to set the stats which the backend querys are recorded to.
+func (txn *Txn) SetExecStats(stats *xcontext.ExecStats) {
+	txn.stats = stats
+}
+
+// SetSpan used to set the span which the backend querys are traced as its children.
+func (txn *Txn) SetSpan(span *xtrace.Span) {
+	txn.span = span
+}
+
 // MaxJoinRows returns txn maxJoinRows.
 func (txn *Txn) MaxJoinRows() int {
 	return txn.maxJoinRows
@@ -253,6 +291,7 @@ func (txn *Txn) fetchOneConnection(back string) (Connection, error) {
 	if txn.isExecOnRep {
 		conn, err = txn.replicaConnection(back)
 		if err == nil {
+			conn.SetTxnID(txn.id)
 			return conn, nil
 		}
 		log.Warning("txn.can.not.get.replica.connection.by.backend[%+v].from.pool", back)
@@ -267,6 +306,7 @@ func (txn *Txn) fetchOneConnection(back string) (Connection, error) {
 			return nil, err
 		}
 	}
+	conn.SetTxnID(txn.id)
 	return conn, nil
 }
 
@@ -285,6 +325,9 @@ func (txn *Txn) Begin() error {
 // 3. XA COMMIT
 func (txn *Txn) Commit() error {
 	txn.state.Set(int32(txnStateCommitting))
+	if txn.deadlocked.Get() {
+		return deadlockError()
+	}
 
 	// Here, we only handle the write-txn.
 	// Commit nothing for read-txn.
@@ -301,7 +344,9 @@ func (txn *Txn) Commit() error {
 		}
 
 		// 3. XA COMMIT
-		txn.xaCommit()
+		if err := txn.xaCommitWithDecision(); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -313,6 +358,9 @@ func (txn *Txn) Commit() error {
 func (txn *Txn) Rollback() error {
 	log := txn.log
 	txn.state.Set(int32(txnStateRollbacking))
+	if txn.deadlocked.Get() {
+		return nil
+	}
 
 	// Here, we only handle the write-txn.
 	// Rollback nothing for read-txn.
@@ -342,6 +390,9 @@ func (txn *Txn) Rollback() error {
 func (txn *Txn) RollbackPhaseOne() error {
 	log := txn.log
 	txn.state.Set(int32(txnStateRollbacking))
+	if txn.deadlocked.Get() {
+		return nil
+	}
 
 	// Here, we only handle the write-txn.
 	// Rollback nothing for read-txn.
@@ -372,6 +423,12 @@ func (txn *Txn) BeginScatter() error {
 // CommitScatter is used in the multiple-statement transaction
 func (txn *Txn) CommitScatter() error {
 	txn.state.Set(int32(txnStateCommitting))
+	if txn.deadlocked.Get() {
+		return deadlockError()
+	}
+	if txn.snapshot {
+		return txn.commitSnapshot()
+	}
 	txn.twopc = true
 	txn.req = xcontext.NewRequestContext()
 	txn.req.Mode = xcontext.ReqScatter
@@ -387,14 +444,20 @@ func (txn *Txn) CommitScatter() error {
 	}
 
 	// 3. XA COMMIT
-	txn.xaCommit()
-	return nil
+	return txn.xaCommitWithDecision()
 }
 
 // RollbackScatter is used in the multiple-statement transaction
 func (txn *Txn) RollbackScatter() error {
 	log := txn.log
 	txn.state.Set(int32(txnStateRollbacking))
+	// The backends rolled back the branches when the connections of the deadlock victim were killed.
+	if txn.deadlocked.Get() {
+		return nil
+	}
+	if txn.snapshot {
+		return txn.rollbackSnapshot()
+	}
 	txn.twopc = true
 	txn.req = xcontext.NewRequestContext()
 	txn.req.Mode = xcontext.ReqScatter
@@ -433,17 +496,27 @@ func (txn *Txn) ExecuteRaw(database string, query string) (*sqltypes.Result, err
 // Execute used to execute the query.
 // If the txn is in twopc mode, we do the xaStart before the real query execute.
 func (txn *Txn) Execute(req *xcontext.RequestContext) (*sqltypes.Result, error) {
+	if txn.deadlocked.Get() {
+		return nil, deadlockError()
+	}
+
 	if txn.twopc {
 		// DATA RACE in the same txn e.g, UNION etc.
 		txn.mu.Lock()
 		txn.req = req
 		txn.mu.Unlock()
 
+		if err := txn.checkSnapshot(req); err != nil {
+			return nil, err
+		}
+
 		switch req.TxnMode {
 		case xcontext.TxnRead:
-			// read-txn acquires the commit read-lock.
-			txn.mgr.CommitRLock()
-			defer txn.mgr.CommitRUnlock()
+			// read-txn acquires the commit read-lock, the snapshot txn has its read view already.
+			if !txn.snapshot {
+				txn.mgr.CommitRLock()
+				defer txn.mgr.CommitRUnlock()
+			}
 		case xcontext.TxnWrite:
 			// write-txn xa starts to the single statement.
 			if !txn.isMultiStmtTxn {
@@ -456,19 +529,31 @@ func (txn *Txn) Execute(req *xcontext.RequestContext) (*sqltypes.Result, error)
 	qr, err := txn.execute(req)
 	if err != nil {
 		txn.incErrors()
+		// The statement is interrupted by the kill of the deadlock victim.
+		if txn.deadlocked.Get() {
+			return nil, deadlockError()
+		}
 		return nil, err
 	}
 	return qr, err
 }
 
 // Execute used to execute a query to backends.
-func (txn *Txn) execute(req *xcontext.RequestContext) (*sqltypes.Result, error) {
+func (txn *Txn) execute(req *xcontext.RequestContext) (_ *sqltypes.Result, err error) {
 	var mu sync.Mutex
 	var eg errgroup.Group
 
 	log := txn.log
 	qr := &sqltypes.Result{}
 
+	span := txn.span.StartChild("backend.Txn.execute")
+	span.SetAttribute("radon.request.mode", int(req.Mode))
+	span.SetAttribute("radon.request.querys", len(req.Querys))
+	defer func() {
+		span.SetError(err)
+		span.End()
+	}()
+
 	if txn.twopc {
 		defer queryStats.Record("txn.2pc.execute", time.Now())
 		txn.state.Set(int32(txnStateExecutingTwoPC))
@@ -477,6 +562,24 @@ func (txn *Txn) execute(req *xcontext.RequestContext) (*sqltypes.Result, error)
 		txn.state.Set(int32(txnStateExecutingNormal))
 	}
 
+	// enterScatter used to enter the gate before the querys go to multiple backends.
+	enterScatter := func() (func(), error) {
+		if txn.scatterGate == nil {
+			return func() {}, nil
+		}
+		return txn.scatterGate()
+	}
+
+	// rangeOf returns the range info of the query on the backend, only for the profile.
+	rangeOf := func(back string, query string) string {
+		for _, q := range req.Querys {
+			if q.Backend == back && q.Query == query {
+				return q.Range
+			}
+		}
+		return ""
+	}
+
 	// Execute backend-querys.
 	oneShard := func(back string, txn *Txn, querys []string) error {
 		var x error
@@ -484,16 +587,60 @@ func (txn *Txn) execute(req *xcontext.RequestContext) (*sqltypes.Result, error)
 
 		if c, x = txn.fetchOneConnection(back); x != nil {
 			log.Error("txn.fetch.connection.on[%s].querys[%v].error:%+v", back, querys, x)
+		} else if x = txn.joinSavepoints(back, c); x != nil {
+			log.Error("txn.join.savepoints.on[%s].querys[%v].error:%+v", back, querys, x)
 		} else {
 			log.Debug("conn[%v].txn.sessid[%v].execute[%v]", c.ID(), txn.sessionID, querys[0])
 			for _, query := range querys {
 				var innerqr *sqltypes.Result
 
 				// Execute to backends.
-				if innerqr, x = c.ExecuteWithLimits(query, txn.timeout, txn.maxResult); x != nil {
+				start := time.Now()
+				querySpan := span.StartChildAt("backend.query", start)
+				querySpan.SetKind(xtrace.SpanKindClient)
+				innerqr, x = c.ExecuteWithLimits(query, txn.timeout, txn.maxResult)
+				latency := time.Since(start)
+				if x != nil {
+					monitor.BackendQueryDurationObserve(back, "Error", latency.Seconds())
+				} else {
+					monitor.BackendQueryDurationObserve(back, "OK", latency.Seconds())
+				}
+				querySpan.SetAttribute("db.statement", query)
+				querySpan.SetAttribute("radon.backend", back)
+				querySpan.SetAttribute("radon.range", rangeOf(back, query))
+				if x == nil {
+					querySpan.SetAttribute("db.rows", len(innerqr.Rows))
+				}
+				querySpan.SetError(x)
+				querySpan.End()
+				if req.Profile != nil || txn.stats.Detailed() {
+					prof := &xcontext.QueryProfile{
+						Query:   query,
+						Backend: back,
+						Range:   rangeOf(back, query),
+						Latency: latency,
+					}
+					if x != nil {
+						prof.Error = x.Error()
+					} else {
+						prof.Rows = uint64(len(innerqr.Rows))
+						prof.Bytes = xcontext.ResultBytes(innerqr)
+					}
+					if req.Profile != nil {
+						req.Profile.AddQuery(prof)
+					}
+					txn.stats.AddQuery(prof)
+				}
+				if x != nil {
+					txn.stats.Record(0)
 					log.Error("txn.execute.on[%v].query[%v].error:%+v", c.Address(), query, x)
 					break
 				}
+				rows := innerqr.RowsAffected
+				if len(innerqr.Rows) > 0 {
+					rows = uint64(len(innerqr.Rows))
+				}
+				txn.stats.Record(rows)
 				mu.Lock()
 				qr.AppendResult(innerqr)
 				mu.Unlock()
@@ -517,6 +664,13 @@ func (txn *Txn) execute(req *xcontext.RequestContext) (*sqltypes.Result, error)
 	case xcontext.ReqScatter:
 		qs := []string{req.RawQuery}
 		beLen := len(txn.backends)
+		if beLen > 1 {
+			leave, err := enterScatter()
+			if err != nil {
+				return nil, err
+			}
+			defer leave()
+		}
 		for b, poolz := range txn.backends {
 			if poolz.conf.Role != config.NormalBackend {
 				continue
@@ -545,6 +699,13 @@ func (txn *Txn) execute(req *xcontext.RequestContext) (*sqltypes.Result, error)
 			queryMap[query.Backend] = v
 		}
 		beLen := len(queryMap)
+		if beLen > 1 {
+			leave, err := enterScatter()
+			if err != nil {
+				return nil, err
+			}
+			defer leave()
+		}
 		for b, qs := range queryMap {
 			back := b
 			querys := qs
@@ -736,9 +897,15 @@ func (txn *Txn) Finish() error {
 	defer txn.mu.Unlock()
 
 	defer tz.Remove(txn.txnd)
+	defer func() {
+		if txn.xid != "" {
+			txn.mgr.removeLiveXaid(txn.xid)
+		}
+	}()
 	defer func() {
 		txn.twopc = false
 		txn.isMultiStmtTxn = false
+		txn.snapshot = false
 	}()
 
 	// If the txn has aborted, we won't do finish.
@@ -749,7 +916,14 @@ func (txn *Txn) Finish() error {
 	txn.xaState.Set(int32(txnXAStateNone))
 	txn.state.Set(int32(txnStateFinshing))
 
+	// The connections of the deadlock victim were killed, close them.
+	if txn.deadlocked.Get() {
+		txn.incErrors()
+	}
+
 	// 2pc connections.
+	// The deadlock detector iterates the connections concurrently, see connectionKeys.
+	txn.twopcConnMu.Lock()
 	for id, conn := range txn.twopcConnections {
 		if txn.errors > 0 {
 			conn.Close()
@@ -758,8 +932,10 @@ func (txn *Txn) Finish() error {
 		}
 		delete(txn.twopcConnections, id)
 	}
+	txn.twopcConnMu.Unlock()
 
 	// normal connections.
+	txn.normalConnMu.Lock()
 	for _, conn := range txn.normalConnections {
 		if txn.errors > 0 {
 			conn.Close()
@@ -767,6 +943,7 @@ func (txn *Txn) Finish() error {
 			conn.Recycle()
 		}
 	}
+	txn.normalConnMu.Unlock()
 
 	// replica connections.
 	for _, conn := range txn.replicaConnections {
@@ -788,9 +965,15 @@ func (txn *Txn) Abort() error {
 	defer txn.mu.Unlock()
 
 	defer tz.Remove(txn.txnd)
+	defer func() {
+		if txn.xid != "" {
+			txn.mgr.removeLiveXaid(txn.xid)
+		}
+	}()
 	defer func() {
 		txn.twopc = false
 		txn.isMultiStmtTxn = false
+		txn.snapshot = false
 	}()
 
 	// If the txn has finished, we won't do abort.
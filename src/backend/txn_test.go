@@ -117,6 +117,130 @@ func TestTxnNormalExecute(t *testing.T) {
 	}
 }
 
+func TestTxnExecuteWithProfile(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+
+	fakedb, txnMgr, backends, addrs, cleanup := MockTxnMgr(log, 2)
+	defer cleanup()
+
+	querys := []xcontext.QueryTuple{
+		xcontext.QueryTuple{Query: "select * from node1", Backend: addrs[0], Range: "[0-2048)"},
+		xcontext.QueryTuple{Query: "select * from node2", Backend: addrs[1], Range: "[2048-4096)"},
+	}
+	fakedb.AddQuery(querys[0].Query, result1)
+	fakedb.AddQueryError(querys[1].Query, errors.New("mock.execute.error"))
+
+	profile := xcontext.NewProfile("Merge")
+	rctx := &xcontext.RequestContext{
+		Querys:  querys,
+		Profile: profile,
+	}
+
+	txn, err := txnMgr.CreateTxn(backends)
+	assert.Nil(t, err)
+	defer txn.Finish()
+	_, err = txn.Execute(rctx)
+	assert.NotNil(t, err)
+
+	assert.Equal(t, 2, len(profile.Querys))
+	for _, q := range profile.Querys {
+		switch q.Backend {
+		case addrs[0]:
+			assert.Equal(t, "[0-2048)", q.Range)
+			assert.Equal(t, uint64(len(result1.Rows)), q.Rows)
+			assert.Equal(t, xcontext.ResultBytes(result1), q.Bytes)
+			assert.Equal(t, "", q.Error)
+		case addrs[1]:
+			assert.Equal(t, "[2048-4096)", q.Range)
+			assert.Equal(t, uint64(0), q.Rows)
+			assert.NotEqual(t, "", q.Error)
+		}
+	}
+}
+
+func TestTxnExecuteWithExecStats(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+
+	fakedb, txnMgr, backends, addrs, cleanup := MockTxnMgr(log, 2)
+	defer cleanup()
+
+	querys := []xcontext.QueryTuple{
+		xcontext.QueryTuple{Query: "select * from node1", Backend: addrs[0]},
+		xcontext.QueryTuple{Query: "select * from node2", Backend: addrs[1]},
+	}
+	fakedb.AddQuery(querys[0].Query, result1)
+	fakedb.AddQuery(querys[1].Query, result2)
+
+	stats := xcontext.NewExecStats()
+	txn, err := txnMgr.CreateTxn(backends)
+	assert.Nil(t, err)
+	defer txn.Finish()
+	txn.SetExecStats(stats)
+	_, err = txn.Execute(&xcontext.RequestContext{Querys: querys})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2), stats.Querys())
+	assert.Equal(t, uint64(len(result1.Rows)+len(result2.Rows)), stats.Rows())
+	assert.Nil(t, stats.QueryProfiles())
+
+	// Detail.
+	stats.Reset(true)
+	_, err = txn.Execute(&xcontext.RequestContext{Querys: querys})
+	assert.Nil(t, err)
+	profiles := stats.QueryProfiles()
+	assert.Equal(t, 2, len(profiles))
+	got := make(map[string]uint64)
+	for _, prof := range profiles {
+		got[prof.Backend] = prof.Rows
+	}
+	assert.Equal(t, uint64(len(result1.Rows)), got[addrs[0]])
+	assert.Equal(t, uint64(len(result2.Rows)), got[addrs[1]])
+}
+
+func TestTxnScatterGate(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+
+	fakedb, txnMgr, backends, addrs, cleanup := MockTxnMgr(log, 2)
+	defer cleanup()
+
+	querys := []xcontext.QueryTuple{
+		xcontext.QueryTuple{Query: "select * from node1", Backend: addrs[0]},
+		xcontext.QueryTuple{Query: "select * from node2", Backend: addrs[1]},
+	}
+	fakedb.AddQuery(querys[0].Query, result1)
+	fakedb.AddQuery(querys[1].Query, result2)
+
+	entered := 0
+	left := 0
+	gate := func() (func(), error) {
+		if entered > 0 {
+			return nil, errors.New("mock.scatter.gate.rejected")
+		}
+		entered++
+		return func() { left++ }, nil
+	}
+
+	txn, err := txnMgr.CreateTxn(backends)
+	assert.Nil(t, err)
+	defer txn.Finish()
+	txn.SetScatterGate(gate)
+
+	// The query to one backend doesn't enter the gate.
+	_, err = txn.Execute(&xcontext.RequestContext{Querys: querys[:1]})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, entered)
+
+	_, err = txn.Execute(&xcontext.RequestContext{Querys: querys})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, entered)
+	assert.Equal(t, 1, left)
+
+	_, err = txn.Execute(&xcontext.RequestContext{Mode: xcontext.ReqScatter, RawQuery: querys[0].Query})
+	assert.Equal(t, "mock.scatter.gate.rejected", err.Error())
+}
+
 func TestTxnNormalExecuteWithAttach(t *testing.T) {
 	defer leaktest.Check(t)()
 	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
@@ -22,37 +22,133 @@ import (
 type TxnManager struct {
 	log        *xlog.Log
 	xaCheck    *XaCheck
+	deadlock   *DeadlockDetector
 	txnid      uint64
 	txnNums    int64
 	commitLock sync.RWMutex
+
+	// The xaids of the txns not finished, the online recovery never resolves them.
+	liveMu    sync.Mutex
+	liveXaids map[string]bool
 }
 
 // NewTxnManager creates new TxnManager.
 func NewTxnManager(log *xlog.Log) *TxnManager {
 	return &TxnManager{
-		log:   log,
-		txnid: 0,
+		log:       log,
+		txnid:     0,
+		liveXaids: make(map[string]bool),
 	}
 }
 
-// Init is used to init the async worker xaCheck.
+// Init is used to init the async worker xaCheck and recover the in-doubt XA transactions.
 func (mgr *TxnManager) Init(scatter *Scatter, ScatterConf *config.ScatterConfig) error {
 	xaChecker := NewXaCheck(scatter, ScatterConf)
 	if err := xaChecker.Init(); err != nil {
 		return err
 	}
+
+	// Resolve the PREPARED branches left by the last crash.
+	if err := xaChecker.Recover(false); err != nil {
+		xaChecker.Close()
+		return err
+	}
 	mgr.xaCheck = xaChecker
+
+	mgr.deadlock = NewDeadlockDetector(scatter, ScatterConf)
+	mgr.deadlock.Init()
 	return nil
 }
 
-// Close is used to close the async worker xaCheck.
+// Close is used to close the async worker xaCheck and the deadlock detector.
 func (mgr *TxnManager) Close() {
+	if mgr.deadlock != nil {
+		mgr.deadlock.Close()
+		mgr.deadlock = nil
+	}
 	if mgr.xaCheck != nil {
 		mgr.xaCheck.Close()
 		mgr.xaCheck = nil
 	}
 }
 
+// XaInDoubts returns the in-doubt XA transactions.
+func (mgr *TxnManager) XaInDoubts() []*XaInDoubt {
+	if mgr.xaCheck == nil {
+		return nil
+	}
+	return mgr.xaCheck.InDoubts()
+}
+
+// XaRecover used to rerun the recovery online, e.g. after the unreachable backends are back.
+func (mgr *TxnManager) XaRecover() error {
+	if mgr.xaCheck == nil {
+		return errors.New("txnmgr.xacheck.is.not.inited")
+	}
+
+	// Serialize with the phase two of the in-flight txns.
+	mgr.CommitLock()
+	defer mgr.CommitUnlock()
+	return mgr.xaCheck.Recover(true)
+}
+
+// XaResolve used to commit or rollback the in-doubt XA transaction manually.
+func (mgr *TxnManager) XaResolve(xaid string, action string) error {
+	if mgr.xaCheck == nil {
+		return errors.New("txnmgr.xacheck.is.not.inited")
+	}
+
+	// Serialize with the phase two of the in-flight txns.
+	mgr.CommitLock()
+	defer mgr.CommitUnlock()
+	return mgr.xaCheck.ResolveInDoubt(xaid, action)
+}
+
+// xaNodeID returns the node id embedded in the xaids, empty if the xacheck isn't inited.
+func (mgr *TxnManager) xaNodeID() string {
+	if mgr.xaCheck == nil {
+		return ""
+	}
+	return mgr.xaCheck.nodeID
+}
+
+// logXaDecision used to persist the commit decision before the phase two.
+func (mgr *TxnManager) logXaDecision(txn *Txn, backends []string) error {
+	if mgr.xaCheck == nil {
+		return nil
+	}
+	return mgr.xaCheck.WriteXaDecisionLog(txn, backends)
+}
+
+// forgetXaDecision used to mark the commit decision done after the phase two.
+func (mgr *TxnManager) forgetXaDecision(xaid string) {
+	if mgr.xaCheck == nil {
+		return
+	}
+	mgr.xaCheck.ForgetXaDecisionLog(xaid)
+}
+
+// addLiveXaid used to mark the xaid in-flight after the 'XA START'.
+func (mgr *TxnManager) addLiveXaid(xaid string) {
+	mgr.liveMu.Lock()
+	defer mgr.liveMu.Unlock()
+	mgr.liveXaids[xaid] = true
+}
+
+// removeLiveXaid used to unmark the xaid when the txn is finished.
+func (mgr *TxnManager) removeLiveXaid(xaid string) {
+	mgr.liveMu.Lock()
+	defer mgr.liveMu.Unlock()
+	delete(mgr.liveXaids, xaid)
+}
+
+// isLiveXaid returns true if the txn of the xaid is still in-flight on this radon.
+func (mgr *TxnManager) isLiveXaid(xaid string) bool {
+	mgr.liveMu.Lock()
+	defer mgr.liveMu.Unlock()
+	return mgr.liveXaids[xaid]
+}
+
 // GetID returns a new txnid.
 func (mgr *TxnManager) GetID() uint64 {
 	return atomic.AddUint64(&mgr.txnid, 1)
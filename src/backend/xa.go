@@ -10,9 +10,13 @@ package backend
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 	"xcontext"
 
+	"monitor"
+
 	"github.com/golang/sync/errgroup"
 	"github.com/xelabs/go-mysqlstack/sqldb"
 )
@@ -28,6 +32,7 @@ var (
 	txnCounterXaCommitError   = "#xa.commit.error"
 	txnCounterXaRollback      = "#xa.rollback"
 	txnCounterXaRollbackError = "#xa.rollback.error"
+	txnCounterXaDecisionError = "#xa.decision.error"
 )
 
 var (
@@ -59,7 +64,20 @@ func (txn *Txn) executeXACommand(query string, state txnXAState) error {
 		Mode:     txn.req.Mode,
 		Querys:   txn.req.Querys,
 	}
-	return txn.executeXA(rctx, state)
+	// The phase span, such as 'backend.Txn.xa.prepare'.
+	phase := strings.ToLower(strings.Fields(query)[1])
+	span := txn.span.StartChild("backend.Txn.xa." + phase)
+	span.SetAttribute("db.statement", query)
+	start := time.Now()
+	err := txn.executeXA(rctx, state)
+	if err != nil {
+		monitor.XADurationObserve(phase, "Error", time.Since(start).Seconds())
+	} else {
+		monitor.XADurationObserve(phase, "OK", time.Since(start).Seconds())
+	}
+	span.SetError(err)
+	span.End()
+	return err
 }
 
 // executeXA only used to execute the 'XA START','XA END', 'XA PREPARE', 'XA COMMIT'/'XA ROLLBACK' statements.
@@ -175,11 +193,21 @@ func (txn *Txn) xaStart() error {
 	txn.xaState.Set(int32(txnXAStateStart))
 	defer func() { txn.xaState.Set(int32(txnXAStateStartFinished)) }()
 
+	// The node id tells which radon the xaid belongs to, see XaCheck.Recover.
+	prefix := "RXID"
 	if txn.isMultiStmtTxn {
-		txn.xid = fmt.Sprintf("MULTRXID-%v-%v", time.Now().Format("20060102150405"), txn.id)
+		prefix = "MULTRXID"
+	}
+	if txn.xid != "" {
+		txn.mgr.removeLiveXaid(txn.xid)
+	}
+	if node := txn.mgr.xaNodeID(); node != "" {
+		txn.xid = fmt.Sprintf("%v-%v-%v-%v", prefix, time.Now().Format("20060102150405"), node, txn.id)
 	} else {
-		txn.xid = fmt.Sprintf("RXID-%v-%v", time.Now().Format("20060102150405"), txn.id)
+		txn.xid = fmt.Sprintf("%v-%v-%v", prefix, time.Now().Format("20060102150405"), txn.id)
 	}
+	// The xaid is live until the txn finished, the prepared branches of a live txn are resolved by itself.
+	txn.mgr.addLiveXaid(txn.xid)
 	start := fmt.Sprintf("XA START '%v'", txn.xid)
 	if err := txn.executeXACommand(start, txnXAStateStart); err != nil {
 		log.Error("xa.start[%v].error:%v", start, err)
@@ -222,7 +250,63 @@ func (txn *Txn) xaPrepare() error {
 	return nil
 }
 
-func (txn *Txn) xaCommit() {
+// xaParticipants returns the backends which the XA statements are sent to, see executeXA.
+func (txn *Txn) xaParticipants() []string {
+	var backends []string
+	switch txn.req.Mode {
+	case xcontext.ReqNormal:
+		seen := make(map[string]bool)
+		for _, query := range txn.req.Querys {
+			if !seen[query.Backend] {
+				seen[query.Backend] = true
+				backends = append(backends, query.Backend)
+			}
+		}
+		if len(backends) < 2 {
+			return nil
+		}
+	case xcontext.ReqScatter:
+		for back := range txn.backends {
+			backends = append(backends, back)
+		}
+	}
+	sort.Strings(backends)
+	return backends
+}
+
+// xaDecide used to persist the commit decision before the phase two.
+// Returns true if the decision is logged and must be forgotten after the commit.
+func (txn *Txn) xaDecide() (bool, error) {
+	log := txn.log
+	backends := txn.xaParticipants()
+	if len(backends) == 0 {
+		return false, nil
+	}
+	if err := txn.mgr.logXaDecision(txn, backends); err != nil {
+		log.Error("xa.decision[%v].log.error:%v", txn.xid, err)
+		txnCounters.Add(txnCounterXaDecisionError, 1)
+		txn.incErrors()
+		return false, err
+	}
+	return true, nil
+}
+
+// xaCommitWithDecision used to log the commit decision and then do the XA COMMIT.
+// If the decision can't be logged, the txn is rolled back.
+func (txn *Txn) xaCommitWithDecision() error {
+	logged, err := txn.xaDecide()
+	if err != nil {
+		txn.xaRollback()
+		return err
+	}
+
+	if txn.xaCommit() && logged {
+		txn.mgr.forgetXaDecision(txn.xid)
+	}
+	return nil
+}
+
+func (txn *Txn) xaCommit() bool {
 	log := txn.log
 	txnCounters.Add(txnCounterXaCommit, 1)
 	txn.xaState.Set(int32(txnXAStateCommit))
@@ -239,7 +323,9 @@ func (txn *Txn) xaCommit() {
 		if err := txn.WriteXaCommitErrLog(txnXACommitErrStateCommit); err != nil {
 			log.Error("txn.xa.WriteXaCommitErrLog.query[%v].error[%T]:%+v", commit, err, err)
 		}
+		return false
 	}
+	return true
 }
 
 func (txn *Txn) xaRollback() {
@@ -50,27 +50,39 @@ type XaCommitErrs struct {
 
 // XaCheck tuple.
 type XaCheck struct {
-	log     *xlog.Log
-	dir     string
-	times   int
-	scatter *Scatter
-	retrys  map[string]*XaCommitErr
-	done    chan bool
-	ticker  *time.Ticker
-	wg      sync.WaitGroup
-	mu      sync.RWMutex
+	log        *xlog.Log
+	dir        string
+	times      int
+	grace      int
+	segment    int
+	scatter    *Scatter
+	nodeID     string
+	xalog      *xaLog
+	retrys     map[string]*XaCommitErr
+	decisions  map[string]*XaDecision
+	indoubts   map[string]*XaInDoubt
+	done       chan bool
+	ticker     *time.Ticker
+	wg         sync.WaitGroup
+	mu         sync.RWMutex
+	decisionMu sync.Mutex
+	indoubtMu  sync.Mutex
 }
 
 // NewXaCheck creates the XaCheck tuple.
 func NewXaCheck(scatter *Scatter, conf *config.ScatterConfig) *XaCheck {
 	return &XaCheck{
-		log:     scatter.log,
-		dir:     conf.XaCheckDir,
-		times:   conf.XaCheckRetrys,
-		scatter: scatter,
-		retrys:  make(map[string]*XaCommitErr),
-		done:    make(chan bool),
-		ticker:  time.NewTicker(time.Duration(time.Second * time.Duration(conf.XaCheckInterval))),
+		log:       scatter.log,
+		dir:       conf.XaCheckDir,
+		times:     conf.XaCheckRetrys,
+		grace:     conf.XaRecoverGrace,
+		segment:   conf.XaLogSegmentSize,
+		scatter:   scatter,
+		retrys:    make(map[string]*XaCommitErr),
+		decisions: make(map[string]*XaDecision),
+		indoubts:  make(map[string]*XaInDoubt),
+		done:      make(chan bool),
+		ticker:    time.NewTicker(time.Duration(time.Second * time.Duration(conf.XaCheckInterval))),
 	}
 }
 
@@ -84,10 +96,18 @@ func (xc *XaCheck) Init() error {
 		return err
 	}
 
+	if err := xc.loadXaNodeID(); err != nil {
+		return err
+	}
+
 	if err := xc.LoadXaCommitErrLogs(); err != nil {
 		return err
 	}
 
+	if err := xc.LoadXaLogs(); err != nil {
+		return err
+	}
+
 	xc.wg.Add(1)
 	go func(dc *XaCheck) {
 		defer dc.wg.Done()
@@ -132,25 +152,6 @@ func (xc *XaCheck) addXaCommitErrLog(new *XaCommitErr) error {
 	return nil
 }
 
-// flushXaCommitErrLog is used to write the xaCommitErrlogs to the file.
-func (xc *XaCheck) flushXaCommitErrLog() error {
-	log := xc.log
-	file := path.Join(xc.dir, xacheckJSONFile)
-
-	// stored in the way of the array
-	var xaCommitErrs XaCommitErrs
-	for _, v := range xc.retrys {
-		xaCommitErrs.Logs = append(xaCommitErrs.Logs, v)
-	}
-
-	log.Info("xacheck.flush.to.file[%v].xaCommitErrs:%+v", file, xaCommitErrs)
-	if err := config.WriteConfig(file, xaCommitErrs); err != nil {
-		log.Panicf("xacheck.flush.config.to.file[%v].error:%v", file, err)
-		return err
-	}
-	return nil
-}
-
 // WriteXaCommitErrLog is used to write the xaCommitErrLog into the xacheck file.
 func (xc *XaCheck) WriteXaCommitErrLog(txn *Txn, state string) error {
 	xaCommitErr := &XaCommitErr{
@@ -167,12 +168,14 @@ func (xc *XaCheck) WriteXaCommitErrLog(txn *Txn, state string) error {
 		return errors.WithStack(err)
 	}
 
-	// TODO if the Radon crash at the moment.
-	// flush the mem to the file
-	if err := xc.flushXaCommitErrLog(); err != nil {
-		return errors.WithStack(err)
-	}
-	return nil
+	// append the xaCommitErrLog to the xa log
+	return xc.xalog.Append(&xaLogRecord{
+		Op:    xaLogOpCommitErr,
+		Time:  xaCommitErr.Time,
+		Xaid:  xaCommitErr.Xaid,
+		State: xaCommitErr.State,
+		Times: xaCommitErr.Times,
+	}, true)
 }
 
 // commitRetryBackends
@@ -280,10 +283,14 @@ func (xc *XaCheck) xaCommitsRetryMain() error {
 		}
 
 		if committedOrTimesout {
-			// every retry is committed, update the mem and flush to the file
+			// every retry is committed, update the mem and append to the xa log
 			delete(xc.retrys, retry.Xaid)
-			if err := xc.flushXaCommitErrLog(); err != nil {
-				return errors.WithStack(err)
+			if err := xc.xalog.Append(&xaLogRecord{
+				Op:   xaLogOpRetried,
+				Time: time.Now().Format("20060102150405"),
+				Xaid: retry.Xaid,
+			}, true); err != nil {
+				return err
 			}
 		}
 	}
@@ -307,6 +314,7 @@ func (xc *XaCheck) xaCommitCheck() {
 		select {
 		case <-xc.ticker.C:
 			xc.xaCommitsRetry()
+			xc.xaRecoverCheck()
 		case <-xc.done:
 			return
 		}
@@ -324,6 +332,7 @@ func (xc *XaCheck) ReadXaCommitErrLogs(data string) (*XaCommitErrs, error) {
 }
 
 // LoadXaCommitErrLogs is used to load all XaCommitErr from metadir/xacheck.json file.
+// The xacheck.json is written by the old version, the loaded logs are moved into the xa log by LoadXaLogs.
 func (xc *XaCheck) LoadXaCommitErrLogs() error {
 	log := xc.log
 	metadir := xc.dir
@@ -362,11 +371,14 @@ func (xc *XaCheck) LoadXaCommitErrLogs() error {
 func (xc *XaCheck) Close() {
 	close(xc.done)
 	xc.wg.Wait()
+	if xc.xalog != nil {
+		xc.xalog.Close()
+	}
 }
 
 // GetXaCheckFile get the XaCheck log file
 func (xc *XaCheck) GetXaCheckFile() string {
-	return path.Join(xc.dir, xacheckJSONFile)
+	return xc.xalog.File()
 }
 
 // RemoveXaCommitErrLogs is only used to test to avoid the noise,
@@ -0,0 +1,445 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package backend
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+const (
+	xaLogSegmentPrefix = "xalog-"
+	xaLogSegmentSuffix = ".log"
+
+	// The max requests written by one fsync.
+	xaLogMaxBatch = 512
+
+	xaLogDefaultSegmentSize = 1024 * 1024 * 64
+)
+
+const (
+	// The commit decision is made, written before the phase two.
+	xaLogOpDecide = "decide"
+	// The phase two of the decision is finished.
+	xaLogOpForget = "forget"
+	// The 'XA COMMIT/ROLLBACK' failed, retried by the xaCommitCheck.
+	xaLogOpCommitErr = "commit-err"
+	// The failed 'XA COMMIT/ROLLBACK' is retried or times out.
+	xaLogOpRetried = "retried"
+)
+
+// xaLogRecord tuple.
+type xaLogRecord struct {
+	Op       string   `json:"op"`
+	Time     string   `json:"time"`
+	Xaid     string   `json:"xaid"`
+	State    string   `json:"state,omitempty"`
+	Times    int      `json:"times,omitempty"`
+	Backends []string `json:"backends,omitempty"`
+}
+
+// key returns the key of the record in the live set.
+func (r *xaLogRecord) key() string {
+	switch r.Op {
+	case xaLogOpDecide, xaLogOpForget:
+		return xaLogOpDecide + "/" + r.Xaid
+	default:
+		return xaLogOpCommitErr + "/" + r.Xaid
+	}
+}
+
+type xaLogRequest struct {
+	record *xaLogRecord
+	sync   bool
+	done   chan error
+}
+
+// xaLog tuple.
+// The append-only log of the XA decisions and commit errors under the xa-check-dir.
+// All the records are written by one flusher, the concurrent requests are batched into one fsync(group commit).
+// The log is split into segments, when the segment is full a new one is started with the live
+// records and the old ones are removed, so the log size is bounded by the in-flight XA transactions.
+type xaLog struct {
+	log         *xlog.Log
+	dir         string
+	segmentSize int64
+	seq         uint64
+	size        int64
+	file        *os.File
+	live        map[string]*xaLogRecord
+	requests    chan *xaLogRequest
+	done        chan bool
+	closed      bool
+	wg          sync.WaitGroup
+	mu          sync.Mutex
+	closeMu     sync.RWMutex
+}
+
+func newXaLog(log *xlog.Log, dir string, segmentSize int64) *xaLog {
+	return &xaLog{
+		log:         log,
+		dir:         dir,
+		segmentSize: segmentSize,
+		live:        make(map[string]*xaLogRecord),
+		requests:    make(chan *xaLogRequest, xaLogMaxBatch),
+		done:        make(chan bool),
+	}
+}
+
+func xaLogSegmentName(seq uint64) string {
+	return fmt.Sprintf("%s%016d%s", xaLogSegmentPrefix, seq, xaLogSegmentSuffix)
+}
+
+// segments returns the seqs of the segments in the dir in order.
+func (xl *xaLog) segments() ([]uint64, error) {
+	files, err := ioutil.ReadDir(xl.dir)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var seqs []uint64
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || !strings.HasPrefix(name, xaLogSegmentPrefix) || !strings.HasSuffix(name, xaLogSegmentSuffix) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(name, xaLogSegmentPrefix), xaLogSegmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	return seqs, nil
+}
+
+// Open used to replay the segments and start a new segment with the live records.
+func (xl *xaLog) Open() error {
+	log := xl.log
+
+	seqs, err := xl.segments()
+	if err != nil {
+		return err
+	}
+	for _, seq := range seqs {
+		if err := xl.replay(path.Join(xl.dir, xaLogSegmentName(seq))); err != nil {
+			return err
+		}
+		xl.seq = seq
+	}
+
+	xl.mu.Lock()
+	err = xl.rotate()
+	xl.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	log.Info("xalog.open[%v].segments:%v.live:%v", xl.dir, len(seqs), len(xl.live))
+
+	xl.wg.Add(1)
+	go func(xl *xaLog) {
+		defer xl.wg.Done()
+		xl.flusher()
+	}(xl)
+	return nil
+}
+
+// Close used to flush the queued requests and close the log.
+func (xl *xaLog) Close() {
+	xl.closeMu.Lock()
+	if xl.closed {
+		xl.closeMu.Unlock()
+		return
+	}
+	xl.closed = true
+	xl.closeMu.Unlock()
+
+	close(xl.done)
+	xl.wg.Wait()
+
+	xl.mu.Lock()
+	defer xl.mu.Unlock()
+	if xl.file != nil {
+		xl.file.Close()
+		xl.file = nil
+	}
+}
+
+func (xl *xaLog) replay(file string) error {
+	log := xl.log
+
+	f, err := os.Open(file)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 4096), 1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		record := &xaLogRecord{}
+		if err := json.Unmarshal(line, record); err != nil {
+			// The tail record may be torn if radon crashed during the write,
+			// it isn't durable and the waiter didn't get the ack.
+			log.Warning("xalog.replay[%v].skip.torn.record[%s].error:%v", file, line, err)
+			continue
+		}
+		xl.apply(record)
+	}
+	if err := scanner.Err(); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+func (xl *xaLog) apply(record *xaLogRecord) {
+	switch record.Op {
+	case xaLogOpDecide, xaLogOpCommitErr:
+		xl.live[record.key()] = record
+	case xaLogOpForget, xaLogOpRetried:
+		delete(xl.live, record.key())
+	}
+}
+
+// Records returns the live records.
+func (xl *xaLog) Records() []*xaLogRecord {
+	xl.mu.Lock()
+	defer xl.mu.Unlock()
+
+	records := make([]*xaLogRecord, 0, len(xl.live))
+	for _, record := range xl.live {
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].key() < records[j].key()
+	})
+	return records
+}
+
+// Append used to write the record to the log.
+// If sync is true, it returns after the record is fsynced.
+func (xl *xaLog) Append(record *xaLogRecord, sync bool) error {
+	req := &xaLogRequest{record: record, sync: sync}
+	if sync {
+		req.done = make(chan error, 1)
+	}
+
+	xl.closeMu.RLock()
+	if xl.closed {
+		xl.closeMu.RUnlock()
+		return errors.New("xalog.is.closed")
+	}
+	xl.requests <- req
+	xl.closeMu.RUnlock()
+
+	if !sync {
+		return nil
+	}
+	return <-req.done
+}
+
+// Compact used to start a new segment with the live records and remove the old segments.
+func (xl *xaLog) Compact() error {
+	xl.mu.Lock()
+	defer xl.mu.Unlock()
+	return xl.rotate()
+}
+
+// File returns the current segment file.
+func (xl *xaLog) File() string {
+	xl.mu.Lock()
+	defer xl.mu.Unlock()
+	return path.Join(xl.dir, xaLogSegmentName(xl.seq))
+}
+
+func (xl *xaLog) flusher() {
+	for {
+		select {
+		case req := <-xl.requests:
+			xl.flush(xl.batch(req))
+		case <-xl.done:
+			// No more requests after closed, drain the queued.
+			for {
+				select {
+				case req := <-xl.requests:
+					xl.flush(xl.batch(req))
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// batch collects the queued requests, they are written by one fsync.
+func (xl *xaLog) batch(first *xaLogRequest) []*xaLogRequest {
+	reqs := []*xaLogRequest{first}
+	for len(reqs) < xaLogMaxBatch {
+		select {
+		case req := <-xl.requests:
+			reqs = append(reqs, req)
+		default:
+			return reqs
+		}
+	}
+	return reqs
+}
+
+func (xl *xaLog) flush(reqs []*xaLogRequest) {
+	log := xl.log
+
+	var err error
+	var buf bytes.Buffer
+	sync := false
+	for _, req := range reqs {
+		var data []byte
+		if data, err = json.Marshal(req.record); err != nil {
+			err = errors.WithStack(err)
+			break
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+		sync = sync || req.sync
+	}
+
+	xl.mu.Lock()
+	if err == nil {
+		if err = xl.write(buf.Bytes(), sync); err != nil {
+			log.Error("xalog.write.segment[%v].error:%v", xl.seq, err)
+			// The segment maybe has a torn record, start a new one.
+			if x := xl.rotate(); x != nil {
+				log.Error("xalog.rotate.segment[%v].error:%v", xl.seq, x)
+			}
+		} else {
+			for _, req := range reqs {
+				xl.apply(req.record)
+			}
+			if xl.size >= xl.segmentSize {
+				if x := xl.rotate(); x != nil {
+					log.Error("xalog.rotate.segment[%v].error:%v", xl.seq, x)
+				}
+			}
+		}
+	}
+	xl.mu.Unlock()
+
+	for _, req := range reqs {
+		if req.done != nil {
+			req.done <- err
+		}
+	}
+}
+
+func (xl *xaLog) write(data []byte, sync bool) error {
+	if xl.file == nil {
+		return errors.New("xalog.segment.is.not.opened")
+	}
+
+	n, err := xl.file.Write(data)
+	xl.size += int64(n)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if n != len(data) {
+		return errors.WithStack(io.ErrShortWrite)
+	}
+	if sync {
+		return errors.WithStack(xl.file.Sync())
+	}
+	return nil
+}
+
+// rotate used to start the next segment with the live records, then the old segments are removed.
+// Must be called with the mu held.
+func (xl *xaLog) rotate() error {
+	log := xl.log
+
+	var buf bytes.Buffer
+	keys := make([]string, 0, len(xl.live))
+	for key := range xl.live {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		data, err := json.Marshal(xl.live[key])
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+
+	seq := xl.seq + 1
+	file := path.Join(xl.dir, xaLogSegmentName(seq))
+	f, err := os.OpenFile(file, os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND, 0644)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return errors.WithStack(err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return errors.WithStack(err)
+	}
+	if err := syncDir(xl.dir); err != nil {
+		f.Close()
+		return err
+	}
+
+	if xl.file != nil {
+		xl.file.Close()
+	}
+	xl.file = f
+	xl.seq = seq
+	xl.size = int64(buf.Len())
+
+	// The live records are durable in the new segment, the old ones can be removed.
+	seqs, err := xl.segments()
+	if err != nil {
+		return err
+	}
+	for _, old := range seqs {
+		if old >= seq {
+			continue
+		}
+		if err := os.Remove(path.Join(xl.dir, xaLogSegmentName(old))); err != nil {
+			log.Warning("xalog.remove.segment[%v].error:%v", old, err)
+		}
+	}
+	return nil
+}
+
+// syncDir used to make the created/removed files in the dir durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer d.Close()
+	return errors.WithStack(d.Sync())
+}
@@ -0,0 +1,188 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package backend
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+
+	"fakedb"
+
+	"github.com/fortytw2/leaktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestXaLogGroupCommit(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	dir := fakedb.GetTmpDir("/tmp", "xalog", log)
+	defer os.RemoveAll(dir)
+
+	xl := newXaLog(log, dir, xaLogDefaultSegmentSize)
+	err := xl.Open()
+	assert.Nil(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			xaid := fmt.Sprintf("RXID-20180903103145-%d", i)
+			err := xl.Append(&xaLogRecord{Op: xaLogOpDecide, Xaid: xaid, Backends: []string{"backend0", "backend1"}}, true)
+			assert.Nil(t, err)
+			if i%2 == 0 {
+				err = xl.Append(&xaLogRecord{Op: xaLogOpForget, Xaid: xaid}, false)
+				assert.Nil(t, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	xl.Close()
+
+	// Append after closed.
+	err = xl.Append(&xaLogRecord{Op: xaLogOpDecide, Xaid: "RXID-20180903103145-100"}, true)
+	assert.NotNil(t, err)
+
+	// Replay.
+	xl = newXaLog(log, dir, xaLogDefaultSegmentSize)
+	err = xl.Open()
+	assert.Nil(t, err)
+	defer xl.Close()
+	records := xl.Records()
+	assert.Equal(t, 50, len(records))
+	for _, record := range records {
+		assert.Equal(t, xaLogOpDecide, record.Op)
+		assert.Equal(t, []string{"backend0", "backend1"}, record.Backends)
+	}
+
+	// The old segment is compacted by the open.
+	_, err = os.Stat(path.Join(dir, xaLogSegmentName(1)))
+	assert.True(t, os.IsNotExist(err))
+	assert.Equal(t, path.Join(dir, xaLogSegmentName(2)), xl.File())
+}
+
+func TestXaLogRotate(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	dir := fakedb.GetTmpDir("/tmp", "xalog", log)
+	defer os.RemoveAll(dir)
+
+	xl := newXaLog(log, dir, 1024)
+	err := xl.Open()
+	assert.Nil(t, err)
+	defer xl.Close()
+
+	err = xl.Append(&xaLogRecord{Op: xaLogOpCommitErr, Xaid: "RXID-20180903103145-0", State: "commit", Times: 10}, true)
+	assert.Nil(t, err)
+	for i := 1; i < 100; i++ {
+		xaid := fmt.Sprintf("RXID-20180903103145-%d", i)
+		err := xl.Append(&xaLogRecord{Op: xaLogOpDecide, Xaid: xaid}, true)
+		assert.Nil(t, err)
+		err = xl.Append(&xaLogRecord{Op: xaLogOpForget, Xaid: xaid}, true)
+		assert.Nil(t, err)
+	}
+
+	// Only one segment is left, and the live record is kept.
+	files, err := ioutil.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(files))
+	assert.True(t, strings.HasPrefix(files[0].Name(), xaLogSegmentPrefix))
+	assert.True(t, files[0].Size() < 1024)
+
+	got, err := ioutil.ReadFile(xl.File())
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(string(got), `{"op":"commit-err","time":"","xaid":"RXID-20180903103145-0","state":"commit","times":10}`))
+
+	// Retried.
+	err = xl.Append(&xaLogRecord{Op: xaLogOpRetried, Xaid: "RXID-20180903103145-0"}, true)
+	assert.Nil(t, err)
+	err = xl.Compact()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(xl.Records()))
+	got, err = ioutil.ReadFile(xl.File())
+	assert.Nil(t, err)
+	assert.Equal(t, "", string(got))
+}
+
+func TestXaLogReplayTorn(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	dir := fakedb.GetTmpDir("/tmp", "xalog", log)
+	defer os.RemoveAll(dir)
+
+	seg1 := `{"op":"decide","time":"20180903103145","xaid":"RXID-20180903103145-1","backends":["backend0","backend1"]}
+{"op":"commit-err","time":"20180903103145","xaid":"RXID-20180903103145-2","state":"rollback","times":3}
+`
+	seg2 := `{"op":"forget","time":"20180903103145","xaid":"RXID-20180903103145-1"}
+
+{"op":"decide","time":"20180903103146","xaid":"RXID-20180903103146-3","backends":["backend0"]}
+{"op":"decide","time":"20180903`
+	err := ioutil.WriteFile(path.Join(dir, xaLogSegmentName(1)), []byte(seg1), 0644)
+	assert.Nil(t, err)
+	err = ioutil.WriteFile(path.Join(dir, xaLogSegmentName(2)), []byte(seg2), 0644)
+	assert.Nil(t, err)
+	err = ioutil.WriteFile(path.Join(dir, "xalog-x.log"), []byte("x"), 0644)
+	assert.Nil(t, err)
+
+	xl := newXaLog(log, dir, xaLogDefaultSegmentSize)
+	err = xl.Open()
+	assert.Nil(t, err)
+	defer xl.Close()
+
+	want := []*xaLogRecord{
+		{Op: xaLogOpCommitErr, Time: "20180903103145", Xaid: "RXID-20180903103145-2", State: "rollback", Times: 3},
+		{Op: xaLogOpDecide, Time: "20180903103146", Xaid: "RXID-20180903103146-3", Backends: []string{"backend0"}},
+	}
+	assert.Equal(t, want, xl.Records())
+	assert.Equal(t, path.Join(dir, xaLogSegmentName(3)), xl.File())
+}
+
+func TestXaLogMoveXaCheckJSON(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+
+	data := `{
+    "xacommit-errs": [
+        {
+            "time": "20180903103145",
+            "xaid": "RXID-20180903103145-1",
+            "state": "rollback",
+            "times": 10
+        }
+    ]
+}`
+	dir := fakedb.GetTmpDir("/tmp", "xacheck", log)
+	defer os.RemoveAll(dir)
+	file := path.Join(dir, xacheckJSONFile)
+	err := ioutil.WriteFile(file, []byte(data), 0644)
+	assert.Nil(t, err)
+
+	scatter := NewScatter(log, "")
+	xaChecker := NewXaCheck(scatter, MockScatterDefault2(dir))
+	err = xaChecker.Init()
+	assert.Nil(t, err)
+	xaChecker.Close()
+
+	// The xacheck.json is moved into the xa log.
+	_, err = os.Stat(file)
+	assert.True(t, os.IsNotExist(err))
+
+	xaChecker = NewXaCheck(scatter, MockScatterDefault2(dir))
+	err = xaChecker.Init()
+	assert.Nil(t, err)
+	defer xaChecker.Close()
+	assert.Equal(t, 1, xaChecker.GetRetrysLen())
+	assert.Equal(t, txnXACommitErrStateRollback, xaChecker.retrys["RXID-20180903103145-1"].State)
+}
@@ -0,0 +1,486 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package backend
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	xaDecisionStateCommit = "commit"
+)
+
+const (
+	xaInDoubtActionCommit   = "commit"
+	xaInDoubtActionRollback = "rollback"
+)
+
+const (
+	xaInDoubtReasonGrace        = "within.grace"
+	xaInDoubtReasonUnknownOwner = "unknown.owner"
+)
+
+const (
+	// The file in the xa check dir which keeps the node id of this radon.
+	xaNodeIDFile = "xanode.id"
+)
+
+// XaDecision tuple.
+// The commit decision of one XA transaction, it's written before the phase two.
+type XaDecision struct {
+	Time     string   `json:"time"`
+	Xaid     string   `json:"xaid"`
+	Backends []string `json:"backends"`
+}
+
+// XaInDoubt tuple.
+// The XA branches which are PREPARED on the backends but can't be resolved by the recovery.
+type XaInDoubt struct {
+	Xaid     string   `json:"xaid"`
+	Backends []string `json:"backends"`
+	Decision string   `json:"decision"`
+	Reason   string   `json:"reason"`
+}
+
+// isRadonXaid returns true if the xaid is generated by radon, see xaStart.
+func isRadonXaid(xaid string) bool {
+	return strings.HasPrefix(xaid, "RXID-") || strings.HasPrefix(xaid, "MULTRXID-")
+}
+
+// isXaNodeID returns true if the id is 8 lowercase hex digits.
+func isXaNodeID(id string) bool {
+	if len(id) != 8 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= '0' && c <= '9') && !(c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// xaidNode returns the node id embedded in the xaid, empty if the xaid is generated by the old version without node id.
+// the format of xaid: fmt.Sprintf("RXID-%v-%v-%v", time.Now().Format("20060102150405"), nodeID, txn.id)
+func xaidNode(xaid string) string {
+	parts := strings.Split(xaid, "-")
+	if len(parts) != 4 || !isXaNodeID(parts[2]) {
+		return ""
+	}
+	return parts[2]
+}
+
+// xaidTime returns the time when the xaid was generated.
+// the format of xaid: fmt.Sprintf("RXID-%v-%v-%v", time.Now().Format("20060102150405"), nodeID, txn.id)
+func xaidTime(xaid string) (time.Time, error) {
+	parts := strings.SplitN(xaid, "-", 3)
+	if len(parts) != 3 {
+		return time.Time{}, errors.Errorf("xaid[%v].format.invalid", xaid)
+	}
+	return time.ParseInLocation("20060102150405", parts[1], time.Local)
+}
+
+// loadXaNodeID used to load the node id of this radon from the xa check dir, a new one is generated if not exists.
+// The radons sharing the backends have the different node ids, the recovery only resolves the xaids of this radon.
+func (xc *XaCheck) loadXaNodeID() error {
+	log := xc.log
+	file := path.Join(xc.dir, xaNodeIDFile)
+
+	data, err := ioutil.ReadFile(file)
+	if err == nil {
+		id := strings.TrimSpace(string(data))
+		if !isXaNodeID(id) {
+			return errors.Errorf("xacheck.node.id[%v].in.file[%v].invalid", id, file)
+		}
+		xc.nodeID = id
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return errors.WithStack(err)
+	}
+
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return errors.WithStack(err)
+	}
+	id := hex.EncodeToString(b)
+	if err := writeAppendFile(file, []byte(id)); err != nil {
+		return err
+	}
+	xc.nodeID = id
+	log.Info("xacheck.node.id[%v].generated", id)
+	return nil
+}
+
+// WriteXaDecisionLog used to write the commit decision to the xa log.
+// The decision must be durable before the phase two, so that the recovery
+// after crash knows which PREPARED branches should be committed.
+func (xc *XaCheck) WriteXaDecisionLog(txn *Txn, backends []string) error {
+	decision := &XaDecision{
+		Time:     time.Now().Format("20060102150405"),
+		Xaid:     txn.xid,
+		Backends: backends,
+	}
+
+	if err := xc.xalog.Append(&xaLogRecord{
+		Op:       xaLogOpDecide,
+		Time:     decision.Time,
+		Xaid:     decision.Xaid,
+		Backends: decision.Backends,
+	}, true); err != nil {
+		return err
+	}
+
+	xc.decisionMu.Lock()
+	defer xc.decisionMu.Unlock()
+	xc.decisions[decision.Xaid] = decision
+	return nil
+}
+
+// ForgetXaDecisionLog used to mark the decision done when the phase two finished.
+// The forget record isn't synced, losing it only makes the recovery check the xaid once more.
+func (xc *XaCheck) ForgetXaDecisionLog(xaid string) {
+	log := xc.log
+
+	xc.decisionMu.Lock()
+	if _, ok := xc.decisions[xaid]; !ok {
+		xc.decisionMu.Unlock()
+		return
+	}
+	delete(xc.decisions, xaid)
+	xc.decisionMu.Unlock()
+
+	if err := xc.xalog.Append(&xaLogRecord{
+		Op:   xaLogOpForget,
+		Time: time.Now().Format("20060102150405"),
+		Xaid: xaid,
+	}, false); err != nil {
+		log.Error("xacheck.forget.decision[%v].error:%v", xaid, err)
+	}
+}
+
+// LoadXaLogs used to open the xa log and load the pending decisions and commit errors.
+// The commit errors loaded from the old xacheck.json are moved into the xa log.
+func (xc *XaCheck) LoadXaLogs() error {
+	log := xc.log
+
+	segment := int64(xc.segment)
+	if segment <= 0 {
+		segment = xaLogDefaultSegmentSize
+	}
+	xalog := newXaLog(log, xc.dir, segment)
+	if err := xalog.Open(); err != nil {
+		return err
+	}
+
+	xc.decisionMu.Lock()
+	defer xc.decisionMu.Unlock()
+	for _, record := range xalog.Records() {
+		switch record.Op {
+		case xaLogOpDecide:
+			xc.decisions[record.Xaid] = &XaDecision{
+				Time:     record.Time,
+				Xaid:     record.Xaid,
+				Backends: record.Backends,
+			}
+		case xaLogOpCommitErr:
+			if _, ok := xc.retrys[record.Xaid]; ok {
+				continue
+			}
+			xc.retrys[record.Xaid] = &XaCommitErr{
+				Time:  record.Time,
+				Xaid:  record.Xaid,
+				State: record.State,
+				Times: record.Times,
+			}
+		}
+	}
+
+	file := path.Join(xc.dir, xacheckJSONFile)
+	if _, err := os.Stat(file); err == nil {
+		for _, retry := range xc.retrys {
+			if err := xalog.Append(&xaLogRecord{
+				Op:    xaLogOpCommitErr,
+				Time:  retry.Time,
+				Xaid:  retry.Xaid,
+				State: retry.State,
+				Times: retry.Times,
+			}, true); err != nil {
+				xalog.Close()
+				return err
+			}
+		}
+		if err := os.Remove(file); err != nil {
+			xalog.Close()
+			return errors.WithStack(err)
+		}
+		log.Warning("xacheck.load.xa.log.moved[%v].into.xa.log", file)
+	}
+	xc.xalog = xalog
+	log.Info("xacheck.load.xa.log[%v].decisions:%v.retrys:%v", xc.dir, len(xc.decisions), len(xc.retrys))
+	return nil
+}
+
+// compactXaLog used to forget the finished decisions and compact the xa log.
+func (xc *XaCheck) compactXaLog(xaids []string) error {
+	for _, xaid := range xaids {
+		if err := xc.xalog.Append(&xaLogRecord{
+			Op:   xaLogOpForget,
+			Time: time.Now().Format("20060102150405"),
+			Xaid: xaid,
+		}, true); err != nil {
+			return err
+		}
+	}
+	return xc.xalog.Compact()
+}
+
+func (xc *XaCheck) getXaDecision(xaid string) (*XaDecision, bool) {
+	xc.decisionMu.Lock()
+	defer xc.decisionMu.Unlock()
+	decision, ok := xc.decisions[xaid]
+	return decision, ok
+}
+
+// xaRecoverBackends used to collect the PREPARED radon xaids on all the backends.
+// Returns the xaid->backends map and the backends which can't be reached.
+func (xc *XaCheck) xaRecoverBackends(txn *Txn, backends []string) (map[string][]string, []string) {
+	log := xc.log
+	var unreachable []string
+	prepared := make(map[string][]string)
+
+	for _, backend := range backends {
+		result, err := txn.ExecuteOnThisBackend(backend, "XA RECOVER")
+		if err != nil {
+			log.Warning("xacheck.recover.backend[%v].xa.recover.error:%v", backend, err)
+			unreachable = append(unreachable, backend)
+			continue
+		}
+		if len(result.Fields) != 4 {
+			continue
+		}
+		for _, row := range result.Rows {
+			xaid := string(row[3].Raw())
+			if !isRadonXaid(xaid) {
+				continue
+			}
+			prepared[xaid] = append(prepared[xaid], backend)
+		}
+	}
+	return prepared, unreachable
+}
+
+// xaResolveBackends used to send 'XA COMMIT/ROLLBACK' to the backends, returns the failed backends.
+func (xc *XaCheck) xaResolveBackends(txn *Txn, xaid string, action string, backends []string) []string {
+	log := xc.log
+	var failed []string
+
+	query := fmt.Sprintf("XA %s '%s'", strings.ToUpper(action), xaid)
+	for _, backend := range backends {
+		if _, err := txn.ExecuteOnThisBackend(backend, query); err != nil {
+			log.Error("xacheck.resolve.query[%v].on.backend[%v].error:%v", query, backend, err)
+			failed = append(failed, backend)
+			continue
+		}
+		log.Warning("xacheck.resolve.query[%v].on.backend[%v].done", query, backend)
+	}
+	return failed
+}
+
+// Recover used to resolve the PREPARED branches left by a crash between the 'XA PREPARE' and 'XA COMMIT'.
+// Only the xaids of this radon are resolved, the decision log tells which xaids were decided to commit,
+// the others are rolled back (presumed abort).
+// The xaids of other radons are resolved by themselves, the xaids without node id are left in-doubt.
+// If online is true, the txns of this radon maybe in-flight, the xaids of the unfinished txns are skipped,
+// the xaids younger than the grace are left in-doubt.
+func (xc *XaCheck) Recover(online bool) error {
+	log := xc.log
+	scatter := xc.scatter
+
+	xc.mu.Lock()
+	defer xc.mu.Unlock()
+
+	backends := scatter.AllBackends()
+	if len(backends) == 0 {
+		return nil
+	}
+
+	txn, err := scatter.CreateTransaction()
+	if err != nil {
+		log.Error("xacheck.recover.create.transaction.error:[%v]", err)
+		return err
+	}
+	defer txn.Finish()
+
+	prepared, unreachable := xc.xaRecoverBackends(txn, backends)
+	xaids := make([]string, 0, len(prepared))
+	for xaid := range prepared {
+		xaids = append(xaids, xaid)
+	}
+	sort.Strings(xaids)
+
+	resolved := make(map[string]bool)
+	indoubts := make(map[string]*XaInDoubt)
+	for _, xaid := range xaids {
+		backs := prepared[xaid]
+		// The failed commit/rollback is retried by the xaCommitCheck.
+		if _, ok := xc.retrys[xaid]; ok {
+			continue
+		}
+		// The txn is still in-flight, its phase two doesn't hold the commit lock.
+		if online && xc.scatter.txnMgr.isLiveXaid(xaid) {
+			continue
+		}
+
+		action := xaInDoubtActionRollback
+		decision := "none"
+		_, decided := xc.getXaDecision(xaid)
+		if decided {
+			action = xaInDoubtActionCommit
+			decision = xaDecisionStateCommit
+		}
+		if node := xaidNode(xaid); !decided && node != xc.nodeID {
+			if node == "" {
+				indoubts[xaid] = &XaInDoubt{Xaid: xaid, Backends: backs, Decision: decision, Reason: xaInDoubtReasonUnknownOwner}
+			}
+			continue
+		}
+		if online {
+			start, err := xaidTime(xaid)
+			if err != nil || time.Since(start) < time.Duration(xc.grace)*time.Second {
+				indoubts[xaid] = &XaInDoubt{Xaid: xaid, Backends: backs, Decision: decision, Reason: xaInDoubtReasonGrace}
+				continue
+			}
+		}
+
+		if failed := xc.xaResolveBackends(txn, xaid, action, backs); len(failed) > 0 {
+			indoubts[xaid] = &XaInDoubt{Xaid: xaid, Backends: failed, Decision: decision, Reason: fmt.Sprintf("xa.%s.failed", action)}
+			continue
+		}
+		resolved[xaid] = true
+		log.Warning("xacheck.recover.xaid[%v].%s.on.backends%v", xaid, action, backs)
+	}
+
+	// Only when all the backends are reached we know the pending decisions are all finished.
+	var finished []string
+	if len(unreachable) == 0 {
+		xc.decisionMu.Lock()
+		for xaid := range xc.decisions {
+			if _, ok := prepared[xaid]; !ok || resolved[xaid] {
+				delete(xc.decisions, xaid)
+				finished = append(finished, xaid)
+			}
+		}
+		xc.decisionMu.Unlock()
+	} else {
+		log.Warning("xacheck.recover.backends%v.unreachable.the.decisions.are.kept", unreachable)
+	}
+
+	xc.indoubtMu.Lock()
+	xc.indoubts = indoubts
+	xc.indoubtMu.Unlock()
+	log.Info("xacheck.recover.done.prepared:%v.indoubt:%v", len(prepared), len(indoubts))
+	return xc.compactXaLog(finished)
+}
+
+// needRecover returns true if some in-doubt xaids can be resolved by the recovery now,
+// the grace expired or the commit/rollback failed. The xaids of unknown owner are left to the manual.
+func (xc *XaCheck) needRecover() bool {
+	xc.indoubtMu.Lock()
+	defer xc.indoubtMu.Unlock()
+
+	for _, indoubt := range xc.indoubts {
+		switch indoubt.Reason {
+		case xaInDoubtReasonUnknownOwner:
+			continue
+		case xaInDoubtReasonGrace:
+			start, err := xaidTime(indoubt.Xaid)
+			if err != nil || time.Since(start) < time.Duration(xc.grace)*time.Second {
+				continue
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// xaRecoverCheck used to rerun the recovery online if the in-doubt xaids can be resolved.
+func (xc *XaCheck) xaRecoverCheck() {
+	log := xc.log
+	if !xc.needRecover() {
+		return
+	}
+
+	// Serialize with the phase two of the in-flight txns.
+	mgr := xc.scatter.txnMgr
+	mgr.CommitLock()
+	defer mgr.CommitUnlock()
+	if err := xc.Recover(true); err != nil {
+		log.Error("xacheck.recover.check.error:%v", err)
+	}
+}
+
+// InDoubts returns the in-doubt XA branches which need to be resolved manually.
+func (xc *XaCheck) InDoubts() []*XaInDoubt {
+	xc.indoubtMu.Lock()
+	defer xc.indoubtMu.Unlock()
+
+	indoubts := make([]*XaInDoubt, 0, len(xc.indoubts))
+	for _, indoubt := range xc.indoubts {
+		indoubts = append(indoubts, indoubt)
+	}
+	sort.Slice(indoubts, func(i, j int) bool {
+		return indoubts[i].Xaid < indoubts[j].Xaid
+	})
+	return indoubts
+}
+
+// ResolveInDoubt used to commit or rollback the in-doubt xaid manually.
+func (xc *XaCheck) ResolveInDoubt(xaid string, action string) error {
+	log := xc.log
+
+	action = strings.ToLower(action)
+	if action != xaInDoubtActionCommit && action != xaInDoubtActionRollback {
+		return errors.Errorf("xacheck.resolve.action[%v].unsupported", action)
+	}
+
+	xc.indoubtMu.Lock()
+	indoubt, ok := xc.indoubts[xaid]
+	xc.indoubtMu.Unlock()
+	if !ok {
+		return errors.Errorf("xacheck.resolve.xaid[%v].not.in.doubt", xaid)
+	}
+
+	txn, err := xc.scatter.CreateTransaction()
+	if err != nil {
+		return err
+	}
+	defer txn.Finish()
+
+	if failed := xc.xaResolveBackends(txn, xaid, action, indoubt.Backends); len(failed) > 0 {
+		return errors.Errorf("xacheck.resolve.xaid[%v].%s.failed.on.backends%v", xaid, action, failed)
+	}
+	log.Warning("xacheck.resolve.xaid[%v].%s.on.backends%v.by.manual", xaid, action, indoubt.Backends)
+
+	xc.indoubtMu.Lock()
+	delete(xc.indoubts, xaid)
+	xc.indoubtMu.Unlock()
+	xc.ForgetXaDecisionLog(xaid)
+	return nil
+}
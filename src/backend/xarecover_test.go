@@ -0,0 +1,429 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"fakedb"
+	"xcontext"
+
+	"github.com/fortytw2/leaktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/sqldb"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func mockXaRecoverResult(xaids ...string) *sqltypes.Result {
+	qr := &sqltypes.Result{
+		RowsAffected: uint64(len(xaids)),
+		Fields: []*querypb.Field{
+			{Name: "formatID", Type: querypb.Type_INT64},
+			{Name: "gtrid_length", Type: querypb.Type_INT64},
+			{Name: "bqual_length", Type: querypb.Type_INT64},
+			{Name: "data", Type: querypb.Type_VARCHAR},
+		},
+	}
+	for _, xaid := range xaids {
+		qr.Rows = append(qr.Rows, []sqltypes.Value{
+			sqltypes.MakeTrusted(querypb.Type_INT64, []byte("1")),
+			sqltypes.MakeTrusted(querypb.Type_INT64, []byte(fmt.Sprintf("%d", len(xaid)))),
+			sqltypes.MakeTrusted(querypb.Type_INT64, []byte("0")),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(xaid)),
+		})
+	}
+	return qr
+}
+
+func mockXaNodeID(t *testing.T, dir string, id string) {
+	err := ioutil.WriteFile(path.Join(dir, xaNodeIDFile), []byte(id), 0644)
+	assert.Nil(t, err)
+}
+
+func TestXaRecoverCommitWithDecision(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+
+	scatter, fakedb1, cleanup := MockScatter(log, 2)
+	defer cleanup()
+
+	dir := fakedb.GetTmpDir("/tmp", "xacheck", log)
+	defer os.RemoveAll(dir)
+	mockXaNodeID(t, dir, "0a0b0c0d")
+	data := `{"op":"decide","time":"20180903103145","xaid":"RXID-20180903103145-0a0b0c0d-1","backends":["backend0","backend1"]}
+{"op":"decide","time":"20180903103145","xaid":"RXID-20180903103145-0a0b0c0d-2","backends":["backend0","backend1"]}
+{"op":"forget","time":"20180903103146","xaid":"RXID-20180903103145-0a0b0c0d-2"}
+{"op":"decide","time":"20180903103146","xaid":"RXID-2018`
+	err := ioutil.WriteFile(path.Join(dir, xaLogSegmentName(1)), []byte(data), 0644)
+	assert.Nil(t, err)
+
+	fakedb1.AddQuery("XA RECOVER", mockXaRecoverResult(
+		"RXID-20180903103145-0a0b0c0d-1",
+		"RXID-20180903103145-0a0b0c0d-3",
+		"RXID-20180903103145-ffffffff-4",
+		"RXID-20180903103145-5",
+		"other-xid"))
+	fakedb1.AddQueryPattern("XA COMMIT .*", &sqltypes.Result{})
+	fakedb1.AddQueryPattern("XA ROLLBACK .*", &sqltypes.Result{})
+
+	err = scatter.Init(MockScatterDefault2(dir))
+	assert.Nil(t, err)
+
+	// The xaid with decision is committed, the orphan of this radon is rolled back, others are untouched.
+	assert.Equal(t, 2, fakedb1.GetQueryCalledNum("xa commit 'rxid-20180903103145-0a0b0c0d-1'"))
+	assert.Equal(t, 2, fakedb1.GetQueryCalledNum("xa rollback 'rxid-20180903103145-0a0b0c0d-3'"))
+	assert.Equal(t, 0, fakedb1.GetQueryCalledNum("xa rollback 'rxid-20180903103145-ffffffff-4'"))
+	assert.Equal(t, 0, fakedb1.GetQueryCalledNum("xa rollback 'rxid-20180903103145-5'"))
+	assert.Equal(t, 0, fakedb1.GetQueryCalledNum("xa rollback 'other-xid'"))
+
+	// The xaid without node id is left in-doubt.
+	indoubts := scatter.XaInDoubts()
+	assert.Equal(t, 1, len(indoubts))
+	assert.Equal(t, "RXID-20180903103145-5", indoubts[0].Xaid)
+	assert.Equal(t, "unknown.owner", indoubts[0].Reason)
+
+	// All the decisions are finished, the log is compacted.
+	_, err = os.Stat(path.Join(dir, xaLogSegmentName(1)))
+	assert.True(t, os.IsNotExist(err))
+	got, err := ioutil.ReadFile(scatter.txnMgr.xaCheck.GetXaCheckFile())
+	assert.Nil(t, err)
+	assert.Equal(t, "", string(got))
+}
+
+func TestXaRecoverInDoubt(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+
+	scatter, fakedb1, cleanup := MockScatter(log, 2)
+	defer cleanup()
+
+	dir := fakedb.GetTmpDir("/tmp", "xacheck", log)
+	defer os.RemoveAll(dir)
+	mockXaNodeID(t, dir, "0a0b0c0d")
+	data := `{"op":"decide","time":"20180903103145","xaid":"RXID-20180903103145-0a0b0c0d-1","backends":["backend0","backend1"]}
+`
+	err := ioutil.WriteFile(path.Join(dir, xaLogSegmentName(1)), []byte(data), 0644)
+	assert.Nil(t, err)
+
+	// At startup, the young xaid of this radon is rolled back.
+	young := fmt.Sprintf("RXID-%v-0a0b0c0d-9", time.Now().Format("20060102150405"))
+	fakedb1.AddQuery("XA RECOVER", mockXaRecoverResult("RXID-20180903103145-0a0b0c0d-1", young))
+	fakedb1.AddQueryErrorPattern("XA COMMIT .*", errors.New("mock.xa.commit.error"))
+	fakedb1.AddQueryPattern("XA ROLLBACK .*", &sqltypes.Result{})
+
+	conf := MockScatterDefault2(dir)
+	conf.XaRecoverGrace = 3600
+	err = scatter.Init(conf)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, fakedb1.GetQueryCalledNum(fmt.Sprintf("xa rollback '%s'", strings.ToLower(young))))
+
+	indoubts := scatter.XaInDoubts()
+	assert.Equal(t, 1, len(indoubts))
+	assert.Equal(t, "RXID-20180903103145-0a0b0c0d-1", indoubts[0].Xaid)
+	assert.Equal(t, "commit", indoubts[0].Decision)
+	assert.Equal(t, "xa.commit.failed", indoubts[0].Reason)
+
+	// Online, the young xaid maybe in-flight.
+	young = fmt.Sprintf("RXID-%v-0a0b0c0d-10", time.Now().Format("20060102150405"))
+	fakedb1.AddQuery("XA RECOVER", mockXaRecoverResult("RXID-20180903103145-0a0b0c0d-1", young))
+	err = scatter.XaRecover()
+	assert.Nil(t, err)
+	indoubts = scatter.XaInDoubts()
+	assert.Equal(t, 2, len(indoubts))
+	assert.Equal(t, "RXID-20180903103145-0a0b0c0d-1", indoubts[0].Xaid)
+	assert.Equal(t, young, indoubts[1].Xaid)
+	assert.Equal(t, "none", indoubts[1].Decision)
+	assert.Equal(t, "within.grace", indoubts[1].Reason)
+
+	// The decision of in-doubt xaid is kept.
+	got, err := ioutil.ReadFile(scatter.txnMgr.xaCheck.GetXaCheckFile())
+	assert.Nil(t, err)
+	assert.True(t, strings.Contains(string(got), "RXID-20180903103145-0a0b0c0d-1"))
+
+	// Resolve errors.
+	{
+		err = scatter.XaResolve("RXID-20180903103145-0a0b0c0d-1", "forget")
+		assert.NotNil(t, err)
+		err = scatter.XaResolve("RXID-20180903103145-0a0b0c0d-8", "commit")
+		assert.NotNil(t, err)
+		err = scatter.XaResolve("RXID-20180903103145-0a0b0c0d-1", "commit")
+		assert.NotNil(t, err)
+		assert.Equal(t, 2, len(scatter.XaInDoubts()))
+	}
+
+	// Resolve.
+	{
+		fakedb1.ResetPatternErrors()
+		fakedb1.AddQueryPattern("XA COMMIT .*", &sqltypes.Result{})
+		err = scatter.XaResolve("RXID-20180903103145-0a0b0c0d-1", "COMMIT")
+		assert.Nil(t, err)
+		err = scatter.XaResolve(young, "rollback")
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(scatter.XaInDoubts()))
+	}
+}
+
+func TestXaRecoverCheck(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+
+	scatter, fakedb1, cleanup := MockScatter(log, 2)
+	defer cleanup()
+
+	dir := fakedb.GetTmpDir("/tmp", "xacheck", log)
+	defer os.RemoveAll(dir)
+	mockXaNodeID(t, dir, "0a0b0c0d")
+
+	fakedb1.AddQuery("XA RECOVER", mockXaRecoverResult())
+	fakedb1.AddQueryPattern("XA ROLLBACK .*", &sqltypes.Result{})
+	conf := MockScatterDefault2(dir)
+	conf.XaRecoverGrace = 1
+	err := scatter.Init(conf)
+	assert.Nil(t, err)
+
+	// The young xaid is in-doubt online.
+	young := fmt.Sprintf("RXID-%v-0a0b0c0d-9", time.Now().Format("20060102150405"))
+	other := fmt.Sprintf("RXID-%v-ffffffff-9", time.Now().Format("20060102150405"))
+	fakedb1.AddQuery("XA RECOVER", mockXaRecoverResult(young, other))
+	err = scatter.XaRecover()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(scatter.XaInDoubts()))
+
+	// The recovery reruns after the grace expired.
+	time.Sleep(time.Second * 3)
+	assert.Equal(t, 0, len(scatter.XaInDoubts()))
+	assert.Equal(t, 2, fakedb1.GetQueryCalledNum(fmt.Sprintf("xa rollback '%s'", strings.ToLower(young))))
+	assert.Equal(t, 0, fakedb1.GetQueryCalledNum(fmt.Sprintf("xa rollback '%s'", strings.ToLower(other))))
+}
+
+func TestXaRecoverLiveTxn(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+
+	scatter, fakedb1, cleanup := MockScatter(log, 2)
+	defer cleanup()
+
+	dir := fakedb.GetTmpDir("/tmp", "xacheck", log)
+	defer os.RemoveAll(dir)
+	mockXaNodeID(t, dir, "0a0b0c0d")
+
+	fakedb1.AddQuery("XA RECOVER", mockXaRecoverResult())
+	fakedb1.AddQueryPattern("XA .*", &sqltypes.Result{})
+	conf := MockScatterDefault2(dir)
+	conf.XaRecoverGrace = 1
+	err := scatter.Init(conf)
+	assert.Nil(t, err)
+
+	txn, err := scatter.CreateTransaction()
+	assert.Nil(t, err)
+	err = txn.BeginScatter()
+	assert.Nil(t, err)
+	xaid := txn.xid
+	fakedb1.AddQuery("XA RECOVER", mockXaRecoverResult(xaid))
+
+	// The branches of the live txn are never resolved, even the grace expired.
+	time.Sleep(time.Second * 2)
+	err = scatter.XaRecover()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(scatter.XaInDoubts()))
+	assert.Equal(t, 0, fakedb1.GetQueryCalledNum(fmt.Sprintf("xa rollback '%s'", strings.ToLower(xaid))))
+
+	// The finished txn is resolved.
+	txn.Finish()
+	err = scatter.XaRecover()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, fakedb1.GetQueryCalledNum(fmt.Sprintf("xa rollback '%s'", strings.ToLower(xaid))))
+}
+
+func TestXaNodeID(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+
+	dir := fakedb.GetTmpDir("/tmp", "xacheck", log)
+	defer os.RemoveAll(dir)
+
+	// The node id is generated and kept.
+	var nodeID string
+	{
+		scatter, fakedb1, cleanup := MockScatter(log, 2)
+		fakedb1.AddQuery("XA RECOVER", mockXaRecoverResult())
+		fakedb1.AddQueryPattern("XA .*", &sqltypes.Result{})
+		err := scatter.Init(MockScatterDefault2(dir))
+		assert.Nil(t, err)
+		nodeID = scatter.txnMgr.xaNodeID()
+		assert.True(t, isXaNodeID(nodeID))
+
+		txn, err := scatter.CreateTransaction()
+		assert.Nil(t, err)
+		err = txn.BeginScatter()
+		assert.Nil(t, err)
+		assert.Equal(t, nodeID, xaidNode(txn.xid))
+		txn.Finish()
+		cleanup()
+	}
+	{
+		scatter, fakedb1, cleanup := MockScatter(log, 2)
+		fakedb1.AddQuery("XA RECOVER", mockXaRecoverResult())
+		err := scatter.Init(MockScatterDefault2(dir))
+		assert.Nil(t, err)
+		assert.Equal(t, nodeID, scatter.txnMgr.xaNodeID())
+		cleanup()
+	}
+
+	// Invalid node id.
+	{
+		mockXaNodeID(t, dir, "node1")
+		scatter, _, cleanup := MockScatter(log, 2)
+		err := scatter.Init(MockScatterDefault2(dir))
+		assert.NotNil(t, err)
+		cleanup()
+	}
+
+	assert.Equal(t, "", xaidNode("RXID-20180903103145-1"))
+	assert.Equal(t, "", xaidNode("RXID-20180903103145-node1-1"))
+	assert.Equal(t, "0a0b0c0d", xaidNode("MULTRXID-20180903103145-0a0b0c0d-1"))
+}
+
+func TestXaRecoverBackendUnreachable(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+
+	scatter, _, cleanup := MockScatter(log, 2)
+	defer cleanup()
+
+	dir := fakedb.GetTmpDir("/tmp", "xacheck", log)
+	defer os.RemoveAll(dir)
+	data := `{"op":"decide","time":"20180903103145","xaid":"RXID-20180903103145-1","backends":["backend0","backend1"]}
+`
+	err := ioutil.WriteFile(path.Join(dir, xaLogSegmentName(1)), []byte(data), 0644)
+	assert.Nil(t, err)
+
+	// XA RECOVER returns error, the decisions must be kept.
+	err = scatter.Init(MockScatterDefault2(dir))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(scatter.XaInDoubts()))
+
+	got, err := ioutil.ReadFile(scatter.txnMgr.xaCheck.GetXaCheckFile())
+	assert.Nil(t, err)
+	assert.True(t, strings.Contains(string(got), "RXID-20180903103145-1"))
+}
+
+func TestXaDecisionLogWithCommit(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedb, txnMgr, backends, addrs, scatter, cleanup := MockTxnMgrScatter(log, 2)
+	defer cleanup()
+	conf := MockScatterDefault(log)
+	defer os.RemoveAll(conf.XaCheckDir)
+	err := scatter.Init(conf)
+	assert.Nil(t, err)
+
+	querys := []xcontext.QueryTuple{
+		xcontext.QueryTuple{Query: "update", Backend: addrs[0]},
+		xcontext.QueryTuple{Query: "update", Backend: addrs[1]},
+	}
+	fakedb.AddQuery(querys[0].Query, result1)
+	fakedb.AddQueryPattern("XA .*", result1)
+
+	rctx := &xcontext.RequestContext{
+		Mode:    xcontext.ReqNormal,
+		TxnMode: xcontext.TxnWrite,
+		Querys:  querys,
+	}
+
+	// Commit ok, the decision is done.
+	{
+		txn, err := txnMgr.CreateTxn(backends)
+		assert.Nil(t, err)
+		defer txn.Finish()
+
+		err = txn.Begin()
+		assert.Nil(t, err)
+		_, err = txn.Execute(rctx)
+		assert.Nil(t, err)
+		err = txn.Commit()
+		assert.Nil(t, err)
+
+		got, err := ioutil.ReadFile(txnMgr.xaCheck.GetXaCheckFile())
+		assert.Nil(t, err)
+		assert.True(t, strings.Contains(string(got), fmt.Sprintf(`"xaid":"%s","backends"`, txn.XID())))
+		_, ok := txnMgr.xaCheck.getXaDecision(txn.XID())
+		assert.False(t, ok)
+	}
+
+	// Commit error, the decision is pending.
+	{
+		txn, err := txnMgr.CreateTxn(backends)
+		assert.Nil(t, err)
+		defer txn.Finish()
+
+		fakedb.AddQueryErrorPattern("XA COMMIT .*", sqldb.NewSQLError1(1397, "XAE04", "XAER_NOTA: Unknown XID"))
+		err = txn.Begin()
+		assert.Nil(t, err)
+		_, err = txn.Execute(rctx)
+		assert.Nil(t, err)
+		err = txn.Commit()
+		assert.Nil(t, err)
+
+		decision, ok := txnMgr.xaCheck.getXaDecision(txn.XID())
+		assert.True(t, ok)
+		want := []string{addrs[0], addrs[1]}
+		sort.Strings(want)
+		assert.Equal(t, want, decision.Backends)
+	}
+}
+
+func TestXaDecisionLogError(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedb, txnMgr, backends, addrs, scatter, cleanup := MockTxnMgrScatter(log, 2)
+	defer cleanup()
+	conf := MockScatterDefault(log)
+	err := scatter.Init(conf)
+	assert.Nil(t, err)
+
+	querys := []xcontext.QueryTuple{
+		xcontext.QueryTuple{Query: "update", Backend: addrs[0]},
+		xcontext.QueryTuple{Query: "update", Backend: addrs[1]},
+	}
+	fakedb.AddQuery(querys[0].Query, result1)
+	fakedb.AddQueryPattern("XA .*", result1)
+
+	// Make the decision log unwritable.
+	defer os.RemoveAll(conf.XaCheckDir)
+	txnMgr.xaCheck.xalog.Close()
+
+	txn, err := txnMgr.CreateTxn(backends)
+	assert.Nil(t, err)
+	defer txn.Finish()
+
+	err = txn.Begin()
+	assert.Nil(t, err)
+	rctx := &xcontext.RequestContext{
+		Mode:    xcontext.ReqNormal,
+		TxnMode: xcontext.TxnWrite,
+		Querys:  querys,
+	}
+	_, err = txn.Execute(rctx)
+	assert.Nil(t, err)
+	err = txn.Commit()
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, fakedb.GetQueryCalledNum(fmt.Sprintf("xa commit '%s'", strings.ToLower(txn.XID()))))
+	assert.Equal(t, 2, fakedb.GetQueryCalledNum(fmt.Sprintf("xa rollback '%s'", strings.ToLower(txn.XID()))))
+}
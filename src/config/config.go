@@ -44,6 +44,19 @@ type ProxyConfig struct {
 	//If autocommit-false-is-txn=true (false by default), a client connection with cmd: set autocommit=0
 	//is treated as start a transaction, e.g. begin, start transaction.
 	AutocommitFalseIsTxn bool `json:"autocommit-false-is-txn"`
+
+	// The TLS of the client connections, enabled if the cert and key are set.
+	// If the tls-ca is set, the client certs are verified, and required if tls-verify-client=true.
+	TLSCert         string `json:"tls-cert,omitempty"`
+	TLSKey          string `json:"tls-key,omitempty"`
+	TLSCA           string `json:"tls-ca,omitempty"`
+	TLSVerifyClient bool   `json:"tls-verify-client,omitempty"`
+
+	// If require-secure-transport=true, the client connections without TLS are refused.
+	RequireSecureTransport bool `json:"require-secure-transport,omitempty"`
+
+	// If statement-digests=true (true by default), the statements are summarized into the digests.
+	StatementDigests bool `json:"statement-digests"`
 }
 
 // DefaultProxyConfig returns default proxy config.
@@ -62,6 +75,7 @@ func DefaultProxyConfig() *ProxyConfig {
 		LongQueryTime:       5,                // 5 seconds
 		StreamBufferSize:    1024 * 1024 * 32, // 32MB
 		IdleTxnTimeout:      60,               // 60 seconds
+		StatementDigests:    true,
 	}
 }
 
@@ -76,12 +90,36 @@ func (c *ProxyConfig) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// AuditFilterConfig tuple, the filter rule of the audit events.
+// An event matches the rule if it matches all the non-empty fields of the rule.
+type AuditFilterConfig struct {
+	Users  []string `json:"users,omitempty"`
+	DBs    []string `json:"databases,omitempty"`
+	Tables []string `json:"tables,omitempty"`
+	// The command types, such as SELECT, INSERT, DDL.
+	Types []string `json:"types,omitempty"`
+	// "ok" -- the successful events, "error" -- the failed events, empty for both.
+	Status string `json:"status,omitempty"`
+}
+
 // AuditConfig tuple.
 type AuditConfig struct {
 	Mode        string `json:"mode"`
 	LogDir      string `json:"audit-dir"`
 	MaxSize     int    `json:"max-size"`
 	ExpireHours int    `json:"expire-hours"`
+	// The sink of the events, "file" -- JSON lines to the rotate files, "csv" -- CSV records to the rotate files,
+	// "syslog" -- the syslog, "socket" -- JSON lines to the socket.
+	Sink string `json:"sink"`
+	// The network and address of the syslog or socket sink, the empty syslog network is the local syslog.
+	Network string `json:"network"`
+	Address string `json:"address"`
+	// Appends the hash chain to the events of the file and csv sinks, the chain continues across the files.
+	HashChain bool `json:"hash-chain"`
+	// The file of the HMAC key of the hash chain, required if the hash chain is enabled.
+	HashKeyFile string `json:"hash-key-file,omitempty"`
+	// The event is logged if it matches one of the rules, all the events are logged if no rules.
+	Filters []*AuditFilterConfig `json:"filters"`
 }
 
 // DefaultAuditConfig returns default audit config.
@@ -91,6 +129,7 @@ func DefaultAuditConfig() *AuditConfig {
 		LogDir:      "/tmp/auditlog",
 		MaxSize:     1024 * 1024 * 256, // 256MB
 		ExpireHours: 1,                 // 1hours
+		Sink:        "file",
 	}
 }
 
@@ -105,6 +144,116 @@ func (c *AuditConfig) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// SlowLogConfig tuple.
+type SlowLogConfig struct {
+	// Enables the slow log, the statements which take more than the proxy 'long-query-time' are logged.
+	Enable bool `json:"enable"`
+	// The format of the slow log, "text" -- MySQL slow log compatible, "json" -- one JSON event per line.
+	Format      string `json:"format"`
+	LogDir      string `json:"slowlog-dir"`
+	MaxSize     int    `json:"max-size"`
+	ExpireHours int    `json:"expire-hours"`
+}
+
+// DefaultSlowLogConfig returns default slow log config.
+func DefaultSlowLogConfig() *SlowLogConfig {
+	return &SlowLogConfig{
+		Enable:      false,
+		Format:      "text",
+		LogDir:      "/tmp/slowlog",
+		MaxSize:     1024 * 1024 * 256, // 256MB
+		ExpireHours: 24,                // 24hours
+	}
+}
+
+// UnmarshalJSON interface on SlowLogConfig.
+func (c *SlowLogConfig) UnmarshalJSON(b []byte) error {
+	type confAlias *SlowLogConfig
+	conf := confAlias(DefaultSlowLogConfig())
+	if err := json.Unmarshal(b, conf); err != nil {
+		return err
+	}
+	*c = SlowLogConfig(*conf)
+	return nil
+}
+
+// TraceConfig tuple.
+type TraceConfig struct {
+	// Enables the tracing of the statements.
+	Enable bool `json:"enable"`
+	// The span exporter, "otlp" -- the OTLP/HTTP(JSON) endpoint, "file" -- one OTLP JSON request per line.
+	Exporter string `json:"exporter"`
+	// The OTLP/HTTP traces endpoint, such as 'http://127.0.0.1:4318/v1/traces'.
+	Endpoint string `json:"endpoint"`
+	// The file of the "file" exporter.
+	File        string `json:"file"`
+	ServiceName string `json:"service-name"`
+	// The ratio of the statements without the traceparent comment to be traced, from 0 to 1.
+	// The statements with the traceparent are traced if the parent is sampled.
+	SampleRatio float64 `json:"sample-ratio"`
+}
+
+// DefaultTraceConfig returns default trace config.
+func DefaultTraceConfig() *TraceConfig {
+	return &TraceConfig{
+		Enable:      false,
+		Exporter:    "otlp",
+		Endpoint:    "http://127.0.0.1:4318/v1/traces",
+		File:        "/tmp/radon-trace.json",
+		ServiceName: "radon",
+		SampleRatio: 1,
+	}
+}
+
+// UnmarshalJSON interface on TraceConfig.
+func (c *TraceConfig) UnmarshalJSON(b []byte) error {
+	type confAlias *TraceConfig
+	conf := confAlias(DefaultTraceConfig())
+	if err := json.Unmarshal(b, conf); err != nil {
+		return err
+	}
+	*c = TraceConfig(*conf)
+	return nil
+}
+
+// AuthConfig tuple.
+type AuthConfig struct {
+	// The authenticator of the users:
+	// "mysql" -- the mysql.user of the backends,
+	// "file"  -- the static users file,
+	// "ldap"  -- the LDAP-like local directory, only the clear password is verified.
+	Authenticator string `json:"authenticator"`
+	// The users file of "file", or the directory file(LDIF) of "ldap".
+	File string `json:"file,omitempty"`
+	// The base DN of the user entries of "ldap", the user DN is 'uid=<user>,<ldap-base-dn>'.
+	LDAPBaseDN string `json:"ldap-base-dn,omitempty"`
+	// The auth plugin in the greeting: mysql_native_password or caching_sha2_password.
+	DefaultAuthPlugin string `json:"default-authentication-plugin"`
+	// The RSA private key(PEM) for the caching_sha2_password full authentication without TLS, generated if empty.
+	RSAPrivateKey string `json:"caching-sha2-password-private-key,omitempty"`
+	// If disable-local-root-login=true, the root from 127.0.0.1 must be authenticated too.
+	DisableLocalRootLogin bool `json:"disable-local-root-login"`
+}
+
+// DefaultAuthConfig returns default auth config.
+func DefaultAuthConfig() *AuthConfig {
+	return &AuthConfig{
+		Authenticator:     "mysql",
+		DefaultAuthPlugin: "mysql_native_password",
+	}
+}
+
+// UnmarshalJSON interface on AuthConfig.
+func (c *AuthConfig) UnmarshalJSON(b []byte) error {
+	type confAlias *AuthConfig
+	conf := confAlias(DefaultAuthConfig())
+	if err := json.Unmarshal(b, conf); err != nil {
+		return err
+	}
+	*c = AuthConfig(*conf)
+	return nil
+}
+
 // LogConfig tuple.
 type LogConfig struct {
 	Level string `json:"level"`
@@ -162,6 +311,14 @@ type BackendConfig struct {
 	Charset        string `json:"charset"`
 	MaxConnections int    `json:"max-connections"`
 	Role           int    `json:"role"`
+
+	// The TLS of the connections to the backend.
+	// If the tls-ca is empty, the backend cert is verified by the system roots.
+	TLS           bool   `json:"tls,omitempty"`
+	TLSCA         string `json:"tls-ca,omitempty"`
+	TLSCert       string `json:"tls-cert,omitempty"`
+	TLSKey        string `json:"tls-key,omitempty"`
+	TLSSkipVerify bool   `json:"tls-skip-verify,omitempty"`
 }
 
 // BackendsConfig tuple.
@@ -229,14 +386,27 @@ type ScatterConfig struct {
 	XaCheckInterval int    `json:"xa-check-interval"`
 	XaCheckDir      string `json:"xa-check-dir"`
 	XaCheckRetrys   int    `json:"xa-check-retrys"`
+
+	// The PREPARED xaids younger than the grace(in seconds) maybe in-flight,
+	// the online recovery leaves them in-doubt instead of commit/rollback.
+	XaRecoverGrace int `json:"xa-recover-grace"`
+
+	// The max size(in bytes) of one xa log segment, the full segment is compacted.
+	XaLogSegmentSize int `json:"xa-log-segment-size"`
+
+	// The interval(in milliseconds) to detect the distributed deadlocks across the backends, 0 is disabled.
+	DeadlockCheckInterval int `json:"deadlock-check-interval"`
 }
 
 // DefaultScatterConfig returns default ScatterConfig config.
 func DefaultScatterConfig() *ScatterConfig {
 	return &ScatterConfig{
-		XaCheckInterval: 10,
-		XaCheckDir:      "./xacheck", //In the production environment, don't set the tmp dir
-		XaCheckRetrys:   10,
+		XaCheckInterval:       10,
+		XaCheckDir:            "./xacheck", //In the production environment, don't set the tmp dir
+		XaCheckRetrys:         10,
+		XaRecoverGrace:        60,
+		XaLogSegmentSize:      1024 * 1024 * 64, // 64MB
+		DeadlockCheckInterval: 1000,
 	}
 }
 
@@ -251,14 +421,102 @@ func (c *ScatterConfig) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// WorkloadGroupConfig tuple, the query is matched to the group by the hint '/*+ workload=name */',
+// or the first group whose users, databases and fingerprints(regexps of the query fingerprint) all match,
+// the empty one matches any.
+type WorkloadGroupConfig struct {
+	Name         string   `json:"name"`
+	Users        []string `json:"users,omitempty"`
+	Databases    []string `json:"databases,omitempty"`
+	Fingerprints []string `json:"fingerprints,omitempty"`
+
+	// The max concurrent queries of the group, 0 means no limit.
+	Slots int `json:"slots"`
+	// The weight to share the max-concurrency with the other groups, 1 if it's 0.
+	Weight int `json:"weight"`
+	// The max queries waiting in the queue of the group, 0 means no limit.
+	QueueSize int `json:"queue-size"`
+	// The max time(in milliseconds) waiting in the queue, 0 means the default 10000ms, a negative value means no limit.
+	QueueTimeout int `json:"queue-timeout"`
+}
+
+// WorkloadConfig tuple.
+type WorkloadConfig struct {
+	// The max concurrent queries shared by all the groups, 0 means no limit.
+	MaxConcurrency int `json:"max-concurrency"`
+	// The groups, the unmatched queries are in the group 'default' which can also be configured.
+	Groups []*WorkloadGroupConfig `json:"groups"`
+}
+
+// DefaultWorkloadConfig returns default workload config.
+func DefaultWorkloadConfig() *WorkloadConfig {
+	return &WorkloadConfig{
+		MaxConcurrency: 0,
+		Groups:         []*WorkloadGroupConfig{},
+	}
+}
+
+// UnmarshalJSON interface on WorkloadConfig.
+func (c *WorkloadConfig) UnmarshalJSON(b []byte) error {
+	type confAlias *WorkloadConfig
+	conf := confAlias(DefaultWorkloadConfig())
+	if err := json.Unmarshal(b, conf); err != nil {
+		return err
+	}
+	*c = WorkloadConfig(*conf)
+	return nil
+}
+
+// EncryptedColumnConfig tuple, the column is encrypted by the key in the keyring.
+type EncryptedColumnConfig struct {
+	Database string `json:"database"`
+	Table    string `json:"table"`
+	Column   string `json:"column"`
+	// The key id in the keyring.
+	Key string `json:"key"`
+	// DETERMINISTIC keeps the equality predicates and the shard-key routing working,
+	// RANDOMIZED is for the non-queried columns.
+	Mode string `json:"mode"`
+}
+
+// EncryptionConfig tuple.
+type EncryptionConfig struct {
+	// The keyring file, the content is {"keys": [{"id": "k1", "key": "base64 of the 32 bytes key"}]}.
+	Keyring string                   `json:"keyring"`
+	Columns []*EncryptedColumnConfig `json:"columns"`
+}
+
+// DefaultEncryptionConfig returns default encryption config.
+func DefaultEncryptionConfig() *EncryptionConfig {
+	return &EncryptionConfig{
+		Columns: []*EncryptedColumnConfig{},
+	}
+}
+
+// UnmarshalJSON interface on EncryptionConfig.
+func (c *EncryptionConfig) UnmarshalJSON(b []byte) error {
+	type confAlias *EncryptionConfig
+	conf := confAlias(DefaultEncryptionConfig())
+	if err := json.Unmarshal(b, conf); err != nil {
+		return err
+	}
+	*c = EncryptionConfig(*conf)
+	return nil
+}
+
 // Config tuple.
 type Config struct {
-	Proxy   *ProxyConfig   `json:"proxy"`
-	Audit   *AuditConfig   `json:"audit"`
-	Router  *RouterConfig  `json:"router"`
-	Log     *LogConfig     `json:"log"`
-	Monitor *MonitorConfig `json:"monitor"`
-	Scatter *ScatterConfig `json:"scatter"`
+	Proxy      *ProxyConfig      `json:"proxy"`
+	Audit      *AuditConfig      `json:"audit"`
+	SlowLog    *SlowLogConfig    `json:"slowlog"`
+	Trace      *TraceConfig      `json:"trace"`
+	Router     *RouterConfig     `json:"router"`
+	Log        *LogConfig        `json:"log"`
+	Monitor    *MonitorConfig    `json:"monitor"`
+	Scatter    *ScatterConfig    `json:"scatter"`
+	Auth       *AuthConfig       `json:"auth"`
+	Workload   *WorkloadConfig   `json:"workload"`
+	Encryption *EncryptionConfig `json:"encryption"`
 }
 
 func checkConfig(conf *Config) {
@@ -270,6 +528,14 @@ func checkConfig(conf *Config) {
 		conf.Audit = DefaultAuditConfig()
 	}
 
+	if conf.SlowLog == nil {
+		conf.SlowLog = DefaultSlowLogConfig()
+	}
+
+	if conf.Trace == nil {
+		conf.Trace = DefaultTraceConfig()
+	}
+
 	if conf.Router == nil {
 		conf.Router = DefaultRouterConfig()
 	}
@@ -285,6 +551,18 @@ func checkConfig(conf *Config) {
 	if conf.Scatter == nil {
 		conf.Scatter = DefaultScatterConfig()
 	}
+
+	if conf.Auth == nil {
+		conf.Auth = DefaultAuthConfig()
+	}
+
+	if conf.Workload == nil {
+		conf.Workload = DefaultWorkloadConfig()
+	}
+
+	if conf.Encryption == nil {
+		conf.Encryption = DefaultEncryptionConfig()
+	}
 }
 
 // LoadConfig used to load the config from file.
@@ -29,12 +29,17 @@ func TestWriteConfig(t *testing.T) {
 	defer os.RemoveAll(tmpDir)
 
 	conf := &Config{
-		Proxy:   MockProxyConfig,
-		Log:     MockLogConfig,
-		Audit:   DefaultAuditConfig(),
-		Router:  DefaultRouterConfig(),
-		Monitor: DefaultMonitorConfig(),
-		Scatter: DefaultScatterConfig(),
+		Proxy:      MockProxyConfig,
+		Log:        MockLogConfig,
+		Audit:      DefaultAuditConfig(),
+		SlowLog:    DefaultSlowLogConfig(),
+		Trace:      DefaultTraceConfig(),
+		Router:     DefaultRouterConfig(),
+		Monitor:    DefaultMonitorConfig(),
+		Scatter:    DefaultScatterConfig(),
+		Auth:       DefaultAuthConfig(),
+		Workload:   DefaultWorkloadConfig(),
+		Encryption: DefaultEncryptionConfig(),
 	}
 
 	path := path.Join(tmpDir, radonTestJSON)
@@ -66,12 +71,17 @@ func TestLoadConfig(t *testing.T) {
 			PeerAddress:    ":8080",
 		}
 		conf := &Config{
-			Proxy:   mockProxyConfig,
-			Audit:   DefaultAuditConfig(),
-			Router:  DefaultRouterConfig(),
-			Monitor: DefaultMonitorConfig(),
-			Log:     MockLogConfig,
-			Scatter: DefaultScatterConfig(),
+			Proxy:      mockProxyConfig,
+			Audit:      DefaultAuditConfig(),
+			SlowLog:    DefaultSlowLogConfig(),
+			Trace:      DefaultTraceConfig(),
+			Router:     DefaultRouterConfig(),
+			Monitor:    DefaultMonitorConfig(),
+			Log:        MockLogConfig,
+			Scatter:    DefaultScatterConfig(),
+			Auth:       DefaultAuthConfig(),
+			Workload:   DefaultWorkloadConfig(),
+			Encryption: DefaultEncryptionConfig(),
 		}
 
 		err := WriteConfig(path, conf)
@@ -97,12 +107,17 @@ func TestLoadConfig(t *testing.T) {
 		assert.Nil(t, err)
 		{
 			want := &Config{
-				Proxy:   MockProxyConfig,
-				Log:     MockLogConfig,
-				Audit:   DefaultAuditConfig(),
-				Router:  DefaultRouterConfig(),
-				Monitor: DefaultMonitorConfig(),
-				Scatter: DefaultScatterConfig(),
+				Proxy:      MockProxyConfig,
+				Log:        MockLogConfig,
+				Audit:      DefaultAuditConfig(),
+				SlowLog:    DefaultSlowLogConfig(),
+				Trace:      DefaultTraceConfig(),
+				Router:     DefaultRouterConfig(),
+				Monitor:    DefaultMonitorConfig(),
+				Scatter:    DefaultScatterConfig(),
+				Auth:       DefaultAuthConfig(),
+				Workload:   DefaultWorkloadConfig(),
+				Encryption: DefaultEncryptionConfig(),
 			}
 			got, err := LoadConfig(path)
 			assert.Nil(t, err)
@@ -112,12 +127,17 @@ func TestLoadConfig(t *testing.T) {
 
 	{
 		want := &Config{
-			Proxy:   MockProxyConfig,
-			Log:     MockLogConfig,
-			Audit:   DefaultAuditConfig(),
-			Router:  DefaultRouterConfig(),
-			Monitor: DefaultMonitorConfig(),
-			Scatter: DefaultScatterConfig(),
+			Proxy:      MockProxyConfig,
+			Log:        MockLogConfig,
+			Audit:      DefaultAuditConfig(),
+			SlowLog:    DefaultSlowLogConfig(),
+			Trace:      DefaultTraceConfig(),
+			Router:     DefaultRouterConfig(),
+			Monitor:    DefaultMonitorConfig(),
+			Scatter:    DefaultScatterConfig(),
+			Auth:       DefaultAuthConfig(),
+			Workload:   DefaultWorkloadConfig(),
+			Encryption: DefaultEncryptionConfig(),
 		}
 
 		err := WriteConfig(path, want)
@@ -147,12 +167,17 @@ func TestWriteLoadConfig(t *testing.T) {
 		conf, err := LoadConfig(path)
 		assert.Nil(t, err)
 		want := &Config{
-			Proxy:   MockProxyConfig,
-			Log:     MockLogConfig,
-			Audit:   DefaultAuditConfig(),
-			Router:  DefaultRouterConfig(),
-			Monitor: DefaultMonitorConfig(),
-			Scatter: DefaultScatterConfig(),
+			Proxy:      MockProxyConfig,
+			Log:        MockLogConfig,
+			Audit:      DefaultAuditConfig(),
+			SlowLog:    DefaultSlowLogConfig(),
+			Trace:      DefaultTraceConfig(),
+			Router:     DefaultRouterConfig(),
+			Monitor:    DefaultMonitorConfig(),
+			Scatter:    DefaultScatterConfig(),
+			Auth:       DefaultAuthConfig(),
+			Workload:   DefaultWorkloadConfig(),
+			Encryption: DefaultEncryptionConfig(),
 		}
 		got := conf
 		assert.Equal(t, want, got)
@@ -257,12 +282,17 @@ func TestRouterConfigUnmarshalJSON(t *testing.T) {
 		got, err := LoadConfig(path)
 		assert.Nil(t, err)
 		want := &Config{
-			Proxy:   DefaultProxyConfig(),
-			Router:  DefaultRouterConfig(),
-			Audit:   DefaultAuditConfig(),
-			Log:     DefaultLogConfig(),
-			Monitor: DefaultMonitorConfig(),
-			Scatter: DefaultScatterConfig(),
+			Proxy:      DefaultProxyConfig(),
+			Router:     DefaultRouterConfig(),
+			Audit:      DefaultAuditConfig(),
+			SlowLog:    DefaultSlowLogConfig(),
+			Trace:      DefaultTraceConfig(),
+			Log:        DefaultLogConfig(),
+			Monitor:    DefaultMonitorConfig(),
+			Scatter:    DefaultScatterConfig(),
+			Auth:       DefaultAuthConfig(),
+			Workload:   DefaultWorkloadConfig(),
+			Encryption: DefaultEncryptionConfig(),
 		}
 		assert.Equal(t, want, got)
 	}
@@ -299,15 +329,153 @@ func TestRouterConfigUnmarshalJSON(t *testing.T) {
 		proxy := DefaultProxyConfig()
 		proxy.Endpoint = ":5566"
 		want := &Config{
-			Proxy:   proxy,
-			Router:  DefaultRouterConfig(),
-			Audit:   DefaultAuditConfig(),
-			Log:     DefaultLogConfig(),
-			Monitor: DefaultMonitorConfig(),
-			Scatter: DefaultScatterConfig(),
+			Proxy:      proxy,
+			Router:     DefaultRouterConfig(),
+			Audit:      DefaultAuditConfig(),
+			SlowLog:    DefaultSlowLogConfig(),
+			Trace:      DefaultTraceConfig(),
+			Log:        DefaultLogConfig(),
+			Monitor:    DefaultMonitorConfig(),
+			Scatter:    DefaultScatterConfig(),
+			Auth:       DefaultAuthConfig(),
+			Workload:   DefaultWorkloadConfig(),
+			Encryption: DefaultEncryptionConfig(),
 		}
 		assert.Equal(t, want, got)
 	}
+
+	// Workload.
+	{
+		os.Remove(path)
+		data := `{
+	"workload": {
+		"max-concurrency": 64,
+		"groups": [
+			{"name": "report", "users": ["reporter"], "slots": 4, "weight": 1, "queue-size": 100, "queue-timeout": 5000},
+			{"name": "oltp", "databases": ["db1"], "fingerprints": ["^select .* where id = \\?$"], "slots": 32, "weight": 8}
+		]
+	}
+}`
+		err := ioutil.WriteFile(path, []byte(data), 0644)
+		assert.Nil(t, err)
+		got, err := LoadConfig(path)
+		assert.Nil(t, err)
+
+		want := &WorkloadConfig{
+			MaxConcurrency: 64,
+			Groups: []*WorkloadGroupConfig{
+				{Name: "report", Users: []string{"reporter"}, Slots: 4, Weight: 1, QueueSize: 100, QueueTimeout: 5000},
+				{Name: "oltp", Databases: []string{"db1"}, Fingerprints: []string{`^select .* where id = \?$`}, Slots: 32, Weight: 8},
+			},
+		}
+		assert.Equal(t, want, got.Workload)
+	}
+
+	// Encryption.
+	{
+		os.Remove(path)
+		data := `{
+	"encryption": {
+		"keyring": "/etc/radon/keyring.json",
+		"columns": [
+			{"database": "db1", "table": "t1", "column": "phone", "key": "k1", "mode": "deterministic"}
+		]
+	}
+}`
+		err := ioutil.WriteFile(path, []byte(data), 0644)
+		assert.Nil(t, err)
+		got, err := LoadConfig(path)
+		assert.Nil(t, err)
+
+		want := &EncryptionConfig{
+			Keyring: "/etc/radon/keyring.json",
+			Columns: []*EncryptedColumnConfig{
+				{Database: "db1", Table: "t1", Column: "phone", Key: "k1", Mode: "deterministic"},
+			},
+		}
+		assert.Equal(t, want, got.Encryption)
+	}
+
+	// Audit sink and filters, the unset fields are default.
+	{
+		os.Remove(path)
+		data := `{
+	"audit": {
+		"mode": "A",
+		"sink": "csv",
+		"hash-chain": true,
+		"hash-key-file": "/etc/radon/audit.key",
+		"filters": [
+			{"users": ["u1"], "databases": ["db1"], "tables": ["t1"], "types": ["DELETE"], "status": "error"}
+		]
+	}
+}`
+		err := ioutil.WriteFile(path, []byte(data), 0644)
+		assert.Nil(t, err)
+		got, err := LoadConfig(path)
+		assert.Nil(t, err)
+
+		want := DefaultAuditConfig()
+		want.Mode = "A"
+		want.Sink = "csv"
+		want.HashChain = true
+		want.HashKeyFile = "/etc/radon/audit.key"
+		want.Filters = []*AuditFilterConfig{
+			{Users: []string{"u1"}, DBs: []string{"db1"}, Tables: []string{"t1"}, Types: []string{"DELETE"}, Status: "error"},
+		}
+		assert.Equal(t, want, got.Audit)
+	}
+
+	// Slow log, the unset fields are default.
+	{
+		os.Remove(path)
+		data := `{
+	"slowlog": {
+		"enable": true,
+		"format": "json",
+		"slowlog-dir": "/tmp/radon-slowlog"
+	}
+}`
+		err := ioutil.WriteFile(path, []byte(data), 0644)
+		assert.Nil(t, err)
+		got, err := LoadConfig(path)
+		assert.Nil(t, err)
+
+		want := &SlowLogConfig{
+			Enable:      true,
+			Format:      "json",
+			LogDir:      "/tmp/radon-slowlog",
+			MaxSize:     1024 * 1024 * 256,
+			ExpireHours: 24,
+		}
+		assert.Equal(t, want, got.SlowLog)
+	}
+
+	// Trace, the unset fields are default.
+	{
+		os.Remove(path)
+		data := `{
+	"trace": {
+		"enable": true,
+		"exporter": "file",
+		"sample-ratio": 0.5
+	}
+}`
+		err := ioutil.WriteFile(path, []byte(data), 0644)
+		assert.Nil(t, err)
+		got, err := LoadConfig(path)
+		assert.Nil(t, err)
+
+		want := &TraceConfig{
+			Enable:      true,
+			Exporter:    "file",
+			Endpoint:    "http://127.0.0.1:4318/v1/traces",
+			File:        "/tmp/radon-trace.json",
+			ServiceName: "radon",
+			SampleRatio: 0.5,
+		}
+		assert.Equal(t, want, got.Trace)
+	}
 }
 
 func TestReadBackendsConfigAttach(t *testing.T) {
@@ -14,6 +14,7 @@ import (
 	"net/http"
 	_ "net/http/pprof"
 
+	v1 "ctl/v1"
 	"proxy"
 
 	"github.com/ant0ine/go-json-rest/rest"
@@ -49,6 +50,7 @@ func (admin *Admin) Start() {
 		panic(err)
 	}
 
+	api.Use(v1.AdminAuditMiddleware(admin.log, admin.proxy))
 	api.SetApp(router)
 	handlers := api.MakeHandler()
 	admin.server = &http.Server{Addr: admin.proxy.PeerAddress(), Handler: handlers}
@@ -27,16 +27,30 @@ func (admin *Admin) NewRouter() (rest.App, error) {
 		rest.Put("/v1/radon/readonly", v1.ReadonlyHandler(log, proxy)),
 		rest.Put("/v1/radon/twopc", v1.TwopcHandler(log, proxy)),
 		rest.Put("/v1/radon/throttle", v1.ThrottleHandler(log, proxy)),
+		rest.Put("/v1/radon/workload", v1.WorkloadHandler(log, proxy)),
+		rest.Put("/v1/radon/firewall", v1.FirewallHandler(log, proxy)),
+		rest.Get("/v1/radon/firewall", v1.FirewallzHandler(log, proxy)),
+		rest.Put("/v1/radon/rewrite", v1.RewriteHandler(log, proxy)),
+		rest.Get("/v1/radon/rewrite", v1.RewritezHandler(log, proxy)),
+		rest.Put("/v1/radon/masking", v1.MaskingHandler(log, proxy)),
+		rest.Get("/v1/radon/masking", v1.MaskingzHandler(log, proxy)),
+		rest.Put("/v1/radon/audit", v1.AuditHandler(log, proxy)),
+		rest.Get("/v1/radon/audit", v1.AuditzHandler(log, proxy)),
+		rest.Get("/v1/radon/audit/admin/:limit", v1.AuditAdminzHandler(log, proxy)),
 		rest.Post("/v1/radon/backend", v1.AddBackendHandler(log, proxy)),
 		rest.Delete("/v1/radon/backend/:name", v1.RemoveBackendHandler(log, proxy)),
 		rest.Get("/v1/radon/restapiaddress", v1.RestAPIAddressHandler(log, proxy)),
 		rest.Get("/v1/radon/status", v1.StatusHandler(log, proxy)),
+		rest.Get("/v1/radon/xa/indoubt", v1.XaInDoubtHandler(log, proxy)),
+		rest.Post("/v1/radon/xa/recover", v1.XaRecoverHandler(log, proxy)),
+		rest.Post("/v1/radon/xa/resolve", v1.XaResolveHandler(log, proxy)),
 
 		// user
 		rest.Post("/v1/user/add", v1.CreateUserHandler(log, proxy)),
 		rest.Post("/v1/user/update", v1.AlterUserHandler(log, proxy)),
 		rest.Post("/v1/user/remove", v1.DropUserHandler(log, proxy)),
 		rest.Get("/v1/user/userz", v1.UserzHandler(log, proxy)),
+		rest.Post("/v1/user/limits", v1.UserLimitsHandler(log, proxy)),
 
 		// shard
 		rest.Get("/v1/shard/shardz", v1.ShardzHandler(log, proxy)),
@@ -63,5 +77,8 @@ func (admin *Admin) NewRouter() (rest.App, error) {
 		rest.Get("/v1/debug/configz", v1.ConfigzHandler(log, proxy)),
 		rest.Get("/v1/debug/backendz", v1.BackendzHandler(log, proxy)),
 		rest.Get("/v1/debug/schemaz", v1.SchemazHandler(log, proxy)),
+		rest.Get("/v1/debug/workloadz", v1.WorkloadzHandler(log, proxy)),
+		rest.Get("/v1/debug/digests", v1.DigestsHandler(log, proxy)),
+		rest.Delete("/v1/debug/digests", v1.DigestsResetHandler(log, proxy)),
 	)
 }
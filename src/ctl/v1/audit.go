@@ -0,0 +1,163 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package v1
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"audit"
+	"config"
+	"proxy"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+type auditParams struct {
+	Filters []*config.AuditFilterConfig `json:"filters"`
+}
+
+// AuditHandler impl.
+func AuditHandler(log *xlog.Log, proxy *proxy.Proxy) rest.HandlerFunc {
+	f := func(w rest.ResponseWriter, r *rest.Request) {
+		auditHandler(log, proxy, w, r)
+	}
+	return f
+}
+
+func auditHandler(log *xlog.Log, proxy *proxy.Proxy, w rest.ResponseWriter, r *rest.Request) {
+	p := auditParams{}
+	err := r.DecodeJsonPayload(&p)
+	if err != nil {
+		log.Error("api.v1.radon.audit.error:%+v", err)
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Warning("api.v1.radon.audit[from:%v].body:%+v", r.RemoteAddr, p)
+	if err := proxy.SetAuditFilters(p.Filters); err != nil {
+		log.Error("api.v1.radon.audit.set.error:%+v", err)
+		rest.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	// write to file.
+	if err := proxy.FlushConfig(); err != nil {
+		log.Error("api.v1.radon.audit.flush.config.error:%+v", err)
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteJson(proxy.Audit().Status())
+}
+
+// AuditzHandler impl.
+func AuditzHandler(log *xlog.Log, proxy *proxy.Proxy) rest.HandlerFunc {
+	f := func(w rest.ResponseWriter, r *rest.Request) {
+		auditzHandler(log, proxy, w, r)
+	}
+	return f
+}
+
+func auditzHandler(log *xlog.Log, proxy *proxy.Proxy, w rest.ResponseWriter, r *rest.Request) {
+	w.WriteJson(proxy.Audit().Status())
+}
+
+// AuditAdminzHandler impl.
+func AuditAdminzHandler(log *xlog.Log, proxy *proxy.Proxy) rest.HandlerFunc {
+	f := func(w rest.ResponseWriter, r *rest.Request) {
+		auditAdminzHandler(log, proxy, w, r)
+	}
+	return f
+}
+
+func auditAdminzHandler(log *xlog.Log, proxy *proxy.Proxy, w rest.ResponseWriter, r *rest.Request) {
+	limit := 100
+	if v, err := strconv.Atoi(r.PathParam("limit")); err == nil {
+		limit = v
+	}
+	w.WriteJson(proxy.Audit().AdminEvents(limit))
+}
+
+var (
+	// The requests don't change anything.
+	adminAuditSkips = map[string]bool{
+		"/v1/radon/explain": true,
+	}
+)
+
+// auditResponseWriter records the status code and the error of the response.
+type auditResponseWriter struct {
+	rest.ResponseWriter
+	code int
+	err  string
+}
+
+func (w *auditResponseWriter) WriteHeader(code int) {
+	w.code = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *auditResponseWriter) WriteJson(v interface{}) error {
+	if m, ok := v.(map[string]string); ok && w.code >= http.StatusBadRequest {
+		w.err = m[rest.ErrorFieldName]
+	}
+	return w.ResponseWriter.WriteJson(v)
+}
+
+// AdminAuditMiddleware used to record the administrative actions as the audit events,
+// the action is the method and the path of the POST, PUT and DELETE requests.
+func AdminAuditMiddleware(log *xlog.Log, proxy *proxy.Proxy) rest.MiddlewareSimple {
+	return func(handler rest.HandlerFunc) rest.HandlerFunc {
+		return func(w rest.ResponseWriter, r *rest.Request) {
+			if r.Method == http.MethodGet || adminAuditSkips[r.URL.Path] {
+				handler(w, r)
+				return
+			}
+
+			var body []byte
+			if r.Body != nil {
+				var err error
+				if body, err = ioutil.ReadAll(r.Body); err != nil {
+					log.Error("api.v1.admin.audit[from:%v].read.body.error:%+v", r.RemoteAddr, err)
+				}
+				r.Body.Close()
+				r.Body = ioutil.NopCloser(bytes.NewReader(body))
+			}
+
+			syncer := proxy.Syncer()
+			e := &audit.AdminEvent{
+				Start:             time.Now().UTC(),
+				UserHost:          r.RemoteAddr,
+				Action:            r.Method + " " + r.URL.Path,
+				Params:            audit.RedactJSON(body),
+				MetaVersionBefore: syncer.MetaVersion(),
+			}
+			if user, ok := r.Env["REMOTE_USER"].(string); ok {
+				e.User = user
+			}
+
+			aw := &auditResponseWriter{ResponseWriter: w, code: http.StatusOK}
+			handler(aw, r)
+
+			e.MetaVersionAfter = syncer.MetaVersion()
+			if aw.code >= http.StatusBadRequest {
+				e.Status = 1
+				e.Error = aw.err
+				if e.Error == "" {
+					e.Error = http.StatusText(aw.code)
+				}
+			}
+			proxy.Audit().LogAdminEvent(e)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package v1
+
+import (
+	"testing"
+
+	"config"
+	"proxy"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestCtlV1RadonAudit(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	_, proxy, cleanup := proxy.MockProxy(log)
+	defer cleanup()
+
+	{
+		// server
+		api := rest.NewApi()
+		router, _ := rest.MakeRouter(
+			rest.Put("/v1/radon/audit", AuditHandler(log, proxy)),
+			rest.Get("/v1/radon/audit", AuditzHandler(log, proxy)),
+		)
+		api.SetApp(router)
+		handler := api.MakeHandler()
+
+		// 200.
+		{
+			p := &auditParams{
+				Filters: []*config.AuditFilterConfig{
+					{Users: []string{"u1"}, Types: []string{"DELETE"}, Status: "error"},
+				},
+			}
+			recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("PUT", "http://localhost/v1/radon/audit", p))
+			recorded.CodeIs(200)
+			assert.Equal(t, p.Filters, proxy.Config().Audit.Filters)
+		}
+
+		// auditz.
+		{
+			recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("GET", "http://localhost/v1/radon/audit", nil))
+			recorded.CodeIs(200)
+			assert.Contains(t, recorded.Recorder.Body.String(), `"mode":"N","sink":"file","hash-chain":false,`)
+			assert.Contains(t, recorded.Recorder.Body.String(), `"filters":[{"users":["u1"],"types":["DELETE"],"status":"error"}]`)
+		}
+
+		// 503.
+		{
+			p := &auditParams{
+				Filters: []*config.AuditFilterConfig{{Status: "x"}},
+			}
+			recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("PUT", "http://localhost/v1/radon/audit", p))
+			recorded.CodeIs(503)
+		}
+
+		// 500.
+		{
+			recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("PUT", "http://localhost/v1/radon/audit", "x"))
+			recorded.CodeIs(500)
+		}
+	}
+}
+
+func TestCtlV1AdminAudit(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	_, proxy, cleanup := proxy.MockProxy(log)
+	defer cleanup()
+
+	// server
+	api := rest.NewApi()
+	api.Use(AdminAuditMiddleware(log, proxy))
+	router, _ := rest.MakeRouter(
+		rest.Post("/v1/radon/explain", ExplainHandler(log, proxy)),
+		rest.Post("/v1/user/add", CreateUserHandler(log, proxy)),
+		rest.Delete("/v1/radon/backend/:name", RemoveBackendHandler(log, proxy)),
+		rest.Get("/v1/radon/audit/admin/:limit", AuditAdminzHandler(log, proxy)),
+	)
+	api.SetApp(router)
+	handler := api.MakeHandler()
+
+	// The explain and the GET requests are not recorded.
+	{
+		p := &explainParams{Query: "select 1"}
+		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/radon/explain", p))
+		recorded.CodeIs(200)
+		assert.Equal(t, 0, len(proxy.Audit().AdminEvents(0)))
+	}
+
+	// The password is redacted.
+	{
+		p := &userParams{User: "u1", Password: "123456", Databases: "db1", Privilege: "ALL"}
+		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/user/add", p))
+		recorded.CodeIs(200)
+	}
+
+	// Meta changed.
+	{
+		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("DELETE", "http://localhost/v1/radon/backend/backend1", nil))
+		recorded.CodeIs(200)
+	}
+
+	// Failed.
+	{
+		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("DELETE", "http://localhost/v1/radon/backend/xx", nil))
+		recorded.CodeIs(500)
+	}
+
+	events := proxy.Audit().AdminEvents(0)
+	assert.Equal(t, 3, len(events))
+	assert.Equal(t, "POST /v1/user/add", events[0].Action)
+	assert.Contains(t, events[0].Params, `"password":"******"`)
+	assert.NotContains(t, events[0].Params, "123456")
+	assert.EqualValues(t, 0, events[0].Status)
+
+	assert.Equal(t, "DELETE /v1/radon/backend/backend1", events[1].Action)
+	assert.True(t, events[1].MetaVersionAfter > events[1].MetaVersionBefore)
+
+	assert.Equal(t, "DELETE /v1/radon/backend/xx", events[2].Action)
+	assert.EqualValues(t, 1, events[2].Status)
+	assert.NotEqual(t, "", events[2].Error)
+	assert.Equal(t, events[2].MetaVersionBefore, events[2].MetaVersionAfter)
+
+	// adminz.
+	{
+		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("GET", "http://localhost/v1/radon/audit/admin/1", nil))
+		recorded.CodeIs(200)
+		assert.Contains(t, recorded.Recorder.Body.String(), `"action":"DELETE /v1/radon/backend/xx"`)
+		assert.NotContains(t, recorded.Recorder.Body.String(), `"action":"POST /v1/user/add"`)
+	}
+}
@@ -26,6 +26,11 @@ type backendParams struct {
 	User           string `json:"user"`
 	Password       string `json:"password"`
 	MaxConnections int    `json:"max-connections"`
+	TLS            bool   `json:"tls"`
+	TLSCA          string `json:"tls-ca"`
+	TLSCert        string `json:"tls-cert"`
+	TLSKey         string `json:"tls-key"`
+	TLSSkipVerify  bool   `json:"tls-skip-verify"`
 }
 
 // AddBackendHandler impl.
@@ -71,6 +76,11 @@ func addBackendHandler(log *xlog.Log, proxy *proxy.Proxy, w rest.ResponseWriter,
 		Password:       p.Password,
 		Charset:        "utf8",
 		MaxConnections: p.MaxConnections,
+		TLS:            p.TLS,
+		TLSCA:          p.TLSCA,
+		TLSCert:        p.TLSCert,
+		TLSKey:         p.TLSKey,
+		TLSSkipVerify:  p.TLSSkipVerify,
 	}
 	log.Warning("api.v1.add[from:%v].backend[%+v]", r.RemoteAddr, conf)
 
@@ -0,0 +1,41 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package v1
+
+import (
+	"proxy"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+// DigestsHandler impl.
+func DigestsHandler(log *xlog.Log, proxy *proxy.Proxy) rest.HandlerFunc {
+	f := func(w rest.ResponseWriter, r *rest.Request) {
+		digestsHandler(log, proxy, w, r)
+	}
+	return f
+}
+
+func digestsHandler(log *xlog.Log, proxy *proxy.Proxy, w rest.ResponseWriter, r *rest.Request) {
+	w.WriteJson(proxy.Digests().Rows())
+}
+
+// DigestsResetHandler impl.
+func DigestsResetHandler(log *xlog.Log, proxy *proxy.Proxy) rest.HandlerFunc {
+	f := func(w rest.ResponseWriter, r *rest.Request) {
+		digestsResetHandler(log, proxy, w, r)
+	}
+	return f
+}
+
+func digestsResetHandler(log *xlog.Log, proxy *proxy.Proxy, w rest.ResponseWriter, r *rest.Request) {
+	proxy.Digests().Reset()
+	log.Warning("api.v1.debug.digests.reset")
+}
@@ -0,0 +1,74 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package v1
+
+import (
+	"strings"
+	"testing"
+
+	"proxy"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestCtlV1Digests(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := proxy.MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("select .*", &sqltypes.Result{})
+	}
+
+	// select.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		defer client.Quit()
+		_, err = client.FetchAll("select 1 from dual", -1)
+		assert.Nil(t, err)
+	}
+
+	{
+		api := rest.NewApi()
+		router, _ := rest.MakeRouter(
+			rest.Get("/v1/debug/digests", DigestsHandler(log, proxy)),
+			rest.Delete("/v1/debug/digests", DigestsResetHandler(log, proxy)),
+		)
+		api.SetApp(router)
+		handler := api.MakeHandler()
+
+		// digests.
+		{
+			recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("GET", "http://localhost/v1/debug/digests", nil))
+			recorded.CodeIs(200)
+			got := recorded.Recorder.Body.String()
+			assert.True(t, strings.Contains(got, `"digest-text":"select ? from dual","count":1`), got)
+			assert.True(t, strings.Contains(got, `"latency":{"1000":`), got)
+		}
+
+		// reset.
+		{
+			recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("DELETE", "http://localhost/v1/debug/digests", nil))
+			recorded.CodeIs(200)
+			assert.Equal(t, 0, len(proxy.Digests().Rows()))
+
+			recorded = test.RunRequest(t, handler, test.MakeSimpleRequest("GET", "http://localhost/v1/debug/digests", nil))
+			recorded.CodeIs(200)
+			assert.Equal(t, "[]", recorded.Recorder.Body.String())
+		}
+	}
+}
@@ -0,0 +1,74 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package v1
+
+import (
+	"net/http"
+
+	"plugins/firewall"
+	"proxy"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+type firewallParams struct {
+	Mode         *string              `json:"mode"`
+	Rules        []*firewall.Rule     `json:"rules"`
+	Fingerprints *map[string][]string `json:"fingerprints"`
+}
+
+// FirewallHandler impl.
+func FirewallHandler(log *xlog.Log, proxy *proxy.Proxy) rest.HandlerFunc {
+	f := func(w rest.ResponseWriter, r *rest.Request) {
+		firewallHandler(log, proxy, w, r)
+	}
+	return f
+}
+
+func firewallHandler(log *xlog.Log, proxy *proxy.Proxy, w rest.ResponseWriter, r *rest.Request) {
+	p := firewallParams{}
+	err := r.DecodeJsonPayload(&p)
+	if err != nil {
+		log.Error("api.v1.radon.firewall.error:%+v", err)
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Warning("api.v1.radon.firewall[from:%v].body:%+v", r.RemoteAddr, p)
+	fw := proxy.Plugins().PlugFirewall()
+	conf := fw.Config()
+	if p.Mode != nil {
+		conf.Mode = *p.Mode
+	}
+	if p.Rules != nil {
+		conf.Rules = p.Rules
+	}
+	if p.Fingerprints != nil {
+		conf.Fingerprints = *p.Fingerprints
+	}
+	if err := fw.SetConfig(conf); err != nil {
+		log.Error("api.v1.radon.firewall.set.error:%+v", err)
+		rest.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteJson(fw.Config())
+}
+
+// FirewallzHandler impl.
+func FirewallzHandler(log *xlog.Log, proxy *proxy.Proxy) rest.HandlerFunc {
+	f := func(w rest.ResponseWriter, r *rest.Request) {
+		firewallzHandler(log, proxy, w, r)
+	}
+	return f
+}
+
+func firewallzHandler(log *xlog.Log, proxy *proxy.Proxy, w rest.ResponseWriter, r *rest.Request) {
+	w.WriteJson(proxy.Plugins().PlugFirewall().Config())
+}
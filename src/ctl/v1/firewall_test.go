@@ -0,0 +1,102 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package v1
+
+import (
+	"testing"
+
+	"plugins/firewall"
+	"proxy"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestCtlV1RadonFirewall(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	_, proxy, cleanup := proxy.MockProxy(log)
+	defer cleanup()
+
+	{
+		// server
+		api := rest.NewApi()
+		router, _ := rest.MakeRouter(
+			rest.Put("/v1/radon/firewall", FirewallHandler(log, proxy)),
+			rest.Get("/v1/radon/firewall", FirewallzHandler(log, proxy)),
+		)
+		api.SetApp(router)
+		handler := api.MakeHandler()
+
+		// Rules and mode.
+		{
+			mode := "learning"
+			p := &firewallParams{
+				Mode:  &mode,
+				Rules: []*firewall.Rule{{Name: "scatter-delete", Statement: "DELETE", Scatter: true}},
+			}
+			recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("PUT", "http://localhost/v1/radon/firewall", p))
+			recorded.CodeIs(200)
+			want := `{"mode":"LEARNING","rules":[{"name":"scatter-delete","statement":"DELETE","scatter":true}],"fingerprints":{}}`
+			assert.Equal(t, want, recorded.Recorder.Body.String())
+		}
+
+		// Fingerprints only, the mode and rules are unchanged.
+		{
+			fps := map[string][]string{"u1": {"select ?"}}
+			p := &firewallParams{Fingerprints: &fps}
+			recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("PUT", "http://localhost/v1/radon/firewall", p))
+			recorded.CodeIs(200)
+
+			recorded = test.RunRequest(t, handler, test.MakeSimpleRequest("GET", "http://localhost/v1/radon/firewall", nil))
+			recorded.CodeIs(200)
+			conf := proxy.Plugins().PlugFirewall().Config()
+			assert.Equal(t, firewall.ModeLearning, conf.Mode)
+			assert.Equal(t, 1, len(conf.Rules))
+			assert.Equal(t, fps, conf.Fingerprints)
+		}
+	}
+}
+
+func TestCtlV1RadonFirewallError(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	_, proxy, cleanup := proxy.MockProxy(log)
+	defer cleanup()
+
+	{
+		// server
+		api := rest.NewApi()
+		router, _ := rest.MakeRouter(
+			rest.Put("/v1/radon/firewall", FirewallHandler(log, proxy)),
+		)
+		api.SetApp(router)
+		handler := api.MakeHandler()
+
+		// 405.
+		{
+			recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/radon/firewall", nil))
+			recorded.CodeIs(405)
+		}
+
+		// 500.
+		{
+			recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("PUT", "http://localhost/v1/radon/firewall", nil))
+			recorded.CodeIs(500)
+		}
+
+		// 503.
+		{
+			mode := "xx"
+			p := &firewallParams{Mode: &mode}
+			recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("PUT", "http://localhost/v1/radon/firewall", p))
+			recorded.CodeIs(503)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package v1
+
+import (
+	"net/http"
+
+	"plugins/masking"
+	"proxy"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+type maskingParams struct {
+	Policies []*masking.Policy `json:"policies"`
+}
+
+// MaskingHandler impl.
+func MaskingHandler(log *xlog.Log, proxy *proxy.Proxy) rest.HandlerFunc {
+	f := func(w rest.ResponseWriter, r *rest.Request) {
+		maskingHandler(log, proxy, w, r)
+	}
+	return f
+}
+
+func maskingHandler(log *xlog.Log, proxy *proxy.Proxy, w rest.ResponseWriter, r *rest.Request) {
+	p := maskingParams{}
+	err := r.DecodeJsonPayload(&p)
+	if err != nil {
+		log.Error("api.v1.radon.masking.error:%+v", err)
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Warning("api.v1.radon.masking[from:%v].body:%+v", r.RemoteAddr, p)
+	m := proxy.Plugins().PlugMasking()
+	if err := m.SetPolicies(p.Policies); err != nil {
+		log.Error("api.v1.radon.masking.set.error:%+v", err)
+		rest.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteJson(&maskingParams{Policies: m.Policies()})
+}
+
+// MaskingzHandler impl.
+func MaskingzHandler(log *xlog.Log, proxy *proxy.Proxy) rest.HandlerFunc {
+	f := func(w rest.ResponseWriter, r *rest.Request) {
+		maskingzHandler(log, proxy, w, r)
+	}
+	return f
+}
+
+func maskingzHandler(log *xlog.Log, proxy *proxy.Proxy, w rest.ResponseWriter, r *rest.Request) {
+	w.WriteJson(&maskingParams{Policies: proxy.Plugins().PlugMasking().Policies()})
+}
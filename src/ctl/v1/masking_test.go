@@ -0,0 +1,101 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package v1
+
+import (
+	"testing"
+
+	"plugins/masking"
+	"proxy"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestCtlV1RadonMasking(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	_, proxy, cleanup := proxy.MockProxy(log)
+	defer cleanup()
+
+	{
+		// server
+		api := rest.NewApi()
+		router, _ := rest.MakeRouter(
+			rest.Put("/v1/radon/masking", MaskingHandler(log, proxy)),
+			rest.Get("/v1/radon/masking", MaskingzHandler(log, proxy)),
+		)
+		api.SetApp(router)
+		handler := api.MakeHandler()
+
+		// Set.
+		{
+			p := &maskingParams{
+				Policies: []*masking.Policy{
+					{Name: "mask-phone", Database: "db1", Table: "t1", Column: "Phone", Type: "partial", Users: []string{"u1"}},
+				},
+			}
+			recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("PUT", "http://localhost/v1/radon/masking", p))
+			recorded.CodeIs(200)
+			want := `{"policies":[{"name":"mask-phone","database":"db1","table":"t1","column":"phone","type":"PARTIAL","users":["u1"]}]}`
+			assert.Equal(t, want, recorded.Recorder.Body.String())
+		}
+
+		// Get.
+		{
+			recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("GET", "http://localhost/v1/radon/masking", nil))
+			recorded.CodeIs(200)
+			assert.Equal(t, 1, len(proxy.Plugins().PlugMasking().Policies()))
+		}
+
+		// Clear.
+		{
+			p := &maskingParams{}
+			recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("PUT", "http://localhost/v1/radon/masking", p))
+			recorded.CodeIs(200)
+			assert.Equal(t, `{"policies":[]}`, recorded.Recorder.Body.String())
+		}
+	}
+}
+
+func TestCtlV1RadonMaskingError(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	_, proxy, cleanup := proxy.MockProxy(log)
+	defer cleanup()
+
+	{
+		// server
+		api := rest.NewApi()
+		router, _ := rest.MakeRouter(
+			rest.Put("/v1/radon/masking", MaskingHandler(log, proxy)),
+		)
+		api.SetApp(router)
+		handler := api.MakeHandler()
+
+		// 405.
+		{
+			recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/radon/masking", nil))
+			recorded.CodeIs(405)
+		}
+
+		// 500.
+		{
+			recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("PUT", "http://localhost/v1/radon/masking", nil))
+			recorded.CodeIs(500)
+		}
+
+		// 503.
+		{
+			p := &maskingParams{Policies: []*masking.Policy{{Name: "p1", Database: "db1", Table: "t1", Column: "c1", Type: "xx", Users: []string{"u1"}}}}
+			recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("PUT", "http://localhost/v1/radon/masking", p))
+			recorded.CodeIs(503)
+		}
+	}
+}
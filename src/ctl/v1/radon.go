@@ -18,18 +18,20 @@ import (
 )
 
 type radonParams struct {
-	MaxConnections      *int     `json:"max-connections"`
-	MaxResultSize       *int     `json:"max-result-size"`
-	MaxJoinRows         *int     `json:"max-join-rows"`
-	DDLTimeout          *int     `json:"ddl-timeout"`
-	QueryTimeout        *int     `json:"query-timeout"`
-	TwoPCEnable         *bool    `json:"twopc-enable"`
-	LoadBalance         *int     `json:"load-balance"`
-	AllowIP             []string `json:"allowip,omitempty"`
-	AuditMode           *string  `json:"audit-mode"`
-	StreamBufferSize    *int     `json:"stream-buffer-size"`
-	Blocks              *int     `json:"blocks-readonly"`
-	LowerCaseTableNames *int     `json:"lower-case-table-names"`
+	MaxConnections         *int     `json:"max-connections"`
+	MaxResultSize          *int     `json:"max-result-size"`
+	MaxJoinRows            *int     `json:"max-join-rows"`
+	DDLTimeout             *int     `json:"ddl-timeout"`
+	QueryTimeout           *int     `json:"query-timeout"`
+	TwoPCEnable            *bool    `json:"twopc-enable"`
+	LoadBalance            *int     `json:"load-balance"`
+	AllowIP                []string `json:"allowip,omitempty"`
+	AuditMode              *string  `json:"audit-mode"`
+	StreamBufferSize       *int     `json:"stream-buffer-size"`
+	Blocks                 *int     `json:"blocks-readonly"`
+	LowerCaseTableNames    *int     `json:"lower-case-table-names"`
+	RequireSecureTransport *bool    `json:"require-secure-transport"`
+	StatementDigests       *bool    `json:"statement-digests"`
 }
 
 // RadonConfigHandler impl.
@@ -84,6 +86,12 @@ func radonConfigHandler(log *xlog.Log, proxy *proxy.Proxy, w rest.ResponseWriter
 	if p.LowerCaseTableNames != nil {
 		proxy.SetLowerCaseTableNames(*p.LowerCaseTableNames)
 	}
+	if p.RequireSecureTransport != nil {
+		proxy.SetRequireSecureTransport(*p.RequireSecureTransport)
+	}
+	if p.StatementDigests != nil {
+		proxy.SetStatementDigests(*p.StatementDigests)
+	}
 
 	// reset the allow ip table list.
 	proxy.IPTable().Refresh()
@@ -48,6 +48,8 @@ func TestCtlV1RadonConfig(t *testing.T) {
 			StreamBufferSize    int      `json:"stream-buffer-size"`
 			Blocks              int      `json:"blocks-readonly"`
 			LowerCaseTableNames int      `json:"lower-case-table-names"`
+			RequireSecure       bool     `json:"require-secure-transport"`
+			StatementDigests    bool     `json:"statement-digests"`
 		}
 
 		// 200.
@@ -65,6 +67,8 @@ func TestCtlV1RadonConfig(t *testing.T) {
 				StreamBufferSize:    16777216,
 				Blocks:              128,
 				LowerCaseTableNames: 1,
+				RequireSecure:       true,
+				StatementDigests:    false,
 			}
 			recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("PUT", "http://localhost/v1/radon/config", p))
 			recorded.CodeIs(200)
@@ -82,6 +86,9 @@ func TestCtlV1RadonConfig(t *testing.T) {
 			assert.Equal(t, 16777216, radonConf.Proxy.StreamBufferSize)
 			assert.Equal(t, 128, radonConf.Router.Blocks)
 			assert.Equal(t, 1, radonConf.Proxy.LowerCaseTableNames)
+			assert.Equal(t, true, radonConf.Proxy.RequireSecureTransport)
+			assert.Equal(t, false, radonConf.Proxy.StatementDigests)
+			assert.False(t, proxy.Digests().Enabled())
 		}
 
 		// Unset AllowIP.
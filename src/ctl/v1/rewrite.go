@@ -0,0 +1,62 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package v1
+
+import (
+	"net/http"
+
+	"plugins/rewrite"
+	"proxy"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+type rewriteParams struct {
+	Rules []*rewrite.Rule `json:"rules"`
+}
+
+// RewriteHandler impl.
+func RewriteHandler(log *xlog.Log, proxy *proxy.Proxy) rest.HandlerFunc {
+	f := func(w rest.ResponseWriter, r *rest.Request) {
+		rewriteHandler(log, proxy, w, r)
+	}
+	return f
+}
+
+func rewriteHandler(log *xlog.Log, proxy *proxy.Proxy, w rest.ResponseWriter, r *rest.Request) {
+	p := rewriteParams{}
+	err := r.DecodeJsonPayload(&p)
+	if err != nil {
+		log.Error("api.v1.radon.rewrite.error:%+v", err)
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Warning("api.v1.radon.rewrite[from:%v].body:%+v", r.RemoteAddr, p)
+	rw := proxy.Plugins().PlugRewrite()
+	if err := rw.SetRules(p.Rules); err != nil {
+		log.Error("api.v1.radon.rewrite.set.error:%+v", err)
+		rest.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteJson(&rewriteParams{Rules: rw.Rules()})
+}
+
+// RewritezHandler impl.
+func RewritezHandler(log *xlog.Log, proxy *proxy.Proxy) rest.HandlerFunc {
+	f := func(w rest.ResponseWriter, r *rest.Request) {
+		rewritezHandler(log, proxy, w, r)
+	}
+	return f
+}
+
+func rewritezHandler(log *xlog.Log, proxy *proxy.Proxy, w rest.ResponseWriter, r *rest.Request) {
+	w.WriteJson(&rewriteParams{Rules: proxy.Plugins().PlugRewrite().Rules()})
+}
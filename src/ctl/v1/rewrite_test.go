@@ -0,0 +1,102 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package v1
+
+import (
+	"testing"
+
+	"plugins/rewrite"
+	"proxy"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestCtlV1RadonRewrite(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	_, proxy, cleanup := proxy.MockProxy(log)
+	defer cleanup()
+
+	{
+		// server
+		api := rest.NewApi()
+		router, _ := rest.MakeRouter(
+			rest.Put("/v1/radon/rewrite", RewriteHandler(log, proxy)),
+			rest.Get("/v1/radon/rewrite", RewritezHandler(log, proxy)),
+		)
+		api.SetApp(router)
+		handler := api.MakeHandler()
+
+		// Set.
+		{
+			p := &rewriteParams{
+				Rules: []*rewrite.Rule{
+					{Name: "limit-select", Statement: "select", Limit: 1000},
+					{Name: "redirect-t1", Database: "db1", Table: "t1", Redirect: "t1_ro"},
+				},
+			}
+			recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("PUT", "http://localhost/v1/radon/rewrite", p))
+			recorded.CodeIs(200)
+			want := `{"rules":[{"name":"limit-select","statement":"SELECT","limit":1000},{"name":"redirect-t1","database":"db1","table":"t1","redirect":"t1_ro"}]}`
+			assert.Equal(t, want, recorded.Recorder.Body.String())
+		}
+
+		// Get.
+		{
+			recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("GET", "http://localhost/v1/radon/rewrite", nil))
+			recorded.CodeIs(200)
+			assert.Equal(t, 2, len(proxy.Plugins().PlugRewrite().Rules()))
+		}
+
+		// Clear.
+		{
+			p := &rewriteParams{}
+			recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("PUT", "http://localhost/v1/radon/rewrite", p))
+			recorded.CodeIs(200)
+			assert.Equal(t, `{"rules":[]}`, recorded.Recorder.Body.String())
+		}
+	}
+}
+
+func TestCtlV1RadonRewriteError(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	_, proxy, cleanup := proxy.MockProxy(log)
+	defer cleanup()
+
+	{
+		// server
+		api := rest.NewApi()
+		router, _ := rest.MakeRouter(
+			rest.Put("/v1/radon/rewrite", RewriteHandler(log, proxy)),
+		)
+		api.SetApp(router)
+		handler := api.MakeHandler()
+
+		// 405.
+		{
+			recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/radon/rewrite", nil))
+			recorded.CodeIs(405)
+		}
+
+		// 500.
+		{
+			recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("PUT", "http://localhost/v1/radon/rewrite", nil))
+			recorded.CodeIs(500)
+		}
+
+		// 503.
+		{
+			p := &rewriteParams{Rules: []*rewrite.Rule{{Name: "r1"}}}
+			recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("PUT", "http://localhost/v1/radon/rewrite", p))
+			recorded.CodeIs(503)
+		}
+	}
+}
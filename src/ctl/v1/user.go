@@ -9,15 +9,16 @@
 package v1
 
 import (
-	"fmt"
 	"math/rand"
 	"net/http"
+	"strings"
 
+	"plugins/authentication"
+	"plugins/privilege"
 	"proxy"
 
 	"github.com/ant0ine/go-json-rest/rest"
 	"github.com/xelabs/go-mysqlstack/xlog"
-	"strings"
 )
 
 type userParams struct {
@@ -36,7 +37,7 @@ func CreateUserHandler(log *xlog.Log, proxy *proxy.Proxy) rest.HandlerFunc {
 }
 
 func createUserHandler(log *xlog.Log, proxy *proxy.Proxy, w rest.ResponseWriter, r *rest.Request) {
-	spanner := proxy.Spanner()
+	catalog := proxy.Plugins().PlugPrivilege().Catalog()
 	p := userParams{}
 	err := r.DecodeJsonPayload(&p)
 	if err != nil {
@@ -55,19 +56,41 @@ func createUserHandler(log *xlog.Log, proxy *proxy.Proxy, w rest.ResponseWriter,
 		p.Databases = "*"
 	}
 
-	log.Warning("api.v1.create.user[from:%v].[%v]", r.RemoteAddr, p)
+	log.Warning("api.v1.create.user[from:%v].[%v]", r.RemoteAddr, p.User)
 	databases := strings.TrimSuffix(p.Databases, ",")
 	dbList := strings.Split(databases, ",")
 	priv := p.Privilege
 	if priv == "" {
 		priv = "ALL"
 	}
+	var privs []string
+	for _, name := range strings.Split(priv, ",") {
+		normalized, err := privilege.NormalizePrivilege(name)
+		if err != nil {
+			log.Error("api.v1.create.user[%+v].error:%+v", p.User, err)
+			rest.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		privs = append(privs, normalized)
+	}
+
+	// The user is created in the radon-native catalog, the existing user is identified by the new password.
+	authString := authentication.NativePasswordHash(p.Password)
+	if _, ok := catalog.AuthenticationString(p.User); ok {
+		err = catalog.AlterUser(p.User, authString)
+	} else {
+		err = catalog.CreateUser(p.User, authString, false)
+	}
+	if err != nil {
+		log.Error("api.v1.create.user[%+v].error:%+v", p.User, err)
+		rest.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
 	for _, db := range dbList {
-		query := fmt.Sprintf("GRANT %s ON %s.* TO '%s'@'%%' IDENTIFIED BY '%s'", priv, db, p.User, p.Password)
-		if _, err := spanner.ExecuteScatter(query); err != nil {
-			log.Error("api.v1.create.user[%+v].error:%+v", p, err)
+		if err := catalog.Grant(privs, strings.TrimSpace(db), p.User); err != nil {
+			log.Error("api.v1.create.user[%+v].grant.on[%s].error:%+v", p.User, db, err)
 			rest.Error(w, err.Error(), http.StatusServiceUnavailable)
-			break
+			return
 		}
 	}
 }
@@ -81,7 +104,7 @@ func AlterUserHandler(log *xlog.Log, proxy *proxy.Proxy) rest.HandlerFunc {
 }
 
 func alterUserHandler(log *xlog.Log, proxy *proxy.Proxy, w rest.ResponseWriter, r *rest.Request) {
-	spanner := proxy.Spanner()
+	catalog := proxy.Plugins().PlugPrivilege().Catalog()
 	p := userParams{}
 	err := r.DecodeJsonPayload(&p)
 	if err != nil {
@@ -89,11 +112,10 @@ func alterUserHandler(log *xlog.Log, proxy *proxy.Proxy, w rest.ResponseWriter,
 		rest.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	log.Warning("api.v1.alter.user[from:%v].[%v]", r.RemoteAddr, p)
+	log.Warning("api.v1.alter.user[from:%v].[%v]", r.RemoteAddr, p.User)
 
-	query := fmt.Sprintf("ALTER USER '%s'@'%%' IDENTIFIED BY '%s'", p.User, p.Password)
-	if _, err := spanner.ExecuteScatter(query); err != nil {
-		log.Error("api.v1.alter.user[%+v].error:%+v", p, err)
+	if err := catalog.AlterUser(p.User, authentication.NativePasswordHash(p.Password)); err != nil {
+		log.Error("api.v1.alter.user[%+v].error:%+v", p.User, err)
 		rest.Error(w, err.Error(), http.StatusServiceUnavailable)
 	}
 }
@@ -107,7 +129,7 @@ func DropUserHandler(log *xlog.Log, proxy *proxy.Proxy) rest.HandlerFunc {
 }
 
 func dropUserHandler(log *xlog.Log, proxy *proxy.Proxy, w rest.ResponseWriter, r *rest.Request) {
-	spanner := proxy.Spanner()
+	catalog := proxy.Plugins().PlugPrivilege().Catalog()
 	p := userParams{}
 	err := r.DecodeJsonPayload(&p)
 	if err != nil {
@@ -117,8 +139,7 @@ func dropUserHandler(log *xlog.Log, proxy *proxy.Proxy, w rest.ResponseWriter, r
 	}
 	log.Warning("api.v1.drop.user[from:%v].[%v]", r.RemoteAddr, p)
 
-	query := fmt.Sprintf("DROP USER '%s'@'%%'", p.User)
-	if _, err := spanner.ExecuteScatter(query); err != nil {
+	if err := catalog.DropUser(p.User, false); err != nil {
 		log.Error("api.v1.drop.user[%+v].error:%+v", p.User, err)
 		rest.Error(w, err.Error(), http.StatusServiceUnavailable)
 	}
@@ -152,12 +173,69 @@ func userzHandler(log *xlog.Log, proxy *proxy.Proxy, w rest.ResponseWriter, r *r
 		Host      string
 		SuperPriv string
 	}
-	var Users = make([]UserInfo, len(qr.Rows))
-	for i, row := range qr.Rows {
-		Users[i].User = string(row[0].Raw())
-		Users[i].Host = string(row[1].Raw())
-		Users[i].SuperPriv = string(row[2].Raw())
+	// The radon-native users first.
+	var Users = make([]UserInfo, 0, len(qr.Rows))
+	privilegePlug := proxy.Plugins().PlugPrivilege()
+	for _, user := range privilegePlug.Catalog().Users() {
+		superPriv := "N"
+		if privilegePlug.IsSuperPriv(user) {
+			superPriv = "Y"
+		}
+		Users = append(Users, UserInfo{User: user, Host: "%", SuperPriv: superPriv})
+	}
+	for _, row := range qr.Rows {
+		Users = append(Users, UserInfo{User: string(row[0].Raw()), Host: string(row[1].Raw()), SuperPriv: string(row[2].Raw())})
 	}
 
 	w.WriteJson(Users)
 }
+
+type userLimitsParams struct {
+	User                string `json:"user"`
+	MaxUserConnections  *int   `json:"max-user-connections"`
+	MaxQueriesPerSecond *int   `json:"max-queries-per-second"`
+	MaxQueriesPerHour   *int   `json:"max-queries-per-hour"`
+	MaxScatterQueries   *int   `json:"max-scatter-queries"`
+	MaxRows             *int   `json:"max-rows"`
+}
+
+// UserLimitsHandler impl.
+func UserLimitsHandler(log *xlog.Log, proxy *proxy.Proxy) rest.HandlerFunc {
+	f := func(w rest.ResponseWriter, r *rest.Request) {
+		userLimitsHandler(log, proxy, w, r)
+	}
+	return f
+}
+
+func userLimitsHandler(log *xlog.Log, proxy *proxy.Proxy, w rest.ResponseWriter, r *rest.Request) {
+	catalog := proxy.Plugins().PlugPrivilege().Catalog()
+	p := userLimitsParams{}
+	err := r.DecodeJsonPayload(&p)
+	if err != nil {
+		log.Error("api.v1.user.limits.error:%+v", err)
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Warning("api.v1.user.limits[from:%v].[%+v]", r.RemoteAddr, p.User)
+
+	// Only the limits in the request are changed.
+	options := make(map[string]int)
+	for option, value := range map[string]*int{
+		privilege.MaxUserConnections:  p.MaxUserConnections,
+		privilege.MaxQueriesPerSecond: p.MaxQueriesPerSecond,
+		privilege.MaxQueriesPerHour:   p.MaxQueriesPerHour,
+		privilege.MaxScatterQueries:   p.MaxScatterQueries,
+		privilege.MaxRows:             p.MaxRows,
+	} {
+		if value != nil {
+			options[option] = *value
+		}
+	}
+	if err := catalog.AlterResources(p.User, options); err != nil {
+		log.Error("api.v1.user.limits[%+v].error:%+v", p.User, err)
+		rest.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	res, _ := catalog.Resources(p.User)
+	w.WriteJson(res)
+}
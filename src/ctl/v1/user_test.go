@@ -12,6 +12,7 @@ import (
 	"errors"
 	"testing"
 
+	"plugins/privilege"
 	"proxy"
 
 	"github.com/ant0ine/go-json-rest/rest"
@@ -24,13 +25,9 @@ import (
 
 func TestCtlV1CreateUser(t *testing.T) {
 	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
-	fakedbs, proxy, cleanup := proxy.MockProxy(log)
+	_, proxy, cleanup := proxy.MockProxy(log)
 	defer cleanup()
-
-	// fakedbs.
-	{
-		fakedbs.AddQuery("GRANT select,insert,update,delete ON *.* TO 'mock'@'%' IDENTIFIED BY 'pwd'", &sqltypes.Result{})
-	}
+	catalog := proxy.Plugins().PlugPrivilege().Catalog()
 
 	// server
 	api := rest.NewApi()
@@ -49,21 +46,21 @@ func TestCtlV1CreateUser(t *testing.T) {
 		}
 		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/user/add", p))
 		recorded.CodeIs(200)
+
+		authString, ok := catalog.AuthenticationString("mock")
+		assert.True(t, ok)
+		assert.Equal(t, "*975B2CD4FF9AE554FE8AD33168FBFC326D2021DD", authString)
+		grants, err := catalog.ShowGrants("mock")
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"GRANT SELECT, INSERT, UPDATE, DELETE ON *.* TO 'mock'@'%'"}, grants)
 	}
 }
 
 func TestCtlV1CreateUserDatabases(t *testing.T) {
 	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
-	fakedbs, proxy, cleanup := proxy.MockProxy(log)
+	_, proxy, cleanup := proxy.MockProxy(log)
 	defer cleanup()
-
-	// fakedbs.
-	{
-		fakedbs.AddQuery("GRANT ALL ON *.* TO 'mock'@'%' IDENTIFIED BY 'pwd'", &sqltypes.Result{})
-		fakedbs.AddQuery("GRANT ALL ON a.* TO 'mock'@'%' IDENTIFIED BY 'pwd'", &sqltypes.Result{})
-		fakedbs.AddQuery("GRANT ALL ON b.* TO 'mock'@'%' IDENTIFIED BY 'pwd'", &sqltypes.Result{})
-		fakedbs.AddQueryError("GRANT ALL ON c.* TO 'mock'@'%' IDENTIFIED BY 'pwd'", errors.New("mock.create.user.error"))
-	}
+	catalog := proxy.Plugins().PlugPrivilege().Catalog()
 
 	// server
 	api := rest.NewApi()
@@ -75,57 +72,45 @@ func TestCtlV1CreateUserDatabases(t *testing.T) {
 
 	{
 		p := &userParams{
-			Databases: "",
+			Databases: "a,b,",
 			User:      "mock",
 			Password:  "pwd",
 		}
 		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/user/add", p))
 		recorded.CodeIs(200)
-	}
 
-	{
-		p := &userParams{
-			Databases: "a,b,",
-			User:      "mock",
-			Password:  "pwd",
+		grants, err := catalog.ShowGrants("mock")
+		assert.Nil(t, err)
+		want := []string{
+			"GRANT USAGE ON *.* TO 'mock'@'%'",
+			"GRANT ALL PRIVILEGES ON `a`.* TO 'mock'@'%'",
+			"GRANT ALL PRIVILEGES ON `b`.* TO 'mock'@'%'",
 		}
-		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/user/add", p))
-		recorded.CodeIs(200)
+		assert.Equal(t, want, grants)
 	}
 
+	// Add the global privileges to the existing user.
 	{
 		p := &userParams{
-			Databases: "*,a,b",
+			Databases: "",
 			User:      "mock",
 			Password:  "pwd",
 		}
 		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/user/add", p))
 		recorded.CodeIs(200)
-	}
 
-	{
-		p := &userParams{
-			Databases: "*,a,b,c",
-			User:      "mock",
-			Password:  "pwd",
-		}
-		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/user/add", p))
-		recorded.CodeIs(503)
+		grants, err := catalog.ShowGrants("mock")
+		assert.Nil(t, err)
+		assert.Equal(t, "GRANT ALL PRIVILEGES ON *.* TO 'mock'@'%'", grants[0])
+		assert.True(t, proxy.Plugins().PlugPrivilege().IsSuperPriv("mock"))
 	}
 }
 
 func TestCtlV1CreateUserError(t *testing.T) {
 	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
-	fakedbs, proxy, cleanup := proxy.MockProxy(log)
+	_, proxy, cleanup := proxy.MockProxy(log)
 	defer cleanup()
-
-	// fakedbs.
-	{
-		fakedbs.AddQueryError("GRANT ALL ON *.* TO 'mock'@'%' IDENTIFIED BY 'pwd'", errors.New("mock.create.user.error"))
-		fakedbs.AddQueryError("GRANT privErr ON *.* TO 'mock'@'%' IDENTIFIED BY 'pwd'", errors.New("mock.create.user.error"))
-		fakedbs.AddQueryError("GRANT ON *.* TO 'mock'@'%' IDENTIFIED BY 'pwd'", errors.New("mock.create.user.error"))
-		fakedbs.AddQueryError("GRANT selec,insert,update ON *.* TO 'mock'@'%' IDENTIFIED BY 'pwd'", errors.New("mock.create.user.error"))
-	}
+	catalog := proxy.Plugins().PlugPrivilege().Catalog()
 
 	// server
 	api := rest.NewApi()
@@ -140,55 +125,37 @@ func TestCtlV1CreateUserError(t *testing.T) {
 		recorded.CodeIs(500)
 	}
 
-	{
-		p := &userParams{
-			Databases: "*",
-			User:      "mock",
-			Password:  "pwd",
-		}
-		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/user/add", p))
-		recorded.CodeIs(503)
-	}
-
-	{
-		p := &userParams{
-			Databases: "*",
-			User:      "mock",
-			Password:  "pwd",
-			Privilege: "select",
-		}
-		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/user/add", p))
-		recorded.CodeIs(503)
-	}
-
-	{
+	privs := []string{"selec,insert,update", " ", "privErr", "select,"}
+	for _, priv := range privs {
 		p := &userParams{
 			Databases: "*,a,b",
 			User:      "mock",
 			Password:  "pwd",
-			Privilege: "selec,insert,update",
+			Privilege: priv,
 		}
 		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/user/add", p))
 		recorded.CodeIs(503)
 	}
 
+	// The global privilege on the database.
 	{
 		p := &userParams{
-			Databases: "*,a,b",
+			Databases: "a",
 			User:      "mock",
 			Password:  "pwd",
-			Privilege: " ",
+			Privilege: "super",
 		}
 		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/user/add", p))
 		recorded.CodeIs(503)
 	}
 
+	// The name is used by the role.
 	{
+		err := catalog.CreateRole("r1", false)
+		assert.Nil(t, err)
 		p := &userParams{
-			Databases: "*,a,b",
-			User:      "mock",
-			Password:  "pwd",
-			Privilege: "privErr",
+			User:     "r1",
+			Password: "pwd",
 		}
 		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/user/add", p))
 		recorded.CodeIs(503)
@@ -221,17 +188,9 @@ func TestCtlV1CreateUserError1(t *testing.T) {
 
 func TestCtlV1CreateUserPriv(t *testing.T) {
 	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
-	fakedbs, proxy, cleanup := proxy.MockProxy(log)
+	_, proxy, cleanup := proxy.MockProxy(log)
 	defer cleanup()
-
-	// fakedbs.
-	{
-		fakedbs.AddQuery("GRANT ALL ON *.* TO 'mock'@'%' IDENTIFIED BY 'pwd'", &sqltypes.Result{})
-		fakedbs.AddQuery("GRANT select,insert,update,delete ON *.* TO 'mock'@'%' IDENTIFIED BY 'pwd'", &sqltypes.Result{})
-		fakedbs.AddQuery("GRANT select ON *.* TO 'mock'@'%' IDENTIFIED BY 'pwd'", &sqltypes.Result{})
-		fakedbs.AddQuery("GRANT insert,update,delete ON *.* TO 'mock'@'%' IDENTIFIED BY 'pwd'", &sqltypes.Result{})
-		fakedbs.AddQuery("GRANT delete ON *.* TO 'mock'@'%' IDENTIFIED BY 'pwd'", &sqltypes.Result{})
-	}
+	catalog := proxy.Plugins().PlugPrivilege().Catalog()
 
 	// server
 	api := rest.NewApi()
@@ -241,60 +200,37 @@ func TestCtlV1CreateUserPriv(t *testing.T) {
 	api.SetApp(router)
 	handler := api.MakeHandler()
 
-	{
-		p := &userParams{
-			Databases: "*",
-			User:      "mock",
-			Password:  "pwd",
-			Privilege: "select,insert,update,delete",
-		}
-		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/user/add", p))
-		recorded.CodeIs(200)
+	tests := []struct {
+		privilege string
+		want      string
+	}{
+		{"select", "GRANT SELECT ON `db1`.* TO 'mock'@'%'"},
+		{"delete", "GRANT SELECT, DELETE ON `db1`.* TO 'mock'@'%'"},
+		{"insert, update", "GRANT SELECT, INSERT, UPDATE, DELETE ON `db1`.* TO 'mock'@'%'"},
 	}
-
-	{
+	for _, test1 := range tests {
 		p := &userParams{
-			Databases: "*",
+			Databases: "db1",
 			User:      "mock",
 			Password:  "pwd",
-			Privilege: "select",
+			Privilege: test1.privilege,
 		}
 		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/user/add", p))
 		recorded.CodeIs(200)
-	}
-
-	{
-		p := &userParams{
-			Databases: "*",
-			User:      "mock",
-			Password:  "pwd",
-			Privilege: "delete",
-		}
-		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/user/add", p))
-		recorded.CodeIs(200)
-	}
 
-	{
-		p := &userParams{
-			Databases: "*",
-			User:      "mock",
-			Password:  "pwd",
-			Privilege: "insert,update,delete",
-		}
-		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/user/add", p))
-		recorded.CodeIs(200)
+		grants, err := catalog.ShowGrants("mock")
+		assert.Nil(t, err)
+		assert.Equal(t, test1.want, grants[1])
 	}
 }
 
 func TestCtlV1AlterUser(t *testing.T) {
 	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
-	fakedbs, proxy, cleanup := proxy.MockProxy(log)
+	_, proxy, cleanup := proxy.MockProxy(log)
 	defer cleanup()
-
-	// fakedbs.
-	{
-		fakedbs.AddQuery("ALTER USER 'mock'@'%' IDENTIFIED BY 'pwd'", &sqltypes.Result{})
-	}
+	catalog := proxy.Plugins().PlugPrivilege().Catalog()
+	err := catalog.CreateUser("mock", "", false)
+	assert.Nil(t, err)
 
 	// server
 	api := rest.NewApi()
@@ -311,19 +247,17 @@ func TestCtlV1AlterUser(t *testing.T) {
 		}
 		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/user/update", p))
 		recorded.CodeIs(200)
+
+		authString, _ := catalog.AuthenticationString("mock")
+		assert.Equal(t, "*975B2CD4FF9AE554FE8AD33168FBFC326D2021DD", authString)
 	}
 }
 
 func TestCtlV1AlterUserError(t *testing.T) {
 	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
-	fakedbs, proxy, cleanup := proxy.MockProxy(log)
+	_, proxy, cleanup := proxy.MockProxy(log)
 	defer cleanup()
 
-	// fakedbs.
-	{
-		fakedbs.AddQueryError("ALTER USER 'mock'@'%' IDENTIFIED BY 'pwd'", errors.New("mock.alter.user.error"))
-	}
-
 	// server
 	api := rest.NewApi()
 	router, _ := rest.MakeRouter(
@@ -338,7 +272,7 @@ func TestCtlV1AlterUserError(t *testing.T) {
 		recorded.CodeIs(500)
 	}
 
-	// 503.
+	// 503, the user not exists.
 	{
 		p := &userParams{
 			User:     "mock",
@@ -349,15 +283,98 @@ func TestCtlV1AlterUserError(t *testing.T) {
 	}
 }
 
-func TestCtlV1DropUser(t *testing.T) {
+func TestCtlV1UserLimits(t *testing.T) {
 	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
-	fakedbs, proxy, cleanup := proxy.MockProxy(log)
+	_, proxy, cleanup := proxy.MockProxy(log)
 	defer cleanup()
+	catalog := proxy.Plugins().PlugPrivilege().Catalog()
+	err := catalog.CreateUser("u1", "", false)
+	assert.Nil(t, err)
 
-	// fakedbs.
+	// server
+	api := rest.NewApi()
+	router, _ := rest.MakeRouter(
+		rest.Post("/v1/user/limits", UserLimitsHandler(log, proxy)),
+	)
+	api.SetApp(router)
+	handler := api.MakeHandler()
+
+	{
+		body := map[string]interface{}{
+			"user":                 "u1",
+			"max-user-connections": 2,
+			"max-queries-per-hour": 100,
+			"max-rows":             1000,
+		}
+		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/user/limits", body))
+		recorded.CodeIs(200)
+		recorded.BodyIs(`{"max-user-connections":2,"max-queries-per-hour":100,"max-rows":1000}`)
+	}
+
+	// Only the max-rows is changed.
+	{
+		body := map[string]interface{}{
+			"user":     "u1",
+			"max-rows": 0,
+		}
+		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/user/limits", body))
+		recorded.CodeIs(200)
+
+		res, _ := catalog.Resources("u1")
+		assert.Equal(t, privilege.Resources{MaxUserConnections: 2, MaxQueriesPerHour: 100}, res)
+	}
+}
+
+func TestCtlV1UserLimitsError(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	_, proxy, cleanup := proxy.MockProxy(log)
+	defer cleanup()
+
+	// server
+	api := rest.NewApi()
+	router, _ := rest.MakeRouter(
+		rest.Post("/v1/user/limits", UserLimitsHandler(log, proxy)),
+	)
+	api.SetApp(router)
+	handler := api.MakeHandler()
+
+	// 500.
+	{
+		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/user/limits", nil))
+		recorded.CodeIs(500)
+	}
+
+	// 503, the user not exists.
+	{
+		body := map[string]interface{}{
+			"user":     "u1",
+			"max-rows": 1,
+		}
+		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/user/limits", body))
+		recorded.CodeIs(503)
+	}
+
+	// 503, the illegal value.
 	{
-		fakedbs.AddQueryPattern("DROP USER 'mock'@'%'", &sqltypes.Result{})
+		catalog := proxy.Plugins().PlugPrivilege().Catalog()
+		err := catalog.CreateUser("u1", "", false)
+		assert.Nil(t, err)
+		body := map[string]interface{}{
+			"user":     "u1",
+			"max-rows": -1,
+		}
+		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/user/limits", body))
+		recorded.CodeIs(503)
 	}
+}
+
+func TestCtlV1DropUser(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	_, proxy, cleanup := proxy.MockProxy(log)
+	defer cleanup()
+	catalog := proxy.Plugins().PlugPrivilege().Catalog()
+	err := catalog.CreateUser("mock", "", false)
+	assert.Nil(t, err)
 
 	// server
 	api := rest.NewApi()
@@ -373,19 +390,17 @@ func TestCtlV1DropUser(t *testing.T) {
 		}
 		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/user/remove", p))
 		recorded.CodeIs(200)
+
+		_, ok := catalog.AuthenticationString("mock")
+		assert.False(t, ok)
 	}
 }
 
 func TestCtlV1DropError(t *testing.T) {
 	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
-	fakedbs, proxy, cleanup := proxy.MockProxy(log)
+	_, proxy, cleanup := proxy.MockProxy(log)
 	defer cleanup()
 
-	// fakedbs.
-	{
-		fakedbs.AddQueryErrorPattern("DROP .*", errors.New("mock.drop.user.error"))
-	}
-
 	// server
 	api := rest.NewApi()
 	router, _ := rest.MakeRouter(
@@ -394,7 +409,13 @@ func TestCtlV1DropError(t *testing.T) {
 	api.SetApp(router)
 	handler := api.MakeHandler()
 
-	// 503.
+	// 500.
+	{
+		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/user/remove", nil))
+		recorded.CodeIs(500)
+	}
+
+	// 503, the user not exists.
 	{
 		p := &userParams{
 			User: "mock",
@@ -460,6 +481,21 @@ func TestCtlV1Userz(t *testing.T) {
 		log.Debug(got)
 		assert.Equal(t, want, got)
 	}
+
+	// The radon-native users first.
+	{
+		catalog := proxy.Plugins().PlugPrivilege().Catalog()
+		catalog.CreateUser("u1", "", false)
+		catalog.Grant([]string{"ALL PRIVILEGES"}, "*", "u1")
+		catalog.CreateUser("u2", "", false)
+
+		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("GET", "http://localhost/v1/user/userz", nil))
+		recorded.CodeIs(200)
+
+		want := "[{\"User\":\"u1\",\"Host\":\"%\",\"SuperPriv\":\"Y\"},{\"User\":\"u2\",\"Host\":\"%\",\"SuperPriv\":\"N\"},{\"User\":\"test1\",\"Host\":\"%\",\"SuperPriv\":\"Y\"},{\"User\":\"test2\",\"Host\":\"%\",\"SuperPriv\":\"N\"}]"
+		got := recorded.Recorder.Body.String()
+		assert.Equal(t, want, got)
+	}
 }
 
 func TestCtlV1UserzError(t *testing.T) {
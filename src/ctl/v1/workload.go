@@ -0,0 +1,63 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package v1
+
+import (
+	"net/http"
+
+	"config"
+	"proxy"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+// WorkloadHandler impl.
+func WorkloadHandler(log *xlog.Log, proxy *proxy.Proxy) rest.HandlerFunc {
+	f := func(w rest.ResponseWriter, r *rest.Request) {
+		workloadHandler(log, proxy, w, r)
+	}
+	return f
+}
+
+func workloadHandler(log *xlog.Log, proxy *proxy.Proxy, w rest.ResponseWriter, r *rest.Request) {
+	p := config.DefaultWorkloadConfig()
+	err := r.DecodeJsonPayload(p)
+	if err != nil {
+		log.Error("api.v1.radon.workload.error:%+v", err)
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Warning("api.v1.radon.workload[from:%v].body:%+v", r.RemoteAddr, p)
+	if err := proxy.SetWorkload(p); err != nil {
+		log.Error("api.v1.radon.workload.set.error:%+v", err)
+		rest.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	// write to file.
+	if err := proxy.FlushConfig(); err != nil {
+		log.Error("api.v1.radon.workload.flush.config.error:%+v", err)
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// WorkloadzHandler impl.
+func WorkloadzHandler(log *xlog.Log, proxy *proxy.Proxy) rest.HandlerFunc {
+	f := func(w rest.ResponseWriter, r *rest.Request) {
+		workloadzHandler(log, proxy, w, r)
+	}
+	return f
+}
+
+func workloadzHandler(log *xlog.Log, proxy *proxy.Proxy, w rest.ResponseWriter, r *rest.Request) {
+	w.WriteJson(proxy.Workload().Status())
+}
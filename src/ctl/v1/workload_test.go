@@ -0,0 +1,99 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package v1
+
+import (
+	"testing"
+
+	"config"
+	"proxy"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestCtlV1RadonWorkload(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	_, proxy, cleanup := proxy.MockProxy(log)
+	defer cleanup()
+
+	{
+		// server
+		api := rest.NewApi()
+		router, _ := rest.MakeRouter(
+			rest.Put("/v1/radon/workload", WorkloadHandler(log, proxy)),
+			rest.Get("/v1/debug/workloadz", WorkloadzHandler(log, proxy)),
+		)
+		api.SetApp(router)
+		handler := api.MakeHandler()
+
+		// 200.
+		{
+			p := &config.WorkloadConfig{
+				MaxConcurrency: 64,
+				Groups: []*config.WorkloadGroupConfig{
+					{Name: "report", Users: []string{"reporter"}, Slots: 4, Weight: 1, QueueSize: 100},
+					{Name: "oltp", Databases: []string{"db1"}, Slots: 32, Weight: 8},
+				},
+			}
+			recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("PUT", "http://localhost/v1/radon/workload", p))
+			recorded.CodeIs(200)
+			assert.Equal(t, p, proxy.Config().Workload)
+		}
+
+		// workloadz.
+		{
+			recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("GET", "http://localhost/v1/debug/workloadz", nil))
+			recorded.CodeIs(200)
+			want := `[{"name":"default","slots":0,"weight":1,"running":0,"queue-depth":0},{"name":"oltp","slots":32,"weight":8,"running":0,"queue-depth":0},{"name":"report","slots":4,"weight":1,"running":0,"queue-depth":0}]`
+			assert.Equal(t, want, recorded.Recorder.Body.String())
+		}
+	}
+}
+
+func TestCtlV1RadonWorkloadError(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	_, proxy, cleanup := proxy.MockProxy(log)
+	defer cleanup()
+
+	{
+		// server
+		api := rest.NewApi()
+		router, _ := rest.MakeRouter(
+			rest.Put("/v1/radon/workload", WorkloadHandler(log, proxy)),
+		)
+		api.SetApp(router)
+		handler := api.MakeHandler()
+
+		// 405.
+		{
+			recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/radon/workload", nil))
+			recorded.CodeIs(405)
+		}
+
+		// 500.
+		{
+			recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("PUT", "http://localhost/v1/radon/workload", nil))
+			recorded.CodeIs(500)
+		}
+
+		// 503.
+		{
+			p := &config.WorkloadConfig{
+				Groups: []*config.WorkloadGroupConfig{
+					{Name: "g1", Fingerprints: []string{"select ("}},
+				},
+			}
+			recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("PUT", "http://localhost/v1/radon/workload", p))
+			recorded.CodeIs(503)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package v1
+
+import (
+	"net/http"
+
+	"proxy"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+// XaInDoubtHandler impl.
+func XaInDoubtHandler(log *xlog.Log, proxy *proxy.Proxy) rest.HandlerFunc {
+	f := func(w rest.ResponseWriter, r *rest.Request) {
+		xaInDoubtHandler(log, proxy, w, r)
+	}
+	return f
+}
+
+func xaInDoubtHandler(log *xlog.Log, proxy *proxy.Proxy, w rest.ResponseWriter, r *rest.Request) {
+	type indoubt struct {
+		Xaid     string   `json:"xaid"`
+		Backends []string `json:"backends"`
+		Decision string   `json:"decision"`
+		Reason   string   `json:"reason"`
+	}
+
+	rsp := make([]indoubt, 0, 8)
+	scatter := proxy.Scatter()
+	for _, row := range scatter.XaInDoubts() {
+		rsp = append(rsp, indoubt{
+			Xaid:     row.Xaid,
+			Backends: row.Backends,
+			Decision: row.Decision,
+			Reason:   row.Reason,
+		})
+	}
+	w.WriteJson(rsp)
+}
+
+type xaResolveParams struct {
+	Xaid   string `json:"xaid"`
+	Action string `json:"action"`
+}
+
+// XaResolveHandler impl.
+func XaResolveHandler(log *xlog.Log, proxy *proxy.Proxy) rest.HandlerFunc {
+	f := func(w rest.ResponseWriter, r *rest.Request) {
+		xaResolveHandler(log, proxy, w, r)
+	}
+	return f
+}
+
+func xaResolveHandler(log *xlog.Log, proxy *proxy.Proxy, w rest.ResponseWriter, r *rest.Request) {
+	p := xaResolveParams{}
+	err := r.DecodeJsonPayload(&p)
+	if err != nil {
+		log.Error("api.v1.radon.xa.resolve.error:%+v", err)
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Warning("api.v1.radon.xa.resolve[from:%v].body:%+v", r.RemoteAddr, p)
+	if p.Xaid == "" {
+		rest.Error(w, "api.v1.radon.xa.resolve.xaid.is.empty", http.StatusInternalServerError)
+		return
+	}
+
+	scatter := proxy.Scatter()
+	if err := scatter.XaResolve(p.Xaid, p.Action); err != nil {
+		log.Error("api.v1.radon.xa.resolve[%+v].error:%+v", p, err)
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// XaRecoverHandler impl.
+func XaRecoverHandler(log *xlog.Log, proxy *proxy.Proxy) rest.HandlerFunc {
+	f := func(w rest.ResponseWriter, r *rest.Request) {
+		xaRecoverHandler(log, proxy, w, r)
+	}
+	return f
+}
+
+func xaRecoverHandler(log *xlog.Log, proxy *proxy.Proxy, w rest.ResponseWriter, r *rest.Request) {
+	log.Warning("api.v1.radon.xa.recover[from:%v]", r.RemoteAddr)
+	scatter := proxy.Scatter()
+	if err := scatter.XaRecover(); err != nil {
+		log.Error("api.v1.radon.xa.recover.error:%+v", err)
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	xaInDoubtHandler(log, proxy, w, r)
+}
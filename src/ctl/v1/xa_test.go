@@ -0,0 +1,133 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package v1
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"proxy"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/stretchr/testify/assert"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestCtlV1XaInDoubt(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := proxy.MockProxy(log)
+	defer cleanup()
+
+	xaid := fmt.Sprintf("RXID-%v-1", time.Now().Format("20060102150405"))
+	fakedbs.AddQuery("XA RECOVER", &sqltypes.Result{
+		RowsAffected: 1,
+		Fields: []*querypb.Field{
+			{Name: "formatID", Type: querypb.Type_INT64},
+			{Name: "gtrid_length", Type: querypb.Type_INT64},
+			{Name: "bqual_length", Type: querypb.Type_INT64},
+			{Name: "data", Type: querypb.Type_VARCHAR},
+		},
+		Rows: [][]sqltypes.Value{
+			{
+				sqltypes.MakeTrusted(querypb.Type_INT64, []byte("1")),
+				sqltypes.MakeTrusted(querypb.Type_INT64, []byte("22")),
+				sqltypes.MakeTrusted(querypb.Type_INT64, []byte("0")),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(xaid)),
+			},
+		},
+	})
+	fakedbs.AddQueryPattern("XA ROLLBACK .*", &sqltypes.Result{})
+
+	// server
+	api := rest.NewApi()
+	router, _ := rest.MakeRouter(
+		rest.Get("/v1/radon/xa/indoubt", XaInDoubtHandler(log, proxy)),
+		rest.Post("/v1/radon/xa/recover", XaRecoverHandler(log, proxy)),
+		rest.Post("/v1/radon/xa/resolve", XaResolveHandler(log, proxy)),
+	)
+	api.SetApp(router)
+	handler := api.MakeHandler()
+
+	// indoubt: empty.
+	{
+		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("GET", "http://localhost/v1/radon/xa/indoubt", nil))
+		recorded.CodeIs(200)
+		recorded.BodyIs("[]")
+	}
+
+	// recover: the xaid without node id is in-doubt.
+	{
+		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/radon/xa/recover", nil))
+		recorded.CodeIs(200)
+		got := recorded.Recorder.Body.String()
+		assert.True(t, strings.Contains(got, xaid))
+		assert.True(t, strings.Contains(got, `"reason":"unknown.owner"`))
+
+		recorded = test.RunRequest(t, handler, test.MakeSimpleRequest("GET", "http://localhost/v1/radon/xa/indoubt", nil))
+		recorded.CodeIs(200)
+		assert.True(t, strings.Contains(recorded.Recorder.Body.String(), xaid))
+	}
+
+	// resolve.
+	{
+		p := &xaResolveParams{Xaid: xaid, Action: "rollback"}
+		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/radon/xa/resolve", p))
+		recorded.CodeIs(200)
+
+		recorded = test.RunRequest(t, handler, test.MakeSimpleRequest("GET", "http://localhost/v1/radon/xa/indoubt", nil))
+		recorded.CodeIs(200)
+		recorded.BodyIs("[]")
+	}
+}
+
+func TestCtlV1XaResolveError(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	_, proxy, cleanup := proxy.MockProxy(log)
+	defer cleanup()
+
+	// server
+	api := rest.NewApi()
+	router, _ := rest.MakeRouter(
+		rest.Post("/v1/radon/xa/resolve", XaResolveHandler(log, proxy)),
+	)
+	api.SetApp(router)
+	handler := api.MakeHandler()
+
+	// 405.
+	{
+		p := &xaResolveParams{}
+		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("PUT", "http://localhost/v1/radon/xa/resolve", p))
+		recorded.CodeIs(405)
+	}
+
+	// 500.
+	{
+		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/radon/xa/resolve", nil))
+		recorded.CodeIs(500)
+	}
+
+	// 500: xaid empty.
+	{
+		p := &xaResolveParams{Action: "commit"}
+		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/radon/xa/resolve", p))
+		recorded.CodeIs(500)
+	}
+
+	// 500: xaid not in-doubt.
+	{
+		p := &xaResolveParams{Xaid: "RXID-20180903103145-1", Action: "commit"}
+		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/radon/xa/resolve", p))
+		recorded.CodeIs(500)
+	}
+}
@@ -8,7 +8,16 @@
 
 package engine
 
-import "sync"
+import (
+	"sync"
+	"time"
+
+	"executor/engine/operator"
+	"planner/builder"
+	"xcontext"
+
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
 
 // calcPool used to the merge join calc.
 type calcPool struct {
@@ -44,3 +53,22 @@ func (p *calcPool) done() {
 func (p *calcPool) wait() {
 	p.wg.Wait()
 }
+
+// subContext returns the result context which collects the execution statistics into profile.
+func subContext(profile *xcontext.Profile) *xcontext.ResultContext {
+	ctx := xcontext.NewResultContext()
+	ctx.Profile = profile
+	return ctx
+}
+
+// execSubPlan executes the children plan of the node, and records one loop of the node into profile.
+func execSubPlan(log *xlog.Log, node builder.PlanNode, ctx *xcontext.ResultContext, profile *xcontext.Profile, start time.Time) error {
+	sub := subContext(profile)
+	sub.Results = ctx.Results
+	if err := operator.ExecSubPlan(log, node, sub); err != nil {
+		return err
+	}
+	ctx.Results = sub.Results
+	profile.Finish(start, ctx.Results)
+	return nil
+}
@@ -9,8 +9,9 @@
 package engine
 
 import (
+	"time"
+
 	"backend"
-	"executor/engine/operator"
 	"planner/builder"
 	"xcontext"
 
@@ -31,6 +32,9 @@ type JoinEngine struct {
 	node        *builder.JoinNode
 	left, right PlanEngine
 	txn         backend.Transaction
+
+	// profile used for EXPLAIN ANALYZE.
+	profile *xcontext.Profile
 }
 
 // NewJoinEngine creates the new join executor.
@@ -47,15 +51,17 @@ func (j *JoinEngine) Execute(ctx *xcontext.ResultContext) error {
 	var eg errgroup.Group
 	var err error
 
+	start := time.Now()
+	profile := j.profileOf(ctx.Profile)
 	maxrow := j.txn.MaxJoinRows()
 	if j.node.Strategy == builder.NestLoop {
 		joinVars := make(map[string]*querypb.BindVariable)
-		if err := j.execBindVars(ctx, joinVars, true); err != nil {
+		if err := j.nestLoop(ctx, profile, joinVars, true); err != nil {
 			return err
 		}
 	} else {
-		lctx := xcontext.NewResultContext()
-		rctx := xcontext.NewResultContext()
+		lctx := subContext(j.left.profileOf(profile))
+		rctx := subContext(j.right.profileOf(profile))
 
 		eg.Go(func() error {
 			return j.left.Execute(lctx)
@@ -70,6 +76,7 @@ func (j *JoinEngine) Execute(ctx *xcontext.ResultContext) error {
 		ctx.Results = &sqltypes.Result{}
 		ctx.Results.Fields = joinFields(lctx.Results.Fields, rctx.Results.Fields, j.node.Cols)
 		if len(lctx.Results.Rows) == 0 {
+			profile.Finish(start, ctx.Results)
 			return nil
 		}
 
@@ -88,15 +95,25 @@ func (j *JoinEngine) Execute(ctx *xcontext.ResultContext) error {
 			return err
 		}
 	}
-
-	return operator.ExecSubPlan(j.log, j.node, ctx)
+	return execSubPlan(j.log, j.node, ctx, profile, start)
 }
 
 // execBindVars used to execute querys with bindvars.
 func (j *JoinEngine) execBindVars(ctx *xcontext.ResultContext, bindVars map[string]*querypb.BindVariable, wantfields bool) error {
+	start := time.Now()
+	profile := j.profileOf(ctx.Profile)
+	if err := j.nestLoop(ctx, profile, bindVars, wantfields); err != nil {
+		return err
+	}
+	profile.Finish(start, ctx.Results)
+	return nil
+}
+
+// nestLoop used to execute the nest loop join with bindvars.
+func (j *JoinEngine) nestLoop(ctx *xcontext.ResultContext, profile *xcontext.Profile, bindVars map[string]*querypb.BindVariable, wantfields bool) error {
 	var err error
-	lctx := xcontext.NewResultContext()
-	rctx := xcontext.NewResultContext()
+	lctx := subContext(j.left.profileOf(profile))
+	rctx := subContext(j.right.profileOf(profile))
 	maxrow := j.txn.MaxJoinRows()
 	ctx.Results = &sqltypes.Result{}
 
@@ -166,8 +183,9 @@ func (j *JoinEngine) execBindVars(ctx *xcontext.ResultContext, bindVars map[stri
 // getFields fetches the field info.
 func (j *JoinEngine) getFields(ctx *xcontext.ResultContext, bindVars map[string]*querypb.BindVariable) error {
 	var err error
-	lctx := xcontext.NewResultContext()
-	rctx := xcontext.NewResultContext()
+	profile := j.profileOf(ctx.Profile)
+	lctx := subContext(j.left.profileOf(profile))
+	rctx := subContext(j.right.profileOf(profile))
 
 	joinVars := make(map[string]*querypb.BindVariable)
 	if err = j.left.getFields(lctx, bindVars); err != nil {
@@ -186,6 +204,23 @@ func (j *JoinEngine) getFields(ctx *xcontext.ResultContext, bindVars map[string]
 	return nil
 }
 
+// profileOf returns the profile of the engine, created under the parent on the first call.
+func (j *JoinEngine) profileOf(parent *xcontext.Profile) *xcontext.Profile {
+	if j.profile == nil {
+		var strategy string
+		switch j.node.Strategy {
+		case builder.Cartesian:
+			strategy = "Cartesian"
+		case builder.SortMerge:
+			strategy = "SortMerge"
+		case builder.NestLoop:
+			strategy = "NestLoop"
+		}
+		j.profile = parent.NewChild("Join(" + strategy + ")")
+	}
+	return j.profile
+}
+
 // joinFields used to join two fields.
 func joinFields(lfields, rfields []*querypb.Field, cols []int) []*querypb.Field {
 	fields := make([]*querypb.Field, len(cols))
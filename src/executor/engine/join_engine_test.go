@@ -10,6 +10,7 @@ package engine
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"backend"
@@ -352,12 +353,21 @@ func TestJoinEngine(t *testing.T) {
 		planEngine := BuildEngine(log, plan.Root, txn)
 		{
 			ctx := xcontext.NewResultContext()
+			ctx.Profile = xcontext.NewProfile("Select")
 			err := planEngine.Execute(ctx)
 			assert.Nil(t, err)
 			want := results[i]
 			got := fmt.Sprintf("%v", ctx.Results.Rows)
 			assert.Equal(t, want, got)
 			log.Debug("%+v", ctx.Results)
+
+			// Profile.
+			assert.Equal(t, 1, len(ctx.Profile.Children))
+			join := ctx.Profile.Children[0]
+			assert.True(t, strings.HasPrefix(join.Name, "Join("))
+			assert.Equal(t, 2, len(join.Children))
+			assert.Equal(t, 1, join.Loops)
+			assert.Equal(t, uint64(len(ctx.Results.Rows)), join.Rows)
 		}
 	}
 }
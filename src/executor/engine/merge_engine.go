@@ -9,8 +9,9 @@
 package engine
 
 import (
+	"time"
+
 	"backend"
-	"executor/engine/operator"
 	"planner/builder"
 	"xcontext"
 
@@ -28,6 +29,9 @@ type MergeEngine struct {
 	log  *xlog.Log
 	node *builder.MergeNode
 	txn  backend.Transaction
+
+	// profile used for EXPLAIN ANALYZE.
+	profile *xcontext.Profile
 }
 
 // NewMergeEngine creates the new merge executor.
@@ -43,9 +47,12 @@ func NewMergeEngine(log *xlog.Log, node *builder.MergeNode, txn backend.Transact
 func (m *MergeEngine) Execute(ctx *xcontext.ResultContext) error {
 	var err error
 
+	start := time.Now()
+	profile := m.profileOf(ctx.Profile)
 	reqCtx := xcontext.NewRequestContext()
 	reqCtx.Mode = m.node.ReqMode
 	reqCtx.TxnMode = xcontext.TxnRead
+	reqCtx.Profile = profile
 	if reqCtx.Mode == xcontext.ReqNormal {
 		reqCtx.Querys = m.node.Querys
 	} else {
@@ -57,7 +64,7 @@ func (m *MergeEngine) Execute(ctx *xcontext.ResultContext) error {
 	if ctx.Results, err = m.txn.Execute(reqCtx); err != nil {
 		return err
 	}
-	return operator.ExecSubPlan(m.log, m.node, ctx)
+	return execSubPlan(m.log, m.node, ctx, profile, start)
 }
 
 // execBindVars used to execute querys with bindvas.
@@ -65,6 +72,8 @@ func (m *MergeEngine) execBindVars(ctx *xcontext.ResultContext, bindVars map[str
 	var query string
 	var err error
 
+	start := time.Now()
+	profile := m.profileOf(ctx.Profile)
 	querys := m.node.Querys
 	for i, p := range m.node.ParsedQuerys {
 		query, err = p.GenerateQuery(bindVars, nil)
@@ -78,11 +87,12 @@ func (m *MergeEngine) execBindVars(ctx *xcontext.ResultContext, bindVars map[str
 	reqCtx.Mode = xcontext.ReqNormal
 	reqCtx.TxnMode = xcontext.TxnRead
 	reqCtx.Querys = querys
+	reqCtx.Profile = profile
 
 	if ctx.Results, err = m.txn.Execute(reqCtx); err != nil {
 		return err
 	}
-	return operator.ExecSubPlan(m.log, m.node, ctx)
+	return execSubPlan(m.log, m.node, ctx, profile, start)
 }
 
 // getFields fetches the field info.
@@ -99,9 +109,18 @@ func (m *MergeEngine) getFields(ctx *xcontext.ResultContext, bindVars map[string
 	reqCtx.Mode = xcontext.ReqNormal
 	reqCtx.TxnMode = xcontext.TxnRead
 	reqCtx.Querys = []xcontext.QueryTuple{query}
+	reqCtx.Profile = m.profileOf(ctx.Profile)
 
 	if ctx.Results, err = m.txn.Execute(reqCtx); err != nil {
 		return err
 	}
 	return nil
 }
+
+// profileOf returns the profile of the engine, created under the parent on the first call.
+func (m *MergeEngine) profileOf(parent *xcontext.Profile) *xcontext.Profile {
+	if m.profile == nil {
+		m.profile = parent.NewChild("Merge")
+	}
+	return m.profile
+}
@@ -117,12 +117,22 @@ func TestMergeEngine(t *testing.T) {
 		planEngine := BuildEngine(log, plan.Root, txn)
 		{
 			ctx := xcontext.NewResultContext()
+			ctx.Profile = xcontext.NewProfile("Select")
 			err := planEngine.Execute(ctx)
 			assert.Nil(t, err)
 			want := results[i]
 			got := fmt.Sprintf("%v", ctx.Results.Rows)
 			assert.Equal(t, want, got)
 			log.Debug("%+v", ctx.Results)
+
+			// Profile.
+			assert.Equal(t, 1, len(ctx.Profile.Children))
+			merge := ctx.Profile.Children[0]
+			assert.Equal(t, "Merge", merge.Name)
+			assert.Equal(t, 1, merge.Loops)
+			assert.Equal(t, uint64(5), merge.Rows)
+			assert.Equal(t, 4, len(merge.Querys))
+			assert.Equal(t, "OrderBy", merge.Operators[0].Name)
 		}
 	}
 }
@@ -0,0 +1,70 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package operator
+
+import (
+	"planner/builder"
+	"xcontext"
+
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+var (
+	_ Operator = &DecryptOperator{}
+)
+
+// DecryptOperator represents column decryption operator.
+type DecryptOperator struct {
+	log       *xlog.Log
+	decrypter builder.Decrypter
+	plan      *builder.MaskPlan
+
+	// The encrypted flags of the last fields, kept for the stream chunks without fields.
+	encrypted []bool
+}
+
+// NewDecryptOperator creates the new decrypt operator, only the fields resolved by the plan are decrypted.
+func NewDecryptOperator(log *xlog.Log, decrypter builder.Decrypter, plan *builder.MaskPlan) *DecryptOperator {
+	return &DecryptOperator{
+		log:       log,
+		decrypter: decrypter,
+		plan:      plan,
+	}
+}
+
+// Execute used to execute the operator.
+func (operator *DecryptOperator) Execute(ctx *xcontext.ResultContext) error {
+	rs := ctx.Results
+	if rs == nil {
+		return nil
+	}
+
+	if len(rs.Fields) > 0 {
+		operator.encrypted = make([]bool, len(rs.Fields))
+		for i, field := range rs.Fields {
+			operator.encrypted[i] = operator.plan.MaskOf(i, field.Name) != ""
+		}
+	}
+
+	for _, row := range rs.Rows {
+		for i, encrypted := range operator.encrypted {
+			if !encrypted || i >= len(row) || row[i].IsNull() {
+				continue
+			}
+			plain, err := operator.decrypter.Decrypt(row[i].Raw())
+			if err != nil {
+				operator.log.Error("operator.decrypt.error:%v", err)
+				return err
+			}
+			row[i] = sqltypes.MakeTrusted(row[i].Type(), plain)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package operator
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"planner/builder"
+	"xcontext"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+type mockDecrypter struct{}
+
+// Columns returns the phone of the table A as the encrypted column.
+func (d *mockDecrypter) Columns(database string, table string) map[string]string {
+	if table == "A" {
+		return map[string]string{"phone": "DETERMINISTIC"}
+	}
+	return nil
+}
+
+// Decrypt removes the 'enc:' prefix of the value.
+func (d *mockDecrypter) Decrypt(value []byte) ([]byte, error) {
+	if string(value) == "enc:" {
+		return nil, errors.New("mock.decrypt.error")
+	}
+	return []byte(strings.TrimPrefix(string(value), "enc:")), nil
+}
+
+func TestDecryptOperator(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	node, err := sqlparser.Parse("select id, phone, name from A")
+	assert.Nil(t, err)
+	plan := builder.NewDecryptPlan(log, "sbtest", node.(sqlparser.SelectStatement), &mockDecrypter{})
+	err = plan.Build()
+	assert.Nil(t, err)
+	operator := NewDecryptOperator(log, &mockDecrypter{}, plan)
+
+	// Nil result.
+	err = operator.Execute(xcontext.NewResultContext())
+	assert.Nil(t, err)
+
+	// The plaintext column with the prefix is not decrypted.
+	ctx := xcontext.NewResultContext()
+	ctx.Results = &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "id", Type: querypb.Type_INT32},
+			{Name: "phone", Type: querypb.Type_VARCHAR},
+			{Name: "name", Type: querypb.Type_VARCHAR},
+		},
+		Rows: [][]sqltypes.Value{
+			{
+				sqltypes.MakeTrusted(querypb.Type_INT32, []byte("1")),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("enc:13812345678")),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("enc:")),
+			},
+			{
+				sqltypes.MakeTrusted(querypb.Type_INT32, []byte("2")),
+				sqltypes.NULL,
+				sqltypes.NULL,
+			},
+		},
+	}
+	err = operator.Execute(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "[[1 13812345678 enc:] [2  ]]", fmt.Sprintf("%v", ctx.Results.Rows))
+
+	// The stream chunk without the fields.
+	ctx.Results = &sqltypes.Result{
+		Rows: [][]sqltypes.Value{
+			{
+				sqltypes.MakeTrusted(querypb.Type_INT32, []byte("3")),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("enc:13900000000")),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("x")),
+			},
+		},
+	}
+	err = operator.Execute(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "[[3 13900000000 x]]", fmt.Sprintf("%v", ctx.Results.Rows))
+
+	// Error.
+	ctx.Results.Rows[0][1] = sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("enc:"))
+	err = operator.Execute(ctx)
+	assert.Equal(t, "mock.decrypt.error", err.Error())
+}
@@ -98,11 +98,18 @@ func TestLimitOperator(t *testing.T) {
 			ctx := xcontext.NewResultContext()
 			ctx.Results = &sqltypes.Result{}
 			ctx.Results = r1
+			ctx.Profile = xcontext.NewProfile("Merge")
 			err = ExecSubPlan(log, plan.Root, ctx)
 			assert.Nil(t, err)
 			want := results[i]
 			got := fmt.Sprintf("%v", ctx.Results.Rows)
 			assert.Equal(t, want, got)
+
+			ops := ctx.Profile.Operators
+			assert.Equal(t, 2, len(ops))
+			assert.Equal(t, "OrderBy", ops[0].Name)
+			assert.Equal(t, "Limit", ops[1].Name)
+			assert.Equal(t, uint64(1), ops[1].Rows)
 			log.Debug("%+v", ctx.Results)
 		}
 	}
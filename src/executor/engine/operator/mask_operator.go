@@ -0,0 +1,105 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package operator
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"planner/builder"
+	"xcontext"
+
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+var (
+	_ Operator = &MaskOperator{}
+)
+
+// MaskOperator represents column masking operator.
+type MaskOperator struct {
+	log  *xlog.Log
+	plan builder.ChildPlan
+
+	// The mask types of the last fields, kept for the stream chunks without fields.
+	typs []string
+}
+
+// NewMaskOperator creates the new mask operator.
+func NewMaskOperator(log *xlog.Log, plan builder.ChildPlan) *MaskOperator {
+	return &MaskOperator{
+		log:  log,
+		plan: plan,
+	}
+}
+
+// Execute used to execute the operator.
+// For the stream fetch, the chunks after the fields are masked by the mask types of the fields.
+func (operator *MaskOperator) Execute(ctx *xcontext.ResultContext) error {
+	rs := ctx.Results
+	if rs == nil {
+		return nil
+	}
+	plan := operator.plan.(*builder.MaskPlan)
+
+	if len(rs.Fields) > 0 {
+		operator.typs = make([]string, len(rs.Fields))
+		for i, field := range rs.Fields {
+			typ := plan.MaskOf(i, field.Name)
+			operator.typs[i] = typ
+			// The masked value is a string except NULL.
+			if typ != "" && typ != builder.MaskNull {
+				rs.Fields[i] = &querypb.Field{
+					Name:     field.Name,
+					Type:     querypb.Type_VARCHAR,
+					Table:    field.Table,
+					OrgTable: field.OrgTable,
+					Database: field.Database,
+					OrgName:  field.OrgName,
+					Charset:  33,
+				}
+			}
+		}
+	}
+
+	for _, row := range rs.Rows {
+		for i, typ := range operator.typs {
+			if typ != "" && i < len(row) {
+				row[i] = maskValue(typ, row[i])
+			}
+		}
+	}
+	return nil
+}
+
+// maskValue returns the masked value, NULL is kept.
+func maskValue(typ string, v sqltypes.Value) sqltypes.Value {
+	if v.IsNull() {
+		return v
+	}
+	switch typ {
+	case builder.MaskFull:
+		return sqltypes.NewVarChar("****")
+	case builder.MaskPartial:
+		runes := []rune(v.String())
+		keep := 0
+		if len(runes) > 4 {
+			keep = 4
+		}
+		return sqltypes.NewVarChar(strings.Repeat("*", len(runes)-keep) + string(runes[len(runes)-keep:]))
+	case builder.MaskHash:
+		sum := sha256.Sum256(v.Raw())
+		return sqltypes.NewVarChar(hex.EncodeToString(sum[:]))
+	default:
+		return sqltypes.NULL
+	}
+}
@@ -0,0 +1,150 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package operator
+
+import (
+	"fmt"
+	"testing"
+
+	"planner"
+	"router"
+	"xcontext"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+type mockMasker map[string]string
+
+func (m mockMasker) Columns(database string, table string) map[string]string {
+	if database == "sbtest" && table == "A" {
+		return m
+	}
+	return nil
+}
+
+func TestMaskOperator(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableAConfig())
+	assert.Nil(t, err)
+
+	masker := mockMasker{"a": "FULL", "b": "PARTIAL", "c": "HASH", "d": "NULL"}
+	querys := []string{
+		"select id, a, b, c, d from A",
+		"select * from A",
+	}
+	fields := []*querypb.Field{
+		{Name: "id", Type: querypb.Type_INT32},
+		{Name: "a", Type: querypb.Type_VARCHAR},
+		{Name: "b", Type: querypb.Type_INT64},
+		{Name: "c", Type: querypb.Type_VARCHAR},
+		{Name: "d", Type: querypb.Type_VARCHAR},
+	}
+	want := "[[1 **** ****5678 2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824 ] [2  ***  ]]"
+
+	for _, query := range querys {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+
+		plan := planner.NewSelectPlan(log, database, query, node.(*sqlparser.Select), route)
+		plan.SetMasker(masker)
+		err = plan.Build()
+		assert.Nil(t, err)
+		assert.NotNil(t, plan.Mask)
+
+		ctx := xcontext.NewResultContext()
+		ctx.Results = &sqltypes.Result{
+			Fields: append([]*querypb.Field{}, fields...),
+			Rows: [][]sqltypes.Value{
+				{
+					sqltypes.MakeTrusted(querypb.Type_INT32, []byte("1")),
+					sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("secret")),
+					sqltypes.MakeTrusted(querypb.Type_INT64, []byte("12345678")),
+					sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("hello")),
+					sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("x")),
+				},
+				{
+					sqltypes.MakeTrusted(querypb.Type_INT32, []byte("2")),
+					sqltypes.NULL,
+					sqltypes.MakeTrusted(querypb.Type_INT64, []byte("123")),
+					sqltypes.NULL,
+					sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("y")),
+				},
+			},
+		}
+		operator := NewMaskOperator(log, plan.Mask)
+		err = operator.Execute(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, want, fmt.Sprintf("%v", ctx.Results.Rows), query)
+		assert.Equal(t, querypb.Type_INT32, ctx.Results.Fields[0].Type)
+		assert.Equal(t, querypb.Type_VARCHAR, ctx.Results.Fields[2].Type)
+		assert.Equal(t, querypb.Type_VARCHAR, ctx.Results.Fields[4].Type)
+	}
+}
+
+func TestMaskOperatorStream(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableAConfig())
+	assert.Nil(t, err)
+
+	query := "select id, a from A"
+	node, err := sqlparser.Parse(query)
+	assert.Nil(t, err)
+	plan := planner.NewSelectPlan(log, database, query, node.(*sqlparser.Select), route)
+	plan.SetMasker(mockMasker{"a": "FULL"})
+	err = plan.Build()
+	assert.Nil(t, err)
+
+	operator := NewMaskOperator(log, plan.Mask)
+	// Nil result.
+	err = operator.Execute(xcontext.NewResultContext())
+	assert.Nil(t, err)
+
+	// The fields chunk.
+	ctx := xcontext.NewResultContext()
+	ctx.Results = &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "id", Type: querypb.Type_INT32},
+			{Name: "a", Type: querypb.Type_INT32},
+		},
+	}
+	err = operator.Execute(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, querypb.Type_VARCHAR, ctx.Results.Fields[1].Type)
+
+	// The rows chunk.
+	ctx.Results = &sqltypes.Result{
+		Rows: [][]sqltypes.Value{
+			{
+				sqltypes.MakeTrusted(querypb.Type_INT32, []byte("1")),
+				sqltypes.MakeTrusted(querypb.Type_INT32, []byte("100")),
+			},
+		},
+	}
+	err = operator.Execute(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "[[1 ****]]", fmt.Sprintf("%v", ctx.Results.Rows))
+}
@@ -9,6 +9,8 @@
 package operator
 
 import (
+	"time"
+
 	"planner/builder"
 	"xcontext"
 
@@ -25,23 +27,23 @@ func ExecSubPlan(log *xlog.Log, node builder.PlanNode, ctx *xcontext.ResultConte
 	subPlanTree := node.Children()
 	if subPlanTree != nil {
 		for _, subPlan := range subPlanTree {
+			var name string
+			var op Operator
 			switch subPlan.Type() {
 			case builder.ChildTypeAggregate:
-				aggrOperator := NewAggregateOperator(log, subPlan)
-				if err := aggrOperator.Execute(ctx); err != nil {
-					return err
-				}
+				name, op = "Aggregate", NewAggregateOperator(log, subPlan)
 			case builder.ChildTypeOrderby:
-				orderByOperator := NewOrderByOperator(log, subPlan)
-				if err := orderByOperator.Execute(ctx); err != nil {
-					return err
-				}
+				name, op = "OrderBy", NewOrderByOperator(log, subPlan)
 			case builder.ChildTypeLimit:
-				limitOperator := NewLimitOperator(log, subPlan)
-				if err := limitOperator.Execute(ctx); err != nil {
-					return err
-				}
+				name, op = "Limit", NewLimitOperator(log, subPlan)
+			default:
+				continue
+			}
+			start := time.Now()
+			if err := op.Execute(ctx); err != nil {
+				return err
 			}
+			ctx.Profile.AddOperator(name, start, ctx.Results)
 		}
 	}
 	return nil
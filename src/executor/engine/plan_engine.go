@@ -22,6 +22,7 @@ type PlanEngine interface {
 	Execute(ctx *xcontext.ResultContext) error
 	execBindVars(ctx *xcontext.ResultContext, bindVars map[string]*querypb.BindVariable, wantfields bool) error
 	getFields(ctx *xcontext.ResultContext, bindVars map[string]*querypb.BindVariable) error
+	profileOf(parent *xcontext.Profile) *xcontext.Profile
 }
 
 // BuildEngine used to build the executor tree.
@@ -10,9 +10,10 @@ package engine
 
 import (
 	"errors"
+	"strings"
+	"time"
 
 	"backend"
-	"executor/engine/operator"
 	"planner/builder"
 	"xcontext"
 
@@ -33,6 +34,9 @@ type UnionEngine struct {
 	node        *builder.UnionNode
 	left, right PlanEngine
 	txn         backend.Transaction
+
+	// profile used for EXPLAIN ANALYZE.
+	profile *xcontext.Profile
 }
 
 // NewUnionEngine creates the new union executor.
@@ -48,8 +52,10 @@ func NewUnionEngine(log *xlog.Log, node *builder.UnionNode, txn backend.Transact
 func (u *UnionEngine) Execute(ctx *xcontext.ResultContext) error {
 	var eg errgroup.Group
 
-	lctx := xcontext.NewResultContext()
-	rctx := xcontext.NewResultContext()
+	start := time.Now()
+	profile := u.profileOf(ctx.Profile)
+	lctx := subContext(u.left.profileOf(profile))
+	rctx := subContext(u.right.profileOf(profile))
 
 	eg.Go(func() error {
 		return u.left.Execute(lctx)
@@ -68,6 +74,7 @@ func (u *UnionEngine) Execute(ctx *xcontext.ResultContext) error {
 	ctx.Results.Fields = lctx.Results.Fields
 	lctx.Results.AppendResult(rctx.Results)
 	if len(lctx.Results.Rows) == 0 {
+		profile.Finish(start, ctx.Results)
 		return nil
 	}
 	if u.node.Typ == "union distinct" || u.node.Typ == "union" {
@@ -89,7 +96,7 @@ func (u *UnionEngine) Execute(ctx *xcontext.ResultContext) error {
 		ctx.Results.Rows = lctx.Results.Rows
 		ctx.Results.RowsAffected = lctx.Results.RowsAffected
 	}
-	return operator.ExecSubPlan(u.log, u.node, ctx)
+	return execSubPlan(u.log, u.node, ctx, profile, start)
 }
 
 // execBindVars used to execute querys with bindvas.
@@ -101,3 +108,11 @@ func (u *UnionEngine) execBindVars(ctx *xcontext.ResultContext, bindVars map[str
 func (u *UnionEngine) getFields(ctx *xcontext.ResultContext, bindVars map[string]*querypb.BindVariable) error {
 	return errors.New("UnionEngine.getFields: unreachable")
 }
+
+// profileOf returns the profile of the engine, created under the parent on the first call.
+func (u *UnionEngine) profileOf(parent *xcontext.Profile) *xcontext.Profile {
+	if u.profile == nil {
+		u.profile = parent.NewChild("Union(" + strings.ToUpper(u.node.Typ) + ")")
+	}
+	return u.profile
+}
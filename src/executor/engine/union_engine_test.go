@@ -10,6 +10,7 @@ package engine
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"backend"
@@ -130,12 +131,20 @@ func TestUnionEngine(t *testing.T) {
 		planEngine := BuildEngine(log, plan.Root, txn)
 		{
 			ctx := xcontext.NewResultContext()
+			ctx.Profile = xcontext.NewProfile("Union")
 			err := planEngine.Execute(ctx)
 			assert.Nil(t, err)
 			want := results[i]
 			got := fmt.Sprintf("%v", ctx.Results.Rows)
 			assert.Equal(t, want, got)
 			log.Debug("%+v", ctx.Results)
+
+			// Profile.
+			assert.Equal(t, 1, len(ctx.Profile.Children))
+			union := ctx.Profile.Children[0]
+			assert.True(t, strings.HasPrefix(union.Name, "Union("))
+			assert.Equal(t, 2, len(union.Children))
+			assert.Equal(t, uint64(len(ctx.Results.Rows)), union.Rows)
 		}
 	}
 }
@@ -12,6 +12,7 @@ import (
 	"backend"
 	"planner"
 	"xcontext"
+	"xtrace"
 
 	"github.com/pkg/errors"
 	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
@@ -29,6 +30,8 @@ type Tree struct {
 	children []Executor
 	txn      backend.Transaction
 	planTree *planner.PlanTree
+	profile  *xcontext.Profile
+	span     *xtrace.Span
 }
 
 // NewTree creates the new execute tree.
@@ -41,6 +44,18 @@ func NewTree(log *xlog.Log, planTree *planner.PlanTree, txn backend.Transaction)
 	}
 }
 
+// WithProfile sets the profile which collects the execution statistics, used by EXPLAIN ANALYZE.
+func (et *Tree) WithProfile(profile *xcontext.Profile) *Tree {
+	et.profile = profile
+	return et
+}
+
+// WithSpan used to trace the execution as the child of the span, the backend querys are traced as the children of the execution.
+func (et *Tree) WithSpan(span *xtrace.Span) *Tree {
+	et.span = span
+	return et
+}
+
 // Add adds a executor to the tree
 func (et *Tree) Add(executor Executor) error {
 	et.children = append(et.children, executor)
@@ -48,7 +63,17 @@ func (et *Tree) Add(executor Executor) error {
 }
 
 // Execute executes all Executor.Execute
-func (et *Tree) Execute() (*sqltypes.Result, error) {
+func (et *Tree) Execute() (_ *sqltypes.Result, err error) {
+	if et.span != nil {
+		span := et.span.StartChild("executor.Execute")
+		et.txn.SetSpan(span)
+		defer func() {
+			et.txn.SetSpan(et.span)
+			span.SetError(err)
+			span.End()
+		}()
+	}
+
 	// build tree
 	for _, plan := range et.planTree.Plans() {
 		switch plan.Type() {
@@ -94,6 +119,7 @@ func (et *Tree) Execute() (*sqltypes.Result, error) {
 
 	// execute all
 	rsCtx := xcontext.NewResultContext()
+	rsCtx.Profile = et.profile
 	for _, executor := range et.children {
 		if err := executor.Execute(rsCtx); err != nil {
 			return nil, err
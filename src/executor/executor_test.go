@@ -15,6 +15,7 @@ import (
 	"fakedb"
 	"planner"
 	"router"
+	"xcontext"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/xelabs/go-mysqlstack/sqlparser"
@@ -135,3 +136,43 @@ func TestExecutor1(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, fakedb.Result3, qr)
 }
+
+func TestExecutorWithProfile(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+
+	// Create scatter and query handler.
+	scatter, fakedbs, cleanup := backend.MockScatter(log, 10)
+	defer cleanup()
+	fakedbs.AddQueryPattern("select.*", fakedb.Result3)
+
+	database := "sbtest"
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableAConfig())
+	assert.Nil(t, err)
+
+	planTree := planner.NewPlanTree()
+	query := "select * from A where id=2"
+	node, err := sqlparser.Parse(query)
+	assert.Nil(t, err)
+	plan := planner.NewSelectPlan(log, database, query, node.(*sqlparser.Select), route)
+	err = plan.Build()
+	assert.Nil(t, err)
+	err = planTree.Add(plan)
+	assert.Nil(t, err)
+
+	txn, err := scatter.CreateTransaction()
+	assert.Nil(t, err)
+	defer txn.Finish()
+
+	profile := xcontext.NewProfile("Select")
+	_, err = NewTree(log, planTree, txn).WithProfile(profile).Execute()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(profile.Children))
+	assert.Equal(t, "Merge", profile.Children[0].Name)
+	assert.Equal(t, 1, len(profile.Children[0].Querys))
+	assert.Equal(t, uint64(len(fakedb.Result3.Rows)), profile.Children[0].Querys[0].Rows)
+}
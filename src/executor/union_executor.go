@@ -9,8 +9,11 @@
 package executor
 
 import (
+	"time"
+
 	"backend"
 	"executor/engine"
+	"executor/engine/operator"
 	"planner"
 	"xcontext"
 
@@ -45,5 +48,21 @@ func (executor *UnionExecutor) Execute(ctx *xcontext.ResultContext) error {
 	if err := planEngine.Execute(ctx); err != nil {
 		return err
 	}
+	// Decrypts the columns on the final result, before masking.
+	if plan.Decrypt != nil {
+		start := time.Now()
+		if err := operator.NewDecryptOperator(log, plan.Decrypter, plan.Decrypt).Execute(ctx); err != nil {
+			return err
+		}
+		ctx.Profile.AddOperator("Decrypt", start, ctx.Results)
+	}
+	// Masks the columns on the final result.
+	if plan.Mask != nil {
+		start := time.Now()
+		if err := operator.NewMaskOperator(log, plan.Mask).Execute(ctx); err != nil {
+			return err
+		}
+		ctx.Profile.AddOperator("Mask", start, ctx.Results)
+	}
 	return nil
 }
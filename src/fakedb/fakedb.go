@@ -9,6 +9,7 @@
 package fakedb
 
 import (
+	"crypto/tls"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -164,6 +165,15 @@ func (db *DB) Close() {
 	}
 }
 
+// SetTLSConfig used to enable the TLS on all the listeners.
+func (db *DB) SetTLSConfig(config *tls.Config) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for _, l := range db.listeners {
+		l.SetTLSConfig(config)
+	}
+}
+
 // AddQuery used to add a query and the return result expected.
 func (db *DB) AddQuery(query string, result *sqltypes.Result) {
 	db.handler.AddQuery(query, result)
@@ -70,11 +70,143 @@ var (
 		[]string{"command", "result"},
 	)
 
+	slowLogDroppedCounter = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "slow_log_dropped_total",
+			Help: "Counter of slow log events dropped by the full queue.",
+		},
+	)
+
 	peerNum = prometheus.NewGauge(
 		prometheus.GaugeOpts{
 			Name: "peer_number",
 			Help: "radon peer Number",
 		})
+
+	workloadQueueDepth = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "workload_queue_depth",
+			Help: "queries waiting in the workload group queue",
+		},
+		[]string{"group"},
+	)
+
+	workloadRunning = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "workload_running",
+			Help: "queries running in the workload group",
+		},
+		[]string{"group"},
+	)
+
+	workloadWaitSeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "workload_wait_seconds",
+			Help:    "wait time of the queries in the workload group queue",
+			Buckets: []float64{0.001, 0.01, 0.1, 0.5, 1, 5, 10, 60},
+		},
+		[]string{"group"},
+	)
+
+	workloadRejectedCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "workload_rejected_total",
+			Help: "Counter of queries rejected by the workload group.",
+		},
+		[]string{"group", "reason"},
+	)
+
+	queryDurationSeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "query_duration_seconds",
+			Help:    "latency of the queries",
+			Buckets: []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10, 60},
+		},
+		[]string{"command", "result"},
+	)
+
+	queryFanout = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "query_fanout",
+			Help:    "number of the backend querys of the queries",
+			Buckets: []float64{1, 2, 4, 8, 16, 32, 64, 128},
+		},
+		[]string{"command"},
+	)
+
+	backendQueryDurationSeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "backend_query_duration_seconds",
+			Help:    "latency of the querys on the backend",
+			Buckets: []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10, 60},
+		},
+		[]string{"backend", "result"},
+	)
+
+	backendPoolConnections = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "backend_pool_connections",
+			Help: "connections of the backend pool by state(idle or in_use)",
+		},
+		[]string{"backend", "address", "state"},
+	)
+
+	backendPoolWaitSeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "backend_pool_wait_seconds",
+			Help:    "wait time to get a connection from the backend pool, including the ping and dial",
+			Buckets: []float64{0.0001, 0.001, 0.01, 0.1, 0.5, 1, 5},
+		},
+		[]string{"backend", "address"},
+	)
+
+	xaDurationSeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "xa_duration_seconds",
+			Help:    "latency of the XA phases",
+			Buckets: []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10},
+		},
+		[]string{"phase", "result"},
+	)
+
+	throttleQueueDepth = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "throttle_queue_depth",
+			Help: "queries waiting for the throttle",
+		})
+
+	shiftRunning = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "shift_running",
+			Help: "running shifts of the reshard or rebalance",
+		},
+		[]string{"type"},
+	)
+
+	shiftStartTime = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "shift_start_time_seconds",
+			Help: "start time(unix) of the running shift, deleted when finished",
+		},
+		[]string{"type", "key"},
+	)
+
+	shiftTotalCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "shift_total",
+			Help: "Counter of finished shifts.",
+		},
+		[]string{"type", "result"},
+	)
+
+	shiftDurationSeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "shift_duration_seconds",
+			Help:    "duration of the finished shifts",
+			Buckets: []float64{1, 10, 60, 300, 1800, 3600, 4 * 3600, 24 * 3600},
+		},
+		[]string{"type", "result"},
+	)
 )
 
 func init() {
@@ -84,7 +216,23 @@ func init() {
 	prometheus.MustRegister(backendNum)
 	prometheus.MustRegister(diskUsage)
 	prometheus.MustRegister(slowQueryTotalCounter)
+	prometheus.MustRegister(slowLogDroppedCounter)
 	prometheus.MustRegister(peerNum)
+	prometheus.MustRegister(workloadQueueDepth)
+	prometheus.MustRegister(workloadRunning)
+	prometheus.MustRegister(workloadWaitSeconds)
+	prometheus.MustRegister(workloadRejectedCounter)
+	prometheus.MustRegister(queryDurationSeconds)
+	prometheus.MustRegister(queryFanout)
+	prometheus.MustRegister(backendQueryDurationSeconds)
+	prometheus.MustRegister(backendPoolConnections)
+	prometheus.MustRegister(backendPoolWaitSeconds)
+	prometheus.MustRegister(xaDurationSeconds)
+	prometheus.MustRegister(throttleQueueDepth)
+	prometheus.MustRegister(shiftRunning)
+	prometheus.MustRegister(shiftStartTime)
+	prometheus.MustRegister(shiftTotalCounter)
+	prometheus.MustRegister(shiftDurationSeconds)
 }
 
 // Start monitor
@@ -153,6 +301,11 @@ func SlowQueryTotalCounterInc(command string, result string) {
 	slowQueryTotalCounter.WithLabelValues(command, result).Inc()
 }
 
+// SlowLogDroppedInc add 1
+func SlowLogDroppedInc() {
+	slowLogDroppedCounter.Inc()
+}
+
 //PeerNumInc add 1
 func PeerNumInc() {
 	peerNum.Inc()
@@ -167,3 +320,93 @@ func PeerNumDec() {
 func PeerNumSet(v float64) {
 	peerNum.Set(v)
 }
+
+// WorkloadQueueDepthSet set the queue depth of the workload group.
+func WorkloadQueueDepthSet(group string, v float64) {
+	workloadQueueDepth.WithLabelValues(group).Set(v)
+}
+
+// WorkloadRunningSet set the running queries of the workload group.
+func WorkloadRunningSet(group string, v float64) {
+	workloadRunning.WithLabelValues(group).Set(v)
+}
+
+// WorkloadWaitObserve observe the wait time(in seconds) of the workload group.
+func WorkloadWaitObserve(group string, v float64) {
+	workloadWaitSeconds.WithLabelValues(group).Observe(v)
+}
+
+// WorkloadRejectedInc add 1
+func WorkloadRejectedInc(group string, reason string) {
+	workloadRejectedCounter.WithLabelValues(group, reason).Inc()
+}
+
+// QueryDurationObserve observe the latency(in seconds) of the query.
+func QueryDurationObserve(command string, result string, v float64) {
+	queryDurationSeconds.WithLabelValues(command, result).Observe(v)
+}
+
+// QueryFanoutObserve observe the number of the backend querys of the query.
+func QueryFanoutObserve(command string, v float64) {
+	queryFanout.WithLabelValues(command).Observe(v)
+}
+
+// BackendQueryDurationObserve observe the latency(in seconds) of the query on the backend.
+func BackendQueryDurationObserve(backend string, result string, v float64) {
+	backendQueryDurationSeconds.WithLabelValues(backend, result).Observe(v)
+}
+
+// BackendPoolConnectionsSet set the idle and in-use connections of the backend pool.
+func BackendPoolConnectionsSet(backend string, address string, idle float64, inUse float64) {
+	backendPoolConnections.WithLabelValues(backend, address, "idle").Set(idle)
+	backendPoolConnections.WithLabelValues(backend, address, "in_use").Set(inUse)
+}
+
+// BackendPoolDelete delete the metrics of the closed backend pool.
+func BackendPoolDelete(backend string, address string) {
+	backendPoolConnections.DeleteLabelValues(backend, address, "idle")
+	backendPoolConnections.DeleteLabelValues(backend, address, "in_use")
+	backendPoolWaitSeconds.DeleteLabelValues(backend, address)
+}
+
+// BackendPoolWaitObserve observe the wait time(in seconds) to get a connection from the backend pool.
+func BackendPoolWaitObserve(backend string, address string, v float64) {
+	backendPoolWaitSeconds.WithLabelValues(backend, address).Observe(v)
+}
+
+// XADurationObserve observe the latency(in seconds) of the XA phase.
+func XADurationObserve(phase string, result string, v float64) {
+	xaDurationSeconds.WithLabelValues(phase, result).Observe(v)
+}
+
+// ThrottleQueueDepthInc add 1
+func ThrottleQueueDepthInc() {
+	throttleQueueDepth.Inc()
+}
+
+// ThrottleQueueDepthDec dec 1
+func ThrottleQueueDepthDec() {
+	throttleQueueDepth.Dec()
+}
+
+// ShiftStart add 1 to the running shifts of the type.
+func ShiftStart(typ string) {
+	shiftRunning.WithLabelValues(typ).Inc()
+}
+
+// ShiftFinish dec 1 from the running shifts and observe the duration(in seconds) of the shift.
+func ShiftFinish(typ string, result string, v float64) {
+	shiftRunning.WithLabelValues(typ).Dec()
+	shiftTotalCounter.WithLabelValues(typ, result).Inc()
+	shiftDurationSeconds.WithLabelValues(typ, result).Observe(v)
+}
+
+// ShiftInstanceStart set the start time of the running shift specified by the key.
+func ShiftInstanceStart(typ string, key string) {
+	shiftStartTime.WithLabelValues(typ, key).SetToCurrentTime()
+}
+
+// ShiftInstanceFinish delete the start time of the finished shift specified by the key.
+func ShiftInstanceFinish(typ string, key string) {
+	shiftStartTime.DeleteLabelValues(typ, key)
+}
@@ -13,6 +13,7 @@ import (
 
 	"config"
 
+	"github.com/prometheus/client_golang/prometheus"
 	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 	"github.com/xelabs/go-mysqlstack/xlog"
@@ -190,3 +191,181 @@ func TestMonitorStart(t *testing.T) {
 	conf.Monitor = config.DefaultMonitorConfig()
 	Start(log, &conf)
 }
+
+func TestWorkload(t *testing.T) {
+	group := "report"
+	WorkloadQueueDepthSet(group, 3)
+	WorkloadRunningSet(group, 2)
+	WorkloadWaitObserve(group, 0.5)
+	WorkloadWaitObserve(group, 2)
+	WorkloadRejectedInc(group, "timeout")
+
+	var m dto.Metric
+	g, _ := workloadQueueDepth.GetMetricWithLabelValues(group)
+	err := g.Write(&m)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 3, m.GetGauge().GetValue())
+
+	g, _ = workloadRunning.GetMetricWithLabelValues(group)
+	err = g.Write(&m)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 2, m.GetGauge().GetValue())
+
+	c, _ := workloadRejectedCounter.GetMetricWithLabelValues(group, "timeout")
+	err = c.Write(&m)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, m.GetCounter().GetValue())
+
+	h, _ := workloadWaitSeconds.GetMetricWithLabelValues(group)
+	err = h.(prometheus.Histogram).Write(&m)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 2, m.GetHistogram().GetSampleCount())
+	assert.EqualValues(t, 2.5, m.GetHistogram().GetSampleSum())
+}
+
+func TestQueryDurationAndFanout(t *testing.T) {
+	QueryDurationObserve("Select", "OK", 0.5)
+	QueryDurationObserve("Select", "OK", 1.5)
+	QueryDurationObserve("Select", "Error", 1)
+	QueryFanoutObserve("Select", 1)
+	QueryFanoutObserve("Select", 16)
+
+	var m dto.Metric
+	h, _ := queryDurationSeconds.GetMetricWithLabelValues("Select", "OK")
+	err := h.(prometheus.Histogram).Write(&m)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 2, m.GetHistogram().GetSampleCount())
+	assert.EqualValues(t, 2, m.GetHistogram().GetSampleSum())
+
+	h, _ = queryDurationSeconds.GetMetricWithLabelValues("Select", "Error")
+	err = h.(prometheus.Histogram).Write(&m)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, m.GetHistogram().GetSampleCount())
+
+	h, _ = queryFanout.GetMetricWithLabelValues("Select")
+	err = h.(prometheus.Histogram).Write(&m)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 2, m.GetHistogram().GetSampleCount())
+	assert.EqualValues(t, 17, m.GetHistogram().GetSampleSum())
+	// The bucket le=1.
+	assert.EqualValues(t, 1, m.GetHistogram().GetBucket()[0].GetCumulativeCount())
+}
+
+func TestBackendQueryAndPool(t *testing.T) {
+	backend := "backend1"
+	address := "192.168.0.3:3306"
+	BackendQueryDurationObserve(backend, "OK", 0.25)
+	BackendPoolConnectionsSet(backend, address, 3, 2)
+	BackendPoolWaitObserve(backend, address, 0.01)
+
+	var m dto.Metric
+	h, _ := backendQueryDurationSeconds.GetMetricWithLabelValues(backend, "OK")
+	err := h.(prometheus.Histogram).Write(&m)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, m.GetHistogram().GetSampleCount())
+	assert.EqualValues(t, 0.25, m.GetHistogram().GetSampleSum())
+
+	g, _ := backendPoolConnections.GetMetricWithLabelValues(backend, address, "idle")
+	err = g.Write(&m)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 3, m.GetGauge().GetValue())
+
+	g, _ = backendPoolConnections.GetMetricWithLabelValues(backend, address, "in_use")
+	err = g.Write(&m)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 2, m.GetGauge().GetValue())
+
+	h, _ = backendPoolWaitSeconds.GetMetricWithLabelValues(backend, address)
+	err = h.(prometheus.Histogram).Write(&m)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, m.GetHistogram().GetSampleCount())
+
+	// The series of the closed pool are deleted.
+	BackendPoolDelete(backend, address)
+	assert.Equal(t, 0, seriesCount(backendPoolConnections))
+	assert.Equal(t, 0, seriesCount(backendPoolWaitSeconds))
+}
+
+// seriesCount returns the number of the series of the collector.
+func seriesCount(c prometheus.Collector) int {
+	ch := make(chan prometheus.Metric, 16)
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+	n := 0
+	for range ch {
+		n++
+	}
+	return n
+}
+
+func TestXADuration(t *testing.T) {
+	XADurationObserve("prepare", "OK", 0.1)
+	XADurationObserve("commit", "Error", 0.2)
+
+	var m dto.Metric
+	h, _ := xaDurationSeconds.GetMetricWithLabelValues("prepare", "OK")
+	err := h.(prometheus.Histogram).Write(&m)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, m.GetHistogram().GetSampleCount())
+
+	h, _ = xaDurationSeconds.GetMetricWithLabelValues("commit", "Error")
+	err = h.(prometheus.Histogram).Write(&m)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, m.GetHistogram().GetSampleCount())
+	assert.EqualValues(t, 0.2, m.GetHistogram().GetSampleSum())
+}
+
+func TestThrottleQueueDepth(t *testing.T) {
+	ThrottleQueueDepthInc()
+	ThrottleQueueDepthInc()
+
+	var m dto.Metric
+	err := throttleQueueDepth.Write(&m)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 2, m.GetGauge().GetValue())
+
+	ThrottleQueueDepthDec()
+	ThrottleQueueDepthDec()
+	err = throttleQueueDepth.Write(&m)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 0, m.GetGauge().GetValue())
+}
+
+func TestShift(t *testing.T) {
+	typ := "reshard"
+	ShiftStart(typ)
+	ShiftStart(typ)
+
+	var m dto.Metric
+	g, _ := shiftRunning.GetMetricWithLabelValues(typ)
+	err := g.Write(&m)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 2, m.GetGauge().GetValue())
+
+	ShiftFinish(typ, "OK", 60)
+	ShiftFinish(typ, "Error", 10)
+	err = g.Write(&m)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 0, m.GetGauge().GetValue())
+
+	c, _ := shiftTotalCounter.GetMetricWithLabelValues(typ, "OK")
+	err = c.Write(&m)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, m.GetCounter().GetValue())
+
+	h, _ := shiftDurationSeconds.GetMetricWithLabelValues(typ, "Error")
+	err = h.(prometheus.Histogram).Write(&m)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, m.GetHistogram().GetSampleCount())
+	assert.EqualValues(t, 10, m.GetHistogram().GetSampleSum())
+
+	ShiftInstanceStart(typ, "db1.t1")
+	g, _ = shiftStartTime.GetMetricWithLabelValues(typ, "db1.t1")
+	err = g.Write(&m)
+	assert.Nil(t, err)
+	assert.True(t, m.GetGauge().GetValue() > 0)
+	ShiftInstanceFinish(typ, "db1.t1")
+	assert.Equal(t, 0, seriesCount(shiftStartTime))
+}
@@ -10,7 +10,9 @@ package optimizer
 
 import (
 	"planner"
+	"planner/builder"
 	"router"
+	"xtrace"
 
 	"github.com/pkg/errors"
 	"github.com/xelabs/go-mysqlstack/sqlparser"
@@ -23,11 +25,14 @@ var (
 
 // SimpleOptimizer is a simple optimizer who dispatches the plans
 type SimpleOptimizer struct {
-	log      *xlog.Log
-	database string
-	query    string
-	node     sqlparser.Statement
-	router   *router.Router
+	log       *xlog.Log
+	database  string
+	query     string
+	node      sqlparser.Statement
+	router    *router.Router
+	masker    builder.Masker
+	decrypter builder.Decrypter
+	span      *xtrace.Span
 }
 
 // NewSimpleOptimizer creates the new simple optimizer.
@@ -41,8 +46,33 @@ func NewSimpleOptimizer(log *xlog.Log, database string, query string, node sqlpa
 	}
 }
 
+// WithMasker used to mask the columns of the select result for the session user,
+// and check the masked columns in the update and delete.
+func (so *SimpleOptimizer) WithMasker(masker builder.Masker) *SimpleOptimizer {
+	so.masker = masker
+	return so
+}
+
+// WithDecrypter used to decrypt the encrypted columns of the select result.
+func (so *SimpleOptimizer) WithDecrypter(decrypter builder.Decrypter) *SimpleOptimizer {
+	so.decrypter = decrypter
+	return so
+}
+
+// WithSpan used to trace the plan building as the child of the span.
+func (so *SimpleOptimizer) WithSpan(span *xtrace.Span) *SimpleOptimizer {
+	so.span = span
+	return so
+}
+
 // BuildPlanTree used to build plan trees for the query.
-func (so *SimpleOptimizer) BuildPlanTree() (*planner.PlanTree, error) {
+func (so *SimpleOptimizer) BuildPlanTree() (_ *planner.PlanTree, err error) {
+	span := so.span.StartChild("optimizer.BuildPlanTree")
+	defer func() {
+		span.SetError(err)
+		span.End()
+	}()
+
 	log := so.log
 	database := so.database
 	query := so.query
@@ -59,16 +89,22 @@ func (so *SimpleOptimizer) BuildPlanTree() (*planner.PlanTree, error) {
 		plans.Add(node)
 	case *sqlparser.Delete:
 		node := planner.NewDeletePlan(log, database, query, node.(*sqlparser.Delete), router)
+		node.SetMasker(so.masker)
 		plans.Add(node)
 	case *sqlparser.Update:
 		node := planner.NewUpdatePlan(log, database, query, node.(*sqlparser.Update), router)
+		node.SetMasker(so.masker)
 		plans.Add(node)
 	case *sqlparser.Select:
 		nod := node.(*sqlparser.Select)
 		selectNode := planner.NewSelectPlan(log, database, query, nod, router)
+		selectNode.SetMasker(so.masker)
+		selectNode.Decrypter = so.decrypter
 		plans.Add(selectNode)
 	case *sqlparser.Union:
 		node := planner.NewUnionPlan(log, database, query, node.(*sqlparser.Union), router)
+		node.SetMasker(so.masker)
+		node.Decrypter = so.decrypter
 		plans.Add(node)
 	case *sqlparser.Checksum, *sqlparser.Optimize, *sqlparser.Check:
 		node := planner.NewOthersPlan(log, database, query, node, router)
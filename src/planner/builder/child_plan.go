@@ -20,6 +20,9 @@ const (
 
 	// ChildTypeAggregate enum.
 	ChildTypeAggregate ChildType = "ChildTypeAggregate"
+
+	// ChildTypeMask enum.
+	ChildTypeMask ChildType = "ChildTypeMask"
 )
 
 // ChildPlan interface.
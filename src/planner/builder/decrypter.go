@@ -0,0 +1,29 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package builder
+
+import (
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+// Decrypter interface, returns the encrypted columns(lower case) of the table and decrypts the value,
+// the value which is not encrypted is returned as is.
+type Decrypter interface {
+	Columns(database string, table string) map[string]string
+	Decrypt(value []byte) ([]byte, error)
+}
+
+// NewDecryptPlan used to create the MaskPlan which resolves the result fields of the encrypted columns,
+// the encrypted columns can be used in the predicates since their values are not leaked.
+func NewDecryptPlan(log *xlog.Log, database string, node sqlparser.SelectStatement, decrypter Decrypter) *MaskPlan {
+	p := NewMaskPlan(log, database, node, decrypter)
+	p.resolveOnly = true
+	return p
+}
@@ -0,0 +1,472 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package builder
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/common"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+const (
+	// MaskFull replaces the value with '****'.
+	MaskFull = "FULL"
+
+	// MaskPartial keeps the last 4 characters, the others are replaced by '*'.
+	MaskPartial = "PARTIAL"
+
+	// MaskHash replaces the value with its sha256 hex digest.
+	MaskHash = "HASH"
+
+	// MaskNull replaces the value with NULL.
+	MaskNull = "NULL"
+)
+
+var (
+	_ ChildPlan = &MaskPlan{}
+)
+
+// Masker interface, returns the masked columns(lower case) of the table and their mask types.
+type Masker interface {
+	Columns(database string, table string) map[string]string
+}
+
+// MaskPlan represents the column masking plan, applied on the final result.
+type MaskPlan struct {
+	log *xlog.Log
+
+	masker   Masker
+	database string
+	node     sqlparser.SelectStatement
+
+	// resolveOnly is true if the columns are resolved only, without the checks of the leaks.
+	resolveOnly bool
+
+	// The mask types by the field index, used if there's no '*' in the select exprs.
+	Masks []string `json:",omitempty"`
+
+	// The mask types by the field name(lower case), used if there's '*' in the select exprs.
+	Names map[string]string `json:",omitempty"`
+
+	// type
+	typ ChildType
+}
+
+// NewMaskPlan used to create MaskPlan.
+func NewMaskPlan(log *xlog.Log, database string, node sqlparser.SelectStatement, masker Masker) *MaskPlan {
+	return &MaskPlan{
+		log:      log,
+		masker:   masker,
+		database: database,
+		node:     node,
+		typ:      ChildTypeMask,
+	}
+}
+
+// maskScope tuple, the masked columns of the tables in the from clause.
+type maskScope struct {
+	aliases []string
+	columns map[string]map[string]string
+	// The masked select exprs by the alias(lower case), used by the HAVING, GROUP BY and ORDER BY.
+	selects map[string]string
+}
+
+// lookup returns the mask type of the column, the unqualified column matches any table.
+func (s *maskScope) lookup(col *sqlparser.ColName) string {
+	name := col.Name.Lowered()
+	if !col.Qualifier.IsEmpty() {
+		return s.columns[col.Qualifier.Name.String()][name]
+	}
+	if typ := s.selects[name]; typ != "" {
+		return typ
+	}
+	for _, alias := range s.aliases {
+		if typ := s.columns[alias][name]; typ != "" {
+			return typ
+		}
+	}
+	return ""
+}
+
+// maskOf returns the first masked column and its mask type in the expr.
+func (s *maskScope) maskOf(expr sqlparser.SQLNode) (*sqlparser.ColName, string) {
+	var col *sqlparser.ColName
+	var typ string
+	sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+		if c, ok := node.(*sqlparser.ColName); ok {
+			if t := s.lookup(c); t != "" {
+				col, typ = c, t
+				return false, nil
+			}
+		}
+		return true, nil
+	}, expr)
+	return col, typ
+}
+
+// scanTables used to resolve the masked columns of the tables, and collect the join conditions.
+// The masked columns of the derived table are resolved by its select exprs.
+func (p *MaskPlan) scanTables(exprs sqlparser.TableExprs, scope *maskScope, ons *[]sqlparser.Expr) error {
+	for _, expr := range exprs {
+		switch expr := expr.(type) {
+		case *sqlparser.AliasedTableExpr:
+			switch table := expr.Expr.(type) {
+			case sqlparser.TableName:
+				database := p.database
+				if !table.Qualifier.IsEmpty() {
+					database = table.Qualifier.String()
+				}
+				alias := table.Name.String()
+				if !expr.As.IsEmpty() {
+					alias = expr.As.String()
+				}
+				scope.aliases = append(scope.aliases, alias)
+				scope.columns[alias] = p.masker.Columns(database, table.Name.String())
+			case *sqlparser.Subquery:
+				masks, names, err := p.analyze(table.Select)
+				if err != nil {
+					return err
+				}
+				alias := expr.As.String()
+				scope.aliases = append(scope.aliases, alias)
+				scope.columns[alias] = outputMasks(table.Select, masks, names)
+			}
+		case *sqlparser.JoinTableExpr:
+			if err := p.scanTables(sqlparser.TableExprs{expr.LeftExpr, expr.RightExpr}, scope, ons); err != nil {
+				return err
+			}
+			if expr.On != nil {
+				*ons = append(*ons, expr.On)
+			}
+		case *sqlparser.ParenTableExpr:
+			if err := p.scanTables(expr.Exprs, scope, ons); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// outputMasks returns the mask types by the output column name(lower case) of the select statement.
+func outputMasks(node sqlparser.SelectStatement, masks []string, names map[string]string) map[string]string {
+	if names != nil {
+		return names
+	}
+	for {
+		switch n := node.(type) {
+		case *sqlparser.Union:
+			node = n.Left
+			continue
+		case *sqlparser.ParenSelect:
+			node = n.Select
+			continue
+		}
+		break
+	}
+	outputs := make(map[string]string)
+	sel, ok := node.(*sqlparser.Select)
+	if !ok {
+		return outputs
+	}
+	for i, expr := range sel.SelectExprs {
+		aliased, ok := expr.(*sqlparser.AliasedExpr)
+		if !ok || i >= len(masks) || masks[i] == "" {
+			continue
+		}
+		name := aliased.As.Lowered()
+		if name == "" {
+			if col, ok := aliased.Expr.(*sqlparser.ColName); ok {
+				name = col.Name.Lowered()
+			} else {
+				name = strings.ToLower(sqlparser.String(aliased.Expr))
+			}
+		}
+		outputs[name] = masks[i]
+	}
+	return outputs
+}
+
+// checkOrder used to check the masked columns in the GROUP BY or ORDER BY, which leak the order of the values.
+// The position(from 1) of the select expr is checked by the masked func.
+func checkOrder(scope *maskScope, exprs []sqlparser.Expr, masked func(pos int) bool, clause string) error {
+	for _, expr := range exprs {
+		if val, ok := expr.(*sqlparser.SQLVal); ok && val.Type == sqlparser.IntVal {
+			pos, err := strconv.Atoi(string(val.Val))
+			if err == nil && pos > 0 && masked(pos) {
+				return errors.Errorf("unsupported: the.masked.column[%d].in.the.%s", pos, clause)
+			}
+			continue
+		}
+		if col, _ := scope.maskOf(expr); col != nil {
+			return errors.Errorf("unsupported: the.masked.column[%s].in.the.%s", sqlparser.String(col), clause)
+		}
+	}
+	return nil
+}
+
+func orderExprs(orderBy sqlparser.OrderBy) []sqlparser.Expr {
+	var exprs []sqlparser.Expr
+	for _, order := range orderBy {
+		exprs = append(exprs, order.Expr)
+	}
+	return exprs
+}
+
+// analyzeSelect returns the masks by index, or by name if there's '*' in the select exprs.
+// The masked columns can't be used in the predicates, otherwise the values will be leaked by the filter.
+func (p *MaskPlan) analyzeSelect(node *sqlparser.Select) ([]string, map[string]string, error) {
+	var ons []sqlparser.Expr
+	scope := &maskScope{columns: make(map[string]map[string]string)}
+	if err := p.scanTables(node.From, scope, &ons); err != nil {
+		return nil, nil, err
+	}
+
+	predicates := ons
+	if node.Where != nil {
+		predicates = append(predicates, node.Where.Expr)
+	}
+	for _, predicate := range predicates {
+		if col, _ := scope.maskOf(predicate); col != nil && !p.resolveOnly {
+			return nil, nil, errors.Errorf("unsupported: the.masked.column[%s].in.the.predicate", sqlparser.String(col))
+		}
+	}
+
+	hasStar := false
+	for _, expr := range node.SelectExprs {
+		if _, ok := expr.(*sqlparser.StarExpr); ok {
+			hasStar = true
+		}
+	}
+
+	var masks []string
+	names := make(map[string]string)
+	scope.selects = make(map[string]string)
+	for _, expr := range node.SelectExprs {
+		switch expr := expr.(type) {
+		case *sqlparser.StarExpr:
+			qualifier := expr.TableName.Name.String()
+			for _, alias := range scope.aliases {
+				if qualifier != "" && qualifier != alias {
+					continue
+				}
+				for col, typ := range scope.columns[alias] {
+					names[col] = typ
+				}
+			}
+		case *sqlparser.AliasedExpr:
+			col, typ := scope.maskOf(expr.Expr)
+			if col != nil && !expr.As.IsEmpty() {
+				scope.selects[expr.As.Lowered()] = typ
+			}
+			if !hasStar {
+				masks = append(masks, typ)
+				continue
+			}
+			if col == nil {
+				continue
+			}
+			name := expr.As.Lowered()
+			if name == "" {
+				c, ok := expr.Expr.(*sqlparser.ColName)
+				if !ok {
+					if p.resolveOnly {
+						continue
+					}
+					return nil, nil, errors.Errorf("unsupported: the.masked.column[%s].in.the.expression.with.'*'", sqlparser.String(col))
+				}
+				name = c.Name.Lowered()
+			}
+			names[name] = typ
+		default:
+			masks = append(masks, "")
+		}
+	}
+
+	if p.resolveOnly {
+		if hasStar {
+			return nil, names, nil
+		}
+		return masks, nil, nil
+	}
+
+	// The HAVING, GROUP BY and ORDER BY can refer to the select exprs by the alias.
+	if node.Having != nil {
+		if col, _ := scope.maskOf(node.Having.Expr); col != nil {
+			return nil, nil, errors.Errorf("unsupported: the.masked.column[%s].in.the.predicate", sqlparser.String(col))
+		}
+	}
+	masked := func(pos int) bool {
+		// The position can't be resolved with '*', all the positions are treated as masked.
+		if hasStar {
+			return len(names) > 0
+		}
+		return pos <= len(masks) && masks[pos-1] != ""
+	}
+	if err := checkOrder(scope, node.GroupBy, masked, "group.by"); err != nil {
+		return nil, nil, err
+	}
+	if err := checkOrder(scope, orderExprs(node.OrderBy), masked, "order.by"); err != nil {
+		return nil, nil, err
+	}
+
+	if hasStar {
+		return nil, names, nil
+	}
+	return masks, nil, nil
+}
+
+// analyze used to analyze the masks of the select statement.
+func (p *MaskPlan) analyze(node sqlparser.SelectStatement) ([]string, map[string]string, error) {
+	switch node := node.(type) {
+	case *sqlparser.Select:
+		return p.analyzeSelect(node)
+	case *sqlparser.ParenSelect:
+		return p.analyze(node.Select)
+	case *sqlparser.Union:
+		lmasks, lnames, err := p.analyze(node.Left)
+		if err != nil {
+			return nil, nil, err
+		}
+		rmasks, rnames, err := p.analyze(node.Right)
+		if err != nil {
+			return nil, nil, err
+		}
+		if (len(lnames) > 0 || len(rnames) > 0) && p.resolveOnly {
+			// The positions can't be resolved with '*', resolved by the names of both sides.
+			names := make(map[string]string)
+			for _, outputs := range []map[string]string{outputMasks(node.Left, lmasks, lnames), outputMasks(node.Right, rmasks, rnames)} {
+				for name, typ := range outputs {
+					names[name] = typ
+				}
+			}
+			return nil, names, nil
+		}
+		if len(lnames) > 0 || len(rnames) > 0 {
+			return nil, nil, errors.New("unsupported: the.masked.columns.with.'*'.in.union")
+		}
+		if len(rmasks) > len(lmasks) {
+			lmasks, rmasks = rmasks, lmasks
+		}
+		for i, typ := range rmasks {
+			if lmasks[i] == "" {
+				lmasks[i] = typ
+			}
+		}
+		if !p.resolveOnly {
+			// The ORDER BY of the union refers to the output columns.
+			scope := &maskScope{selects: outputMasks(node, lmasks, nil)}
+			masked := func(pos int) bool {
+				return pos <= len(lmasks) && lmasks[pos-1] != ""
+			}
+			if err := checkOrder(scope, orderExprs(node.OrderBy), masked, "order.by"); err != nil {
+				return nil, nil, err
+			}
+		}
+		return lmasks, nil, nil
+	}
+	return nil, nil, nil
+}
+
+// CheckMaskDML used to check the masked columns in the UPDATE or DELETE of the session user.
+// The masked columns can't be used in the predicates or the ORDER BY, otherwise the values will be leaked by the
+// affected rows, nor in the SET values, otherwise the values will be copied to the unmasked columns.
+func CheckMaskDML(log *xlog.Log, database string, node sqlparser.Statement, masker Masker) error {
+	p := &MaskPlan{log: log, masker: masker, database: database}
+	scope := &maskScope{columns: make(map[string]map[string]string)}
+
+	var ons []sqlparser.Expr
+	var where *sqlparser.Where
+	var orderBy sqlparser.OrderBy
+	var values []sqlparser.Expr
+	switch node := node.(type) {
+	case *sqlparser.Update:
+		if err := p.scanTables(sqlparser.TableExprs{&sqlparser.AliasedTableExpr{Expr: node.Table}}, scope, &ons); err != nil {
+			return err
+		}
+		where, orderBy = node.Where, node.OrderBy
+		for _, expr := range node.Exprs {
+			values = append(values, expr.Expr)
+		}
+	case *sqlparser.Delete:
+		if err := p.scanTables(node.TableRefs, scope, &ons); err != nil {
+			return err
+		}
+		where, orderBy = node.Where, node.OrderBy
+	default:
+		return nil
+	}
+
+	predicates := ons
+	if where != nil {
+		predicates = append(predicates, where.Expr)
+	}
+	for _, predicate := range predicates {
+		if col, _ := scope.maskOf(predicate); col != nil {
+			return errors.Errorf("unsupported: the.masked.column[%s].in.the.predicate", sqlparser.String(col))
+		}
+	}
+	for _, value := range values {
+		if col, _ := scope.maskOf(value); col != nil {
+			return errors.Errorf("unsupported: the.masked.column[%s].in.the.set.value", sqlparser.String(col))
+		}
+	}
+	return checkOrder(scope, orderExprs(orderBy), func(int) bool { return false }, "order.by")
+}
+
+// Build used to build the masks.
+func (p *MaskPlan) Build() error {
+	masks, names, err := p.analyze(p.node)
+	if err != nil {
+		return err
+	}
+	for _, typ := range masks {
+		if typ != "" {
+			p.Masks = masks
+			break
+		}
+	}
+	if len(names) > 0 {
+		p.Names = names
+	}
+	return nil
+}
+
+// Empty returns true if no column is masked.
+func (p *MaskPlan) Empty() bool {
+	return len(p.Masks) == 0 && len(p.Names) == 0
+}
+
+// MaskOf returns the mask type of the field.
+func (p *MaskPlan) MaskOf(idx int, name string) string {
+	if p.Names != nil {
+		return p.Names[strings.ToLower(name)]
+	}
+	if idx < len(p.Masks) {
+		return p.Masks[idx]
+	}
+	return ""
+}
+
+// Type returns the type of the plan.
+func (p *MaskPlan) Type() ChildType {
+	return p.typ
+}
+
+// JSON returns the plan info.
+func (p *MaskPlan) JSON() string {
+	out, err := common.ToJSONString(p, false, "", "\t")
+	if err != nil {
+		return err.Error()
+	}
+	return out
+}
@@ -0,0 +1,178 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package builder
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+type mockMasker map[string]map[string]string
+
+func (m mockMasker) Columns(database string, table string) map[string]string {
+	return m[database+"."+table]
+}
+
+var testMasker = mockMasker{
+	"sbtest.A": {"phone": MaskPartial, "email": MaskHash},
+	"sbtest.B": {"name": MaskFull},
+}
+
+func TestMaskPlan(t *testing.T) {
+	querys := []string{
+		"select id, phone, email from A where id=1",
+		"select id, concat(a.phone, 'x') as p from A a join B on a.id=B.id",
+		"select * from A",
+		"select a.*, B.name as n, B.id from A a, B",
+		"select B.*, id from A join B on A.id=B.id",
+		"select id from A union select name from B",
+		"select id, phone from A union all (select id, name from B)",
+		"select id from C where phone=1",
+		"select * from C",
+		"select * from (select id, phone as p from A) x",
+		"select x.p from (select * from A) as x",
+		"select id from A order by id",
+		"select id, phone from A union select id, name from B order by id",
+	}
+	wants := []string{
+		"{\n\t\"Masks\": [\n\t\t\"\",\n\t\t\"PARTIAL\",\n\t\t\"HASH\"\n\t]\n}",
+		"{\n\t\"Masks\": [\n\t\t\"\",\n\t\t\"PARTIAL\"\n\t]\n}",
+		"{\n\t\"Names\": {\n\t\t\"email\": \"HASH\",\n\t\t\"phone\": \"PARTIAL\"\n\t}\n}",
+		"{\n\t\"Names\": {\n\t\t\"email\": \"HASH\",\n\t\t\"n\": \"FULL\",\n\t\t\"phone\": \"PARTIAL\"\n\t}\n}",
+		"{\n\t\"Names\": {\n\t\t\"name\": \"FULL\"\n\t}\n}",
+		"{\n\t\"Masks\": [\n\t\t\"FULL\"\n\t]\n}",
+		"{\n\t\"Masks\": [\n\t\t\"\",\n\t\t\"PARTIAL\"\n\t]\n}",
+		"{}",
+		"{}",
+		"{\n\t\"Names\": {\n\t\t\"p\": \"PARTIAL\"\n\t}\n}",
+		"{}",
+		"{}",
+		"{\n\t\"Masks\": [\n\t\t\"\",\n\t\t\"PARTIAL\"\n\t]\n}",
+	}
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	for i, query := range querys {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		p := NewMaskPlan(log, "sbtest", node.(sqlparser.SelectStatement), testMasker)
+		err = p.Build()
+		assert.Nil(t, err, query)
+		assert.Equal(t, wants[i], p.JSON(), query)
+		assert.Equal(t, ChildTypeMask, p.Type())
+		assert.Equal(t, wants[i] == "{}", p.Empty())
+	}
+}
+
+func TestMaskPlanMaskOf(t *testing.T) {
+	p := &MaskPlan{Masks: []string{"", MaskFull}}
+	assert.Equal(t, "", p.MaskOf(0, "id"))
+	assert.Equal(t, MaskFull, p.MaskOf(1, "phone"))
+	assert.Equal(t, "", p.MaskOf(2, "email"))
+
+	p = &MaskPlan{Names: map[string]string{"phone": MaskNull}}
+	assert.Equal(t, MaskNull, p.MaskOf(0, "PHONE"))
+	assert.Equal(t, "", p.MaskOf(1, "id"))
+}
+
+func TestMaskPlanError(t *testing.T) {
+	querys := []string{
+		"select id from A where phone='13800000000'",
+		"select id from A where A.email like 'a%'",
+		"select A.id from A join B on A.id=B.id and B.name='x'",
+		"select id, count(*) from A group by id having max(phone)>1",
+		"select *, concat(phone, 'x') from A",
+		"select * from A union select * from B",
+		"select phone as p from A having p like '138%'",
+		"select id, phone from A order by phone",
+		"select id, phone as p from A order by p desc",
+		"select id, phone from A order by 2",
+		"select * from A order by 1",
+		"select count(*) from A group by email",
+		"select name as n, count(*) from B group by n",
+		"select id, phone from A union select id, name from B order by 2",
+		"select id, phone as p from A union select id, name from B order by p",
+		"select * from (select id from A where phone='1') x",
+		"select id from (select * from A) x where x.phone like '138%'",
+		"select id from (select phone as p from A) x order by p",
+	}
+	wants := []string{
+		"unsupported: the.masked.column[phone].in.the.predicate",
+		"unsupported: the.masked.column[A.email].in.the.predicate",
+		"unsupported: the.masked.column[B.name].in.the.predicate",
+		"unsupported: the.masked.column[phone].in.the.predicate",
+		"unsupported: the.masked.column[phone].in.the.expression.with.'*'",
+		"unsupported: the.masked.columns.with.'*'.in.union",
+		"unsupported: the.masked.column[p].in.the.predicate",
+		"unsupported: the.masked.column[phone].in.the.order.by",
+		"unsupported: the.masked.column[p].in.the.order.by",
+		"unsupported: the.masked.column[2].in.the.order.by",
+		"unsupported: the.masked.column[1].in.the.order.by",
+		"unsupported: the.masked.column[email].in.the.group.by",
+		"unsupported: the.masked.column[n].in.the.group.by",
+		"unsupported: the.masked.column[2].in.the.order.by",
+		"unsupported: the.masked.column[p].in.the.order.by",
+		"unsupported: the.masked.column[phone].in.the.predicate",
+		"unsupported: the.masked.column[x.phone].in.the.predicate",
+		"unsupported: the.masked.column[p].in.the.order.by",
+	}
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	for i, query := range querys {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		p := NewMaskPlan(log, "sbtest", node.(sqlparser.SelectStatement), testMasker)
+		err = p.Build()
+		assert.Equal(t, wants[i], err.Error(), query)
+	}
+}
+
+func TestCheckMaskDML(t *testing.T) {
+	querys := []string{
+		"update A set phone='x' where id=1",
+		"update A set name=id where id=1",
+		"delete from A where id=1",
+		"delete from A where id=1 order by id limit 1",
+		"update C set name=phone where phone like '1%'",
+	}
+	errs := []string{
+		"unsupported: the.masked.column[phone].in.the.predicate",
+		"unsupported: the.masked.column[email].in.the.predicate",
+		"unsupported: the.masked.column[phone].in.the.set.value",
+		"unsupported: the.masked.column[sbtest.A.email].in.the.set.value",
+		"unsupported: the.masked.column[phone].in.the.order.by",
+		"unsupported: the.masked.column[B.name].in.the.predicate",
+		"unsupported: the.masked.column[phone].in.the.order.by",
+	}
+	errQuerys := []string{
+		"update A set id=1 where phone like '1%'",
+		"update sbtest.A set id=1 where id=1 and email='x'",
+		"update A set name=concat(phone, 'x') where id=1",
+		"update sbtest.A set name=sbtest.A.email where id=1",
+		"update A set id=1 where id>1 order by phone limit 1",
+		"delete a from A a join B on a.phone=B.name where B.id=1",
+		"delete from A where id>1 order by phone limit 1",
+	}
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	for _, query := range querys {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		err = CheckMaskDML(log, "sbtest", node, testMasker)
+		assert.Nil(t, err, query)
+	}
+	for i, query := range errQuerys {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		err = CheckMaskDML(log, "sbtest", node, testMasker)
+		assert.NotNil(t, err, query)
+		if err != nil {
+			assert.Equal(t, errs[i], err.Error(), query)
+		}
+	}
+}
@@ -47,6 +47,9 @@ type DeletePlan struct {
 
 	// query and backend tuple
 	Querys []xcontext.QueryTuple
+
+	// masker, the column masking of the session user.
+	masker builder.Masker
 }
 
 // NewDeletePlan used to create DeletePlan
@@ -80,6 +83,19 @@ func (p *DeletePlan) analyze() error {
 	return nil
 }
 
+// SetMasker used to set the column masker of the session user.
+func (p *DeletePlan) SetMasker(masker builder.Masker) {
+	p.masker = masker
+}
+
+// checkMask used to check the masked columns which can be leaked by the delete.
+func (p *DeletePlan) checkMask() error {
+	if p.masker == nil {
+		return nil
+	}
+	return builder.CheckMaskDML(p.log, p.database, p.node, p.masker)
+}
+
 // Build used to build distributed querys.
 func (p *DeletePlan) Build() error {
 	// step 1: analyze if delete has unsupported features.
@@ -87,6 +103,9 @@ func (p *DeletePlan) Build() error {
 	if err = p.analyze(); err != nil {
 		return err
 	}
+	if err = p.checkMask(); err != nil {
+		return err
+	}
 	newNode := *p.node
 	// For single table, the len(TableRefs)=1 and the type of TableExpr must be AliasedTableExpr.
 	newAliseExpr := newNode.TableRefs[0].(*sqlparser.AliasedTableExpr)
@@ -45,6 +45,18 @@ type SelectPlan struct {
 	typ PlanType
 
 	Root builder.PlanNode
+
+	// masker, the column masking of the session user.
+	masker builder.Masker
+
+	// Mask is nil if no column is masked.
+	Mask *builder.MaskPlan
+
+	// Decrypter decrypts the encrypted columns of the result, nil if no column is encrypted.
+	Decrypter builder.Decrypter
+
+	// Decrypt resolves the result fields of the encrypted columns, nil if no field is encrypted.
+	Decrypt *builder.MaskPlan
 }
 
 // NewSelectPlan used to create SelectPlan.
@@ -67,10 +79,51 @@ func (p *SelectPlan) Build() error {
 	if hasSubquery(p.node) {
 		return errors.New("unsupported: subqueries.in.select")
 	}
+	if err = p.buildMask(); err != nil {
+		return err
+	}
+	if err = p.buildDecrypt(); err != nil {
+		return err
+	}
 	p.Root, err = builder.BuildNode(p.log, p.router, p.database, p.node)
 	return err
 }
 
+// SetMasker used to set the column masker of the session user.
+func (p *SelectPlan) SetMasker(masker builder.Masker) {
+	p.masker = masker
+}
+
+// buildMask used to build the mask plan before the ast is rewritten by the builder.
+func (p *SelectPlan) buildMask() error {
+	if p.masker == nil {
+		return nil
+	}
+	mask := builder.NewMaskPlan(p.log, p.database, p.node, p.masker)
+	if err := mask.Build(); err != nil {
+		return err
+	}
+	if !mask.Empty() {
+		p.Mask = mask
+	}
+	return nil
+}
+
+// buildDecrypt used to resolve the encrypted fields before the ast is rewritten by the builder.
+func (p *SelectPlan) buildDecrypt() error {
+	if p.Decrypter == nil {
+		return nil
+	}
+	decrypt := builder.NewDecryptPlan(p.log, p.database, p.node, p.Decrypter)
+	if err := decrypt.Build(); err != nil {
+		return err
+	}
+	if !decrypt.Empty() {
+		p.Decrypt = decrypt
+	}
+	return nil
+}
+
 // Type returns the type of the plan.
 func (p *SelectPlan) Type() PlanType {
 	return p.typ
@@ -97,6 +150,7 @@ func (p *SelectPlan) JSON() string {
 		GatherMerge []string              `json:",omitempty"`
 		HashGroupBy []string              `json:",omitempty"`
 		Limit       *limit                `json:",omitempty"`
+		Mask        *builder.MaskPlan     `json:",omitempty"`
 	}
 
 	var joins *join
@@ -159,6 +213,7 @@ func (p *SelectPlan) JSON() string {
 		GatherMerge: gatherMerge,
 		HashGroupBy: hashGroup,
 		Limit:       lim,
+		Mask:        p.Mask,
 	}
 	out, err := common.ToJSONString(exp, false, "", "\t")
 	if err != nil {
@@ -42,6 +42,18 @@ type UnionPlan struct {
 	typ PlanType
 
 	Root builder.PlanNode
+
+	// masker, the column masking of the session user.
+	masker builder.Masker
+
+	// Mask is nil if no column is masked.
+	Mask *builder.MaskPlan
+
+	// Decrypter decrypts the encrypted columns of the result, nil if no column is encrypted.
+	Decrypter builder.Decrypter
+
+	// Decrypt resolves the result fields of the encrypted columns, nil if no field is encrypted.
+	Decrypt *builder.MaskPlan
 }
 
 // NewUnionPlan used to create SelectPlan.
@@ -59,10 +71,51 @@ func NewUnionPlan(log *xlog.Log, database string, query string, node *sqlparser.
 // Build used to build distributed querys.
 func (p *UnionPlan) Build() error {
 	var err error
+	if err = p.buildMask(); err != nil {
+		return err
+	}
+	if err = p.buildDecrypt(); err != nil {
+		return err
+	}
 	p.Root, err = builder.BuildNode(p.log, p.router, p.database, p.node)
 	return err
 }
 
+// SetMasker used to set the column masker of the session user.
+func (p *UnionPlan) SetMasker(masker builder.Masker) {
+	p.masker = masker
+}
+
+// buildMask used to build the mask plan before the ast is rewritten by the builder.
+func (p *UnionPlan) buildMask() error {
+	if p.masker == nil {
+		return nil
+	}
+	mask := builder.NewMaskPlan(p.log, p.database, p.node, p.masker)
+	if err := mask.Build(); err != nil {
+		return err
+	}
+	if !mask.Empty() {
+		p.Mask = mask
+	}
+	return nil
+}
+
+// buildDecrypt used to resolve the encrypted fields before the ast is rewritten by the builder.
+func (p *UnionPlan) buildDecrypt() error {
+	if p.Decrypter == nil {
+		return nil
+	}
+	decrypt := builder.NewDecryptPlan(p.log, p.database, p.node, p.Decrypter)
+	if err := decrypt.Build(); err != nil {
+		return err
+	}
+	if !decrypt.Empty() {
+		p.Decrypt = decrypt
+	}
+	return nil
+}
+
 // Type returns the type of the plan.
 func (p *UnionPlan) Type() PlanType {
 	return p.typ
@@ -82,6 +135,7 @@ func (p *UnionPlan) JSON() string {
 		UnionType   *string               `json:",omitempty"`
 		GatherMerge []string              `json:",omitempty"`
 		Limit       *limit                `json:",omitempty"`
+		Mask        *builder.MaskPlan     `json:",omitempty"`
 	}
 
 	// Union.
@@ -112,6 +166,7 @@ func (p *UnionPlan) JSON() string {
 		UnionType:   uni,
 		GatherMerge: gatherMerge,
 		Limit:       lim,
+		Mask:        p.Mask,
 	}
 	out, err := common.ToJSONString(exp, false, "", "\t")
 	if err != nil {
@@ -47,6 +47,9 @@ type UpdatePlan struct {
 
 	// query and backend tuple
 	Querys []xcontext.QueryTuple
+
+	// masker, the column masking of the session user.
+	masker builder.Masker
 }
 
 // NewUpdatePlan used to create UpdatePlan
@@ -75,11 +78,27 @@ func (p *UpdatePlan) analyze() error {
 	return nil
 }
 
+// SetMasker used to set the column masker of the session user.
+func (p *UpdatePlan) SetMasker(masker builder.Masker) {
+	p.masker = masker
+}
+
+// checkMask used to check the masked columns which can be leaked by the update.
+func (p *UpdatePlan) checkMask() error {
+	if p.masker == nil {
+		return nil
+	}
+	return builder.CheckMaskDML(p.log, p.database, p.node, p.masker)
+}
+
 // Build used to build distributed querys.
 func (p *UpdatePlan) Build() error {
 	if err := p.analyze(); err != nil {
 		return err
 	}
+	if err := p.checkMask(); err != nil {
+		return err
+	}
 
 	node := p.node
 	// Database.
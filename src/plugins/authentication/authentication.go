@@ -0,0 +1,241 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package authentication
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"sync"
+
+	"config"
+
+	"github.com/pkg/errors"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+const (
+	// MySQLAuthenticator is the mysql.user of the backends, registered by the proxy.
+	MySQLAuthenticator = "mysql"
+	// FileAuthenticator is the static users file.
+	FileAuthenticator = "file"
+	// LDAPAuthenticator is the LDAP-like local directory.
+	LDAPAuthenticator = "ldap"
+)
+
+// cachedPassword tuple, the SHA256(SHA256(password)) of the user which passed the full authentication.
+type cachedPassword struct {
+	authString string
+	digest     [sha256.Size]byte
+}
+
+// Authentication tuple.
+type Authentication struct {
+	mu             sync.RWMutex
+	log            *xlog.Log
+	conf           *config.AuthConfig
+	authenticators map[string]Authenticator
+	cache          map[string]cachedPassword
+
+	// The RSA key is generated when the first client asks for the public key if it isn't configured.
+	keyOnce sync.Once
+	key     *rsa.PrivateKey
+	keyErr  error
+}
+
+// NewAuthentication -- creates new Authentication.
+func NewAuthentication(log *xlog.Log, conf *config.Config) AuthenticationHandler {
+	authConf := config.DefaultAuthConfig()
+	if conf != nil && conf.Auth != nil {
+		authConf = conf.Auth
+	}
+	return &Authentication{
+		log:            log,
+		conf:           authConf,
+		authenticators: make(map[string]Authenticator),
+		cache:          make(map[string]cachedPassword),
+	}
+}
+
+// Init -- init the builtin authenticator in the config.
+func (a *Authentication) Init() error {
+	log := a.log
+	conf := a.conf
+
+	switch conf.Authenticator {
+	case FileAuthenticator:
+		a.Register(FileAuthenticator, NewFile(log, conf.File))
+	case LDAPAuthenticator:
+		a.Register(LDAPAuthenticator, NewLDAP(log, conf.File, conf.LDAPBaseDN))
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if authenticator, ok := a.authenticators[conf.Authenticator]; ok {
+		if err := authenticator.Init(); err != nil {
+			return err
+		}
+	}
+	log.Info("plugin.authentication.init.done.authenticator[%s]", conf.Authenticator)
+	return nil
+}
+
+// Register used to register the authenticator, the mysql.user one is registered by the proxy.
+func (a *Authentication) Register(name string, authenticator Authenticator) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.authenticators[name] = authenticator
+}
+
+func (a *Authentication) authenticator() (Authenticator, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	authenticator, ok := a.authenticators[a.conf.Authenticator]
+	if !ok {
+		return nil, errors.Errorf("authenticator[%s].not.registered", a.conf.Authenticator)
+	}
+	return authenticator, nil
+}
+
+// Lookup returns the account of the user from the configured authenticator.
+func (a *Authentication) Lookup(user string) (*Account, error) {
+	authenticator, err := a.authenticator()
+	if err != nil {
+		return nil, err
+	}
+	return authenticator.Lookup(user)
+}
+
+// Authenticate used to verify the clear password of the full authentication,
+// the user is cached for the caching_sha2_password fast authentication if it passes.
+func (a *Authentication) Authenticate(account *Account, password string) error {
+	authenticator, err := a.authenticator()
+	if err != nil {
+		return err
+	}
+	if err := authenticator.Authenticate(account, password); err != nil {
+		a.mu.Lock()
+		delete(a.cache, account.User)
+		a.mu.Unlock()
+		return err
+	}
+
+	if password != "" {
+		stage1 := sha256.Sum256([]byte(password))
+		a.mu.Lock()
+		a.cache[account.User] = cachedPassword{authString: account.AuthenticationString, digest: sha256.Sum256(stage1[:])}
+		a.mu.Unlock()
+	}
+	return nil
+}
+
+// FastAuthenticate used to verify the caching_sha2_password scramble by the cache,
+// returns false if the user isn't cached or the account has changed since cached.
+func (a *Authentication) FastAuthenticate(account *Account, salt []byte, scramble []byte) bool {
+	a.mu.RLock()
+	cached, ok := a.cache[account.User]
+	a.mu.RUnlock()
+	if !ok || cached.authString != account.AuthenticationString || len(scramble) != sha256.Size {
+		return false
+	}
+
+	// SHA256(password) = XOR(scramble, SHA256(SHA256(SHA256(password)), salt))
+	crypt := sha256.New()
+	crypt.Write(cached.digest[:])
+	crypt.Write(salt)
+	stage3 := crypt.Sum(nil)
+	stage1 := make([]byte, sha256.Size)
+	for i := range scramble {
+		stage1[i] = scramble[i] ^ stage3[i]
+	}
+	stage2 := sha256.Sum256(stage1)
+	return bytes.Equal(stage2[:], cached.digest[:])
+}
+
+func (a *Authentication) privateKey() (*rsa.PrivateKey, error) {
+	a.keyOnce.Do(func() {
+		if a.conf.RSAPrivateKey == "" {
+			a.key, a.keyErr = rsa.GenerateKey(rand.Reader, 2048)
+			return
+		}
+		a.key, a.keyErr = loadPrivateKey(a.conf.RSAPrivateKey)
+	})
+	return a.key, a.keyErr
+}
+
+func loadPrivateKey(file string) (*rsa.PrivateKey, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.Errorf("rsa.private.key[%s].invalid.pem", file)
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.Errorf("rsa.private.key[%s].not.rsa", file)
+	}
+	return rsaKey, nil
+}
+
+// PublicKey returns the RSA public key in PEM for the caching_sha2_password full authentication.
+func (a *Authentication) PublicKey() ([]byte, error) {
+	key, err := a.privateKey()
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+// DecryptPassword used to decrypt the password encrypted by the RSA public key:
+// RSA(XOR(password + '\0', salt)).
+func (a *Authentication) DecryptPassword(data []byte, salt []byte) (string, error) {
+	if len(salt) == 0 {
+		return "", errors.New("decrypt.password.salt.empty")
+	}
+	key, err := a.privateKey()
+	if err != nil {
+		return "", err
+	}
+	plain, err := rsa.DecryptOAEP(sha1.New(), rand.Reader, key, data, nil)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	for i := range plain {
+		plain[i] ^= salt[i%len(salt)]
+	}
+	return string(bytes.TrimRight(plain, "\x00")), nil
+}
+
+// Close -- close all the authenticators.
+func (a *Authentication) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, authenticator := range a.authenticators {
+		authenticator.Close()
+	}
+	return nil
+}
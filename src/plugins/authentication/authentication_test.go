@@ -0,0 +1,178 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package authentication
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/proto"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestAuthentication(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	conf := &config.Config{Auth: config.DefaultAuthConfig()}
+	auth := NewAuthentication(log, conf)
+	err := auth.Init()
+	assert.Nil(t, err)
+	defer auth.Close()
+
+	// The mysql authenticator isn't registered.
+	{
+		_, err := auth.Lookup("mock")
+		assert.NotNil(t, err)
+		err = auth.Authenticate(&Account{User: "mock"}, "mock")
+		assert.NotNil(t, err)
+	}
+
+	mock := &mockAuthenticator{accounts: map[string]*Account{
+		"mock": {User: "mock", Plugin: NativePassword, AuthenticationString: NativePasswordHash("mock")},
+	}}
+	auth.Register(MySQLAuthenticator, mock)
+	salt := []byte("01234567890123456789")
+
+	// Lookup.
+	{
+		account, err := auth.Lookup("mock")
+		assert.Nil(t, err)
+		assert.Equal(t, "mock", account.User)
+
+		_, err = auth.Lookup("mockx")
+		assert.NotNil(t, err)
+	}
+
+	// Fast authentication.
+	{
+		account, _ := auth.Lookup("mock")
+		scramble := proto.CachingSHA2Password("mock", salt)
+
+		// Not cached.
+		assert.False(t, auth.FastAuthenticate(account, salt, scramble))
+
+		// Full authentication failed.
+		err := auth.Authenticate(account, "mockx")
+		assert.NotNil(t, err)
+		assert.False(t, auth.FastAuthenticate(account, salt, proto.CachingSHA2Password("mockx", salt)))
+
+		// Full authentication passed, then cached.
+		err = auth.Authenticate(account, "mock")
+		assert.Nil(t, err)
+		assert.True(t, auth.FastAuthenticate(account, salt, scramble))
+		assert.False(t, auth.FastAuthenticate(account, salt, proto.CachingSHA2Password("mockx", salt)))
+		assert.False(t, auth.FastAuthenticate(account, salt, nil))
+
+		// Password changed.
+		changed := &Account{User: "mock", Plugin: NativePassword, AuthenticationString: NativePasswordHash("mock1")}
+		assert.False(t, auth.FastAuthenticate(changed, salt, scramble))
+	}
+
+	// RSA.
+	{
+		data, err := auth.PublicKey()
+		assert.Nil(t, err)
+		block, _ := pem.Decode(data)
+		pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+		assert.Nil(t, err)
+
+		plain := append([]byte("mock"), 0)
+		for i := range plain {
+			plain[i] ^= salt[i%len(salt)]
+		}
+		encrypted, err := rsa.EncryptOAEP(sha1.New(), rand.Reader, pub.(*rsa.PublicKey), plain, nil)
+		assert.Nil(t, err)
+
+		password, err := auth.DecryptPassword(encrypted, salt)
+		assert.Nil(t, err)
+		assert.Equal(t, "mock", password)
+
+		_, err = auth.DecryptPassword([]byte("xx"), salt)
+		assert.NotNil(t, err)
+		_, err = auth.DecryptPassword(encrypted, nil)
+		assert.NotNil(t, err)
+	}
+}
+
+func TestAuthenticationRSAPrivateKey(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "authentication_")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	assert.Nil(t, err)
+
+	// PKCS1 and PKCS8.
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.Nil(t, err)
+	blocks := []*pem.Block{
+		{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)},
+		{Type: "PRIVATE KEY", Bytes: pkcs8},
+	}
+	for i, block := range blocks {
+		file := path.Join(tmpDir, "key.pem")
+		err := ioutil.WriteFile(file, pem.EncodeToMemory(block), 0600)
+		assert.Nil(t, err)
+
+		conf := &config.Config{Auth: config.DefaultAuthConfig()}
+		conf.Auth.RSAPrivateKey = file
+		auth := NewAuthentication(log, conf)
+		data, err := auth.PublicKey()
+		assert.Nil(t, err, "%d", i)
+
+		der, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+		assert.Equal(t, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), data)
+	}
+
+	// Error.
+	{
+		conf := &config.Config{Auth: config.DefaultAuthConfig()}
+		conf.Auth.RSAPrivateKey = path.Join(tmpDir, "not.exists.pem")
+		auth := NewAuthentication(log, conf)
+		_, err := auth.PublicKey()
+		assert.NotNil(t, err)
+		_, err = auth.DecryptPassword([]byte("xx"), []byte("salt"))
+		assert.NotNil(t, err)
+
+		file := path.Join(tmpDir, "invalid.pem")
+		ioutil.WriteFile(file, []byte("invalid"), 0600)
+		_, err = loadPrivateKey(file)
+		assert.NotNil(t, err)
+	}
+}
+
+func TestAuthenticationInitError(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+
+	for _, name := range []string{FileAuthenticator, LDAPAuthenticator} {
+		conf := &config.Config{Auth: config.DefaultAuthConfig()}
+		conf.Auth.Authenticator = name
+		conf.Auth.File = "/tmp/radon.not.exists.users"
+		auth := NewAuthentication(log, conf)
+		err := auth.Init()
+		assert.NotNil(t, err)
+	}
+
+	// Nil config.
+	{
+		auth := NewAuthentication(log, nil)
+		err := auth.Init()
+		assert.Nil(t, err)
+	}
+}
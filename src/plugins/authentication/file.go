@@ -0,0 +1,137 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package authentication
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+// fileUser tuple, the plugin is inferred from the authentication-string if it's empty.
+type fileUser struct {
+	User                 string `json:"user"`
+	Plugin               string `json:"plugin,omitempty"`
+	AuthenticationString string `json:"authentication-string"`
+}
+
+// fileUsers tuple.
+type fileUsers struct {
+	Users []fileUser `json:"users"`
+}
+
+// watchedFile tuple, the file is reloaded if it's modified.
+type watchedFile struct {
+	mu      sync.Mutex
+	path    string
+	modTime time.Time
+	parse   func(data []byte) error
+}
+
+// load used to reload the file if the modification time changed.
+func (w *watchedFile) load() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if info.ModTime().Equal(w.modTime) {
+		return nil
+	}
+	data, err := ioutil.ReadFile(w.path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := w.parse(data); err != nil {
+		return err
+	}
+	w.modTime = info.ModTime()
+	return nil
+}
+
+// File tuple, the users are stored in the static JSON file:
+// {"users": [{"user": "u1", "authentication-string": "*6BB4837EB74329105EE4568DDA7DC67ED2CA2AD9"}]}
+type File struct {
+	mu       sync.RWMutex
+	log      *xlog.Log
+	file     *watchedFile
+	accounts map[string]*Account
+}
+
+// NewFile -- creates new File authenticator.
+func NewFile(log *xlog.Log, path string) *File {
+	f := &File{
+		log:      log,
+		accounts: make(map[string]*Account),
+	}
+	f.file = &watchedFile{path: path, parse: f.parse}
+	return f
+}
+
+func (f *File) parse(data []byte) error {
+	users := &fileUsers{}
+	if err := json.Unmarshal(data, users); err != nil {
+		return errors.WithStack(err)
+	}
+
+	accounts := make(map[string]*Account, len(users.Users))
+	for _, u := range users.Users {
+		plugin := u.Plugin
+		if plugin == "" {
+			plugin = AccountPlugin(u.AuthenticationString)
+		}
+		if plugin != NativePassword && plugin != CachingSHA2Password {
+			return errors.Errorf("file.user[%s].unsupported.plugin[%s]", u.User, plugin)
+		}
+		accounts[u.User] = &Account{User: u.User, Plugin: plugin, AuthenticationString: u.AuthenticationString}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.accounts = accounts
+	return nil
+}
+
+// Init -- load the users file.
+func (f *File) Init() error {
+	return f.file.load()
+}
+
+// Lookup returns the account of the user, the file is reloaded if it's modified.
+func (f *File) Lookup(user string) (*Account, error) {
+	if err := f.file.load(); err != nil {
+		// Keep using the last loaded users.
+		f.log.Error("plugin.authentication.file.reload.error:%+v", err)
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	account, ok := f.accounts[user]
+	if !ok {
+		return nil, errors.Errorf("user[%s].not.exists", user)
+	}
+	return account, nil
+}
+
+// Authenticate used to check the clear password with the authentication string.
+func (f *File) Authenticate(account *Account, password string) error {
+	return CheckPassword(account.Plugin, account.AuthenticationString, password)
+}
+
+// Close -- do nothing.
+func (f *File) Close() error {
+	return nil
+}
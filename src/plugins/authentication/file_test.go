@@ -0,0 +1,88 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package authentication
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func writeWatchedFile(t *testing.T, file string, data string, modTime time.Time) {
+	err := ioutil.WriteFile(file, []byte(data), 0600)
+	assert.Nil(t, err)
+	err = os.Chtimes(file, modTime, modTime)
+	assert.Nil(t, err)
+}
+
+func TestFile(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "authentication_")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	sha2 := CachingSHA2PasswordHash("sha2", []byte("abcdefghijabcdefghij"), 5000)
+	file := path.Join(tmpDir, "users.json")
+	now := time.Now()
+	writeWatchedFile(t, file, fmt.Sprintf(`{"users":[{"user":"u1","authentication-string":"%s"},{"user":"u2","authentication-string":"%s"}]}`, NativePasswordHash("u1"), sha2), now)
+
+	f := NewFile(log, file)
+	err = f.Init()
+	assert.Nil(t, err)
+	defer f.Close()
+
+	{
+		account, err := f.Lookup("u1")
+		assert.Nil(t, err)
+		assert.Equal(t, NativePassword, account.Plugin)
+		assert.Nil(t, f.Authenticate(account, "u1"))
+		assert.NotNil(t, f.Authenticate(account, "u2"))
+
+		account, err = f.Lookup("u2")
+		assert.Nil(t, err)
+		assert.Equal(t, CachingSHA2Password, account.Plugin)
+		assert.Nil(t, f.Authenticate(account, "sha2"))
+		assert.NotNil(t, f.Authenticate(account, "u2"))
+
+		_, err = f.Lookup("u3")
+		assert.NotNil(t, err)
+	}
+
+	// Reload.
+	{
+		writeWatchedFile(t, file, fmt.Sprintf(`{"users":[{"user":"u3","plugin":"mysql_native_password","authentication-string":"%s"}]}`, NativePasswordHash("u3")), now.Add(time.Second))
+		account, err := f.Lookup("u3")
+		assert.Nil(t, err)
+		assert.Nil(t, f.Authenticate(account, "u3"))
+
+		_, err = f.Lookup("u1")
+		assert.NotNil(t, err)
+	}
+
+	// Reload error, keep the last users.
+	{
+		writeWatchedFile(t, file, `{"users":[{"user":"u4","plugin":"sha256_password"}]}`, now.Add(2*time.Second))
+		_, err := f.Lookup("u3")
+		assert.Nil(t, err)
+
+		writeWatchedFile(t, file, `{"users":`, now.Add(3*time.Second))
+		_, err = f.Lookup("u3")
+		assert.Nil(t, err)
+
+		os.Remove(file)
+		_, err = f.Lookup("u3")
+		assert.Nil(t, err)
+	}
+}
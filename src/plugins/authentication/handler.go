@@ -0,0 +1,38 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package authentication
+
+// Account tuple, the credential of the user.
+// The Plugin is the format of the AuthenticationString: mysql_native_password or caching_sha2_password,
+// empty if the authenticator only verifies the clear password, e.g. the LDAP.
+type Account struct {
+	User                 string
+	Plugin               string
+	AuthenticationString string
+}
+
+// Authenticator interface, the store of the user accounts.
+type Authenticator interface {
+	Init() error
+	Lookup(user string) (*Account, error)
+	Authenticate(account *Account, password string) error
+	Close() error
+}
+
+// AuthenticationHandler interface.
+type AuthenticationHandler interface {
+	Init() error
+	Register(name string, authenticator Authenticator)
+	Lookup(user string) (*Account, error)
+	Authenticate(account *Account, password string) error
+	FastAuthenticate(account *Account, salt []byte, scramble []byte) bool
+	PublicKey() ([]byte, error)
+	DecryptPassword(data []byte, salt []byte) (string, error)
+	Close() error
+}
@@ -0,0 +1,193 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package authentication
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+// LDAP tuple, a local stand-in of the LDAP simple bind.
+// The directory is a LDIF file, the user entry is found by the DN 'uid=<user>,<baseDN>':
+//
+// dn: uid=alice,ou=people,dc=radon,dc=io
+// uid: alice
+// userPassword: {SSHA}...
+//
+// The userPassword supports the {SHA}, {SSHA}, {CLEARTEXT} schemes, no scheme is the clear text.
+// The password is never stored as the MySQL hash, so only the clear password can be verified,
+// the client must use the caching_sha2_password full authentication.
+type LDAP struct {
+	mu      sync.RWMutex
+	log     *xlog.Log
+	baseDN  string
+	file    *watchedFile
+	entries map[string]string
+}
+
+// NewLDAP -- creates new LDAP authenticator.
+func NewLDAP(log *xlog.Log, path string, baseDN string) *LDAP {
+	l := &LDAP{
+		log:     log,
+		baseDN:  baseDN,
+		entries: make(map[string]string),
+	}
+	l.file = &watchedFile{path: path, parse: l.parse}
+	return l
+}
+
+// normalizeDN used to compare the DN case-insensitive and without the spaces around the RDNs.
+func normalizeDN(dn string) string {
+	rdns := strings.Split(dn, ",")
+	for i := range rdns {
+		rdns[i] = strings.ToLower(strings.TrimSpace(rdns[i]))
+	}
+	return strings.Join(rdns, ",")
+}
+
+// parse used to parse the LDIF, only the dn and userPassword attributes are used.
+func (l *LDAP) parse(data []byte) error {
+	entries := make(map[string]string)
+	var dn, password string
+	flush := func() {
+		if dn != "" {
+			entries[normalizeDN(dn)] = password
+		}
+		dn, password = "", ""
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			flush()
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.Index(line, ":")
+		if i < 0 {
+			return errors.Errorf("ldap.invalid.line[%s]", line)
+		}
+		attr, value := strings.ToLower(strings.TrimSpace(line[:i])), line[i+1:]
+		// 'attr:: value' is base64 encoded.
+		if strings.HasPrefix(value, ":") {
+			decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value[1:]))
+			if err != nil {
+				return errors.Errorf("ldap.invalid.base64[%s]", line)
+			}
+			value = string(decoded)
+		} else {
+			value = strings.TrimSpace(value)
+		}
+		switch attr {
+		case "dn":
+			flush()
+			dn = value
+		case "userpassword":
+			password = value
+		}
+	}
+	flush()
+	if err := scanner.Err(); err != nil {
+		return errors.WithStack(err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = entries
+	return nil
+}
+
+// userDN returns the DN of the user entry.
+func (l *LDAP) userDN(user string) string {
+	if l.baseDN == "" {
+		return fmt.Sprintf("uid=%s", user)
+	}
+	return fmt.Sprintf("uid=%s,%s", user, l.baseDN)
+}
+
+// Init -- load the directory.
+func (l *LDAP) Init() error {
+	return l.file.load()
+}
+
+// Lookup returns the account of the user entry, the plugin is empty.
+func (l *LDAP) Lookup(user string) (*Account, error) {
+	if err := l.file.load(); err != nil {
+		// Keep using the last loaded directory.
+		l.log.Error("plugin.authentication.ldap.reload.error:%+v", err)
+	}
+
+	dn := l.userDN(user)
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	password, ok := l.entries[normalizeDN(dn)]
+	if !ok {
+		return nil, errors.Errorf("ldap.entry[%s].not.exists", dn)
+	}
+	return &Account{User: user, AuthenticationString: password}, nil
+}
+
+// Authenticate used to bind the user entry with the password.
+func (l *LDAP) Authenticate(account *Account, password string) error {
+	// The unauthenticated bind isn't allowed.
+	if password == "" {
+		return errors.Errorf("ldap.bind[%s].empty.password", l.userDN(account.User))
+	}
+	if !checkLDAPPassword(account.AuthenticationString, password) {
+		return errors.Errorf("ldap.bind[%s].invalid.credentials", l.userDN(account.User))
+	}
+	return nil
+}
+
+// checkLDAPPassword used to check the password with the userPassword value.
+func checkLDAPPassword(userPassword string, password string) bool {
+	scheme, value := "", userPassword
+	if strings.HasPrefix(userPassword, "{") {
+		if i := strings.Index(userPassword, "}"); i > 0 {
+			scheme, value = strings.ToUpper(userPassword[1:i]), userPassword[i+1:]
+		}
+	}
+
+	switch scheme {
+	case "", "CLEARTEXT":
+		return subtle.ConstantTimeCompare([]byte(value), []byte(password)) == 1
+	case "SHA", "SSHA":
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil || len(decoded) < sha1.Size {
+			return false
+		}
+		// {SSHA} is SHA1(password + salt) + salt.
+		digest, salt := decoded[:sha1.Size], decoded[sha1.Size:]
+		if scheme == "SHA" && len(salt) > 0 {
+			return false
+		}
+		crypt := sha1.New()
+		crypt.Write([]byte(password))
+		crypt.Write(salt)
+		return subtle.ConstantTimeCompare(digest, crypt.Sum(nil)) == 1
+	}
+	return false
+}
+
+// Close -- do nothing.
+func (l *LDAP) Close() error {
+	return nil
+}
@@ -0,0 +1,110 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package authentication
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func ldapSHA(password string, salt string) string {
+	crypt := sha1.New()
+	crypt.Write([]byte(password))
+	crypt.Write([]byte(salt))
+	return base64.StdEncoding.EncodeToString(append(crypt.Sum(nil), salt...))
+}
+
+func TestLDAP(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "authentication_")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	ldif := fmt.Sprintf(`# people
+dn: uid=alice,ou=people,dc=radon,dc=io
+uid: alice
+userPassword: {SSHA}%s
+
+dn: UID=bob, OU=people, DC=radon, DC=io
+userPassword: {SHA}%s
+
+dn: uid=carol,ou=people,dc=radon,dc=io
+userPassword:: %s
+
+dn: uid=dave,ou=people,dc=radon,dc=io
+userPassword: {CLEARTEXT}dave
+`, ldapSHA("alice", "salt"), ldapSHA("bob", ""), base64.StdEncoding.EncodeToString([]byte("carol")))
+
+	file := path.Join(tmpDir, "users.ldif")
+	now := time.Now()
+	writeWatchedFile(t, file, ldif, now)
+
+	l := NewLDAP(log, file, "ou=people,dc=radon,dc=io")
+	err = l.Init()
+	assert.Nil(t, err)
+	defer l.Close()
+
+	tests := []struct {
+		user     string
+		password string
+	}{
+		{"alice", "alice"},
+		{"bob", "bob"},
+		{"carol", "carol"},
+		{"dave", "dave"},
+	}
+	for _, test := range tests {
+		account, err := l.Lookup(test.user)
+		assert.Nil(t, err)
+		assert.Equal(t, "", account.Plugin)
+		assert.Nil(t, l.Authenticate(account, test.password), test.user)
+		assert.NotNil(t, l.Authenticate(account, test.password+"x"), test.user)
+		assert.NotNil(t, l.Authenticate(account, ""), test.user)
+	}
+
+	{
+		_, err := l.Lookup("eve")
+		assert.NotNil(t, err)
+		assert.False(t, checkLDAPPassword("{SSHA}xx", "xx"))
+		assert.False(t, checkLDAPPassword("{SHA}"+ldapSHA("bob", "salt"), "bob"))
+		assert.False(t, checkLDAPPassword("{MD5}xx", "xx"))
+	}
+
+	// Reload.
+	{
+		writeWatchedFile(t, file, "dn: uid=eve\nuserPassword: eve\n", now.Add(time.Second))
+		_, err := l.Lookup("alice")
+		assert.NotNil(t, err)
+
+		nobase := NewLDAP(log, file, "")
+		account, err := nobase.Lookup("eve")
+		assert.Nil(t, err)
+		assert.Nil(t, nobase.Authenticate(account, "eve"))
+	}
+
+	// Parse error.
+	{
+		writeWatchedFile(t, file, "dn uid=eve\n", now.Add(2*time.Second))
+		err := l.Init()
+		assert.NotNil(t, err)
+
+		writeWatchedFile(t, file, "dn: uid=eve\nuserPassword:: !!\n", now.Add(3*time.Second))
+		err = l.Init()
+		assert.NotNil(t, err)
+	}
+}
@@ -0,0 +1,38 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package authentication
+
+import (
+	"github.com/pkg/errors"
+)
+
+// mockAuthenticator tuple.
+type mockAuthenticator struct {
+	accounts map[string]*Account
+}
+
+func (m *mockAuthenticator) Init() error {
+	return nil
+}
+
+func (m *mockAuthenticator) Lookup(user string) (*Account, error) {
+	account, ok := m.accounts[user]
+	if !ok {
+		return nil, errors.Errorf("user[%s].not.exists", user)
+	}
+	return account, nil
+}
+
+func (m *mockAuthenticator) Authenticate(account *Account, password string) error {
+	return CheckPassword(account.Plugin, account.AuthenticationString, password)
+}
+
+func (m *mockAuthenticator) Close() error {
+	return nil
+}
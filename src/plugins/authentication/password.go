@@ -0,0 +1,265 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package authentication
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// NativePassword is the mysql_native_password plugin.
+	NativePassword = "mysql_native_password"
+	// CachingSHA2Password is the caching_sha2_password plugin.
+	CachingSHA2Password = "caching_sha2_password"
+
+	// The caching_sha2_password authentication string: '$A$' + rounds(3) + '$' + salt(20) + digest(43).
+	cachingSHA2Prefix     = "$A$"
+	cachingSHA2SaltLen    = 20
+	cachingSHA2DigestLen  = 43
+	cachingSHA2RoundsUnit = 1000
+	cachingSHA2Rounds     = 5000
+
+	// saltChars are the salt characters of the generated authentication string, without the '$' and NUL.
+	saltChars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789./"
+)
+
+// AccountPlugin returns the plugin of the authentication string as the mysql.user.
+func AccountPlugin(authString string) string {
+	if strings.HasPrefix(authString, cachingSHA2Prefix) {
+		return CachingSHA2Password
+	}
+	return NativePassword
+}
+
+// NativePasswordHash returns the mysql_native_password authentication string:
+// '*' + HEX(SHA1(SHA1(password))), empty if the password is empty.
+func NativePasswordHash(password string) string {
+	if password == "" {
+		return ""
+	}
+	stage1 := sha1.Sum([]byte(password))
+	stage2 := sha1.Sum(stage1[:])
+	return "*" + strings.ToUpper(hex.EncodeToString(stage2[:]))
+}
+
+// CachingSHA2PasswordHash returns the caching_sha2_password authentication string with the salt,
+// the salt must be 20 bytes.
+func CachingSHA2PasswordHash(password string, salt []byte, rounds int) string {
+	if password == "" {
+		return ""
+	}
+	// The rounds is stored in hex as MySQL.
+	return fmt.Sprintf("%s%03X$%s%s", cachingSHA2Prefix, rounds/cachingSHA2RoundsUnit, salt, sha256Crypt([]byte(password), salt, rounds))
+}
+
+// NewAuthenticationString returns the authentication string of the password for the plugin,
+// the caching_sha2_password uses a random salt.
+func NewAuthenticationString(plugin string, password string) (string, error) {
+	switch plugin {
+	case "", NativePassword:
+		return NativePasswordHash(password), nil
+	case CachingSHA2Password:
+		salt := make([]byte, cachingSHA2SaltLen)
+		if _, err := rand.Read(salt); err != nil {
+			return "", errors.WithStack(err)
+		}
+		for i := range salt {
+			salt[i] = saltChars[int(salt[i])%len(saltChars)]
+		}
+		return CachingSHA2PasswordHash(password, salt, cachingSHA2Rounds), nil
+	}
+	return "", errors.Errorf("unsupported.authentication.plugin[%s]", plugin)
+}
+
+// CheckNativeScramble used to check the mysql_native_password scramble with the authentication string.
+func CheckNativeScramble(authString string, salt []byte, scramble []byte) bool {
+	if authString == "" {
+		return len(scramble) == 0
+	}
+
+	// mysql.user.authentication_string is ['*' + HEX(SHA1(SHA1(password)))]
+	wantStage2, err := hex.DecodeString(strings.TrimPrefix(authString, "*"))
+	if err != nil || len(scramble) != sha1.Size {
+		return false
+	}
+
+	// want = SHA1(salt <concat> SHA1(SHA1(password)))
+	crypt := sha1.New()
+	crypt.Write(salt)
+	crypt.Write(wantStage2)
+	want := crypt.Sum(nil)
+
+	// gotStage1 = SHA1(password) = (scramble XOR want)
+	gotStage1 := make([]byte, sha1.Size)
+	for i := range scramble {
+		gotStage1[i] = scramble[i] ^ want[i]
+	}
+
+	// gotStage2 = SHA1(SHA1(password))
+	gotStage2 := sha1.Sum(gotStage1)
+	return bytes.Equal(wantStage2, gotStage2[:])
+}
+
+// CheckPassword used to check the clear password with the authentication string.
+func CheckPassword(plugin string, authString string, password string) error {
+	if authString == "" {
+		if password != "" {
+			return errors.New("password.mismatch")
+		}
+		return nil
+	}
+
+	switch plugin {
+	case NativePassword:
+		if subtle.ConstantTimeCompare([]byte(strings.ToUpper(authString)), []byte(NativePasswordHash(password))) != 1 {
+			return errors.New("password.mismatch")
+		}
+		return nil
+	case CachingSHA2Password:
+		salt, rounds, digest, err := parseCachingSHA2(authString)
+		if err != nil {
+			return err
+		}
+		if subtle.ConstantTimeCompare([]byte(digest), []byte(sha256Crypt([]byte(password), salt, rounds))) != 1 {
+			return errors.New("password.mismatch")
+		}
+		return nil
+	}
+	return errors.Errorf("unsupported.plugin[%s]", plugin)
+}
+
+// parseCachingSHA2 used to parse the caching_sha2_password authentication string.
+func parseCachingSHA2(authString string) ([]byte, int, string, error) {
+	// $A$005$<salt><digest>
+	prefixLen := len(cachingSHA2Prefix) + 4
+	if len(authString) != prefixLen+cachingSHA2SaltLen+cachingSHA2DigestLen || authString[prefixLen-1] != '$' {
+		return nil, 0, "", errors.Errorf("invalid.caching.sha2.password.authentication.string")
+	}
+	rounds, err := strconv.ParseInt(authString[len(cachingSHA2Prefix):prefixLen-1], 16, 32)
+	if err != nil || rounds <= 0 {
+		return nil, 0, "", errors.Errorf("invalid.caching.sha2.password.rounds")
+	}
+	salt := []byte(authString[prefixLen : prefixLen+cachingSHA2SaltLen])
+	return salt, int(rounds) * cachingSHA2RoundsUnit, authString[prefixLen+cachingSHA2SaltLen:], nil
+}
+
+// sha256Crypt is the SHA-256 based crypt of Ulrich Drepper without the salt length limit as MySQL,
+// returns the 43 bytes digest.
+// https://www.akkadia.org/drepper/SHA-crypt.txt
+func sha256Crypt(password []byte, salt []byte, rounds int) string {
+	pLen := len(password)
+
+	// Digest B = SHA256(password, salt, password).
+	b := sha256.New()
+	b.Write(password)
+	b.Write(salt)
+	b.Write(password)
+	digestB := b.Sum(nil)
+
+	// Digest A.
+	a := sha256.New()
+	a.Write(password)
+	a.Write(salt)
+	for i := pLen; i > 0; i -= 32 {
+		if i > 32 {
+			a.Write(digestB)
+		} else {
+			a.Write(digestB[:i])
+		}
+	}
+	for i := pLen; i > 0; i >>= 1 {
+		if i&1 != 0 {
+			a.Write(digestB)
+		} else {
+			a.Write(password)
+		}
+	}
+	digestA := a.Sum(nil)
+
+	// Sequence P.
+	dp := sha256.New()
+	for i := 0; i < pLen; i++ {
+		dp.Write(password)
+	}
+	seqP := repeatBytes(dp.Sum(nil), pLen)
+
+	// Sequence S.
+	ds := sha256.New()
+	for i := 0; i < 16+int(digestA[0]); i++ {
+		ds.Write(salt)
+	}
+	seqS := repeatBytes(ds.Sum(nil), len(salt))
+
+	digestC := digestA
+	for i := 0; i < rounds; i++ {
+		c := sha256.New()
+		if i&1 != 0 {
+			c.Write(seqP)
+		} else {
+			c.Write(digestC)
+		}
+		if i%3 != 0 {
+			c.Write(seqS)
+		}
+		if i%7 != 0 {
+			c.Write(seqP)
+		}
+		if i&1 != 0 {
+			c.Write(digestC)
+		} else {
+			c.Write(seqP)
+		}
+		digestC = c.Sum(nil)
+	}
+
+	// The custom base64 with the permuted bytes.
+	const alphabet = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	var out []byte
+	b64 := func(b2, b1, b0 byte, n int) {
+		w := uint(b2)<<16 | uint(b1)<<8 | uint(b0)
+		for ; n > 0; n-- {
+			out = append(out, alphabet[w&0x3f])
+			w >>= 6
+		}
+	}
+	for i := 0; i < 10; i++ {
+		j, k, l := i, i+10, i+20
+		switch i % 3 {
+		case 1:
+			j, k, l = i+20, i, i+10
+		case 2:
+			j, k, l = i+10, i+20, i
+		}
+		b64(digestC[j], digestC[k], digestC[l], 4)
+	}
+	b64(0, digestC[31], digestC[30], 3)
+	return string(out)
+}
+
+func repeatBytes(digest []byte, n int) []byte {
+	seq := make([]byte, 0, n)
+	for len(seq) < n {
+		if n-len(seq) >= len(digest) {
+			seq = append(seq, digest...)
+		} else {
+			seq = append(seq, digest[:n-len(seq)]...)
+		}
+	}
+	return seq
+}
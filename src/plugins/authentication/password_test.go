@@ -0,0 +1,83 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package authentication
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/proto"
+)
+
+func TestSHA256Crypt(t *testing.T) {
+	// The test vectors of the SHA-crypt.
+	assert.Equal(t, "5B8vYYiY.CVt1RlTTf8KbXBH3hsxY/GNooZaBBGWEc5", sha256Crypt([]byte("Hello world!"), []byte("saltstring"), 5000))
+	assert.Equal(t, "3xv.VbSHBb41AL9AvLeujZkZRBAwqFMz2.opqey6IcA", sha256Crypt([]byte("Hello world!"), []byte("saltstringsaltst"), 10000))
+}
+
+func TestNativePassword(t *testing.T) {
+	salt := []byte("01234567890123456789")
+	authString := NativePasswordHash("mock")
+	assert.Equal(t, "*CC86C0D547DE7603129BC1D3B98DB2242E7F744F", authString)
+	assert.Equal(t, NativePassword, AccountPlugin(authString))
+
+	// Scramble.
+	{
+		assert.True(t, CheckNativeScramble(authString, salt, proto.ScramblePassword(NativePassword, "mock", salt)))
+		assert.False(t, CheckNativeScramble(authString, salt, proto.ScramblePassword(NativePassword, "mockx", salt)))
+		assert.False(t, CheckNativeScramble(authString, salt, nil))
+		assert.False(t, CheckNativeScramble("*xx", salt, proto.ScramblePassword(NativePassword, "mock", salt)))
+
+		// Empty password.
+		assert.True(t, CheckNativeScramble("", salt, nil))
+		assert.False(t, CheckNativeScramble("", salt, proto.ScramblePassword(NativePassword, "mock", salt)))
+	}
+
+	// Clear password.
+	{
+		assert.Nil(t, CheckPassword(NativePassword, authString, "mock"))
+		assert.Nil(t, CheckPassword(NativePassword, "*cc86c0d547de7603129bc1d3b98db2242e7f744f", "mock"))
+		assert.NotNil(t, CheckPassword(NativePassword, authString, "mockx"))
+		assert.Nil(t, CheckPassword(NativePassword, "", ""))
+		assert.NotNil(t, CheckPassword(NativePassword, "", "mock"))
+		assert.NotNil(t, CheckPassword("sha256_password", authString, "mock"))
+	}
+}
+
+func TestCachingSHA2Password(t *testing.T) {
+	salt := []byte("abcdefghijabcdefghij")
+	authString := CachingSHA2PasswordHash("mock", salt, 5000)
+	assert.Equal(t, "$A$005$abcdefghijabcdefghij", authString[:27])
+	assert.Equal(t, CachingSHA2Password, AccountPlugin(authString))
+
+	assert.Nil(t, CheckPassword(CachingSHA2Password, authString, "mock"))
+	assert.NotNil(t, CheckPassword(CachingSHA2Password, authString, "mockx"))
+	assert.Equal(t, "", CachingSHA2PasswordHash("", salt, 5000))
+
+	// Invalid authentication string.
+	{
+		assert.NotNil(t, CheckPassword(CachingSHA2Password, "$A$005$abc", "mock"))
+		assert.NotNil(t, CheckPassword(CachingSHA2Password, "$A$0x5"+authString[6:], "mock"))
+		assert.NotNil(t, CheckPassword(CachingSHA2Password, "$A$000"+authString[6:], "mock"))
+	}
+}
+
+func TestNewAuthenticationString(t *testing.T) {
+	authString, err := NewAuthenticationString("", "mock")
+	assert.Nil(t, err)
+	assert.Equal(t, NativePasswordHash("mock"), authString)
+
+	authString, err = NewAuthenticationString(CachingSHA2Password, "mock")
+	assert.Nil(t, err)
+	assert.Equal(t, CachingSHA2Password, AccountPlugin(authString))
+	assert.Nil(t, CheckPassword(CachingSHA2Password, authString, "mock"))
+
+	_, err = NewAuthenticationString("sha256_password", "mock")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,152 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package encryption
+
+import (
+	"strings"
+
+	"config"
+	"planner/builder"
+	"router"
+
+	"github.com/pkg/errors"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+const (
+	// ModeDeterministic encrypts the same plaintext to the same value.
+	ModeDeterministic = "DETERMINISTIC"
+
+	// ModeRandomized encrypts the plaintext with the random nonce.
+	ModeRandomized = "RANDOMIZED"
+)
+
+// column tuple, the encrypted column.
+type column struct {
+	name          string
+	key           string
+	deterministic bool
+}
+
+// Encryption tuple, encrypts the designated columns before the statement reaches the backends,
+// and decrypts the values of the result.
+type Encryption struct {
+	log     *xlog.Log
+	conf    *config.EncryptionConfig
+	router  *router.Router
+	keyring *Keyring
+	// The encrypted columns of the tables, the key is 'database.table'.
+	columns map[string]map[string]*column
+	// The modes of the encrypted columns(lower case), the key is 'database.table'.
+	modes map[string]map[string]string
+}
+
+// NewEncryption creates the new encryption.
+func NewEncryption(log *xlog.Log, conf *config.Config, router *router.Router) EncryptionHandler {
+	encConf := config.DefaultEncryptionConfig()
+	if conf != nil && conf.Encryption != nil {
+		encConf = conf.Encryption
+	}
+	return &Encryption{
+		log:     log,
+		conf:    encConf,
+		router:  router,
+		columns: make(map[string]map[string]*column),
+		modes:   make(map[string]map[string]string),
+	}
+}
+
+// Init -- loads the keyring and checks the encrypted columns.
+func (e *Encryption) Init() error {
+	keyring, err := LoadKeyring(e.conf.Keyring)
+	if err != nil {
+		e.log.Error("plugin.encryption.load.keyring[%v].error:%v", e.conf.Keyring, err)
+		return err
+	}
+	e.keyring = keyring
+
+	for _, c := range e.conf.Columns {
+		if c.Database == "" || c.Table == "" || c.Column == "" {
+			return errors.Errorf("encryption.column[%s.%s.%s].database.table.column.can't.be.empty", c.Database, c.Table, c.Column)
+		}
+		name := strings.Join([]string{c.Database, c.Table, c.Column}, ".")
+		if _, ok := keyring.keys[c.Key]; !ok {
+			return errors.Errorf("encryption.column[%s].key[%s].not.found", name, c.Key)
+		}
+		mode := strings.ToUpper(c.Mode)
+		if mode != ModeDeterministic && mode != ModeRandomized {
+			return errors.Errorf("encryption.column[%s].mode[%s].invalid", name, c.Mode)
+		}
+		table := c.Database + "." + c.Table
+		if e.columns[table] == nil {
+			e.columns[table] = make(map[string]*column)
+		}
+		col := strings.ToLower(c.Column)
+		if _, ok := e.columns[table][col]; ok {
+			return errors.Errorf("encryption.column[%s].duplicate", name)
+		}
+		e.columns[table][col] = &column{name: c.Column, key: c.Key, deterministic: mode == ModeDeterministic}
+		if e.modes[table] == nil {
+			e.modes[table] = make(map[string]string)
+		}
+		e.modes[table][col] = mode
+	}
+	e.log.Info("plugin.encryption.init.done, keys:%d, tables:%d", len(keyring.keys), len(e.columns))
+	return nil
+}
+
+// Close -- do nothing.
+func (e *Encryption) Close() error {
+	return nil
+}
+
+// Enabled returns true if there're encrypted columns.
+func (e *Encryption) Enabled() bool {
+	return len(e.columns) > 0
+}
+
+// Encrypt used to encrypt the values of the encrypted columns in the statement in place.
+func (e *Encryption) Encrypt(database string, node sqlparser.Statement) error {
+	if len(e.columns) == 0 {
+		return nil
+	}
+	switch node := node.(type) {
+	case *sqlparser.Insert:
+		return e.encryptInsert(database, node)
+	case *sqlparser.Update:
+		return e.encryptUpdate(database, node)
+	case *sqlparser.Delete:
+		return e.encryptDelete(database, node)
+	case sqlparser.SelectStatement:
+		return e.encryptSelect(database, node)
+	}
+	return nil
+}
+
+// Decrypter returns the decrypter, nil if no column is encrypted.
+func (e *Encryption) Decrypter() builder.Decrypter {
+	if !e.Enabled() {
+		return nil
+	}
+	return e
+}
+
+// Columns returns the encrypted columns(lower case) of the table and their modes.
+func (e *Encryption) Columns(database string, table string) map[string]string {
+	return e.modes[database+"."+table]
+}
+
+// Decrypt used to decrypt the value of the encrypted column, the value which is not encrypted is returned as is.
+func (e *Encryption) Decrypt(value []byte) ([]byte, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	return e.keyring.Decrypt(value)
+}
@@ -0,0 +1,121 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package encryption
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"config"
+	"router"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+// mockEncryption creates the encryption on the sbtest.A and sbtest.B, the A.id is the shard key.
+func mockEncryption(t *testing.T, log *xlog.Log, dir string, columns []*config.EncryptedColumnConfig) (*Encryption, func()) {
+	route, cleanup := router.MockNewRouter(log)
+	err := route.CreateDatabase("sbtest")
+	assert.Nil(t, err)
+	err = route.AddForTest("sbtest", router.MockTableAConfig(), router.MockTableBConfig())
+	assert.Nil(t, err)
+
+	conf := &config.Config{Encryption: &config.EncryptionConfig{Keyring: mockKeyring(t, dir), Columns: columns}}
+	e := NewEncryption(log, conf, route)
+	err = e.Init()
+	assert.Nil(t, err)
+	return e.(*Encryption), cleanup
+}
+
+func TestEncryption(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	dir, err := ioutil.TempDir(os.TempDir(), "encryption_")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	e, cleanup := mockEncryption(t, log, dir, []*config.EncryptedColumnConfig{
+		{Database: "sbtest", Table: "A", Column: "Phone", Key: "k1", Mode: "deterministic"},
+	})
+	defer cleanup()
+	assert.True(t, e.Enabled())
+	assert.NotNil(t, e.Decrypter())
+	assert.Nil(t, e.Close())
+
+	assert.Equal(t, map[string]string{"phone": ModeDeterministic}, e.Columns("sbtest", "A"))
+	assert.Nil(t, e.Columns("sbtest", "B"))
+
+	phone, err := e.keyring.Encrypt("k1", []byte("13812345678"), true)
+	assert.Nil(t, err)
+	plain, err := e.Decrypt(phone)
+	assert.Nil(t, err)
+	assert.Equal(t, "13812345678", string(plain))
+
+	// Not encrypted.
+	plain, err = e.Decrypt([]byte("13812345678"))
+	assert.Nil(t, err)
+	assert.Equal(t, "13812345678", string(plain))
+
+	// Decrypt error.
+	_, err = e.Decrypt([]byte("RDNENC:k3:xx"))
+	assert.Equal(t, "encryption.key[k3].not.found", err.Error())
+}
+
+func TestEncryptionDisabled(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	e := NewEncryption(log, nil, nil)
+	err := e.Init()
+	assert.Nil(t, err)
+	assert.False(t, e.Enabled())
+	assert.Nil(t, e.Decrypter())
+	assert.Nil(t, e.Encrypt("sbtest", nil))
+}
+
+func TestEncryptionInitError(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	dir, err := ioutil.TempDir(os.TempDir(), "encryption_")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	keyring := mockKeyring(t, dir)
+
+	tcases := []struct {
+		conf *config.EncryptionConfig
+		err  string
+	}{
+		{
+			&config.EncryptionConfig{Keyring: "/radon/none/keyring.json"},
+			"open /radon/none/keyring.json: no such file or directory",
+		},
+		{
+			&config.EncryptionConfig{Keyring: keyring, Columns: []*config.EncryptedColumnConfig{{Database: "db1", Table: "t1", Key: "k1", Mode: "randomized"}}},
+			"encryption.column[db1.t1.].database.table.column.can't.be.empty",
+		},
+		{
+			&config.EncryptionConfig{Keyring: keyring, Columns: []*config.EncryptedColumnConfig{{Database: "db1", Table: "t1", Column: "c1", Key: "k3", Mode: "randomized"}}},
+			"encryption.column[db1.t1.c1].key[k3].not.found",
+		},
+		{
+			&config.EncryptionConfig{Keyring: keyring, Columns: []*config.EncryptedColumnConfig{{Database: "db1", Table: "t1", Column: "c1", Key: "k1", Mode: "xx"}}},
+			"encryption.column[db1.t1.c1].mode[xx].invalid",
+		},
+		{
+			&config.EncryptionConfig{Keyring: keyring, Columns: []*config.EncryptedColumnConfig{
+				{Database: "db1", Table: "t1", Column: "c1", Key: "k1", Mode: "randomized"},
+				{Database: "db1", Table: "t1", Column: "C1", Key: "k2", Mode: "randomized"},
+			}},
+			"encryption.column[db1.t1.C1].duplicate",
+		},
+	}
+	for _, tcase := range tcases {
+		e := NewEncryption(log, &config.Config{Encryption: tcase.conf}, nil)
+		err := e.Init()
+		assert.Equal(t, tcase.err, err.Error())
+	}
+}
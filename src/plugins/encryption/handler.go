@@ -0,0 +1,24 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package encryption
+
+import (
+	"planner/builder"
+
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+)
+
+// EncryptionHandler interface.
+type EncryptionHandler interface {
+	Init() error
+	Enabled() bool
+	Encrypt(database string, node sqlparser.Statement) error
+	Decrypter() builder.Decrypter
+	Close() error
+}
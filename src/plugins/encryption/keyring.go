@@ -0,0 +1,168 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package encryption
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// cipherPrefix is the prefix of the encrypted value: 'RDNENC:<key id>:<base64 of nonce and sealed>'.
+	cipherPrefix = "RDNENC:"
+	keySize      = 32
+)
+
+// KeyConfig tuple.
+type KeyConfig struct {
+	ID string `json:"id"`
+	// The base64 of the 32 bytes key.
+	Key string `json:"key"`
+}
+
+// KeyringConfig tuple, the content of the keyring file.
+type KeyringConfig struct {
+	Keys []*KeyConfig `json:"keys"`
+}
+
+// key tuple, the aes-256-gcm cipher and the hmac key to derive the deterministic nonce.
+type key struct {
+	id    string
+	aead  cipher.AEAD
+	ivKey []byte
+}
+
+// derive returns the sub key of the master key by the hmac-sha256.
+func derive(master []byte, label string) []byte {
+	mac := hmac.New(sha256.New, master)
+	mac.Write([]byte(label))
+	return mac.Sum(nil)
+}
+
+func newKey(id string, master []byte) (*key, error) {
+	if len(master) != keySize {
+		return nil, errors.Errorf("encryption.key[%s].size[%d].must.be.%d", id, len(master), keySize)
+	}
+	block, err := aes.NewCipher(derive(master, "radon-encryption-key"))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &key{id: id, aead: aead, ivKey: derive(master, "radon-encryption-iv")}, nil
+}
+
+// encrypt returns the encrypted value. The deterministic nonce is the hmac of the plaintext,
+// so the same plaintext is always encrypted to the same value.
+func (k *key) encrypt(plain []byte, deterministic bool) ([]byte, error) {
+	nonce := make([]byte, k.aead.NonceSize())
+	if deterministic {
+		mac := hmac.New(sha256.New, k.ivKey)
+		mac.Write(plain)
+		copy(nonce, mac.Sum(nil))
+	} else if _, err := rand.Read(nonce); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	sealed := k.aead.Seal(nonce, nonce, plain, nil)
+	out := []byte(cipherPrefix + k.id + ":")
+	return append(out, base64.StdEncoding.EncodeToString(sealed)...), nil
+}
+
+// decrypt returns the plaintext of the sealed value(without the prefix and the key id).
+func (k *key) decrypt(encoded []byte) ([]byte, error) {
+	sealed, err := base64.StdEncoding.DecodeString(string(encoded))
+	if err != nil {
+		return nil, errors.Errorf("encryption.key[%s].decode.error:%v", k.id, err)
+	}
+	size := k.aead.NonceSize()
+	if len(sealed) < size {
+		return nil, errors.Errorf("encryption.key[%s].ciphertext.too.short", k.id)
+	}
+	plain, err := k.aead.Open(nil, sealed[:size], sealed[size:], nil)
+	if err != nil {
+		return nil, errors.Errorf("encryption.key[%s].decrypt.error:%v", k.id, err)
+	}
+	return plain, nil
+}
+
+// Keyring tuple, the keys by id.
+type Keyring struct {
+	keys map[string]*key
+}
+
+// LoadKeyring loads the keyring from the file, it's empty if the file is empty.
+func LoadKeyring(file string) (*Keyring, error) {
+	kr := &Keyring{keys: make(map[string]*key)}
+	if file == "" {
+		return kr, nil
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	conf := &KeyringConfig{}
+	if err := json.Unmarshal(data, conf); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for _, kc := range conf.Keys {
+		master, err := base64.StdEncoding.DecodeString(kc.Key)
+		if err != nil {
+			return nil, errors.Errorf("encryption.key[%s].decode.error:%v", kc.ID, err)
+		}
+		k, err := newKey(kc.ID, master)
+		if err != nil {
+			return nil, err
+		}
+		kr.keys[kc.ID] = k
+	}
+	return kr, nil
+}
+
+// Encrypt returns the encrypted value by the key.
+func (kr *Keyring) Encrypt(id string, plain []byte, deterministic bool) ([]byte, error) {
+	k, ok := kr.keys[id]
+	if !ok {
+		return nil, errors.Errorf("encryption.key[%s].not.found", id)
+	}
+	return k.encrypt(plain, deterministic)
+}
+
+// IsEncrypted returns true if the value has the encrypted prefix.
+func IsEncrypted(value []byte) bool {
+	return bytes.HasPrefix(value, []byte(cipherPrefix))
+}
+
+// Decrypt returns the plaintext of the encrypted value.
+func (kr *Keyring) Decrypt(value []byte) ([]byte, error) {
+	if !IsEncrypted(value) {
+		return nil, errors.New("encryption.value.isn't.encrypted")
+	}
+	rest := value[len(cipherPrefix):]
+	idx := bytes.IndexByte(rest, ':')
+	if idx < 0 {
+		return nil, errors.New("encryption.value.key.id.not.found")
+	}
+	id := string(rest[:idx])
+	k, ok := kr.keys[id]
+	if !ok {
+		return nil, errors.Errorf("encryption.key[%s].not.found", id)
+	}
+	return k.decrypt(rest[idx+1:])
+}
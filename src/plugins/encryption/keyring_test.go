@@ -0,0 +1,126 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package encryption
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mockKeyring writes the keyring file with the keys k1 and k2 to the dir.
+func mockKeyring(t *testing.T, dir string) string {
+	k1 := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("1", keySize)))
+	k2 := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("2", keySize)))
+	file := path.Join(dir, "keyring.json")
+	data := `{"keys": [{"id": "k1", "key": "` + k1 + `"}, {"id": "k2", "key": "` + k2 + `"}]}`
+	err := ioutil.WriteFile(file, []byte(data), 0600)
+	assert.Nil(t, err)
+	return file
+}
+
+func TestKeyring(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "keyring_")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	kr, err := LoadKeyring(mockKeyring(t, dir))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(kr.keys))
+
+	// Deterministic.
+	{
+		c1, err := kr.Encrypt("k1", []byte("13812345678"), true)
+		assert.Nil(t, err)
+		c2, err := kr.Encrypt("k1", []byte("13812345678"), true)
+		assert.Nil(t, err)
+		assert.Equal(t, c1, c2)
+		assert.True(t, IsEncrypted(c1))
+		assert.True(t, strings.HasPrefix(string(c1), "RDNENC:k1:"))
+
+		c3, err := kr.Encrypt("k2", []byte("13812345678"), true)
+		assert.Nil(t, err)
+		assert.NotEqual(t, c1, c3)
+
+		plain, err := kr.Decrypt(c1)
+		assert.Nil(t, err)
+		assert.Equal(t, "13812345678", string(plain))
+	}
+
+	// Randomized.
+	{
+		c1, err := kr.Encrypt("k1", []byte("secret"), false)
+		assert.Nil(t, err)
+		c2, err := kr.Encrypt("k1", []byte("secret"), false)
+		assert.Nil(t, err)
+		assert.NotEqual(t, c1, c2)
+
+		plain, err := kr.Decrypt(c2)
+		assert.Nil(t, err)
+		assert.Equal(t, "secret", string(plain))
+	}
+
+	// Errors.
+	{
+		_, err := kr.Encrypt("k3", []byte("x"), true)
+		assert.Equal(t, "encryption.key[k3].not.found", err.Error())
+
+		tcases := []struct {
+			value string
+			err   string
+		}{
+			{"xx", "encryption.value.isn't.encrypted"},
+			{"RDNENC:k1", "encryption.value.key.id.not.found"},
+			{"RDNENC:k3:xx", "encryption.key[k3].not.found"},
+			{"RDNENC:k1:AAAA", "encryption.key[k1].ciphertext.too.short"},
+		}
+		for _, tcase := range tcases {
+			_, err := kr.Decrypt([]byte(tcase.value))
+			assert.Equal(t, tcase.err, err.Error())
+		}
+
+		// Tampered.
+		c1, err := kr.Encrypt("k1", []byte("secret"), true)
+		assert.Nil(t, err)
+		c1[len(c1)-2] ^= 1
+		_, err = kr.Decrypt(c1)
+		assert.NotNil(t, err)
+	}
+}
+
+func TestKeyringLoadError(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "keyring_")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	kr, err := LoadKeyring("")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(kr.keys))
+
+	_, err = LoadKeyring(path.Join(dir, "none.json"))
+	assert.NotNil(t, err)
+
+	file := path.Join(dir, "keyring.json")
+	datas := []string{
+		"{",
+		`{"keys": [{"id": "k1", "key": "!!"}]}`,
+		`{"keys": [{"id": "k1", "key": "MTIz"}]}`,
+	}
+	for _, data := range datas {
+		err := ioutil.WriteFile(file, []byte(data), 0600)
+		assert.Nil(t, err)
+		_, err = LoadKeyring(file)
+		assert.NotNil(t, err, data)
+	}
+}
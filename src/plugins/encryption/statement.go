@@ -0,0 +1,317 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package encryption
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+)
+
+// scope tuple, the encrypted columns of the tables referenced by the statement.
+type scope struct {
+	aliases []string
+	columns map[string]map[string]*column
+}
+
+// lookup returns the encrypted column, the unqualified column matches any table.
+func (s *scope) lookup(col *sqlparser.ColName) *column {
+	name := col.Name.Lowered()
+	if !col.Qualifier.IsEmpty() {
+		return s.columns[col.Qualifier.Name.String()][name]
+	}
+	for _, alias := range s.aliases {
+		if c := s.columns[alias][name]; c != nil {
+			return c
+		}
+	}
+	return nil
+}
+
+func (e *Encryption) tableColumns(database string, table sqlparser.TableName) map[string]*column {
+	if !table.Qualifier.IsEmpty() {
+		database = table.Qualifier.String()
+	}
+	return e.columns[database+"."+table.Name.String()]
+}
+
+func (e *Encryption) newScope(database string, exprs sqlparser.TableExprs) *scope {
+	s := &scope{columns: make(map[string]map[string]*column)}
+	var scan func(exprs sqlparser.TableExprs)
+	scan = func(exprs sqlparser.TableExprs) {
+		for _, expr := range exprs {
+			switch expr := expr.(type) {
+			case *sqlparser.AliasedTableExpr:
+				table, ok := expr.Expr.(sqlparser.TableName)
+				if !ok {
+					continue
+				}
+				alias := table.Name.String()
+				if !expr.As.IsEmpty() {
+					alias = expr.As.String()
+				}
+				s.aliases = append(s.aliases, alias)
+				s.columns[alias] = e.tableColumns(database, table)
+			case *sqlparser.JoinTableExpr:
+				scan(sqlparser.TableExprs{expr.LeftExpr, expr.RightExpr})
+			case *sqlparser.ParenTableExpr:
+				scan(expr.Exprs)
+			}
+		}
+	}
+	scan(exprs)
+	return s
+}
+
+// encryptValue returns the encrypted value of the literal, NULL is kept.
+func (e *Encryption) encryptValue(col *column, expr sqlparser.Expr) (sqlparser.Expr, error) {
+	switch expr := expr.(type) {
+	case *sqlparser.NullVal:
+		return expr, nil
+	case sqlparser.ValTuple:
+		tuple := make(sqlparser.ValTuple, 0, len(expr))
+		for _, val := range expr {
+			v, err := e.encryptValue(col, val)
+			if err != nil {
+				return nil, err
+			}
+			tuple = append(tuple, v)
+		}
+		return tuple, nil
+	case *sqlparser.SQLVal:
+		plain := expr.Val
+		switch expr.Type {
+		case sqlparser.ValArg:
+			return nil, errors.Errorf("unsupported: the.bind.variable.on.the.encrypted.column[%s]", col.name)
+		case sqlparser.HexVal:
+			out, err := expr.HexDecode()
+			if err != nil {
+				return nil, err
+			}
+			plain = out
+		}
+		out, err := e.keyring.Encrypt(col.key, plain, col.deterministic)
+		if err != nil {
+			return nil, err
+		}
+		return sqlparser.NewStrVal(out), nil
+	}
+	return nil, errors.Errorf("unsupported: the.non-literal.value[%s].on.the.encrypted.column[%s]", sqlparser.String(expr), col.name)
+}
+
+// checkNoEncrypted returns error if the encrypted column is used in the expr.
+func checkNoEncrypted(s *scope, expr sqlparser.SQLNode) error {
+	var err error
+	sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, werr error) {
+		if col, ok := node.(*sqlparser.ColName); ok && s.lookup(col) != nil {
+			err = errors.Errorf("unsupported: the.encrypted.column[%s].in.the.expression", sqlparser.String(col))
+			return false, nil
+		}
+		return true, nil
+	}, expr)
+	return err
+}
+
+// encryptComparison used to encrypt the value compared with the encrypted column,
+// only the equality comparisons on the deterministic columns are supported.
+func (e *Encryption) encryptComparison(s *scope, cmp *sqlparser.ComparisonExpr) error {
+	var lcol, rcol *column
+	if c, ok := cmp.Left.(*sqlparser.ColName); ok {
+		lcol = s.lookup(c)
+	}
+	if c, ok := cmp.Right.(*sqlparser.ColName); ok {
+		rcol = s.lookup(c)
+	}
+	if lcol == nil && rcol == nil {
+		if err := checkNoEncrypted(s, cmp.Left); err != nil {
+			return err
+		}
+		return checkNoEncrypted(s, cmp.Right)
+	}
+
+	col := lcol
+	if col == nil {
+		col = rcol
+	}
+	if !col.deterministic {
+		return errors.Errorf("unsupported: the.randomized.encrypted.column[%s].in.the.predicate", col.name)
+	}
+	switch cmp.Operator {
+	case sqlparser.EqualStr, sqlparser.NotEqualStr, sqlparser.NullSafeEqualStr, sqlparser.InStr, sqlparser.NotInStr, "<>":
+	default:
+		return errors.Errorf("unsupported: the.encrypted.column[%s].with.the.operator[%s]", col.name, cmp.Operator)
+	}
+
+	// Column compared with column.
+	if lcol != nil && rcol != nil {
+		if !rcol.deterministic || lcol.key != rcol.key {
+			return errors.Errorf("unsupported: the.encrypted.columns[%s,%s].with.different.keys.or.modes", lcol.name, rcol.name)
+		}
+		return nil
+	}
+	var err error
+	if lcol != nil {
+		if _, ok := cmp.Right.(*sqlparser.ColName); ok {
+			return errors.Errorf("unsupported: the.encrypted.column[%s].compared.with.the.plain.column", col.name)
+		}
+		cmp.Right, err = e.encryptValue(col, cmp.Right)
+		return err
+	}
+	if _, ok := cmp.Left.(*sqlparser.ColName); ok {
+		return errors.Errorf("unsupported: the.encrypted.column[%s].compared.with.the.plain.column", col.name)
+	}
+	cmp.Left, err = e.encryptValue(col, cmp.Left)
+	return err
+}
+
+// encryptPredicate used to encrypt the values in the predicate.
+func (e *Encryption) encryptPredicate(s *scope, expr sqlparser.Expr) error {
+	var err error
+	sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, werr error) {
+		switch node := node.(type) {
+		case *sqlparser.ComparisonExpr:
+			err = e.encryptComparison(s, node)
+			return false, nil
+		case *sqlparser.IsExpr:
+			// The NULL isn't encrypted.
+			if _, ok := node.Expr.(*sqlparser.ColName); ok {
+				return false, nil
+			}
+		case *sqlparser.ColName:
+			if s.lookup(node) != nil {
+				err = errors.Errorf("unsupported: the.encrypted.column[%s].in.the.predicate", sqlparser.String(node))
+				return false, nil
+			}
+		}
+		return err == nil, nil
+	}, expr)
+	return err
+}
+
+func (e *Encryption) encryptWhere(s *scope, where *sqlparser.Where) error {
+	if where == nil {
+		return nil
+	}
+	return e.encryptPredicate(s, where.Expr)
+}
+
+func (e *Encryption) encryptInsert(database string, node *sqlparser.Insert) error {
+	cols := e.tableColumns(database, node.Table)
+	if cols == nil {
+		return nil
+	}
+	table := sqlparser.String(node.Table)
+	if len(node.Columns) == 0 {
+		return errors.Errorf("unsupported: the.insert.without.column.list.on.the.encrypted.table[%s]", table)
+	}
+	rows, ok := node.Rows.(sqlparser.Values)
+	if !ok {
+		return errors.Errorf("unsupported: the.insert.select.on.the.encrypted.table[%s]", table)
+	}
+
+	var shardKey string
+	if e.router != nil {
+		db := database
+		if !node.Table.Qualifier.IsEmpty() {
+			db = node.Table.Qualifier.String()
+		}
+		shardKey, _ = e.router.ShardKey(db, node.Table.Name.String())
+	}
+	for idx, c := range node.Columns {
+		col := cols[c.Lowered()]
+		if col == nil {
+			continue
+		}
+		if !col.deterministic && strings.EqualFold(shardKey, c.String()) {
+			return errors.Errorf("unsupported: the.randomized.encrypted.column[%s].can't.be.the.shard.key", col.name)
+		}
+		for _, row := range rows {
+			if idx >= len(row) {
+				continue
+			}
+			val, err := e.encryptValue(col, row[idx])
+			if err != nil {
+				return err
+			}
+			row[idx] = val
+		}
+	}
+
+	for _, expr := range node.OnDup {
+		col := cols[expr.Name.Name.Lowered()]
+		if col == nil {
+			continue
+		}
+		// VALUES(col) refers to the encrypted value.
+		if values, ok := expr.Expr.(*sqlparser.ValuesFuncExpr); ok && values.Name.Equal(expr.Name.Name) {
+			continue
+		}
+		val, err := e.encryptValue(col, expr.Expr)
+		if err != nil {
+			return err
+		}
+		expr.Expr = val
+	}
+	return nil
+}
+
+func (e *Encryption) encryptUpdate(database string, node *sqlparser.Update) error {
+	s := e.newScope(database, sqlparser.TableExprs{&sqlparser.AliasedTableExpr{Expr: node.Table}})
+	for _, expr := range node.Exprs {
+		if col := s.lookup(expr.Name); col != nil {
+			val, err := e.encryptValue(col, expr.Expr)
+			if err != nil {
+				return err
+			}
+			expr.Expr = val
+			continue
+		}
+		if err := checkNoEncrypted(s, expr.Expr); err != nil {
+			return err
+		}
+	}
+	return e.encryptWhere(s, node.Where)
+}
+
+func (e *Encryption) encryptDelete(database string, node *sqlparser.Delete) error {
+	s := e.newScope(database, node.TableRefs)
+	return e.encryptWhere(s, node.Where)
+}
+
+func (e *Encryption) encryptSelect(database string, node sqlparser.SelectStatement) error {
+	switch node := node.(type) {
+	case *sqlparser.Select:
+		s := e.newScope(database, node.From)
+		var ons []sqlparser.Expr
+		sqlparser.Walk(func(n sqlparser.SQLNode) (kontinue bool, err error) {
+			if join, ok := n.(*sqlparser.JoinTableExpr); ok && join.On != nil {
+				ons = append(ons, join.On)
+			}
+			return true, nil
+		}, node.From)
+		for _, on := range ons {
+			if err := e.encryptPredicate(s, on); err != nil {
+				return err
+			}
+		}
+		if err := e.encryptWhere(s, node.Where); err != nil {
+			return err
+		}
+		return e.encryptWhere(s, node.Having)
+	case *sqlparser.ParenSelect:
+		return e.encryptSelect(database, node.Select)
+	case *sqlparser.Union:
+		if err := e.encryptSelect(database, node.Left); err != nil {
+			return err
+		}
+		return e.encryptSelect(database, node.Right)
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package encryption
+
+import (
+	"io/ioutil"
+	"os"
+	"regexp"
+	"testing"
+
+	"config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+var testColumns = []*config.EncryptedColumnConfig{
+	{Database: "sbtest", Table: "A", Column: "id", Key: "k1", Mode: "deterministic"},
+	{Database: "sbtest", Table: "A", Column: "phone", Key: "k1", Mode: "deterministic"},
+	{Database: "sbtest", Table: "A", Column: "secret", Key: "k2", Mode: "randomized"},
+	{Database: "sbtest", Table: "B", Column: "phone", Key: "k1", Mode: "deterministic"},
+	{Database: "sbtest", Table: "B", Column: "email", Key: "k2", Mode: "deterministic"},
+}
+
+// expand replaces the '{x}' in the query with the deterministic encrypted value of x by the key k1.
+func expand(t *testing.T, e *Encryption, query string) string {
+	re := regexp.MustCompile(`\{([^}]*)\}`)
+	return re.ReplaceAllStringFunc(query, func(s string) string {
+		out, err := e.keyring.Encrypt("k1", []byte(s[1:len(s)-1]), true)
+		assert.Nil(t, err)
+		return string(out)
+	})
+}
+
+func TestEncryptStatement(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	dir, err := ioutil.TempDir(os.TempDir(), "encryption_")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	e, cleanup := mockEncryption(t, log, dir, testColumns)
+	defer cleanup()
+
+	querys := []string{
+		"insert into A(id, phone, name) values(1, '13812345678', 'x'), (2, NULL, 'y')",
+		"insert into sbtest.A(id, phone) values(1, 'a') on duplicate key update phone=values(phone), name='x'",
+		"insert into A(id, phone) values(1, 'a') on duplicate key update phone='b'",
+		"insert into C(id, phone) values(1, 'a')",
+		"update A set phone='b', name='x' where id=1 and phone is null",
+		"delete from A where id in (1, 2) and phone != 'a'",
+		"select a.*, B.email from A a join B on a.phone=B.phone where a.id=1 and name='x' and 'a'=B.phone",
+		"select id from A where id=1 union select id from B where phone=x'61'",
+		"select count(*) from A group by phone having count(*)>1",
+		"create table A(id int)",
+	}
+	wants := []string{
+		"insert into A(id, phone, name) values ('{1}', '{13812345678}', 'x'), ('{2}', null, 'y')",
+		"insert into sbtest.A(id, phone) values ('{1}', '{a}') on duplicate key update phone = values(phone), name = 'x'",
+		"insert into A(id, phone) values ('{1}', '{a}') on duplicate key update phone = '{b}'",
+		"insert into C(id, phone) values (1, 'a')",
+		"update A set phone = '{b}', name = 'x' where id = '{1}' and phone is null",
+		"delete from A where id in ('{1}', '{2}') and phone != '{a}'",
+		"select a.*, B.email from A as a join B on a.phone = B.phone where a.id = '{1}' and name = 'x' and '{a}' = B.phone",
+		"select id from A where id = '{1}' union select id from B where phone = '{a}'",
+		"select count(*) from A group by phone having count(*) > 1",
+		"create table A (\n\t`id` int\n)",
+	}
+	for i, query := range querys {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		err = e.Encrypt("sbtest", node)
+		assert.Nil(t, err, query)
+		assert.Equal(t, expand(t, e, wants[i]), sqlparser.String(node), query)
+	}
+
+	// Randomized.
+	{
+		node, err := sqlparser.Parse("insert into A(id, secret) values(1, 'xx')")
+		assert.Nil(t, err)
+		err = e.Encrypt("sbtest", node)
+		assert.Nil(t, err)
+		value := node.(*sqlparser.Insert).Rows.(sqlparser.Values)[0][1].(*sqlparser.SQLVal).Val
+		assert.True(t, IsEncrypted(value))
+		plain, err := e.keyring.Decrypt(value)
+		assert.Nil(t, err)
+		assert.Equal(t, "xx", string(plain))
+	}
+}
+
+func TestEncryptStatementError(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	dir, err := ioutil.TempDir(os.TempDir(), "encryption_")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	e, cleanup := mockEncryption(t, log, dir, testColumns)
+	defer cleanup()
+
+	querys := []string{
+		"insert into A values(1, 'a')",
+		"insert into A(id, phone) select id, phone from B",
+		"insert into A(id, phone) values(1, concat('a', 'b'))",
+		"insert into A(id, phone) values(1, 'a') on duplicate key update phone=values(id)",
+		"update A set name=phone",
+		"update A set phone=:v1",
+		"delete from A where phone like 'a%'",
+		"select * from A where secret='x'",
+		"select * from A where upper(phone)='X'",
+		"select * from A where phone=name",
+		"select * from A where name=phone",
+		"select * from A join B on A.phone=B.email",
+		"select * from A where phone between 'a' and 'b'",
+		"select * from A where phone in (1, id)",
+	}
+	wants := []string{
+		"unsupported: the.insert.without.column.list.on.the.encrypted.table[A]",
+		"unsupported: the.insert.select.on.the.encrypted.table[A]",
+		"unsupported: the.non-literal.value[concat('a', 'b')].on.the.encrypted.column[phone]",
+		"unsupported: the.non-literal.value[values(id)].on.the.encrypted.column[phone]",
+		"unsupported: the.encrypted.column[phone].in.the.expression",
+		"unsupported: the.bind.variable.on.the.encrypted.column[phone]",
+		"unsupported: the.encrypted.column[phone].with.the.operator[like]",
+		"unsupported: the.randomized.encrypted.column[secret].in.the.predicate",
+		"unsupported: the.encrypted.column[phone].in.the.expression",
+		"unsupported: the.encrypted.column[phone].compared.with.the.plain.column",
+		"unsupported: the.encrypted.column[phone].compared.with.the.plain.column",
+		"unsupported: the.encrypted.columns[phone,email].with.different.keys.or.modes",
+		"unsupported: the.encrypted.column[phone].in.the.predicate",
+		"unsupported: the.non-literal.value[id].on.the.encrypted.column[phone]",
+	}
+	for i, query := range querys {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		err = e.Encrypt("sbtest", node)
+		assert.Equal(t, wants[i], err.Error(), query)
+	}
+
+	// The randomized shard key.
+	{
+		e, cleanup := mockEncryption(t, log, dir, []*config.EncryptedColumnConfig{
+			{Database: "sbtest", Table: "A", Column: "id", Key: "k1", Mode: "randomized"},
+		})
+		defer cleanup()
+		node, err := sqlparser.Parse("insert into A(id) values(1)")
+		assert.Nil(t, err)
+		err = e.Encrypt("sbtest", node)
+		assert.Equal(t, "unsupported: the.randomized.encrypted.column[id].can't.be.the.shard.key", err.Error())
+	}
+}
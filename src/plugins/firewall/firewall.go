@@ -0,0 +1,349 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package firewall
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"config"
+	"router"
+
+	"github.com/pkg/errors"
+	"github.com/xelabs/go-mysqlstack/sqldb"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+const (
+	// ModeOff disables the firewall.
+	ModeOff = "OFF"
+
+	// ModeLearning records the fingerprints of the users into the meta, the deny rules are checked.
+	ModeLearning = "LEARNING"
+
+	// ModeEnforcing rejects the fingerprints which are not recorded for the user, the deny rules are checked.
+	ModeEnforcing = "ENFORCING"
+
+	// AllowlistRule is the rule name of the hit which is not in the recorded fingerprints.
+	AllowlistRule = "allowlist"
+
+	firewalljson = "firewall.json"
+
+	// defaultFlushInterval is the interval to flush the learned fingerprints to the meta.
+	defaultFlushInterval = 5 * time.Second
+)
+
+// Config tuple, the content of the metadir/firewall.json.
+type Config struct {
+	Mode  string  `json:"mode"`
+	Rules []*Rule `json:"rules"`
+	// The recorded fingerprints of the users.
+	Fingerprints map[string][]string `json:"fingerprints"`
+}
+
+// Hit tuple, the statement denied by the firewall.
+type Hit struct {
+	Rule        string
+	Fingerprint string
+}
+
+// Firewall tuple.
+type Firewall struct {
+	mu           sync.RWMutex
+	wg           sync.WaitGroup
+	log          *xlog.Log
+	metadir      string
+	router       *router.Router
+	mode         string
+	rules        []*Rule
+	fingerprints map[string]map[string]struct{}
+	// learned is the learned fingerprints not flushed to the meta.
+	learned  map[string]map[string]struct{}
+	interval time.Duration
+	done     chan bool
+}
+
+// NewFirewall creates the new firewall, it's in memory only if the metadir is empty.
+func NewFirewall(log *xlog.Log, conf *config.Config, router *router.Router) FirewallHandler {
+	metadir := ""
+	if conf != nil && conf.Proxy != nil {
+		metadir = conf.Proxy.MetaDir
+	}
+	return &Firewall{
+		log:          log,
+		metadir:      metadir,
+		router:       router,
+		mode:         ModeOff,
+		fingerprints: make(map[string]map[string]struct{}),
+		learned:      make(map[string]map[string]struct{}),
+		interval:     defaultFlushInterval,
+		done:         make(chan bool),
+	}
+}
+
+// Init -- init the firewall plugin.
+func (f *Firewall) Init() error {
+	if err := f.LoadConfig(); err != nil {
+		return err
+	}
+
+	// The learned fingerprints are flushed in the background, not on the query path.
+	f.wg.Add(1)
+	go func(f *Firewall) {
+		defer f.wg.Done()
+		ticker := time.NewTicker(f.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				f.flushDirty()
+			case <-f.done:
+				return
+			}
+		}
+	}(f)
+	f.log.Info("plugin.firewall.init.done, mode:%s", f.mode)
+	return nil
+}
+
+// Close -- stop the flusher and flush the learned fingerprints.
+func (f *Firewall) Close() error {
+	close(f.done)
+	f.wg.Wait()
+	f.flushDirty()
+	return nil
+}
+
+func normalizeMode(mode string) (string, error) {
+	mode = strings.ToUpper(mode)
+	switch mode {
+	case "":
+		return ModeOff, nil
+	case ModeOff, ModeLearning, ModeEnforcing:
+		return mode, nil
+	}
+	return "", errors.Errorf("firewall.mode[%s].invalid", mode)
+}
+
+// apply used to set the config, the lock must be held.
+func (f *Firewall) apply(conf *Config) error {
+	mode, err := normalizeMode(conf.Mode)
+	if err != nil {
+		return err
+	}
+	var rules []*Rule
+	names := make(map[string]bool)
+	for _, r := range conf.Rules {
+		rule := *r
+		if err := rule.build(); err != nil {
+			return err
+		}
+		if names[rule.Name] {
+			return errors.Errorf("firewall.rule[%s].duplicate", rule.Name)
+		}
+		names[rule.Name] = true
+		rules = append(rules, &rule)
+	}
+	fingerprints := make(map[string]map[string]struct{})
+	for user, fps := range conf.Fingerprints {
+		set := make(map[string]struct{})
+		for _, fp := range fps {
+			set[fp] = struct{}{}
+		}
+		fingerprints[user] = set
+	}
+	f.mode = mode
+	f.rules = rules
+	f.fingerprints = fingerprints
+	return nil
+}
+
+// config returns the copy of the config, the lock must be held.
+func (f *Firewall) config() *Config {
+	conf := &Config{
+		Mode:         f.mode,
+		Rules:        []*Rule{},
+		Fingerprints: make(map[string][]string),
+	}
+	for _, r := range f.rules {
+		rule := *r
+		rule.re = nil
+		conf.Rules = append(conf.Rules, &rule)
+	}
+	for user, set := range f.fingerprints {
+		fps := make([]string, 0, len(set))
+		for fp := range set {
+			fps = append(fps, fp)
+		}
+		sort.Strings(fps)
+		conf.Fingerprints[user] = fps
+	}
+	return conf
+}
+
+// LoadConfig used to load the config from the metadir/firewall.json.
+func (f *Firewall) LoadConfig() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	conf := &Config{}
+	if f.metadir != "" {
+		file := path.Join(f.metadir, firewalljson)
+		data, err := ioutil.ReadFile(file)
+		if err != nil && !os.IsNotExist(err) {
+			f.log.Error("firewall.load.from.file[%v].error:%v", file, err)
+			return errors.WithStack(err)
+		}
+		if err == nil {
+			if err := json.Unmarshal(data, conf); err != nil {
+				f.log.Error("firewall.parse.json.file[%v].error:%v", file, err)
+				return errors.WithStack(err)
+			}
+		}
+	}
+	// The learned fingerprints not flushed yet are kept, they're flushed at the next tick.
+	if len(f.learned) > 0 && conf.Fingerprints == nil {
+		conf.Fingerprints = make(map[string][]string)
+	}
+	for user, set := range f.learned {
+		for fp := range set {
+			conf.Fingerprints[user] = append(conf.Fingerprints[user], fp)
+		}
+	}
+	return f.apply(conf)
+}
+
+// flush used to write the config to the metadir/firewall.json and update the meta version, the lock must be held.
+func (f *Firewall) flush() error {
+	if f.metadir == "" {
+		f.learned = make(map[string]map[string]struct{})
+		return nil
+	}
+
+	file := path.Join(f.metadir, firewalljson)
+	if err := config.WriteConfig(file, f.config()); err != nil {
+		f.log.Error("firewall.flush.to.file[%v].error:%v", file, err)
+		return err
+	}
+	if err := config.UpdateVersion(f.metadir); err != nil {
+		f.log.Error("firewall.flush.update.version.error:%v", err)
+		return err
+	}
+	f.learned = make(map[string]map[string]struct{})
+	return nil
+}
+
+// flushDirty used to flush the learned fingerprints if they are not flushed.
+func (f *Firewall) flushDirty() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.learned) == 0 {
+		return
+	}
+	// Retry at the next tick if failed.
+	f.flush()
+}
+
+// Config returns the mode, rules and recorded fingerprints.
+func (f *Firewall) Config() *Config {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.config()
+}
+
+// SetConfig used to set the mode, rules and recorded fingerprints, and flush them to the meta.
+func (f *Firewall) SetConfig(conf *Config) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.apply(conf); err != nil {
+		return err
+	}
+	// The config replaces the learned fingerprints.
+	f.learned = make(map[string]map[string]struct{})
+	f.log.Warning("firewall.set.config:%+v", conf)
+	return f.flush()
+}
+
+// Check used to check the statement by the rules and the recorded fingerprints of the user,
+// returns the hit and the error if the statement is denied.
+func (f *Firewall) Check(database string, user string, node sqlparser.Statement) (*Hit, error) {
+	return f.check(user, func() *statement { return newStatement(database, user, node) })
+}
+
+// CheckQuery used to check the statement which the parser doesn't support, by its type and query text.
+func (f *Firewall) CheckQuery(database string, user string, typ string, query string) (*Hit, error) {
+	return f.check(user, func() *statement { return newQueryStatement(database, user, typ, query) })
+}
+
+func (f *Firewall) check(user string, build func() *statement) (*Hit, error) {
+	f.mu.RLock()
+	mode := f.mode
+	if mode == ModeOff {
+		f.mu.RUnlock()
+		return nil, nil
+	}
+
+	stmt := build()
+	for _, rule := range f.rules {
+		if rule.match(f.router, stmt) {
+			f.mu.RUnlock()
+			return f.deny(rule.Name, stmt)
+		}
+	}
+	_, known := f.fingerprints[user][stmt.fingerprint]
+	f.mu.RUnlock()
+	if known {
+		return nil, nil
+	}
+
+	switch mode {
+	case ModeEnforcing:
+		return f.deny(AllowlistRule, stmt)
+	case ModeLearning:
+		f.learn(user, stmt.fingerprint)
+	}
+	return nil, nil
+}
+
+func (f *Firewall) deny(rule string, stmt *statement) (*Hit, error) {
+	f.log.Warning("firewall.user[%s].rule[%s].deny:%s", stmt.user, rule, stmt.fingerprint)
+	hit := &Hit{Rule: rule, Fingerprint: stmt.fingerprint}
+	return hit, sqldb.NewSQLError1(1045, "28000", "Statement was blocked by Firewall, rule: %s", rule)
+}
+
+// learn used to record the fingerprint of the user, it's flushed to the meta in the background.
+func (f *Firewall) learn(user string, fingerprint string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.mode != ModeLearning {
+		return
+	}
+	set, ok := f.fingerprints[user]
+	if !ok {
+		set = make(map[string]struct{})
+		f.fingerprints[user] = set
+	}
+	if _, ok := set[fingerprint]; ok {
+		return
+	}
+	set[fingerprint] = struct{}{}
+	if _, ok := f.learned[user]; !ok {
+		f.learned[user] = make(map[string]struct{})
+	}
+	f.learned[user][fingerprint] = struct{}{}
+}
@@ -0,0 +1,225 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package firewall
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"config"
+	"router"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func mockConfig(metadir string) *config.Config {
+	return &config.Config{Proxy: &config.ProxyConfig{MetaDir: metadir}}
+}
+
+func TestFirewall(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	metadir, err := ioutil.TempDir(os.TempDir(), "firewall_")
+	assert.Nil(t, err)
+	defer os.RemoveAll(metadir)
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+	err = route.CreateDatabase("db1")
+	assert.Nil(t, err)
+	err = route.AddForTest("db1", router.MockTableAConfig())
+	assert.Nil(t, err)
+
+	fw := NewFirewall(log, mockConfig(metadir), route)
+	err = fw.Init()
+	assert.Nil(t, err)
+	defer fw.Close()
+
+	check := func(user string, query string) (*Hit, error) {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		return fw.Check("db1", user, node)
+	}
+
+	// Off.
+	{
+		assert.Equal(t, &Config{Mode: ModeOff, Rules: []*Rule{}, Fingerprints: map[string][]string{}}, fw.Config())
+		hit, err := check("u1", "delete from A")
+		assert.Nil(t, hit)
+		assert.Nil(t, err)
+	}
+
+	// Learning.
+	{
+		conf := &Config{
+			Mode:  "learning",
+			Rules: []*Rule{{Name: "scatter-delete", Statement: "DELETE", Scatter: true}},
+		}
+		err := fw.SetConfig(conf)
+		assert.Nil(t, err)
+
+		hit, err := check("u1", "delete from A")
+		assert.Equal(t, &Hit{Rule: "scatter-delete", Fingerprint: "delete from a"}, hit)
+		assert.Equal(t, "Statement was blocked by Firewall, rule: scatter-delete (errno 1045) (sqlstate 28000)", err.Error())
+
+		for _, query := range []string{"select a from A where id = 1", "select a from A where id = 2", "delete from A where id = 1"} {
+			hit, err = check("u1", query)
+			assert.Nil(t, hit)
+			assert.Nil(t, err)
+		}
+		want := map[string][]string{"u1": {"delete from a where id = ?", "select a from a where id = ?"}}
+		assert.Equal(t, want, fw.Config().Fingerprints)
+		assert.True(t, config.ReadVersion(metadir) > 0)
+	}
+
+	// Enforcing.
+	{
+		conf := fw.Config()
+		conf.Mode = ModeEnforcing
+		err := fw.SetConfig(conf)
+		assert.Nil(t, err)
+
+		hit, err := check("u1", "select a from A where id = 3")
+		assert.Nil(t, hit)
+		assert.Nil(t, err)
+
+		hit, err = check("u1", "select b from A where id = 3")
+		assert.Equal(t, &Hit{Rule: AllowlistRule, Fingerprint: "select b from a where id = ?"}, hit)
+		assert.Equal(t, "Statement was blocked by Firewall, rule: allowlist (errno 1045) (sqlstate 28000)", err.Error())
+
+		hit, err = check("u2", "select a from A where id = 3")
+		assert.NotNil(t, hit)
+		assert.NotNil(t, err)
+	}
+
+	// The statement which the parser doesn't support.
+	{
+		hit, err := fw.CheckQuery("db1", "u1", "DDL", "CREATE USER u3 IDENTIFIED BY 'p3'")
+		assert.Equal(t, &Hit{Rule: AllowlistRule, Fingerprint: "create user u3 identified by ?"}, hit)
+		assert.Equal(t, "Statement was blocked by Firewall, rule: allowlist (errno 1045) (sqlstate 28000)", err.Error())
+	}
+
+	// Reload from the metadir.
+	{
+		fw1 := NewFirewall(log, mockConfig(metadir), route)
+		err := fw1.Init()
+		assert.Nil(t, err)
+		defer fw1.Close()
+		assert.Equal(t, fw.Config(), fw1.Config())
+	}
+}
+
+func TestFirewallLearnFlush(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	metadir, err := ioutil.TempDir(os.TempDir(), "firewall_")
+	assert.Nil(t, err)
+	defer os.RemoveAll(metadir)
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	fw := NewFirewall(log, mockConfig(metadir), route)
+	fw.(*Firewall).interval = 50 * time.Millisecond
+	err = fw.Init()
+	assert.Nil(t, err)
+	err = fw.SetConfig(&Config{Mode: ModeLearning})
+	assert.Nil(t, err)
+	version := config.ReadVersion(metadir)
+
+	// The learned fingerprints are not flushed on the query path.
+	for _, query := range []string{"select 1", "select a from t1", "select b from t1"} {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		_, err = fw.Check("db1", "u1", node)
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, version, config.ReadVersion(metadir))
+
+	// Flushed in the background.
+	for config.ReadVersion(metadir) == version {
+		time.Sleep(10 * time.Millisecond)
+	}
+	fw1 := NewFirewall(log, mockConfig(metadir), route)
+	err = fw1.LoadConfig()
+	assert.Nil(t, err)
+	assert.Equal(t, fw.Config(), fw1.Config())
+	assert.Nil(t, fw.Close())
+}
+
+func TestFirewallLoadConfigLearned(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	metadir, err := ioutil.TempDir(os.TempDir(), "firewall_")
+	assert.Nil(t, err)
+	defer os.RemoveAll(metadir)
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	fw := NewFirewall(log, mockConfig(metadir), route)
+	fw.(*Firewall).interval = time.Hour
+	err = fw.Init()
+	assert.Nil(t, err)
+	err = fw.SetConfig(&Config{Mode: ModeLearning})
+	assert.Nil(t, err)
+	node, err := sqlparser.Parse("select a from t1")
+	assert.Nil(t, err)
+	_, err = fw.Check("db1", "u1", node)
+	assert.Nil(t, err)
+
+	// The config changed by the other node and synced.
+	fw1 := NewFirewall(log, mockConfig(metadir), route)
+	err = fw1.SetConfig(&Config{Mode: ModeLearning, Fingerprints: map[string][]string{"u2": {"select ?"}}})
+	assert.Nil(t, err)
+
+	// The unflushed learned fingerprints are kept by the reload.
+	err = fw.LoadConfig()
+	assert.Nil(t, err)
+	want := map[string][]string{"u1": {"select a from t1"}, "u2": {"select ?"}}
+	assert.Equal(t, want, fw.Config().Fingerprints)
+
+	// And flushed.
+	assert.Nil(t, fw.Close())
+	err = fw1.LoadConfig()
+	assert.Nil(t, err)
+	assert.Equal(t, want, fw1.Config().Fingerprints)
+}
+
+func TestFirewallError(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	metadir, err := ioutil.TempDir(os.TempDir(), "firewall_")
+	assert.Nil(t, err)
+	defer os.RemoveAll(metadir)
+
+	fw := NewFirewall(log, nil, nil)
+	err = fw.Init()
+	assert.Nil(t, err)
+
+	confs := []*Config{
+		{Mode: "xx"},
+		{Rules: []*Rule{{Name: "r1"}, {Name: "r1"}}},
+		{Rules: []*Rule{{Name: ""}}},
+	}
+	wants := []string{
+		"firewall.mode[XX].invalid",
+		"firewall.rule[r1].duplicate",
+		"firewall.rule.name.can't.be.empty",
+	}
+	for i, conf := range confs {
+		err := fw.SetConfig(conf)
+		assert.Equal(t, wants[i], err.Error())
+	}
+
+	// Invalid json.
+	{
+		err := ioutil.WriteFile(metadir+"/"+firewalljson, []byte("{"), 0644)
+		assert.Nil(t, err)
+		fw := NewFirewall(log, mockConfig(metadir), nil)
+		assert.NotNil(t, fw.Init())
+	}
+}
@@ -0,0 +1,24 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package firewall
+
+import (
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+)
+
+// FirewallHandler interface.
+type FirewallHandler interface {
+	Init() error
+	Check(database string, user string, node sqlparser.Statement) (*Hit, error)
+	CheckQuery(database string, user string, typ string, query string) (*Hit, error)
+	Config() *Config
+	SetConfig(conf *Config) error
+	LoadConfig() error
+	Close() error
+}
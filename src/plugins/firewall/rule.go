@@ -0,0 +1,175 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package firewall
+
+import (
+	"regexp"
+	"strings"
+
+	"router"
+	"xbase"
+
+	"github.com/pkg/errors"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+)
+
+// Rule tuple, the statement matched by the rule is denied.
+type Rule struct {
+	Name string `json:"name"`
+	// The users of the rule, empty means all the users.
+	Users []string `json:"users,omitempty"`
+	// The statement type, such as 'SELECT', 'UPDATE', 'DELETE', empty means all the types.
+	Statement string `json:"statement,omitempty"`
+	// The regexp on the statement fingerprint, empty means all the fingerprints.
+	Fingerprint string `json:"fingerprint,omitempty"`
+	// Only match the UPDATE/DELETE on the sharded table without the shard-key predicate,
+	// which is scattered to all the partitions.
+	Scatter bool `json:"scatter,omitempty"`
+
+	re *regexp.Regexp
+}
+
+// build used to check and compile the rule.
+func (r *Rule) build() error {
+	if r.Name == "" {
+		return errors.New("firewall.rule.name.can't.be.empty")
+	}
+	r.Statement = strings.ToUpper(r.Statement)
+	r.re = nil
+	if r.Fingerprint != "" {
+		re, err := regexp.Compile(r.Fingerprint)
+		if err != nil {
+			return errors.Errorf("firewall.rule[%s].fingerprint[%s].invalid:%v", r.Name, r.Fingerprint, err)
+		}
+		r.re = re
+	}
+	return nil
+}
+
+// statement tuple, the statement checked by the firewall.
+type statement struct {
+	database    string
+	user        string
+	typ         string
+	fingerprint string
+	node        sqlparser.Statement
+}
+
+func newStatement(database string, user string, node sqlparser.Statement) *statement {
+	return &statement{
+		database:    database,
+		user:        user,
+		typ:         xbase.StatementType(node),
+		fingerprint: xbase.Fingerprint(sqlparser.String(node)),
+		node:        node,
+	}
+}
+
+func newQueryStatement(database string, user string, typ string, query string) *statement {
+	return &statement{
+		database:    database,
+		user:        user,
+		typ:         typ,
+		fingerprint: xbase.Fingerprint(query),
+	}
+}
+
+func (r *Rule) match(route *router.Router, stmt *statement) bool {
+	if len(r.Users) > 0 && !containsString(r.Users, stmt.user) {
+		return false
+	}
+	if r.Statement != "" && r.Statement != stmt.typ {
+		return false
+	}
+	if r.re != nil && !r.re.MatchString(stmt.fingerprint) {
+		return false
+	}
+	if r.Scatter && !isScatter(route, stmt.database, stmt.node) {
+		return false
+	}
+	return true
+}
+
+// isScatter returns true if the UPDATE/DELETE is on the sharded table and without the shard-key
+// equality predicate.
+func isScatter(route *router.Router, database string, node sqlparser.Statement) bool {
+	var table sqlparser.TableName
+	var where *sqlparser.Where
+	switch node := node.(type) {
+	case *sqlparser.Update:
+		table, where = node.Table, node.Where
+	case *sqlparser.Delete:
+		if len(node.TableRefs) != 1 {
+			return false
+		}
+		expr, ok := node.TableRefs[0].(*sqlparser.AliasedTableExpr)
+		if !ok {
+			return false
+		}
+		if table, ok = expr.Expr.(sqlparser.TableName); !ok {
+			return false
+		}
+		where = node.Where
+	default:
+		return false
+	}
+	if route == nil {
+		return false
+	}
+	if !table.Qualifier.IsEmpty() {
+		database = table.Qualifier.String()
+	}
+	typ, err := route.PartitionType(database, table.Name.String())
+	if err != nil || (typ != router.MethodTypeHash && typ != router.MethodTypeList) {
+		return false
+	}
+	shardkey, err := route.ShardKey(database, table.Name.String())
+	if err != nil || where == nil {
+		return true
+	}
+	for _, filter := range splitAndExpression(nil, where.Expr) {
+		cmp, ok := filter.(*sqlparser.ComparisonExpr)
+		if !ok || (cmp.Operator != sqlparser.EqualStr && cmp.Operator != sqlparser.InStr) {
+			continue
+		}
+		col, ok := cmp.Left.(*sqlparser.ColName)
+		if !ok {
+			if col, ok = cmp.Right.(*sqlparser.ColName); !ok || cmp.Operator != sqlparser.EqualStr {
+				continue
+			}
+		}
+		if col.Name.EqualString(shardkey) {
+			return false
+		}
+	}
+	return true
+}
+
+func splitAndExpression(filters []sqlparser.Expr, node sqlparser.Expr) []sqlparser.Expr {
+	if node == nil {
+		return filters
+	}
+	switch node := node.(type) {
+	case *sqlparser.AndExpr:
+		filters = splitAndExpression(filters, node.Left)
+		return splitAndExpression(filters, node.Right)
+	case *sqlparser.ParenExpr:
+		return splitAndExpression(filters, node.Expr)
+	}
+	return append(filters, node)
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
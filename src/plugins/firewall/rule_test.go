@@ -0,0 +1,109 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package firewall
+
+import (
+	"testing"
+
+	"router"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestRuleIsScatter(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+	err := route.CreateDatabase("db1")
+	assert.Nil(t, err)
+	err = route.AddForTest("db1", router.MockTableAConfig(), router.MockTableGConfig(), router.MockTableListConfig())
+	assert.Nil(t, err)
+
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"delete from A", true},
+		{"delete from A where a=1", true},
+		{"delete from A where id=1", false},
+		{"delete from A where a=1 and (id in (1,2) and b=2)", false},
+		{"delete from A where 1=id", false},
+		{"delete from A where id>1", true},
+		{"delete from A where id=1 or a=1", true},
+		{"delete from db1.A where b=1", true},
+		{"update A set a=1", true},
+		{"update A set a=1 where id=2", false},
+		{"update L set a=1", true},
+		{"update G set a=1", false},
+		{"update nodb.A set a=1", false},
+		{"update nonexists set a=1", false},
+		{"delete A from A, G where A.a=G.a", false},
+		{"select * from A", false},
+	}
+	for _, test := range tests {
+		node, err := sqlparser.Parse(test.query)
+		assert.Nil(t, err)
+		assert.Equal(t, test.want, isScatter(route, "db1", node), test.query)
+	}
+	node, _ := sqlparser.Parse("delete from A")
+	assert.False(t, isScatter(nil, "db1", node))
+}
+
+func TestRuleMatch(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+	err := route.CreateDatabase("db1")
+	assert.Nil(t, err)
+	err = route.AddForTest("db1", router.MockTableAConfig())
+	assert.Nil(t, err)
+
+	rules := []*Rule{
+		{Name: "scatter-delete", Statement: "delete", Scatter: true},
+		{Name: "u1-select-star", Users: []string{"u1"}, Fingerprint: `^select \* `},
+	}
+	for _, rule := range rules {
+		assert.Nil(t, rule.build())
+	}
+
+	tests := []struct {
+		user  string
+		query string
+		want  string
+	}{
+		{"u2", "delete from A where a=1", "scatter-delete"},
+		{"u2", "delete from A where id=1", ""},
+		{"u2", "select * from A", ""},
+		{"u1", "SELECT * FROM A where id=1", "u1-select-star"},
+		{"u1", "select a from A", ""},
+	}
+	for _, test := range tests {
+		node, err := sqlparser.Parse(test.query)
+		assert.Nil(t, err)
+		stmt := newStatement("db1", test.user, node)
+		got := ""
+		for _, rule := range rules {
+			if rule.match(route, stmt) {
+				got = rule.Name
+				break
+			}
+		}
+		assert.Equal(t, test.want, got, test.query)
+	}
+
+	// Build errors.
+	{
+		rule := &Rule{}
+		assert.Equal(t, "firewall.rule.name.can't.be.empty", rule.build().Error())
+		rule = &Rule{Name: "r1", Fingerprint: "select ("}
+		assert.Equal(t, "firewall.rule[r1].fingerprint[select (].invalid:error parsing regexp: missing closing ): `select (`", rule.build().Error())
+	}
+}
@@ -0,0 +1,23 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package masking
+
+import (
+	"planner/builder"
+)
+
+// MaskingHandler interface.
+type MaskingHandler interface {
+	Init() error
+	Masker(user string) builder.Masker
+	Policies() []*Policy
+	SetPolicies(policies []*Policy) error
+	LoadConfig() error
+	Close() error
+}
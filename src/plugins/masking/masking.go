@@ -0,0 +1,197 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package masking
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"sync"
+
+	"config"
+	"planner/builder"
+	"plugins/privilege"
+
+	"github.com/pkg/errors"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+const (
+	maskingjson = "masking.json"
+)
+
+// Config tuple, the content of the metadir/masking.json.
+type Config struct {
+	Policies []*Policy `json:"policies"`
+}
+
+// Masking tuple, the column masking policies of the users and roles.
+type Masking struct {
+	mu       sync.RWMutex
+	log      *xlog.Log
+	metadir  string
+	catalog  *privilege.Catalog
+	policies []*Policy
+}
+
+// NewMasking creates the new masking, it's in memory only if the metadir is empty.
+// The roles of the user are resolved by the catalog.
+func NewMasking(log *xlog.Log, conf *config.Config, catalog *privilege.Catalog) MaskingHandler {
+	metadir := ""
+	if conf != nil && conf.Proxy != nil {
+		metadir = conf.Proxy.MetaDir
+	}
+	return &Masking{
+		log:     log,
+		metadir: metadir,
+		catalog: catalog,
+	}
+}
+
+// Init -- init the masking plugin.
+func (m *Masking) Init() error {
+	if err := m.LoadConfig(); err != nil {
+		return err
+	}
+	m.log.Info("plugin.masking.init.done, policies:%d", len(m.policies))
+	return nil
+}
+
+// Close -- do nothing.
+func (m *Masking) Close() error {
+	return nil
+}
+
+func buildPolicies(policies []*Policy) ([]*Policy, error) {
+	var built []*Policy
+	names := make(map[string]bool)
+	for _, p := range policies {
+		policy := *p
+		if err := policy.build(); err != nil {
+			return nil, err
+		}
+		if names[policy.Name] {
+			return nil, errors.Errorf("masking.policy[%s].duplicate", policy.Name)
+		}
+		names[policy.Name] = true
+		built = append(built, &policy)
+	}
+	return built, nil
+}
+
+// LoadConfig used to load the policies from the metadir/masking.json.
+func (m *Masking) LoadConfig() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	conf := &Config{}
+	if m.metadir != "" {
+		file := path.Join(m.metadir, maskingjson)
+		data, err := ioutil.ReadFile(file)
+		if err != nil && !os.IsNotExist(err) {
+			m.log.Error("masking.load.from.file[%v].error:%v", file, err)
+			return errors.WithStack(err)
+		}
+		if err == nil {
+			if err := json.Unmarshal(data, conf); err != nil {
+				m.log.Error("masking.parse.json.file[%v].error:%v", file, err)
+				return errors.WithStack(err)
+			}
+		}
+	}
+	policies, err := buildPolicies(conf.Policies)
+	if err != nil {
+		return err
+	}
+	m.policies = policies
+	return nil
+}
+
+// Policies returns the copy of the policies.
+func (m *Masking) Policies() []*Policy {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	policies := []*Policy{}
+	for _, p := range m.policies {
+		policy := *p
+		policies = append(policies, &policy)
+	}
+	return policies
+}
+
+// SetPolicies used to set the policies, and flush them to the metadir/masking.json and update the meta version.
+func (m *Masking) SetPolicies(policies []*Policy) error {
+	built, err := buildPolicies(policies)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.log.Warning("masking.set.policies:%+v", policies)
+	m.policies = built
+	if m.metadir == "" {
+		return nil
+	}
+
+	conf := &Config{Policies: []*Policy{}}
+	conf.Policies = append(conf.Policies, m.policies...)
+	file := path.Join(m.metadir, maskingjson)
+	if err := config.WriteConfig(file, conf); err != nil {
+		m.log.Error("masking.flush.to.file[%v].error:%v", file, err)
+		return err
+	}
+	if err := config.UpdateVersion(m.metadir); err != nil {
+		m.log.Error("masking.flush.update.version.error:%v", err)
+		return err
+	}
+	return nil
+}
+
+// masker tuple, the masked columns of the tables, the key is 'database.table'.
+type masker map[string]map[string]string
+
+// Columns returns the masked columns of the table and their mask types.
+func (mk masker) Columns(database string, table string) map[string]string {
+	return mk[database+"."+table]
+}
+
+// Masker returns the masker of the user, nil if no column is masked for the user.
+// The first policy wins if the column is masked by more than one policies.
+func (m *Masking) Masker(user string) builder.Masker {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if len(m.policies) == 0 {
+		return nil
+	}
+	var roles []string
+	if m.catalog != nil {
+		roles = m.catalog.UserRoles(user)
+	}
+	mk := make(masker)
+	for _, policy := range m.policies {
+		if !policy.match(user, roles) {
+			continue
+		}
+		key := policy.Database + "." + policy.Table
+		if mk[key] == nil {
+			mk[key] = make(map[string]string)
+		}
+		if _, ok := mk[key][policy.Column]; !ok {
+			mk[key][policy.Column] = policy.Type
+		}
+	}
+	if len(mk) == 0 {
+		return nil
+	}
+	return mk
+}
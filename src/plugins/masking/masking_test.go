@@ -0,0 +1,101 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package masking
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"config"
+	"planner/builder"
+	"plugins/privilege"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestMasking(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	metadir, err := ioutil.TempDir(os.TempDir(), "masking_")
+	assert.Nil(t, err)
+	defer os.RemoveAll(metadir)
+	conf := &config.Config{Proxy: &config.ProxyConfig{MetaDir: metadir}}
+
+	catalog := privilege.NewCatalog(log, "")
+	assert.Nil(t, catalog.CreateUser("u2", "", false))
+	assert.Nil(t, catalog.CreateRole("analyst", false))
+	assert.Nil(t, catalog.GrantRole("analyst", "u2"))
+
+	m := NewMasking(log, conf, catalog)
+	err = m.Init()
+	assert.Nil(t, err)
+	defer m.Close()
+	assert.Equal(t, []*Policy{}, m.Policies())
+	assert.Nil(t, m.Masker("u1"))
+
+	policies := []*Policy{
+		{Name: "phone", Database: "db1", Table: "t1", Column: "phone", Type: "partial", Users: []string{"u1"}, Roles: []string{"analyst"}},
+		{Name: "email", Database: "db1", Table: "t1", Column: "email", Type: "hash", Roles: []string{"analyst"}},
+		{Name: "phone-null", Database: "db1", Table: "t1", Column: "phone", Type: "null", Roles: []string{"analyst"}},
+	}
+	err = m.SetPolicies(policies)
+	assert.Nil(t, err)
+	assert.True(t, config.ReadVersion(metadir) > 0)
+
+	// Masker.
+	{
+		masker := m.Masker("u1")
+		assert.Equal(t, map[string]string{"phone": builder.MaskPartial}, masker.Columns("db1", "t1"))
+		assert.Nil(t, masker.Columns("db1", "t2"))
+
+		masker = m.Masker("u2")
+		assert.Equal(t, map[string]string{"phone": builder.MaskPartial, "email": builder.MaskHash}, masker.Columns("db1", "t1"))
+
+		assert.Nil(t, m.Masker("u3"))
+	}
+
+	// Reload from the metadir.
+	{
+		m1 := NewMasking(log, conf, nil)
+		err := m1.Init()
+		assert.Nil(t, err)
+		assert.Equal(t, m.Policies(), m1.Policies())
+		assert.Nil(t, m1.Masker("u2"))
+	}
+
+	// Errors.
+	{
+		err := m.SetPolicies([]*Policy{policies[0], policies[0]})
+		assert.Equal(t, "masking.policy[phone].duplicate", err.Error())
+		assert.Equal(t, 3, len(m.Policies()))
+
+		err = ioutil.WriteFile(path.Join(metadir, maskingjson), []byte("{"), 0644)
+		assert.Nil(t, err)
+		err = m.LoadConfig()
+		assert.NotNil(t, err)
+
+		err = ioutil.WriteFile(path.Join(metadir, maskingjson), []byte(`{"policies":[{"name":"p1"}]}`), 0644)
+		assert.Nil(t, err)
+		err = m.LoadConfig()
+		assert.NotNil(t, err)
+	}
+}
+
+func TestMaskingInMemory(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	m := NewMasking(log, nil, nil)
+	err := m.Init()
+	assert.Nil(t, err)
+
+	err = m.SetPolicies([]*Policy{{Name: "p1", Database: "db1", Table: "t1", Column: "c1", Type: "full", Users: []string{"u1"}}})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"c1": builder.MaskFull}, m.Masker("u1").Columns("db1", "t1"))
+}
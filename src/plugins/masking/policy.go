@@ -0,0 +1,72 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package masking
+
+import (
+	"strings"
+
+	"planner/builder"
+
+	"github.com/pkg/errors"
+)
+
+// Policy tuple, the column is masked for the users and the users granted the roles.
+type Policy struct {
+	Name     string `json:"name"`
+	Database string `json:"database"`
+	Table    string `json:"table"`
+	Column   string `json:"column"`
+	// The mask type: FULL, PARTIAL, HASH or NULL.
+	Type  string   `json:"type"`
+	Users []string `json:"users,omitempty"`
+	Roles []string `json:"roles,omitempty"`
+}
+
+// build used to check and normalize the policy.
+func (p *Policy) build() error {
+	if p.Name == "" {
+		return errors.New("masking.policy.name.can't.be.empty")
+	}
+	if p.Database == "" || p.Table == "" || p.Column == "" {
+		return errors.Errorf("masking.policy[%s].database.table.column.can't.be.empty", p.Name)
+	}
+	p.Type = strings.ToUpper(p.Type)
+	switch p.Type {
+	case builder.MaskFull, builder.MaskPartial, builder.MaskHash, builder.MaskNull:
+	default:
+		return errors.Errorf("masking.policy[%s].type[%s].invalid", p.Name, p.Type)
+	}
+	if len(p.Users) == 0 && len(p.Roles) == 0 {
+		return errors.Errorf("masking.policy[%s].users.and.roles.can't.be.both.empty", p.Name)
+	}
+	p.Column = strings.ToLower(p.Column)
+	return nil
+}
+
+// match returns true if the policy is attached to the user or one of the roles.
+func (p *Policy) match(user string, roles []string) bool {
+	if containsString(p.Users, user) {
+		return true
+	}
+	for _, role := range roles {
+		if containsString(p.Roles, role) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package masking
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicyBuild(t *testing.T) {
+	policy := &Policy{Name: "p1", Database: "db1", Table: "t1", Column: "Phone", Type: "hash", Roles: []string{"r1"}}
+	err := policy.build()
+	assert.Nil(t, err)
+	assert.Equal(t, "HASH", policy.Type)
+	assert.Equal(t, "phone", policy.Column)
+
+	tcases := []struct {
+		policy *Policy
+		err    string
+	}{
+		{&Policy{}, "masking.policy.name.can't.be.empty"},
+		{&Policy{Name: "p1", Database: "db1", Table: "t1"}, "masking.policy[p1].database.table.column.can't.be.empty"},
+		{&Policy{Name: "p1", Database: "db1", Table: "t1", Column: "c1", Type: "mask"}, "masking.policy[p1].type[MASK].invalid"},
+		{&Policy{Name: "p1", Database: "db1", Table: "t1", Column: "c1", Type: "full"}, "masking.policy[p1].users.and.roles.can't.be.both.empty"},
+	}
+	for _, tcase := range tcases {
+		err := tcase.policy.build()
+		assert.Equal(t, tcase.err, err.Error())
+	}
+}
+
+func TestPolicyMatch(t *testing.T) {
+	policy := &Policy{Users: []string{"u1"}, Roles: []string{"r1"}}
+	assert.True(t, policy.match("u1", nil))
+	assert.True(t, policy.match("u2", []string{"r2", "r1"}))
+	assert.False(t, policy.match("u2", []string{"r2"}))
+}
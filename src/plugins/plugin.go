@@ -13,8 +13,13 @@ import (
 	"config"
 	"router"
 
+	"plugins/authentication"
 	"plugins/autoincrement"
+	"plugins/encryption"
+	"plugins/firewall"
+	"plugins/masking"
 	"plugins/privilege"
+	"plugins/rewrite"
 	"plugins/shiftmanager"
 
 	"github.com/xelabs/go-mysqlstack/xlog"
@@ -22,13 +27,18 @@ import (
 
 // Plugin --
 type Plugin struct {
-	log           *xlog.Log
-	conf          *config.Config
-	router        *router.Router
-	scatter       *backend.Scatter
-	autoincrement autoincrement.AutoIncrementHandler
-	privilege     privilege.PrivilegeHandler
-	shiftMgr      shiftmanager.ShiftMgrHandler
+	log            *xlog.Log
+	conf           *config.Config
+	router         *router.Router
+	scatter        *backend.Scatter
+	autoincrement  autoincrement.AutoIncrementHandler
+	privilege      privilege.PrivilegeHandler
+	shiftMgr       shiftmanager.ShiftMgrHandler
+	authentication authentication.AuthenticationHandler
+	firewall       firewall.FirewallHandler
+	rewrite        rewrite.RewriteHandler
+	masking        masking.MaskingHandler
+	encryption     encryption.EncryptionHandler
 }
 
 // NewPlugin -- creates new Plugin.
@@ -69,6 +79,41 @@ func (plugin *Plugin) Init() error {
 	}
 	plugin.shiftMgr = shiftMgr
 
+	// Register authentication plug.
+	authPlug := authentication.NewAuthentication(log, config)
+	if err := authPlug.Init(); err != nil {
+		return err
+	}
+	plugin.authentication = authPlug
+
+	// Register firewall plug.
+	firewallPlug := firewall.NewFirewall(log, config, router)
+	if err := firewallPlug.Init(); err != nil {
+		return err
+	}
+	plugin.firewall = firewallPlug
+
+	// Register rewrite plug.
+	rewritePlug := rewrite.NewRewrite(log, config)
+	if err := rewritePlug.Init(); err != nil {
+		return err
+	}
+	plugin.rewrite = rewritePlug
+
+	// Register masking plug.
+	maskingPlug := masking.NewMasking(log, config, privilegePlug.Catalog())
+	if err := maskingPlug.Init(); err != nil {
+		return err
+	}
+	plugin.masking = maskingPlug
+
+	// Register encryption plug.
+	encryptionPlug := encryption.NewEncryption(log, config, router)
+	if err := encryptionPlug.Init(); err != nil {
+		return err
+	}
+	plugin.encryption = encryptionPlug
+
 	return nil
 }
 
@@ -77,6 +122,11 @@ func (plugin *Plugin) Close() {
 	plugin.autoincrement.Close()
 	plugin.privilege.Close()
 	plugin.shiftMgr.Close()
+	plugin.authentication.Close()
+	plugin.firewall.Close()
+	plugin.rewrite.Close()
+	plugin.masking.Close()
+	plugin.encryption.Close()
 }
 
 // PlugAutoIncrement -- return AutoIncrement plug.
@@ -93,3 +143,28 @@ func (plugin *Plugin) PlugPrivilege() privilege.PrivilegeHandler {
 func (plugin *Plugin) PlugShiftMgr() shiftmanager.ShiftMgrHandler {
 	return plugin.shiftMgr
 }
+
+// PlugAuthentication -- return Authentication plug.
+func (plugin *Plugin) PlugAuthentication() authentication.AuthenticationHandler {
+	return plugin.authentication
+}
+
+// PlugFirewall -- return Firewall plug.
+func (plugin *Plugin) PlugFirewall() firewall.FirewallHandler {
+	return plugin.firewall
+}
+
+// PlugRewrite -- return Rewrite plug.
+func (plugin *Plugin) PlugRewrite() rewrite.RewriteHandler {
+	return plugin.rewrite
+}
+
+// PlugMasking -- return Masking plug.
+func (plugin *Plugin) PlugMasking() masking.MaskingHandler {
+	return plugin.masking
+}
+
+// PlugEncryption -- return Encryption plug.
+func (plugin *Plugin) PlugEncryption() encryption.EncryptionHandler {
+	return plugin.encryption
+}
@@ -40,4 +40,19 @@ func TestPlugins(t *testing.T) {
 
 	shiftMgrPlug := plugin.PlugShiftMgr()
 	assert.NotNil(t, shiftMgrPlug)
+
+	authPlug := plugin.PlugAuthentication()
+	assert.NotNil(t, authPlug)
+
+	firewallPlug := plugin.PlugFirewall()
+	assert.NotNil(t, firewallPlug)
+
+	rewritePlug := plugin.PlugRewrite()
+	assert.NotNil(t, rewritePlug)
+
+	maskingPlug := plugin.PlugMasking()
+	assert.NotNil(t, maskingPlug)
+
+	encryptionPlug := plugin.PlugEncryption()
+	assert.NotNil(t, encryptionPlug)
 }
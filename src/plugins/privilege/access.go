@@ -0,0 +1,308 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package privilege
+
+import (
+	"strings"
+
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+)
+
+// access tuple, the privilege required on the table.
+// The table level privilege is required if all is true, such as 'select *',
+// otherwise the privilege on the columns, or on any column if the columns is empty.
+type access struct {
+	priv    string
+	db      string
+	table   string
+	all     bool
+	columns []string
+}
+
+// tableRef tuple, the table in the scope, the name is empty for the derived table.
+type tableRef struct {
+	db    string
+	name  string
+	alias string
+}
+
+// accessResolver tuple, resolves the tables and columns touched by the statement through the AST.
+type accessResolver struct {
+	database string
+	scopes   [][]tableRef
+	accesses []*access
+}
+
+// resolveAccesses returns the accesses of the DML statement, false if the statement isn't checked by the tables.
+func resolveAccesses(database string, node sqlparser.Statement) ([]*access, bool) {
+	r := &accessResolver{database: database}
+	switch node := node.(type) {
+	case sqlparser.SelectStatement:
+		r.selectStatement(node)
+	case *sqlparser.Insert:
+		r.insert(node)
+	case *sqlparser.Update:
+		r.update(node)
+	case *sqlparser.Delete:
+		r.delete(node)
+	case *sqlparser.Checksum:
+		for _, table := range node.Tables {
+			r.add(PrivSelect, r.tableRef(table), true, "")
+		}
+	default:
+		return nil, false
+	}
+	return r.accesses, true
+}
+
+// add used to add the access of the table, the column is empty for the table level or any column.
+func (r *accessResolver) add(priv string, ref tableRef, all bool, column string) {
+	var acc *access
+	for _, a := range r.accesses {
+		if a.priv == priv && a.db == ref.db && a.table == ref.name {
+			acc = a
+			break
+		}
+	}
+	if acc == nil {
+		acc = &access{priv: priv, db: ref.db, table: ref.name}
+		r.accesses = append(r.accesses, acc)
+	}
+	acc.all = acc.all || all
+	if column != "" && !containsString(acc.columns, column) {
+		acc.columns = append(acc.columns, column)
+	}
+}
+
+func (r *accessResolver) tableRef(table sqlparser.TableName) tableRef {
+	db := r.database
+	if !table.Qualifier.IsEmpty() {
+		db = table.Qualifier.String()
+	}
+	return tableRef{db: db, name: table.Name.String(), alias: table.Name.String()}
+}
+
+// matches returns true if the column qualifier refers to the table.
+func (ref tableRef) matches(qualifier sqlparser.TableName) bool {
+	if qualifier.Qualifier.IsEmpty() {
+		return qualifier.Name.String() == ref.alias
+	}
+	return qualifier.Qualifier.String() == ref.db && qualifier.Name.String() == ref.name && ref.alias == ref.name
+}
+
+// pushScope used to enter the scope of the tables, returns the function to leave it.
+func (r *accessResolver) pushScope(scope []tableRef) func() {
+	r.scopes = append(r.scopes, scope)
+	return func() {
+		r.scopes = r.scopes[:len(r.scopes)-1]
+	}
+}
+
+// tables used to collect the tables of the FROM clause into the scope, and the join conditions.
+func (r *accessResolver) tables(exprs sqlparser.TableExprs, scope *[]tableRef, ons *[]sqlparser.Expr) {
+	for _, expr := range exprs {
+		switch expr := expr.(type) {
+		case *sqlparser.AliasedTableExpr:
+			switch table := expr.Expr.(type) {
+			case sqlparser.TableName:
+				if table.Qualifier.IsEmpty() && strings.EqualFold(table.Name.String(), "dual") {
+					continue
+				}
+				ref := r.tableRef(table)
+				if !expr.As.IsEmpty() {
+					ref.alias = expr.As.String()
+				}
+				*scope = append(*scope, ref)
+			case *sqlparser.Subquery:
+				// The derived table, its columns are checked in the subquery.
+				r.selectStatement(table.Select)
+				*scope = append(*scope, tableRef{alias: expr.As.String()})
+			}
+		case *sqlparser.ParenTableExpr:
+			r.tables(expr.Exprs, scope, ons)
+		case *sqlparser.JoinTableExpr:
+			r.tables(sqlparser.TableExprs{expr.LeftExpr, expr.RightExpr}, scope, ons)
+			if expr.On != nil {
+				*ons = append(*ons, expr.On)
+			}
+		}
+	}
+}
+
+func (r *accessResolver) selectStatement(node sqlparser.SelectStatement) {
+	switch node := node.(type) {
+	case *sqlparser.Select:
+		r.selectNode(node)
+	case *sqlparser.Union:
+		r.selectStatement(node.Left)
+		r.selectStatement(node.Right)
+	case *sqlparser.ParenSelect:
+		r.selectStatement(node.Select)
+	}
+}
+
+func (r *accessResolver) selectNode(node *sqlparser.Select) {
+	var scope []tableRef
+	var ons []sqlparser.Expr
+	r.tables(node.From, &scope, &ons)
+	defer r.pushScope(scope)()
+
+	aliases := make(map[string]bool)
+	for _, expr := range node.SelectExprs {
+		switch expr := expr.(type) {
+		case *sqlparser.StarExpr:
+			for _, ref := range scope {
+				if ref.name != "" && (expr.TableName.IsEmpty() || ref.matches(expr.TableName)) {
+					r.add(PrivSelect, ref, true, "")
+				}
+			}
+		case *sqlparser.AliasedExpr:
+			r.expr(PrivSelect, expr.Expr, nil)
+			if !expr.As.IsEmpty() {
+				aliases[expr.As.Lowered()] = true
+			}
+		case sqlparser.Nextval:
+			r.expr(PrivSelect, expr.Expr, nil)
+		}
+	}
+	for _, on := range ons {
+		r.expr(PrivSelect, on, nil)
+	}
+	if node.Where != nil {
+		r.expr(PrivSelect, node.Where.Expr, nil)
+	}
+	// The GROUP BY, HAVING and ORDER BY can refer to the select aliases.
+	r.expr(PrivSelect, node.GroupBy, aliases)
+	if node.Having != nil {
+		r.expr(PrivSelect, node.Having.Expr, aliases)
+	}
+	r.expr(PrivSelect, node.OrderBy, aliases)
+
+	// The table without any column touched, such as 'select count(*) from t1', requires the privilege on any column.
+	for _, ref := range scope {
+		if ref.name != "" {
+			r.add(PrivSelect, ref, false, "")
+		}
+	}
+}
+
+func (r *accessResolver) insert(node *sqlparser.Insert) {
+	ref := r.tableRef(node.Table)
+	if len(node.Columns) == 0 {
+		r.add(PrivInsert, ref, true, "")
+	}
+	for _, column := range node.Columns {
+		r.add(PrivInsert, ref, false, column.Lowered())
+	}
+
+	switch rows := node.Rows.(type) {
+	case sqlparser.SelectStatement:
+		r.selectStatement(rows)
+	case sqlparser.Values:
+		r.expr(PrivSelect, rows, nil)
+	}
+
+	defer r.pushScope([]tableRef{ref})()
+	for _, expr := range node.OnDup {
+		r.add(PrivUpdate, ref, false, expr.Name.Name.Lowered())
+		r.expr(PrivSelect, expr.Expr, nil)
+	}
+}
+
+func (r *accessResolver) update(node *sqlparser.Update) {
+	ref := r.tableRef(node.Table)
+	defer r.pushScope([]tableRef{ref})()
+
+	for _, expr := range node.Exprs {
+		r.add(PrivUpdate, ref, false, expr.Name.Name.Lowered())
+		r.expr(PrivSelect, expr.Expr, nil)
+	}
+	if node.Where != nil {
+		r.expr(PrivSelect, node.Where.Expr, nil)
+	}
+	r.expr(PrivSelect, node.OrderBy, nil)
+}
+
+func (r *accessResolver) delete(node *sqlparser.Delete) {
+	var scope []tableRef
+	var ons []sqlparser.Expr
+	r.tables(node.TableRefs, &scope, &ons)
+	defer r.pushScope(scope)()
+
+	// The multiple-table delete only deletes the rows of the tables before the FROM.
+	for _, ref := range scope {
+		if ref.name == "" {
+			continue
+		}
+		target := len(node.TableList) == 0
+		for _, table := range node.TableList {
+			target = target || ref.matches(table)
+		}
+		if target {
+			r.add(PrivDelete, ref, true, "")
+		} else {
+			r.add(PrivSelect, ref, false, "")
+		}
+	}
+
+	for _, on := range ons {
+		r.expr(PrivSelect, on, nil)
+	}
+	if node.Where != nil {
+		r.expr(PrivSelect, node.Where.Expr, nil)
+	}
+	r.expr(PrivSelect, node.OrderBy, nil)
+}
+
+// expr used to resolve the columns and subqueries of the expression, the unqualified column in
+// the aliases refers to the select expression.
+func (r *accessResolver) expr(priv string, node sqlparser.SQLNode, aliases map[string]bool) {
+	sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+		switch node := node.(type) {
+		case *sqlparser.Subquery:
+			r.selectStatement(node.Select)
+			return false, nil
+		case *sqlparser.ColName:
+			if !(node.Qualifier.IsEmpty() && aliases[node.Name.Lowered()]) {
+				r.column(priv, node)
+			}
+			return false, nil
+		}
+		return true, nil
+	}, node)
+}
+
+// column used to resolve the column to the table, from the innermost scope to the outer ones.
+// The schema is unknown here, so the unqualified column is resolved to all the tables of the
+// innermost non-empty scope.
+func (r *accessResolver) column(priv string, column *sqlparser.ColName) {
+	name := column.Name.Lowered()
+	for i := len(r.scopes) - 1; i >= 0; i-- {
+		scope := r.scopes[i]
+		if column.Qualifier.IsEmpty() {
+			if len(scope) == 0 {
+				continue
+			}
+			for _, ref := range scope {
+				if ref.name != "" {
+					r.add(priv, ref, false, name)
+				}
+			}
+			return
+		}
+		for _, ref := range scope {
+			if ref.matches(column.Qualifier) {
+				if ref.name != "" {
+					r.add(priv, ref, false, name)
+				}
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,155 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package privilege
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"backend"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func formatAccesses(accesses []*access) string {
+	var res []string
+	for _, acc := range accesses {
+		columns := strings.Join(acc.columns, ",")
+		if acc.all {
+			columns = "*"
+		}
+		res = append(res, fmt.Sprintf("%s %s.%s(%s)", acc.priv, acc.db, acc.table, columns))
+	}
+	return strings.Join(res, "; ")
+}
+
+func TestResolveAccesses(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"select a, b from t1", "SELECT db1.t1(a,b)"},
+		{"select * from t1 where a=1", "SELECT db1.t1(*)"},
+		{"select t1.a, T2.b from t1 join db2.t2 as T2 on t1.id=T2.id", "SELECT db1.t1(a,id); SELECT db2.t2(b,id)"},
+		{"select x.* , y.a from t1 x, t2 y", "SELECT db1.t1(*); SELECT db1.t2(a)"},
+		{"select a from t1, t2", "SELECT db1.t1(a); SELECT db1.t2(a)"},
+		{"select count(*) from db2.t1", "SELECT db2.t1()"},
+		{"select a as x from t1 group by x having x > 1 order by x", "SELECT db1.t1(a)"},
+		{"select a from t1 where b in (select c from t2 where t2.d = t1.e)", "SELECT db1.t1(a,b,e); SELECT db1.t2(c,d)"},
+		{"select d.a from (select a from t1) as d", "SELECT db1.t1(a)"},
+		{"select a from t1 union select b from t2", "SELECT db1.t1(a); SELECT db1.t2(b)"},
+		{"select 1 from dual", ""},
+		{"select 1", ""},
+		{"insert into t1(a, B) values(1, 2)", "INSERT db1.t1(a,b)"},
+		{"insert into t1 values(1, 2)", "INSERT db1.t1(*)"},
+		{"insert into t1(a) values(1) on duplicate key update b=b+1", "INSERT db1.t1(a); UPDATE db1.t1(b); SELECT db1.t1(b)"},
+		{"insert into t1(a) select b from db2.t2", "INSERT db1.t1(a); SELECT db2.t2(b)"},
+		{"update t1 set a=b+1 where c=1", "UPDATE db1.t1(a); SELECT db1.t1(b,c)"},
+		{"update t1 set a=1", "UPDATE db1.t1(a)"},
+		{"delete from t1", "DELETE db1.t1(*)"},
+		{"delete from db2.t1 where a=1", "DELETE db2.t1(*); SELECT db2.t1(a)"},
+		{"delete t1 from t1 join t2 on t1.id=t2.id where t2.a=1", "DELETE db1.t1(*); SELECT db1.t2(id,a); SELECT db1.t1(id)"},
+		{"checksum table t1, db2.t2", "SELECT db1.t1(*); SELECT db2.t2(*)"},
+	}
+	for _, test := range tests {
+		node, err := sqlparser.Parse(test.query)
+		assert.Nil(t, err, test.query)
+		accesses, ok := resolveAccesses("db1", node)
+		assert.True(t, ok, test.query)
+		assert.Equal(t, test.want, formatAccesses(accesses), test.query)
+	}
+
+	for _, query := range []string{"show tables", "create table t1(a int)", "set autocommit=0"} {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		_, ok := resolveAccesses("db1", node)
+		assert.False(t, ok, query)
+	}
+}
+
+func TestCheckTablePrivilege(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	scatter, fakedbs, cleanup := backend.MockScatter(log, 3)
+	defer cleanup()
+
+	// The backend account 'mock' with the table and column privileges.
+	TablesPrivRs.Rows = [][]sqltypes.Value{
+		{
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("db1")),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("t1")),
+			sqltypes.MakeTrusted(querypb.Type_SET, []byte("Select,Insert")),
+		},
+	}
+	ColumnsPrivRs.Rows = [][]sqltypes.Value{
+		{
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("db1")),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("t2")),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("A")),
+			sqltypes.MakeTrusted(querypb.Type_SET, []byte("Select,Update")),
+		},
+		{
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("db1")),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("t2")),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("b")),
+			sqltypes.MakeTrusted(querypb.Type_SET, []byte("Select")),
+		},
+	}
+	defer func() {
+		TablesPrivRs.Rows = nil
+		ColumnsPrivRs.Rows = nil
+	}()
+	MockInitPrivilegeN(fakedbs)
+
+	handler := NewPrivilege(log, nil, scatter)
+	err := handler.Init()
+	assert.Nil(t, err)
+	defer handler.Close()
+
+	tests := []struct {
+		sql string
+		err string
+	}{
+		{"select * from t1", ""},
+		{"insert into t1 values(1)", ""},
+		{"select a, b from t2 where a > 1", ""},
+		{"select count(*) from t2", ""},
+		{"select t1.*, t2.a from t1 join t2 on t1.id=t2.b", ""},
+		{"update t2 set a=1 where b=2", ""},
+		{"select * from db1.t2", "SELECT command denied to user 'mock'@'%' for table 't2' (errno 1142) (sqlstate 42000)"},
+		{"select a, c from t2", "SELECT command denied to user 'mock'@'%' for column 'c' in table 't2' (errno 1143) (sqlstate 42000)"},
+		{"select a from t1, t2", ""},
+		{"select c from t1, t2", "SELECT command denied to user 'mock'@'%' for column 'c' in table 't2' (errno 1143) (sqlstate 42000)"},
+		{"update t1 set a=1", "UPDATE command denied to user 'mock'@'%' for column 'a' in table 't1' (errno 1143) (sqlstate 42000)"},
+		{"update t2 set b=1", "UPDATE command denied to user 'mock'@'%' for column 'b' in table 't2' (errno 1143) (sqlstate 42000)"},
+		{"delete from t2", "DELETE command denied to user 'mock'@'%' for table 't2' (errno 1142) (sqlstate 42000)"},
+		{"select a from t3", "SELECT command denied to user 'mock'@'%' for table 't3' (errno 1142) (sqlstate 42000)"},
+		{"select a from t2 where c in (select id from t1)", "SELECT command denied to user 'mock'@'%' for column 'c' in table 't2' (errno 1143) (sqlstate 42000)"},
+		{"select a from db2.t1", "Access denied for user 'mock'@'%' to database 'db2' (errno 1045) (sqlstate 28000)"},
+		{"checksum table t1", ""},
+		{"checksum table t2", "SELECT command denied to user 'mock'@'%' for table 't2' (errno 1142) (sqlstate 42000)"},
+	}
+	for _, test := range tests {
+		node, err := sqlparser.Parse(test.sql)
+		assert.Nil(t, err)
+		err = handler.Check("db1", "mock", node)
+		errmsg := ""
+		if err != nil {
+			errmsg = err.Error()
+		}
+		assert.Equal(t, test.err, errmsg, test.sql)
+	}
+
+	// The database with the table privileges can be used.
+	assert.True(t, handler.CheckDBinUserPrivilege("mock", "db1"))
+}
@@ -0,0 +1,703 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package privilege
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"config"
+
+	"github.com/pkg/errors"
+	"github.com/xelabs/go-mysqlstack/sqldb"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+const (
+	userjson = "user.json"
+
+	// AllDatabases is the database of the global grant '*.*'.
+	AllDatabases = "*"
+)
+
+// The privileges supported by the catalog.
+const (
+	PrivSelect        = "SELECT"
+	PrivInsert        = "INSERT"
+	PrivUpdate        = "UPDATE"
+	PrivDelete        = "DELETE"
+	PrivCreate        = "CREATE"
+	PrivDrop          = "DROP"
+	PrivAlter         = "ALTER"
+	PrivIndex         = "INDEX"
+	PrivShowDatabases = "SHOW DATABASES"
+	PrivSuper         = "SUPER"
+	PrivGrantOption   = "GRANT OPTION"
+	PrivAll           = "ALL PRIVILEGES"
+)
+
+var (
+	// dbPrivileges are the privileges can be granted on the database.
+	dbPrivileges = []string{PrivSelect, PrivInsert, PrivUpdate, PrivDelete, PrivCreate, PrivDrop, PrivAlter, PrivIndex, PrivGrantOption}
+
+	// globalPrivileges are the privileges can only be granted on '*.*'.
+	globalPrivileges = []string{PrivShowDatabases, PrivSuper}
+
+	// columnPrivileges are the privileges can be granted on the column.
+	columnPrivileges = []string{PrivSelect, PrivInsert, PrivUpdate}
+)
+
+// Grant tuple, the privileges on the database('*' for all), or the table if the Table isn't empty,
+// or the column of the table if the Column isn't empty.
+type Grant struct {
+	Database   string   `json:"database"`
+	Table      string   `json:"table,omitempty"`
+	Column     string   `json:"column,omitempty"`
+	Privileges []string `json:"privileges"`
+}
+
+// User tuple.
+type User struct {
+	Name                 string     `json:"name"`
+	AuthenticationString string     `json:"authentication-string"`
+	Roles                []string   `json:"roles,omitempty"`
+	Grants               []*Grant   `json:"grants,omitempty"`
+	Resources            *Resources `json:"resources,omitempty"`
+}
+
+// Role tuple.
+type Role struct {
+	Name   string   `json:"name"`
+	Grants []*Grant `json:"grants,omitempty"`
+}
+
+// CatalogConfig tuple, the content of the metadir/user.json.
+type CatalogConfig struct {
+	Users []*User `json:"users"`
+	Roles []*Role `json:"roles"`
+}
+
+// Catalog tuple, the radon-native users and roles, persisted in the metadir and synced by the syncer.
+type Catalog struct {
+	mu      sync.RWMutex
+	log     *xlog.Log
+	metadir string
+	users   map[string]*User
+	roles   map[string]*Role
+}
+
+// NewCatalog creates the new catalog, it's in memory only if the metadir is empty.
+func NewCatalog(log *xlog.Log, metadir string) *Catalog {
+	return &Catalog{
+		log:     log,
+		metadir: metadir,
+		users:   make(map[string]*User),
+		roles:   make(map[string]*Role),
+	}
+}
+
+// NormalizePrivilege returns the catalog privilege name, such as 'select' to 'SELECT', 'all' to 'ALL PRIVILEGES'.
+func NormalizePrivilege(priv string) (string, error) {
+	name := strings.ToUpper(strings.Join(strings.Fields(priv), " "))
+	switch name {
+	case "ALL", PrivAll:
+		return PrivAll, nil
+	}
+	for _, p := range append(dbPrivileges, globalPrivileges...) {
+		if p == name {
+			return name, nil
+		}
+	}
+	return "", sqldb.NewSQLError1(1064, "42000", "Illegal privilege '%s'", priv)
+}
+
+func accountName(name string) string {
+	return fmt.Sprintf("'%s'@'%%'", name)
+}
+
+func operationFailed(op string, name string) error {
+	return sqldb.NewSQLError1(1396, "HY000", "Operation %s failed for %s", op, accountName(name))
+}
+
+// LoadConfig used to load the users and roles from the metadir/user.json.
+func (c *Catalog) LoadConfig() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	users := make(map[string]*User)
+	roles := make(map[string]*Role)
+	if c.metadir != "" {
+		file := path.Join(c.metadir, userjson)
+		data, err := ioutil.ReadFile(file)
+		if err != nil && !os.IsNotExist(err) {
+			c.log.Error("privilege.catalog.load.from.file[%v].error:%v", file, err)
+			return errors.WithStack(err)
+		}
+		if err == nil {
+			conf := &CatalogConfig{}
+			if err := json.Unmarshal(data, conf); err != nil {
+				c.log.Error("privilege.catalog.parse.json.file[%v].error:%v", file, err)
+				return errors.WithStack(err)
+			}
+			for _, user := range conf.Users {
+				users[user.Name] = user
+			}
+			for _, role := range conf.Roles {
+				roles[role.Name] = role
+			}
+		}
+	}
+	c.users = users
+	c.roles = roles
+	return nil
+}
+
+// flush used to write the catalog to the metadir/user.json and update the meta version, the lock must be held.
+func (c *Catalog) flush() error {
+	if c.metadir == "" {
+		return nil
+	}
+
+	conf := &CatalogConfig{Users: []*User{}, Roles: []*Role{}}
+	for _, user := range c.users {
+		conf.Users = append(conf.Users, user)
+	}
+	for _, role := range c.roles {
+		conf.Roles = append(conf.Roles, role)
+	}
+	sort.Slice(conf.Users, func(i, j int) bool { return conf.Users[i].Name < conf.Users[j].Name })
+	sort.Slice(conf.Roles, func(i, j int) bool { return conf.Roles[i].Name < conf.Roles[j].Name })
+
+	file := path.Join(c.metadir, userjson)
+	if err := config.WriteConfig(file, conf); err != nil {
+		c.log.Error("privilege.catalog.flush.to.file[%v].error:%v", file, err)
+		return err
+	}
+	if err := config.UpdateVersion(c.metadir); err != nil {
+		c.log.Error("privilege.catalog.flush.update.version.error:%v", err)
+		return err
+	}
+	return nil
+}
+
+// CreateUser used to create the user with the authentication string.
+func (c *Catalog) CreateUser(name string, authString string, ifNotExists bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.users[name]; ok {
+		if ifNotExists {
+			return nil
+		}
+		return operationFailed("CREATE USER", name)
+	}
+	if _, ok := c.roles[name]; ok {
+		return operationFailed("CREATE USER", name)
+	}
+	c.users[name] = &User{Name: name, AuthenticationString: authString}
+	return c.flush()
+}
+
+// AlterUser used to change the authentication string of the user.
+func (c *Catalog) AlterUser(name string, authString string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	user, ok := c.users[name]
+	if !ok {
+		return operationFailed("ALTER USER", name)
+	}
+	c.users[name] = &User{Name: name, AuthenticationString: authString, Roles: user.Roles, Grants: user.Grants, Resources: user.Resources}
+	return c.flush()
+}
+
+// DropUser used to drop the user.
+func (c *Catalog) DropUser(name string, ifExists bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.users[name]; !ok {
+		if ifExists {
+			return nil
+		}
+		return operationFailed("DROP USER", name)
+	}
+	delete(c.users, name)
+	return c.flush()
+}
+
+// CreateRole used to create the role.
+func (c *Catalog) CreateRole(name string, ifNotExists bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.roles[name]; ok {
+		if ifNotExists {
+			return nil
+		}
+		return operationFailed("CREATE ROLE", name)
+	}
+	if _, ok := c.users[name]; ok {
+		return operationFailed("CREATE ROLE", name)
+	}
+	c.roles[name] = &Role{Name: name}
+	return c.flush()
+}
+
+// DropRole used to drop the role and revoke it from all the users.
+func (c *Catalog) DropRole(name string, ifExists bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.roles[name]; !ok {
+		if ifExists {
+			return nil
+		}
+		return operationFailed("DROP ROLE", name)
+	}
+	delete(c.roles, name)
+	for _, user := range c.users {
+		if roles := removeString(user.Roles, name); len(roles) != len(user.Roles) {
+			c.users[user.Name] = &User{Name: user.Name, AuthenticationString: user.AuthenticationString, Roles: roles, Grants: user.Grants, Resources: user.Resources}
+		}
+	}
+	return c.flush()
+}
+
+// grantsOf returns the grants of the user or role, the lock must be held.
+func (c *Catalog) grantsOf(name string) ([]*Grant, bool) {
+	if user, ok := c.users[name]; ok {
+		return user.Grants, true
+	}
+	if role, ok := c.roles[name]; ok {
+		return role.Grants, true
+	}
+	return nil, false
+}
+
+// setGrants used to replace the grants of the user or role, the lock must be held.
+func (c *Catalog) setGrants(name string, grants []*Grant) {
+	if user, ok := c.users[name]; ok {
+		c.users[name] = &User{Name: name, AuthenticationString: user.AuthenticationString, Roles: user.Roles, Grants: grants, Resources: user.Resources}
+		return
+	}
+	c.roles[name] = &Role{Name: name, Grants: grants}
+}
+
+func illegalGrant() error {
+	return sqldb.NewSQLError1(1144, "42000", "Illegal GRANT/REVOKE command; please consult the manual to see which privileges can be used")
+}
+
+// expandPrivileges used to expand the 'ALL PRIVILEGES' and check the privileges are allowed on the level.
+func expandPrivileges(privs []string, database string, table string, column bool) ([]string, error) {
+	var expanded []string
+	for _, priv := range privs {
+		switch {
+		case column && !containsString(columnPrivileges, priv):
+			return nil, illegalGrant()
+		case priv == PrivAll:
+			// The 'ALL PRIVILEGES' doesn't include the 'GRANT OPTION'.
+			expanded = append(expanded, removeString(dbPrivileges, PrivGrantOption)...)
+			if database == AllDatabases {
+				expanded = append(expanded, globalPrivileges...)
+			}
+		case containsString(globalPrivileges, priv) && table != "":
+			return nil, illegalGrant()
+		case containsString(globalPrivileges, priv) && database != AllDatabases:
+			return nil, sqldb.NewSQLError1(1221, "HY000", "Incorrect usage of DB GRANT and GLOBAL PRIVILEGES")
+		default:
+			expanded = append(expanded, priv)
+		}
+	}
+	return expanded, nil
+}
+
+// Grant used to grant the privileges on the database('*' for all) to the user or role.
+func (c *Catalog) Grant(privs []string, database string, grantee string) error {
+	return c.GrantTable(privs, nil, database, "", grantee)
+}
+
+// Revoke used to revoke the privileges on the database from the user or role.
+func (c *Catalog) Revoke(privs []string, database string, grantee string) error {
+	return c.RevokeTable(privs, nil, database, "", grantee)
+}
+
+// GrantTable used to grant the privileges on the table to the user or role, the privileges are granted
+// on the columns if the columns isn't empty, and on the database if the table is empty.
+func (c *Catalog) GrantTable(privs []string, columns []string, database string, table string, grantee string) error {
+	return c.change(privs, columns, database, table, grantee, false)
+}
+
+// RevokeTable used to revoke the privileges on the table or columns from the user or role.
+func (c *Catalog) RevokeTable(privs []string, columns []string, database string, table string, grantee string) error {
+	return c.change(privs, columns, database, table, grantee, true)
+}
+
+// change used to add the privileges to or remove them from the grants of the user or role.
+func (c *Catalog) change(privs []string, columns []string, database string, table string, grantee string, revoke bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	grants, ok := c.grantsOf(grantee)
+	if !ok {
+		if revoke {
+			return sqldb.NewSQLError1(1141, "42000", "There is no such grant defined for user '%s' on host '%%'", grantee)
+		}
+		return sqldb.NewSQLError1(1133, "42000", "Can't find any matching row in the user table")
+	}
+	if len(columns) > 0 && table == "" {
+		return illegalGrant()
+	}
+	expanded, err := expandPrivileges(privs, database, table, len(columns) > 0)
+	if err != nil {
+		return err
+	}
+
+	levels := []Grant{{Database: database, Table: table}}
+	if len(columns) > 0 {
+		levels = levels[:0]
+		for _, column := range columns {
+			levels = append(levels, Grant{Database: database, Table: table, Column: strings.ToLower(column)})
+		}
+	}
+	for _, level := range levels {
+		grants = changeGrants(grants, level, expanded, revoke)
+	}
+	c.setGrants(grantee, grants)
+	return c.flush()
+}
+
+// changeGrants returns the new grants with the privileges added to or removed from the grant on the level.
+func changeGrants(grants []*Grant, level Grant, privs []string, revoke bool) []*Grant {
+	var newGrants []*Grant
+	found := false
+	for _, grant := range grants {
+		if grant.Database == level.Database && grant.Table == level.Table && grant.Column == level.Column {
+			found = true
+			left := unionStrings(grant.Privileges, privs)
+			if revoke {
+				left = grant.Privileges
+				for _, priv := range privs {
+					left = removeString(left, priv)
+				}
+			}
+			if len(left) == 0 {
+				continue
+			}
+			grant = &Grant{Database: level.Database, Table: level.Table, Column: level.Column, Privileges: left}
+		}
+		newGrants = append(newGrants, grant)
+	}
+	if !found && !revoke {
+		newGrants = append(newGrants, &Grant{Database: level.Database, Table: level.Table, Column: level.Column, Privileges: unionStrings(nil, privs)})
+	}
+	return newGrants
+}
+
+// GrantRole used to grant the role to the user.
+func (c *Catalog) GrantRole(role string, user string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.roles[role]; !ok {
+		return sqldb.NewSQLError1(3523, "HY000", "Unknown authorization ID `%s`@`%%`", role)
+	}
+	u, ok := c.users[user]
+	if !ok {
+		return sqldb.NewSQLError1(3523, "HY000", "Unknown authorization ID `%s`@`%%`", user)
+	}
+	c.users[user] = &User{Name: user, AuthenticationString: u.AuthenticationString, Roles: unionStrings(u.Roles, []string{role}), Grants: u.Grants, Resources: u.Resources}
+	return c.flush()
+}
+
+// RevokeRole used to revoke the role from the user.
+func (c *Catalog) RevokeRole(role string, user string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	u, ok := c.users[user]
+	if !ok {
+		return sqldb.NewSQLError1(3523, "HY000", "Unknown authorization ID `%s`@`%%`", user)
+	}
+	c.users[user] = &User{Name: user, AuthenticationString: u.AuthenticationString, Roles: removeString(u.Roles, role), Grants: u.Grants, Resources: u.Resources}
+	return c.flush()
+}
+
+// AuthenticationString returns the authentication string of the user, false if the user isn't in the catalog.
+func (c *Catalog) AuthenticationString(name string) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	user, ok := c.users[name]
+	if !ok {
+		return "", false
+	}
+	return user.AuthenticationString, true
+}
+
+// UserRoles returns the roles granted to the user, nil if the user isn't in the catalog.
+func (c *Catalog) UserRoles(name string) []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	user, ok := c.users[name]
+	if !ok {
+		return nil
+	}
+	return append([]string(nil), user.Roles...)
+}
+
+// Users returns the sorted user names.
+func (c *Catalog) Users() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var names []string
+	for name := range c.users {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Roles returns the sorted role names.
+func (c *Catalog) Roles() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var names []string
+	for name := range c.roles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// formatGrant returns the 'GRANT ... ON ... TO ...' row, the 'ALL PRIVILEGES' is used if all the privileges
+// allowed on the level are granted, the columns are the column privileges of the table.
+func formatGrant(privs []string, allowed []string, columns map[string][]string, on string, name string) string {
+	var names []string
+	for _, priv := range allowed {
+		if priv != PrivGrantOption && containsString(privs, priv) {
+			names = append(names, priv)
+		}
+	}
+	if len(names) == len(allowed)-1 {
+		names = []string{PrivAll}
+	}
+	for _, priv := range columnPrivileges {
+		if cols := columns[priv]; len(cols) > 0 {
+			sort.Strings(cols)
+			names = append(names, fmt.Sprintf("%s (`%s`)", priv, strings.Join(cols, "`, `")))
+		}
+	}
+	if len(names) == 0 {
+		names = []string{"USAGE"}
+	}
+
+	row := fmt.Sprintf("GRANT %s ON %s TO %s", strings.Join(names, ", "), on, accountName(name))
+	if containsString(privs, PrivGrantOption) {
+		row += " WITH GRANT OPTION"
+	}
+	return row
+}
+
+// tableGrants tuple, the table and column grants of one table for the 'SHOW GRANTS'.
+type tableGrants struct {
+	database   string
+	table      string
+	privileges []string
+	columns    map[string][]string
+}
+
+// ShowGrants returns the 'SHOW GRANTS' rows of the user or role.
+func (c *Catalog) ShowGrants(name string) ([]string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	grants, ok := c.grantsOf(name)
+	if !ok {
+		return nil, sqldb.NewSQLError1(1141, "42000", "There is no such grant defined for user '%s' on host '%%'", name)
+	}
+
+	var global []string
+	var dbs []*Grant
+	var tables []*tableGrants
+	for _, grant := range grants {
+		switch {
+		case grant.Database == AllDatabases:
+			global = grant.Privileges
+		case grant.Table == "":
+			dbs = append(dbs, grant)
+		default:
+			var tg *tableGrants
+			for _, t := range tables {
+				if t.database == grant.Database && t.table == grant.Table {
+					tg = t
+				}
+			}
+			if tg == nil {
+				tg = &tableGrants{database: grant.Database, table: grant.Table, columns: make(map[string][]string)}
+				tables = append(tables, tg)
+			}
+			if grant.Column == "" {
+				tg.privileges = grant.Privileges
+				continue
+			}
+			for _, priv := range grant.Privileges {
+				tg.columns[priv] = append(tg.columns[priv], grant.Column)
+			}
+		}
+	}
+	sort.Slice(dbs, func(i, j int) bool { return dbs[i].Database < dbs[j].Database })
+	sort.Slice(tables, func(i, j int) bool {
+		if tables[i].database != tables[j].database {
+			return tables[i].database < tables[j].database
+		}
+		return tables[i].table < tables[j].table
+	})
+
+	rows := []string{formatGrant(global, append(append([]string{}, dbPrivileges...), globalPrivileges...), nil, "*.*", name)}
+	for _, grant := range dbs {
+		rows = append(rows, formatGrant(grant.Privileges, dbPrivileges, nil, fmt.Sprintf("`%s`.*", grant.Database), name))
+	}
+	for _, tg := range tables {
+		rows = append(rows, formatGrant(tg.privileges, dbPrivileges, tg.columns, fmt.Sprintf("`%s`.`%s`", tg.database, tg.table), name))
+	}
+	if user, ok := c.users[name]; ok && len(user.Roles) > 0 {
+		var roles []string
+		for _, role := range user.Roles {
+			roles = append(roles, accountName(role))
+		}
+		rows = append(rows, fmt.Sprintf("GRANT %s TO %s", strings.Join(roles, ", "), accountName(name)))
+	}
+	return rows, nil
+}
+
+// userPriv returns the effective privileges of the user with its roles, false if the user isn't in the catalog.
+func (c *Catalog) userPriv(name string) (userPriv, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	user, ok := c.users[name]
+	if !ok {
+		return userPriv{}, false
+	}
+
+	grants := append([]*Grant{}, user.Grants...)
+	for _, role := range user.Roles {
+		if r, ok := c.roles[role]; ok {
+			grants = append(grants, r.Grants...)
+		}
+	}
+
+	upriv := userPriv{host: "%", user: name, dbPrivs: make(map[string]dbPriv)}
+	for _, grant := range grants {
+		switch {
+		case grant.Database == AllDatabases:
+			upriv.priv = upriv.priv.merge(grant.Privileges)
+		case grant.Table == "":
+			dpriv := upriv.dbPrivs[grant.Database]
+			dpriv.host, dpriv.user, dpriv.db = "%", name, grant.Database
+			dpriv.priv = dpriv.priv.merge(grant.Privileges)
+			upriv.dbPrivs[grant.Database] = dpriv
+		default:
+			privs, column := grant.Privileges, grant.Column
+			setTablePriv(upriv.dbPrivs, "%", name, grant.Database, grant.Table, func(t *tablePriv) {
+				if column == "" {
+					t.priv = t.priv.merge(privs)
+					return
+				}
+				t.columns[column] = t.columns[column].merge(privs)
+			})
+		}
+	}
+	return upriv, true
+}
+
+// merge returns the privilege with the granted privilege names.
+func (p privilege) merge(privs []string) privilege {
+	for _, priv := range privs {
+		switch priv {
+		case PrivSelect:
+			p.selectPriv = true
+		case PrivInsert:
+			p.insertPriv = true
+		case PrivUpdate:
+			p.updatePriv = true
+		case PrivDelete:
+			p.deletePriv = true
+		case PrivCreate:
+			p.createPriv = true
+		case PrivDrop:
+			p.dropPriv = true
+		case PrivAlter:
+			p.alterPriv = true
+		case PrivIndex:
+			p.indexPriv = true
+		case PrivShowDatabases:
+			p.showDBPriv = true
+		case PrivSuper:
+			p.superPriv = true
+		case PrivGrantOption:
+			p.grantPriv = true
+		}
+	}
+	return p
+}
+
+// has returns true if the privilege is granted.
+func (p privilege) has(priv string) bool {
+	switch priv {
+	case PrivSelect:
+		return p.selectPriv
+	case PrivInsert:
+		return p.insertPriv
+	case PrivUpdate:
+		return p.updatePriv
+	case PrivDelete:
+		return p.deletePriv
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func removeString(list []string, s string) []string {
+	var res []string
+	for _, v := range list {
+		if v != s {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+func unionStrings(list []string, adds []string) []string {
+	res := append([]string{}, list...)
+	for _, v := range adds {
+		if !containsString(res, v) {
+			res = append(res, v)
+		}
+	}
+	return res
+}
@@ -0,0 +1,386 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package privilege
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"backend"
+	"config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestCatalog(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	metadir, err := ioutil.TempDir(os.TempDir(), "privilege_catalog_")
+	assert.Nil(t, err)
+	defer os.RemoveAll(metadir)
+
+	catalog := NewCatalog(log, metadir)
+	err = catalog.LoadConfig()
+	assert.Nil(t, err)
+
+	// Users and roles.
+	{
+		err := catalog.CreateUser("u1", "*xx", false)
+		assert.Nil(t, err)
+		err = catalog.CreateUser("u1", "*yy", true)
+		assert.Nil(t, err)
+		err = catalog.CreateUser("u2", "", false)
+		assert.Nil(t, err)
+		err = catalog.CreateRole("r1", false)
+		assert.Nil(t, err)
+		err = catalog.CreateRole("r1", true)
+		assert.Nil(t, err)
+
+		authString, ok := catalog.AuthenticationString("u1")
+		assert.True(t, ok)
+		assert.Equal(t, "*xx", authString)
+		assert.Equal(t, []string{"u1", "u2"}, catalog.Users())
+		assert.Equal(t, []string{"r1"}, catalog.Roles())
+		assert.True(t, config.ReadVersion(metadir) > 0)
+	}
+
+	// Grants.
+	{
+		err := catalog.Grant([]string{PrivSelect, PrivInsert}, "db1", "r1")
+		assert.Nil(t, err)
+		err = catalog.Grant([]string{PrivUpdate}, "db1", "r1")
+		assert.Nil(t, err)
+		err = catalog.GrantRole("r1", "u1")
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"r1"}, catalog.UserRoles("u1"))
+		assert.Nil(t, catalog.UserRoles("u3"))
+		err = catalog.Grant([]string{PrivShowDatabases}, AllDatabases, "u1")
+		assert.Nil(t, err)
+		err = catalog.Grant([]string{PrivAll, PrivGrantOption}, "db2", "u1")
+		assert.Nil(t, err)
+
+		grants, err := catalog.ShowGrants("u1")
+		assert.Nil(t, err)
+		want := []string{
+			"GRANT SHOW DATABASES ON *.* TO 'u1'@'%'",
+			"GRANT ALL PRIVILEGES ON `db2`.* TO 'u1'@'%' WITH GRANT OPTION",
+			"GRANT 'r1'@'%' TO 'u1'@'%'",
+		}
+		assert.Equal(t, want, grants)
+
+		grants, err = catalog.ShowGrants("r1")
+		assert.Nil(t, err)
+		want = []string{
+			"GRANT USAGE ON *.* TO 'r1'@'%'",
+			"GRANT SELECT, INSERT, UPDATE ON `db1`.* TO 'r1'@'%'",
+		}
+		assert.Equal(t, want, grants)
+
+		// The effective privileges with the role.
+		upriv, ok := catalog.userPriv("u1")
+		assert.True(t, ok)
+		assert.True(t, upriv.priv.showDBPriv)
+		assert.False(t, upriv.priv.selectPriv)
+		assert.True(t, upriv.dbPrivs["db1"].priv.selectPriv)
+		assert.True(t, upriv.dbPrivs["db1"].priv.updatePriv)
+		assert.False(t, upriv.dbPrivs["db1"].priv.deletePriv)
+		assert.True(t, upriv.dbPrivs["db2"].priv.grantPriv)
+		_, ok = catalog.userPriv("r1")
+		assert.False(t, ok)
+	}
+
+	// Reload from the metadir.
+	{
+		reloaded := NewCatalog(log, metadir)
+		err := reloaded.LoadConfig()
+		assert.Nil(t, err)
+		want, _ := catalog.ShowGrants("u1")
+		got, err := reloaded.ShowGrants("u1")
+		assert.Nil(t, err)
+		assert.Equal(t, want, got)
+	}
+
+	// Revoke.
+	{
+		err := catalog.Revoke([]string{PrivInsert, PrivUpdate}, "db1", "r1")
+		assert.Nil(t, err)
+		err = catalog.Revoke([]string{PrivAll}, "db2", "u1")
+		assert.Nil(t, err)
+		err = catalog.RevokeRole("r1", "u1")
+		assert.Nil(t, err)
+
+		grants, err := catalog.ShowGrants("u1")
+		assert.Nil(t, err)
+		want := []string{
+			"GRANT SHOW DATABASES ON *.* TO 'u1'@'%'",
+			"GRANT USAGE ON `db2`.* TO 'u1'@'%' WITH GRANT OPTION",
+		}
+		assert.Equal(t, want, grants)
+
+		grants, err = catalog.ShowGrants("r1")
+		assert.Nil(t, err)
+		assert.Equal(t, "GRANT SELECT ON `db1`.* TO 'r1'@'%'", grants[1])
+
+		err = catalog.Revoke([]string{PrivSelect}, "db1", "r1")
+		assert.Nil(t, err)
+		grants, err = catalog.ShowGrants("r1")
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(grants))
+	}
+
+	// Alter and drop.
+	{
+		err := catalog.GrantRole("r1", "u2")
+		assert.Nil(t, err)
+		err = catalog.AlterUser("u2", "*zz")
+		assert.Nil(t, err)
+		authString, _ := catalog.AuthenticationString("u2")
+		assert.Equal(t, "*zz", authString)
+
+		err = catalog.DropRole("r1", false)
+		assert.Nil(t, err)
+		grants, err := catalog.ShowGrants("u2")
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"GRANT USAGE ON *.* TO 'u2'@'%'"}, grants)
+
+		err = catalog.DropUser("u2", false)
+		assert.Nil(t, err)
+		err = catalog.DropUser("u2", true)
+		assert.Nil(t, err)
+		err = catalog.DropRole("r1", true)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"u1"}, catalog.Users())
+	}
+}
+
+func TestCatalogTableGrant(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	catalog := NewCatalog(log, "")
+	err := catalog.LoadConfig()
+	assert.Nil(t, err)
+	catalog.CreateUser("u1", "", false)
+
+	// Grants.
+	{
+		err := catalog.GrantTable([]string{PrivSelect, PrivInsert}, nil, "db1", "t1", "u1")
+		assert.Nil(t, err)
+		err = catalog.GrantTable([]string{PrivSelect}, []string{"B", "a"}, "db1", "t2", "u1")
+		assert.Nil(t, err)
+		err = catalog.GrantTable([]string{PrivUpdate}, []string{"a"}, "db1", "t2", "u1")
+		assert.Nil(t, err)
+		err = catalog.GrantTable([]string{PrivAll, PrivGrantOption}, nil, "db1", "t3", "u1")
+		assert.Nil(t, err)
+		err = catalog.GrantTable([]string{PrivDelete}, nil, "db1", "t2", "u1")
+		assert.Nil(t, err)
+
+		grants, err := catalog.ShowGrants("u1")
+		assert.Nil(t, err)
+		want := []string{
+			"GRANT USAGE ON *.* TO 'u1'@'%'",
+			"GRANT SELECT, INSERT ON `db1`.`t1` TO 'u1'@'%'",
+			"GRANT DELETE, SELECT (`a`, `b`), UPDATE (`a`) ON `db1`.`t2` TO 'u1'@'%'",
+			"GRANT ALL PRIVILEGES ON `db1`.`t3` TO 'u1'@'%' WITH GRANT OPTION",
+		}
+		assert.Equal(t, want, grants)
+
+		upriv, ok := catalog.userPriv("u1")
+		assert.True(t, ok)
+		dbpriv := upriv.dbPrivs["db1"]
+		assert.False(t, dbpriv.priv.selectPriv)
+		assert.True(t, dbpriv.tablePrivs["t1"].priv.insertPriv)
+		assert.True(t, dbpriv.tablePrivs["t2"].columns["b"].selectPriv)
+		assert.False(t, dbpriv.tablePrivs["t2"].columns["b"].updatePriv)
+		assert.True(t, dbpriv.tablePrivs["t2"].columns["a"].updatePriv)
+	}
+
+	// Revoke.
+	{
+		err := catalog.RevokeTable([]string{PrivSelect}, []string{"a", "b"}, "db1", "t2", "u1")
+		assert.Nil(t, err)
+		err = catalog.RevokeTable([]string{PrivAll}, nil, "db1", "t3", "u1")
+		assert.Nil(t, err)
+		err = catalog.RevokeTable([]string{PrivSelect, PrivInsert}, nil, "db1", "t1", "u1")
+		assert.Nil(t, err)
+
+		grants, err := catalog.ShowGrants("u1")
+		assert.Nil(t, err)
+		want := []string{
+			"GRANT USAGE ON *.* TO 'u1'@'%'",
+			"GRANT DELETE, UPDATE (`a`) ON `db1`.`t2` TO 'u1'@'%'",
+			"GRANT USAGE ON `db1`.`t3` TO 'u1'@'%' WITH GRANT OPTION",
+		}
+		assert.Equal(t, want, grants)
+	}
+
+	// Errors.
+	{
+		illegal := "Illegal GRANT/REVOKE command; please consult the manual to see which privileges can be used (errno 1144) (sqlstate 42000)"
+		err := catalog.GrantTable([]string{PrivDelete}, []string{"a"}, "db1", "t2", "u1")
+		assert.Equal(t, illegal, err.Error())
+		err = catalog.GrantTable([]string{PrivAll}, []string{"a"}, "db1", "t2", "u1")
+		assert.Equal(t, illegal, err.Error())
+		err = catalog.GrantTable([]string{PrivSuper}, nil, "db1", "t2", "u1")
+		assert.Equal(t, illegal, err.Error())
+		err = catalog.GrantTable([]string{PrivSelect}, []string{"a"}, "db1", "", "u1")
+		assert.Equal(t, illegal, err.Error())
+		err = catalog.RevokeTable([]string{PrivSelect}, nil, "db1", "t1", "u2")
+		assert.Equal(t, "There is no such grant defined for user 'u2' on host '%' (errno 1141) (sqlstate 42000)", err.Error())
+	}
+}
+
+func TestCatalogError(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	catalog := NewCatalog(log, "")
+	err := catalog.LoadConfig()
+	assert.Nil(t, err)
+
+	catalog.CreateUser("u1", "", false)
+	catalog.CreateRole("r1", false)
+
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{catalog.CreateUser("u1", "", false), "Operation CREATE USER failed for 'u1'@'%' (errno 1396) (sqlstate HY000)"},
+		{catalog.CreateUser("r1", "", false), "Operation CREATE USER failed for 'r1'@'%' (errno 1396) (sqlstate HY000)"},
+		{catalog.CreateRole("r1", false), "Operation CREATE ROLE failed for 'r1'@'%' (errno 1396) (sqlstate HY000)"},
+		{catalog.CreateRole("u1", false), "Operation CREATE ROLE failed for 'u1'@'%' (errno 1396) (sqlstate HY000)"},
+		{catalog.AlterUser("u2", ""), "Operation ALTER USER failed for 'u2'@'%' (errno 1396) (sqlstate HY000)"},
+		{catalog.DropUser("u2", false), "Operation DROP USER failed for 'u2'@'%' (errno 1396) (sqlstate HY000)"},
+		{catalog.DropRole("r2", false), "Operation DROP ROLE failed for 'r2'@'%' (errno 1396) (sqlstate HY000)"},
+		{catalog.Grant([]string{PrivSelect}, "db1", "u2"), "Can't find any matching row in the user table (errno 1133) (sqlstate 42000)"},
+		{catalog.Grant([]string{PrivSuper}, "db1", "u1"), "Incorrect usage of DB GRANT and GLOBAL PRIVILEGES (errno 1221) (sqlstate HY000)"},
+		{catalog.Revoke([]string{PrivSuper}, "db1", "u1"), "Incorrect usage of DB GRANT and GLOBAL PRIVILEGES (errno 1221) (sqlstate HY000)"},
+		{catalog.Revoke([]string{PrivSelect}, "db1", "u2"), "There is no such grant defined for user 'u2' on host '%' (errno 1141) (sqlstate 42000)"},
+		{catalog.GrantRole("r2", "u1"), "Unknown authorization ID `r2`@`%` (errno 3523) (sqlstate HY000)"},
+		{catalog.GrantRole("r1", "u2"), "Unknown authorization ID `u2`@`%` (errno 3523) (sqlstate HY000)"},
+		{catalog.RevokeRole("r1", "u2"), "Unknown authorization ID `u2`@`%` (errno 3523) (sqlstate HY000)"},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.want, test.err.Error())
+	}
+
+	_, err = catalog.ShowGrants("u2")
+	assert.Equal(t, "There is no such grant defined for user 'u2' on host '%' (errno 1141) (sqlstate 42000)", err.Error())
+	_, err = NormalizePrivilege("selec")
+	assert.Equal(t, "Illegal privilege 'selec' (errno 1064) (sqlstate 42000)", err.Error())
+}
+
+func TestCatalogLoadError(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	metadir, err := ioutil.TempDir(os.TempDir(), "privilege_catalog_")
+	assert.Nil(t, err)
+	defer os.RemoveAll(metadir)
+
+	err = ioutil.WriteFile(path.Join(metadir, userjson), []byte("{"), 0644)
+	assert.Nil(t, err)
+	catalog := NewCatalog(log, metadir)
+	err = catalog.LoadConfig()
+	assert.NotNil(t, err)
+
+	// The metadir isn't writable.
+	catalog = NewCatalog(log, "/xx/radon_privilege_catalog")
+	err = catalog.LoadConfig()
+	assert.Nil(t, err)
+	err = catalog.CreateUser("u1", "", false)
+	assert.NotNil(t, err)
+}
+
+func TestNormalizePrivilege(t *testing.T) {
+	tests := []struct {
+		priv string
+		want string
+	}{
+		{"select", PrivSelect},
+		{" Insert ", PrivInsert},
+		{"all", PrivAll},
+		{"all  privileges", PrivAll},
+		{"show databases", PrivShowDatabases},
+		{"grant option", PrivGrantOption},
+	}
+	for _, test := range tests {
+		got, err := NormalizePrivilege(test.priv)
+		assert.Nil(t, err)
+		assert.Equal(t, test.want, got)
+	}
+}
+
+func TestPrivilegeCatalogUser(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	scatter, fakedbs, cleanup := backend.MockScatter(log, 3)
+	defer cleanup()
+	MockInitPrivilegeY(fakedbs)
+
+	handler := NewPrivilege(log, nil, scatter)
+	err := handler.Init()
+	assert.Nil(t, err)
+	defer handler.Close()
+
+	// The catalog user has no backend account.
+	catalog := handler.Catalog()
+	catalog.CreateUser("u1", "", false)
+	catalog.CreateRole("reader", false)
+	catalog.Grant([]string{PrivSelect}, "db1", "reader")
+	catalog.GrantRole("reader", "u1")
+
+	tests := []struct {
+		db  string
+		sql string
+		ok  bool
+	}{
+		{"db1", "select * from t1", true},
+		{"db2", "select * from t1", false},
+		{"db2", "select * from db1.t1", true},
+		{"db1", "insert into t1 values(1)", false},
+		{"db1", "create table t1(a int)", false},
+	}
+	for _, test := range tests {
+		node, err := sqlparser.Parse(test.sql)
+		assert.Nil(t, err)
+		err = handler.Check(test.db, "u1", node)
+		assert.Equal(t, test.ok, err == nil, test.sql)
+	}
+
+	assert.False(t, handler.IsSuperPriv("u1"))
+	assert.False(t, handler.CheckUserPrivilegeIsSet("u1"))
+	assert.True(t, handler.CheckDBinUserPrivilege("u1", "db1"))
+	assert.Equal(t, map[string]struct{}{"db1": {}}, handler.GetUserPrivilegeDBS("u1"))
+
+	// The table and column privileges.
+	catalog.GrantTable([]string{PrivSelect}, nil, "db2", "t1", "u1")
+	catalog.GrantTable([]string{PrivSelect}, []string{"a"}, "db2", "t2", "u1")
+	tests = []struct {
+		db  string
+		sql string
+		ok  bool
+	}{
+		{"db2", "select * from t1", true},
+		{"db2", "select a from t2", true},
+		{"db2", "select * from t2", false},
+		{"db2", "select b from t2", false},
+		{"db2", "select a from t3", false},
+	}
+	for _, test := range tests {
+		node, err := sqlparser.Parse(test.sql)
+		assert.Nil(t, err)
+		err = handler.Check(test.db, "u1", node)
+		assert.Equal(t, test.ok, err == nil, test.sql)
+	}
+	assert.True(t, handler.CheckDBinUserPrivilege("u1", "db2"))
+
+	// The catalog user shadows the backend account.
+	assert.True(t, handler.IsSuperPriv("mock"))
+	catalog.CreateUser("mock", "", false)
+	assert.False(t, handler.IsSuperPriv("mock"))
+	catalog.Grant([]string{PrivAll}, AllDatabases, "mock")
+	assert.True(t, handler.IsSuperPriv("mock"))
+}
@@ -316,6 +316,7 @@ func MockInitPrivilegeY(fakedbs *fakedb.DB) {
 
 	fakedbs.AddQuery("select host, user, select_priv, insert_priv, update_priv, delete_priv, create_priv, drop_priv, alter_priv, index_priv, show_db_priv, super_priv from mysql.user", UserRs)
 	fakedbs.AddQueryPattern("select host, user, select_priv, insert_priv, update_priv, delete_priv, create_priv, drop_priv, grant_priv, alter_priv, index_priv, db from .*", DbRs)
+	mockTablePrivileges(fakedbs)
 }
 
 // MockInitPrivilegeN init the Rows with N.
@@ -337,6 +338,7 @@ func MockInitPrivilegeN(fakedbs *fakedb.DB) {
 
 	fakedbs.AddQuery("select host, user, select_priv, insert_priv, update_priv, delete_priv, create_priv, drop_priv, alter_priv, index_priv, show_db_priv, super_priv from mysql.user", UserRs)
 	fakedbs.AddQueryPattern("select host, user, select_priv, insert_priv, update_priv, delete_priv, create_priv, drop_priv, grant_priv, alter_priv, index_priv, db from .*", DbRs)
+	mockTablePrivileges(fakedbs)
 }
 
 // MockInitPrivilegeNotSuper init the Rows with N to Super_priv.
@@ -359,6 +361,7 @@ func MockInitPrivilegeNotSuper(fakedbs *fakedb.DB) {
 
 	fakedbs.AddQuery("select host, user, select_priv, insert_priv, update_priv, delete_priv, create_priv, drop_priv, alter_priv, index_priv, show_db_priv, super_priv from mysql.user", UserRs)
 	fakedbs.AddQueryPattern("select host, user, select_priv, insert_priv, update_priv, delete_priv, create_priv, drop_priv, grant_priv, alter_priv, index_priv, db from .*", DbRs)
+	mockTablePrivileges(fakedbs)
 }
 
 // MockInitPrivilegeUsers init the Rows with multiple users.
@@ -397,6 +400,7 @@ func MockInitPrivilegeUsers(fakedbs *fakedb.DB) {
 
 	fakedbs.AddQuery("select host, user, select_priv, insert_priv, update_priv, delete_priv, create_priv, drop_priv, alter_priv, index_priv, show_db_priv, super_priv from mysql.user", UserRs1)
 	fakedbs.AddQueryPattern("select host, user, select_priv, insert_priv, update_priv, delete_priv, create_priv, drop_priv, grant_priv, alter_priv, index_priv, db from .*", DbRs1)
+	mockTablePrivileges(fakedbs)
 }
 
 // MockInitPrivilegeUserNDatabaseY init the Rows with user priv N and db priv Y.
@@ -418,4 +422,31 @@ func MockInitPrivilegeUserNDatabaseY(fakedbs *fakedb.DB) {
 
 	fakedbs.AddQuery("select host, user, select_priv, insert_priv, update_priv, delete_priv, create_priv, drop_priv, alter_priv, index_priv, show_db_priv, super_priv from mysql.user", UserRs)
 	fakedbs.AddQueryPattern("select host, user, select_priv, insert_priv, update_priv, delete_priv, create_priv, drop_priv, grant_priv, alter_priv, index_priv, db from .*", DbRs)
+	mockTablePrivileges(fakedbs)
+}
+
+var (
+	// TablesPrivRs is the result of the mysql.tables_priv, empty by default.
+	TablesPrivRs = &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "Db", Type: querypb.Type_VARCHAR},
+			{Name: "Table_name", Type: querypb.Type_VARCHAR},
+			{Name: "Table_priv", Type: querypb.Type_SET},
+		},
+	}
+
+	// ColumnsPrivRs is the result of the mysql.columns_priv, empty by default.
+	ColumnsPrivRs = &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "Db", Type: querypb.Type_VARCHAR},
+			{Name: "Table_name", Type: querypb.Type_VARCHAR},
+			{Name: "Column_name", Type: querypb.Type_VARCHAR},
+			{Name: "Column_priv", Type: querypb.Type_SET},
+		},
+	}
+)
+
+func mockTablePrivileges(fakedbs *fakedb.DB) {
+	fakedbs.AddQueryPattern("select db, table_name, table_priv from mysql.tables_priv .*", TablesPrivRs)
+	fakedbs.AddQueryPattern("select db, table_name, column_name, column_priv from mysql.columns_priv .*", ColumnsPrivRs)
 }
@@ -10,6 +10,7 @@ package privilege
 
 import (
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -38,11 +39,20 @@ type privilege struct {
 	superPriv  bool
 }
 
+// tablePriv tuple, the table level privileges and the column level privileges.
+type tablePriv struct {
+	db      string
+	table   string
+	priv    privilege
+	columns map[string]privilege
+}
+
 type dbPriv struct {
-	host string
-	user string
-	db   string
-	priv privilege
+	host       string
+	user       string
+	db         string
+	priv       privilege
+	tablePrivs map[string]tablePriv
 }
 
 type userPriv struct {
@@ -60,26 +70,36 @@ type Privilege struct {
 	conf      *config.Config
 	done      chan bool
 	userPrivs map[string]userPriv
+	catalog   *Catalog
 	scatter   *backend.Scatter
 	ticker    *time.Ticker
 }
 
 // NewPrivilege -- creates new Privilege.
 func NewPrivilege(log *xlog.Log, conf *config.Config, scatter *backend.Scatter) PrivilegeHandler {
+	metadir := ""
+	if conf != nil && conf.Proxy != nil {
+		metadir = conf.Proxy.MetaDir
+	}
 	return &Privilege{
 		log:       log,
 		conf:      conf,
 		done:      make(chan bool),
 		userPrivs: make(map[string]userPriv),
+		catalog:   NewCatalog(log, metadir),
 		scatter:   scatter,
 		ticker:    time.NewTicker(time.Duration(time.Second * 5)),
 	}
 }
 
 // Init -- init the privilege plugin.
+// The users in the radon-native catalog are checked by the catalog, the others by the backend mysql.user.
 func (p *Privilege) Init() error {
 	log := p.log
 
+	if err := p.catalog.LoadConfig(); err != nil {
+		return err
+	}
 	if err := p.UpdatePrivileges(); err != nil {
 		log.Error("plugin.privilege.init.privilege.error:%+v", err)
 	}
@@ -105,13 +125,27 @@ func (p *Privilege) Init() error {
 	return nil
 }
 
+// Catalog returns the radon-native user and role catalog.
+func (p *Privilege) Catalog() *Catalog {
+	return p.catalog
+}
+
+// getUserPriv returns the privileges of the user, the catalog user first.
+func (p *Privilege) getUserPriv(user string) userPriv {
+	if userpriv, ok := p.catalog.userPriv(user); ok {
+		return userpriv
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.userPrivs[user]
+}
+
 // https://dev.mysql.com/doc/refman/8.0/en/privileges-provided.html
 func (p *Privilege) CheckPrivilege(db string, user string, node sqlparser.Statement) bool {
 	log := p.log
-	p.mu.RLock()
-	userpriv := p.userPrivs[user]
+	userpriv := p.getUserPriv(user)
 	dbpriv := userpriv.dbPrivs[db]
-	p.mu.RUnlock()
 
 	if node != nil {
 		switch node.(type) {
@@ -144,7 +178,12 @@ func (p *Privilege) CheckPrivilege(db string, user string, node sqlparser.Statem
 }
 
 // Check -- checks the session privilege on the database.
+// The DML statements are checked by the tables and columns they touch, which can be granted on the table or column level.
 func (p *Privilege) Check(database string, user string, node sqlparser.Statement) error {
+	if accesses, ok := resolveAccesses(database, node); ok && len(accesses) > 0 {
+		return p.checkAccesses(user, accesses)
+	}
+
 	ok := true
 	db := database
 
@@ -183,19 +222,84 @@ func (p *Privilege) Check(database string, user string, node sqlparser.Statement
 	return nil
 }
 
+// checkAccesses -- checks the accesses on the global, database, table and column levels in order.
+func (p *Privilege) checkAccesses(user string, accesses []*access) error {
+	userpriv := p.getUserPriv(user)
+	for _, acc := range accesses {
+		if userpriv.priv.superPriv || userpriv.priv.has(acc.priv) {
+			continue
+		}
+		dbpriv := userpriv.dbPrivs[acc.db]
+		if dbpriv.priv.has(acc.priv) {
+			continue
+		}
+		if len(dbpriv.tablePrivs) == 0 {
+			return sqldb.NewSQLErrorf(sqldb.ER_ACCESS_DENIED_ERROR, "Access denied for user '%v'@'%%' to database '%v'", user, acc.db)
+		}
+
+		tablepriv, ok := dbpriv.tablePrivs[acc.table]
+		switch {
+		case ok && tablepriv.priv.has(acc.priv):
+			continue
+		case !ok || acc.all:
+			return tableAccessDenied(acc.priv, user, acc.table)
+		case len(acc.columns) == 0:
+			// Any column privilege is enough, such as 'select count(*) from t1'.
+			if !tablepriv.hasAnyColumn(acc.priv) {
+				return tableAccessDenied(acc.priv, user, acc.table)
+			}
+		default:
+			for _, column := range acc.columns {
+				if !tablepriv.columns[column].has(acc.priv) {
+					return sqldb.NewSQLError1(1143, "42000", "%s command denied to user '%s'@'%%' for column '%s' in table '%s'", acc.priv, user, column, acc.table)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func tableAccessDenied(priv string, user string, table string) error {
+	return sqldb.NewSQLError1(1142, "42000", "%s command denied to user '%s'@'%%' for table '%s'", priv, user, table)
+}
+
+// hasAnyColumn returns true if any column has the privilege.
+func (t tablePriv) hasAnyColumn(priv string) bool {
+	for _, colpriv := range t.columns {
+		if colpriv.has(priv) {
+			return true
+		}
+	}
+	return false
+}
+
+// setTablePriv used to update the table privileges of the database, the dbPriv is added if not exists.
+func setTablePriv(dbprivs map[string]dbPriv, host string, user string, db string, table string, update func(*tablePriv)) {
+	dbpriv, ok := dbprivs[db]
+	if !ok {
+		dbpriv = dbPriv{host: host, user: user, db: db}
+	}
+	if dbpriv.tablePrivs == nil {
+		dbpriv.tablePrivs = make(map[string]tablePriv)
+	}
+	tablepriv, ok := dbpriv.tablePrivs[table]
+	if !ok {
+		tablepriv = tablePriv{db: db, table: table, columns: make(map[string]privilege)}
+	}
+	update(&tablepriv)
+	dbpriv.tablePrivs[table] = tablepriv
+	dbprivs[db] = dbpriv
+}
+
 // IsSuperPriv ...
 func (p *Privilege) IsSuperPriv(user string) bool {
-	p.mu.RLock()
-	userpriv := p.userPrivs[user]
-	p.mu.RUnlock()
+	userpriv := p.getUserPriv(user)
 	return userpriv.priv.superPriv
 }
 
 // CheckUserPrivilegeIsSet ...
 func (p *Privilege) CheckUserPrivilegeIsSet(user string) bool {
-	p.mu.RLock()
-	userpriv := p.userPrivs[user]
-	p.mu.RUnlock()
+	userpriv := p.getUserPriv(user)
 
 	isSet := userpriv.priv.selectPriv || userpriv.priv.insertPriv || userpriv.priv.updatePriv || userpriv.priv.deletePriv ||
 		userpriv.priv.createPriv || userpriv.priv.dropPriv || userpriv.priv.grantPriv || userpriv.priv.alterPriv ||
@@ -206,9 +310,7 @@ func (p *Privilege) CheckUserPrivilegeIsSet(user string) bool {
 
 // GetUserPrivilegeDBS get the dbmap with dbPrivs in the user.
 func (p *Privilege) GetUserPrivilegeDBS(user string) (dbMap map[string]struct{}) {
-	p.mu.RLock()
-	userpriv := p.userPrivs[user]
-	p.mu.RUnlock()
+	userpriv := p.getUserPriv(user)
 
 	dbs := make(map[string]struct{})
 	for db, _ := range userpriv.dbPrivs {
@@ -219,9 +321,7 @@ func (p *Privilege) GetUserPrivilegeDBS(user string) (dbMap map[string]struct{})
 
 // CheckDBinUserPrivilege ...
 func (p *Privilege) CheckDBinUserPrivilege(user string, db string) bool {
-	p.mu.RLock()
-	userpriv := p.userPrivs[user]
-	p.mu.RUnlock()
+	userpriv := p.getUserPriv(user)
 
 	if _, ok := userpriv.dbPrivs[db]; ok {
 		return true
@@ -289,6 +389,59 @@ func (p *Privilege) loadDBPrivileges(host string, user string) (map[string]dbPri
 	return privis, nil
 }
 
+// loadTablePrivileges -- used to get the backend's table and column privileges of the user into the dbprivs.
+// mysql> select Db, Table_name, Table_priv from mysql.tables_priv;
+//+------+------------+---------------+
+//| Db   | Table_name | Table_priv    |
+//+------+------------+---------------+
+//| db1  | t1         | Select,Insert |
+//+------+------------+---------------+
+// mysql> select Db, Table_name, Column_name, Column_priv from mysql.columns_priv;
+//+------+------------+-------------+-------------+
+//| Db   | Table_name | Column_name | Column_priv |
+//+------+------------+-------------+-------------+
+//| db1  | t2         | a           | Select      |
+//+------+------------+-------------+-------------+
+func (p *Privilege) loadTablePrivileges(host string, user string, dbprivs map[string]dbPriv) error {
+	query := fmt.Sprintf(`select Db, Table_name, Table_priv from mysql.tables_priv where Host='%v' and User='%s'`, host, user)
+	qr, err := p.execute(query)
+	if err != nil {
+		return err
+	}
+	for _, r := range qr.Rows {
+		privs := parsePrivilegeSet(string(r[2].Raw()))
+		setTablePriv(dbprivs, host, user, string(r[0].Raw()), string(r[1].Raw()), func(t *tablePriv) {
+			t.priv = t.priv.merge(privs)
+		})
+	}
+
+	query = fmt.Sprintf(`select Db, Table_name, Column_name, Column_priv from mysql.columns_priv where Host='%v' and User='%s'`, host, user)
+	if qr, err = p.execute(query); err != nil {
+		return err
+	}
+	for _, r := range qr.Rows {
+		column := strings.ToLower(string(r[2].Raw()))
+		privs := parsePrivilegeSet(string(r[3].Raw()))
+		setTablePriv(dbprivs, host, user, string(r[0].Raw()), string(r[1].Raw()), func(t *tablePriv) {
+			t.columns[column] = t.columns[column].merge(privs)
+		})
+	}
+	return nil
+}
+
+// parsePrivilegeSet returns the privilege names of the SET value, such as 'Select,Insert,Grant'.
+func parsePrivilegeSet(set string) []string {
+	var privs []string
+	for _, priv := range strings.Split(set, ",") {
+		priv = strings.ToUpper(strings.TrimSpace(priv))
+		if priv == "GRANT" {
+			priv = PrivGrantOption
+		}
+		privs = append(privs, priv)
+	}
+	return privs
+}
+
 // mysql> select Host, User, Select_priv, Insert_priv, Update_priv, Delete_priv, Create_priv, Drop_priv, Alter_priv, Index_priv, Show_db_priv, Super_priv from mysql.user
 //+-----------+---------------+-------------+-------------+-------------+-------------+-------------+-----------+------------+------------+--------------+------------+
 //| Host      | User          | Select_priv | Insert_priv | Update_priv | Delete_priv | Create_priv | Drop_priv | Alter_priv | Index_priv | Show_db_priv | Super_priv |
@@ -315,6 +468,9 @@ func (p *Privilege) loadUserPrivileges() (map[string]userPriv, error) {
 		if err != nil {
 			return nil, err
 		}
+		if err := p.loadTablePrivileges(host, user, dbprivs); err != nil {
+			return nil, err
+		}
 
 		userpriv := userPriv{
 			host: host,
@@ -0,0 +1,100 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package privilege
+
+import (
+	"strings"
+
+	"github.com/xelabs/go-mysqlstack/sqldb"
+)
+
+// The resource options of the user.
+const (
+	MaxUserConnections  = "MAX_USER_CONNECTIONS"
+	MaxQueriesPerSecond = "MAX_QUERIES_PER_SECOND"
+	MaxQueriesPerHour   = "MAX_QUERIES_PER_HOUR"
+	MaxScatterQueries   = "MAX_SCATTER_QUERIES"
+	MaxRows             = "MAX_ROWS"
+)
+
+// Resources tuple, the resource limits of the user, 0 means no limit.
+type Resources struct {
+	MaxUserConnections  int `json:"max-user-connections,omitempty"`
+	MaxQueriesPerSecond int `json:"max-queries-per-second,omitempty"`
+	MaxQueriesPerHour   int `json:"max-queries-per-hour,omitempty"`
+	MaxScatterQueries   int `json:"max-scatter-queries,omitempty"`
+	MaxRows             int `json:"max-rows,omitempty"`
+}
+
+// Set used to set the limit of the resource option, such as 'MAX_QUERIES_PER_HOUR'.
+func (r *Resources) Set(option string, value int) error {
+	if value < 0 {
+		return sqldb.NewSQLError1(1064, "42000", "Illegal value %d of the resource option '%s'", value, option)
+	}
+	switch strings.ToUpper(option) {
+	case MaxUserConnections:
+		r.MaxUserConnections = value
+	case MaxQueriesPerSecond:
+		r.MaxQueriesPerSecond = value
+	case MaxQueriesPerHour:
+		r.MaxQueriesPerHour = value
+	case MaxScatterQueries:
+		r.MaxScatterQueries = value
+	case MaxRows:
+		r.MaxRows = value
+	default:
+		return sqldb.NewSQLError1(1064, "42000", "Illegal resource option '%s'", option)
+	}
+	return nil
+}
+
+// Unlimited returns true if none of the resources is limited.
+func (r Resources) Unlimited() bool {
+	return r == Resources{}
+}
+
+// AlterResources used to change the resource limits of the user by the options, the others are unchanged.
+func (c *Catalog) AlterResources(name string, options map[string]int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	user, ok := c.users[name]
+	if !ok {
+		return operationFailed("ALTER USER", name)
+	}
+	res := &Resources{}
+	if user.Resources != nil {
+		*res = *user.Resources
+	}
+	for option, value := range options {
+		if err := res.Set(option, value); err != nil {
+			return err
+		}
+	}
+	if res.Unlimited() {
+		res = nil
+	}
+	c.users[name] = &User{Name: name, AuthenticationString: user.AuthenticationString, Roles: user.Roles, Grants: user.Grants, Resources: res}
+	return c.flush()
+}
+
+// Resources returns the resource limits of the user, false if the user isn't in the catalog.
+func (c *Catalog) Resources(name string) (Resources, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	user, ok := c.users[name]
+	if !ok {
+		return Resources{}, false
+	}
+	if user.Resources == nil {
+		return Resources{}, true
+	}
+	return *user.Resources, true
+}
@@ -0,0 +1,74 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package privilege
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestCatalogResources(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	metadir, err := ioutil.TempDir(os.TempDir(), "privilege_catalog_")
+	assert.Nil(t, err)
+	defer os.RemoveAll(metadir)
+
+	catalog := NewCatalog(log, metadir)
+	err = catalog.LoadConfig()
+	assert.Nil(t, err)
+
+	err = catalog.CreateUser("u1", "*xx", false)
+	assert.Nil(t, err)
+	res, ok := catalog.Resources("u1")
+	assert.True(t, ok)
+	assert.True(t, res.Unlimited())
+
+	err = catalog.AlterResources("u1", map[string]int{"max_queries_per_hour": 100, MaxUserConnections: 2, MaxRows: 10})
+	assert.Nil(t, err)
+	err = catalog.AlterResources("u1", map[string]int{MaxQueriesPerSecond: 5, MaxScatterQueries: 1, MaxRows: 0})
+	assert.Nil(t, err)
+	want := Resources{MaxUserConnections: 2, MaxQueriesPerSecond: 5, MaxQueriesPerHour: 100, MaxScatterQueries: 1}
+	res, _ = catalog.Resources("u1")
+	assert.Equal(t, want, res)
+
+	// The resources are kept by the other changes.
+	err = catalog.AlterUser("u1", "*yy")
+	assert.Nil(t, err)
+	err = catalog.Grant([]string{PrivSelect}, "db1", "u1")
+	assert.Nil(t, err)
+
+	// Reload from the metadir.
+	reloaded := NewCatalog(log, metadir)
+	err = reloaded.LoadConfig()
+	assert.Nil(t, err)
+	res, _ = reloaded.Resources("u1")
+	assert.Equal(t, want, res)
+
+	// Reset all.
+	err = catalog.AlterResources("u1", map[string]int{MaxUserConnections: 0, MaxQueriesPerSecond: 0, MaxQueriesPerHour: 0, MaxScatterQueries: 0})
+	assert.Nil(t, err)
+	res, _ = catalog.Resources("u1")
+	assert.True(t, res.Unlimited())
+
+	// Errors.
+	{
+		_, ok := catalog.Resources("u2")
+		assert.False(t, ok)
+		err := catalog.AlterResources("u2", map[string]int{MaxRows: 1})
+		assert.Equal(t, "Operation ALTER USER failed for 'u2'@'%' (errno 1396) (sqlstate HY000)", err.Error())
+		err = catalog.AlterResources("u1", map[string]int{"MAX_XX": 1})
+		assert.Equal(t, "Illegal resource option 'MAX_XX' (errno 1064) (sqlstate 42000)", err.Error())
+		err = catalog.AlterResources("u1", map[string]int{MaxRows: -1})
+		assert.Equal(t, "Illegal value -1 of the resource option 'MAX_ROWS' (errno 1064) (sqlstate 42000)", err.Error())
+	}
+}
@@ -0,0 +1,23 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package rewrite
+
+import (
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+)
+
+// RewriteHandler interface.
+type RewriteHandler interface {
+	Init() error
+	Rewrite(database string, user string, node sqlparser.Statement) []string
+	Rules() []*Rule
+	SetRules(rules []*Rule) error
+	LoadConfig() error
+	Close() error
+}
@@ -0,0 +1,182 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package rewrite
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"sync"
+
+	"config"
+	"xbase"
+
+	"github.com/pkg/errors"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+const (
+	rewritejson = "rewrite.json"
+)
+
+// Config tuple, the content of the metadir/rewrite.json.
+type Config struct {
+	Rules []*Rule `json:"rules"`
+}
+
+// Rewrite tuple, the rules are applied in order between the parser and the planner.
+type Rewrite struct {
+	mu      sync.RWMutex
+	log     *xlog.Log
+	metadir string
+	rules   []*Rule
+}
+
+// NewRewrite creates the new rewrite, it's in memory only if the metadir is empty.
+func NewRewrite(log *xlog.Log, conf *config.Config) RewriteHandler {
+	metadir := ""
+	if conf != nil && conf.Proxy != nil {
+		metadir = conf.Proxy.MetaDir
+	}
+	return &Rewrite{
+		log:     log,
+		metadir: metadir,
+	}
+}
+
+// Init -- init the rewrite plugin.
+func (rw *Rewrite) Init() error {
+	if err := rw.LoadConfig(); err != nil {
+		return err
+	}
+	rw.log.Info("plugin.rewrite.init.done, rules:%d", len(rw.rules))
+	return nil
+}
+
+// Close -- do nothing.
+func (rw *Rewrite) Close() error {
+	return nil
+}
+
+func buildRules(rules []*Rule) ([]*Rule, error) {
+	var built []*Rule
+	names := make(map[string]bool)
+	for _, r := range rules {
+		rule := *r
+		if err := rule.build(); err != nil {
+			return nil, err
+		}
+		if names[rule.Name] {
+			return nil, errors.Errorf("rewrite.rule[%s].duplicate", rule.Name)
+		}
+		names[rule.Name] = true
+		built = append(built, &rule)
+	}
+	return built, nil
+}
+
+// LoadConfig used to load the rules from the metadir/rewrite.json.
+func (rw *Rewrite) LoadConfig() error {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	conf := &Config{}
+	if rw.metadir != "" {
+		file := path.Join(rw.metadir, rewritejson)
+		data, err := ioutil.ReadFile(file)
+		if err != nil && !os.IsNotExist(err) {
+			rw.log.Error("rewrite.load.from.file[%v].error:%v", file, err)
+			return errors.WithStack(err)
+		}
+		if err == nil {
+			if err := json.Unmarshal(data, conf); err != nil {
+				rw.log.Error("rewrite.parse.json.file[%v].error:%v", file, err)
+				return errors.WithStack(err)
+			}
+		}
+	}
+	rules, err := buildRules(conf.Rules)
+	if err != nil {
+		return err
+	}
+	rw.rules = rules
+	return nil
+}
+
+// Rules returns the copy of the rules.
+func (rw *Rewrite) Rules() []*Rule {
+	rw.mu.RLock()
+	defer rw.mu.RUnlock()
+
+	rules := []*Rule{}
+	for _, r := range rw.rules {
+		rule := *r
+		rule.re = nil
+		rules = append(rules, &rule)
+	}
+	return rules
+}
+
+// SetRules used to set the rules, and flush them to the metadir/rewrite.json and update the meta version.
+func (rw *Rewrite) SetRules(rules []*Rule) error {
+	built, err := buildRules(rules)
+	if err != nil {
+		return err
+	}
+
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	rw.log.Warning("rewrite.set.rules:%+v", rules)
+	rw.rules = built
+	if rw.metadir == "" {
+		return nil
+	}
+
+	conf := &Config{Rules: []*Rule{}}
+	for _, r := range rw.rules {
+		rule := *r
+		rule.re = nil
+		conf.Rules = append(conf.Rules, &rule)
+	}
+	file := path.Join(rw.metadir, rewritejson)
+	if err := config.WriteConfig(file, conf); err != nil {
+		rw.log.Error("rewrite.flush.to.file[%v].error:%v", file, err)
+		return err
+	}
+	if err := config.UpdateVersion(rw.metadir); err != nil {
+		rw.log.Error("rewrite.flush.update.version.error:%v", err)
+		return err
+	}
+	return nil
+}
+
+// Rewrite used to rewrite the statement in place by the rules, returns the names of the fired rules.
+func (rw *Rewrite) Rewrite(database string, user string, node sqlparser.Statement) []string {
+	rw.mu.RLock()
+	defer rw.mu.RUnlock()
+
+	if len(rw.rules) == 0 {
+		return nil
+	}
+	var fired []string
+	stmt := &statement{
+		database: database,
+		user:     user,
+		typ:      xbase.StatementType(node),
+		node:     node,
+	}
+	for _, rule := range rw.rules {
+		if rule.apply(stmt) {
+			fired = append(fired, rule.Name)
+		}
+	}
+	return fired
+}
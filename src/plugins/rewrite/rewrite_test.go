@@ -0,0 +1,96 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package rewrite
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestRewrite(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	metadir, err := ioutil.TempDir(os.TempDir(), "rewrite_")
+	assert.Nil(t, err)
+	defer os.RemoveAll(metadir)
+	conf := &config.Config{Proxy: &config.ProxyConfig{MetaDir: metadir}}
+
+	rw := NewRewrite(log, conf)
+	err = rw.Init()
+	assert.Nil(t, err)
+	defer rw.Close()
+	assert.Equal(t, []*Rule{}, rw.Rules())
+
+	// No rules.
+	node, err := sqlparser.Parse("select * from t1")
+	assert.Nil(t, err)
+	assert.Nil(t, rw.Rewrite("db1", "u1", node))
+
+	rules := []*Rule{
+		{Name: "redirect-t1", Statement: "select", Table: "t1", Redirect: "t1_ro"},
+		{Name: "limit-select", Statement: "select", Limit: 1000},
+		{Name: "force-index", Table: "t2", ForceIndex: []string{"idx_a"}},
+	}
+	err = rw.SetRules(rules)
+	assert.Nil(t, err)
+	assert.True(t, config.ReadVersion(metadir) > 0)
+
+	// Rewrite.
+	{
+		node, err := sqlparser.Parse("select * from t1 where id=1")
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"redirect-t1", "limit-select"}, rw.Rewrite("db1", "u1", node))
+		assert.Equal(t, "select * from t1_ro as t1 where id = 1 limit 1000", sqlparser.String(node))
+
+		node, err = sqlparser.Parse("update t1 set a=1")
+		assert.Nil(t, err)
+		assert.Nil(t, rw.Rewrite("db1", "u1", node))
+		assert.Equal(t, "update t1 set a = 1", sqlparser.String(node))
+	}
+
+	// Reload from the metadir.
+	{
+		rw1 := NewRewrite(log, conf)
+		err := rw1.Init()
+		assert.Nil(t, err)
+		assert.Equal(t, rw.Rules(), rw1.Rules())
+		assert.Equal(t, "t1_ro", rw1.Rules()[0].Redirect)
+	}
+
+	// Errors.
+	{
+		err := rw.SetRules([]*Rule{{Name: "r1", Limit: 1}, {Name: "r1", Limit: 1}})
+		assert.Equal(t, "rewrite.rule[r1].duplicate", err.Error())
+		assert.Equal(t, 3, len(rw.Rules()))
+
+		err = ioutil.WriteFile(path.Join(metadir, rewritejson), []byte("{"), 0644)
+		assert.Nil(t, err)
+		assert.NotNil(t, NewRewrite(log, conf).Init())
+
+		err = ioutil.WriteFile(path.Join(metadir, rewritejson), []byte(`{"rules":[{"name":""}]}`), 0644)
+		assert.Nil(t, err)
+		assert.NotNil(t, NewRewrite(log, conf).Init())
+	}
+
+	// In memory.
+	{
+		rw := NewRewrite(log, nil)
+		err := rw.Init()
+		assert.Nil(t, err)
+		err = rw.SetRules(rules)
+		assert.Nil(t, err)
+	}
+}
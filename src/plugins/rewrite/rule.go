@@ -0,0 +1,178 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package rewrite
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"xbase"
+
+	"github.com/pkg/errors"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+)
+
+// Rule tuple, the statement matched by the pattern is rewritten by the replacement.
+type Rule struct {
+	Name string `json:"name"`
+
+	// The pattern, the empty one matches any.
+	// The users of the rule.
+	Users []string `json:"users,omitempty"`
+	// The statement type, such as 'SELECT', 'UPDATE'.
+	Statement string `json:"statement,omitempty"`
+	// The regexp on the statement fingerprint.
+	Fingerprint string `json:"fingerprint,omitempty"`
+	// The database of the table, the session database if the table isn't qualified.
+	Database string `json:"database,omitempty"`
+	// The table referenced by the statement.
+	Table string `json:"table,omitempty"`
+
+	// The replacement.
+	// Adds the LIMIT to the SELECT without LIMIT.
+	Limit int `json:"limit,omitempty"`
+	// Adds the FORCE INDEX hint to the table.
+	ForceIndex []string `json:"force-index,omitempty"`
+	// Redirects the table to the other table, such as 'db.t1_ro' or 't1_ro'.
+	Redirect string `json:"redirect,omitempty"`
+
+	re *regexp.Regexp
+}
+
+// build used to check and compile the rule.
+func (r *Rule) build() error {
+	if r.Name == "" {
+		return errors.New("rewrite.rule.name.can't.be.empty")
+	}
+	if r.Limit < 0 {
+		return errors.Errorf("rewrite.rule[%s].limit[%d].invalid", r.Name, r.Limit)
+	}
+	if r.Limit == 0 && len(r.ForceIndex) == 0 && r.Redirect == "" {
+		return errors.Errorf("rewrite.rule[%s].replacement.can't.be.empty", r.Name)
+	}
+	if r.Table == "" && (len(r.ForceIndex) > 0 || r.Redirect != "") {
+		return errors.Errorf("rewrite.rule[%s].table.can't.be.empty.for.force-index.or.redirect", r.Name)
+	}
+	r.Statement = strings.ToUpper(r.Statement)
+	r.re = nil
+	if r.Fingerprint != "" {
+		re, err := regexp.Compile(r.Fingerprint)
+		if err != nil {
+			return errors.Errorf("rewrite.rule[%s].fingerprint[%s].invalid:%v", r.Name, r.Fingerprint, err)
+		}
+		r.re = re
+	}
+	return nil
+}
+
+// statement tuple, the statement to rewrite.
+type statement struct {
+	database    string
+	user        string
+	typ         string
+	fingerprint string
+	node        sqlparser.Statement
+}
+
+func (s *statement) getFingerprint() string {
+	if s.fingerprint == "" {
+		s.fingerprint = xbase.Fingerprint(sqlparser.String(s.node))
+	}
+	return s.fingerprint
+}
+
+// tables returns the table expressions referring to the table of the rule.
+func (r *Rule) tables(stmt *statement) []*sqlparser.AliasedTableExpr {
+	var exprs []*sqlparser.AliasedTableExpr
+	_ = sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		expr, ok := node.(*sqlparser.AliasedTableExpr)
+		if !ok {
+			return true, nil
+		}
+		table, ok := expr.Expr.(sqlparser.TableName)
+		if !ok {
+			return true, nil
+		}
+		database := stmt.database
+		if !table.Qualifier.IsEmpty() {
+			database = table.Qualifier.String()
+		}
+		if (r.Database == "" || r.Database == database) && r.Table == table.Name.String() {
+			exprs = append(exprs, expr)
+		}
+		return true, nil
+	}, stmt.node)
+	return exprs
+}
+
+// apply used to rewrite the statement if it matches the pattern, returns true if the statement is rewritten.
+func (r *Rule) apply(stmt *statement) bool {
+	if len(r.Users) > 0 && !containsString(r.Users, stmt.user) {
+		return false
+	}
+	if r.Statement != "" && r.Statement != stmt.typ {
+		return false
+	}
+	if r.re != nil && !r.re.MatchString(stmt.getFingerprint()) {
+		return false
+	}
+	var exprs []*sqlparser.AliasedTableExpr
+	if r.Table != "" {
+		if exprs = r.tables(stmt); len(exprs) == 0 {
+			return false
+		}
+	}
+
+	fired := false
+	if r.Limit > 0 {
+		if sel, ok := stmt.node.(*sqlparser.Select); ok && sel.Limit == nil {
+			sel.Limit = &sqlparser.Limit{Rowcount: sqlparser.NewIntVal([]byte(strconv.Itoa(r.Limit)))}
+			fired = true
+		}
+	}
+	for _, expr := range exprs {
+		if len(r.ForceIndex) > 0 {
+			hints := &sqlparser.IndexHints{Type: sqlparser.ForceStr}
+			for _, index := range r.ForceIndex {
+				hints.Indexes = append(hints.Indexes, sqlparser.NewColIdent(index))
+			}
+			expr.Hints = hints
+			fired = true
+		}
+		if r.Redirect != "" {
+			table := expr.Expr.(sqlparser.TableName)
+			// Keep the origin name as the alias for the columns qualified by the table name.
+			if expr.As.IsEmpty() {
+				expr.As = table.Name
+			}
+			if i := strings.Index(r.Redirect, "."); i >= 0 {
+				table.Qualifier = sqlparser.NewTableIdent(r.Redirect[:i])
+				table.Name = sqlparser.NewTableIdent(r.Redirect[i+1:])
+			} else {
+				table.Name = sqlparser.NewTableIdent(r.Redirect)
+			}
+			expr.Expr = table
+			fired = true
+		}
+	}
+	if fired {
+		stmt.fingerprint = ""
+	}
+	return fired
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package rewrite
+
+import (
+	"testing"
+
+	"xbase"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+)
+
+func TestRuleApply(t *testing.T) {
+	tests := []struct {
+		rule  *Rule
+		user  string
+		query string
+		fired bool
+		want  string
+	}{
+		// Limit.
+		{&Rule{Name: "r", Limit: 100}, "u1", "select * from t1", true, "select * from t1 limit 100"},
+		{&Rule{Name: "r", Limit: 100}, "u1", "select * from t1 limit 10", false, "select * from t1 limit 10"},
+		{&Rule{Name: "r", Limit: 100}, "u1", "delete from t1", false, "delete from t1"},
+		{&Rule{Name: "r", Limit: 100, Table: "t2"}, "u1", "select * from t1", false, "select * from t1"},
+		{&Rule{Name: "r", Limit: 100, Users: []string{"u2"}}, "u1", "select * from t1", false, "select * from t1"},
+		{&Rule{Name: "r", Limit: 100, Statement: "select"}, "u1", "select * from t1", true, "select * from t1 limit 100"},
+		{&Rule{Name: "r", Limit: 100, Fingerprint: `where a = \?`}, "u1", "select * from t1 where a=1", true, "select * from t1 where a = 1 limit 100"},
+		{&Rule{Name: "r", Limit: 100, Fingerprint: `where a = \?`}, "u1", "select * from t1 where b=1", false, "select * from t1 where b = 1"},
+
+		// Force index.
+		{&Rule{Name: "r", Table: "t1", ForceIndex: []string{"idx_a"}}, "u1", "select * from t1 where a=1", true, "select * from t1 force index (idx_a) where a = 1"},
+		{&Rule{Name: "r", Table: "t1", Database: "db1", ForceIndex: []string{"idx_a", "idx_b"}}, "u1", "select * from db1.t1 as x join t2 on x.a=t2.a", true, "select * from db1.t1 as x force index (idx_a, idx_b) join t2 on x.a = t2.a"},
+		{&Rule{Name: "r", Table: "t1", Database: "db2", ForceIndex: []string{"idx_a"}}, "u1", "select * from t1", false, "select * from t1"},
+
+		// Redirect.
+		{&Rule{Name: "r", Table: "t1", Redirect: "t1_ro"}, "u1", "select t1.a from t1 where t1.b=1", true, "select t1.a from t1_ro as t1 where t1.b = 1"},
+		{&Rule{Name: "r", Table: "t1", Redirect: "db2.t1_ro"}, "u1", "select x.a from db1.t1 as x", true, "select x.a from db2.t1_ro as x"},
+		{&Rule{Name: "r", Table: "t1", Redirect: "t1_ro"}, "u1", "select a from t2 where b in (select b from t1)", true, "select a from t2 where b in (select b from t1_ro as t1)"},
+	}
+	for _, test := range tests {
+		assert.Nil(t, test.rule.build())
+		node, err := sqlparser.Parse(test.query)
+		assert.Nil(t, err)
+		stmt := &statement{database: "db1", user: test.user, typ: xbase.StatementType(node), node: node}
+		assert.Equal(t, test.fired, test.rule.apply(stmt), test.query)
+		assert.Equal(t, test.want, sqlparser.String(node), test.query)
+	}
+}
+
+func TestRuleBuildError(t *testing.T) {
+	rules := []*Rule{
+		{},
+		{Name: "r1", Limit: -1},
+		{Name: "r1"},
+		{Name: "r1", Redirect: "t2"},
+		{Name: "r1", Limit: 1, Fingerprint: "select ("},
+	}
+	wants := []string{
+		"rewrite.rule.name.can't.be.empty",
+		"rewrite.rule[r1].limit[-1].invalid",
+		"rewrite.rule[r1].replacement.can't.be.empty",
+		"rewrite.rule[r1].table.can't.be.empty.for.force-index.or.redirect",
+		"rewrite.rule[r1].fingerprint[select (].invalid:error parsing regexp: missing closing ): `select (`",
+	}
+	for i, rule := range rules {
+		assert.Equal(t, wants[i], rule.build().Error())
+	}
+}
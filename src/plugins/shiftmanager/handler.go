@@ -46,6 +46,17 @@ const (
 	ShiftTypeRebalance
 )
 
+// String returns the name of the shift type.
+func (typ ShiftType) String() string {
+	switch typ {
+	case ShiftTypeReshard:
+		return "reshard"
+	case ShiftTypeRebalance:
+		return "rebalance"
+	}
+	return "none"
+}
+
 // ShiftInfo used to record basic infos used by shift
 type ShiftInfo struct {
 	From         string
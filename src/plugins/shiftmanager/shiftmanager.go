@@ -12,6 +12,9 @@ import (
 	"fmt"
 	"runtime"
 	"sync"
+	"time"
+
+	"monitor"
 
 	"github.com/radondb/shift/build"
 	"github.com/radondb/shift/shift"
@@ -43,6 +46,7 @@ type shiftInstancesAlived struct {
 	status    ShiftStatus
 	progress  string
 	shiftType ShiftType
+	start     time.Time
 }
 
 // shiftInstancesFinished used to store the finished shift instances no matter success or failed.
@@ -147,7 +151,10 @@ func (shiftMgr *ShiftManager) addAnAlivedInstance(key string, typ ShiftType, shi
 			status:    ShiftStatusMigrating,
 			progress:  "",
 			shiftType: typ,
+			start:     time.Now(),
 		}
+		monitor.ShiftStart(typ.String())
+		monitor.ShiftInstanceStart(typ.String(), key)
 		return nil
 	}
 	return fmt.Errorf("shift.instances.num.exceeding.10.limits")
@@ -164,6 +171,14 @@ func (shiftMgr *ShiftManager) updateFinishedInstance(key string, status ShiftSta
 	// the finished instance added into instancesFinished
 	shiftMgr.instancesFinished[key] = finished
 	// the finished instance in instancesAlived should be removed
+	if alived, ok := shiftMgr.instancesAlived[key]; ok {
+		result := "OK"
+		if status != ShiftStatusSuccess {
+			result = "Error"
+		}
+		monitor.ShiftFinish(typ.String(), result, time.Since(alived.start).Seconds())
+		monitor.ShiftInstanceFinish(typ.String(), key)
+	}
 	delete(shiftMgr.instancesAlived, key)
 }
 
@@ -591,3 +591,9 @@ func TestGetProgress(t *testing.T) {
 	// TODO: now GetProgress() func not implemented.
 	assert.Nil(t, progress)
 }
+
+func TestShiftTypeString(t *testing.T) {
+	assert.Equal(t, "reshard", ShiftTypeReshard.String())
+	assert.Equal(t, "rebalance", ShiftTypeRebalance.String())
+	assert.Equal(t, "none", ShiftTypeNone.String())
+}
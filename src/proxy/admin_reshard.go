@@ -15,6 +15,8 @@ import (
 	"time"
 
 	"backend"
+	"monitor"
+	"plugins/shiftmanager"
 	"router"
 
 	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
@@ -177,12 +179,16 @@ func (reshard *Reshard) shiftTable(user string) error {
 	oneshift := func(db, srcTable, dstDB, dstTable string, user string, spanner *Spanner) {
 		defer wg.Done()
 
+		start := time.Now()
+		monitor.ShiftStart(shiftmanager.ShiftTypeReshard.String())
 		err := reshard.handle.ShiftProcess()
 		reshard.SetShiftProcessBar(shiftFinished)
 		if err != nil {
+			monitor.ShiftFinish(shiftmanager.ShiftTypeReshard.String(), "Error", time.Since(start).Seconds())
 			reshard.SetShiftStatus(err)
 			return
 		}
+		monitor.ShiftFinish(shiftmanager.ShiftTypeReshard.String(), "OK", time.Since(start).Seconds())
 
 		reshard.SetShiftStatus(nil)
 	}
@@ -54,7 +54,7 @@ func (adminXA *AdminXA) Commit() (*sqltypes.Result, error) {
 	}
 
 	for _, row := range qr.Rows {
-		// the format of xaid: txn.xid = fmt.Sprintf("RXID-%v-%v", time.Now().Format("20060102150405"), txn.id)
+		// the format of xaid: txn.xid = fmt.Sprintf("RXID-%v-%v-%v", time.Now().Format("20060102150405"), nodeID, txn.id)
 		data := string(row[3].Raw())
 		xaid := strings.SplitN(data, "-", 3)
 		xaTimeStamp := xaid[1]
@@ -90,7 +90,7 @@ func (adminXA *AdminXA) Rollback() (*sqltypes.Result, error) {
 	}
 
 	for _, row := range qr.Rows {
-		// the format of xaid: txn.xid = fmt.Sprintf("RXID-%v-%v", time.Now().Format("20060102150405"), txn.id)
+		// the format of xaid: txn.xid = fmt.Sprintf("RXID-%v-%v-%v", time.Now().Format("20060102150405"), nodeID, txn.id)
 		data := string(row[3].Raw())
 		xaid := strings.SplitN(data, "-", 3)
 		xaTimeStamp := xaid[1]
@@ -9,9 +9,17 @@
 package proxy
 
 import (
+	"encoding/json"
+	"fmt"
+	"regexp"
 	"time"
 
+	"audit"
+	"config"
+
 	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqldb"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
 	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
 )
 
@@ -36,9 +44,87 @@ func (spanner *Spanner) auditLog(session *driver.Session, m mode, typ string, qu
 	now := time.Now().UTC()
 	switch m {
 	case R:
-		adit.LogReadEvent(typ, user, host, connID, query, status, affected, now)
+		adit.LogReadEvent(typ, user, host, session.Schema(), connID, query, status, affected, now)
 	case W:
-		adit.LogWriteEvent(typ, user, host, connID, query, status, affected, now)
+		adit.LogWriteEvent(typ, user, host, session.Schema(), connID, query, status, affected, now)
 	}
 	return nil
 }
+
+// newAdminEvent creates the admin event of the session with the current meta version.
+func (spanner *Spanner) newAdminEvent(session *driver.Session, action string, params string) *audit.AdminEvent {
+	return &audit.AdminEvent{
+		Start:             time.Now().UTC(),
+		User:              session.User(),
+		UserHost:          session.Addr(),
+		Action:            action,
+		Params:            params,
+		MetaVersionBefore: config.ReadVersion(spanner.conf.Proxy.MetaDir),
+	}
+}
+
+// logAdminEvent used to log the admin event with the outcome.
+func (spanner *Spanner) logAdminEvent(e *audit.AdminEvent, err error) {
+	e.MetaVersionAfter = config.ReadVersion(spanner.conf.Proxy.MetaDir)
+	if err != nil {
+		e.Status = 1
+		e.Error = err.Error()
+	}
+	spanner.audit.LogAdminEvent(e)
+}
+
+var (
+	// SHOW AUDIT STATUS
+	auditStatusRegexp = regexp.MustCompile(`(?is)^show\s+audit\s+status$`)
+)
+
+// isShowAuditStatus returns true if the query is 'SHOW AUDIT STATUS', the parser doesn't support it.
+func isShowAuditStatus(query string) bool {
+	return auditStatusRegexp.MatchString(query)
+}
+
+// handleShowAuditStatus used to handle the 'SHOW AUDIT STATUS'.
+func (spanner *Spanner) handleShowAuditStatus(session *driver.Session) (*sqltypes.Result, error) {
+	privilegePlug := spanner.plugins.PlugPrivilege()
+	if !privilegePlug.IsSuperPriv(session.User()) {
+		return nil, sqldb.NewSQLErrorf(sqldb.ER_SPECIFIC_ACCESS_DENIED_ERROR, "Access denied; lacking super privilege for the operation")
+	}
+
+	status := spanner.audit.Status()
+	filters, err := json.Marshal(status.Filters)
+	if err != nil {
+		return nil, err
+	}
+	// The chain head is anchored outside the log to find the events cut off from the end.
+	head := audit.ChainHead{}
+	if status.ChainHead != nil {
+		head = *status.ChainHead
+	}
+	qr := &sqltypes.Result{}
+	qr.Fields = []*querypb.Field{
+		{Name: "Variable_name", Type: querypb.Type_VARCHAR},
+		{Name: "Value", Type: querypb.Type_VARCHAR},
+	}
+	rows := [][]string{
+		{"audit_mode", status.Mode},
+		{"audit_sink", status.Sink},
+		{"audit_hash_chain", fmt.Sprintf("%v", status.HashChain)},
+		{"audit_chain_head", head.Hash},
+		{"audit_chain_seq", fmt.Sprintf("%d", head.Seq)},
+		{"audit_file", status.File},
+		{"audit_filters", string(filters)},
+		{"audit_queued", fmt.Sprintf("%d", status.Queued)},
+		{"audit_logged", fmt.Sprintf("%d", status.Logged)},
+		{"audit_filtered", fmt.Sprintf("%d", status.Filtered)},
+		{"audit_failed", fmt.Sprintf("%d", status.Failed)},
+		{"audit_dropped", fmt.Sprintf("%d", status.Dropped)},
+	}
+	for _, row := range rows {
+		qr.Rows = append(qr.Rows, []sqltypes.Value{
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(row[0])),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(row[1])),
+		})
+	}
+	qr.RowsAffected = uint64(len(qr.Rows))
+	return qr, nil
+}
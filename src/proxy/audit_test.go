@@ -0,0 +1,151 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"testing"
+	"time"
+
+	"config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestProxyShowAuditStatus(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	conf := MockDefaultConfig()
+	conf.Audit.Mode = "A"
+	fakedbs, proxy, cleanup := MockProxy1(log, conf)
+	defer cleanup()
+	address := proxy.Address()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("select .*", &sqltypes.Result{})
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+	assert.Nil(t, err)
+	defer client.Quit()
+
+	// Only the statements on t2 are logged.
+	err = proxy.SetAuditFilters([]*config.AuditFilterConfig{{Tables: []string{"test.t2"}}})
+	assert.Nil(t, err)
+	{
+		_, err = client.FetchAll("create database test", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("create table test.t2(id int, b int) partition by hash(id)", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("select 1", -1)
+		assert.Nil(t, err)
+	}
+
+	for proxy.Audit().Status().Logged+proxy.Audit().Status().Filtered < 3 {
+		time.Sleep(time.Millisecond * 10)
+	}
+	qr, err := client.FetchAll("show audit status", -1)
+	assert.Nil(t, err)
+	got := make(map[string]string)
+	for _, row := range qr.Rows {
+		got[row[0].String()] = row[1].String()
+	}
+	assert.Equal(t, "A", got["audit_mode"])
+	assert.Equal(t, "file", got["audit_sink"])
+	assert.Equal(t, "false", got["audit_hash_chain"])
+	assert.Equal(t, "", got["audit_chain_head"])
+	assert.Equal(t, "0", got["audit_chain_seq"])
+	assert.Equal(t, `[{"tables":["test.t2"]}]`, got["audit_filters"])
+	assert.Equal(t, "1", got["audit_logged"])
+	assert.Equal(t, "2", got["audit_filtered"])
+	assert.Equal(t, "0", got["audit_failed"])
+	assert.Equal(t, "0", got["audit_dropped"])
+	assert.Equal(t, []*config.AuditFilterConfig{{Tables: []string{"test.t2"}}}, proxy.Config().Audit.Filters)
+
+	// Invalid filters.
+	err = proxy.SetAuditFilters([]*config.AuditFilterConfig{{Status: "x"}})
+	assert.NotNil(t, err)
+	assert.Equal(t, []*config.AuditFilterConfig{{Tables: []string{"test.t2"}}}, proxy.Config().Audit.Filters)
+}
+
+func TestProxyShowAuditStatusPrivilege(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxyPrivilegeN(log, MockDefaultConfig())
+	defer cleanup()
+	address := proxy.Address()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+	assert.Nil(t, err)
+	defer client.Quit()
+	_, err = client.FetchAll("show audit status", -1)
+	want := "Access denied; lacking super privilege for the operation (errno 1227) (sqlstate 42000)"
+	assert.Equal(t, want, err.Error())
+}
+
+func TestProxyRadonAdminEvents(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("show databases", &sqltypes.Result{})
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+	assert.Nil(t, err)
+	defer client.Quit()
+	{
+		_, err = client.FetchAll("radon cleanup", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("radon reshard db.tb to db2.t2", -1)
+		assert.NotNil(t, err)
+		// Not an admin action.
+		_, err = client.FetchAll("radon attachlist", -1)
+		assert.Nil(t, err)
+	}
+
+	events := proxy.Audit().AdminEvents(0)
+	assert.Equal(t, 2, len(events))
+	assert.Equal(t, "RADON CLEANUP", events[0].Action)
+	assert.Equal(t, "mock", events[0].User)
+	assert.EqualValues(t, 0, events[0].Status)
+	assert.Equal(t, events[0].MetaVersionBefore, events[0].MetaVersionAfter)
+	assert.Equal(t, "RADON RESHARD", events[1].Action)
+	assert.Equal(t, `{"database":"","new-table":"db2.t2","table":"db.tb"}`, events[1].Params)
+	assert.EqualValues(t, 1, events[1].Status)
+	assert.NotEqual(t, "", events[1].Error)
+}
+
+func TestRadonParams(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"radon attach('127.0.0.1:6000', 'root', '123456')", `{"address":"127.0.0.1:6000","password":"******","user":"root"}`},
+		{"radon detach('127.0.0.1:6000')", `{"name":"127.0.0.1:6000"}`},
+		{"radon rebalance", ""},
+	}
+	for _, test := range tests {
+		node, err := sqlparser.Parse(test.query)
+		assert.Nil(t, err)
+		assert.Equal(t, test.want, radonParams(nil, node.(*sqlparser.Radon)), test.query)
+	}
+}
@@ -10,16 +10,22 @@ package proxy
 
 import (
 	"bytes"
-	"crypto/sha1"
-	"encoding/hex"
 	"fmt"
 	"net"
-	"strings"
 
+	"plugins/authentication"
+
+	"github.com/pkg/errors"
 	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/proto"
 	"github.com/xelabs/go-mysqlstack/sqldb"
 )
 
+// insecureTransportError returns the same error as MySQL with require_secure_transport=ON.
+func insecureTransportError() error {
+	return sqldb.NewSQLError1(3159, "HY000", "Connections using insecure transport are prohibited while --require_secure_transport=ON.")
+}
+
 func localHostLogin(host string) bool {
 	return host == "127.0.0.1"
 }
@@ -45,6 +51,12 @@ func (spanner *Spanner) SessionCheck(s *driver.Session) error {
 	}
 
 	log := spanner.log
+	// The session can't switch to TLS if the TLS is disabled.
+	if spanner.isRequireSecureTransport() && !spanner.tlsEnabled {
+		log.Warning("proxy.spanner.require.secure.transport.but.tls.disabled.from[%s]", s.Addr())
+		return insecureTransportError()
+	}
+
 	host, _, err := net.SplitHostPort(s.Addr())
 	if err != nil {
 		log.Error("proxy.spanner.split.address.error:%+v", s.Addr())
@@ -64,20 +76,132 @@ func (spanner *Spanner) SessionCheck(s *driver.Session) error {
 	return nil
 }
 
+// accessDenied returns the access denied error of the user.
+func accessDenied(user string) error {
+	return sqldb.NewSQLErrorf(sqldb.ER_ACCESS_DENIED_ERROR, "Access denied for user '%v'", user)
+}
+
 // AuthCheck impl.
 func (spanner *Spanner) AuthCheck(s *driver.Session) error {
+	// Secure transport check, the session switches to TLS after the SessionCheck.
+	if spanner.isRequireSecureTransport() && !s.TLS() {
+		spanner.log.Warning("proxy.spanner.user[%s].from[%s].insecure.transport.denied", s.User(), s.Addr())
+		return insecureTransportError()
+	}
+
 	// Local login bypass.
-	if localUserLogin(s) {
+	if spanner.isLocalRootLogin() && localUserLogin(s) {
 		return nil
 	}
 
 	log := spanner.log
 	user := s.User()
+	auth := spanner.plugins.PlugAuthentication()
+
+	account, err := auth.Lookup(user)
+	if err != nil {
+		log.Error("proxy: auth.can't.find.the.user[%s].error:%+v", user, err)
+		return accessDenied(user)
+	}
+
+	switch s.AuthPluginName() {
+	case authentication.CachingSHA2Password:
+		err = spanner.cachingSHA2Auth(s, account)
+	default:
+		// The mysql_native_password scramble can only be checked by the native hash,
+		// the others need the password, switch to the caching_sha2_password.
+		if account.Plugin == authentication.NativePassword {
+			if !authentication.CheckNativeScramble(account.AuthenticationString, s.Salt(), s.Scramble()) {
+				err = errors.New("password.invalid")
+			}
+			break
+		}
+		if _, err = s.AuthSwitch(authentication.CachingSHA2Password); err == nil {
+			err = spanner.cachingSHA2Auth(s, account)
+		}
+	}
+	if err != nil {
+		log.Error("proxy: auth.user[%s].failed:%v", user, err)
+		return accessDenied(user)
+	}
+
+	// Max user connections check.
+	if err := spanner.checkUserConnections(s); err != nil {
+		log.Warning("proxy: auth.user[%s].from[%s].error:%v", user, s.Addr(), err)
+		return err
+	}
+	return nil
+}
 
-	// Server salt.
+// cachingSHA2Auth used to do the caching_sha2_password authentication.
+// The fast authentication checks the scramble by the cache of the users which passed the full authentication.
+// The full authentication needs the password, which is sent in clear text on TLS, or encrypted by the RSA public key.
+func (spanner *Spanner) cachingSHA2Auth(s *driver.Session, account *authentication.Account) error {
+	auth := spanner.plugins.PlugAuthentication()
 	salt := s.Salt()
-	// Client response.
-	resp := s.Scramble()
+	scramble := s.Scramble()
+
+	if len(scramble) == 0 {
+		return auth.Authenticate(account, "")
+	}
+	if auth.FastAuthenticate(account, salt, scramble) {
+		return s.WriteAuthMoreData([]byte{proto.CachingSHA2FastAuthSuccess})
+	}
+
+	// Full authentication.
+	if err := s.WriteAuthMoreData([]byte{proto.CachingSHA2PerformFullAuthentication}); err != nil {
+		return err
+	}
+	data, err := s.ReadAuthData()
+	if err != nil {
+		return err
+	}
+
+	var password string
+	if s.TLS() {
+		password = string(bytes.TrimRight(data, "\x00"))
+	} else {
+		if len(data) == 1 && data[0] == proto.CachingSHA2RequestPublicKey {
+			key, err := auth.PublicKey()
+			if err != nil {
+				return err
+			}
+			if err := s.WriteAuthMoreData(key); err != nil {
+				return err
+			}
+			if data, err = s.ReadAuthData(); err != nil {
+				return err
+			}
+		}
+		if password, err = auth.DecryptPassword(data, salt); err != nil {
+			return err
+		}
+	}
+	return auth.Authenticate(account, password)
+}
+
+// mysqlAuthenticator tuple, the accounts are the radon-native catalog users and the mysql.user of the backends.
+type mysqlAuthenticator struct {
+	spanner *Spanner
+}
+
+func newMySQLAuthenticator(spanner *Spanner) *mysqlAuthenticator {
+	return &mysqlAuthenticator{spanner: spanner}
+}
+
+// Init impl.
+func (m *mysqlAuthenticator) Init() error {
+	return nil
+}
+
+// Lookup impl.
+func (m *mysqlAuthenticator) Lookup(user string) (*authentication.Account, error) {
+	spanner := m.spanner
+
+	// The radon-native user first.
+	if authStr, ok := spanner.plugins.PlugPrivilege().Catalog().AuthenticationString(user); ok {
+		return &authentication.Account{User: user, Plugin: authentication.AccountPlugin(authStr), AuthenticationString: authStr}, nil
+	}
 
 	// Diff query for different MySQL version.
 	var query string
@@ -91,52 +215,23 @@ func (spanner *Spanner) AuthCheck(s *driver.Session) error {
 
 	qr, err := spanner.ExecuteSingle(query)
 	if err != nil {
-		log.Error("proxy: auth.error:%+v", err)
-		return sqldb.NewSQLErrorf(sqldb.ER_ACCESS_DENIED_ERROR, "Access denied for user '%v'", user)
+		return nil, err
 	}
 
 	// User not exists.
 	if len(qr.Rows) == 0 {
-		log.Error("proxy: auth.can't.find.the.user:%s", user)
-		return sqldb.NewSQLErrorf(sqldb.ER_ACCESS_DENIED_ERROR, "Access denied for user '%v'", user)
-	}
-
-	// mysql.user.authentication_string is ['*' + HEX(SHA1(SHA1(password)))]
-	authStr := strings.TrimPrefix(qr.Rows[0][0].String(), "*")
-	wantStage2, err := hex.DecodeString(authStr)
-	if err != nil {
-		log.Error("proxy: auth.user[%s].decode[%s].error:%+v", user, authStr, err)
-		return sqldb.NewSQLErrorf(sqldb.ER_ACCESS_DENIED_ERROR, "Access denied for user '%v'", user)
-	}
-
-	// last= SHA1(salt <concat> SHA1(SHA1(password)))
-	crypt := sha1.New()
-	crypt.Write(salt)
-	crypt.Write(wantStage2)
-	want := crypt.Sum(nil)
-
-	// gotStage1 = SHA1(password)
-	gotStage1 := make([]byte, 20)
-	for i := range resp {
-		// SHA1(password) = (resp XOR want)
-		gotStage1[i] = (resp[i] ^ want[i])
+		return nil, errors.Errorf("user[%s].not.exists", user)
 	}
+	authStr := qr.Rows[0][0].String()
+	return &authentication.Account{User: user, Plugin: authentication.AccountPlugin(authStr), AuthenticationString: authStr}, nil
+}
 
-	// gotStage2 = SHA1(SHA1(password))
-	crypt.Reset()
-	crypt.Write(gotStage1)
-	gotStage2 := crypt.Sum(nil)
-
-	// last= SHA1(salt <concat> SHA1(SHA1(password)))
-	crypt.Reset()
-	crypt.Write(salt)
-	crypt.Write(gotStage2)
-	got := crypt.Sum(nil)
+// Authenticate impl.
+func (m *mysqlAuthenticator) Authenticate(account *authentication.Account, password string) error {
+	return authentication.CheckPassword(account.Plugin, account.AuthenticationString, password)
+}
 
-	if !bytes.Equal(want, got) {
-		log.Warning("spanner.auth\nwant:\n\tstage2:%+v\n\tlast:%+v\ngot\n\tstage2:%+v\n\tlast:%+v\n\n\tsalt:%+v", wantStage2, want, gotStage2, got, salt)
-		log.Error("proxy: auth.user[%s].failed(password.invalid):want[%+v]!=got[%+v]", user, want, got)
-		return sqldb.NewSQLErrorf(sqldb.ER_ACCESS_DENIED_ERROR, "Access denied for user '%v'", user)
-	}
+// Close impl.
+func (m *mysqlAuthenticator) Close() error {
 	return nil
 }
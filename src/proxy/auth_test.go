@@ -9,8 +9,16 @@
 package proxy
 
 import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
 	"testing"
 
+	"fakedb"
+	"plugins/authentication"
+	"xbase"
+
 	"github.com/stretchr/testify/assert"
 	"github.com/xelabs/go-mysqlstack/driver"
 	"github.com/xelabs/go-mysqlstack/xlog"
@@ -192,3 +200,261 @@ func TestProxyAuthMySQLVersionToLower(t *testing.T) {
 		assert.Equal(t, want, got)
 	}
 }
+
+func TestProxyAuthTLS(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	tmpDir := fakedb.GetTmpDir("", "proxy_tls_", log)
+	defer os.RemoveAll(tmpDir)
+	ca, cert, key, err := xbase.MockTLSFiles(tmpDir)
+	assert.Nil(t, err)
+
+	conf := MockDefaultConfig()
+	conf.Proxy.TLSCert = cert
+	conf.Proxy.TLSKey = key
+	conf.Proxy.TLSCA = ca
+	fakedbs, proxy, cleanup := MockProxy1(log, conf)
+	defer cleanup()
+	address := proxy.Address()
+
+	fakedbs.AddQuery("select version() as version", resultVersion57)
+	tlsConfig, err := xbase.NewClientTLSConfig(ca, cert, key, "localhost", false)
+	assert.Nil(t, err)
+
+	// TLS and the plain connections are both OK.
+	{
+		client, err := driver.NewTLSConn("mock", "mock", address, "", "utf8", tlsConfig)
+		assert.Nil(t, err)
+		client.Close()
+
+		client, err = driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		client.Close()
+	}
+
+	// Require secure transport.
+	{
+		proxy.SetRequireSecureTransport(true)
+		_, err := driver.NewConn("root", "", address, "", "utf8")
+		want := "Connections using insecure transport are prohibited while --require_secure_transport=ON. (errno 3159) (sqlstate HY000)"
+		assert.Equal(t, want, err.Error())
+
+		client, err := driver.NewTLSConn("root", "", address, "", "utf8", tlsConfig)
+		assert.Nil(t, err)
+		client.Close()
+
+		// Auth password error on TLS.
+		_, err = driver.NewTLSConn("mock", "mockx", address, "", "utf8", tlsConfig)
+		want = "Access denied for user 'mock' (errno 1045) (sqlstate 28000)"
+		assert.Equal(t, want, err.Error())
+	}
+}
+
+func TestProxyAuthTLSVerifyClient(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	tmpDir := fakedb.GetTmpDir("", "proxy_tls_", log)
+	defer os.RemoveAll(tmpDir)
+	ca, cert, key, err := xbase.MockTLSFiles(tmpDir)
+	assert.Nil(t, err)
+
+	conf := MockDefaultConfig()
+	conf.Proxy.TLSCert = cert
+	conf.Proxy.TLSKey = key
+	conf.Proxy.TLSCA = ca
+	conf.Proxy.TLSVerifyClient = true
+	_, proxy, cleanup := MockProxy1(log, conf)
+	defer cleanup()
+	address := proxy.Address()
+
+	// Without the client cert.
+	{
+		tlsConfig, err := xbase.NewClientTLSConfig(ca, "", "", "localhost", false)
+		assert.Nil(t, err)
+		_, err = driver.NewTLSConn("root", "", address, "", "utf8", tlsConfig)
+		assert.NotNil(t, err)
+	}
+
+	// With the client cert.
+	{
+		tlsConfig, err := xbase.NewClientTLSConfig(ca, cert, key, "localhost", false)
+		assert.Nil(t, err)
+		client, err := driver.NewTLSConn("root", "", address, "", "utf8", tlsConfig)
+		assert.Nil(t, err)
+		client.Close()
+	}
+}
+
+func TestProxyAuthRequireSecureTransportWithoutTLS(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	conf := MockDefaultConfig()
+	conf.Proxy.RequireSecureTransport = true
+	_, proxy, cleanup := MockProxy1(log, conf)
+	defer cleanup()
+	address := proxy.Address()
+
+	{
+		_, err := driver.NewConn("root", "", address, "", "utf8")
+		want := "Connections using insecure transport are prohibited while --require_secure_transport=ON. (errno 3159) (sqlstate HY000)"
+		assert.Equal(t, want, err.Error())
+	}
+}
+
+func TestProxyAuthCachingSHA2Password(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	conf := MockDefaultConfig()
+	conf.Auth.DefaultAuthPlugin = authentication.CachingSHA2Password
+	fakedbs, proxy, cleanup := MockProxy1(log, conf)
+	defer cleanup()
+	address := proxy.Address()
+	fakedbs.AddQuery("select version() as version", resultVersion57)
+
+	// Full authentication with the RSA public key, then the fast authentication.
+	{
+		for i := 0; i < 2; i++ {
+			client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+			assert.Nil(t, err)
+			client.Close()
+		}
+	}
+
+	// Password error.
+	{
+		_, err := driver.NewConn("mock", "mockx", address, "", "utf8")
+		want := "Access denied for user 'mock' (errno 1045) (sqlstate 28000)"
+		assert.Equal(t, want, err.Error())
+
+		// The cache is dropped, the full authentication is OK.
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		client.Close()
+	}
+
+	// Local root login passby.
+	{
+		client, err := driver.NewConn("root", "", "127.0.0.1"+address, "", "utf8")
+		assert.Nil(t, err)
+		client.Close()
+	}
+}
+
+func TestProxyAuthCachingSHA2PasswordTLS(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	tmpDir := fakedb.GetTmpDir("", "proxy_tls_", log)
+	defer os.RemoveAll(tmpDir)
+	ca, cert, key, err := xbase.MockTLSFiles(tmpDir)
+	assert.Nil(t, err)
+
+	conf := MockDefaultConfig()
+	conf.Proxy.TLSCert = cert
+	conf.Proxy.TLSKey = key
+	conf.Auth.DefaultAuthPlugin = authentication.CachingSHA2Password
+	fakedbs, proxy, cleanup := MockProxy1(log, conf)
+	defer cleanup()
+	address := proxy.Address()
+	fakedbs.AddQuery("select version() as version", resultVersion57)
+
+	tlsConfig, err := xbase.NewClientTLSConfig(ca, "", "", "localhost", false)
+	assert.Nil(t, err)
+
+	// Full authentication with the clear password on TLS.
+	{
+		client, err := driver.NewTLSConn("mock", "mock", address, "", "utf8", tlsConfig)
+		assert.Nil(t, err)
+		client.Close()
+
+		_, err = driver.NewTLSConn("mock", "mockx", address, "", "utf8", tlsConfig)
+		want := "Access denied for user 'mock' (errno 1045) (sqlstate 28000)"
+		assert.Equal(t, want, err.Error())
+	}
+}
+
+func TestProxyAuthFileAuthenticator(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	tmpDir := fakedb.GetTmpDir("", "proxy_auth_", log)
+	defer os.RemoveAll(tmpDir)
+
+	file := path.Join(tmpDir, "users.json")
+	sha2 := authentication.CachingSHA2PasswordHash("sha2", []byte("abcdefghijabcdefghij"), 5000)
+	users := fmt.Sprintf(`{"users":[{"user":"native","authentication-string":"%s"},{"user":"sha2","authentication-string":"%s"}]}`, authentication.NativePasswordHash("native"), sha2)
+	err := ioutil.WriteFile(file, []byte(users), 0600)
+	assert.Nil(t, err)
+
+	conf := MockDefaultConfig()
+	conf.Auth.Authenticator = authentication.FileAuthenticator
+	conf.Auth.File = file
+	_, proxy, cleanup := MockProxy1(log, conf)
+	defer cleanup()
+	address := proxy.Address()
+
+	// The native account with the mysql_native_password.
+	{
+		client, err := driver.NewConn("native", "native", address, "", "utf8")
+		assert.Nil(t, err)
+		client.Close()
+
+		_, err = driver.NewConn("native", "nativex", address, "", "utf8")
+		assert.NotNil(t, err)
+	}
+
+	// The caching_sha2_password account, switch the auth plugin.
+	{
+		client, err := driver.NewConn("sha2", "sha2", address, "", "utf8")
+		assert.Nil(t, err)
+		client.Close()
+
+		_, err = driver.NewConn("sha2", "sha2x", address, "", "utf8")
+		assert.NotNil(t, err)
+	}
+
+	// User not exists.
+	{
+		_, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		want := "Access denied for user 'mock' (errno 1045) (sqlstate 28000)"
+		assert.Equal(t, want, err.Error())
+	}
+}
+
+func TestProxyAuthLDAPAuthenticator(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	tmpDir := fakedb.GetTmpDir("", "proxy_auth_", log)
+	defer os.RemoveAll(tmpDir)
+
+	file := path.Join(tmpDir, "users.ldif")
+	err := ioutil.WriteFile(file, []byte("dn: uid=alice,ou=people,dc=radon,dc=io\nuserPassword: {CLEARTEXT}alice\n"), 0600)
+	assert.Nil(t, err)
+
+	conf := MockDefaultConfig()
+	conf.Auth.Authenticator = authentication.LDAPAuthenticator
+	conf.Auth.File = file
+	conf.Auth.LDAPBaseDN = "ou=people,dc=radon,dc=io"
+	_, proxy, cleanup := MockProxy1(log, conf)
+	defer cleanup()
+	address := proxy.Address()
+
+	{
+		client, err := driver.NewConn("alice", "alice", address, "", "utf8")
+		assert.Nil(t, err)
+		client.Close()
+
+		_, err = driver.NewConn("alice", "alicex", address, "", "utf8")
+		assert.NotNil(t, err)
+
+		_, err = driver.NewConn("alice", "", address, "", "utf8")
+		assert.NotNil(t, err)
+	}
+}
+
+func TestProxyAuthDisableLocalRootLogin(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	conf := MockDefaultConfig()
+	conf.Auth.DisableLocalRootLogin = true
+	fakedbs, proxy, cleanup := MockProxy1(log, conf)
+	defer cleanup()
+	address := proxy.Address()
+	fakedbs.AddQuery("select version() as version", resultVersion57)
+
+	{
+		_, err := driver.NewConn("root", "", "127.0.0.1"+address, "", "utf8")
+		want := "Access denied for user 'root' (errno 1045) (sqlstate 28000)"
+		assert.Equal(t, want, err.Error())
+	}
+}
@@ -53,7 +53,7 @@ func (spanner *Spanner) handleChecksumTable(session *driver.Session, query strin
 			continue
 		}
 
-		qr, err := spanner.ExecuteNormal(session, database, sqlparser.String(&newNode), &newNode)
+		qr, err := spanner.executeChecksum(session, database, sqlparser.String(&newNode), &newNode)
 		if err != nil {
 			// Database or table not exist, we return NULL
 			if strings.Contains(fmt.Sprintf("%+v", err), "doesn't exist") {
@@ -85,3 +85,13 @@ func (spanner *Spanner) handleChecksumTable(session *driver.Session, query strin
 	}
 	return newqr, nil
 }
+
+// executeChecksum used to execute the checksum in the snapshot txn if the session is in,
+// so the checksum is computed on the globally consistent read view.
+func (spanner *Spanner) executeChecksum(session *driver.Session, database string, query string, node sqlparser.Statement) (*sqltypes.Result, error) {
+	txSession := spanner.sessions.getTxnSession(session)
+	if txn := txSession.transaction; txn != nil && txn.IsSnapshot() {
+		return spanner.ExecuteMultiStmtsInTxn(session, database, query, node)
+	}
+	return spanner.ExecuteNormal(session, database, query, node)
+}
@@ -0,0 +1,362 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"xbase"
+	"xbase/stats"
+	"xcontext"
+
+	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqldb"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+const (
+	// digestsMaxSize is the max number of the digests, the statements of the
+	// new digests are accumulated into the overflow digest(empty digest) when reached.
+	digestsMaxSize = 10000
+)
+
+// digestLatencyCutoffs is the cutoffs(in microsecond) of the latency histogram.
+var digestLatencyCutoffs = []int64{1000, 5000, 10000, 50000, 100000, 500000, 1000000, 5000000, 10000000}
+
+// digest tuple.
+type digest struct {
+	schema       string
+	digest       string
+	text         string
+	count        uint64
+	errors       uint64
+	rowsSent     uint64
+	rowsExamined uint64
+	sumFanout    uint64
+	maxFanout    uint64
+	maxLatency   time.Duration
+	latency      *stats.Histogram
+	firstSeen    time.Time
+	lastSeen     time.Time
+}
+
+// DigestRow tuple.
+type DigestRow struct {
+	Schema       string          `json:"schema"`
+	Digest       string          `json:"digest"`
+	DigestText   string          `json:"digest-text"`
+	Count        uint64          `json:"count"`
+	Errors       uint64          `json:"errors"`
+	RowsSent     uint64          `json:"rows-sent"`
+	RowsExamined uint64          `json:"rows-examined"`
+	SumFanout    uint64          `json:"sum-fanout"`
+	MaxFanout    uint64          `json:"max-fanout"`
+	SumLatency   time.Duration   `json:"sum-latency"`
+	MaxLatency   time.Duration   `json:"max-latency"`
+	Latency      json.RawMessage `json:"latency"`
+	FirstSeen    time.Time       `json:"first-seen"`
+	LastSeen     time.Time       `json:"last-seen"`
+}
+
+// AvgLatency returns the average latency of the digest.
+func (r *DigestRow) AvgLatency() time.Duration {
+	if r.Count == 0 {
+		return 0
+	}
+	return r.SumLatency / time.Duration(r.Count)
+}
+
+// digestShards is the number of the shards of the digests, one for each first hex digit of the digest,
+// the statements of the different shards are recorded in parallel.
+const digestShards = 16
+
+// digestShard tuple.
+type digestShard struct {
+	mu      sync.Mutex
+	digests map[string]*digest
+}
+
+// Digests tuple, the statement digests summary keyed by the schema and the query fingerprint.
+type Digests struct {
+	enabled int32
+	size    int
+	// The number of the digests of all the shards.
+	count  int64
+	shards [digestShards]*digestShard
+}
+
+// NewDigests creates the enabled Digests with the max number of the digests.
+func NewDigests(size int) *Digests {
+	d := &Digests{
+		enabled: 1,
+		size:    size,
+	}
+	for i := range d.shards {
+		d.shards[i] = &digestShard{digests: make(map[string]*digest)}
+	}
+	return d
+}
+
+func newDigests(enabled bool) *Digests {
+	d := NewDigests(digestsMaxSize)
+	d.SetEnabled(enabled)
+	return d
+}
+
+// SetEnabled used to enable or disable the recording, the recorded digests are kept.
+func (d *Digests) SetEnabled(enabled bool) {
+	v := int32(0)
+	if enabled {
+		v = 1
+	}
+	atomic.StoreInt32(&d.enabled, v)
+}
+
+// Enabled returns true if the statements are recorded.
+func (d *Digests) Enabled() bool {
+	return atomic.LoadInt32(&d.enabled) == 1
+}
+
+// digestOf returns the digest(md5 hex) of the fingerprint.
+func digestOf(text string) string {
+	sum := md5.Sum([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+// Record used to record the statement to its digest, the query should be formatted by the parser
+// to make the fingerprints of the same statements equal.
+func (d *Digests) Record(schema string, query string, latency time.Duration, qr *sqltypes.Result, execStats *xcontext.ExecStats, err error) {
+	if !d.Enabled() {
+		return
+	}
+	text := xbase.Fingerprint(query)
+	hash := digestOf(text)
+	key := schema + "." + hash
+	now := time.Now()
+
+	// The digests are sharded by the first hex digit.
+	shard := d.shards[strings.IndexByte("0123456789abcdef", hash[0])]
+	shard.mu.Lock()
+	dg, ok := shard.digests[key]
+	if !ok && atomic.LoadInt64(&d.count) < int64(d.size) {
+		dg, ok = newDigest(schema, hash, text, now), true
+		shard.digests[key] = dg
+		atomic.AddInt64(&d.count, 1)
+	}
+	if ok {
+		dg.record(now, latency, qr, execStats, err)
+		shard.mu.Unlock()
+		return
+	}
+	shard.mu.Unlock()
+
+	// The overflow digest is kept in the first shard.
+	shard = d.shards[0]
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	if dg, ok = shard.digests["."]; !ok {
+		dg = newDigest("", "", "", now)
+		shard.digests["."] = dg
+	}
+	dg.record(now, latency, qr, execStats, err)
+}
+
+func newDigest(schema string, hash string, text string, now time.Time) *digest {
+	return &digest{
+		schema:    schema,
+		digest:    hash,
+		text:      text,
+		latency:   stats.NewHistogram("", digestLatencyCutoffs),
+		firstSeen: now,
+	}
+}
+
+// record used to accumulate the statement, the lock of the shard must be held.
+func (dg *digest) record(now time.Time, latency time.Duration, qr *sqltypes.Result, execStats *xcontext.ExecStats, err error) {
+	dg.count++
+	if err != nil {
+		dg.errors++
+	}
+	if qr != nil {
+		dg.rowsSent += uint64(len(qr.Rows))
+	}
+	dg.rowsExamined += execStats.Rows()
+	fanout := execStats.Querys()
+	dg.sumFanout += fanout
+	if fanout > dg.maxFanout {
+		dg.maxFanout = fanout
+	}
+	if latency > dg.maxLatency {
+		dg.maxLatency = latency
+	}
+	dg.latency.Add(int64(latency / time.Microsecond))
+	dg.lastSeen = now
+}
+
+// Reset used to clear all the digests.
+func (d *Digests) Reset() {
+	for _, shard := range d.shards {
+		shard.mu.Lock()
+		n := len(shard.digests)
+		// The overflow digest isn't counted.
+		if _, ok := shard.digests["."]; ok {
+			n--
+		}
+		atomic.AddInt64(&d.count, -int64(n))
+		shard.digests = make(map[string]*digest)
+		shard.mu.Unlock()
+	}
+}
+
+// Rows returns the digests order by the sum latency desc.
+func (d *Digests) Rows() []*DigestRow {
+	rows := []*DigestRow{}
+	for _, shard := range d.shards {
+		shard.mu.Lock()
+		for _, dg := range shard.digests {
+			rows = append(rows, dg.row())
+		}
+		shard.mu.Unlock()
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].SumLatency != rows[j].SumLatency {
+			return rows[i].SumLatency > rows[j].SumLatency
+		}
+		return rows[i].Digest < rows[j].Digest
+	})
+	return rows
+}
+
+// row returns the row of the digest, the lock of the shard must be held.
+func (dg *digest) row() *DigestRow {
+	return &DigestRow{
+		Schema:       dg.schema,
+		Digest:       dg.digest,
+		DigestText:   dg.text,
+		Count:        dg.count,
+		Errors:       dg.errors,
+		RowsSent:     dg.rowsSent,
+		RowsExamined: dg.rowsExamined,
+		SumFanout:    dg.sumFanout,
+		MaxFanout:    dg.maxFanout,
+		SumLatency:   time.Duration(dg.latency.Total()) * time.Microsecond,
+		MaxLatency:   dg.maxLatency,
+		Latency:      json.RawMessage(dg.latency.String()),
+		FirstSeen:    dg.firstSeen,
+		LastSeen:     dg.lastSeen,
+	}
+}
+
+// digestsResult returns the digests as the rows of the 'radon.statement_digests' table,
+// the latency columns are in microsecond.
+func digestsResult(rows []*DigestRow) *sqltypes.Result {
+	qr := &sqltypes.Result{}
+	qr.Fields = []*querypb.Field{
+		{Name: "SCHEMA_NAME", Type: querypb.Type_VARCHAR},
+		{Name: "DIGEST", Type: querypb.Type_VARCHAR},
+		{Name: "DIGEST_TEXT", Type: querypb.Type_VARCHAR},
+		{Name: "COUNT_STAR", Type: querypb.Type_UINT64},
+		{Name: "SUM_ERRORS", Type: querypb.Type_UINT64},
+		{Name: "SUM_ROWS_SENT", Type: querypb.Type_UINT64},
+		{Name: "SUM_ROWS_EXAMINED", Type: querypb.Type_UINT64},
+		{Name: "SUM_FANOUT", Type: querypb.Type_UINT64},
+		{Name: "MAX_FANOUT", Type: querypb.Type_UINT64},
+		{Name: "SUM_LATENCY", Type: querypb.Type_UINT64},
+		{Name: "AVG_LATENCY", Type: querypb.Type_UINT64},
+		{Name: "MAX_LATENCY", Type: querypb.Type_UINT64},
+		{Name: "LATENCY_HISTOGRAM", Type: querypb.Type_VARCHAR},
+		{Name: "FIRST_SEEN", Type: querypb.Type_DATETIME},
+		{Name: "LAST_SEEN", Type: querypb.Type_DATETIME},
+	}
+	for _, r := range rows {
+		qr.Rows = append(qr.Rows, []sqltypes.Value{
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(r.Schema)),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(r.Digest)),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(r.DigestText)),
+			uint64Value(r.Count),
+			uint64Value(r.Errors),
+			uint64Value(r.RowsSent),
+			uint64Value(r.RowsExamined),
+			uint64Value(r.SumFanout),
+			uint64Value(r.MaxFanout),
+			uint64Value(uint64(r.SumLatency / time.Microsecond)),
+			uint64Value(uint64(r.AvgLatency() / time.Microsecond)),
+			uint64Value(uint64(r.MaxLatency / time.Microsecond)),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(r.Latency)),
+			sqltypes.MakeTrusted(querypb.Type_DATETIME, []byte(r.FirstSeen.Format("2006-01-02 15:04:05"))),
+			sqltypes.MakeTrusted(querypb.Type_DATETIME, []byte(r.LastSeen.Format("2006-01-02 15:04:05"))),
+		})
+	}
+	qr.RowsAffected = uint64(len(qr.Rows))
+	return qr
+}
+
+type digestAction int
+
+const (
+	digestShow digestAction = iota
+	digestReset
+)
+
+var (
+	// SHOW QUERY DIGESTS
+	digestShowRegexp = regexp.MustCompile(`(?is)^show\s+query\s+digests$`)
+	// RESET QUERY DIGESTS
+	digestResetRegexp = regexp.MustCompile(`(?is)^reset\s+query\s+digests$`)
+)
+
+// digestStmt tuple.
+type digestStmt struct {
+	action digestAction
+}
+
+// parseDigestStmt used to parse the digests statements which the parser doesn't support,
+// returns nil if the query isn't a digests statement.
+func parseDigestStmt(query string) *digestStmt {
+	switch {
+	case digestShowRegexp.MatchString(query):
+		return &digestStmt{action: digestShow}
+	case digestResetRegexp.MatchString(query):
+		return &digestStmt{action: digestReset}
+	}
+	return nil
+}
+
+// handleDigest used to handle the 'SHOW QUERY DIGESTS' and 'RESET QUERY DIGESTS'.
+func (spanner *Spanner) handleDigest(session *driver.Session, query string, stmt *digestStmt) (*sqltypes.Result, error) {
+	privilegePlug := spanner.plugins.PlugPrivilege()
+	if !privilegePlug.IsSuperPriv(session.User()) {
+		return nil, sqldb.NewSQLErrorf(sqldb.ER_SPECIFIC_ACCESS_DENIED_ERROR, "Access denied; lacking super privilege for the operation")
+	}
+
+	switch stmt.action {
+	case digestReset:
+		spanner.digests.Reset()
+		return &sqltypes.Result{}, nil
+	default:
+		return digestsResult(spanner.digests.Rows()), nil
+	}
+}
+
+// digestQueryType returns the audit query type of the digests statement.
+func digestQueryType(stmt *digestStmt) string {
+	if stmt.action == digestReset {
+		return xbase.RADON
+	}
+	return xbase.SHOW
+}
@@ -0,0 +1,199 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"xcontext"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestDigests(t *testing.T) {
+	digests := NewDigests(2)
+	qr := &sqltypes.Result{
+		Rows: [][]sqltypes.Value{{sqltypes.NewInt64(1)}, {sqltypes.NewInt64(2)}},
+	}
+	stats := xcontext.NewExecStats()
+	stats.Record(3)
+	stats.Record(4)
+
+	digests.Record("db1", "select * from t1 where id = 1", 2*time.Millisecond, qr, stats, nil)
+	digests.Record("db1", "SELECT * FROM t1 WHERE id = 2", 4*time.Millisecond, nil, nil, errors.New("mock.error"))
+	digests.Record("db2", "select * from t1 where id = 1", time.Millisecond, nil, nil, nil)
+
+	rows := digests.Rows()
+	assert.Equal(t, 2, len(rows))
+	row := rows[0]
+	assert.Equal(t, "db1", row.Schema)
+	assert.Equal(t, "select * from t1 where id = ?", row.DigestText)
+	assert.Equal(t, digestOf(row.DigestText), row.Digest)
+	assert.Equal(t, uint64(2), row.Count)
+	assert.Equal(t, uint64(1), row.Errors)
+	assert.Equal(t, uint64(2), row.RowsSent)
+	assert.Equal(t, uint64(7), row.RowsExamined)
+	assert.Equal(t, uint64(2), row.SumFanout)
+	assert.Equal(t, uint64(2), row.MaxFanout)
+	assert.Equal(t, 6*time.Millisecond, row.SumLatency)
+	assert.Equal(t, 4*time.Millisecond, row.MaxLatency)
+	assert.Equal(t, 3*time.Millisecond, row.AvgLatency())
+	assert.Equal(t, `{"1000": 0, "5000": 2, "10000": 2, "50000": 2, "100000": 2, "500000": 2, "1000000": 2, "5000000": 2, "10000000": 2, "inf": 2, "Count": 2, "Total": 6000}`, string(row.Latency))
+	assert.Equal(t, "db2", rows[1].Schema)
+
+	// Overflow.
+	{
+		digests.Record("db1", "delete from t1", time.Millisecond, nil, nil, nil)
+		digests.Record("db1", "update t1 set a = 1", time.Millisecond, nil, nil, nil)
+		rows := digests.Rows()
+		assert.Equal(t, 3, len(rows))
+		overflow := rows[1]
+		assert.Equal(t, "", overflow.Digest)
+		assert.Equal(t, "", overflow.DigestText)
+		assert.Equal(t, uint64(2), overflow.Count)
+	}
+
+	// Reset.
+	{
+		digests.Reset()
+		assert.Equal(t, 0, len(digests.Rows()))
+		assert.Equal(t, int64(0), digests.count)
+		digests.Record("db1", "delete from t1", time.Millisecond, nil, nil, nil)
+		assert.Equal(t, 1, len(digests.Rows()))
+		assert.Equal(t, int64(1), digests.count)
+	}
+
+	// Disabled.
+	{
+		digests.SetEnabled(false)
+		assert.False(t, digests.Enabled())
+		digests.Record("db1", "select 1", time.Millisecond, nil, nil, nil)
+		assert.Equal(t, 1, len(digests.Rows()))
+		digests.SetEnabled(true)
+		digests.Record("db1", "select 1", time.Millisecond, nil, nil, nil)
+		assert.Equal(t, 2, len(digests.Rows()))
+	}
+}
+
+func TestParseDigestStmt(t *testing.T) {
+	tests := []struct {
+		query  string
+		action digestAction
+	}{
+		{"show query digests", digestShow},
+		{"SHOW  QUERY\nDIGESTS", digestShow},
+		{"reset query digests", digestReset},
+	}
+	for _, test := range tests {
+		stmt := parseDigestStmt(test.query)
+		assert.NotNil(t, stmt, test.query)
+		assert.Equal(t, test.action, stmt.action)
+	}
+
+	for _, query := range []string{"show queryz", "show query digests 1", "reset query"} {
+		assert.Nil(t, parseDigestStmt(query), query)
+	}
+}
+
+func TestProxyQueryDigests(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("select .*", selectResult)
+	}
+
+	// create database and table.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		_, err = client.FetchAll("create database test", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("create table test.t1(id int, b int) partition by hash(id)", -1)
+		assert.Nil(t, err)
+		client.Quit()
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+	assert.Nil(t, err)
+	defer client.Quit()
+
+	// reset and select.
+	{
+		_, err = client.FetchAll("reset query digests", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("select * from t1 where id=1", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("select * from t1 where id=2", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("select * from t1", -1)
+		assert.Nil(t, err)
+	}
+
+	// show query digests.
+	{
+		qr, err := client.FetchAll("show query digests", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, 15, len(qr.Fields))
+		assert.Equal(t, 2, len(qr.Rows))
+
+		got := make(map[string]string)
+		for _, row := range qr.Rows {
+			got[row[2].String()] = fmt.Sprintf("count:%s sent:%s examined:%s fanout:%s", row[3].String(), row[5].String(), row[6].String(), row[8].String())
+		}
+		rows := len(selectResult.Rows)
+		assert.Equal(t, fmt.Sprintf("count:2 sent:%d examined:%d fanout:1", 2*rows, 2*rows), got["select * from t1 where id = ?"])
+		assert.Equal(t, fmt.Sprintf("count:1 sent:%d examined:%d fanout:30", 30*rows, 30*rows), got["select * from t1"])
+	}
+
+	// disabled.
+	{
+		proxy.SetStatementDigests(false)
+		assert.False(t, proxy.Config().Proxy.StatementDigests)
+		_, err = client.FetchAll("select b from t1", -1)
+		assert.Nil(t, err)
+		qr, err := client.FetchAll("show query digests", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(qr.Rows))
+		proxy.SetStatementDigests(true)
+	}
+}
+
+func TestProxyQueryDigestsPrivilege(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxyPrivilegeN(log, MockDefaultConfig())
+	defer cleanup()
+	address := proxy.Address()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+	assert.Nil(t, err)
+	defer client.Quit()
+	want := "Access denied; lacking super privilege for the operation (errno 1227) (sqlstate 42000)"
+	for _, query := range []string{"show query digests", "reset query digests"} {
+		_, err = client.FetchAll(query, -1)
+		assert.NotNil(t, err)
+		assert.Equal(t, want, err.Error())
+	}
+}
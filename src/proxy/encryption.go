@@ -0,0 +1,34 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"planner/builder"
+
+	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+)
+
+// encrypt used to encrypt the values of the encrypted columns in the statement, returns false if no column is encrypted.
+func (spanner *Spanner) encrypt(session *driver.Session, node sqlparser.Statement) (bool, error) {
+	encryption := spanner.plugins.PlugEncryption()
+	if !encryption.Enabled() {
+		return false, nil
+	}
+	if err := encryption.Encrypt(session.Schema(), node); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// decrypter returns the decrypter of the result, nil if the keyring is empty.
+func (spanner *Spanner) decrypter() builder.Decrypter {
+	encryption := spanner.plugins.PlugEncryption()
+	return encryption.Decrypter()
+}
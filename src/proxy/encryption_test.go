@@ -0,0 +1,140 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"testing"
+
+	"config"
+	"plugins/encryption"
+
+	"github.com/fortytw2/leaktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/driver"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestProxyEncryption(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+
+	dir, err := ioutil.TempDir(os.TempDir(), "radon_keyring_")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	keyringFile := path.Join(dir, "keyring.json")
+	key := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32)))
+	err = ioutil.WriteFile(keyringFile, []byte(`{"keys":[{"id":"k1","key":"`+key+`"}]}`), 0600)
+	assert.Nil(t, err)
+	keyring, err := encryption.LoadKeyring(keyringFile)
+	assert.Nil(t, err)
+	id, err := keyring.Encrypt("k1", []byte("1"), true)
+	assert.Nil(t, err)
+	phone, err := keyring.Encrypt("k1", []byte("13812345678"), true)
+	assert.Nil(t, err)
+
+	conf := MockDefaultConfig()
+	conf.Encryption = &config.EncryptionConfig{
+		Keyring: keyringFile,
+		Columns: []*config.EncryptedColumnConfig{
+			{Database: "db1", Table: "t1", Column: "id", Key: "k1", Mode: "deterministic"},
+			{Database: "db1", Table: "t1", Column: "phone", Key: "k1", Mode: "deterministic"},
+		},
+	}
+	fakedbs, proxy, cleanup := MockProxy1(log, conf)
+	defer cleanup()
+	address := proxy.Address()
+
+	// fakedbs, only the encrypted values reach the backends.
+	{
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		// The fakedb matches the patterns with the lower case query.
+		idPattern := strings.ToLower(regexp.QuoteMeta(string(id)))
+		phonePattern := strings.ToLower(regexp.QuoteMeta(string(phone)))
+		fakedbs.AddQueryPattern("insert into db1.t1_[0-9]+\\(id, phone\\) values \\('"+idPattern+"', '"+phonePattern+"'\\)", &sqltypes.Result{RowsAffected: 1})
+		fakedbs.AddQueryPattern("select .*id, phone from db1.t1_[0-9]+ as t1 where id = '"+idPattern+"'", &sqltypes.Result{
+			Fields: []*querypb.Field{
+				{Name: "id", Type: querypb.Type_VARCHAR},
+				{Name: "phone", Type: querypb.Type_VARCHAR},
+			},
+			Rows: [][]sqltypes.Value{
+				{
+					sqltypes.MakeTrusted(querypb.Type_VARCHAR, id),
+					sqltypes.MakeTrusted(querypb.Type_VARCHAR, phone),
+				},
+			},
+		})
+		fakedbs.AddQueryPattern("select note from db1.t2.*", &sqltypes.Result{
+			Fields: []*querypb.Field{
+				{Name: "note", Type: querypb.Type_VARCHAR},
+			},
+			Rows: [][]sqltypes.Value{
+				{
+					sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("RDNENC:plain")),
+				},
+			},
+		})
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+	queries := []string{
+		"create database db1",
+		"create table db1.t1(id varchar(64), phone varchar(128)) partition by hash(id)",
+		"create table db1.t2(id int, note varchar(128)) global",
+	}
+	for _, query := range queries {
+		_, err = client.FetchAll(query, -1)
+		assert.Nil(t, err, query)
+	}
+
+	// Insert.
+	{
+		qr, err := client.FetchAll("insert into db1.t1(id, phone) values('1', '13812345678')", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(1), qr.RowsAffected)
+	}
+
+	// Select, routed by the encrypted shard key.
+	{
+		qr, err := client.FetchAll("select id, phone from db1.t1 where id='1'", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, "[[1 13812345678]]", fmt.Sprintf("%v", qr.Rows))
+
+		qr, err = client.FetchAll("select /*+ streaming */ id, phone from db1.t1 where id='1'", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, "[[1 13812345678]]", fmt.Sprintf("%v", qr.Rows))
+
+		qr, err = client.FetchAll("explain select id, phone from db1.t1 where id='1'", -1)
+		assert.Nil(t, err)
+		assert.Contains(t, string(qr.Rows[0][0].Raw()), string(id))
+	}
+
+	// The plaintext column with the cipher prefix is returned as is.
+	{
+		qr, err := client.FetchAll("select note from db1.t2", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, "[[RDNENC:plain]]", fmt.Sprintf("%v", qr.Rows))
+	}
+
+	// Unsupported predicate.
+	{
+		_, err := client.FetchAll("select id from db1.t1 where phone like '138%'", -1)
+		assert.Equal(t, "unsupported: the.encrypted.column[phone].with.the.operator[like] (errno 1105) (sqlstate HY000)", err.Error())
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"strings"
 
 	"executor"
+	"executor/engine/operator"
 	"optimizer"
 	"planner"
 	"planner/builder"
@@ -24,20 +25,34 @@ import (
 	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
 )
 
+// buildPlanTree used to build the plans of the statement and record the plan types to the session stats.
+func (spanner *Spanner) buildPlanTree(session *driver.Session, database string, query string, node sqlparser.Statement) (*planner.PlanTree, error) {
+	plans, err := optimizer.NewSimpleOptimizer(spanner.log, database, query, node, spanner.router).WithMasker(spanner.masker(session)).WithDecrypter(spanner.decrypter()).WithSpan(spanner.sessions.span(session)).BuildPlanTree()
+	if err != nil {
+		return nil, err
+	}
+	execStats := spanner.sessions.execStats(session)
+	for _, plan := range plans.Plans() {
+		execStats.SetPlanType(string(plan.Type()))
+	}
+	return plans, nil
+}
+
 // ExecuteMultiStmtsInTxn used to execute multiple statements in the transaction.
 func (spanner *Spanner) ExecuteMultiStmtsInTxn(session *driver.Session, database string, query string, node sqlparser.Statement) (*sqltypes.Result, error) {
 	log := spanner.log
-	router := spanner.router
 	sessions := spanner.sessions
 	txSession := sessions.getTxnSession(session)
 
+	// The timeout of each statement in the txn, the max execution time works on the read-only SELECT.
+	txSession.transaction.SetTimeout(spanner.statementTimeout(session, node, spanner.conf.Proxy.QueryTimeout))
 	sessions.MultiStmtTxnBinding(session, nil, node, query)
 
-	plans, err := optimizer.NewSimpleOptimizer(log, database, query, node, router).BuildPlanTree()
+	plans, err := spanner.buildPlanTree(session, database, query, node)
 	if err != nil {
 		return nil, err
 	}
-	executors := executor.NewTree(log, plans, txSession.transaction)
+	executors := executor.NewTree(log, plans, txSession.transaction).WithSpan(spanner.sessions.span(session))
 	qr, err := executors.Execute()
 	if err != nil {
 		// need the user to rollback
@@ -52,7 +67,6 @@ func (spanner *Spanner) ExecuteMultiStmtsInTxn(session *driver.Session, database
 func (spanner *Spanner) ExecuteSingleStmtTxnTwoPC(session *driver.Session, database string, query string, node sqlparser.Statement) (*sqltypes.Result, error) {
 	log := spanner.log
 	conf := spanner.conf
-	router := spanner.router
 	scatter := spanner.scatter
 	sessions := spanner.sessions
 
@@ -65,10 +79,11 @@ func (spanner *Spanner) ExecuteSingleStmtTxnTwoPC(session *driver.Session, datab
 	defer txn.Finish()
 
 	// txn limits.
-	txn.SetTimeout(conf.Proxy.QueryTimeout)
+	txn.SetTimeout(spanner.statementTimeout(session, node, conf.Proxy.QueryTimeout))
 	txn.SetMaxResult(conf.Proxy.MaxResultSize)
 	txn.SetMaxJoinRows(conf.Proxy.MaxJoinRows)
 	txn.SetIsExecOnRep(isExecOnRep(conf.Proxy.LoadBalance, node))
+	spanner.setTxnLimits(session, txn)
 
 	// binding.
 	sessions.TxnBinding(session, txn, node, query)
@@ -81,12 +96,12 @@ func (spanner *Spanner) ExecuteSingleStmtTxnTwoPC(session *driver.Session, datab
 	}
 
 	// Transaction execute.
-	plans, err := optimizer.NewSimpleOptimizer(log, database, query, node, router).BuildPlanTree()
+	plans, err := spanner.buildPlanTree(session, database, query, node)
 	if err != nil {
 		return nil, err
 	}
 
-	executors := executor.NewTree(log, plans, txn)
+	executors := executor.NewTree(log, plans, txn).WithSpan(spanner.sessions.span(session))
 	qr, err := executors.Execute()
 	if err != nil {
 		if x := txn.RollbackPhaseOne(); x != nil {
@@ -129,7 +144,6 @@ func (spanner *Spanner) ExecuteDDL(session *driver.Session, database string, que
 func (spanner *Spanner) executeWithTimeout(session *driver.Session, database string, query string, node sqlparser.Statement, timeout int) (*sqltypes.Result, error) {
 	log := spanner.log
 	conf := spanner.conf
-	router := spanner.router
 	scatter := spanner.scatter
 	sessions := spanner.sessions
 
@@ -142,20 +156,21 @@ func (spanner *Spanner) executeWithTimeout(session *driver.Session, database str
 	defer txn.Finish()
 
 	// txn limits.
-	txn.SetTimeout(timeout)
+	txn.SetTimeout(spanner.statementTimeout(session, node, timeout))
 	txn.SetMaxResult(conf.Proxy.MaxResultSize)
 	txn.SetMaxJoinRows(conf.Proxy.MaxJoinRows)
 	txn.SetIsExecOnRep(isExecOnRep(conf.Proxy.LoadBalance, node))
+	spanner.setTxnLimits(session, txn)
 
 	// binding.
 	sessions.TxnBinding(session, txn, node, query)
 	defer sessions.TxnUnBinding(session)
 
-	plans, err := optimizer.NewSimpleOptimizer(log, database, query, node, router).BuildPlanTree()
+	plans, err := spanner.buildPlanTree(session, database, query, node)
 	if err != nil {
 		return nil, err
 	}
-	executors := executor.NewTree(log, plans, txn)
+	executors := executor.NewTree(log, plans, txn).WithSpan(spanner.sessions.span(session))
 	qr, err := executors.Execute()
 	if err != nil {
 		return nil, err
@@ -191,6 +206,8 @@ func (spanner *Spanner) ExecuteStreamFetch(session *driver.Session, database str
 	}
 
 	plan := planner.NewSelectPlan(log, database, query, selectNode, router)
+	plan.SetMasker(spanner.masker(session))
+	plan.Decrypter = spanner.decrypter()
 	if err := plan.Build(); err != nil {
 		return err
 	}
@@ -203,6 +220,26 @@ func (spanner *Spanner) ExecuteStreamFetch(session *driver.Session, database str
 	reqCtx.Querys = m.GetQuery()
 	reqCtx.RawQuery = plan.RawQuery
 	streamBufferSize := spanner.conf.Proxy.StreamBufferSize
+	// Decrypts and masks the columns of every stream chunk.
+	var operators []operator.Operator
+	if plan.Decrypt != nil {
+		operators = append(operators, operator.NewDecryptOperator(log, plan.Decrypter, plan.Decrypt))
+	}
+	if plan.Mask != nil {
+		operators = append(operators, operator.NewMaskOperator(log, plan.Mask))
+	}
+	if len(operators) > 0 {
+		next := callback
+		callback = func(qr *sqltypes.Result) error {
+			ctx := &xcontext.ResultContext{Results: qr}
+			for _, op := range operators {
+				if err := op.Execute(ctx); err != nil {
+					return err
+				}
+			}
+			return next(qr)
+		}
+	}
 	return txn.ExecuteStreamFetch(reqCtx, callback, streamBufferSize)
 }
 
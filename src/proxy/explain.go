@@ -9,15 +9,62 @@
 package proxy
 
 import (
+	"regexp"
+
 	"optimizer"
 
 	"github.com/xelabs/go-mysqlstack/driver"
 	"github.com/xelabs/go-mysqlstack/sqldb"
 	"github.com/xelabs/go-mysqlstack/sqlparser"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/common"
 	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
 	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
 )
 
+var (
+	explainAnalyzeRegexp = regexp.MustCompile(`(?is)^(explain|describe|desc)\s+analyze\s+(.+)$`)
+	explainBackendRegexp = regexp.MustCompile(`(?is)^(explain|describe|desc)\s+backend\s+(.+)$`)
+	explainDDLRegexp     = regexp.MustCompile(`(?is)^(explain|describe|desc)\s+((create|alter|drop|truncate)\s+.+)$`)
+)
+
+// parseExplain used to parse the query with the EXPLAIN extensions which the parser doesn't support:
+// 'EXPLAIN ANALYZE FORMAT = JSON', 'EXPLAIN BACKEND' and 'EXPLAIN ddl_statement'.
+// The 'ANALYZE' and 'BACKEND' are stripped, the Explain.Analyze is set for the 'ANALYZE',
+// the 'BACKEND' is checked by the handleExplain from the query.
+func parseExplain(query string) (sqlparser.Statement, error) {
+	if m := explainAnalyzeRegexp.FindStringSubmatch(query); m != nil {
+		node, err := sqlparser.Parse(m[1] + " " + m[2])
+		if err != nil {
+			return nil, err
+		}
+		if explain, ok := node.(*sqlparser.Explain); ok {
+			explain.Analyze = true
+			return explain, nil
+		}
+	}
+
+	if m := explainBackendRegexp.FindStringSubmatch(query); m != nil {
+		node, err := parseExplain(m[1] + " " + m[2])
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := node.(*sqlparser.Explain); ok {
+			return node, nil
+		}
+	}
+
+	if m := explainDDLRegexp.FindStringSubmatch(query); m != nil {
+		node, err := sqlparser.Parse(m[2])
+		if err != nil {
+			return nil, err
+		}
+		if ddl, ok := node.(*sqlparser.DDL); ok {
+			return &sqlparser.Explain{Statement: ddl}, nil
+		}
+	}
+	return sqlparser.Parse(query)
+}
+
 // handleExplain used to handle the EXPLAIN command.
 func (spanner *Spanner) handleExplain(session *driver.Session, query string, node sqlparser.Statement) (*sqltypes.Result, error) {
 	log := spanner.log
@@ -28,7 +75,8 @@ func (spanner *Spanner) handleExplain(session *driver.Session, query string, nod
 		{Name: "EXPLAIN", Type: querypb.Type_VARCHAR},
 	}
 
-	explainableStmt := node.(*sqlparser.Explain).Statement
+	explain := node.(*sqlparser.Explain)
+	explainableStmt := explain.Statement
 	privilegePlug := spanner.plugins.PlugPrivilege()
 	if err := privilegePlug.Check(database, session.User(), explainableStmt); err != nil {
 		return nil, err
@@ -46,24 +94,108 @@ func (spanner *Spanner) handleExplain(session *driver.Session, query string, nod
 			return nil, err
 		}
 	case *sqlparser.Update:
+	case *sqlparser.DDL:
+		// Only the DDL on the existing table is routed by the planner.
+		ddl := explainableStmt.(*sqlparser.DDL)
+		switch ddl.Action {
+		case sqlparser.DropTableStr:
+			if len(ddl.Tables) != 1 {
+				return nil, sqldb.NewSQLError(sqldb.ER_SYNTAX_ERROR, "explain only supports DROP TABLE with one table")
+			}
+			ddl.Table = ddl.Tables[0]
+		case sqlparser.CreateIndexStr, sqlparser.DropIndexStr,
+			sqlparser.AlterEngineStr, sqlparser.AlterCharsetStr,
+			sqlparser.AlterAddColumnStr, sqlparser.AlterDropColumnStr, sqlparser.AlterModifyColumnStr,
+			sqlparser.TruncateTableStr, sqlparser.RenameStr:
+		default:
+			return nil, sqldb.NewSQLError(sqldb.ER_SYNTAX_ERROR, "explain unsupported DDL: "+ddl.Action)
+		}
 	default:
-		return nil, sqldb.NewSQLError(sqldb.ER_SYNTAX_ERROR, "explain only supports SELECT/DELETE/INSERT/UNION")
+		return nil, sqldb.NewSQLError(sqldb.ER_SYNTAX_ERROR, "explain only supports SELECT/DELETE/INSERT/REPLACE/UPDATE/UNION/DDL")
+	}
+
+	// Explain backend runs the EXPLAIN on the backends, MySQL doesn't explain the DDL.
+	backend := explainBackendRegexp.MatchString(query)
+	if backend {
+		if _, ok := explainableStmt.(*sqlparser.DDL); ok {
+			return nil, sqldb.NewSQLError(sqldb.ER_SYNTAX_ERROR, "explain backend only supports SELECT/DELETE/INSERT/REPLACE/UPDATE/UNION")
+		}
+	}
+
+	// Explain analyze executes the statement, only the read-only statements are allowed.
+	if explain.Analyze {
+		switch explainableStmt.(type) {
+		case *sqlparser.Select, *sqlparser.Union:
+		default:
+			return nil, sqldb.NewSQLError(sqldb.ER_SYNTAX_ERROR, "explain analyze only supports SELECT/UNION")
+		}
+	}
+
+	// The rewrite rules fired on the statement, the statement is changed by the planner.
+	var rewritten string
+	rules := spanner.rewrite(session, explainableStmt)
+	if len(rules) > 0 {
+		rewritten = sqlparser.String(explainableStmt)
 	}
 
-	simOptimizer := optimizer.NewSimpleOptimizer(log, database, query, explainableStmt, router)
+	// The statement is routed by the encrypted values.
+	if _, err := spanner.encrypt(session, explainableStmt); err != nil {
+		return nil, err
+	}
+
+	simOptimizer := optimizer.NewSimpleOptimizer(log, database, query, explainableStmt, router).WithMasker(spanner.masker(session))
+	if explain.Analyze {
+		simOptimizer = simOptimizer.WithDecrypter(spanner.decrypter())
+	}
 	planTree, err := simOptimizer.BuildPlanTree()
 	if err != nil {
 		log.Error("proxy.explain.error:%+v", err)
 		return nil, err
 	}
 
-	if len(planTree.Plans()) > 0 {
+	if backend {
+		return spanner.explainBackend(explain.Type, planTree.Plans()[0])
+	}
+
+	if explain.Analyze {
+		profile, err := spanner.analyze(session, query, explainableStmt, planTree)
+		if err != nil {
+			log.Error("proxy.explain.analyze.error:%+v", err)
+			return nil, err
+		}
+		msg := analyzeTree(profile)
+		if explain.Type == sqlparser.ExplainTypeJSON {
+			if msg, err = analyzeJSON(profile); err != nil {
+				return nil, err
+			}
+		}
+		row := []sqltypes.Value{
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(msg)),
+		}
+		qr.Rows = append(qr.Rows, row)
+	} else if len(planTree.Plans()) > 0 {
 		msg := planTree.Plans()[0].JSON()
 		row := []sqltypes.Value{
 			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(msg)),
 		}
 		qr.Rows = append(qr.Rows, row)
-		return qr, nil
+	}
+	if len(rules) > 0 {
+		type rewrite struct {
+			Rules []string
+			Query string
+		}
+		exp := &struct{ Rewrite *rewrite }{
+			Rewrite: &rewrite{Rules: rules, Query: rewritten},
+		}
+		msg, err := common.ToJSONString(exp, false, "", "\t")
+		if err != nil {
+			return nil, err
+		}
+		row := []sqltypes.Value{
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(msg)),
+		}
+		qr.Rows = append(qr.Rows, row)
 	}
 	return qr, nil
 }
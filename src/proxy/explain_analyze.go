@@ -0,0 +1,169 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"backend"
+	"executor"
+	"planner"
+	"xcontext"
+
+	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/common"
+)
+
+// analyze used to execute the plan tree with the profile, the result is discarded.
+// In the multiple-statement transaction, the plan runs on the transaction of the session and the binding is kept.
+func (spanner *Spanner) analyze(session *driver.Session, query string, node sqlparser.Statement, planTree *planner.PlanTree) (*xcontext.Profile, error) {
+	log := spanner.log
+	conf := spanner.conf
+	scatter := spanner.scatter
+	sessions := spanner.sessions
+
+	if txSession := sessions.getTxnSession(session); txSession != nil && txSession.transaction != nil {
+		sessions.MultiStmtTxnBinding(session, nil, node, query)
+		defer sessions.MultiStmtTxnUnBinding(session, false)
+		return spanner.profile(node, planTree, txSession.transaction)
+	}
+
+	txn, err := scatter.CreateTransaction()
+	if err != nil {
+		log.Error("spanner.txn.create.error:[%v]", err)
+		return nil, err
+	}
+	defer txn.Finish()
+
+	txn.SetTimeout(conf.Proxy.QueryTimeout)
+	txn.SetMaxResult(conf.Proxy.MaxResultSize)
+	txn.SetMaxJoinRows(conf.Proxy.MaxJoinRows)
+	txn.SetIsExecOnRep(isExecOnRep(conf.Proxy.LoadBalance, node))
+	spanner.setTxnLimits(session, txn)
+
+	sessions.TxnBinding(session, txn, node, query)
+	defer sessions.TxnUnBinding(session)
+	return spanner.profile(node, planTree, txn)
+}
+
+// profile used to execute the plan tree on the transaction and returns the profile.
+func (spanner *Spanner) profile(node sqlparser.Statement, planTree *planner.PlanTree, txn backend.Transaction) (*xcontext.Profile, error) {
+	name := "Select"
+	if _, ok := node.(*sqlparser.Union); ok {
+		name = "Union"
+	}
+	profile := xcontext.NewProfile(name)
+	start := time.Now()
+	qr, err := executor.NewTree(spanner.log, planTree, txn).WithProfile(profile).Execute()
+	if err != nil {
+		return nil, err
+	}
+	profile.Finish(start, qr)
+	return profile, nil
+}
+
+// analyzeQuery tuple.
+type analyzeQuery struct {
+	Backend string
+	Range   string
+	Query   string
+	Rows    uint64
+	Bytes   uint64
+	Latency string
+	Error   string `json:",omitempty"`
+}
+
+// analyzeOperator tuple.
+type analyzeOperator struct {
+	Name  string
+	Loops int
+	Rows  uint64
+	Time  string
+}
+
+// analyzeNode tuple.
+type analyzeNode struct {
+	Name      string
+	Loops     int
+	Rows      uint64
+	Time      string
+	Operators []*analyzeOperator `json:",omitempty"`
+	Querys    []*analyzeQuery    `json:",omitempty"`
+	Children  []*analyzeNode     `json:",omitempty"`
+}
+
+func newAnalyzeNode(profile *xcontext.Profile) *analyzeNode {
+	node := &analyzeNode{
+		Name:  profile.Name,
+		Loops: profile.Loops,
+		Rows:  profile.Rows,
+		Time:  profile.Time.String(),
+	}
+	for _, op := range profile.Operators {
+		node.Operators = append(node.Operators, &analyzeOperator{
+			Name:  op.Name,
+			Loops: op.Loops,
+			Rows:  op.Rows,
+			Time:  op.Time.String(),
+		})
+	}
+	for _, q := range profile.Querys {
+		node.Querys = append(node.Querys, &analyzeQuery{
+			Backend: q.Backend,
+			Range:   q.Range,
+			Query:   q.Query,
+			Rows:    q.Rows,
+			Bytes:   q.Bytes,
+			Latency: q.Latency.String(),
+			Error:   q.Error,
+		})
+	}
+	for _, child := range profile.Children {
+		node.Children = append(node.Children, newAnalyzeNode(child))
+	}
+	return node
+}
+
+// analyzeJSON returns the profile in JSON format.
+func analyzeJSON(profile *xcontext.Profile) (string, error) {
+	exp := &struct{ Analyze *analyzeNode }{
+		Analyze: newAnalyzeNode(profile),
+	}
+	return common.ToJSONString(exp, false, "", "\t")
+}
+
+// analyzeTree returns the profile in TREE format, one line per node, operator and backend query.
+func analyzeTree(profile *xcontext.Profile) string {
+	buf := bytes.NewBufferString("")
+	writeAnalyzeTree(buf, profile, 0)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func writeAnalyzeTree(buf *bytes.Buffer, profile *xcontext.Profile, depth int) {
+	indent := strings.Repeat("    ", depth)
+	fmt.Fprintf(buf, "%s-> %s (time=%v rows=%d loops=%d)\n", indent, profile.Name, profile.Time, profile.Rows, profile.Loops)
+	indent += "    "
+	for _, op := range profile.Operators {
+		fmt.Fprintf(buf, "%s-> %s (time=%v rows=%d loops=%d)\n", indent, op.Name, op.Time, op.Rows, op.Loops)
+	}
+	for _, child := range profile.Children {
+		writeAnalyzeTree(buf, child, depth+1)
+	}
+	for _, q := range profile.Querys {
+		fmt.Fprintf(buf, "%s-> Backend %s range%s (latency=%v rows=%d bytes=%d)", indent, q.Backend, q.Range, q.Latency, q.Rows, q.Bytes)
+		if q.Error != "" {
+			fmt.Fprintf(buf, " error[%s]", q.Error)
+		}
+		fmt.Fprintf(buf, ": %s\n", q.Query)
+	}
+}
@@ -0,0 +1,165 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"xcontext"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/driver"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestAnalyzeTree(t *testing.T) {
+	profile := xcontext.NewProfile("Select")
+	profile.Loops, profile.Rows, profile.Time = 1, 2, 3*time.Millisecond
+	profile.Operators = []*xcontext.OperatorProfile{
+		{Name: "Mask", Loops: 1, Rows: 2, Time: time.Microsecond},
+	}
+	merge := profile.NewChild("Merge")
+	merge.Loops, merge.Rows, merge.Time = 1, 2, 2*time.Millisecond
+	merge.Operators = []*xcontext.OperatorProfile{
+		{Name: "Limit", Loops: 1, Rows: 2, Time: 2 * time.Microsecond},
+	}
+	merge.Querys = []*xcontext.QueryProfile{
+		{Backend: "backend0", Range: "[0-128)", Query: "select a from t_0000", Rows: 1, Bytes: 1, Latency: time.Millisecond},
+		{Backend: "backend1", Range: "[128-256)", Query: "select a from t_0001", Error: "mock.error", Latency: time.Millisecond},
+	}
+
+	want := `-> Select (time=3ms rows=2 loops=1)
+    -> Mask (time=1µs rows=2 loops=1)
+    -> Merge (time=2ms rows=2 loops=1)
+        -> Limit (time=2µs rows=2 loops=1)
+        -> Backend backend0 range[0-128) (latency=1ms rows=1 bytes=1): select a from t_0000
+        -> Backend backend1 range[128-256) (latency=1ms rows=0 bytes=0) error[mock.error]: select a from t_0001`
+	assert.Equal(t, want, analyzeTree(profile))
+
+	got, err := analyzeJSON(profile)
+	assert.Nil(t, err)
+	assert.True(t, strings.Contains(got, `"Name": "Merge"`))
+	assert.True(t, strings.Contains(got, `"Latency": "1ms"`))
+	assert.True(t, strings.Contains(got, `"Error": "mock.error"`))
+}
+
+func TestProxyExplainAnalyze(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	result := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "id", Type: querypb.Type_INT32},
+			{Name: "b", Type: querypb.Type_INT32},
+		},
+		Rows: [][]sqltypes.Value{
+			{sqltypes.MakeTrusted(querypb.Type_INT32, []byte("1")), sqltypes.MakeTrusted(querypb.Type_INT32, []byte("11"))},
+			{sqltypes.MakeTrusted(querypb.Type_INT32, []byte("2")), sqltypes.MakeTrusted(querypb.Type_INT32, []byte("22"))},
+		},
+	}
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("select .*", result)
+	}
+
+	// create database and table.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		_, err = client.FetchAll("create database test", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("create table test.t1(id int, b int) partition by hash(id)", -1)
+		assert.Nil(t, err)
+		client.Quit()
+	}
+
+	// explain analyze.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+		assert.Nil(t, err)
+		defer client.Quit()
+		qr, err := client.FetchAll("explain analyze select id, b from t1 where id=1", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(qr.Rows))
+		got := qr.Rows[0][0].String()
+		assert.True(t, strings.HasPrefix(got, "-> Select ("), got)
+		assert.True(t, strings.Contains(got, "    -> Merge ("), got)
+		assert.True(t, strings.Contains(got, "        -> Backend backend2 range[2278-2457) ("), got)
+		assert.True(t, strings.Contains(got, "rows=2 bytes=6): select id, b from test.t1_0017 as t1 where id = 1"), got)
+	}
+
+	// explain analyze format=json.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+		assert.Nil(t, err)
+		defer client.Quit()
+		qr, err := client.FetchAll("explain analyze format=json select id, b from t1 where id>1 order by id limit 1", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(qr.Rows))
+		got := qr.Rows[0][0].String()
+		assert.True(t, strings.HasPrefix(got, "{\n\t\"Analyze\": {"), got)
+		assert.True(t, strings.Contains(got, `"Name": "OrderBy"`), got)
+		assert.True(t, strings.Contains(got, `"Name": "Limit"`), got)
+		assert.True(t, strings.Contains(got, `"Range": "[128-256)"`), got)
+	}
+
+	// explain analyze unsupported.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+		assert.Nil(t, err)
+		defer client.Quit()
+		_, err = client.FetchAll("explain analyze delete from t1 where id=1", -1)
+		want := "You have an error in your SQL syntax; check the manual that corresponds to your MySQL server version for the right syntax to use, explain analyze only supports SELECT/UNION (errno 1149) (sqlstate 42000)"
+		assert.Equal(t, want, err.Error())
+	}
+
+	// explain analyze in the multiple-statement transaction, the transaction is kept.
+	{
+		proxy.SetTwoPC(true)
+		fakedbs.AddQueryPattern("xa .*", &sqltypes.Result{})
+		client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+		assert.Nil(t, err)
+		defer client.Quit()
+		_, err = client.FetchAll("begin", -1)
+		assert.Nil(t, err)
+		id := client.ConnectionID()
+		txnID := proxy.Sessions().txnID(id)
+		assert.NotEqual(t, uint64(0), txnID)
+
+		qr, err := client.FetchAll("explain analyze select id, b from t1 where id=1", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(qr.Rows))
+		assert.Equal(t, txnID, proxy.Sessions().txnID(id))
+
+		_, err = client.FetchAll("commit", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(0), proxy.Sessions().txnID(id))
+		proxy.SetTwoPC(false)
+	}
+
+	// explain analyze execute error.
+	{
+		fakedbs.AddQueryError("select id, b from test.t1_0017 as t1 where id = 1", errors.New("mock.select.error"))
+		client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+		assert.Nil(t, err)
+		defer client.Quit()
+		_, err = client.FetchAll("explain analyze select id, b from t1 where id=1", -1)
+		assert.NotNil(t, err)
+	}
+}
@@ -0,0 +1,131 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"planner"
+	"planner/builder"
+	"xcontext"
+
+	"github.com/pkg/errors"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// explainBackend used to run the MySQL EXPLAIN of each backend query of the plan on its backend,
+// the results are merged with the backend and range columns ahead.
+func (spanner *Spanner) explainBackend(typ sqlparser.ExplainType, plan planner.Plan) (*sqltypes.Result, error) {
+	log := spanner.log
+	querys, err := backendQuerys(plan)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := "explain "
+	switch typ {
+	case sqlparser.ExplainTypeJSON:
+		prefix = "explain format=json "
+	case sqlparser.ExplainTypeTree:
+		prefix = "explain format=tree "
+	case sqlparser.ExplainTypeTraditional:
+		prefix = "explain format=traditional "
+	}
+
+	qr := &sqltypes.Result{}
+	for _, q := range querys {
+		r, err := spanner.ExecuteOnThisBackend(q.Backend, prefix+q.Query)
+		if err != nil {
+			log.Error("proxy.explain.backend[%s].query[%s].error:%+v", q.Backend, q.Query, err)
+			return nil, err
+		}
+		if qr.Fields == nil {
+			qr.Fields = append([]*querypb.Field{
+				{Name: "Backend", Type: querypb.Type_VARCHAR},
+				{Name: "Range", Type: querypb.Type_VARCHAR},
+			}, r.Fields...)
+		}
+		if len(r.Fields)+2 != len(qr.Fields) {
+			return nil, errors.Errorf("proxy.explain.backend[%s].fields.mismatch", q.Backend)
+		}
+		for _, row := range r.Rows {
+			merged := []sqltypes.Value{
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(q.Backend)),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(q.Range)),
+			}
+			qr.Rows = append(qr.Rows, append(merged, row...))
+		}
+	}
+	qr.RowsAffected = uint64(len(qr.Rows))
+	return qr, nil
+}
+
+// backendQuerys returns the query tuples of the plan which are sent to the backends.
+func backendQuerys(plan planner.Plan) ([]xcontext.QueryTuple, error) {
+	switch plan := plan.(type) {
+	case *planner.SelectPlan:
+		return nodeQuerys(plan.Root, make(map[string]*querypb.BindVariable))
+	case *planner.UnionPlan:
+		return nodeQuerys(plan.Root, make(map[string]*querypb.BindVariable))
+	case *planner.InsertPlan:
+		return plan.Querys, nil
+	case *planner.DeletePlan:
+		return plan.Querys, nil
+	case *planner.UpdatePlan:
+		return plan.Querys, nil
+	}
+	return nil, errors.Errorf("unsupported: explain.backend.plan.type[%v]", plan.Type())
+}
+
+// nodeQuerys returns the query tuples of the plan node, the join vars of the nest loop are bound to NULL.
+func nodeQuerys(node builder.PlanNode, bindVars map[string]*querypb.BindVariable) ([]xcontext.QueryTuple, error) {
+	var querys []xcontext.QueryTuple
+	switch node := node.(type) {
+	case *builder.MergeNode:
+		// The query without table isn't sent to the sharded tables.
+		if node.ReqMode != xcontext.ReqNormal {
+			return nil, nil
+		}
+		for i, pq := range node.ParsedQuerys {
+			query, err := pq.GenerateQuery(bindVars, nil)
+			if err != nil {
+				return nil, err
+			}
+			tuple := node.Querys[i]
+			tuple.Query = query
+			querys = append(querys, tuple)
+		}
+	case *builder.JoinNode:
+		left, err := nodeQuerys(node.Left, bindVars)
+		if err != nil {
+			return nil, err
+		}
+		if node.Strategy == builder.NestLoop {
+			for k := range node.Vars {
+				bindVars[k] = sqltypes.NullBindVariable
+			}
+		}
+		right, err := nodeQuerys(node.Right, bindVars)
+		if err != nil {
+			return nil, err
+		}
+		querys = append(left, right...)
+	case *builder.UnionNode:
+		left, err := nodeQuerys(node.Left, bindVars)
+		if err != nil {
+			return nil, err
+		}
+		right, err := nodeQuerys(node.Right, bindVars)
+		if err != nil {
+			return nil, err
+		}
+		querys = append(left, right...)
+	}
+	return querys, nil
+}
@@ -0,0 +1,163 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"fmt"
+	"testing"
+
+	"planner"
+	"router"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestBackendQuerys(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableAConfig(), router.MockTableBConfig())
+	assert.Nil(t, err)
+
+	// Nest loop join, the join vars are bound to NULL.
+	{
+		query := "select A.id, B.name from A join B on A.name+B.name='golang' where A.id=5 and B.id=1"
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		plan := planner.NewSelectPlan(log, database, query, node.(*sqlparser.Select), route)
+		err = plan.Build()
+		assert.Nil(t, err)
+
+		querys, err := backendQuerys(plan)
+		assert.Nil(t, err)
+		got := fmt.Sprintf("%v", querys)
+		want := "[{select A.id, A.name from sbtest.A8 as A where A.id = 5 backend8 [8-4096)} {select B.name from sbtest.B1 as B where B.id = 1 and null + B.name = 'golang' backend2 [512-4096)}]"
+		assert.Equal(t, want, got)
+	}
+
+	// Union.
+	{
+		query := "select id from A where id=5 union select id from B where id=1"
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		plan := planner.NewUnionPlan(log, database, query, node.(*sqlparser.Union), route)
+		err = plan.Build()
+		assert.Nil(t, err)
+
+		querys, err := backendQuerys(plan)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(querys))
+	}
+
+	// Others.
+	{
+		query := "checksum table A"
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		plan := planner.NewOthersPlan(log, database, query, node, route)
+		_, err = backendQuerys(plan)
+		assert.NotNil(t, err)
+	}
+}
+
+func TestProxyExplainBackend(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	result := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "id", Type: querypb.Type_INT64},
+			{Name: "select_type", Type: querypb.Type_VARCHAR},
+			{Name: "table", Type: querypb.Type_VARCHAR},
+			{Name: "key", Type: querypb.Type_VARCHAR},
+		},
+		Rows: [][]sqltypes.Value{
+			{
+				sqltypes.MakeTrusted(querypb.Type_INT64, []byte("1")),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("SIMPLE")),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("t1")),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("PRIMARY")),
+			},
+		},
+	}
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("explain .*", result)
+	}
+
+	// create database and table.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		_, err = client.FetchAll("create database test", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("create table test.t1(id int, b int) partition by hash(id)", -1)
+		assert.Nil(t, err)
+		client.Quit()
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+	assert.Nil(t, err)
+	defer client.Quit()
+
+	// select.
+	{
+		qr, err := client.FetchAll("explain backend select * from t1 where id=1", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, 6, len(qr.Fields))
+		assert.Equal(t, "Backend", qr.Fields[0].Name)
+		assert.Equal(t, "Range", qr.Fields[1].Name)
+		assert.Equal(t, "key", qr.Fields[5].Name)
+		want := "[[backend2 [2278-2457) 1 SIMPLE t1 PRIMARY]]"
+		assert.Equal(t, want, fmt.Sprintf("%v", qr.Rows))
+	}
+
+	// scatter update with json format.
+	{
+		qr, err := client.FetchAll("explain backend format=json update t1 set b=1 where b>1", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, 30, len(qr.Rows))
+	}
+
+	// replace.
+	{
+		qr, err := client.FetchAll("explain backend replace into t1(id, b) values(1, 2)", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(qr.Rows))
+	}
+
+	// ddl is unsupported.
+	{
+		_, err := client.FetchAll("explain backend alter table t1 engine=tokudb", -1)
+		want := "You have an error in your SQL syntax; check the manual that corresponds to your MySQL server version for the right syntax to use, explain backend only supports SELECT/DELETE/INSERT/REPLACE/UPDATE/UNION (errno 1149) (sqlstate 42000)"
+		assert.Equal(t, want, err.Error())
+	}
+
+	// backend error.
+	{
+		fakedbs.AddQueryError("explain delete from test.t1_0017 where test.t1_0017.id = 1", errors.New("mock.explain.error"))
+		_, err := client.FetchAll("explain backend delete from t1 where id=1", -1)
+		assert.NotNil(t, err)
+	}
+}
@@ -9,14 +9,63 @@
 package proxy
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
 	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
 	"github.com/xelabs/go-mysqlstack/xlog"
 )
 
+func TestParseExplain(t *testing.T) {
+	tests := []struct {
+		query   string
+		analyze bool
+		typ     sqlparser.ExplainType
+	}{
+		{"explain select 1", false, sqlparser.ExplainTypeEmpty},
+		{"explain analyze select 1", true, sqlparser.ExplainTypeEmpty},
+		{"EXPLAIN ANALYZE\tselect 1", true, sqlparser.ExplainTypeEmpty},
+		{"desc analyze select 1", true, sqlparser.ExplainTypeEmpty},
+		{"explain analyze format=json select 1", true, sqlparser.ExplainTypeJSON},
+		{"explain analyze format = tree select 1", true, sqlparser.ExplainTypeTree},
+		{"explain backend select 1", false, sqlparser.ExplainTypeEmpty},
+		{"explain backend format=json replace into t1 values(1)", false, sqlparser.ExplainTypeJSON},
+		{"explain alter table t1 add column (c int)", false, sqlparser.ExplainTypeEmpty},
+		{"desc truncate table t1", false, sqlparser.ExplainTypeEmpty},
+	}
+	for _, test := range tests {
+		node, err := parseExplain(test.query)
+		assert.Nil(t, err, test.query)
+		explain := node.(*sqlparser.Explain)
+		assert.Equal(t, test.analyze, explain.Analyze, test.query)
+		assert.Equal(t, test.typ, explain.Type, test.query)
+	}
+
+	// Not explain.
+	{
+		node, err := parseExplain("select 1")
+		assert.Nil(t, err)
+		_, ok := node.(*sqlparser.Select)
+		assert.True(t, ok)
+	}
+
+	// Errors.
+	{
+		querys := []string{
+			"explain analyze selectx 1",
+			"explain backend selectx 1",
+			"explain create tablex t1",
+		}
+		for _, query := range querys {
+			_, err := parseExplain(query)
+			assert.NotNil(t, err, query)
+		}
+	}
+}
+
 func TestProxyExplain(t *testing.T) {
 	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
 	fakedbs, proxy, cleanup := MockProxy(log)
@@ -282,18 +331,122 @@ func TestProxyExplainUnsupported(t *testing.T) {
 		fakedbs.AddQueryPattern("create table .*", &sqltypes.Result{})
 	}
 
-	// parse query error.
+	// create table is unsupported.
 	{
 		client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
 		assert.Nil(t, err)
 		query := "explain create table t1(a int)"
 		_, err = client.FetchAll(query, -1)
-		want := "You have an error in your SQL syntax; check the manual that corresponds to your MySQL server version for the right syntax to use, syntax error at position 15 near 'create' (errno 1149) (sqlstate 42000)"
+		want := "You have an error in your SQL syntax; check the manual that corresponds to your MySQL server version for the right syntax to use, explain unsupported DDL: create table (errno 1149) (sqlstate 42000)"
+		got := err.Error()
+		assert.Equal(t, want, got)
+	}
+
+	// drop multiple tables is unsupported.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+		assert.Nil(t, err)
+		query := "explain drop table t1, t2"
+		_, err = client.FetchAll(query, -1)
+		want := "You have an error in your SQL syntax; check the manual that corresponds to your MySQL server version for the right syntax to use, explain only supports DROP TABLE with one table (errno 1149) (sqlstate 42000)"
 		got := err.Error()
 		assert.Equal(t, want, got)
 	}
 }
 
+func TestProxyExplainUpdateReplaceDDL(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+	}
+
+	// create database and table.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		_, err = client.FetchAll("create database test", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("create table test.t1(id int, b int) partition by hash(id)", -1)
+		assert.Nil(t, err)
+		client.Quit()
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+	assert.Nil(t, err)
+	defer client.Quit()
+
+	// update.
+	{
+		qr, err := client.FetchAll("explain update t1 set b=2 where id=1", -1)
+		assert.Nil(t, err)
+		want := `{
+	"RawQuery": "explain update t1 set b=2 where id=1",
+	"Partitions": [
+		{
+			"Query": "update test.t1_0017 set b = 2 where id = 1",
+			"Backend": "backend2",
+			"Range": "[2278-2457)"
+		}
+	]
+}`
+		assert.Equal(t, want, string(qr.Rows[0][0].Raw()))
+	}
+
+	// replace.
+	{
+		qr, err := client.FetchAll("explain replace into t1(id, b) values(1, 2)", -1)
+		assert.Nil(t, err)
+		want := `{
+	"RawQuery": "explain replace into t1(id, b) values(1, 2)",
+	"Partitions": [
+		{
+			"Query": "replace into test.t1_0017(id, b) values (1, 2)",
+			"Backend": "backend2",
+			"Range": "[2278-2457)"
+		}
+	]
+}`
+		assert.Equal(t, want, string(qr.Rows[0][0].Raw()))
+	}
+
+	// ddl.
+	{
+		querys := []string{
+			"explain alter table t1 add column (c int)",
+			"explain create index idx_b on t1(b)",
+			"explain truncate table t1",
+			"explain drop table t1",
+			"explain alter table t1 rename to t2",
+		}
+		wants := []string{
+			"alter table test.t1_0000 add column (\\n\\t`c` int\\n)",
+			"create index idx_b on test.t1_0000(`b`)",
+			"truncate table test.t1_0000",
+			"drop table test.t1_0000",
+			"rename table test.t1_0000 to test.t2_0000",
+		}
+		for i, query := range querys {
+			qr, err := client.FetchAll(query, -1)
+			assert.Nil(t, err, query)
+			got := string(qr.Rows[0][0].Raw())
+			assert.Equal(t, 30, strings.Count(got, `"Query":`), query)
+			assert.True(t, strings.Contains(got, `"Query": "`+wants[i]+`"`), got)
+		}
+	}
+
+	// ddl on the table not exists.
+	{
+		_, err := client.FetchAll("explain alter table t3 add column (c int)", -1)
+		assert.NotNil(t, err)
+	}
+}
+
 func TestProxyExplainPrivilege(t *testing.T) {
 	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
 	fakedbs, proxy, cleanup := MockProxyPrivilegeN(log, MockDefaultConfig())
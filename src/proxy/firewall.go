@@ -0,0 +1,36 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"time"
+
+	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+)
+
+// firewallCheck used to check the statement by the firewall, the denied statement is recorded in the audit log.
+func (spanner *Spanner) firewallCheck(session *driver.Session, query string, node sqlparser.Statement) error {
+	firewall := spanner.plugins.PlugFirewall()
+	hit, err := firewall.Check(session.Schema(), session.User(), node)
+	if hit != nil {
+		spanner.audit.LogFirewallEvent(session.User(), session.Addr(), session.ID(), query, hit.Rule, time.Now().UTC())
+	}
+	return err
+}
+
+// firewallCheckQuery used to check the statement which the parser doesn't support by the firewall.
+func (spanner *Spanner) firewallCheckQuery(session *driver.Session, typ string, query string) error {
+	firewall := spanner.plugins.PlugFirewall()
+	hit, err := firewall.CheckQuery(session.Schema(), session.User(), typ, query)
+	if hit != nil {
+		spanner.audit.LogFirewallEvent(session.User(), session.Addr(), session.ID(), query, hit.Rule, time.Now().UTC())
+	}
+	return err
+}
@@ -0,0 +1,85 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"testing"
+
+	"plugins/firewall"
+
+	"github.com/fortytw2/leaktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestProxyFirewall(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("select .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("delete .*", &sqltypes.Result{})
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+	queries := []string{
+		"create database db1",
+		"create table db1.t1(id int, b int) partition by hash(id)",
+	}
+	for _, query := range queries {
+		_, err = client.FetchAll(query, -1)
+		assert.Nil(t, err, query)
+	}
+
+	// Learning.
+	fw := proxy.Plugins().PlugFirewall()
+	{
+		conf := &firewall.Config{
+			Mode:  firewall.ModeLearning,
+			Rules: []*firewall.Rule{{Name: "scatter-delete", Statement: "DELETE", Scatter: true}},
+		}
+		err := fw.SetConfig(conf)
+		assert.Nil(t, err)
+
+		_, err = client.FetchAll("delete from db1.t1 where b=1", -1)
+		assert.Equal(t, "Statement was blocked by Firewall, rule: scatter-delete (errno 1045) (sqlstate 28000)", err.Error())
+
+		queries := []string{
+			"delete from db1.t1 where id=1",
+			"select * from db1.t1 where id=1",
+		}
+		for _, query := range queries {
+			_, err = client.FetchAll(query, -1)
+			assert.Nil(t, err, query)
+		}
+		assert.Equal(t, []string{"delete from db1.t1 where id = ?", "select * from db1.t1 where id = ?"}, fw.Config().Fingerprints["mock"])
+	}
+
+	// Enforcing.
+	{
+		conf := fw.Config()
+		conf.Mode = firewall.ModeEnforcing
+		err := fw.SetConfig(conf)
+		assert.Nil(t, err)
+
+		_, err = client.FetchAll("select * from db1.t1 where id=2", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("select b from db1.t1 where id=2", -1)
+		assert.Equal(t, "Statement was blocked by Firewall, rule: allowlist (errno 1045) (sqlstate 28000)", err.Error())
+	}
+}
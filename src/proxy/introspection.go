@@ -0,0 +1,130 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"fmt"
+	"time"
+
+	"backend"
+
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// The introspection virtual tables, the rows are the real-time snapshots:
+// radon.sessions        -- the client sessions, TXN_ID is the transaction of the session
+// radon.transactions    -- the live transactions
+// radon.backend_queries -- the running queries on the backends, TXN_ID is the transaction which holds the backend connection
+// The AGE columns are in second.
+
+func uint64Value(v uint64) sqltypes.Value {
+	return sqltypes.MakeTrusted(querypb.Type_UINT64, []byte(fmt.Sprintf("%d", v)))
+}
+
+func varcharValue(v string) sqltypes.Value {
+	return sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(v))
+}
+
+func datetimeValue(t time.Time) sqltypes.Value {
+	return sqltypes.MakeTrusted(querypb.Type_DATETIME, []byte(t.Format("2006-01-02 15:04:05")))
+}
+
+func ageValue(d time.Duration) sqltypes.Value {
+	return sqltypes.MakeTrusted(querypb.Type_FLOAT64, []byte(fmt.Sprintf("%.3f", d.Seconds())))
+}
+
+// txnIDValue returns NULL if the id is 0, which means no transaction.
+func txnIDValue(id uint64) sqltypes.Value {
+	if id == 0 {
+		return sqltypes.NULL
+	}
+	return uint64Value(id)
+}
+
+// sessionsResult returns the sessions as the rows of the 'radon.sessions' table.
+func sessionsResult(infos []SessionInfo) *sqltypes.Result {
+	qr := &sqltypes.Result{}
+	qr.Fields = []*querypb.Field{
+		{Name: "ID", Type: querypb.Type_UINT64},
+		{Name: "USER", Type: querypb.Type_VARCHAR},
+		{Name: "HOST", Type: querypb.Type_VARCHAR},
+		{Name: "DB", Type: querypb.Type_VARCHAR},
+		{Name: "COMMAND", Type: querypb.Type_VARCHAR},
+		{Name: "TIME", Type: querypb.Type_UINT64},
+		{Name: "STATE", Type: querypb.Type_VARCHAR},
+		{Name: "INFO", Type: querypb.Type_VARCHAR},
+		{Name: "TXN_ID", Type: querypb.Type_UINT64},
+	}
+	for _, info := range infos {
+		qr.Rows = append(qr.Rows, []sqltypes.Value{
+			uint64Value(uint64(info.ID)),
+			varcharValue(info.User),
+			varcharValue(info.Host),
+			varcharValue(info.DB),
+			varcharValue(info.Command),
+			uint64Value(uint64(info.Time)),
+			varcharValue(info.State),
+			varcharValue(info.Info),
+			txnIDValue(info.TxnID),
+		})
+	}
+	qr.RowsAffected = uint64(len(qr.Rows))
+	return qr
+}
+
+// transactionsResult returns the transactions as the rows of the 'radon.transactions' table.
+func transactionsResult(rows []backend.TxnDetailzRow) *sqltypes.Result {
+	qr := &sqltypes.Result{}
+	qr.Fields = []*querypb.Field{
+		{Name: "TXN_ID", Type: querypb.Type_UINT64},
+		{Name: "XA_ID", Type: querypb.Type_VARCHAR},
+		{Name: "STATE", Type: querypb.Type_VARCHAR},
+		{Name: "XA_STATE", Type: querypb.Type_VARCHAR},
+		{Name: "START", Type: querypb.Type_DATETIME},
+		{Name: "AGE", Type: querypb.Type_FLOAT64},
+	}
+	for _, r := range rows {
+		qr.Rows = append(qr.Rows, []sqltypes.Value{
+			uint64Value(r.TxnID),
+			varcharValue(r.XAID),
+			varcharValue(r.State),
+			varcharValue(r.XaState),
+			datetimeValue(r.Start),
+			ageValue(r.Duration),
+		})
+	}
+	qr.RowsAffected = uint64(len(qr.Rows))
+	return qr
+}
+
+// backendQueriesResult returns the backend queries as the rows of the 'radon.backend_queries' table.
+func backendQueriesResult(rows []backend.QueryDetailzRow) *sqltypes.Result {
+	qr := &sqltypes.Result{}
+	qr.Fields = []*querypb.Field{
+		{Name: "CONN_ID", Type: querypb.Type_UINT64},
+		{Name: "ADDRESS", Type: querypb.Type_VARCHAR},
+		{Name: "TXN_ID", Type: querypb.Type_UINT64},
+		{Name: "START", Type: querypb.Type_DATETIME},
+		{Name: "AGE", Type: querypb.Type_FLOAT64},
+		{Name: "QUERY", Type: querypb.Type_VARCHAR},
+	}
+	for _, r := range rows {
+		qr.Rows = append(qr.Rows, []sqltypes.Value{
+			uint64Value(uint64(r.ConnID)),
+			varcharValue(r.Address),
+			txnIDValue(r.TxnID),
+			datetimeValue(r.Start),
+			ageValue(r.Duration),
+			varcharValue(r.Query),
+		})
+	}
+	qr.RowsAffected = uint64(len(qr.Rows))
+	return qr
+}
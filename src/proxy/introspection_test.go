@@ -0,0 +1,135 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"backend"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestIntrospectionResults(t *testing.T) {
+	start := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	qr := sessionsResult([]SessionInfo{
+		{ID: 1, User: "u1", Host: "127.0.0.1:8899", DB: "db1", Command: "Sleep", Time: 3},
+		{ID: 2, User: "u2", Host: "127.0.0.1:8898", Command: "Query", State: sessionStateInTransaction, Info: "select 1", TxnID: 7},
+	})
+	assert.Equal(t, "[[1 u1 127.0.0.1:8899 db1 Sleep 3   ] [2 u2 127.0.0.1:8898  Query 0 "+sessionStateInTransaction+" select 1 7]]", fmt.Sprintf("%v", qr.Rows))
+	assert.True(t, qr.Rows[0][8].IsNull())
+
+	qr = transactionsResult([]backend.TxnDetailzRow{
+		{Start: start, Duration: 1500 * time.Millisecond, TxnID: 7, XAID: "RXID-1", State: "txnStateExecutingTwoPC", XaState: "txnXAStateStart"},
+	})
+	assert.Equal(t, "[[7 RXID-1 txnStateExecutingTwoPC txnXAStateStart 2018-01-02 03:04:05 1.500]]", fmt.Sprintf("%v", qr.Rows))
+
+	qr = backendQueriesResult([]backend.QueryDetailzRow{
+		{Start: start, Duration: 10 * time.Millisecond, ConnID: 11, Address: "127.0.0.1:3306", Query: "select 1"},
+		{Start: start, Duration: 20 * time.Millisecond, ConnID: 12, TxnID: 7, Address: "127.0.0.1:3307", Query: "select 2"},
+	})
+	assert.Equal(t, "[[11 127.0.0.1:3306  2018-01-02 03:04:05 0.010 select 1] [12 127.0.0.1:3307 7 2018-01-02 03:04:05 0.020 select 2]]", fmt.Sprintf("%v", qr.Rows))
+	assert.True(t, qr.Rows[0][2].IsNull())
+}
+
+func TestProxySelectIntrospection(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("xa .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("select * .*", &sqltypes.Result{})
+		fakedbs.AddQueryDelay("select * from test.t1_0002 as t1", &sqltypes.Result{}, 2000)
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+	assert.Nil(t, err)
+	defer client.Quit()
+	{
+		_, err = client.FetchAll("create database test", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("create table test.t1(id int, b int) partition by hash(id)", -1)
+		assert.Nil(t, err)
+	}
+
+	// The long query in the transaction.
+	var wg sync.WaitGroup
+	proxy.SetTwoPC(true)
+	clientTxn, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+	assert.Nil(t, err)
+	defer clientTxn.Quit()
+	{
+		_, err = clientTxn.FetchAll("begin", -1)
+		assert.Nil(t, err)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := clientTxn.FetchAll("select * from t1", -1)
+			assert.Nil(t, err)
+		}()
+	}
+	time.Sleep(time.Millisecond * 500)
+
+	// sessions.
+	{
+		qr, err := client.FetchAll("select id, command, state, info from radon.sessions where txn_id is not null", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(qr.Rows))
+		assert.Equal(t, "[Query "+sessionStateInTransaction+" select * from t1]", fmt.Sprintf("%v", qr.Rows[0][1:]))
+	}
+
+	// transactions.
+	{
+		qr, err := client.FetchAll("select * from radon.transactions where age > 0.1 order by start", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(qr.Rows))
+		assert.Equal(t, "TXN_ID", qr.Fields[0].Name)
+	}
+
+	// backend queries.
+	{
+		qr, err := client.FetchAll("select query from radon.backend_queries where query like '%t1_0002%' and txn_id is not null", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, "[[select * from test.t1_0002 as t1]]", fmt.Sprintf("%v", qr.Rows))
+	}
+
+	// join.
+	{
+		query := "select s.id, t.txn_id, b.conn_id, b.query from radon.sessions as s " +
+			"join radon.transactions as t on s.txn_id = t.txn_id " +
+			"left join radon.backend_queries as b on b.txn_id = t.txn_id and b.query like '%t1_0002%' " +
+			"where t.age > 0.1"
+		qr, err := client.FetchAll(query, -1)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(qr.Rows))
+		assert.Equal(t, "select * from test.t1_0002 as t1", qr.Rows[0][3].String())
+
+		// The sessions without the transaction.
+		qr, err = client.FetchAll("select s.id from radon.sessions s left join radon.transactions t on s.txn_id = t.txn_id where t.txn_id is null", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(qr.Rows))
+	}
+
+	wg.Wait()
+	_, err = clientTxn.FetchAll("commit", -1)
+	assert.Nil(t, err)
+	proxy.SetTwoPC(false)
+}
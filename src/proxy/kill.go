@@ -9,12 +9,30 @@
 package proxy
 
 import (
+	"regexp"
+	"strconv"
+
 	"github.com/xelabs/go-mysqlstack/driver"
 	"github.com/xelabs/go-mysqlstack/sqldb"
 	"github.com/xelabs/go-mysqlstack/sqlparser"
 	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
 )
 
+var (
+	killQueryRegexp = regexp.MustCompile(`(?is)^kill\s+query\s+(\d+)$`)
+)
+
+// parseKillQuery returns the thread id of the 'KILL QUERY id', the parser drops the QUERY option.
+func parseKillQuery(query string) (uint32, bool) {
+	m := killQueryRegexp.FindStringSubmatch(query)
+	if m == nil {
+		return 0, false
+	}
+	// The id out of range is an unknown thread.
+	id, _ := strconv.ParseUint(m[1], 10, 32)
+	return uint32(id), true
+}
+
 // handleKill used to handle the KILL command.
 //mysql> show processlist;
 //+----+------+-----------------+------+---------+------+----------+------------------+-----------+---------------+
@@ -45,6 +63,9 @@ func (spanner *Spanner) handleKill(session *driver.Session, query string, node s
 	privilegePlug := spanner.plugins.PlugPrivilege()
 	if !privilegePlug.IsSuperPriv(session.User()) {
 		needKill := sessions.getSession(id)
+		if needKill == nil {
+			return nil, sqldb.NewSQLError1(1094, "HY000", "Unknown thread id: %d", id)
+		}
 		if needKill.session.User() != session.User() {
 			return nil, sqldb.NewSQLErrorf(sqldb.ER_KILL_DENIED_ERROR, "You are not owner of thread %d", id)
 		}
@@ -53,3 +74,27 @@ func (spanner *Spanner) handleKill(session *driver.Session, query string, node s
 	sessions.Kill(id, "kill.query.from.client")
 	return &sqltypes.Result{}, nil
 }
+
+// handleKillQuery used to handle the KILL QUERY command, it kills the running querys of the session on the backends,
+// the session and its transaction are kept.
+func (spanner *Spanner) handleKillQuery(session *driver.Session, id uint32) (*sqltypes.Result, error) {
+	log := spanner.log
+	log.Warning("proxy.handleKillQuery[%d].from.session[%v]", id, session.ID())
+	sessions := spanner.sessions
+
+	needKill := sessions.getSession(id)
+	if needKill == nil {
+		return nil, sqldb.NewSQLError1(1094, "HY000", "Unknown thread id: %d", id)
+	}
+	privilegePlug := spanner.plugins.PlugPrivilege()
+	if !privilegePlug.IsSuperPriv(session.User()) && needKill.session.User() != session.User() {
+		return nil, sqldb.NewSQLErrorf(sqldb.ER_KILL_DENIED_ERROR, "You are not owner of thread %d", id)
+	}
+
+	// The statement runs in the transaction bound to the session.
+	if txnID := sessions.txnID(id); txnID != 0 {
+		killed := spanner.scatter.Queryz().KillTxnQuerys(txnID, "kill.query.from.client")
+		log.Warning("proxy.handleKillQuery[%d].killed[%d].backend.querys", id, killed)
+	}
+	return &sqltypes.Result{}, nil
+}
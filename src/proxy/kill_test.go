@@ -161,3 +161,82 @@ func TestProxyKillPrivilege(t *testing.T) {
 	}
 	wg.Wait()
 }
+
+func TestProxyKillQuery(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("select * .*", &sqltypes.Result{})
+		fakedbs.AddQueryDelay("select * from test.t1_0002 as t1", &sqltypes.Result{}, 2000)
+	}
+
+	// create test table.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		_, err = client.FetchAll("create database test", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("create table test.t1(id int, b int) partition by hash(id)", -1)
+		assert.Nil(t, err)
+		client.Quit()
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+	assert.Nil(t, err)
+	defer client.Quit()
+	kill, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+	assert.Nil(t, err)
+	defer kill.Quit()
+
+	// The session without running query.
+	{
+		_, err = kill.FetchAll(fmt.Sprintf("kill query %d", client.ConnectionID()), -1)
+		assert.Nil(t, err)
+	}
+
+	// The unknown session.
+	{
+		_, err = kill.FetchAll("kill query 100000", -1)
+		assert.Equal(t, "Unknown thread id: 100000 (errno 1094) (sqlstate HY000)", err.Error())
+	}
+
+	// long query.
+	var wg sync.WaitGroup
+	{
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.FetchAll("select * from t1", -1)
+			log.Debug("%+v", err)
+		}()
+	}
+
+	// kill query.
+	{
+		time.Sleep(time.Millisecond * 500)
+		var connID uint32
+		for _, row := range proxy.Scatter().Queryz().GetQueryzRows() {
+			if row.Query == "select * from test.t1_0002 as t1" {
+				connID = row.ConnID
+			}
+		}
+		assert.NotEqual(t, uint32(0), connID)
+
+		_, err = kill.FetchAll(fmt.Sprintf("KILL QUERY %d", client.ConnectionID()), -1)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, fakedbs.GetQueryCalledNum(fmt.Sprintf("kill query %d", connID)))
+	}
+	wg.Wait()
+
+	// The session is kept.
+	{
+		_, err = client.FetchAll("select * from t1 where id=1", -1)
+		assert.Nil(t, err)
+	}
+}
@@ -0,0 +1,147 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"sync"
+	"time"
+
+	"backend"
+	"plugins/privilege"
+
+	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqldb"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// userUsage tuple, the usage of the user in the current second and hour windows.
+type userUsage struct {
+	second        int64
+	secondQueries int
+	hour          int64
+	hourQueries   int
+	scatters      int
+}
+
+// UserLimits tuple, the per-user query quotas and concurrent scatter querys.
+type UserLimits struct {
+	mu    sync.Mutex
+	users map[string]*userUsage
+}
+
+// NewUserLimits creates the new UserLimits.
+func NewUserLimits() *UserLimits {
+	return &UserLimits{
+		users: make(map[string]*userUsage),
+	}
+}
+
+func (l *UserLimits) usage(user string) *userUsage {
+	usage, ok := l.users[user]
+	if !ok {
+		usage = &userUsage{}
+		l.users[user] = usage
+	}
+	return usage
+}
+
+func userLimitReached(user string, resource string, value int) error {
+	return sqldb.NewSQLError1(1226, "42000", "User '%s' has exceeded the '%s' resource (current value: %d)", user, resource, value)
+}
+
+// Query used to count the query of the user, returns error if the queries per second or hour exceed the limits.
+func (l *UserLimits) Query(user string, res privilege.Resources, now time.Time) error {
+	if res.MaxQueriesPerSecond == 0 && res.MaxQueriesPerHour == 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	usage := l.usage(user)
+	second := now.Unix()
+	hour := second / 3600
+	if usage.second != second {
+		usage.second, usage.secondQueries = second, 0
+	}
+	if usage.hour != hour {
+		usage.hour, usage.hourQueries = hour, 0
+	}
+	if res.MaxQueriesPerHour > 0 && usage.hourQueries >= res.MaxQueriesPerHour {
+		return userLimitReached(user, "max_queries_per_hour", usage.hourQueries)
+	}
+	if res.MaxQueriesPerSecond > 0 && usage.secondQueries >= res.MaxQueriesPerSecond {
+		return userLimitReached(user, "max_queries_per_second", usage.secondQueries)
+	}
+	usage.secondQueries++
+	usage.hourQueries++
+	return nil
+}
+
+// ScatterGate returns the gate which limits the concurrent scatter querys of the user, nil if no limit.
+func (l *UserLimits) ScatterGate(user string, max int) backend.ScatterGate {
+	if max == 0 {
+		return nil
+	}
+	return func() (func(), error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		usage := l.usage(user)
+		if usage.scatters >= max {
+			return nil, userLimitReached(user, "max_scatter_queries", usage.scatters)
+		}
+		usage.scatters++
+		return func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			l.usage(user).scatters--
+		}, nil
+	}
+}
+
+// Reset used to clear the usage of all the users.
+func (l *UserLimits) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.users = make(map[string]*userUsage)
+}
+
+// limitRows returns the callback which fails if the rows returned exceed the max.
+func limitRows(user string, max int, callback func(qr *sqltypes.Result) error) func(qr *sqltypes.Result) error {
+	rows := 0
+	return func(qr *sqltypes.Result) error {
+		rows += len(qr.Rows)
+		if rows > max {
+			return sqldb.NewSQLError1(1104, "42000", "The query would return more than the max_rows(%d) rows of the user '%s'", max, user)
+		}
+		return callback(qr)
+	}
+}
+
+// userResources returns the resource limits of the user in the catalog.
+func (spanner *Spanner) userResources(user string) privilege.Resources {
+	res, _ := spanner.plugins.PlugPrivilege().Catalog().Resources(user)
+	return res
+}
+
+// checkUserConnections used to check the connections of the user after the authentication,
+// the session of the user is counted already.
+func (spanner *Spanner) checkUserConnections(s *driver.Session) error {
+	user := s.User()
+	max := spanner.userResources(user).MaxUserConnections
+	if max > 0 && spanner.sessions.UserCount(user) > max {
+		return sqldb.NewSQLError1(1203, "42000", "User %s already has more than 'max_user_connections' active connections", user)
+	}
+	return nil
+}
+
+// setTxnLimits used to set the per-user limits of the session to the txn.
+func (spanner *Spanner) setTxnLimits(session *driver.Session, txn backend.Transaction) {
+	user := session.User()
+	txn.SetScatterGate(spanner.limits.ScatterGate(user, spanner.userResources(user).MaxScatterQueries))
+}
@@ -0,0 +1,175 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"testing"
+	"time"
+
+	"plugins/privilege"
+
+	"github.com/fortytw2/leaktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/driver"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestUserLimitsQuery(t *testing.T) {
+	limits := NewUserLimits()
+	now := time.Unix(7200, 0)
+
+	// No limits.
+	for i := 0; i < 10; i++ {
+		assert.Nil(t, limits.Query("u1", privilege.Resources{}, now))
+	}
+
+	// Queries per second.
+	res := privilege.Resources{MaxQueriesPerSecond: 2, MaxQueriesPerHour: 3}
+	assert.Nil(t, limits.Query("u1", res, now))
+	assert.Nil(t, limits.Query("u1", res, now))
+	err := limits.Query("u1", res, now)
+	assert.Equal(t, "User 'u1' has exceeded the 'max_queries_per_second' resource (current value: 2) (errno 1226) (sqlstate 42000)", err.Error())
+
+	// The other user has its own quota.
+	assert.Nil(t, limits.Query("u2", res, now))
+
+	// Queries per hour.
+	now = now.Add(time.Second)
+	assert.Nil(t, limits.Query("u1", res, now))
+	err = limits.Query("u1", res, now)
+	assert.Equal(t, "User 'u1' has exceeded the 'max_queries_per_hour' resource (current value: 3) (errno 1226) (sqlstate 42000)", err.Error())
+
+	// The next hour.
+	now = now.Add(time.Hour)
+	assert.Nil(t, limits.Query("u1", res, now))
+
+	// Reset.
+	limits.Reset()
+	assert.Nil(t, limits.Query("u1", res, now))
+	assert.Nil(t, limits.Query("u1", res, now))
+}
+
+func TestUserLimitsScatterGate(t *testing.T) {
+	limits := NewUserLimits()
+	assert.Nil(t, limits.ScatterGate("u1", 0))
+
+	gate := limits.ScatterGate("u1", 2)
+	leave1, err := gate()
+	assert.Nil(t, err)
+	leave2, err := gate()
+	assert.Nil(t, err)
+	_, err = gate()
+	assert.Equal(t, "User 'u1' has exceeded the 'max_scatter_queries' resource (current value: 2) (errno 1226) (sqlstate 42000)", err.Error())
+
+	leave1()
+	leave3, err := gate()
+	assert.Nil(t, err)
+	leave2()
+	leave3()
+	assert.Equal(t, 0, limits.users["u1"].scatters)
+}
+
+func TestUserLimitsRows(t *testing.T) {
+	var got int
+	callback := limitRows("u1", 2, func(qr *sqltypes.Result) error {
+		got += len(qr.Rows)
+		return nil
+	})
+	row := []sqltypes.Value{sqltypes.MakeTrusted(querypb.Type_INT32, []byte("1"))}
+
+	err := callback(&sqltypes.Result{Rows: [][]sqltypes.Value{row}})
+	assert.Nil(t, err)
+	err = callback(&sqltypes.Result{Rows: [][]sqltypes.Value{row}})
+	assert.Nil(t, err)
+	err = callback(&sqltypes.Result{Rows: [][]sqltypes.Value{row}})
+	assert.Equal(t, "The query would return more than the max_rows(2) rows of the user 'u1' (errno 1104) (sqlstate 42000)", err.Error())
+	assert.Equal(t, 2, got)
+}
+
+func TestProxyUserLimits(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	// fakedbs.
+	{
+		rs := &sqltypes.Result{
+			Fields: []*querypb.Field{{Name: "id", Type: querypb.Type_INT32}},
+			Rows:   [][]sqltypes.Value{{sqltypes.MakeTrusted(querypb.Type_INT32, []byte("1"))}},
+		}
+		fakedbs.AddQuery("select version() as version", resultVersion57)
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("select .*", rs)
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+	}
+
+	// The super user creates the user with the limits.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		defer client.Close()
+
+		queries := []string{
+			"create database db1",
+			"create table db1.t1(id int) partition by hash(id)",
+			"create user u1 identified by 'p1' with max_user_connections 1 max_queries_per_hour 5",
+			"grant select on db1.* to u1",
+		}
+		for _, query := range queries {
+			_, err = client.FetchAll(query, -1)
+			assert.Nil(t, err, query)
+		}
+		res, _ := proxy.Plugins().PlugPrivilege().Catalog().Resources("u1")
+		assert.Equal(t, privilege.Resources{MaxUserConnections: 1, MaxQueriesPerHour: 5}, res)
+	}
+
+	// Max user connections and queries per hour.
+	{
+		client, err := driver.NewConn("u1", "p1", address, "db1", "utf8")
+		assert.Nil(t, err)
+
+		_, err = driver.NewConn("u1", "p1", address, "db1", "utf8")
+		assert.NotNil(t, err)
+		assert.Equal(t, "User u1 already has more than 'max_user_connections' active connections (errno 1203) (sqlstate 42000)", err.Error())
+
+		for i := 0; i < 5; i++ {
+			_, err = client.FetchAll("select 1", -1)
+			assert.Nil(t, err)
+		}
+		_, err = client.FetchAll("select 1", -1)
+		assert.Equal(t, "User 'u1' has exceeded the 'max_queries_per_hour' resource (current value: 5) (errno 1226) (sqlstate 42000)", err.Error())
+		client.Close()
+	}
+
+	// Max rows.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		_, err = client.FetchAll("alter user u1 with max_queries_per_hour 0 max_rows 1", -1)
+		assert.Nil(t, err)
+		client.Close()
+
+		// Wait the session of u1 closed.
+		for proxy.Spanner().sessions.UserCount("u1") > 0 {
+			time.Sleep(10 * time.Millisecond)
+		}
+		client, err = driver.NewConn("u1", "p1", address, "db1", "utf8")
+		assert.Nil(t, err)
+		defer client.Close()
+		qr, err := client.FetchAll("select id from t1 where id=1", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(qr.Rows))
+		_, err = client.FetchAll("select id from t1", -1)
+		assert.Equal(t, "The query would return more than the max_rows(1) rows of the user 'u1' (errno 1104) (sqlstate 42000)", err.Error())
+	}
+}
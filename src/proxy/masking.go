@@ -0,0 +1,21 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"planner/builder"
+
+	"github.com/xelabs/go-mysqlstack/driver"
+)
+
+// masker returns the column masker of the session user, nil if no column is masked.
+func (spanner *Spanner) masker(session *driver.Session) builder.Masker {
+	masking := spanner.plugins.PlugMasking()
+	return masking.Masker(session.User())
+}
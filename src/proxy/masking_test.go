@@ -0,0 +1,112 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"fmt"
+	"testing"
+
+	"plugins/masking"
+
+	"github.com/fortytw2/leaktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/driver"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestProxyMasking(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	result := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "id", Type: querypb.Type_INT32},
+			{Name: "phone", Type: querypb.Type_VARCHAR},
+		},
+		Rows: [][]sqltypes.Value{
+			{
+				sqltypes.MakeTrusted(querypb.Type_INT32, []byte("1")),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("13812345678")),
+			},
+		},
+	}
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("select .*", result)
+		fakedbs.AddQueryPattern("delete .*", &sqltypes.Result{})
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+	queries := []string{
+		"create database db1",
+		"create table db1.t1(id int, phone varchar(32)) partition by hash(id)",
+	}
+	for _, query := range queries {
+		_, err = client.FetchAll(query, -1)
+		assert.Nil(t, err, query)
+	}
+
+	// Not masked.
+	{
+		qr, err := client.FetchAll("select id, phone from db1.t1 where id=1", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, "[[1 13812345678]]", fmt.Sprintf("%v", qr.Rows))
+	}
+
+	policies := []*masking.Policy{
+		{Name: "phone", Database: "db1", Table: "t1", Column: "phone", Type: "partial", Users: []string{"mock"}},
+	}
+	err = proxy.Plugins().PlugMasking().SetPolicies(policies)
+	assert.Nil(t, err)
+
+	// Masked.
+	{
+		qr, err := client.FetchAll("select id, phone from db1.t1 where id=1", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, "[[1 *******5678]]", fmt.Sprintf("%v", qr.Rows))
+
+		qr, err = client.FetchAll("select /*+ streaming */ * from db1.t1 where id=1", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, "[[1 *******5678]]", fmt.Sprintf("%v", qr.Rows))
+
+		qr, err = client.FetchAll("explain select id, phone from db1.t1 where id=1", -1)
+		assert.Nil(t, err)
+		assert.Contains(t, string(qr.Rows[0][0].Raw()), "\"Mask\": {\n\t\t\"Masks\": [\n\t\t\t\"\",\n\t\t\t\"PARTIAL\"\n\t\t]\n\t}")
+	}
+
+	// The masked column in the predicate.
+	{
+		_, err := client.FetchAll("select id from db1.t1 where phone like '138%'", -1)
+		assert.Equal(t, "unsupported: the.masked.column[phone].in.the.predicate (errno 1105) (sqlstate HY000)", err.Error())
+	}
+
+	// The masked column in the update and delete.
+	{
+		_, err := client.FetchAll("delete from db1.t1 where id=1", -1)
+		assert.Nil(t, err)
+
+		_, err = client.FetchAll("delete from db1.t1 where phone like '138%'", -1)
+		assert.Equal(t, "unsupported: the.masked.column[phone].in.the.predicate (errno 1105) (sqlstate HY000)", err.Error())
+
+		_, err = client.FetchAll("update db1.t1 set phone='x' where phone like '138%'", -1)
+		assert.Equal(t, "unsupported: the.masked.column[phone].in.the.predicate (errno 1105) (sqlstate HY000)", err.Error())
+
+		_, err = client.FetchAll("update db1.t1 set phone=concat(phone, 'x') where id=1", -1)
+		assert.Equal(t, "unsupported: the.masked.column[phone].in.the.set.value (errno 1105) (sqlstate HY000)", err.Error())
+	}
+}
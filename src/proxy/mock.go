@@ -77,11 +77,16 @@ func randomPort(min int, max int) int {
 // MockDefaultConfig mocks the default config.
 func MockDefaultConfig() *config.Config {
 	conf := &config.Config{
-		Proxy:   config.DefaultProxyConfig(),
-		Audit:   config.DefaultAuditConfig(),
-		Router:  config.DefaultRouterConfig(),
-		Log:     config.DefaultLogConfig(),
-		Scatter: config.DefaultScatterConfig(),
+		Proxy:      config.DefaultProxyConfig(),
+		Audit:      config.DefaultAuditConfig(),
+		SlowLog:    config.DefaultSlowLogConfig(),
+		Trace:      config.DefaultTraceConfig(),
+		Router:     config.DefaultRouterConfig(),
+		Log:        config.DefaultLogConfig(),
+		Scatter:    config.DefaultScatterConfig(),
+		Auth:       config.DefaultAuthConfig(),
+		Workload:   config.DefaultWorkloadConfig(),
+		Encryption: config.DefaultEncryptionConfig(),
 	}
 	return conf
 }
@@ -9,6 +9,8 @@
 package proxy
 
 import (
+	"strings"
+
 	"backend"
 
 	"github.com/pkg/errors"
@@ -24,7 +26,7 @@ func (spanner *Spanner) handleMultiStmtTxn(session *driver.Session, query string
 	snode := node.(*sqlparser.Transaction)
 	switch snode.Action {
 	case sqlparser.StartTxnStr:
-		qr, err = spanner.handleStartTransaction(session, snode.Action, node)
+		qr, err = spanner.handleStartTransaction(session, query, node)
 	case sqlparser.BeginTxnStr:
 		qr, err = spanner.handleBegin(session, snode.Action, node)
 	case sqlparser.RollbackTxnStr:
@@ -40,9 +42,56 @@ func (spanner *Spanner) handleMultiStmtTxn(session *driver.Session, query string
 
 // handleStartTransaction used to handle Multi-statement transaction "start transaction"
 func (spanner *Spanner) handleStartTransaction(session *driver.Session, query string, node sqlparser.Statement) (*sqltypes.Result, error) {
+	if isConsistentSnapshot(query) {
+		return spanner.ExecuteBeginSnapshot(session, query, node)
+	}
 	return spanner.ExecuteBegin(session, query, node)
 }
 
+// isConsistentSnapshot used to check the query is "start transaction with consistent snapshot",
+// the parser discards the characteristics after "start transaction".
+// The words are matched on the tokens, the comments and the string literals are skipped,
+// the version comments like '/*!40100 WITH CONSISTENT SNAPSHOT */' are executed by MySQL, so they are matched.
+func isConsistentSnapshot(query string) bool {
+	want := []string{"with", "consistent", "snapshot"}
+	words := queryWords(query)
+	for i := 0; i+len(want) <= len(words); i++ {
+		matched := true
+		for j := range want {
+			if words[i+j] != want[j] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// queryWords returns the lowercase tokens of the query without the comments, the string literal is one token.
+func queryWords(query string) []string {
+	var words []string
+	tokenizer := sqlparser.NewStringTokenizer(query)
+	for {
+		typ, val := tokenizer.Scan()
+		switch typ {
+		case 0, sqlparser.LEX_ERROR:
+			return words
+		case sqlparser.COMMENT:
+			if comment := string(val); strings.HasPrefix(comment, "/*!") && strings.HasSuffix(comment, "*/") {
+				_, inner := sqlparser.ExtractMysqlComment(comment)
+				words = append(words, queryWords(inner)...)
+			}
+		case sqlparser.STRING:
+			words = append(words, "'"+string(val)+"'")
+		default:
+			words = append(words, strings.ToLower(string(val)))
+		}
+	}
+}
+
 // handleBegin used to handle Multi-statement transaction "begin"
 func (spanner *Spanner) handleBegin(session *driver.Session, query string, node sqlparser.Statement) (*sqltypes.Result, error) {
 	return spanner.ExecuteBegin(session, query, node)
@@ -60,6 +109,16 @@ func (spanner *Spanner) handleCommit(session *driver.Session, query string, node
 
 // ExecuteBegin used to execute "start transaction" or "begin".
 func (spanner *Spanner) ExecuteBegin(session *driver.Session, query string, node sqlparser.Statement) (*sqltypes.Result, error) {
+	return spanner.executeBegin(session, query, node, false)
+}
+
+// ExecuteBeginSnapshot used to execute "start transaction with consistent snapshot",
+// the read-only txn sees all the backends at the same moment.
+func (spanner *Spanner) ExecuteBeginSnapshot(session *driver.Session, query string, node sqlparser.Statement) (*sqltypes.Result, error) {
+	return spanner.executeBegin(session, query, node, true)
+}
+
+func (spanner *Spanner) executeBegin(session *driver.Session, query string, node sqlparser.Statement, snapshot bool) (*sqltypes.Result, error) {
 	log := spanner.log
 	conf := spanner.conf
 	sessions := spanner.sessions
@@ -94,9 +153,14 @@ func (spanner *Spanner) ExecuteBegin(session *driver.Session, query string, node
 	txn.SetMaxJoinRows(conf.Proxy.MaxJoinRows)
 	txn.SetMultiStmtTxn()
 	txn.SetIsExecOnRep(false)
+	spanner.setTxnLimits(session, txn)
 
 	sessions.MultiStmtTxnBinding(session, txn, node, query)
-	if err := txn.BeginScatter(); err != nil {
+	begin := txn.BeginScatter
+	if snapshot {
+		begin = txn.BeginSnapshot
+	}
+	if err := begin(); err != nil {
 		txn.Finish()
 		sessions.MultiStmtTxnUnBinding(session, true)
 		log.Error("spanner.execute.multistmt.txn.begin.scatter.error:[%v]", err)
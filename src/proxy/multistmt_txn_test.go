@@ -643,3 +643,96 @@ func TestProxyHandleMStmtTxnNOUseDB(t *testing.T) {
 
 	client1.Close()
 }
+
+func TestProxyHandleMStmtTxnSnapshot(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("select .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("checksum table .*", checksumTableResult1)
+		fakedbs.AddQuery("start transaction with consistent snapshot, read only", &sqltypes.Result{})
+		fakedbs.AddQuery("commit", &sqltypes.Result{})
+	}
+
+	// create test table.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		query := "create database test"
+		_, err = client.FetchAll(query, -1)
+		assert.Nil(t, err)
+		query = "create table test.t1(id int, b int) partition by hash(id)"
+		_, err = client.FetchAll(query, -1)
+		assert.Nil(t, err)
+		client.Close()
+	}
+
+	proxy.SetTwoPC(true)
+	client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	{
+		query := "start transaction  WITH consistent\tsnapshot"
+		_, err = client.FetchAll(query, -1)
+		assert.Nil(t, err)
+		assert.True(t, fakedbs.GetQueryCalledNum("start transaction with consistent snapshot, read only") > 0)
+		assert.Equal(t, 0, fakedbs.GetQueryCalledNum("xa start"))
+	}
+
+	{
+		query := "select * from t1"
+		_, err = client.FetchAll(query, -1)
+		assert.Nil(t, err)
+	}
+
+	// checksum in the snapshot.
+	{
+		query := "checksum table t1"
+		_, err = client.FetchAll(query, -1)
+		assert.Nil(t, err)
+	}
+
+	// write is rejected.
+	{
+		query := "insert into t1(id, b) values(1, 1)"
+		_, err = client.FetchAll(query, -1)
+		assert.NotNil(t, err)
+		want := "Cannot execute statement in a READ ONLY transaction. (errno 1792) (sqlstate 25006)"
+		assert.Equal(t, want, err.Error())
+	}
+
+	{
+		query := "commit"
+		_, err = client.FetchAll(query, -1)
+		assert.Nil(t, err)
+		assert.True(t, fakedbs.GetQueryCalledNum("commit") > 0)
+	}
+}
+
+func TestProxyIsConsistentSnapshot(t *testing.T) {
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"start transaction", false},
+		{"start transaction read only", false},
+		{"START TRANSACTION WITH CONSISTENT SNAPSHOT", true},
+		{"start transaction with  consistent\nsnapshot, read only", true},
+		{"start transaction /*!40100 WITH CONSISTENT SNAPSHOT */", true},
+		{"start transaction /* with consistent snapshot */", false},
+		{"start transaction -- with consistent snapshot", false},
+		{"start transaction # with consistent snapshot", false},
+		{"start transaction 'with consistent snapshot'", false},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.want, isConsistentSnapshot(test.query), test.query)
+	}
+}
@@ -103,6 +103,10 @@ func (p *Proxy) Start() {
 	if err := plugins.Init(); err != nil {
 		log.Panic("proxy.plugins.init.panic:%+v", err)
 	}
+	syncer.AddLoader(plugins.PlugPrivilege().Catalog())
+	syncer.AddLoader(plugins.PlugFirewall())
+	syncer.AddLoader(plugins.PlugRewrite())
+	syncer.AddLoader(plugins.PlugMasking())
 
 	spanner := NewSpanner(log, conf, iptable, router, scatter, sessions, audit, throttle, plugins, serverVersion)
 	if err := spanner.Init(); err != nil {
@@ -112,6 +116,18 @@ func (p *Proxy) Start() {
 	if err != nil {
 		log.Panic("proxy.start.error[%+v]", err)
 	}
+	if proxyConf := conf.Proxy; proxyConf.TLSCert != "" && proxyConf.TLSKey != "" {
+		tlsConfig, err := xbase.NewServerTLSConfig(proxyConf.TLSCert, proxyConf.TLSKey, proxyConf.TLSCA, proxyConf.TLSVerifyClient)
+		if err != nil {
+			log.Panic("proxy.start.tls.error[%+v]", err)
+		}
+		svr.SetTLSConfig(tlsConfig)
+		spanner.tlsEnabled = true
+		log.Info("proxy.start.tls.enabled.verify.client[%v]", proxyConf.TLSVerifyClient)
+	}
+	if conf.Auth != nil {
+		svr.SetAuthPluginName(conf.Auth.DefaultAuthPlugin)
+	}
 	p.spanner = spanner
 	p.listener = svr
 	log.Info("proxy.start[%v]...", endpoint)
@@ -178,6 +194,21 @@ func (p *Proxy) Spanner() *Spanner {
 	return p.spanner
 }
 
+// Workload returns the workload scheduler.
+func (p *Proxy) Workload() *Workload {
+	return p.spanner.workload
+}
+
+// Audit returns the audit.
+func (p *Proxy) Audit() *audit.Audit {
+	return p.audit
+}
+
+// Digests returns the statement digests.
+func (p *Proxy) Digests() *Digests {
+	return p.spanner.digests
+}
+
 // SetMaxConnections used to set the max connections.
 func (p *Proxy) SetMaxConnections(connections int) {
 	p.mu.Lock()
@@ -242,6 +273,23 @@ func (p *Proxy) SetAutocommitFalseIsTxn(enable bool) {
 	p.conf.Proxy.AutocommitFalseIsTxn = enable
 }
 
+// SetRequireSecureTransport used to refuse the client connections without TLS or not.
+func (p *Proxy) SetRequireSecureTransport(enable bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.log.Info("proxy.SetRequireSecureTransport:[%v->%v]", p.conf.Proxy.RequireSecureTransport, enable)
+	p.conf.Proxy.RequireSecureTransport = enable
+}
+
+// SetStatementDigests used to enable or disable the statement digests.
+func (p *Proxy) SetStatementDigests(enable bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.log.Info("proxy.SetStatementDigests:[%v->%v]", p.conf.Proxy.StatementDigests, enable)
+	p.conf.Proxy.StatementDigests = enable
+	p.spanner.digests.SetEnabled(enable)
+}
+
 // SetAllowIP used to set allow ips.
 func (p *Proxy) SetAllowIP(ips []string) {
 	p.mu.Lock()
@@ -258,6 +306,19 @@ func (p *Proxy) SetAuditMode(mode string) {
 	p.conf.Audit.Mode = mode
 }
 
+// SetAuditFilters used to set the filter rules of audit.
+func (p *Proxy) SetAuditFilters(filters []*config.AuditFilterConfig) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.audit.SetFilters(filters); err != nil {
+		p.log.Error("proxy.SetAuditFilters[%+v].error:%v", filters, err)
+		return err
+	}
+	p.log.Info("proxy.SetAuditFilters:[%+v->%+v]", p.conf.Audit.Filters, filters)
+	p.conf.Audit.Filters = filters
+	return nil
+}
+
 // SetReadOnly used to enable/disable readonly.
 func (p *Proxy) SetReadOnly(val bool) {
 	p.mu.Lock()
@@ -299,6 +360,19 @@ func (p *Proxy) SetThrottle(val int) {
 	p.throttle.Set(val)
 }
 
+// SetWorkload used to set the workload groups.
+func (p *Proxy) SetWorkload(conf *config.WorkloadConfig) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.spanner.workload.Set(conf); err != nil {
+		p.log.Error("proxy.SetWorkload[%+v].error:%v", conf, err)
+		return err
+	}
+	p.log.Info("proxy.SetWorkload:[%+v->%+v]", p.conf.Workload, conf)
+	p.conf.Workload = conf
+	return nil
+}
+
 // SetStreamBufferSize used to set the streamBufferSize.
 func (p *Proxy) SetStreamBufferSize(streamBufferSize int) {
 	p.mu.Lock()
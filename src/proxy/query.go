@@ -12,6 +12,7 @@ import (
 	"strings"
 	"time"
 
+	"audit"
 	"monitor"
 	"xbase"
 
@@ -28,8 +29,7 @@ func returnQuery(qr *sqltypes.Result, callback func(qr *sqltypes.Result) error,
 	if err != nil {
 		return err
 	}
-	callback(qr)
-	return nil
+	return callback(qr)
 }
 
 // ComQuery impl.
@@ -46,9 +46,33 @@ func (spanner *Spanner) ComQuery(session *driver.Session, query string, bindVari
 	slowQueryTime := time.Duration(spanner.conf.Proxy.LongQueryTime) * time.Second
 
 	// Throttle.
+	monitor.ThrottleQueueDepthInc()
 	throttle.Acquire()
+	monitor.ThrottleQueueDepthDec()
 	defer throttle.Release()
 
+	// Per-user resource limits.
+	user := session.User()
+	res := spanner.userResources(user)
+	if err := spanner.limits.Query(user, res, timeStart); err != nil {
+		log.Warning("proxy.query.user[%s].limit.reached:%v", user, err)
+		return err
+	}
+	if res.MaxRows > 0 {
+		callback = limitRows(user, res.MaxRows, callback)
+	}
+
+	// Workload group scheduling, the statements of the session in the transaction are exempted
+	// since they may hold the locks which the queued queries are waiting for.
+	if !workloadExempt(query) && spanner.sessions.txnID(session.ID()) == 0 {
+		release, err := spanner.workload.Acquire(user, session.Schema(), query)
+		if err != nil {
+			log.Warning("proxy.query.user[%s].workload.error:%v", user, err)
+			return err
+		}
+		defer release()
+	}
+
 	// Disk usage check.
 	if diskChecker.HighWater() {
 		return sqldb.NewSQLErrorf(sqldb.ER_UNKNOWN_ERROR, "%s", "no space left on device")
@@ -67,7 +91,89 @@ func (spanner *Spanner) ComQuery(session *driver.Session, query string, bindVari
 	query = strings.TrimSpace(query)
 	query = strings.TrimSuffix(query, ";")
 
-	node, err := sqlparser.Parse(query)
+	// Savepoint statements, the parser doesn't support them.
+	if stmt := parseSavepoint(query); stmt != nil {
+		status := uint16(0)
+		qr, err := spanner.handleSavepoint(session, query, stmt)
+		if err != nil {
+			log.Error("proxy.savepoint[%s].from.session[%v].error:%+v", query, session.ID(), err)
+			status = 1
+		}
+		spanner.auditLog(session, R, xbase.TRANSACTION, query, qr, status)
+		return returnQuery(qr, callback, err)
+	}
+
+	// Account management statements, the parser doesn't support them.
+	// The passwords are redacted before any logging, the changes are recorded as the admin events.
+	if stmt, err := parseUserStmt(query); stmt != nil || err != nil {
+		status := uint16(0)
+		redacted := redactUserQuery(query)
+		if err != nil {
+			// The syntax error may be near the password.
+			log.Error("proxy.user.query[%s].from.session[%v].syntax.error", redacted, session.ID())
+			return returnQuery(qr, callback, err)
+		}
+
+		var e *audit.AdminEvent
+		if action := userAdminAction(stmt); action != "" {
+			e = spanner.newAdminEvent(session, action, userAdminParams(query))
+		}
+		if err = spanner.userCheck(session, redacted, stmt); err == nil {
+			qr, err = spanner.handleUser(session, query, stmt)
+		}
+		if err != nil {
+			log.Error("proxy.user.query[%s].from.session[%v].error:%+v", redacted, session.ID(), err)
+			status = 1
+		}
+		if e != nil {
+			spanner.logAdminEvent(e, err)
+		}
+		spanner.auditLog(session, W, userQueryType(stmt), redacted, qr, status)
+		return returnQuery(qr, callback, err)
+	}
+
+	// Statement digests statements, the parser doesn't support them.
+	if stmt := parseDigestStmt(query); stmt != nil {
+		status := uint16(0)
+		qr, err := spanner.handleDigest(session, query, stmt)
+		if err != nil {
+			log.Error("proxy.digest[%s].from.session[%v].error:%+v", query, session.ID(), err)
+			status = 1
+		}
+		spanner.auditLog(session, R, digestQueryType(stmt), query, qr, status)
+		return returnQuery(qr, callback, err)
+	}
+
+	// KILL QUERY, the parser takes it as the KILL.
+	if id, ok := parseKillQuery(query); ok {
+		status := uint16(0)
+		qr, err := spanner.handleKillQuery(session, id)
+		if err != nil {
+			log.Error("proxy.kill.query[%s].from.session[%v].error:%+v", query, session.ID(), err)
+			status = 1
+		}
+		spanner.auditLog(session, R, xbase.KILL, query, qr, status)
+		return returnQuery(qr, callback, err)
+	}
+
+	// SHOW AUDIT STATUS, the parser doesn't support it.
+	if isShowAuditStatus(query) {
+		status := uint16(0)
+		qr, err := spanner.handleShowAuditStatus(session)
+		if err != nil {
+			log.Error("proxy.show.audit.status[%s].from.session[%v].error:%+v", query, session.ID(), err)
+			status = 1
+		}
+		spanner.auditLog(session, R, xbase.SHOW, query, qr, status)
+		return returnQuery(qr, callback, err)
+	}
+
+	// The trace context propagated by the SQL comment, the comments are lost after parsing.
+	traceparent := spanner.traceparent(query)
+	parseStart := time.Now()
+
+	// The parser doesn't support some EXPLAIN extensions.
+	node, err := parseExplain(query)
 	if err != nil {
 		log.Error("query[%v].parser.error: %v", query, err)
 		return sqldb.NewSQLError(sqldb.ER_SYNTAX_ERROR, err.Error())
@@ -90,6 +196,8 @@ func (spanner *Spanner) ComQuery(session *driver.Session, query string, bindVari
 		}
 	}
 
+	parseEnd := time.Now()
+
 	if spanner.isLowerCaseTableNames() {
 		node = sqlparser.LowerCaseTableNames(node).(sqlparser.Statement)
 		query = sqlparser.String(node)
@@ -112,8 +220,50 @@ func (spanner *Spanner) ComQuery(session *driver.Session, query string, bindVari
 		}
 	}
 
+	// Firewall check.
+	if err = spanner.firewallCheck(session, query, node); err != nil {
+		log.Warning("proxy.query[%s].from.session[%v].firewall.error:%v", query, session.ID(), err)
+		return err
+	}
+
+	// Query rewrite, the EXPLAIN statement is rewritten in the handleExplain.
+	if _, ok := node.(*sqlparser.Explain); !ok {
+		if rules := spanner.rewrite(session, node); len(rules) > 0 {
+			query = sqlparser.String(node)
+			log.Debug("proxy.query.rewrite.rules[%v].query:%v", rules, query)
+		}
+
+		// Column encryption, the backends only see the encrypted values.
+		var encrypted bool
+		if encrypted, err = spanner.encrypt(session, node); err != nil {
+			log.Error("proxy.query[%s].from.session[%v].encrypt.error:%v", xbase.TruncateQuery(query, 256), session.ID(), err)
+			return err
+		}
+		if encrypted {
+			query = sqlparser.String(node)
+		}
+	}
+
+	// The statement and its backend querys stats are recorded to the digest,
+	// the statement must be formatted before the planner rewrites the node.
+	var digestQuery string
+	digests := spanner.digests.Enabled()
+	if digests {
+		digestQuery = sqlparser.String(node)
+	}
+	execStats := spanner.sessions.execStats(session)
+	execStats.Reset(spanner.slowlog.Enabled())
+	span := spanner.startSpan(session, query, traceparent, timeStart, parseStart, parseEnd)
 	defer func() {
-		queryStat(node, timeStart, slowQueryTime, err)
+		spanner.endSpan(session, span, qr, err)
+		queryTime := time.Since(timeStart)
+		queryStat(node, timeStart, slowQueryTime, execStats.Querys(), err)
+		if digests {
+			spanner.digests.Record(session.Schema(), digestQuery, queryTime, qr, execStats, err)
+		}
+		if queryTime > slowQueryTime {
+			spanner.slowLog(session, query, timeStart, queryTime, qr, execStats, err)
+		}
 	}()
 	// The status of the execution result, zero for success and non-zero for failure.
 	status := uint16(0)
@@ -296,6 +446,12 @@ func (spanner *Spanner) ComQuery(session *driver.Session, query string, bindVari
 						log.Error("proxy.select[%s].from.session[%v].error:%+v", query, session.ID(), err)
 						status = 1
 					}
+				} else if isVirtualDB(tb.Qualifier.String()) {
+					// Radon virtual table select.
+					if qr, err = spanner.handleSelectVirtual(session, query, node); err != nil {
+						log.Error("proxy.select[%s].from.session[%v].error:%+v", query, session.ID(), err)
+						status = 1
+					}
 				} else if spanner.router.IsSystemDB(tb.Qualifier.String()) {
 					// System database select.
 					if qr, err = spanner.handleSelectSystem(session, query, node); err != nil {
@@ -313,7 +469,13 @@ func (spanner *Spanner) ComQuery(session *driver.Session, query string, bindVari
 			spanner.auditLog(session, R, xbase.SELECT, query, qr, status)
 			return returnQuery(qr, callback, err)
 		default: // ParenTableExpr, JoinTableExpr
-			if qr, err = spanner.handleSelect(session, query, node); err != nil {
+			if isVirtualFrom(node.From) {
+				// Radon virtual tables join.
+				if qr, err = spanner.handleSelectVirtual(session, query, node); err != nil {
+					log.Error("proxy.select[%s].from.session[%v].error:%+v", query, session.ID(), err)
+					status = 1
+				}
+			} else if qr, err = spanner.handleSelect(session, query, node); err != nil {
 				log.Error("proxy.select[%s].from.session[%v].error:%+v", query, session.ID(), err)
 				status = 1
 			}
@@ -453,7 +615,9 @@ func (spanner *Spanner) IsAdminCmd(node sqlparser.Statement) bool {
 	return false
 }
 
-func queryStat(node sqlparser.Statement, timeStart time.Time, slowQueryTime time.Duration, err error) {
+// queryStat records the query counters and the latency and fanout histograms,
+// the fanout is the number of the backend querys of the query.
+func queryStat(node sqlparser.Statement, timeStart time.Time, slowQueryTime time.Duration, fanout uint64, err error) {
 	var command string
 	switch node.(type) {
 	case *sqlparser.Use:
@@ -484,15 +648,16 @@ func queryStat(node sqlparser.Statement, timeStart time.Time, slowQueryTime time
 		command = "Unsupport"
 	}
 	queryTime := time.Since(timeStart)
+	result := "OK"
 	if err != nil {
-		if queryTime > slowQueryTime {
-			monitor.SlowQueryTotalCounterInc(command, "Error")
-		}
-		monitor.QueryTotalCounterInc(command, "Error")
-	} else {
-		if queryTime > slowQueryTime {
-			monitor.SlowQueryTotalCounterInc(command, "OK")
-		}
-		monitor.QueryTotalCounterInc(command, "OK")
+		result = "Error"
+	}
+	if queryTime > slowQueryTime {
+		monitor.SlowQueryTotalCounterInc(command, result)
+	}
+	monitor.QueryTotalCounterInc(command, result)
+	monitor.QueryDurationObserve(command, result, queryTime.Seconds())
+	if fanout > 0 {
+		monitor.QueryFanoutObserve(command, float64(fanout))
 	}
 }
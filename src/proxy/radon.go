@@ -9,6 +9,10 @@
 package proxy
 
 import (
+	"strings"
+
+	"audit"
+
 	"github.com/pkg/errors"
 	"github.com/xelabs/go-mysqlstack/driver"
 	"github.com/xelabs/go-mysqlstack/sqldb"
@@ -39,6 +43,14 @@ func (spanner *Spanner) handleRadon(session *driver.Session, query string, node
 		}
 	}
 
+	// The administrative actions are recorded as the admin events.
+	if isRadonAdminAction(snode.Action) {
+		e := spanner.newAdminEvent(session, "RADON "+strings.ToUpper(snode.Action), radonParams(session, snode))
+		defer func() {
+			spanner.logAdminEvent(e, err)
+		}()
+	}
+
 	switch snode.Action {
 	case sqlparser.AttachStr:
 		qr, err = attach.Attach(snode)
@@ -87,3 +99,40 @@ func (spanner *Spanner) handleRadon(session *driver.Session, query string, node
 	}
 	return qr, err
 }
+
+// isRadonAdminAction returns true if the action changes the meta or the backends.
+func isRadonAdminAction(action string) bool {
+	switch action {
+	case sqlparser.AttachStr, sqlparser.DetachStr, sqlparser.ReshardStr, sqlparser.CleanupStr,
+		sqlparser.RebalanceStr, sqlparser.XACommitStr, sqlparser.XARollbackStr:
+		return true
+	}
+	return false
+}
+
+// radonParams returns the params of the radon statement, the password of the attach is redacted.
+func radonParams(session *driver.Session, snode *sqlparser.Radon) string {
+	params := make(map[string]string)
+	switch snode.Action {
+	case sqlparser.AttachStr:
+		keys := []string{"address", "user", "password"}
+		for i, expr := range snode.Row {
+			if val, ok := expr.(*sqlparser.SQLVal); ok && i < len(keys) {
+				params[keys[i]] = common.BytesToString(val.Val)
+			}
+		}
+	case sqlparser.DetachStr:
+		if len(snode.Row) == DetachParamsCount {
+			if val, ok := snode.Row[0].(*sqlparser.SQLVal); ok {
+				params["name"] = common.BytesToString(val.Val)
+			}
+		}
+	case sqlparser.ReshardStr:
+		params["database"] = session.Schema()
+		params["table"] = sqlparser.String(snode.Table)
+		params["new-table"] = sqlparser.String(snode.NewName)
+	default:
+		return ""
+	}
+	return audit.RedactParams(params)
+}
@@ -0,0 +1,20 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+)
+
+// rewrite used to rewrite the statement in place by the rewrite rules, returns the fired rules.
+func (spanner *Spanner) rewrite(session *driver.Session, node sqlparser.Statement) []string {
+	rewrite := spanner.plugins.PlugRewrite()
+	return rewrite.Rewrite(session.Schema(), session.User(), node)
+}
@@ -0,0 +1,88 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"testing"
+
+	"plugins/rewrite"
+
+	"github.com/fortytw2/leaktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestProxyRewrite(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("select .*", &sqltypes.Result{})
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+	queries := []string{
+		"create database db1",
+		"create table db1.t1(id int, b int) partition by hash(id)",
+		"create table db1.t1_ro(id int, b int) partition by hash(id)",
+	}
+	for _, query := range queries {
+		_, err = client.FetchAll(query, -1)
+		assert.Nil(t, err, query)
+	}
+
+	rules := []*rewrite.Rule{
+		{Name: "redirect-t1", Statement: "select", Database: "db1", Table: "t1", Redirect: "t1_ro"},
+		{Name: "limit-select", Statement: "select", Limit: 1000},
+	}
+	err = proxy.Plugins().PlugRewrite().SetRules(rules)
+	assert.Nil(t, err)
+
+	// Explain shows the fired rules.
+	{
+		qr, err := client.FetchAll("explain select * from db1.t1 where id=1", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(qr.Rows))
+		want := `{
+	"Rewrite": {
+		"Rules": [
+			"redirect-t1",
+			"limit-select"
+		],
+		"Query": "select * from db1.t1_ro as t1 where id = 1 limit 1000"
+	}
+}`
+		assert.Equal(t, want, string(qr.Rows[1][0].Raw()))
+		assert.Contains(t, string(qr.Rows[0][0].Raw()), "select * from db1.t1_ro_0017 as t1 where id = 1 limit 1000")
+
+		qr, err = client.FetchAll("explain select * from db1.t1_ro where id=1 limit 1", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(qr.Rows))
+	}
+
+	// The rewritten query is executed.
+	{
+		_, err := client.FetchAll("select * from db1.t1 where id=1", -1)
+		assert.Nil(t, err)
+
+		err = proxy.Plugins().PlugRewrite().SetRules([]*rewrite.Rule{{Name: "redirect-t1", Database: "db1", Table: "t1", Redirect: "t2"}})
+		assert.Nil(t, err)
+		_, err = client.FetchAll("select * from db1.t1 where id=1", -1)
+		assert.Equal(t, "Table 't2' doesn't exist (errno 1146) (sqlstate 42S02)", err.Error())
+	}
+}
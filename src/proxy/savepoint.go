@@ -0,0 +1,106 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqldb"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+type savepointAction int
+
+const (
+	savepointSet savepointAction = iota
+	savepointRollback
+	savepointRelease
+)
+
+// savepointIdent matches the quoted or unquoted savepoint name.
+const savepointIdent = "(`(?:[^`]|``)+`|[^`\\s]+)"
+
+var (
+	// SAVEPOINT identifier
+	savepointSetRegexp = regexp.MustCompile("(?is)^savepoint\\s+" + savepointIdent + "$")
+	// ROLLBACK [WORK] TO [SAVEPOINT] identifier
+	savepointRollbackRegexp = regexp.MustCompile("(?is)^rollback(?:\\s+work)?\\s+to(?:\\s+savepoint)?\\s+" + savepointIdent + "$")
+	// RELEASE SAVEPOINT identifier
+	savepointReleaseRegexp = regexp.MustCompile("(?is)^release\\s+savepoint\\s+" + savepointIdent + "$")
+)
+
+// savepointStmt tuple.
+type savepointStmt struct {
+	action savepointAction
+	name   string
+}
+
+// parseSavepoint used to parse the savepoint statements which the parser doesn't support,
+// returns nil if the query isn't a savepoint statement.
+// The 'ROLLBACK TO SAVEPOINT' must be checked before the parser, it's parsed as 'ROLLBACK'.
+func parseSavepoint(query string) *savepointStmt {
+	patterns := []struct {
+		action savepointAction
+		re     *regexp.Regexp
+	}{
+		{savepointSet, savepointSetRegexp},
+		{savepointRollback, savepointRollbackRegexp},
+		{savepointRelease, savepointReleaseRegexp},
+	}
+
+	for _, pattern := range patterns {
+		if m := pattern.re.FindStringSubmatch(query); m != nil {
+			name := m[1]
+			if len(name) > 1 && strings.HasPrefix(name, "`") && strings.HasSuffix(name, "`") {
+				name = strings.Replace(name[1:len(name)-1], "``", "`", -1)
+			}
+			return &savepointStmt{action: pattern.action, name: name}
+		}
+	}
+	return nil
+}
+
+// handleSavepoint used to handle the 'SAVEPOINT', 'ROLLBACK TO SAVEPOINT' and 'RELEASE SAVEPOINT'
+// in the multiple-statement transaction.
+func (spanner *Spanner) handleSavepoint(session *driver.Session, query string, stmt *savepointStmt) (*sqltypes.Result, error) {
+	log := spanner.log
+	sessions := spanner.sessions
+	qr := &sqltypes.Result{}
+
+	// Without the transaction, the 'SAVEPOINT' does nothing as MySQL with autocommit.
+	txn := sessions.getTxnSession(session).transaction
+	if !spanner.isTwoPC() || txn == nil {
+		if stmt.action == savepointSet {
+			return qr, nil
+		}
+		return nil, sqldb.NewSQLError1(1305, "42000", "SAVEPOINT %s does not exist", stmt.name)
+	}
+
+	var err error
+	sessions.MultiStmtTxnBinding(session, nil, nil, query)
+	switch stmt.action {
+	case savepointSet:
+		err = txn.Savepoint(stmt.name)
+	case savepointRollback:
+		err = txn.RollbackToSavepoint(stmt.name)
+	case savepointRelease:
+		err = txn.ReleaseSavepoint(stmt.name)
+	default:
+		err = errors.Errorf("unsupported: savepoint.action[%v]", stmt.action)
+	}
+	sessions.MultiStmtTxnUnBinding(session, false)
+	if err != nil {
+		log.Error("spanner.execute.multistmt.txn.savepoint[%s].error:%v", query, err)
+		return nil, err
+	}
+	return qr, nil
+}
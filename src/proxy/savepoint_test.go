@@ -0,0 +1,120 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"testing"
+
+	"github.com/fortytw2/leaktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestProxyParseSavepoint(t *testing.T) {
+	tests := []struct {
+		query string
+		want  *savepointStmt
+	}{
+		{"savepoint sp1", &savepointStmt{savepointSet, "sp1"}},
+		{"SAVEPOINT `s p``1`", &savepointStmt{savepointSet, "s p`1"}},
+		{"rollback to savepoint sp1", &savepointStmt{savepointRollback, "sp1"}},
+		{"ROLLBACK WORK TO sp1", &savepointStmt{savepointRollback, "sp1"}},
+		{"rollback to\tsp1", &savepointStmt{savepointRollback, "sp1"}},
+		{"release savepoint `sp1`", &savepointStmt{savepointRelease, "sp1"}},
+		{"rollback", nil},
+		{"rollback work", nil},
+		{"release sp1", nil},
+		{"savepoint", nil},
+		{"select * from savepoint", nil},
+	}
+	for _, test := range tests {
+		got := parseSavepoint(test.query)
+		assert.Equal(t, test.want, got, test.query)
+	}
+}
+
+func TestProxySavepoint(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("xa .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("insert .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("savepoint .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("rollback to savepoint .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("release savepoint .*", &sqltypes.Result{})
+	}
+
+	// create test table.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		query := "create database test"
+		_, err = client.FetchAll(query, -1)
+		assert.Nil(t, err)
+		query = "create table test.t1(id int, b int) partition by hash(id)"
+		_, err = client.FetchAll(query, -1)
+		assert.Nil(t, err)
+		client.Close()
+	}
+
+	proxy.SetTwoPC(true)
+	client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	// Without the txn.
+	{
+		query := "savepoint sp1"
+		_, err = client.FetchAll(query, -1)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, fakedbs.GetQueryCalledNum("savepoint `sp1`"))
+
+		query = "rollback to savepoint sp1"
+		_, err = client.FetchAll(query, -1)
+		want := "SAVEPOINT sp1 does not exist (errno 1305) (sqlstate 42000)"
+		assert.Equal(t, want, err.Error())
+	}
+
+	querys := []string{
+		"begin",
+		"insert into t1(id, b) values(1, 1)",
+		"savepoint sp1",
+		"insert into t1(id, b) values(2, 2), (3, 3), (4, 4), (5, 5)",
+		"rollback to savepoint sp1",
+		"release savepoint sp1",
+		"commit",
+	}
+	for _, query := range querys {
+		_, err = client.FetchAll(query, -1)
+		assert.Nil(t, err, query)
+	}
+	assert.True(t, fakedbs.GetQueryCalledNum("savepoint `sp1`") > 1)
+	assert.Equal(t, fakedbs.GetQueryCalledNum("savepoint `sp1`"), fakedbs.GetQueryCalledNum("rollback to savepoint `sp1`"))
+	assert.Equal(t, fakedbs.GetQueryCalledNum("savepoint `sp1`"), fakedbs.GetQueryCalledNum("release savepoint `sp1`"))
+
+	// The released savepoint doesn't exist.
+	{
+		_, err = client.FetchAll("begin", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("rollback to sp1", -1)
+		want := "SAVEPOINT sp1 does not exist (errno 1305) (sqlstate 42000)"
+		assert.Equal(t, want, err.Error())
+		_, err = client.FetchAll("rollback", -1)
+		assert.Nil(t, err)
+	}
+}
@@ -13,6 +13,8 @@ import (
 	"time"
 
 	"backend"
+	"xcontext"
+	"xtrace"
 
 	"github.com/xelabs/go-mysqlstack/driver"
 	"github.com/xelabs/go-mysqlstack/sqlparser"
@@ -34,7 +36,10 @@ type session struct {
 	session      *driver.Session
 	timestamp    int64
 	capabilities bitmask
+	maxExecTime  int // The max_execution_time session variable in millisecond, 0 means not set.
 	transaction  backend.Transaction
+	stats        *xcontext.ExecStats
+	span         *xtrace.Span
 }
 
 func (s *session) setStreamingFetchVar(r bool) {
@@ -49,12 +54,21 @@ func (s *session) getStreamingFetchVar() bool {
 	return s.capabilities&cap_streaming_fetch != 0
 }
 
+func (s *session) setMaxExecutionTimeVar(ms int) {
+	s.maxExecTime = ms
+}
+
+func (s *session) getMaxExecutionTimeVar() int {
+	return s.maxExecTime
+}
+
 func newSession(log *xlog.Log, s *driver.Session) *session {
 	log.Debug("session[%v].created", s.ID())
 	return &session{
 		log:       log,
 		session:   s,
 		timestamp: time.Now().Unix(),
+		stats:     xcontext.NewExecStats(),
 	}
 }
 
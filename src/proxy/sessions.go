@@ -14,6 +14,8 @@ import (
 	"time"
 
 	"backend"
+	"xcontext"
+	"xtrace"
 
 	"github.com/xelabs/go-mysqlstack/driver"
 	"github.com/xelabs/go-mysqlstack/sqlparser"
@@ -80,6 +82,23 @@ func (ss *Sessions) Kill(id uint32, reason string) {
 	session.close()
 }
 
+// txnID returns the id of the transaction bound to the session, 0 if none.
+func (ss *Sessions) txnID(id uint32) uint64 {
+	ss.mu.RLock()
+	session, ok := ss.sessions[id]
+	ss.mu.RUnlock()
+	if !ok {
+		return 0
+	}
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if session.transaction == nil {
+		return 0
+	}
+	return session.transaction.TxID()
+}
+
 // Reaches used to check whether the sessions count reaches(>=) the quota.
 func (ss *Sessions) Reaches(quota int) bool {
 	ss.mu.RLock()
@@ -87,6 +106,19 @@ func (ss *Sessions) Reaches(quota int) bool {
 	return (len(ss.sessions) >= quota)
 }
 
+// UserCount returns the count of the sessions of the user.
+func (ss *Sessions) UserCount(user string) int {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+	count := 0
+	for _, v := range ss.sessions {
+		if v.session.User() == user {
+			count++
+		}
+	}
+	return count
+}
+
 // getTxnSession used to get current connection session.
 func (ss *Sessions) getTxnSession(session *driver.Session) *session {
 	ss.mu.RLock()
@@ -101,6 +133,50 @@ func (ss *Sessions) getSession(id uint32) *session {
 	return ss.sessions[id]
 }
 
+// execStats returns the backend querys stats of the session, nil if the session isn't found.
+func (ss *Sessions) execStats(s *driver.Session) *xcontext.ExecStats {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+	session, ok := ss.sessions[s.ID()]
+	if !ok {
+		return nil
+	}
+	return session.stats
+}
+
+// setSpan used to set the span of the current statement, the transaction of the session is traced with it.
+func (ss *Sessions) setSpan(s *driver.Session, span *xtrace.Span) {
+	ss.mu.RLock()
+	session, ok := ss.sessions[s.ID()]
+	if !ok {
+		ss.mu.RUnlock()
+		return
+	}
+	ss.mu.RUnlock()
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	session.span = span
+	if session.transaction != nil {
+		session.transaction.SetSpan(span)
+	}
+}
+
+// span returns the span of the current statement, nil if the statement isn't traced.
+func (ss *Sessions) span(s *driver.Session) *xtrace.Span {
+	ss.mu.RLock()
+	session, ok := ss.sessions[s.ID()]
+	if !ok {
+		ss.mu.RUnlock()
+		return nil
+	}
+	ss.mu.RUnlock()
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	return session.span
+}
+
 // TxnBinding used to bind txn to the session.
 func (ss *Sessions) TxnBinding(s *driver.Session, txn backend.Transaction, node sqlparser.Statement, query string) {
 
@@ -123,6 +199,8 @@ func (ss *Sessions) TxnBinding(s *driver.Session, txn backend.Transaction, node
 
 	// Bind sid to txn.
 	txn.SetSessionID(s.ID())
+	txn.SetExecStats(session.stats)
+	txn.SetSpan(session.span)
 	session.transaction = txn
 	session.timestamp = time.Now().Unix()
 }
@@ -168,6 +246,8 @@ func (ss *Sessions) MultiStmtTxnBinding(s *driver.Session, txn backend.Transacti
 	if txn != nil {
 		// Bind sid to txn.
 		txn.SetSessionID(s.ID())
+		txn.SetExecStats(session.stats)
+		txn.SetSpan(session.span)
 		session.transaction = txn
 	}
 	session.timestamp = time.Now().Unix()
@@ -227,6 +307,7 @@ type SessionInfo struct {
 	Info         string
 	RowsSent     uint64
 	RowsExamined uint64
+	TxnID        uint64
 }
 
 // Sort by id.
@@ -266,6 +347,7 @@ func (ss *Sessions) Snapshot() []SessionInfo {
 		if v.transaction != nil {
 			// https://dev.mysql.com/doc/refman/5.7/en/general-thread-states.html about state.
 			info.State = sessionStateInTransaction
+			info.TxnID = v.transaction.TxID()
 		}
 
 		infos = append(infos, info)
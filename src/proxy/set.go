@@ -10,6 +10,7 @@ package proxy
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/xelabs/go-mysqlstack/driver"
@@ -18,8 +19,9 @@ import (
 )
 
 const (
-	var_mysql_autocommit      = "autocommit"
-	var_radon_streaming_fetch = "radon_streaming_fetch"
+	var_mysql_autocommit         = "autocommit"
+	var_mysql_max_execution_time = "max_execution_time"
+	var_radon_streaming_fetch    = "radon_streaming_fetch"
 )
 
 // handleSet used to handle the SET command.
@@ -57,6 +59,23 @@ func (spanner *Spanner) handleSet(session *driver.Session, query string, node *s
 				}
 			}
 
+		case var_mysql_max_execution_time:
+			switch expr := expr.Val.(*sqlparser.OptVal).Value.(type) {
+			case *sqlparser.SQLVal:
+				switch expr.Type {
+				case sqlparser.IntVal:
+					ms, err := strconv.ParseUint(string(expr.Val), 10, 32)
+					if err != nil {
+						return nil, fmt.Errorf("Invalid value: %v", sqlparser.String(expr))
+					}
+					txSession.setMaxExecutionTimeVar(int(ms))
+				default:
+					return nil, fmt.Errorf("Invalid value type: %v", sqlparser.String(expr))
+				}
+			default:
+				return nil, fmt.Errorf("Invalid value type: %v", sqlparser.String(expr))
+			}
+
 		case var_mysql_autocommit:
 			var autocommit = true
 
@@ -75,6 +75,16 @@ func TestProxySet(t *testing.T) {
 			_, err := client.FetchAll(query, -1)
 			assert.NotNil(t, err)
 		}
+		{
+			query := "set max_execution_time=1000"
+			_, err := client.FetchAll(query, -1)
+			assert.Nil(t, err)
+		}
+		{
+			query := "set @@SESSION.max_execution_time='1000'"
+			_, err := client.FetchAll(query, -1)
+			assert.NotNil(t, err)
+		}
 		{
 			query := "SET SESSION TRANSACTION ISOLATION LEVEL SERIALIZABLE, READ WRITE"
 			_, err := client.FetchAll(query, -1)
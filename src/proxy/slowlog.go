@@ -0,0 +1,57 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"time"
+
+	"slowlog"
+	"xcontext"
+
+	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// slowLog used to write the slow query and its backend sub-querys to the slow log.
+func (spanner *Spanner) slowLog(session *driver.Session, query string, timeStart time.Time, queryTime time.Duration, qr *sqltypes.Result, execStats *xcontext.ExecStats, err error) {
+	if !spanner.slowlog.Enabled() {
+		return
+	}
+
+	e := &slowlog.Event{
+		Start:        timeStart,
+		QueryTime:    queryTime.Seconds(),
+		User:         session.User(),
+		Host:         session.Addr(),
+		DB:           session.Schema(),
+		ThreadID:     session.ID(),
+		Query:        query,
+		RowsExamined: execStats.Rows(),
+		Fanout:       execStats.Querys(),
+		PlanType:     execStats.PlanType(),
+	}
+	if qr != nil {
+		e.RowsSent = uint64(len(qr.Rows))
+	}
+	if err != nil {
+		e.Status = 1
+		e.Error = err.Error()
+	}
+	for _, prof := range execStats.QueryProfiles() {
+		e.Backends = append(e.Backends, &slowlog.Backend{
+			Backend:   prof.Backend,
+			Range:     prof.Range,
+			Query:     prof.Query,
+			QueryTime: prof.Latency.Seconds(),
+			Rows:      prof.Rows,
+			Error:     prof.Error,
+		})
+	}
+	spanner.slowlog.LogEvent(e)
+}
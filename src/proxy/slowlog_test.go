@@ -0,0 +1,99 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"fakedb"
+	"slowlog"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestProxySlowLog(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	tmpDir := fakedb.GetTmpDir("", "radon_slowlog_", log)
+	defer os.RemoveAll(tmpDir)
+	conf := MockDefaultConfig()
+	conf.SlowLog.Enable = true
+	conf.SlowLog.Format = slowlog.FormatJSON
+	conf.SlowLog.LogDir = tmpDir
+	fakedbs, proxy, cleanup := MockProxy1(log, conf)
+	address := proxy.Address()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("select .*", selectResult)
+	}
+
+	// create database and table.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		_, err = client.FetchAll("create database test", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("create table test.t1(id int, b int) partition by hash(id)", -1)
+		assert.Nil(t, err)
+		client.Quit()
+	}
+
+	// Every query is slow.
+	proxy.SetLongQueryTime(0)
+	client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+	assert.Nil(t, err)
+	_, err = client.FetchAll("select * from t1 where id=1", -1)
+	assert.Nil(t, err)
+	client.Quit()
+
+	// Close the proxy to flush the slow log.
+	cleanup()
+
+	files, err := ioutil.ReadDir(tmpDir)
+	assert.Nil(t, err)
+	var lines []string
+	for _, file := range files {
+		data, err := ioutil.ReadFile(filepath.Join(tmpDir, file.Name()))
+		assert.Nil(t, err)
+		lines = append(lines, strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")...)
+	}
+
+	var got *slowlog.Event
+	for _, line := range lines {
+		e := &slowlog.Event{}
+		err := json.Unmarshal([]byte(line), e)
+		assert.Nil(t, err)
+		if e.Query == "select * from t1 where id=1" {
+			got = e
+		}
+	}
+	assert.NotNil(t, got)
+	rows := uint64(len(selectResult.Rows))
+	assert.Equal(t, "mock", got.User)
+	assert.Equal(t, "test", got.DB)
+	assert.Equal(t, rows, got.RowsSent)
+	assert.Equal(t, rows, got.RowsExamined)
+	assert.Equal(t, uint64(1), got.Fanout)
+	assert.Equal(t, "PlanTypeSelect", got.PlanType)
+	assert.Equal(t, 1, len(got.Backends))
+	assert.Equal(t, "backend2", got.Backends[0].Backend)
+	assert.Equal(t, "[2278-2457)", got.Backends[0].Range)
+	assert.Equal(t, "select * from test.t1_0017 as t1 where id = 1", got.Backends[0].Query)
+	assert.Equal(t, rows, got.Backends[0].Rows)
+}
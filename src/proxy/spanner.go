@@ -14,10 +14,13 @@ import (
 	"config"
 	"monitor"
 	"plugins"
+	"plugins/authentication"
 	"router"
+	"slowlog"
 	"sync"
 	"xbase"
 	"xbase/sync2"
+	"xtrace"
 
 	"github.com/xelabs/go-mysqlstack/driver"
 	"github.com/xelabs/go-mysqlstack/xlog"
@@ -33,12 +36,18 @@ type Spanner struct {
 	sessions      *Sessions
 	iptable       *IPTable
 	throttle      *xbase.Throttle
+	limits        *UserLimits
+	workload      *Workload
+	digests       *Digests
+	slowlog       *slowlog.SlowLog
+	tracer        *xtrace.Tracer
 	plugins       *plugins.Plugin
 	diskChecker   *DiskCheck
 	manager       *Manager
 	readonly      sync2.AtomicBool
 	mu            sync.RWMutex
 	serverVersion string
+	tlsEnabled    bool
 }
 
 // NewSpanner creates a new spanner.
@@ -53,6 +62,8 @@ func NewSpanner(log *xlog.Log, conf *config.Config,
 		scatter:       scatter,
 		sessions:      sessions,
 		throttle:      throttle,
+		limits:        NewUserLimits(),
+		digests:       newDigests(conf.Proxy.StatementDigests),
 		plugins:       plugins,
 		serverVersion: serverVersion,
 	}
@@ -69,11 +80,32 @@ func (spanner *Spanner) Init() error {
 	}
 	spanner.diskChecker = diskChecker
 
+	workload, err := NewWorkload(log, conf.Workload)
+	if err != nil {
+		return err
+	}
+	spanner.workload = workload
+
 	mgr := NewManager(log, spanner.sessions, conf.Proxy)
 	if err := mgr.Init(); err != nil {
 		return err
 	}
 	spanner.manager = mgr
+
+	slowlog := slowlog.NewSlowLog(log, conf.SlowLog)
+	if err := slowlog.Init(); err != nil {
+		return err
+	}
+	spanner.slowlog = slowlog
+
+	tracer := xtrace.NewTracer(log, conf.Trace)
+	if err := tracer.Init(); err != nil {
+		return err
+	}
+	spanner.tracer = tracer
+
+	// The mysql.user of the backends.
+	spanner.plugins.PlugAuthentication().Register(authentication.MySQLAuthenticator, newMySQLAuthenticator(spanner))
 	return nil
 }
 
@@ -81,6 +113,8 @@ func (spanner *Spanner) Init() error {
 func (spanner *Spanner) Close() error {
 	spanner.diskChecker.Close()
 	spanner.manager.Close()
+	spanner.slowlog.Close()
+	spanner.tracer.Close()
 	spanner.log.Info("spanner.closed...")
 	return nil
 }
@@ -152,6 +186,14 @@ func (spanner *Spanner) isAutocommitFalseIsTxn() bool {
 	return spanner.conf.Proxy.AutocommitFalseIsTxn
 }
 
+func (spanner *Spanner) isRequireSecureTransport() bool {
+	return spanner.conf.Proxy.RequireSecureTransport
+}
+
+func (spanner *Spanner) isLocalRootLogin() bool {
+	return spanner.conf.Auth == nil || !spanner.conf.Auth.DisableLocalRootLogin
+}
+
 func (spanner *Spanner) isLowerCaseTableNames() bool {
 	if spanner.conf.Proxy.LowerCaseTableNames == 0 {
 		return false
@@ -0,0 +1,77 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"regexp"
+	"strconv"
+
+	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+)
+
+var (
+	maxExecutionTimeRegexp = regexp.MustCompile(`(?is)^/\*\+.*\bmax_execution_time\s*\(\s*(\d+)\s*\).*\*/$`)
+)
+
+// maxExecutionTimeHint returns the /*+ MAX_EXECUTION_TIME(ms) */ of the SELECT, 0 if none.
+// For the UNION, the hint is on the first SELECT.
+func maxExecutionTimeHint(node sqlparser.SelectStatement) int {
+	switch node := node.(type) {
+	case *sqlparser.Select:
+		for _, comment := range node.Comments {
+			if m := maxExecutionTimeRegexp.FindSubmatch(comment); m != nil {
+				ms, err := strconv.ParseUint(string(m[1]), 10, 32)
+				if err != nil {
+					return 0
+				}
+				return int(ms)
+			}
+		}
+	case *sqlparser.Union:
+		return maxExecutionTimeHint(node.Left)
+	case *sqlparser.ParenSelect:
+		return maxExecutionTimeHint(node.Select)
+	}
+	return 0
+}
+
+// statementTimeout returns the timeout(in millisecond) of the statement.
+// Same as MySQL, the max execution time only works on the read-only SELECT,
+// the MAX_EXECUTION_TIME hint comes first, then the max_execution_time session variable,
+// then the default timeout. 0 of the hint or the variable means not set.
+// The hint and the variable are capped by the default timeout if it's limited, the users can't exceed the global limit.
+func (spanner *Spanner) statementTimeout(session *driver.Session, node sqlparser.Statement, timeout int) int {
+	var sel sqlparser.SelectStatement
+	switch node := node.(type) {
+	case *sqlparser.Select:
+		if node.Lock != "" {
+			return timeout
+		}
+		sel = node
+	case *sqlparser.Union:
+		if node.Lock != "" {
+			return timeout
+		}
+		sel = node
+	default:
+		return timeout
+	}
+
+	ms := maxExecutionTimeHint(sel)
+	if ms == 0 {
+		if txSession := spanner.sessions.getTxnSession(session); txSession != nil {
+			ms = txSession.getMaxExecutionTimeVar()
+		}
+	}
+	if ms <= 0 || (timeout > 0 && ms > timeout) {
+		return timeout
+	}
+	return ms
+}
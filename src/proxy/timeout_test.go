@@ -0,0 +1,141 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestMaxExecutionTimeHint(t *testing.T) {
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"select * from t1", 0},
+		{"select /*+ MAX_EXECUTION_TIME(1000) */ * from t1", 1000},
+		{"select /*+ max_execution_time( 10 ) */ * from t1", 10},
+		{"select /*+ streaming MAX_EXECUTION_TIME(20) */ * from t1", 20},
+		{"select /* MAX_EXECUTION_TIME(1000) */ * from t1", 0},
+		{"select /*+ MAX_EXECUTION_TIME(99999999999) */ * from t1", 0},
+		{"select /*+ MAX_EXECUTION_TIME(30) */ * from t1 union select * from t2", 30},
+		{"(select /*+ MAX_EXECUTION_TIME(40) */ * from t1) union select * from t2", 40},
+	}
+	for _, test := range tests {
+		node, err := sqlparser.Parse(test.query)
+		assert.Nil(t, err, test.query)
+		assert.Equal(t, test.want, maxExecutionTimeHint(node.(sqlparser.SelectStatement)), test.query)
+	}
+}
+
+func TestProxyMaxExecutionTime(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("insert .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("xa .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("select .*", &sqltypes.Result{})
+		fakedbs.AddQueryDelay("select * from test.t1_0002 as t1", &sqltypes.Result{}, 1000)
+		fakedbs.AddQueryDelay("select /*+ MAX_EXECUTION_TIME(100) */ * from test.t1_0002 as t1", &sqltypes.Result{}, 1000)
+		fakedbs.AddQueryDelay("select /*+ MAX_EXECUTION_TIME(99999) */ * from test.t1_0002 as t1", &sqltypes.Result{}, 1000)
+	}
+
+	// create test table.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		_, err = client.FetchAll("create database test", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("create table test.t1(id int, b int) partition by hash(id)", -1)
+		assert.Nil(t, err)
+		client.Quit()
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+	assert.Nil(t, err)
+	defer client.Quit()
+
+	// Capped by the query-timeout.
+	{
+		proxy.SetQueryTimeout(300)
+		_, err = client.FetchAll("set max_execution_time=4294967295", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("select * from t1", -1)
+		assert.Equal(t, "Query execution was interrupted, timeout[300ms] exceeded (errno 1105) (sqlstate HY000)", err.Error())
+		_, err = client.FetchAll("select /*+ MAX_EXECUTION_TIME(99999) */ * from t1", -1)
+		assert.Equal(t, "Query execution was interrupted, timeout[300ms] exceeded (errno 1105) (sqlstate HY000)", err.Error())
+		_, err = client.FetchAll("select /*+ MAX_EXECUTION_TIME(100) */ * from t1", -1)
+		assert.Equal(t, "Query execution was interrupted, timeout[100ms] exceeded (errno 1105) (sqlstate HY000)", err.Error())
+		proxy.SetQueryTimeout(5 * 60 * 1000)
+		_, err = client.FetchAll("set max_execution_time=0", -1)
+		assert.Nil(t, err)
+	}
+
+	// The hint.
+	{
+		_, err = client.FetchAll("select /*+ MAX_EXECUTION_TIME(100) */ * from t1", -1)
+		assert.Equal(t, "Query execution was interrupted, timeout[100ms] exceeded (errno 1105) (sqlstate HY000)", err.Error())
+	}
+
+	// The session variable.
+	{
+		_, err = client.FetchAll("set max_execution_time=200", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("select * from t1", -1)
+		assert.Equal(t, "Query execution was interrupted, timeout[200ms] exceeded (errno 1105) (sqlstate HY000)", err.Error())
+
+		// The hint overrides the session variable.
+		_, err = client.FetchAll("select /*+ MAX_EXECUTION_TIME(100) */ * from t1", -1)
+		assert.Equal(t, "Query execution was interrupted, timeout[100ms] exceeded (errno 1105) (sqlstate HY000)", err.Error())
+
+		// Not for the write.
+		_, err = client.FetchAll("insert into t1(id, b) values(1, 1)", -1)
+		assert.Nil(t, err)
+	}
+
+	// The single statement txn with 2pc.
+	{
+		proxy.SetTwoPC(true)
+		_, err = client.FetchAll("select /*+ MAX_EXECUTION_TIME(100) */ * from t1", -1)
+		assert.Equal(t, "Query execution was interrupted, timeout[100ms] exceeded (errno 1105) (sqlstate HY000)", err.Error())
+		proxy.SetTwoPC(false)
+	}
+
+	// 0 means using the query-timeout.
+	{
+		_, err = client.FetchAll("set max_execution_time=0", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("select * from t1", -1)
+		assert.Nil(t, err)
+	}
+
+	// The multiple statements txn, the backend connection is killed by the timeout.
+	{
+		proxy.SetTwoPC(true)
+		txnClient, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+		assert.Nil(t, err)
+		_, err = txnClient.FetchAll("begin", -1)
+		assert.Nil(t, err)
+		_, err = txnClient.FetchAll("select /*+ MAX_EXECUTION_TIME(100) */ * from t1", -1)
+		assert.Equal(t, "Query execution was interrupted, timeout[100ms] exceeded (errno 1105) (sqlstate HY000)", err.Error())
+		txnClient.Quit()
+		proxy.SetTwoPC(false)
+	}
+}
@@ -0,0 +1,58 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"time"
+
+	"xbase"
+	"xtrace"
+
+	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// traceparent returns the trace context of the traceparent in the SQL comment,
+// nil if the tracing is disabled or the query has no traceparent.
+func (spanner *Spanner) traceparent(query string) *xtrace.SpanContext {
+	if !spanner.tracer.Enabled() {
+		return nil
+	}
+	return xtrace.ParseTraceparent(query)
+}
+
+// startSpan used to start the span of the statement at the time it's received, the parsing is traced
+// as the child span, and the plan building, execution and backend querys are traced by the session span.
+func (spanner *Spanner) startSpan(session *driver.Session, query string, parent *xtrace.SpanContext, timeStart, parseStart, parseEnd time.Time) *xtrace.Span {
+	span := spanner.tracer.StartSpan("proxy.ComQuery", parent, timeStart)
+	if span == nil {
+		return nil
+	}
+	span.SetAttribute("db.system", "mysql")
+	span.SetAttribute("db.user", session.User())
+	span.SetAttribute("db.name", session.Schema())
+	span.SetAttribute("db.statement", xbase.TruncateQuery(query, 1024))
+	span.SetAttribute("radon.session.id", session.ID())
+	span.StartChildAt("sqlparser.Parse", parseStart).EndAt(parseEnd)
+	spanner.sessions.setSpan(session, span)
+	return span
+}
+
+// endSpan used to end the span of the statement and unset it from the session.
+func (spanner *Spanner) endSpan(session *driver.Session, span *xtrace.Span, qr *sqltypes.Result, err error) {
+	if span == nil {
+		return
+	}
+	spanner.sessions.setSpan(session, nil)
+	if qr != nil {
+		span.SetAttribute("db.rows", len(qr.Rows))
+	}
+	span.SetError(err)
+	span.End()
+}
@@ -0,0 +1,190 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+// traceSpan is the span decoded from the OTLP JSON request.
+type traceSpan struct {
+	TraceID      string `json:"traceId"`
+	SpanID       string `json:"spanId"`
+	ParentSpanID string `json:"parentSpanId"`
+	Name         string `json:"name"`
+	Attributes   []struct {
+		Key   string                 `json:"key"`
+		Value map[string]interface{} `json:"value"`
+	} `json:"attributes"`
+}
+
+func (s *traceSpan) attribute(key string) interface{} {
+	for _, attr := range s.Attributes {
+		if attr.Key == key {
+			for _, v := range attr.Value {
+				return v
+			}
+		}
+	}
+	return nil
+}
+
+// traceCollector is the OTLP/HTTP collector stub.
+type traceCollector struct {
+	mu    sync.Mutex
+	spans []*traceSpan
+}
+
+func (c *traceCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []*traceSpan `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	body, _ := ioutil.ReadAll(r.Body)
+	if err := json.Unmarshal(body, &req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, rs := range req.ResourceSpans {
+		for _, ss := range rs.ScopeSpans {
+			c.spans = append(c.spans, ss.Spans...)
+		}
+	}
+}
+
+// trace returns the spans of the trace.
+func (c *traceCollector) trace(traceID string) []*traceSpan {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var spans []*traceSpan
+	for _, span := range c.spans {
+		if span.TraceID == traceID {
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
+
+func TestProxyTrace(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	collector := &traceCollector{}
+	server := httptest.NewServer(collector)
+	defer server.Close()
+
+	conf := MockDefaultConfig()
+	conf.Trace.Enable = true
+	conf.Trace.Endpoint = server.URL + "/v1/traces"
+	conf.Trace.SampleRatio = 0
+	fakedbs, proxy, cleanup := MockProxy1(log, conf)
+	address := proxy.Address()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("select .*", selectResult)
+		fakedbs.AddQueryPattern("insert .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("xa .*", &sqltypes.Result{})
+	}
+
+	// create database and table.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		_, err = client.FetchAll("create database test", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("create table test.t1(id int, b int) partition by hash(id)", -1)
+		assert.Nil(t, err)
+		client.Quit()
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+	assert.Nil(t, err)
+	_, err = client.FetchAll("select * from t1 where id=1 /* traceparent='00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01' */", -1)
+	assert.Nil(t, err)
+	// Not sampled.
+	_, err = client.FetchAll("select * from t1 where id=1 /* traceparent='00-1af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-00' */", -1)
+	assert.Nil(t, err)
+	// 2PC.
+	proxy.conf.Proxy.TwopcEnable = true
+	_, err = client.FetchAll("insert /* traceparent='00-2af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01' */ into t1(id, b) values(1,2),(3,4)", -1)
+	assert.Nil(t, err)
+	client.Quit()
+
+	// Close the proxy to flush the spans.
+	cleanup()
+
+	// select.
+	{
+		spans := collector.trace("0af7651916cd43dd8448eb211c80319c")
+		byName := make(map[string]*traceSpan)
+		for _, span := range spans {
+			byName[span.Name] = span
+		}
+		assert.Equal(t, 6, len(spans))
+
+		root := byName["proxy.ComQuery"]
+		assert.Equal(t, "b7ad6b7169203331", root.ParentSpanID)
+		assert.Equal(t, "select * from t1 where id=1 /* traceparent='00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01' */", root.attribute("db.statement"))
+		assert.Equal(t, "test", root.attribute("db.name"))
+		assert.Equal(t, root.SpanID, byName["sqlparser.Parse"].ParentSpanID)
+		assert.Equal(t, root.SpanID, byName["optimizer.BuildPlanTree"].ParentSpanID)
+		assert.Equal(t, root.SpanID, byName["executor.Execute"].ParentSpanID)
+		assert.Equal(t, byName["executor.Execute"].SpanID, byName["backend.Txn.execute"].ParentSpanID)
+
+		query := byName["backend.query"]
+		assert.Equal(t, byName["backend.Txn.execute"].SpanID, query.ParentSpanID)
+		assert.Equal(t, "backend2", query.attribute("radon.backend"))
+		assert.Equal(t, "[2278-2457)", query.attribute("radon.range"))
+		assert.Equal(t, "select * from test.t1_0017 as t1 where id = 1", query.attribute("db.statement"))
+	}
+
+	// Not sampled.
+	{
+		spans := collector.trace("1af7651916cd43dd8448eb211c80319c")
+		assert.Equal(t, 0, len(spans))
+	}
+
+	// 2PC.
+	{
+		spans := collector.trace("2af7651916cd43dd8448eb211c80319c")
+		var root *traceSpan
+		names := make(map[string]int)
+		for _, span := range spans {
+			names[span.Name]++
+			if span.Name == "proxy.ComQuery" {
+				root = span
+			}
+		}
+		assert.Equal(t, 2, names["backend.query"])
+		for _, name := range []string{"backend.Txn.xa.start", "backend.Txn.xa.end", "backend.Txn.xa.prepare", "backend.Txn.xa.commit"} {
+			assert.Equal(t, 1, names[name], name)
+		}
+		for _, span := range spans {
+			if span.Name == "backend.Txn.xa.commit" {
+				assert.Equal(t, root.SpanID, span.ParentSpanID)
+			}
+		}
+	}
+}
@@ -0,0 +1,724 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"audit"
+	"plugins/authentication"
+	"plugins/privilege"
+	"xbase"
+
+	"github.com/pkg/errors"
+	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqldb"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/common"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+type userAction int
+
+var (
+	// The password after 'IDENTIFIED [WITH plugin] BY [PASSWORD]', the quoted or the unterminated or the bare word.
+	userPasswordRegexp = regexp.MustCompile(`(?is)(\bIDENTIFIED\s+(?:WITH\s+\S+\s+)?BY\s+(?:PASSWORD\s+)?)` +
+		`('(?:[^'\\]|\\.|'')*'|"(?:[^"\\]|\\.|"")*"|['"].*|[^\s,;]+)`)
+)
+
+const (
+	userCreate userAction = iota
+	userAlter
+	userDrop
+	roleCreate
+	roleDrop
+	privilegeGrant
+	privilegeRevoke
+	roleGrant
+	roleRevoke
+	grantsShow
+)
+
+// userSpec tuple, the account with the optional 'IDENTIFIED [WITH plugin] BY password'.
+type userSpec struct {
+	name       string
+	identified bool
+	plugin     string
+	password   string
+}
+
+// grantItem tuple, the privilege with the optional column list, or the role.
+type grantItem struct {
+	name    string
+	columns []string
+}
+
+// userStmt tuple, the account management statements which the parser doesn't support.
+// The database is empty for the table of the current database.
+type userStmt struct {
+	action           userAction
+	ifExists         bool
+	users            []userSpec
+	names            []string
+	privileges       []string
+	columnPrivileges []grantItem
+	database         string
+	table            string
+	roles            []string
+	grantOption      bool
+	resources        map[string]int
+}
+
+// userToken tuple.
+type userToken struct {
+	value  string
+	quoted bool
+}
+
+// userParser tuple, parses the account statements by the tokens.
+type userParser struct {
+	tokens []userToken
+	pos    int
+}
+
+// tokenizeUser used to split the query into the words, the quoted strings and the symbols.
+func tokenizeUser(query string) ([]userToken, error) {
+	var tokens []userToken
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '\'' || c == '"' || c == '`':
+			var buf []byte
+			j := i + 1
+			for ; j < len(query); j++ {
+				if query[j] == c {
+					// The doubled quote is the quote itself.
+					if j+1 < len(query) && query[j+1] == c {
+						buf = append(buf, c)
+						j++
+						continue
+					}
+					break
+				}
+				if query[j] == '\\' && c != '`' && j+1 < len(query) {
+					j++
+				}
+				buf = append(buf, query[j])
+			}
+			if j >= len(query) {
+				return nil, errors.Errorf("unterminated.quoted.string.at[%d]", i)
+			}
+			tokens = append(tokens, userToken{value: string(buf), quoted: true})
+			i = j + 1
+		case strings.IndexByte(",@.*();=", c) >= 0:
+			tokens = append(tokens, userToken{value: string(c)})
+			i++
+		default:
+			j := i
+			for ; j < len(query); j++ {
+				if strings.IndexByte(" \t\r\n'\"`,@.*();=", query[j]) >= 0 {
+					break
+				}
+			}
+			tokens = append(tokens, userToken{value: query[i:j]})
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+// peekKeyword returns true if the next tokens are the unquoted keywords.
+func (p *userParser) peekKeyword(keywords ...string) bool {
+	if p.pos+len(keywords) > len(p.tokens) {
+		return false
+	}
+	for i, keyword := range keywords {
+		token := p.tokens[p.pos+i]
+		if token.quoted || !strings.EqualFold(token.value, keyword) {
+			return false
+		}
+	}
+	return true
+}
+
+// acceptKeyword used to consume the keywords if they are the next tokens.
+func (p *userParser) acceptKeyword(keywords ...string) bool {
+	if !p.peekKeyword(keywords...) {
+		return false
+	}
+	p.pos += len(keywords)
+	return true
+}
+
+func (p *userParser) expectKeyword(keywords ...string) error {
+	if !p.acceptKeyword(keywords...) {
+		return p.syntaxError()
+	}
+	return nil
+}
+
+func (p *userParser) eof() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *userParser) syntaxError() error {
+	near := ""
+	if !p.eof() {
+		near = p.tokens[p.pos].value
+	}
+	return sqldb.NewSQLError1(1064, "42000", "You have an error in your SQL syntax; check the manual that corresponds to your MySQL server version for the right syntax to use near '%s'", near)
+}
+
+// name used to read the identifier or the quoted string.
+func (p *userParser) name() (string, error) {
+	if p.eof() {
+		return "", p.syntaxError()
+	}
+	token := p.tokens[p.pos]
+	if !token.quoted && strings.IndexByte(",@.*();=", token.value[0]) >= 0 {
+		return "", p.syntaxError()
+	}
+	p.pos++
+	return token.value, nil
+}
+
+// account used to read the 'user[@host]', only the host '%' is supported.
+func (p *userParser) account() (string, error) {
+	name, err := p.name()
+	if err != nil {
+		return "", err
+	}
+	if p.acceptKeyword("@") {
+		host, err := p.name()
+		if err != nil {
+			return "", err
+		}
+		if host != "%" {
+			return "", sqldb.NewSQLErrorf(sqldb.ER_UNKNOWN_ERROR, "unsupported: the host[%s] of the account '%s', only the '%%' is supported", host, name)
+		}
+	}
+	return name, nil
+}
+
+// accounts used to read the account list.
+func (p *userParser) accounts() ([]string, error) {
+	var names []string
+	for {
+		name, err := p.account()
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+		if !p.acceptKeyword(",") {
+			return names, nil
+		}
+	}
+}
+
+// userSpecs used to read the 'user [IDENTIFIED [WITH plugin] BY 'password']' list.
+func (p *userParser) userSpecs() ([]userSpec, error) {
+	var specs []userSpec
+	for {
+		name, err := p.account()
+		if err != nil {
+			return nil, err
+		}
+		spec := userSpec{name: name}
+		if p.acceptKeyword("IDENTIFIED") {
+			spec.identified = true
+			if p.acceptKeyword("WITH") {
+				if spec.plugin, err = p.name(); err != nil {
+					return nil, err
+				}
+			}
+			if err := p.expectKeyword("BY"); err != nil {
+				return nil, err
+			}
+			if p.eof() || !p.tokens[p.pos].quoted {
+				return nil, p.syntaxError()
+			}
+			spec.password = p.tokens[p.pos].value
+			p.pos++
+		}
+		specs = append(specs, spec)
+		if !p.acceptKeyword(",") {
+			return specs, nil
+		}
+	}
+}
+
+// resourceOptions used to read the 'WITH resource_option [resource_option] ...', the option is
+// MAX_USER_CONNECTIONS, MAX_QUERIES_PER_SECOND, MAX_QUERIES_PER_HOUR, MAX_SCATTER_QUERIES or MAX_ROWS.
+func (p *userParser) resourceOptions() (map[string]int, error) {
+	if !p.acceptKeyword("WITH") {
+		return nil, nil
+	}
+	options := make(map[string]int)
+	for !p.eof() {
+		option := strings.ToUpper(p.tokens[p.pos].value)
+		if p.tokens[p.pos].quoted || (&privilege.Resources{}).Set(option, 0) != nil {
+			return nil, p.syntaxError()
+		}
+		p.pos++
+		if p.eof() {
+			return nil, p.syntaxError()
+		}
+		value, err := strconv.Atoi(p.tokens[p.pos].value)
+		if err != nil || value < 0 || p.tokens[p.pos].quoted {
+			return nil, p.syntaxError()
+		}
+		p.pos++
+		options[option] = value
+	}
+	if len(options) == 0 {
+		return nil, p.syntaxError()
+	}
+	return options, nil
+}
+
+// privilegeLevel used to read the 'ON [TABLE] {*.* | * | db.* | db.tbl | tbl}', returns the database and table.
+func (p *userParser) privilegeLevel() (string, string, error) {
+	if err := p.expectKeyword("ON"); err != nil {
+		return "", "", err
+	}
+	if p.peekKeyword("TABLE") && !p.peekKeyword("TABLE", ".") && !p.peekKeyword("TABLE", "TO") && !p.peekKeyword("TABLE", "FROM") {
+		p.pos++
+	}
+	if p.acceptKeyword("*") {
+		if p.acceptKeyword(".") {
+			if err := p.expectKeyword("*"); err != nil {
+				return "", "", err
+			}
+		}
+		return privilege.AllDatabases, "", nil
+	}
+	name, err := p.name()
+	if err != nil {
+		return "", "", err
+	}
+	if !p.acceptKeyword(".") {
+		return "", name, nil
+	}
+	if p.acceptKeyword("*") {
+		return name, "", nil
+	}
+	table, err := p.name()
+	if err != nil {
+		return "", "", err
+	}
+	return name, table, nil
+}
+
+// columnList used to read the '(col [, col] ...)' after the privilege.
+func (p *userParser) columnList() ([]string, error) {
+	var columns []string
+	for {
+		column, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
+		if !p.acceptKeyword(",") {
+			break
+		}
+	}
+	if err := p.expectKeyword(")"); err != nil {
+		return nil, err
+	}
+	return columns, nil
+}
+
+// grantList used to read the privilege list or the role list before the 'ON', 'TO' or 'FROM',
+// returns true if it's the privilege list. The privilege may be more words, such as 'SHOW DATABASES',
+// and with the column list, such as 'SELECT (a, b)'.
+func (p *userParser) grantList() ([]grantItem, bool, error) {
+	var items []grantItem
+	for {
+		if p.eof() {
+			return nil, false, p.syntaxError()
+		}
+		if p.tokens[p.pos].quoted || (p.pos+1 < len(p.tokens) && p.tokens[p.pos+1] == userToken{value: "@"}) {
+			// The role account.
+			name, err := p.account()
+			if err != nil {
+				return nil, false, err
+			}
+			items = append(items, grantItem{name: name})
+		} else {
+			var words []string
+			var columns []string
+			for !p.eof() && !p.peekKeyword(",") && !p.peekKeyword("ON") && !p.peekKeyword("TO") && !p.peekKeyword("FROM") {
+				if p.acceptKeyword("(") {
+					var err error
+					if columns, err = p.columnList(); err != nil {
+						return nil, false, err
+					}
+					break
+				}
+				words = append(words, p.tokens[p.pos].value)
+				p.pos++
+			}
+			if len(words) == 0 {
+				return nil, false, p.syntaxError()
+			}
+			items = append(items, grantItem{name: strings.Join(words, " "), columns: columns})
+		}
+		if !p.acceptKeyword(",") {
+			return items, p.peekKeyword("ON"), nil
+		}
+	}
+}
+
+// parseGrant used to parse the 'GRANT ... TO' and 'REVOKE ... FROM'.
+func (p *userParser) parseGrant(stmt *userStmt, revoke bool) error {
+	items, isPrivilege, err := p.grantList()
+	if err != nil {
+		return err
+	}
+	if isPrivilege {
+		for _, item := range items {
+			priv, err := privilege.NormalizePrivilege(item.name)
+			if err != nil {
+				return err
+			}
+			if len(item.columns) > 0 {
+				stmt.columnPrivileges = append(stmt.columnPrivileges, grantItem{name: priv, columns: item.columns})
+				continue
+			}
+			stmt.privileges = append(stmt.privileges, priv)
+		}
+		if stmt.database, stmt.table, err = p.privilegeLevel(); err != nil {
+			return err
+		}
+	} else {
+		for _, item := range items {
+			if len(item.columns) > 0 {
+				return p.syntaxError()
+			}
+			stmt.roles = append(stmt.roles, item.name)
+		}
+	}
+
+	switch {
+	case revoke:
+		stmt.action = roleRevoke
+		if isPrivilege {
+			stmt.action = privilegeRevoke
+		}
+		err = p.expectKeyword("FROM")
+	default:
+		stmt.action = roleGrant
+		if isPrivilege {
+			stmt.action = privilegeGrant
+		}
+		err = p.expectKeyword("TO")
+	}
+	if err != nil {
+		return err
+	}
+	if stmt.names, err = p.accounts(); err != nil {
+		return err
+	}
+	if !revoke && isPrivilege && p.acceptKeyword("WITH", "GRANT", "OPTION") {
+		stmt.grantOption = true
+	}
+	return nil
+}
+
+// parseUserStmt used to parse the account management statements:
+// CREATE USER [IF NOT EXISTS] user [IDENTIFIED [WITH plugin] BY 'password'] [, ...] [WITH resource_option ...]
+// ALTER USER [IF EXISTS] user [IDENTIFIED [WITH plugin] BY 'password'] [, ...] [WITH resource_option ...]
+// DROP USER [IF EXISTS] user [, ...]
+// CREATE ROLE [IF NOT EXISTS] role [, ...]
+// DROP ROLE [IF EXISTS] role [, ...]
+// GRANT priv [(col [, ...])] [, ...] ON [TABLE] {*.* | * | db.* | db.tbl | tbl} TO user [, ...] [WITH GRANT OPTION]
+// GRANT role [, ...] TO user [, ...]
+// REVOKE priv [(col [, ...])] [, ...] ON [TABLE] {*.* | * | db.* | db.tbl | tbl} FROM user [, ...]
+// REVOKE role [, ...] FROM user [, ...]
+// SHOW GRANTS [FOR user]
+// Returns nil if the query isn't the account management statement.
+func parseUserStmt(query string) (*userStmt, error) {
+	fields := strings.Fields(strings.ToUpper(query))
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	switch fields[0] {
+	case "CREATE", "ALTER", "DROP", "SHOW":
+		if len(fields) < 2 || (fields[1] != "USER" && fields[1] != "ROLE" && fields[1] != "GRANTS") {
+			return nil, nil
+		}
+	case "GRANT", "REVOKE":
+	default:
+		return nil, nil
+	}
+
+	tokens, err := tokenizeUser(query)
+	if err != nil {
+		return nil, sqldb.NewSQLError1(1064, "42000", "You have an error in your SQL syntax: %v", err)
+	}
+	p := &userParser{tokens: tokens}
+	stmt := &userStmt{}
+	switch {
+	case p.acceptKeyword("CREATE", "USER"):
+		stmt.action = userCreate
+		stmt.ifExists = p.acceptKeyword("IF", "NOT", "EXISTS")
+		if stmt.users, err = p.userSpecs(); err == nil {
+			stmt.resources, err = p.resourceOptions()
+		}
+	case p.acceptKeyword("ALTER", "USER"):
+		stmt.action = userAlter
+		stmt.ifExists = p.acceptKeyword("IF", "EXISTS")
+		if stmt.users, err = p.userSpecs(); err == nil {
+			stmt.resources, err = p.resourceOptions()
+		}
+		// The ALTER USER changes the password or the resources at least.
+		if err == nil && stmt.resources == nil {
+			for _, spec := range stmt.users {
+				if !spec.identified {
+					err = p.syntaxError()
+				}
+			}
+		}
+	case p.acceptKeyword("DROP", "USER"):
+		stmt.action = userDrop
+		stmt.ifExists = p.acceptKeyword("IF", "EXISTS")
+		stmt.names, err = p.accounts()
+	case p.acceptKeyword("CREATE", "ROLE"):
+		stmt.action = roleCreate
+		stmt.ifExists = p.acceptKeyword("IF", "NOT", "EXISTS")
+		stmt.names, err = p.accounts()
+	case p.acceptKeyword("DROP", "ROLE"):
+		stmt.action = roleDrop
+		stmt.ifExists = p.acceptKeyword("IF", "EXISTS")
+		stmt.names, err = p.accounts()
+	case p.acceptKeyword("GRANT"):
+		err = p.parseGrant(stmt, false)
+	case p.acceptKeyword("REVOKE"):
+		err = p.parseGrant(stmt, true)
+	case p.acceptKeyword("SHOW", "GRANTS"):
+		stmt.action = grantsShow
+		if p.acceptKeyword("FOR") {
+			var name string
+			if p.acceptKeyword("CURRENT_USER") {
+				if p.acceptKeyword("(") {
+					err = p.expectKeyword(")")
+				}
+			} else if name, err = p.account(); err == nil {
+				stmt.names = []string{name}
+			}
+		}
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !p.eof() {
+		return nil, p.syntaxError()
+	}
+	return stmt, nil
+}
+
+// handleUser used to handle the account management statements on the radon-native catalog.
+func (spanner *Spanner) handleUser(session *driver.Session, query string, stmt *userStmt) (*sqltypes.Result, error) {
+	privilegePlug := spanner.plugins.PlugPrivilege()
+	catalog := privilegePlug.Catalog()
+	qr := &sqltypes.Result{}
+
+	if stmt.action == grantsShow {
+		return spanner.handleShowGrants(session, stmt)
+	}
+	if !privilegePlug.IsSuperPriv(session.User()) {
+		return nil, sqldb.NewSQLErrorf(sqldb.ER_SPECIFIC_ACCESS_DENIED_ERROR, "Access denied; lacking super privilege for the operation")
+	}
+
+	var err error
+	switch stmt.action {
+	case userCreate, userAlter:
+		for _, spec := range stmt.users {
+			if stmt.action == userAlter {
+				if _, ok := catalog.AuthenticationString(spec.name); !ok && stmt.ifExists {
+					continue
+				}
+			}
+			if stmt.action == userCreate || spec.identified {
+				authString, x := authentication.NewAuthenticationString(spec.plugin, spec.password)
+				if x != nil {
+					return nil, sqldb.NewSQLError1(1524, "HY000", "Plugin '%s' is not loaded", spec.plugin)
+				}
+				if stmt.action == userCreate {
+					err = catalog.CreateUser(spec.name, authString, stmt.ifExists)
+				} else {
+					err = catalog.AlterUser(spec.name, authString)
+				}
+				if err != nil {
+					break
+				}
+			}
+			if stmt.resources != nil {
+				if err = catalog.AlterResources(spec.name, stmt.resources); err != nil {
+					break
+				}
+			}
+		}
+	case userDrop, roleCreate, roleDrop:
+		for _, name := range stmt.names {
+			switch stmt.action {
+			case userDrop:
+				err = catalog.DropUser(name, stmt.ifExists)
+			case roleCreate:
+				err = catalog.CreateRole(name, stmt.ifExists)
+			case roleDrop:
+				err = catalog.DropRole(name, stmt.ifExists)
+			}
+			if err != nil {
+				break
+			}
+		}
+	case privilegeGrant, privilegeRevoke:
+		database := stmt.database
+		if database == "" {
+			if database = session.Schema(); database == "" {
+				return nil, sqldb.NewSQLErrorf(sqldb.ER_NO_DB_ERROR, "No database selected")
+			}
+		}
+		for _, name := range stmt.names {
+			if err = changePrivileges(catalog, stmt, database, name); err != nil {
+				break
+			}
+		}
+	case roleGrant, roleRevoke:
+		for _, name := range stmt.names {
+			for _, role := range stmt.roles {
+				if stmt.action == roleGrant {
+					err = catalog.GrantRole(role, name)
+				} else {
+					err = catalog.RevokeRole(role, name)
+				}
+				if err != nil {
+					break
+				}
+			}
+			if err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return qr, nil
+}
+
+// changePrivileges used to grant or revoke the privileges of the statement on the database or table,
+// the column privileges are changed one by one.
+func changePrivileges(catalog *privilege.Catalog, stmt *userStmt, database string, grantee string) error {
+	change := catalog.GrantTable
+	if stmt.action == privilegeRevoke {
+		change = catalog.RevokeTable
+	}
+
+	privs := append([]string{}, stmt.privileges...)
+	if stmt.grantOption {
+		privs = append(privs, privilege.PrivGrantOption)
+	}
+	if len(privs) > 0 {
+		if err := change(privs, nil, database, stmt.table, grantee); err != nil {
+			return err
+		}
+	}
+	for _, item := range stmt.columnPrivileges {
+		if err := change([]string{item.name}, item.columns, database, stmt.table, grantee); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handleShowGrants used to handle the 'SHOW GRANTS [FOR user]', the account which isn't in the catalog is shown by the backend.
+func (spanner *Spanner) handleShowGrants(session *driver.Session, stmt *userStmt) (*sqltypes.Result, error) {
+	privilegePlug := spanner.plugins.PlugPrivilege()
+
+	user := session.User()
+	if len(stmt.names) > 0 && stmt.names[0] != user {
+		if !privilegePlug.IsSuperPriv(user) {
+			return nil, sqldb.NewSQLErrorf(sqldb.ER_SPECIFIC_ACCESS_DENIED_ERROR, "Access denied; lacking super privilege for the operation")
+		}
+		user = stmt.names[0]
+	}
+
+	rows, err := privilegePlug.Catalog().ShowGrants(user)
+	if err != nil {
+		return spanner.ExecuteSingle(fmt.Sprintf("SHOW GRANTS FOR '%s'@'%%'", strings.Replace(user, "'", "''", -1)))
+	}
+	qr := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: fmt.Sprintf("Grants for %s@%%", user), Type: querypb.Type_VARCHAR},
+		},
+	}
+	for _, row := range rows {
+		qr.Rows = append(qr.Rows, []sqltypes.Value{sqltypes.MakeTrusted(querypb.Type_VARCHAR, common.StringToBytes(row))})
+	}
+	qr.RowsAffected = uint64(len(qr.Rows))
+	return qr, nil
+}
+
+// redactUserQuery returns the query with the passwords redacted, it's used before any logging.
+func redactUserQuery(query string) string {
+	return userPasswordRegexp.ReplaceAllString(query, "${1}'******'")
+}
+
+// userAdminAction returns the admin action of the account management statement, empty for the 'SHOW GRANTS'.
+func userAdminAction(stmt *userStmt) string {
+	switch stmt.action {
+	case userCreate:
+		return "CREATE USER"
+	case userAlter:
+		return "ALTER USER"
+	case userDrop:
+		return "DROP USER"
+	case roleCreate:
+		return "CREATE ROLE"
+	case roleDrop:
+		return "DROP ROLE"
+	case privilegeGrant, roleGrant:
+		return "GRANT"
+	case privilegeRevoke, roleRevoke:
+		return "REVOKE"
+	}
+	return ""
+}
+
+// userAdminParams returns the params of the admin event, the statement with the passwords redacted.
+func userAdminParams(query string) string {
+	return audit.RedactParams(map[string]string{"statement": redactUserQuery(query)})
+}
+
+// userQueryType returns the audit query type of the account management statement.
+func userQueryType(stmt *userStmt) string {
+	if stmt.action == grantsShow {
+		return xbase.SHOW
+	}
+	return xbase.DDL
+}
+
+// userCheck used to apply the read-only and firewall checks to the account management statement,
+// the same as the admin commands.
+func (spanner *Spanner) userCheck(session *driver.Session, query string, stmt *userStmt) error {
+	typ := userQueryType(stmt)
+	if typ != xbase.SHOW && spanner.ReadOnly() {
+		return sqldb.NewSQLError(sqldb.ER_OPTION_PREVENTS_STATEMENT, "--read-only")
+	}
+	return spanner.firewallCheckQuery(session, typ, query)
+}
@@ -0,0 +1,345 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"fmt"
+	"testing"
+
+	"plugins/firewall"
+	"plugins/privilege"
+
+	"github.com/fortytw2/leaktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestProxyParseUserStmt(t *testing.T) {
+	tests := []struct {
+		query string
+		want  *userStmt
+	}{
+		{"create user u1", &userStmt{action: userCreate, users: []userSpec{{name: "u1"}}}},
+		{"CREATE USER IF NOT EXISTS 'u1'@'%' IDENTIFIED BY 'p''1', `u2` IDENTIFIED WITH caching_sha2_password BY \"p2\"",
+			&userStmt{action: userCreate, ifExists: true, users: []userSpec{{name: "u1", identified: true, password: "p'1"}, {name: "u2", identified: true, plugin: "caching_sha2_password", password: "p2"}}}},
+		{"alter user if exists u1@'%' identified by 'p1'", &userStmt{action: userAlter, ifExists: true, users: []userSpec{{name: "u1", identified: true, password: "p1"}}}},
+		{"create user u1 identified by 'p1' with max_queries_per_hour 100 MAX_USER_CONNECTIONS 2",
+			&userStmt{action: userCreate, users: []userSpec{{name: "u1", identified: true, password: "p1"}}, resources: map[string]int{privilege.MaxQueriesPerHour: 100, privilege.MaxUserConnections: 2}}},
+		{"alter user u1, u2 with max_queries_per_second 10 max_scatter_queries 1 max_rows 0",
+			&userStmt{action: userAlter, users: []userSpec{{name: "u1"}, {name: "u2"}}, resources: map[string]int{privilege.MaxQueriesPerSecond: 10, privilege.MaxScatterQueries: 1, privilege.MaxRows: 0}}},
+		{"drop user u1, 'u2'", &userStmt{action: userDrop, names: []string{"u1", "u2"}}},
+		{"drop user if exists u1", &userStmt{action: userDrop, ifExists: true, names: []string{"u1"}}},
+		{"create role if not exists r1, r2", &userStmt{action: roleCreate, ifExists: true, names: []string{"r1", "r2"}}},
+		{"drop role r1", &userStmt{action: roleDrop, names: []string{"r1"}}},
+		{"grant select, insert on db1.* to u1, u2",
+			&userStmt{action: privilegeGrant, privileges: []string{privilege.PrivSelect, privilege.PrivInsert}, database: "db1", names: []string{"u1", "u2"}}},
+		{"GRANT ALL PRIVILEGES ON *.* TO 'u1'@'%' WITH GRANT OPTION",
+			&userStmt{action: privilegeGrant, privileges: []string{privilege.PrivAll}, database: "*", names: []string{"u1"}, grantOption: true}},
+		{"grant show databases on * to u1", &userStmt{action: privilegeGrant, privileges: []string{privilege.PrivShowDatabases}, database: "*", names: []string{"u1"}}},
+		{"revoke update on `db1`.* from u1", &userStmt{action: privilegeRevoke, privileges: []string{privilege.PrivUpdate}, database: "db1", names: []string{"u1"}}},
+		{"grant select (a, `B`), insert on db1.t1 to u1",
+			&userStmt{action: privilegeGrant, privileges: []string{privilege.PrivInsert}, columnPrivileges: []grantItem{{name: privilege.PrivSelect, columns: []string{"a", "B"}}}, database: "db1", table: "t1", names: []string{"u1"}}},
+		{"grant select on table t1 to u1", &userStmt{action: privilegeGrant, privileges: []string{privilege.PrivSelect}, table: "t1", names: []string{"u1"}}},
+		{"grant select on db1 to u1", &userStmt{action: privilegeGrant, privileges: []string{privilege.PrivSelect}, table: "db1", names: []string{"u1"}}},
+		{"revoke update (a) on `db1`.`t1` from u1", &userStmt{action: privilegeRevoke, columnPrivileges: []grantItem{{name: privilege.PrivUpdate, columns: []string{"a"}}}, database: "db1", table: "t1", names: []string{"u1"}}},
+		{"grant r1, 'r2'@'%' to u1", &userStmt{action: roleGrant, roles: []string{"r1", "r2"}, names: []string{"u1"}}},
+		{"grant r1@'%' to u1", &userStmt{action: roleGrant, roles: []string{"r1"}, names: []string{"u1"}}},
+		{"revoke r1 from u1", &userStmt{action: roleRevoke, roles: []string{"r1"}, names: []string{"u1"}}},
+		{"show grants", &userStmt{action: grantsShow}},
+		{"show grants for current_user()", &userStmt{action: grantsShow}},
+		{"SHOW GRANTS FOR 'u1'@'%'", &userStmt{action: grantsShow, names: []string{"u1"}}},
+		{"select * from user", nil},
+		{"create table user(a int)", nil},
+		{"show tables", nil},
+		{"drop table t1", nil},
+	}
+	for _, test := range tests {
+		got, err := parseUserStmt(test.query)
+		assert.Nil(t, err, test.query)
+		assert.Equal(t, test.want, got, test.query)
+	}
+}
+
+func TestProxyParseUserStmtError(t *testing.T) {
+	tests := []struct {
+		query string
+		err   string
+	}{
+		{"create user", "You have an error in your SQL syntax; check the manual that corresponds to your MySQL server version for the right syntax to use near '' (errno 1064) (sqlstate 42000)"},
+		{"create user u1 identified 'p1'", "You have an error in your SQL syntax; check the manual that corresponds to your MySQL server version for the right syntax to use near 'p1' (errno 1064) (sqlstate 42000)"},
+		{"create user u1 identified by p1", "You have an error in your SQL syntax; check the manual that corresponds to your MySQL server version for the right syntax to use near 'p1' (errno 1064) (sqlstate 42000)"},
+		{"alter user u1", "You have an error in your SQL syntax; check the manual that corresponds to your MySQL server version for the right syntax to use near '' (errno 1064) (sqlstate 42000)"},
+		{"alter user u1 identified by 'p1', u2", "You have an error in your SQL syntax; check the manual that corresponds to your MySQL server version for the right syntax to use near '' (errno 1064) (sqlstate 42000)"},
+		{"alter user u1 with", "You have an error in your SQL syntax; check the manual that corresponds to your MySQL server version for the right syntax to use near '' (errno 1064) (sqlstate 42000)"},
+		{"alter user u1 with max_xx 1", "You have an error in your SQL syntax; check the manual that corresponds to your MySQL server version for the right syntax to use near 'max_xx' (errno 1064) (sqlstate 42000)"},
+		{"alter user u1 with max_rows", "You have an error in your SQL syntax; check the manual that corresponds to your MySQL server version for the right syntax to use near '' (errno 1064) (sqlstate 42000)"},
+		{"alter user u1 with max_rows -1", "You have an error in your SQL syntax; check the manual that corresponds to your MySQL server version for the right syntax to use near '-1' (errno 1064) (sqlstate 42000)"},
+		{"create user 'u1", "You have an error in your SQL syntax: unterminated.quoted.string.at[12] (errno 1064) (sqlstate 42000)"},
+		{"create user u1@localhost", "unsupported: the host[localhost] of the account 'u1', only the '%' is supported (errno 1105) (sqlstate HY000)"},
+		{"drop user u1,", "You have an error in your SQL syntax; check the manual that corresponds to your MySQL server version for the right syntax to use near '' (errno 1064) (sqlstate 42000)"},
+		{"grant selec on db1.* to u1", "Illegal privilege 'selec' (errno 1064) (sqlstate 42000)"},
+		{"grant select (a on db1.t1 to u1", "You have an error in your SQL syntax; check the manual that corresponds to your MySQL server version for the right syntax to use near 'on' (errno 1064) (sqlstate 42000)"},
+		{"grant select () on db1.t1 to u1", "You have an error in your SQL syntax; check the manual that corresponds to your MySQL server version for the right syntax to use near ')' (errno 1064) (sqlstate 42000)"},
+		{"grant select on db1. to u1", "You have an error in your SQL syntax; check the manual that corresponds to your MySQL server version for the right syntax to use near 'u1' (errno 1064) (sqlstate 42000)"},
+		{"grant r1 (a) to u1", "You have an error in your SQL syntax; check the manual that corresponds to your MySQL server version for the right syntax to use near 'to' (errno 1064) (sqlstate 42000)"},
+		{"grant select on db1.* u1", "You have an error in your SQL syntax; check the manual that corresponds to your MySQL server version for the right syntax to use near 'u1' (errno 1064) (sqlstate 42000)"},
+		{"grant to u1", "You have an error in your SQL syntax; check the manual that corresponds to your MySQL server version for the right syntax to use near 'to' (errno 1064) (sqlstate 42000)"},
+		{"revoke r1 to u1", "You have an error in your SQL syntax; check the manual that corresponds to your MySQL server version for the right syntax to use near 'to' (errno 1064) (sqlstate 42000)"},
+		{"show grants for u1 x", "You have an error in your SQL syntax; check the manual that corresponds to your MySQL server version for the right syntax to use near 'x' (errno 1064) (sqlstate 42000)"},
+	}
+	for _, test := range tests {
+		_, err := parseUserStmt(test.query)
+		assert.NotNil(t, err, test.query)
+		if err != nil {
+			assert.Equal(t, test.err, err.Error(), test.query)
+		}
+	}
+}
+
+func TestProxyUser(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	// fakedbs.
+	{
+		fakedbs.AddQuery("select version() as version", resultVersion57)
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("select .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("insert .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+	}
+
+	// The super user creates the radon-native users and roles.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		defer client.Close()
+
+		queries := []string{
+			"create database db1",
+			"create table db1.t1(id int, b int) partition by hash(id)",
+			"create user u1 identified by 'p1'",
+			"create user u2 identified with caching_sha2_password by 'p2'",
+			"create role reader",
+			"grant select on db1.* to reader",
+			"grant reader to u1, u2",
+			"grant insert on db1.* to u2",
+		}
+		for _, query := range queries {
+			_, err = client.FetchAll(query, -1)
+			assert.Nil(t, err, query)
+		}
+
+		qr, err := client.FetchAll("show grants for u2", -1)
+		assert.Nil(t, err)
+		want := "[[GRANT USAGE ON *.* TO 'u2'@'%'] [GRANT INSERT ON `db1`.* TO 'u2'@'%'] [GRANT 'reader'@'%' TO 'u2'@'%']]"
+		assert.Equal(t, want, fmt.Sprintf("%+v", qr.Rows))
+		assert.Equal(t, "Grants for u2@%", qr.Fields[0].Name)
+
+		// Errors.
+		_, err = client.FetchAll("create user u1", -1)
+		assert.Equal(t, "Operation CREATE USER failed for 'u1'@'%' (errno 1396) (sqlstate HY000)", err.Error())
+		_, err = client.FetchAll("create user u3 identified with sha256_password by 'p3'", -1)
+		assert.Equal(t, "Plugin 'sha256_password' is not loaded (errno 1524) (sqlstate HY000)", err.Error())
+		_, err = client.FetchAll("grant select on db1.* to u3", -1)
+		assert.Equal(t, "Can't find any matching row in the user table (errno 1133) (sqlstate 42000)", err.Error())
+		_, err = client.FetchAll("grant select on t1 to u1", -1)
+		assert.Equal(t, "No database selected (errno 1046) (sqlstate 3D000)", err.Error())
+		_, err = client.FetchAll("grant delete (b) on db1.t1 to u1", -1)
+		assert.Equal(t, "Illegal GRANT/REVOKE command; please consult the manual to see which privileges can be used (errno 1144) (sqlstate 42000)", err.Error())
+	}
+
+	// The native user without the backend account.
+	{
+		client, err := driver.NewConn("u1", "p1", address, "db1", "utf8")
+		assert.Nil(t, err)
+		defer client.Close()
+
+		_, err = client.FetchAll("select * from t1", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("insert into t1(id, b) values(1, 1)", -1)
+		assert.Equal(t, "Access denied for user 'u1'@'%' to database 'db1' (errno 1045) (sqlstate 28000)", err.Error())
+
+		// Show self grants.
+		qr, err := client.FetchAll("show grants", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(qr.Rows))
+
+		// No super privilege.
+		_, err = client.FetchAll("show grants for u2", -1)
+		assert.Equal(t, "Access denied; lacking super privilege for the operation (errno 1227) (sqlstate 42000)", err.Error())
+		_, err = client.FetchAll("create user u3", -1)
+		assert.Equal(t, "Access denied; lacking super privilege for the operation (errno 1227) (sqlstate 42000)", err.Error())
+
+		_, err = driver.NewConn("u1", "p2", address, "db1", "utf8")
+		assert.NotNil(t, err)
+	}
+
+	// The caching_sha2_password user.
+	{
+		client, err := driver.NewConn("u2", "p2", address, "db1", "utf8")
+		assert.Nil(t, err)
+		_, err = client.FetchAll("insert into t1(id, b) values(1, 1)", -1)
+		assert.Nil(t, err)
+		client.Close()
+	}
+
+	// The table and column privileges.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "db1", "utf8")
+		assert.Nil(t, err)
+		queries := []string{
+			"create user analyst identified by 'a1'",
+			"grant select (id), update (b) on t1 to analyst",
+		}
+		for _, query := range queries {
+			_, err = client.FetchAll(query, -1)
+			assert.Nil(t, err, query)
+		}
+		qr, err := client.FetchAll("show grants for analyst", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, "[[GRANT USAGE ON *.* TO 'analyst'@'%'] [GRANT SELECT (`id`), UPDATE (`b`) ON `db1`.`t1` TO 'analyst'@'%']]", fmt.Sprintf("%+v", qr.Rows))
+		client.Close()
+
+		client, err = driver.NewConn("analyst", "a1", address, "db1", "utf8")
+		assert.Nil(t, err)
+		defer client.Close()
+		_, err = client.FetchAll("select id from t1 where id=1", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("select b from t1", -1)
+		assert.Equal(t, "SELECT command denied to user 'analyst'@'%' for column 'b' in table 't1' (errno 1143) (sqlstate 42000)", err.Error())
+		_, err = client.FetchAll("select * from t1", -1)
+		assert.Equal(t, "SELECT command denied to user 'analyst'@'%' for table 't1' (errno 1142) (sqlstate 42000)", err.Error())
+	}
+
+	// Revoke and drop.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		defer client.Close()
+
+		queries := []string{
+			"revoke reader from u1",
+			"revoke insert on db1.* from u2",
+			"alter user u2 identified by 'p22'",
+			"drop role reader",
+			"drop user u1",
+			"drop user if exists u1",
+		}
+		for _, query := range queries {
+			_, err = client.FetchAll(query, -1)
+			assert.Nil(t, err, query)
+		}
+
+		qr, err := client.FetchAll("show grants for u2", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, "[[GRANT USAGE ON *.* TO 'u2'@'%']]", fmt.Sprintf("%+v", qr.Rows))
+
+		_, err = driver.NewConn("u1", "p1", address, "", "utf8")
+		assert.NotNil(t, err)
+		client2, err := driver.NewConn("u2", "p22", address, "", "utf8")
+		assert.Nil(t, err)
+		client2.Close()
+
+		// The backend account is shown by the backend.
+		fakedbs.AddQuery("show grants for 'mock'@'%'", &sqltypes.Result{})
+		_, err = client.FetchAll("show grants", -1)
+		assert.Nil(t, err)
+	}
+}
+
+func TestProxyUserCheck(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("show grants .*", &sqltypes.Result{})
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	// Read only.
+	{
+		proxy.SetReadOnly(true)
+		queries := []string{
+			"create user u1 identified by 'p1'",
+			"drop user u1",
+			"grant select on db1.* to u1",
+			"revoke select on db1.* from u1",
+		}
+		for _, query := range queries {
+			_, err = client.FetchAll(query, -1)
+			assert.Equal(t, "The MySQL server is running with the --read-only option so it cannot execute this statement (errno 1290) (sqlstate 42000)", err.Error(), query)
+		}
+		_, err = client.FetchAll("show grants", -1)
+		assert.Nil(t, err)
+		proxy.SetReadOnly(false)
+	}
+
+	// Firewall.
+	{
+		fw := proxy.Plugins().PlugFirewall()
+		conf := &firewall.Config{
+			Mode:  firewall.ModeLearning,
+			Rules: []*firewall.Rule{{Name: "no-grant", Statement: "DDL", Fingerprint: "^grant "}},
+		}
+		err := fw.SetConfig(conf)
+		assert.Nil(t, err)
+
+		_, err = client.FetchAll("grant select on db1.* to u1", -1)
+		assert.Equal(t, "Statement was blocked by Firewall, rule: no-grant (errno 1045) (sqlstate 28000)", err.Error())
+		_, err = client.FetchAll("create user u1 identified by 'p1'", -1)
+		assert.Nil(t, err)
+	}
+
+	// The admin events with the passwords redacted.
+	{
+		events := proxy.Audit().AdminEvents(0)
+		assert.Equal(t, 6, len(events))
+		for _, e := range events {
+			assert.NotContains(t, e.Params, "p1")
+		}
+		assert.Equal(t, "CREATE USER", events[0].Action)
+		assert.EqualValues(t, 1, events[0].Status)
+		assert.Equal(t, "GRANT", events[4].Action)
+		assert.EqualValues(t, 1, events[4].Status)
+		assert.Equal(t, "CREATE USER", events[5].Action)
+		assert.Equal(t, `{"statement":"create user u1 identified by '******'"}`, events[5].Params)
+		assert.EqualValues(t, 0, events[5].Status)
+	}
+}
+
+func TestRedactUserQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"create user u1", "create user u1"},
+		{"create user u1 identified by 'p1'", "create user u1 identified by '******'"},
+		{"CREATE USER 'u1'@'%' IDENTIFIED BY 'p''1', `u2` IDENTIFIED WITH caching_sha2_password BY \"p\\\"2\" WITH MAX_ROWS 1",
+			"CREATE USER 'u1'@'%' IDENTIFIED BY '******', `u2` IDENTIFIED WITH caching_sha2_password BY '******' WITH MAX_ROWS 1"},
+		{"alter user u1 identified by p1", "alter user u1 identified by '******'"},
+		{"alter user u1 identified by 'p1", "alter user u1 identified by '******'"},
+		{"alter user u1 identified\nby\tpassword 'hash'", "alter user u1 identified\nby\tpassword '******'"},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.want, redactUserQuery(test.query), test.query)
+	}
+}
@@ -0,0 +1,516 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"bytes"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqldb"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// virtualDB is the database of the radon virtual tables, the rows are generated by radon itself.
+const virtualDB = "radon"
+
+// isVirtualDB returns true if the database is the radon virtual database.
+func isVirtualDB(database string) bool {
+	return strings.EqualFold(database, virtualDB)
+}
+
+// handleSelectVirtual used to handle the select on the radon virtual tables, such as 'radon.statement_digests'.
+func (spanner *Spanner) handleSelectVirtual(session *driver.Session, query string, node *sqlparser.Select) (*sqltypes.Result, error) {
+	privilegePlug := spanner.plugins.PlugPrivilege()
+	if !privilegePlug.IsSuperPriv(session.User()) {
+		return nil, sqldb.NewSQLErrorf(sqldb.ER_SPECIFIC_ACCESS_DENIED_ERROR, "Access denied; lacking super privilege for the operation")
+	}
+
+	table, err := spanner.virtualFrom(node.From)
+	if err != nil {
+		return nil, err
+	}
+	return virtualSelect(node, table)
+}
+
+// virtualTable returns the rows of the virtual table.
+func (spanner *Spanner) virtualTable(name string) (*sqltypes.Result, error) {
+	switch strings.ToLower(name) {
+	case "statement_digests":
+		return digestsResult(spanner.digests.Rows()), nil
+	case "sessions":
+		return sessionsResult(spanner.sessions.Snapshot()), nil
+	case "transactions":
+		return transactionsResult(spanner.scatter.Txnz().GetTxnzRows()), nil
+	case "backend_queries":
+		return backendQueriesResult(spanner.scatter.Queryz().GetQueryzRows()), nil
+	}
+	return nil, sqldb.NewSQLError(sqldb.ER_NO_SUCH_TABLE, virtualDB+"."+name)
+}
+
+// isVirtualFrom returns true if one of the tables in the FROM is a virtual table.
+func isVirtualFrom(exprs sqlparser.TableExprs) bool {
+	for _, expr := range exprs {
+		switch expr := expr.(type) {
+		case *sqlparser.AliasedTableExpr:
+			if tb, ok := expr.Expr.(sqlparser.TableName); ok && isVirtualDB(tb.Qualifier.String()) {
+				return true
+			}
+		case *sqlparser.JoinTableExpr:
+			if isVirtualFrom(sqlparser.TableExprs{expr.LeftExpr, expr.RightExpr}) {
+				return true
+			}
+		case *sqlparser.ParenTableExpr:
+			if isVirtualFrom(expr.Exprs) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// virtualFrom returns the rows of the FROM, the tables separated by the comma are cross joined.
+// The fields are qualified by the table names or the aliases.
+func (spanner *Spanner) virtualFrom(exprs sqlparser.TableExprs) (*sqltypes.Result, error) {
+	var qr *sqltypes.Result
+	for _, expr := range exprs {
+		table, err := spanner.virtualTableExpr(expr)
+		if err != nil {
+			return nil, err
+		}
+		if qr == nil {
+			qr = table
+			continue
+		}
+		if qr, err = virtualJoin(qr, table, nil, false); err != nil {
+			return nil, err
+		}
+	}
+	return qr, nil
+}
+
+func (spanner *Spanner) virtualTableExpr(expr sqlparser.TableExpr) (*sqltypes.Result, error) {
+	switch expr := expr.(type) {
+	case *sqlparser.AliasedTableExpr:
+		tb, ok := expr.Expr.(sqlparser.TableName)
+		if !ok || (!tb.Qualifier.IsEmpty() && !isVirtualDB(tb.Qualifier.String())) {
+			return nil, errors.Errorf("unsupported: table[%s].on.the.virtual.table", sqlparser.String(expr))
+		}
+		table, err := spanner.virtualTable(tb.Name.String())
+		if err != nil {
+			return nil, err
+		}
+		alias := tb.Name.String()
+		if !expr.As.IsEmpty() {
+			alias = expr.As.String()
+		}
+		return virtualQualify(table, alias), nil
+	case *sqlparser.JoinTableExpr:
+		var outer bool
+		switch expr.Join {
+		case sqlparser.JoinStr, sqlparser.StraightJoinStr:
+		case sqlparser.LeftJoinStr:
+			outer = true
+		default:
+			return nil, errors.Errorf("unsupported: %s.on.the.virtual.table", expr.Join)
+		}
+		left, err := spanner.virtualTableExpr(expr.LeftExpr)
+		if err != nil {
+			return nil, err
+		}
+		right, err := spanner.virtualTableExpr(expr.RightExpr)
+		if err != nil {
+			return nil, err
+		}
+		return virtualJoin(left, right, expr.On, outer)
+	case *sqlparser.ParenTableExpr:
+		return spanner.virtualFrom(expr.Exprs)
+	}
+	return nil, errors.Errorf("unsupported: table[%s].on.the.virtual.table", sqlparser.String(expr))
+}
+
+// virtualQualify returns the table with the fields qualified by the name.
+func virtualQualify(table *sqltypes.Result, name string) *sqltypes.Result {
+	qr := &sqltypes.Result{Rows: table.Rows, RowsAffected: table.RowsAffected}
+	for _, field := range table.Fields {
+		f := *field
+		f.Table = name
+		qr.Fields = append(qr.Fields, &f)
+	}
+	return qr
+}
+
+// virtualJoin returns the rows of the left and right tables which match the ON expression,
+// the left rows without the matched right rows are padded with NULLs if it's the left join.
+func virtualJoin(left, right *sqltypes.Result, on sqlparser.Expr, outer bool) (*sqltypes.Result, error) {
+	qr := &sqltypes.Result{}
+	qr.Fields = append(qr.Fields, left.Fields...)
+	qr.Fields = append(qr.Fields, right.Fields...)
+	for _, l := range left.Rows {
+		matched := false
+		for _, r := range right.Rows {
+			row := make([]sqltypes.Value, 0, len(l)+len(r))
+			row = append(append(row, l...), r...)
+			if on != nil {
+				match, err := virtualMatch(on, qr.Fields, row)
+				if err != nil {
+					return nil, err
+				}
+				if !match {
+					continue
+				}
+			}
+			matched = true
+			qr.Rows = append(qr.Rows, row)
+		}
+		if outer && !matched {
+			row := make([]sqltypes.Value, len(l)+len(right.Fields))
+			copy(row, l)
+			qr.Rows = append(qr.Rows, row)
+		}
+	}
+	qr.RowsAffected = uint64(len(qr.Rows))
+	return qr, nil
+}
+
+// virtualSelect used to evaluate the select on the rows of the virtual table,
+// supports the column projection, the WHERE filter of the column and value comparisons,
+// the ORDER BY columns and the LIMIT.
+func virtualSelect(node *sqlparser.Select, table *sqltypes.Result) (*sqltypes.Result, error) {
+	if node.Distinct != "" || len(node.GroupBy) > 0 || node.Having != nil {
+		return nil, errors.New("unsupported: distinct/groupby/having.on.the.virtual.table")
+	}
+
+	// Filter.
+	rows := make([][]sqltypes.Value, 0, len(table.Rows))
+	for _, row := range table.Rows {
+		if node.Where != nil {
+			match, err := virtualMatch(node.Where.Expr, table.Fields, row)
+			if err != nil {
+				return nil, err
+			}
+			if !match {
+				continue
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	// Order by.
+	if len(node.OrderBy) > 0 {
+		type orderKey struct {
+			idx  int
+			desc bool
+		}
+		keys := make([]orderKey, 0, len(node.OrderBy))
+		for _, order := range node.OrderBy {
+			col, ok := order.Expr.(*sqlparser.ColName)
+			if !ok {
+				return nil, errors.Errorf("unsupported: orderby[%s].on.the.virtual.table", sqlparser.String(order.Expr))
+			}
+			idx, err := virtualColumn(table.Fields, col)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, orderKey{idx: idx, desc: order.Direction == sqlparser.DescScr})
+		}
+		sort.SliceStable(rows, func(i, j int) bool {
+			for _, key := range keys {
+				cmp := virtualCompare(rows[i][key.idx], rows[j][key.idx])
+				if cmp == 0 {
+					continue
+				}
+				if key.desc {
+					return cmp > 0
+				}
+				return cmp < 0
+			}
+			return false
+		})
+	}
+
+	// Limit.
+	if node.Limit != nil {
+		offset, count, err := virtualLimit(node.Limit)
+		if err != nil {
+			return nil, err
+		}
+		if offset > len(rows) {
+			offset = len(rows)
+		}
+		rows = rows[offset:]
+		if count < len(rows) {
+			rows = rows[:count]
+		}
+	}
+
+	// Projection.
+	qr := &sqltypes.Result{}
+	var idxs []int
+	for _, expr := range node.SelectExprs {
+		switch expr := expr.(type) {
+		case *sqlparser.StarExpr:
+			qualifier := expr.TableName.Name.String()
+			for i, field := range table.Fields {
+				if qualifier != "" && !strings.EqualFold(field.Table, qualifier) {
+					continue
+				}
+				idxs = append(idxs, i)
+				qr.Fields = append(qr.Fields, field)
+			}
+			if qualifier != "" && len(idxs) == 0 {
+				return nil, errors.Errorf("unknown.table[%s].on.the.virtual.table", qualifier)
+			}
+		case *sqlparser.AliasedExpr:
+			col, ok := expr.Expr.(*sqlparser.ColName)
+			if !ok {
+				return nil, errors.Errorf("unsupported: select.expr[%s].on.the.virtual.table", sqlparser.String(expr))
+			}
+			idx, err := virtualColumn(table.Fields, col)
+			if err != nil {
+				return nil, err
+			}
+			field := *table.Fields[idx]
+			if !expr.As.IsEmpty() {
+				field.Name = expr.As.String()
+			}
+			idxs = append(idxs, idx)
+			qr.Fields = append(qr.Fields, &field)
+		default:
+			return nil, errors.Errorf("unsupported: select.expr[%s].on.the.virtual.table", sqlparser.String(expr))
+		}
+	}
+	for _, row := range rows {
+		projected := make([]sqltypes.Value, len(idxs))
+		for i, idx := range idxs {
+			projected[i] = row[idx]
+		}
+		qr.Rows = append(qr.Rows, projected)
+	}
+	qr.RowsAffected = uint64(len(qr.Rows))
+	return qr, nil
+}
+
+// virtualColumn returns the index of the column in the fields, case-insensitive.
+// The qualified column matches the field of the table, the unqualified column must be unique in the fields.
+func virtualColumn(fields []*querypb.Field, col *sqlparser.ColName) (int, error) {
+	idx := -1
+	qualifier := col.Qualifier.Name.String()
+	for i, field := range fields {
+		if !strings.EqualFold(field.Name, col.Name.String()) {
+			continue
+		}
+		if qualifier != "" && field.Table != "" && !strings.EqualFold(field.Table, qualifier) {
+			continue
+		}
+		if idx >= 0 {
+			return -1, errors.Errorf("column[%s].is.ambiguous.on.the.virtual.table", sqlparser.String(col))
+		}
+		idx = i
+	}
+	if idx < 0 {
+		return -1, sqldb.NewSQLError(sqldb.ER_BAD_FIELD_ERROR, sqlparser.String(col), "field list")
+	}
+	return idx, nil
+}
+
+// virtualMatch returns true if the row matches the WHERE expression.
+func virtualMatch(expr sqlparser.Expr, fields []*querypb.Field, row []sqltypes.Value) (bool, error) {
+	switch expr := expr.(type) {
+	case *sqlparser.AndExpr:
+		left, err := virtualMatch(expr.Left, fields, row)
+		if err != nil || !left {
+			return false, err
+		}
+		return virtualMatch(expr.Right, fields, row)
+	case *sqlparser.OrExpr:
+		left, err := virtualMatch(expr.Left, fields, row)
+		if err != nil || left {
+			return left, err
+		}
+		return virtualMatch(expr.Right, fields, row)
+	case *sqlparser.NotExpr:
+		match, err := virtualMatch(expr.Expr, fields, row)
+		return !match, err
+	case *sqlparser.ParenExpr:
+		return virtualMatch(expr.Expr, fields, row)
+	case *sqlparser.IsExpr:
+		col, ok := expr.Expr.(*sqlparser.ColName)
+		if !ok {
+			break
+		}
+		idx, err := virtualColumn(fields, col)
+		if err != nil {
+			return false, err
+		}
+		switch expr.Operator {
+		case sqlparser.IsNullStr:
+			return row[idx].IsNull(), nil
+		case sqlparser.IsNotNullStr:
+			return !row[idx].IsNull(), nil
+		}
+	case *sqlparser.ComparisonExpr:
+		col, ok := expr.Left.(*sqlparser.ColName)
+		if !ok {
+			break
+		}
+		idx, err := virtualColumn(fields, col)
+		if err != nil {
+			return false, err
+		}
+		v := row[idx]
+		switch expr.Operator {
+		case sqlparser.InStr, sqlparser.NotInStr:
+			tuple, ok := expr.Right.(sqlparser.ValTuple)
+			if !ok {
+				break
+			}
+			in := false
+			for _, e := range tuple {
+				val, err := virtualOperand(e, fields, row)
+				if err != nil {
+					return false, err
+				}
+				if virtualCompare(v, val) == 0 {
+					in = true
+					break
+				}
+			}
+			return in == (expr.Operator == sqlparser.InStr), nil
+		case sqlparser.LikeStr, sqlparser.NotLikeStr:
+			val, err := virtualValue(expr.Right)
+			if err != nil {
+				return false, err
+			}
+			re, err := likeRegexp(val.ToString())
+			if err != nil {
+				return false, err
+			}
+			return re.MatchString(v.ToString()) == (expr.Operator == sqlparser.LikeStr), nil
+		default:
+			val, err := virtualOperand(expr.Right, fields, row)
+			if err != nil {
+				return false, err
+			}
+			cmp := virtualCompare(v, val)
+			switch expr.Operator {
+			case sqlparser.EqualStr, sqlparser.NullSafeEqualStr:
+				return cmp == 0, nil
+			case sqlparser.NotEqualStr:
+				return cmp != 0, nil
+			case sqlparser.LessThanStr:
+				return cmp < 0, nil
+			case sqlparser.LessEqualStr:
+				return cmp <= 0, nil
+			case sqlparser.GreaterThanStr:
+				return cmp > 0, nil
+			case sqlparser.GreaterEqualStr:
+				return cmp >= 0, nil
+			}
+		}
+	}
+	return false, errors.Errorf("unsupported: where[%s].on.the.virtual.table", sqlparser.String(expr))
+}
+
+// virtualOperand returns the value of the column in the row or the literal expression.
+func virtualOperand(expr sqlparser.Expr, fields []*querypb.Field, row []sqltypes.Value) (sqltypes.Value, error) {
+	if col, ok := expr.(*sqlparser.ColName); ok {
+		idx, err := virtualColumn(fields, col)
+		if err != nil {
+			return sqltypes.NULL, err
+		}
+		return row[idx], nil
+	}
+	return virtualValue(expr)
+}
+
+// virtualValue returns the value of the literal expression.
+func virtualValue(expr sqlparser.Expr) (sqltypes.Value, error) {
+	switch expr := expr.(type) {
+	case *sqlparser.SQLVal:
+		switch expr.Type {
+		case sqlparser.IntVal:
+			return sqltypes.NewValue(querypb.Type_INT64, expr.Val)
+		case sqlparser.FloatVal:
+			return sqltypes.NewValue(querypb.Type_FLOAT64, expr.Val)
+		case sqlparser.StrVal:
+			return sqltypes.MakeTrusted(querypb.Type_VARCHAR, expr.Val), nil
+		}
+	case *sqlparser.NullVal:
+		return sqltypes.NULL, nil
+	}
+	return sqltypes.NULL, errors.Errorf("unsupported: value[%s].on.the.virtual.table", sqlparser.String(expr))
+}
+
+// virtualCompare compares the values numerically if both of them are numbers,
+// otherwise compares them as strings.
+func virtualCompare(v1, v2 sqltypes.Value) int {
+	if v1.IsNull() || v2.IsNull() {
+		return sqltypes.NullsafeCompare(v1, v2)
+	}
+	f1, err1 := strconv.ParseFloat(v1.ToString(), 64)
+	f2, err2 := strconv.ParseFloat(v2.ToString(), 64)
+	if err1 == nil && err2 == nil {
+		return sqltypes.CompareFloat64(f1, f2)
+	}
+	return bytes.Compare(v1.Raw(), v2.Raw())
+}
+
+// virtualLimit returns the offset and count of the LIMIT.
+func virtualLimit(limit *sqlparser.Limit) (int, int, error) {
+	var err error
+	offset, count := 0, 0
+	if limit.Offset != nil {
+		if offset, err = virtualInt(limit.Offset); err != nil {
+			return 0, 0, err
+		}
+	}
+	if count, err = virtualInt(limit.Rowcount); err != nil {
+		return 0, 0, err
+	}
+	return offset, count, nil
+}
+
+func virtualInt(expr sqlparser.Expr) (int, error) {
+	val, ok := expr.(*sqlparser.SQLVal)
+	if !ok || val.Type != sqlparser.IntVal {
+		return 0, errors.Errorf("unsupported: limit[%s].on.the.virtual.table", sqlparser.String(expr))
+	}
+	return strconv.Atoi(string(val.Val))
+}
+
+// likeRegexp returns the regexp of the LIKE pattern, '%' matches any string and '_' matches
+// one character, '\' escapes them.
+func likeRegexp(pattern string) (*regexp.Regexp, error) {
+	var buf strings.Builder
+	buf.WriteString("(?is)^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '%':
+			buf.WriteString(".*")
+		case '_':
+			buf.WriteString(".")
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+			}
+			buf.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		default:
+			buf.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	buf.WriteString("$")
+	return regexp.Compile(buf.String())
+}
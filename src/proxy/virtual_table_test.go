@@ -0,0 +1,265 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestVirtualSelect(t *testing.T) {
+	table := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "NAME", Type: querypb.Type_VARCHAR},
+			{Name: "COUNT", Type: querypb.Type_UINT64},
+		},
+	}
+	for i, name := range []string{"select_a", "select_b", "insert_a", "update%a"} {
+		table.Rows = append(table.Rows, []sqltypes.Value{
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(name)),
+			sqltypes.MakeTrusted(querypb.Type_UINT64, []byte(fmt.Sprintf("%d", (i+1)*5))),
+		})
+	}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"select * from radon.t", "[[select_a 5] [select_b 10] [insert_a 15] [update%a 20]]"},
+		{"select count as c, name from radon.t where count >= 10 order by count desc", "[[20 update%a] [15 insert_a] [10 select_b]]"},
+		{"select name from radon.t where name like 'select%' and not count = 5", "[[select_b]]"},
+		{"select name from radon.t where name like 'update\\%_' or count < 6", "[[select_a] [update%a]]"},
+		{"select name from radon.t where name in ('insert_a', 'select_b') order by name", "[[insert_a] [select_b]]"},
+		{"select name from radon.t where count not in (5, 10) and (name != 'x')", "[[insert_a] [update%a]]"},
+		{"select name from radon.t where count > '9' and count <= 15.0", "[[select_b] [insert_a]]"},
+		{"select name from radon.t where name not like '%a' or name = null", "[[select_b]]"},
+		{"select name from radon.t order by count desc limit 1, 2", "[[insert_a] [select_b]]"},
+		{"select name from radon.t limit 10, 2", "[]"},
+	}
+	for _, test := range tests {
+		node, err := sqlparser.Parse(test.query)
+		assert.Nil(t, err)
+		qr, err := virtualSelect(node.(*sqlparser.Select), table)
+		assert.Nil(t, err, test.query)
+		assert.Equal(t, test.want, fmt.Sprintf("%v", qr.Rows), test.query)
+	}
+
+	// Alias.
+	{
+		node, err := sqlparser.Parse("select count as c from radon.t")
+		assert.Nil(t, err)
+		qr, err := virtualSelect(node.(*sqlparser.Select), table)
+		assert.Nil(t, err)
+		assert.Equal(t, "c", qr.Fields[0].Name)
+		assert.Equal(t, "COUNT", table.Fields[1].Name)
+	}
+
+	// Unsupported.
+	errs := []struct {
+		query string
+		want  string
+	}{
+		{"select distinct name from radon.t", "unsupported: distinct/groupby/having.on.the.virtual.table"},
+		{"select name from radon.t group by name", "unsupported: distinct/groupby/having.on.the.virtual.table"},
+		{"select count(*) from radon.t", "unsupported: select.expr[count(*)].on.the.virtual.table"},
+		{"select x from radon.t", "Unknown column 'x' in 'field list' (errno 1054) (sqlstate 42S22)"},
+		{"select name from radon.t where count+1 > 1", "unsupported: where[count + 1 > 1].on.the.virtual.table"},
+		{"select name from radon.t where name = lower('a')", "unsupported: value[lower('a')].on.the.virtual.table"},
+		{"select name from radon.t where count in (select 1)", "unsupported: where[count in (select 1 from dual)].on.the.virtual.table"},
+		{"select name from radon.t order by count+1", "unsupported: orderby[count + 1].on.the.virtual.table"},
+		{"select name from radon.t order by x", "Unknown column 'x' in 'field list' (errno 1054) (sqlstate 42S22)"},
+		{"select name from radon.t limit 1, ?", "unsupported: limit[:v1].on.the.virtual.table"},
+	}
+	for _, test := range errs {
+		node, err := sqlparser.Parse(test.query)
+		assert.Nil(t, err, test.query)
+		_, err = virtualSelect(node.(*sqlparser.Select), table)
+		assert.NotNil(t, err, test.query)
+		if err != nil {
+			assert.Equal(t, test.want, err.Error(), test.query)
+		}
+	}
+}
+
+func TestVirtualJoin(t *testing.T) {
+	newTable := func(name string, fields []string, rows ...[]string) *sqltypes.Result {
+		table := &sqltypes.Result{}
+		for _, field := range fields {
+			table.Fields = append(table.Fields, &querypb.Field{Name: field, Type: querypb.Type_VARCHAR})
+		}
+		for _, row := range rows {
+			var values []sqltypes.Value
+			for _, v := range row {
+				if v == "NULL" {
+					values = append(values, sqltypes.NULL)
+					continue
+				}
+				values = append(values, sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(v)))
+			}
+			table.Rows = append(table.Rows, values)
+		}
+		return virtualQualify(table, name)
+	}
+	sessions := newTable("s", []string{"ID", "TXN_ID"}, []string{"1", "7"}, []string{"2", "NULL"}, []string{"3", "8"})
+	txns := newTable("t", []string{"TXN_ID", "AGE"}, []string{"7", "12.5"}, []string{"8", "0.5"})
+
+	tests := []struct {
+		query string
+		on    string
+		outer bool
+		want  string
+	}{
+		{"select s.id, t.age from radon.t", "s.txn_id = t.txn_id", false, "[[1 12.5] [3 0.5]]"},
+		{"select s.id, age from radon.t where t.age > 10", "s.txn_id = t.txn_id", false, "[[1 12.5]]"},
+		{"select s.id from radon.t where t.txn_id is null", "s.txn_id = t.txn_id", true, "[[2]]"},
+		{"select t.* from radon.t order by s.id desc", "s.txn_id = t.txn_id", true, "[[8 0.5] [ ] [7 12.5]]"},
+		{"select s.id, t.txn_id from radon.t where s.txn_id is not null and s.txn_id != t.txn_id", "", false, "[[1 8] [3 7]]"},
+	}
+	for _, test := range tests {
+		var on sqlparser.Expr
+		if test.on != "" {
+			node, err := sqlparser.Parse("select 1 from t where " + test.on)
+			assert.Nil(t, err)
+			on = node.(*sqlparser.Select).Where.Expr
+		}
+		table, err := virtualJoin(sessions, txns, on, test.outer)
+		assert.Nil(t, err)
+
+		node, err := sqlparser.Parse(test.query)
+		assert.Nil(t, err)
+		qr, err := virtualSelect(node.(*sqlparser.Select), table)
+		assert.Nil(t, err, test.query)
+		assert.Equal(t, test.want, fmt.Sprintf("%v", qr.Rows), test.query)
+	}
+
+	// Errors.
+	table, err := virtualJoin(sessions, txns, nil, false)
+	assert.Nil(t, err)
+	errs := []struct {
+		query string
+		want  string
+	}{
+		{"select txn_id from radon.t", "column[txn_id].is.ambiguous.on.the.virtual.table"},
+		{"select x.txn_id from radon.t", "Unknown column 'x.txn_id' in 'field list' (errno 1054) (sqlstate 42S22)"},
+		{"select x.* from radon.t", "unknown.table[x].on.the.virtual.table"},
+	}
+	for _, test := range errs {
+		node, err := sqlparser.Parse(test.query)
+		assert.Nil(t, err, test.query)
+		_, err = virtualSelect(node.(*sqlparser.Select), table)
+		assert.NotNil(t, err, test.query)
+		if err != nil {
+			assert.Equal(t, test.want, err.Error(), test.query)
+		}
+	}
+}
+
+func TestLikeRegexp(t *testing.T) {
+	tests := []struct {
+		pattern string
+		value   string
+		match   bool
+	}{
+		{"abc", "ABC", true},
+		{"a%", "abc", true},
+		{"a_c", "abc", true},
+		{"a_c", "abbc", false},
+		{"a\\%", "a%", true},
+		{"a\\%", "ab", false},
+		{"a.c", "abc", false},
+		{"中%", "中文", true},
+	}
+	for _, test := range tests {
+		re, err := likeRegexp(test.pattern)
+		assert.Nil(t, err)
+		assert.Equal(t, test.match, re.MatchString(test.value), test.pattern)
+	}
+}
+
+func TestProxySelectVirtual(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("select .*", &sqltypes.Result{})
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+	assert.Nil(t, err)
+	defer client.Quit()
+
+	// statement digests.
+	{
+		_, err = client.FetchAll("reset query digests", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("select 1 from dual", -1)
+		assert.Nil(t, err)
+		qr, err := client.FetchAll("select digest_text, count_star from radon.statement_digests where digest_text like 'select%dual' order by count_star desc", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, "[[select ? from dual 1]]", fmt.Sprintf("%v", qr.Rows))
+	}
+
+	// unknown table.
+	{
+		_, err = client.FetchAll("select * from radon.xx", -1)
+		assert.NotNil(t, err)
+		assert.Equal(t, "Table 'radon.xx' doesn't exist (errno 1146) (sqlstate 42S02)", err.Error())
+	}
+
+	// join with the non-virtual table.
+	{
+		_, err = client.FetchAll("select * from radon.sessions s join test.t1 t on s.id = t.id", -1)
+		assert.NotNil(t, err)
+		assert.Equal(t, "unsupported: table[test.t1 as t].on.the.virtual.table (errno 1105) (sqlstate HY000)", err.Error())
+
+		_, err = client.FetchAll("select * from radon.sessions s right join radon.transactions t on s.txn_id = t.txn_id", -1)
+		assert.NotNil(t, err)
+		assert.Equal(t, "unsupported: right join.on.the.virtual.table (errno 1105) (sqlstate HY000)", err.Error())
+	}
+
+	// The radon database is reserved.
+	{
+		_, err = client.FetchAll("create database radon", -1)
+		assert.NotNil(t, err)
+		assert.Equal(t, "Access denied; lacking privileges for database radon (errno 1227) (sqlstate 42000)", err.Error())
+
+		_, err = client.FetchAll("create table RADON.t1(a int)", -1)
+		assert.NotNil(t, err)
+	}
+}
+
+func TestProxySelectVirtualPrivilege(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxyPrivilegeN(log, MockDefaultConfig())
+	defer cleanup()
+	address := proxy.Address()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+	assert.Nil(t, err)
+	defer client.Quit()
+	_, err = client.FetchAll("select * from radon.statement_digests", -1)
+	assert.NotNil(t, err)
+	assert.Equal(t, "Access denied; lacking super privilege for the operation (errno 1227) (sqlstate 42000)", err.Error())
+}
@@ -0,0 +1,393 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"container/list"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"config"
+	"monitor"
+	"xbase"
+
+	"github.com/pkg/errors"
+	"github.com/xelabs/go-mysqlstack/sqldb"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+const (
+	// defaultWorkloadGroup is the group of the unmatched queries.
+	defaultWorkloadGroup = "default"
+	// defaultWorkloadQueueTimeout is the queue timeout(in milliseconds) if the group doesn't set it.
+	defaultWorkloadQueueTimeout = 10000
+)
+
+var (
+	workloadHintRegexp = regexp.MustCompile(`/\*\+\s*workload\s*=\s*([\w-]+)\s*\*/`)
+
+	// workloadExemptWords are the leading words of the statements which bypass the queues,
+	// the transaction control, KILL, SET and USE must not wait behind the queries they may unblock.
+	workloadExemptWords = map[string]bool{
+		"begin":     true,
+		"start":     true,
+		"commit":    true,
+		"rollback":  true,
+		"savepoint": true,
+		"release":   true,
+		"kill":      true,
+		"set":       true,
+		"use":       true,
+	}
+)
+
+// workloadExempt returns true if the query bypasses the workload queues by its leading word,
+// the leading spaces and comments are skipped.
+func workloadExempt(query string) bool {
+	i := 0
+	for i < len(query) {
+		switch c := query[i]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '(':
+			i++
+		case c == '/' && i+1 < len(query) && query[i+1] == '*':
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return false
+			}
+			i += end + 4
+		default:
+			j := i
+			for j < len(query) && ((query[j] >= 'a' && query[j] <= 'z') || (query[j] >= 'A' && query[j] <= 'Z')) {
+				j++
+			}
+			return workloadExemptWords[strings.ToLower(query[i:j])]
+		}
+	}
+	return false
+}
+
+// workloadWaiter tuple, the query waiting in the queue.
+type workloadWaiter struct {
+	ready    chan struct{}
+	admitted bool
+	group    *workloadGroup
+	elem     *list.Element
+}
+
+// workloadGroup tuple.
+type workloadGroup struct {
+	conf         *config.WorkloadGroupConfig
+	fingerprints []*regexp.Regexp
+	weight       float64
+	running      int
+	waiters      *list.List
+	// The virtual time, increased by 1/weight for each admitted query,
+	// the backlogged group with the smallest virtual time is served first.
+	vtime float64
+}
+
+func newWorkloadGroup(conf *config.WorkloadGroupConfig) (*workloadGroup, error) {
+	g := &workloadGroup{
+		conf:    conf,
+		weight:  1,
+		waiters: list.New(),
+	}
+	if conf.Weight > 0 {
+		g.weight = float64(conf.Weight)
+	}
+	for _, fp := range conf.Fingerprints {
+		re, err := regexp.Compile(fp)
+		if err != nil {
+			return nil, errors.Errorf("workload.group[%s].fingerprint[%s].invalid:%v", conf.Name, fp, err)
+		}
+		g.fingerprints = append(g.fingerprints, re)
+	}
+	return g, nil
+}
+
+func matchWorkloadList(list []string, s string) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// allow returns true if the user and database match the group.
+func (g *workloadGroup) allow(user string, database string) bool {
+	return matchWorkloadList(g.conf.Users, user) && matchWorkloadList(g.conf.Databases, database)
+}
+
+// match returns true if the user, database and fingerprint all match the group.
+func (g *workloadGroup) match(user string, database string, fingerprint func() string) bool {
+	if !g.allow(user, database) {
+		return false
+	}
+	if len(g.fingerprints) == 0 {
+		return true
+	}
+	fp := fingerprint()
+	for _, re := range g.fingerprints {
+		if re.MatchString(fp) {
+			return true
+		}
+	}
+	return false
+}
+
+// WorkloadGroupStatus tuple.
+type WorkloadGroupStatus struct {
+	Name       string `json:"name"`
+	Slots      int    `json:"slots"`
+	Weight     int    `json:"weight"`
+	Running    int    `json:"running"`
+	QueueDepth int    `json:"queue-depth"`
+}
+
+// Workload tuple, the scheduler of the workload groups.
+// Each group has its own concurrency slots and queue, the max-concurrency(the backend pools)
+// is shared by the groups in proportion to their weights by the weighted fair queueing.
+type Workload struct {
+	log     *xlog.Log
+	mu      sync.Mutex
+	max     int
+	running int
+	vtime   float64
+	groups  []*workloadGroup
+	byName  map[string]*workloadGroup
+}
+
+// NewWorkload creates the new workload scheduler.
+func NewWorkload(log *xlog.Log, conf *config.WorkloadConfig) (*Workload, error) {
+	w := &Workload{log: log}
+	if err := w.Set(conf); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Set used to reset the groups, the running and waiting queries are moved to the new groups by the name,
+// or to the default group if the group is removed.
+func (w *Workload) Set(conf *config.WorkloadConfig) error {
+	if conf == nil {
+		conf = config.DefaultWorkloadConfig()
+	}
+	var groups []*workloadGroup
+	byName := make(map[string]*workloadGroup)
+	for _, gconf := range conf.Groups {
+		if gconf.Name == "" {
+			return errors.New("workload.group.name.can't.be.empty")
+		}
+		if _, ok := byName[gconf.Name]; ok {
+			return errors.Errorf("workload.group[%s].duplicate", gconf.Name)
+		}
+		g, err := newWorkloadGroup(gconf)
+		if err != nil {
+			return err
+		}
+		groups = append(groups, g)
+		byName[gconf.Name] = g
+	}
+	if _, ok := byName[defaultWorkloadGroup]; !ok {
+		g, _ := newWorkloadGroup(&config.WorkloadGroupConfig{Name: defaultWorkloadGroup})
+		groups = append(groups, g)
+		byName[defaultWorkloadGroup] = g
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, old := range w.groups {
+		g, ok := byName[old.conf.Name]
+		if !ok {
+			g = byName[defaultWorkloadGroup]
+		}
+		g.running += old.running
+		g.vtime = old.vtime
+		g.waiters.PushBackList(old.waiters)
+	}
+	// The waiters are moved to the new lists.
+	for _, g := range groups {
+		for e := g.waiters.Front(); e != nil; e = e.Next() {
+			waiter := e.Value.(*workloadWaiter)
+			waiter.group, waiter.elem = g, e
+		}
+	}
+	w.max = conf.MaxConcurrency
+	w.groups = groups
+	w.byName = byName
+	w.dispatch()
+	return nil
+}
+
+// Match returns the group name of the query.
+func (w *Workload) Match(user string, database string, query string) string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.match(user, database, query).conf.Name
+}
+
+// match returns the group of the query, the hint only applies to the group whose users and databases match,
+// otherwise it's ignored, a client can't jump into the group of others.
+func (w *Workload) match(user string, database string, query string) *workloadGroup {
+	if m := workloadHintRegexp.FindStringSubmatch(query); m != nil {
+		if g, ok := w.byName[m[1]]; ok && g.allow(user, database) {
+			return g
+		}
+	}
+	var fp string
+	fingerprint := func() string {
+		if fp == "" {
+			fp = xbase.Fingerprint(query)
+		}
+		return fp
+	}
+	for _, g := range w.groups {
+		if g.conf.Name == defaultWorkloadGroup {
+			continue
+		}
+		if g.match(user, database, fingerprint) {
+			return g
+		}
+	}
+	return w.byName[defaultWorkloadGroup]
+}
+
+// runnable returns true if the query of the group can run now.
+func (w *Workload) runnable(g *workloadGroup) bool {
+	return (w.max <= 0 || w.running < w.max) && (g.conf.Slots <= 0 || g.running < g.conf.Slots)
+}
+
+func (w *Workload) admit(g *workloadGroup) {
+	w.running++
+	g.running++
+	w.vtime = g.vtime
+	g.vtime += 1 / g.weight
+	monitor.WorkloadRunningSet(g.conf.Name, float64(g.running))
+}
+
+// dispatch used to admit the waiters of the runnable groups by the virtual time, the lock must be held.
+func (w *Workload) dispatch() {
+	for {
+		var next *workloadGroup
+		for _, g := range w.groups {
+			if g.waiters.Len() > 0 && w.runnable(g) && (next == nil || g.vtime < next.vtime) {
+				next = g
+			}
+		}
+		if next == nil {
+			return
+		}
+		waiter := next.waiters.Remove(next.waiters.Front()).(*workloadWaiter)
+		waiter.admitted = true
+		w.admit(next)
+		monitor.WorkloadQueueDepthSet(next.conf.Name, float64(next.waiters.Len()))
+		close(waiter.ready)
+	}
+}
+
+// Acquire used to acquire the slot of the group matched by the query, the query waits in the queue
+// of the group if there is no slot, returns the function to release the slot.
+func (w *Workload) Acquire(user string, database string, query string) (func(), error) {
+	w.mu.Lock()
+	g := w.match(user, database, query)
+	name := g.conf.Name
+	if g.waiters.Len() == 0 && w.runnable(g) {
+		// The idle group can't save the virtual time to burst.
+		if g.vtime < w.vtime {
+			g.vtime = w.vtime
+		}
+		w.admit(g)
+		w.mu.Unlock()
+		return w.releaser(name), nil
+	}
+	if size := g.conf.QueueSize; size > 0 && g.waiters.Len() >= size {
+		w.mu.Unlock()
+		monitor.WorkloadRejectedInc(name, "queue-full")
+		return nil, sqldb.NewSQLErrorf(sqldb.ER_UNKNOWN_ERROR, "workload group[%s] queue is full(max: %d)", name, size)
+	}
+	if g.waiters.Len() == 0 && g.vtime < w.vtime {
+		g.vtime = w.vtime
+	}
+	waiter := &workloadWaiter{ready: make(chan struct{}), group: g}
+	waiter.elem = g.waiters.PushBack(waiter)
+	monitor.WorkloadQueueDepthSet(name, float64(g.waiters.Len()))
+	timeout := g.conf.QueueTimeout
+	if timeout == 0 {
+		timeout = defaultWorkloadQueueTimeout
+	}
+	w.mu.Unlock()
+
+	start := time.Now()
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(time.Duration(timeout) * time.Millisecond)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case <-waiter.ready:
+	case <-expired:
+		w.mu.Lock()
+		if !waiter.admitted {
+			waiter.group.waiters.Remove(waiter.elem)
+			monitor.WorkloadQueueDepthSet(waiter.group.conf.Name, float64(waiter.group.waiters.Len()))
+			w.mu.Unlock()
+			monitor.WorkloadRejectedInc(name, "timeout")
+			return nil, sqldb.NewSQLErrorf(sqldb.ER_UNKNOWN_ERROR, "workload group[%s] queue wait timeout(%dms)", name, timeout)
+		}
+		w.mu.Unlock()
+	}
+	monitor.WorkloadWaitObserve(name, time.Since(start).Seconds())
+	return w.releaser(name), nil
+}
+
+// releaser returns the function to release the slot of the group, the group is looked up by the name
+// since the groups maybe reset.
+func (w *Workload) releaser(name string) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			w.mu.Lock()
+			defer w.mu.Unlock()
+			g, ok := w.byName[name]
+			if !ok {
+				g = w.byName[defaultWorkloadGroup]
+			}
+			w.running--
+			g.running--
+			monitor.WorkloadRunningSet(g.conf.Name, float64(g.running))
+			w.dispatch()
+		})
+	}
+}
+
+// Status returns the status of the groups, sorted by the name.
+func (w *Workload) Status() []WorkloadGroupStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var status []WorkloadGroupStatus
+	for _, g := range w.groups {
+		status = append(status, WorkloadGroupStatus{
+			Name:       g.conf.Name,
+			Slots:      g.conf.Slots,
+			Weight:     int(g.weight),
+			Running:    g.running,
+			QueueDepth: g.waiters.Len(),
+		})
+	}
+	sort.Slice(status, func(i, j int) bool { return status[i].Name < status[j].Name })
+	return status
+}
@@ -0,0 +1,340 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"config"
+
+	"github.com/fortytw2/leaktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/driver"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestWorkloadMatch(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	conf := &config.WorkloadConfig{
+		Groups: []*config.WorkloadGroupConfig{
+			{Name: "report", Users: []string{"reporter"}},
+			{Name: "oltp", Databases: []string{"db1", "db2"}, Fingerprints: []string{`^select .* where id = \?$`, `^(insert|update|delete) `}},
+			{Name: "scan", Fingerprints: []string{`^select count\(\*\) from`}},
+		},
+	}
+	w, err := NewWorkload(log, conf)
+	assert.Nil(t, err)
+
+	tests := []struct {
+		user  string
+		db    string
+		query string
+		want  string
+	}{
+		{"reporter", "db1", "select * from t1 where id=1", "report"},
+		{"u1", "db1", "SELECT a FROM t1 WHERE id = 10", "oltp"},
+		{"u1", "db2", "insert into t1 values(1)", "oltp"},
+		{"u1", "db3", "insert into t1 values(1)", "default"},
+		{"u1", "db1", "select a from t1", "default"},
+		{"u1", "db3", "select count(*) from t1", "scan"},
+		{"reporter", "db3", "/*+ workload=scan */ select a from t1", "scan"},
+		{"u1", "db1", "/*+ workload=oltp */ select a from t1", "oltp"},
+		// The hint of the group which doesn't allow the user or database is ignored.
+		{"u1", "db1", "/*+ workload=report */ select a from t1", "default"},
+		{"u1", "db3", "/*+ workload=oltp */ select count(*) from t1", "scan"},
+		{"u1", "db1", "/*+ workload=nothing */ select a from t1", "default"},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.want, w.Match(test.user, test.db, test.query), test.query)
+	}
+}
+
+func TestWorkloadExempt(t *testing.T) {
+	tests := []struct {
+		query  string
+		exempt bool
+	}{
+		{"commit", true},
+		{"  ROLLBACK", true},
+		{"/* c */ begin", true},
+		{"start transaction", true},
+		{"kill query 1", true},
+		{"set autocommit=0", true},
+		{"use db1", true},
+		{"select 1", false},
+		{"/*+ workload=report */ select 1", false},
+		{"settings", false},
+		{"/* c", false},
+		{"", false},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.exempt, workloadExempt(test.query), test.query)
+	}
+}
+
+func TestWorkloadSetError(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	w, err := NewWorkload(log, nil)
+	assert.Nil(t, err)
+
+	confs := []*config.WorkloadConfig{
+		{Groups: []*config.WorkloadGroupConfig{{Name: ""}}},
+		{Groups: []*config.WorkloadGroupConfig{{Name: "g1"}, {Name: "g1"}}},
+		{Groups: []*config.WorkloadGroupConfig{{Name: "g1", Fingerprints: []string{"select ("}}}},
+	}
+	wants := []string{
+		"workload.group.name.can't.be.empty",
+		"workload.group[g1].duplicate",
+		"workload.group[g1].fingerprint[select (].invalid:error parsing regexp: missing closing ): `select (`",
+	}
+	for i, conf := range confs {
+		err := w.Set(conf)
+		assert.Equal(t, wants[i], err.Error())
+	}
+	_, err = NewWorkload(log, confs[0])
+	assert.NotNil(t, err)
+
+	// The groups are unchanged.
+	assert.Equal(t, []WorkloadGroupStatus{{Name: "default", Weight: 1}}, w.Status())
+}
+
+func TestWorkloadSlots(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	conf := &config.WorkloadConfig{
+		Groups: []*config.WorkloadGroupConfig{
+			{Name: "report", Users: []string{"reporter"}, Slots: 1, QueueSize: 1, QueueTimeout: 100},
+		},
+	}
+	w, err := NewWorkload(log, conf)
+	assert.Nil(t, err)
+
+	release1, err := w.Acquire("reporter", "", "select 1")
+	assert.Nil(t, err)
+
+	// The other group is not blocked.
+	release2, err := w.Acquire("u1", "", "select 1")
+	assert.Nil(t, err)
+	release2()
+
+	// Timeout.
+	_, err = w.Acquire("reporter", "", "select 1")
+	assert.Equal(t, "workload group[report] queue wait timeout(100ms) (errno 1105) (sqlstate HY000)", err.Error())
+	assert.Equal(t, 0, w.byName["report"].waiters.Len())
+
+	// Queue full.
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		release, err := w.Acquire("reporter", "", "select 1")
+		assert.Nil(t, err)
+		release()
+	}()
+	for w.Status()[1].QueueDepth == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	_, err = w.Acquire("reporter", "", "select 1")
+	assert.Equal(t, "workload group[report] queue is full(max: 1) (errno 1105) (sqlstate HY000)", err.Error())
+
+	// Release twice is ok.
+	release1()
+	release1()
+	wg.Wait()
+	assert.Equal(t, []WorkloadGroupStatus{{Name: "default", Weight: 1}, {Name: "report", Slots: 1, Weight: 1}}, w.Status())
+	assert.Equal(t, 0, w.running)
+}
+
+func TestWorkloadFairness(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	conf := &config.WorkloadConfig{
+		MaxConcurrency: 1,
+		Groups: []*config.WorkloadGroupConfig{
+			{Name: "report", Users: []string{"reporter"}, Weight: 1},
+			{Name: "oltp", Users: []string{"app"}, Weight: 3},
+		},
+	}
+	w, err := NewWorkload(log, conf)
+	assert.Nil(t, err)
+
+	release, err := w.Acquire("u1", "", "select 1")
+	assert.Nil(t, err)
+
+	// Queue 4 queries of each group while the only slot is held.
+	var mu sync.Mutex
+	var order []string
+	var wg sync.WaitGroup
+	for _, user := range []string{"reporter", "app"} {
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func(user string) {
+				defer wg.Done()
+				release, err := w.Acquire(user, "", "select 1")
+				assert.Nil(t, err)
+				mu.Lock()
+				order = append(order, user)
+				mu.Unlock()
+				release()
+			}(user)
+		}
+	}
+	for {
+		status := w.Status()
+		if status[1].QueueDepth == 4 && status[2].QueueDepth == 4 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	release()
+	wg.Wait()
+
+	// The oltp group gets 3 slots for each slot of the report group.
+	assert.Equal(t, 8, len(order))
+	var apps int
+	for _, user := range order[:4] {
+		if user == "app" {
+			apps++
+		}
+	}
+	assert.Equal(t, 3, apps)
+	assert.Equal(t, 0, w.running)
+}
+
+func TestWorkloadSetMigrate(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	conf := &config.WorkloadConfig{
+		Groups: []*config.WorkloadGroupConfig{
+			{Name: "report", Users: []string{"reporter"}, Slots: 1},
+		},
+	}
+	w, err := NewWorkload(log, conf)
+	assert.Nil(t, err)
+
+	release1, err := w.Acquire("reporter", "", "select 1")
+	assert.Nil(t, err)
+	done := make(chan struct{})
+	go func() {
+		release, err := w.Acquire("reporter", "", "select 1")
+		assert.Nil(t, err)
+		release()
+		close(done)
+	}()
+	for w.Status()[1].QueueDepth == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// The report group is removed, the running and waiting queries move to the default group.
+	err = w.Set(&config.WorkloadConfig{})
+	assert.Nil(t, err)
+	<-done
+	release1()
+	assert.Equal(t, []WorkloadGroupStatus{{Name: "default", Weight: 1}}, w.Status())
+	assert.Equal(t, 0, w.running)
+}
+
+func TestProxyWorkload(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	// fakedbs.
+	{
+		rs := &sqltypes.Result{
+			Fields: []*querypb.Field{{Name: "id", Type: querypb.Type_INT32}},
+			Rows:   [][]sqltypes.Value{{sqltypes.MakeTrusted(querypb.Type_INT32, []byte("1"))}},
+		}
+		fakedbs.AddQueryPattern("select .*", rs)
+	}
+
+	conf := &config.WorkloadConfig{
+		Groups: []*config.WorkloadGroupConfig{
+			{Name: "report", Slots: 1, QueueTimeout: 100},
+		},
+	}
+	err := proxy.SetWorkload(conf)
+	assert.Nil(t, err)
+	assert.Equal(t, conf, proxy.Config().Workload)
+
+	err = proxy.SetWorkload(&config.WorkloadConfig{Groups: []*config.WorkloadGroupConfig{{Name: ""}}})
+	assert.NotNil(t, err)
+	assert.Equal(t, conf, proxy.Config().Workload)
+
+	client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	// The report group takes all the queries.
+	_, err = client.FetchAll("select 1", -1)
+	assert.Nil(t, err)
+
+	// The slot is held, the query times out in the queue.
+	release, err := proxy.Workload().Acquire("mock", "", "select 1")
+	assert.Nil(t, err)
+	_, err = client.FetchAll("select 1", -1)
+	assert.Equal(t, "workload group[report] queue wait timeout(100ms) (errno 1105) (sqlstate HY000)", err.Error())
+	release()
+	_, err = client.FetchAll("select 1", -1)
+	assert.Nil(t, err)
+}
+
+func TestProxyWorkloadExempt(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+	proxy.SetTwoPC(true)
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("XA .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("select .*", &sqltypes.Result{})
+	}
+
+	conf := &config.WorkloadConfig{
+		Groups: []*config.WorkloadGroupConfig{
+			{Name: "report", Slots: 1, QueueTimeout: 100},
+		},
+	}
+	err := proxy.SetWorkload(conf)
+	assert.Nil(t, err)
+
+	client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	release, err := proxy.Workload().Acquire("mock", "", "select 1")
+	assert.Nil(t, err)
+	defer release()
+
+	// The statements in the transaction and the transaction control bypass the queue.
+	queries := []string{
+		"begin",
+		"select 1",
+		"commit",
+		"set autocommit=1",
+	}
+	for _, query := range queries {
+		_, err = client.FetchAll(query, -1)
+		assert.Nil(t, err, query)
+	}
+	_, err = client.FetchAll("kill query 9999", -1)
+	assert.Equal(t, "Unknown thread id: 9999 (errno 1094) (sqlstate HY000)", err.Error())
+
+	_, err = client.FetchAll("select 1", -1)
+	assert.Equal(t, "workload group[report] queue wait timeout(100ms) (errno 1105) (sqlstate HY000)", err.Error())
+}
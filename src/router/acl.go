@@ -13,7 +13,8 @@ import (
 )
 
 var (
-	systemDatabases = []string{"SYS", "MYSQL", "INFORMATION_SCHEMA", "PERFORMANCE_SCHEMA"}
+	// The RADON is reserved for the radon virtual tables, such as 'radon.sessions'.
+	systemDatabases = []string{"SYS", "MYSQL", "INFORMATION_SCHEMA", "PERFORMANCE_SCHEMA", "RADON"}
 )
 
 // DatabaseACL tuple.
@@ -401,7 +401,7 @@ func TestRouterDatabaseACL(t *testing.T) {
 
 	// Not ok.
 	{
-		sysDB := []string{"SYS", "MYSQL", "performance_schema", "information_schema"}
+		sysDB := []string{"SYS", "MYSQL", "performance_schema", "information_schema", "radon"}
 		for _, sys := range sysDB {
 			err := router.DatabaseACL(sys)
 			assert.NotNil(t, err)
@@ -410,7 +410,7 @@ func TestRouterDatabaseACL(t *testing.T) {
 
 	// OK.
 	{
-		sysDB := []string{"SYS1", "MYSQL1", "performance_schema1", "information_schema1"}
+		sysDB := []string{"SYS1", "MYSQL1", "performance_schema1", "information_schema1", "radon1"}
 		for _, sys := range sysDB {
 			err := router.DatabaseACL(sys)
 			assert.Nil(t, err)
@@ -426,7 +426,7 @@ func TestRouterIsSystemDB(t *testing.T) {
 
 	// true.
 	{
-		sysDB := []string{"SYS", "MYSQL", "performance_schema", "information_schema"}
+		sysDB := []string{"SYS", "MYSQL", "performance_schema", "information_schema", "radon"}
 		for _, sys := range sysDB {
 			is := router.IsSystemDB(sys)
 			assert.Equal(t, is, true)
@@ -435,7 +435,7 @@ func TestRouterIsSystemDB(t *testing.T) {
 
 	// false.
 	{
-		sysDB := []string{"SYS1", "MYSQL1", "performance_schema1", "information_schema1"}
+		sysDB := []string{"SYS1", "MYSQL1", "performance_schema1", "information_schema1", "radon1"}
 		for _, sys := range sysDB {
 			is := router.IsSystemDB(sys)
 			assert.Equal(t, is, false)
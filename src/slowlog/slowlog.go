@@ -0,0 +1,254 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package slowlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"config"
+	"monitor"
+	"xbase"
+	"xbase/sync2"
+
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+const (
+	prefix    = "slow-"
+	extension = ".log"
+)
+
+const (
+	// FormatText enum, the MySQL slow log compatible format.
+	FormatText = "text"
+
+	// FormatJSON enum, one json event per line.
+	FormatJSON = "json"
+)
+
+// Backend tuple, the sub-query sent to one backend.
+type Backend struct {
+	Backend   string  `json:"backend"`         // Backend name.
+	Range     string  `json:"range,omitempty"` // Partition range of the sub-query.
+	Query     string  `json:"query"`           // Sub-query.
+	QueryTime float64 `json:"query_time"`      // Query time in seconds.
+	Rows      uint64  `json:"rows"`            // Rows returned.
+	Error     string  `json:"error,omitempty"` // Error of the sub-query.
+}
+
+// Event tuple.
+type Event struct {
+	Start        time.Time  `json:"start"`           // Time the query was start.
+	QueryTime    float64    `json:"query_time"`      // Query time in seconds.
+	User         string     `json:"user"`            // User.
+	Host         string     `json:"host"`            // Client address.
+	DB           string     `json:"db"`              // Current database.
+	ThreadID     uint32     `json:"thread_id"`       // Thread id.
+	Query        string     `json:"query"`           // Full query.
+	RowsSent     uint64     `json:"rows_sent"`       // Rows sent to the client.
+	RowsExamined uint64     `json:"rows_examined"`   // Rows returned or affected by the backends.
+	Fanout       uint64     `json:"fanout"`          // Number of the backend sub-querys.
+	PlanType     string     `json:"plan_type"`       // Plan type, empty if the statement has no plan.
+	Status       uint16     `json:"status"`          // Status of results, if 0 success, else failure.
+	Error        string     `json:"error,omitempty"` // Error of the query.
+	Backends     []*Backend `json:"backends"`        // Backend sub-querys.
+}
+
+// SlowLog tuple.
+type SlowLog struct {
+	log    *xlog.Log
+	conf   *config.SlowLogConfig
+	ticker *time.Ticker
+	queue  chan *Event
+	done   chan bool
+	rfile  xbase.RotateFile
+	wg     sync.WaitGroup
+
+	// mu protects the queue from the events logged after closed.
+	mu      sync.RWMutex
+	closed  bool
+	dropped sync2.AtomicInt64
+}
+
+// NewSlowLog creates the new slow log.
+func NewSlowLog(log *xlog.Log, conf *config.SlowLogConfig) *SlowLog {
+	return &SlowLog{
+		log:    log,
+		conf:   conf,
+		done:   make(chan bool),
+		queue:  make(chan *Event, 1024),
+		ticker: time.NewTicker(time.Duration(time.Second * 300)), // 5 minutes
+		rfile:  xbase.NewRotateFile(conf.LogDir, prefix, extension, conf.MaxSize),
+	}
+}
+
+// Init used to create the log dir if the slow log is enabled.
+func (s *SlowLog) Init() error {
+	log := s.log
+
+	log.Info("slowlog.init.conf:%+v", s.conf)
+	if s.conf.Enable {
+		if err := os.MkdirAll(s.conf.LogDir, 0744); err != nil {
+			return err
+		}
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.eventConsumer()
+	}()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.purge()
+	}()
+	log.Info("slowlog.init.done")
+	return nil
+}
+
+// Enabled returns true if the slow log is enabled.
+func (s *SlowLog) Enabled() bool {
+	return s.conf.Enable
+}
+
+// LogEvent used to log the slow query event, it does nothing if the slow log is disabled.
+// The event is dropped if the queue is full or the slow log is closed, a stalled disk never blocks the queries.
+func (s *SlowLog) LogEvent(e *Event) {
+	if !s.conf.Enable {
+		return
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if !s.closed {
+		select {
+		case s.queue <- e:
+			return
+		default:
+		}
+	}
+	s.dropped.Add(1)
+	monitor.SlowLogDroppedInc()
+}
+
+// Dropped returns the number of the dropped events.
+func (s *SlowLog) Dropped() int64 {
+	return s.dropped.Get()
+}
+
+// Close used to close the slow log.
+func (s *SlowLog) Close() {
+	// wait the queue event flush to file.
+	close(s.done)
+	s.mu.Lock()
+	s.closed = true
+	close(s.queue)
+	s.mu.Unlock()
+	s.wg.Wait()
+	s.rfile.Sync()
+	s.rfile.Close()
+	s.log.Info("slowlog.closed")
+}
+
+func (s *SlowLog) eventConsumer() {
+	for e := range s.queue {
+		s.writeEvent(e)
+	}
+}
+
+func (s *SlowLog) writeEvent(e *Event) {
+	log := s.log
+
+	var b []byte
+	if s.conf.Format == FormatJSON {
+		var err error
+		if b, err = json.Marshal(e); err != nil {
+			b = []byte(err.Error())
+		}
+		b = append(b, '\n')
+	} else {
+		b = formatText(e)
+	}
+
+	// write
+	if _, err := s.rfile.Write(b); err != nil {
+		log.Error("slowlog.write.file.error:%v", err)
+	}
+}
+
+// formatText returns the event in the MySQL slow log format, the radon
+// specific fields are written as the comment lines.
+func formatText(e *Event) []byte {
+	var buf bytes.Buffer
+	oneLine := func(s string) string {
+		return strings.Replace(s, "\n", " ", -1)
+	}
+
+	fmt.Fprintf(&buf, "# Time: %s\n", e.Start.UTC().Format("2006-01-02T15:04:05.000000Z"))
+	fmt.Fprintf(&buf, "# User@Host: %s[%s] @  [%s]  Id: %d\n", e.User, e.User, e.Host, e.ThreadID)
+	fmt.Fprintf(&buf, "# Query_time: %.6f  Lock_time: 0.000000 Rows_sent: %d  Rows_examined: %d\n", e.QueryTime, e.RowsSent, e.RowsExamined)
+	fmt.Fprintf(&buf, "# Fanout: %d  Plan_type: %s  Status: %d\n", e.Fanout, e.PlanType, e.Status)
+	if e.Error != "" {
+		fmt.Fprintf(&buf, "# Error: %s\n", oneLine(e.Error))
+	}
+	for _, back := range e.Backends {
+		fmt.Fprintf(&buf, "# Backend: %s  Range: %s  Query_time: %.6f  Rows: %d", back.Backend, back.Range, back.QueryTime, back.Rows)
+		if back.Error != "" {
+			fmt.Fprintf(&buf, "  Error: %s", oneLine(back.Error))
+		}
+		fmt.Fprintf(&buf, "  Query: %s\n", oneLine(back.Query))
+	}
+	if e.DB != "" {
+		fmt.Fprintf(&buf, "use %s;\n", e.DB)
+	}
+	fmt.Fprintf(&buf, "SET timestamp=%d;\n", e.Start.Unix())
+	buf.WriteString(strings.TrimSuffix(e.Query, ";"))
+	buf.WriteString(";\n")
+	return buf.Bytes()
+}
+
+func (s *SlowLog) purge() {
+	defer s.ticker.Stop()
+	for {
+		select {
+		case <-s.ticker.C:
+			s.doPurge()
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *SlowLog) doPurge() {
+	log := s.log
+	if !s.conf.Enable || s.conf.ExpireHours == 0 {
+		return
+	}
+
+	oldLogs, err := s.rfile.GetOldLogInfos()
+	if err != nil {
+		log.Error("slowlog.get.old.loginfos.error:%v", err)
+		return
+	}
+
+	for _, old := range oldLogs {
+		diff := time.Now().UTC().Sub(time.Unix(0, old.Ts))
+		if int(diff.Hours()) > s.conf.ExpireHours {
+			os.Remove(filepath.Join(s.conf.LogDir, old.Name))
+		}
+	}
+}
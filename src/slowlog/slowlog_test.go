@@ -0,0 +1,202 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package slowlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"config"
+	"fakedb"
+
+	"github.com/fortytw2/leaktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func mockEvent() *Event {
+	return &Event{
+		Start:        time.Date(2018, 1, 2, 3, 4, 5, 6000, time.UTC),
+		QueryTime:    1.5,
+		User:         "mock",
+		Host:         "127.0.0.1:8899",
+		DB:           "db1",
+		ThreadID:     1,
+		Query:        "select *\nfrom t1",
+		RowsSent:     3,
+		RowsExamined: 5,
+		Fanout:       2,
+		PlanType:     "PlanTypeSelect",
+		Backends: []*Backend{
+			{Backend: "backend1", Range: "[0-512)", Query: "select * from t1_0000", QueryTime: 0.5, Rows: 2},
+			{Backend: "backend2", Range: "[512-4096)", Query: "select * from t1_0001", QueryTime: 1.25, Rows: 3, Error: "mock.error"},
+		},
+	}
+}
+
+func readLogs(t *testing.T, dir string) string {
+	files, err := ioutil.ReadDir(dir)
+	assert.Nil(t, err)
+	var data []string
+	for _, file := range files {
+		b, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		assert.Nil(t, err)
+		data = append(data, string(b))
+	}
+	return strings.Join(data, "")
+}
+
+func TestSlowLogText(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	tmpDir := fakedb.GetTmpDir("", "radon_slowlog_", log)
+	defer os.RemoveAll(tmpDir)
+	conf := &config.SlowLogConfig{
+		Enable:      true,
+		Format:      FormatText,
+		MaxSize:     102400,
+		ExpireHours: 1,
+		LogDir:      tmpDir,
+	}
+
+	slowlog := NewSlowLog(log, conf)
+	err := slowlog.Init()
+	assert.Nil(t, err)
+	assert.True(t, slowlog.Enabled())
+	slowlog.LogEvent(mockEvent())
+	slowlog.Close()
+
+	want := "# Time: 2018-01-02T03:04:05.000006Z\n" +
+		"# User@Host: mock[mock] @  [127.0.0.1:8899]  Id: 1\n" +
+		"# Query_time: 1.500000  Lock_time: 0.000000 Rows_sent: 3  Rows_examined: 5\n" +
+		"# Fanout: 2  Plan_type: PlanTypeSelect  Status: 0\n" +
+		"# Backend: backend1  Range: [0-512)  Query_time: 0.500000  Rows: 2  Query: select * from t1_0000\n" +
+		"# Backend: backend2  Range: [512-4096)  Query_time: 1.250000  Rows: 3  Error: mock.error  Query: select * from t1_0001\n" +
+		"use db1;\n" +
+		"SET timestamp=1514862245;\n" +
+		"select *\nfrom t1;\n"
+	assert.Equal(t, want, readLogs(t, tmpDir))
+}
+
+func TestSlowLogJSON(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	tmpDir := fakedb.GetTmpDir("", "radon_slowlog_", log)
+	defer os.RemoveAll(tmpDir)
+	conf := &config.SlowLogConfig{
+		Enable:      true,
+		Format:      FormatJSON,
+		MaxSize:     102400,
+		ExpireHours: 1,
+		LogDir:      tmpDir,
+	}
+
+	slowlog := NewSlowLog(log, conf)
+	err := slowlog.Init()
+	assert.Nil(t, err)
+	e := mockEvent()
+	e.Status = 1
+	e.Error = "mock.query.error"
+	slowlog.LogEvent(e)
+	slowlog.LogEvent(mockEvent())
+	slowlog.Close()
+
+	lines := strings.Split(strings.TrimSuffix(readLogs(t, tmpDir), "\n"), "\n")
+	assert.Equal(t, 2, len(lines))
+	got := &Event{}
+	err = json.Unmarshal([]byte(lines[0]), got)
+	assert.Nil(t, err)
+	assert.Equal(t, e, got)
+	assert.Contains(t, lines[1], `"query_time":1.5,`)
+	assert.NotContains(t, lines[1], `"status":0,"error"`)
+}
+
+func TestSlowLogDisabled(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	tmpDir := fakedb.GetTmpDir("", "radon_slowlog_", log)
+	defer os.RemoveAll(tmpDir)
+	conf := config.DefaultSlowLogConfig()
+	conf.LogDir = filepath.Join(tmpDir, "slowlog")
+
+	slowlog := NewSlowLog(log, conf)
+	err := slowlog.Init()
+	assert.Nil(t, err)
+	assert.False(t, slowlog.Enabled())
+	slowlog.LogEvent(mockEvent())
+	slowlog.Close()
+
+	_, err = os.Stat(conf.LogDir)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestSlowLogDropped(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	tmpDir := fakedb.GetTmpDir("", "radon_slowlog_", log)
+	defer os.RemoveAll(tmpDir)
+	conf := config.DefaultSlowLogConfig()
+	conf.Enable = true
+	conf.LogDir = tmpDir
+
+	// The full queue without the consumer.
+	slowlog := NewSlowLog(log, conf)
+	slowlog.queue = make(chan *Event, 1)
+	slowlog.LogEvent(mockEvent())
+	slowlog.LogEvent(mockEvent())
+	assert.Equal(t, int64(1), slowlog.Dropped())
+
+	// Logged after closed.
+	slowlog.Close()
+	slowlog.LogEvent(mockEvent())
+	assert.Equal(t, int64(2), slowlog.Dropped())
+}
+
+func TestSlowLogPurge(t *testing.T) {
+	fileFormat := "20060102150405.000"
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	tmpDir := fakedb.GetTmpDir("", "radon_slowlog_", log)
+	defer os.RemoveAll(tmpDir)
+	conf := &config.SlowLogConfig{
+		Enable:      true,
+		Format:      FormatText,
+		MaxSize:     10240,
+		ExpireHours: 1,
+		LogDir:      tmpDir,
+	}
+
+	slowlog := NewSlowLog(log, conf)
+	err := slowlog.Init()
+	assert.Nil(t, err)
+	for i := 0; i < 100; i++ {
+		slowlog.LogEvent(mockEvent())
+	}
+	slowlog.Close()
+
+	logs, _ := slowlog.rfile.GetOldLogInfos()
+	assert.True(t, len(logs) > 0)
+	// purge the old log.
+	l0 := logs[0]
+	ts := time.Unix(0, l0.Ts).UTC().Add(time.Duration(time.Hour * time.Duration(-2)))
+	timestamp := ts.Format(fileFormat)
+	newName := filepath.Join(conf.LogDir, fmt.Sprintf("%s%s%s", prefix, timestamp, extension))
+	err = os.Rename(filepath.Join(conf.LogDir, l0.Name), newName)
+	assert.Nil(t, err)
+	slowlog.doPurge()
+
+	logs1, _ := slowlog.rfile.GetOldLogInfos()
+	assert.Equal(t, len(logs)-1, len(logs1))
+}
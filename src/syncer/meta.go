@@ -183,6 +183,11 @@ func (s *Syncer) MetaReload() error {
 	if err := s.peer.LoadConfig(); err != nil {
 		log.Panicf("syncer.meta.peer.load.config.error:%+v", err)
 	}
+	for _, loader := range s.loaders {
+		if err := loader.LoadConfig(); err != nil {
+			log.Panicf("syncer.meta.loader[%T].load.config.error:%+v", loader, err)
+		}
+	}
 	log.Warning("syncer.meta.reload.done...")
 	return nil
 }
@@ -125,3 +125,25 @@ func TestMetaVersionCheck(t *testing.T) {
 	checked, _ = syncer0.MetaVersionCheck()
 	assert.True(t, checked)
 }
+
+type mockLoader struct {
+	loaded int
+}
+
+func (l *mockLoader) LoadConfig() error {
+	l.loaded++
+	return nil
+}
+
+func TestMetaReloadLoader(t *testing.T) {
+	defer testRemoveMetadir()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	syncers, cleanup := mockSyncer(log, 1)
+	defer cleanup()
+
+	loader := &mockLoader{}
+	syncers[0].AddLoader(loader)
+	err := syncers[0].MetaReload()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, loader.loaded)
+}
@@ -23,6 +23,11 @@ import (
 	"github.com/xelabs/go-mysqlstack/xlog"
 )
 
+// Loader is the meta which is reloaded from the metadir after the meta rebuilt.
+type Loader interface {
+	LoadConfig() error
+}
+
 // Syncer tuple.
 type Syncer struct {
 	mu      sync.RWMutex
@@ -34,6 +39,7 @@ type Syncer struct {
 	ticker  *time.Ticker
 	router  *router.Router
 	scatter *backend.Scatter
+	loaders []Loader
 }
 
 // NewSyncer creates the new syncer.
@@ -88,6 +94,13 @@ func (s *Syncer) Close() {
 	s.wg.Wait()
 }
 
+// AddLoader used to add the meta loader which is reloaded by the syncer, such as the privilege catalog.
+func (s *Syncer) AddLoader(loader Loader) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loaders = append(s.loaders, loader)
+}
+
 // AddPeer used to add new peer to syncer.
 func (s *Syncer) AddPeer(peer string) error {
 	return s.peer.Add(peer)
@@ -0,0 +1,139 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package xbase
+
+import (
+	"strings"
+)
+
+func isFingerprintSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isFingerprintWord(c byte) bool {
+	return c == '_' || c == '$' || c == '@' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+// Fingerprint returns the fingerprint of the query, the literals are replaced by '?', the lists
+// of '?' are collapsed into '?+', the comments are removed, the spaces are collapsed and the words
+// outside the backquotes are lowercased, such as:
+// "SELECT * FROM t1 WHERE a IN (1, 'x') /* c */" -> "select * from t1 where a in (?+)".
+func Fingerprint(query string) string {
+	var buf []byte
+	space := false
+	emit := func(s string) {
+		// No space around the ',' and inside the parentheses.
+		if space && len(buf) > 0 && s != "," && s != ")" {
+			if last := buf[len(buf)-1]; last != '(' && last != ',' {
+				buf = append(buf, ' ')
+			}
+		}
+		space = false
+		buf = append(buf, s...)
+	}
+
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case isFingerprintSpace(c):
+			space = true
+			i++
+		case c == '/' && i+1 < len(query) && query[i+1] == '*':
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				i = len(query)
+			} else {
+				i += end + 4
+			}
+			space = true
+		case c == '#' || (c == '-' && i+2 < len(query) && query[i+1] == '-' && isFingerprintSpace(query[i+2])):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				i = len(query)
+			} else {
+				i += end + 1
+			}
+			space = true
+		case c == '\'' || c == '"' || c == '`':
+			j := i + 1
+			for ; j < len(query); j++ {
+				if query[j] == '\\' && c != '`' {
+					j++
+					continue
+				}
+				if query[j] == c {
+					if j+1 < len(query) && query[j+1] == c {
+						j++
+						continue
+					}
+					break
+				}
+			}
+			if j >= len(query) {
+				j = len(query) - 1
+			}
+			if c == '`' {
+				emit(query[i : j+1])
+			} else {
+				emit("?")
+			}
+			i = j + 1
+		case c >= '0' && c <= '9', c == '.' && i+1 < len(query) && query[i+1] >= '0' && query[i+1] <= '9':
+			// The number, or the hex/bit literal such as 0x1f.
+			j := i
+			for j < len(query) && (isFingerprintWord(query[j]) || query[j] == '.') {
+				j++
+			}
+			emit("?")
+			i = j
+		case isFingerprintWord(c):
+			j := i
+			for j < len(query) && isFingerprintWord(query[j]) {
+				j++
+			}
+			word := strings.ToLower(query[i:j])
+			// The hex or bit string literal, such as x'1f' and b'01'.
+			if (word == "x" || word == "b") && j < len(query) && query[j] == '\'' {
+				i = j
+				continue
+			}
+			if word == "null" {
+				word = "?"
+			}
+			emit(word)
+			i = j
+		default:
+			emit(string(c))
+			i++
+		}
+	}
+	return collapseFingerprintList(strings.TrimSuffix(string(buf), ";"))
+}
+
+// collapseFingerprintList used to collapse the '?,?,...' into '?+'.
+func collapseFingerprintList(fp string) string {
+	if !strings.Contains(fp, "?,?") {
+		return fp
+	}
+	var buf strings.Builder
+	for i := 0; i < len(fp); {
+		if strings.HasPrefix(fp[i:], "?,?") {
+			j := i + 1
+			for strings.HasPrefix(fp[j:], ",?") {
+				j += 2
+			}
+			buf.WriteString("?+")
+			i = j
+			continue
+		}
+		buf.WriteByte(fp[i])
+		i++
+	}
+	return buf.String()
+}
@@ -0,0 +1,35 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package xbase
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFingerprint(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"SELECT * FROM t1 WHERE a IN (1, 'x') /* c */", "select * from t1 where a in (?+)"},
+		{"select  a,b\n from `DB1`.`T1` where id = 10 and name='it''s' and c=\"x\\\"y\";", "select a,b from `DB1`.`T1` where id = ? and name=? and c=?"},
+		{"insert into t1(a, b) values (1, 2.5), (0x1f, x'1f')", "insert into t1(a,b) values (?+),(?+)"},
+		{"select count(*) from t1 -- comment\n where a is null # comment", "select count(*) from t1 where a is ?"},
+		{"select a1, t2.b from t1 where a1 > -1 and b < .5", "select a1,t2.b from t1 where a1 > -? and b < ?"},
+		{"/*+ workload=report */ select 1", "select ?"},
+		{"select 'unterminated", "select ?"},
+		{"", ""},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.want, Fingerprint(test.query), test.query)
+	}
+	assert.Equal(t, Fingerprint("select * from t1 where id=1"), Fingerprint("SELECT *  FROM t1 WHERE id=2"))
+}
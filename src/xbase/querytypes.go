@@ -1,5 +1,9 @@
 package xbase
 
+import (
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+)
+
 const (
 	// UNSUPPORT type.
 	UNSUPPORT = "UNSUPPORT"
@@ -57,4 +61,40 @@ const (
 
 	// RADON type
 	RADON = "RADON"
+
+	// FIREWALL type, the statement denied by the firewall.
+	FIREWALL = "FIREWALL"
+
+	// ADMIN type, the administrative action of the REST API and the RADON statements.
+	ADMIN = "ADMIN"
 )
+
+// StatementType returns the query type of the statement.
+func StatementType(node sqlparser.Statement) string {
+	switch node := node.(type) {
+	case *sqlparser.Select, *sqlparser.Union:
+		return SELECT
+	case *sqlparser.Insert:
+		if node.Action == sqlparser.ReplaceStr {
+			return REPLACE
+		}
+		return INSERT
+	case *sqlparser.Update:
+		return UPDATE
+	case *sqlparser.Delete:
+		return DELETE
+	case *sqlparser.DDL:
+		return DDL
+	case *sqlparser.Show:
+		return SHOW
+	case *sqlparser.Use:
+		return USEDB
+	case *sqlparser.Set:
+		return SET
+	case *sqlparser.Explain:
+		return EXPLAIN
+	case *sqlparser.Transaction:
+		return TRANSACTION
+	}
+	return RADON
+}
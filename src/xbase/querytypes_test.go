@@ -0,0 +1,42 @@
+/*
+ * Radon
+ *
+ * Copyright 2018-2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package xbase
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+)
+
+func TestStatementType(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"select 1", SELECT},
+		{"select 1 union select 2", SELECT},
+		{"insert into t1 values(1)", INSERT},
+		{"replace into t1 values(1)", REPLACE},
+		{"update t1 set a=1", UPDATE},
+		{"delete from t1", DELETE},
+		{"create table t1(a int)", DDL},
+		{"show databases", SHOW},
+		{"use db1", USEDB},
+		{"set autocommit=1", SET},
+		{"explain select 1", EXPLAIN},
+		{"begin", TRANSACTION},
+		{"kill 1", RADON},
+	}
+	for _, test := range tests {
+		node, err := sqlparser.Parse(test.query)
+		assert.Nil(t, err)
+		assert.Equal(t, test.want, StatementType(node), test.query)
+	}
+}
@@ -0,0 +1,84 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package xbase
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+)
+
+// loadCertPool used to load the CA certs from the PEM file.
+func loadCertPool(ca string) (*x509.CertPool, error) {
+	data, err := ioutil.ReadFile(ca)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, errors.Errorf("tls.ca[%s].has.no.valid.cert", ca)
+	}
+	return pool, nil
+}
+
+// NewServerTLSConfig creates the server TLS config from the PEM files.
+// If the ca is not empty, the client certs are verified by it, and
+// the client must present a cert if verifyClient is true.
+func NewServerTLSConfig(cert, key, ca string, verifyClient bool) (*tls.Config, error) {
+	pair, err := tls.LoadX509KeyPair(cert, key)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{pair},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if ca != "" {
+		if config.ClientCAs, err = loadCertPool(ca); err != nil {
+			return nil, err
+		}
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	if verifyClient {
+		if ca == "" {
+			return nil, errors.New("tls.verify.client.requires.the.ca")
+		}
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+// NewClientTLSConfig creates the client TLS config from the PEM files.
+// If the ca is empty, the server cert is verified by the system roots.
+// The cert and key are optional, used if the server verifies the client.
+func NewClientTLSConfig(ca, cert, key, serverName string, skipVerify bool) (*tls.Config, error) {
+	var err error
+
+	config := &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: skipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+	if ca != "" {
+		if config.RootCAs, err = loadCertPool(ca); err != nil {
+			return nil, err
+		}
+	}
+	if cert != "" || key != "" {
+		pair, err := tls.LoadX509KeyPair(cert, key)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		config.Certificates = []tls.Certificate{pair}
+	}
+	return config, nil
+}
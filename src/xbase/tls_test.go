@@ -0,0 +1,138 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package xbase
+
+import (
+	"crypto/tls"
+	"net"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestTLSConfig(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	tmpDir := getTmpDir("", "xbase_tls_", log)
+	defer os.RemoveAll(tmpDir)
+
+	ca, cert, key, err := MockTLSFiles(tmpDir)
+	assert.Nil(t, err)
+
+	serverConf, err := NewServerTLSConfig(cert, key, ca, true)
+	assert.Nil(t, err)
+	assert.Equal(t, tls.RequireAndVerifyClientCert, serverConf.ClientAuth)
+	clientConf, err := NewClientTLSConfig(ca, cert, key, "127.0.0.1", false)
+	assert.Nil(t, err)
+
+	// Handshake with the client cert verified.
+	{
+		l, err := tls.Listen("tcp", "127.0.0.1:0", serverConf)
+		assert.Nil(t, err)
+		defer l.Close()
+
+		done := make(chan error, 1)
+		go func() {
+			c, err := l.Accept()
+			if err != nil {
+				done <- err
+				return
+			}
+			defer c.Close()
+			done <- c.(*tls.Conn).Handshake()
+		}()
+
+		c, err := tls.Dial("tcp", l.Addr().String(), clientConf)
+		assert.Nil(t, err)
+		assert.Nil(t, <-done)
+		c.Close()
+	}
+
+	// The client cert is optional without verifyClient.
+	{
+		conf, err := NewServerTLSConfig(cert, key, ca, false)
+		assert.Nil(t, err)
+		assert.Equal(t, tls.VerifyClientCertIfGiven, conf.ClientAuth)
+
+		conf, err = NewServerTLSConfig(cert, key, "", false)
+		assert.Nil(t, err)
+		assert.Equal(t, tls.NoClientCert, conf.ClientAuth)
+	}
+}
+
+func TestTLSConfigError(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	tmpDir := getTmpDir("", "xbase_tls_", log)
+	defer os.RemoveAll(tmpDir)
+
+	ca, cert, key, err := MockTLSFiles(tmpDir)
+	assert.Nil(t, err)
+	notExists := path.Join(tmpDir, "not.exists.pem")
+
+	// Server.
+	{
+		_, err := NewServerTLSConfig(notExists, key, ca, false)
+		assert.NotNil(t, err)
+
+		_, err = NewServerTLSConfig(cert, key, notExists, false)
+		assert.NotNil(t, err)
+
+		// The key isn't a cert.
+		_, err = NewServerTLSConfig(cert, key, key, false)
+		assert.NotNil(t, err)
+
+		_, err = NewServerTLSConfig(cert, key, "", true)
+		assert.NotNil(t, err)
+	}
+
+	// Client.
+	{
+		_, err := NewClientTLSConfig(notExists, "", "", "", false)
+		assert.NotNil(t, err)
+
+		_, err = NewClientTLSConfig(ca, cert, "", "", false)
+		assert.NotNil(t, err)
+
+		conf, err := NewClientTLSConfig("", "", "", "localhost", true)
+		assert.Nil(t, err)
+		assert.True(t, conf.InsecureSkipVerify)
+	}
+
+	// Unknown CA.
+	{
+		otherDir := path.Join(tmpDir, "other")
+		assert.Nil(t, os.MkdirAll(otherDir, 0777))
+		otherCA, _, _, err := MockTLSFiles(otherDir)
+		assert.Nil(t, err)
+
+		serverConf, err := NewServerTLSConfig(cert, key, "", false)
+		assert.Nil(t, err)
+		clientConf, err := NewClientTLSConfig(otherCA, "", "", "127.0.0.1", false)
+		assert.Nil(t, err)
+
+		l, err := tls.Listen("tcp", "127.0.0.1:0", serverConf)
+		assert.Nil(t, err)
+		defer l.Close()
+		go func() {
+			c, err := l.Accept()
+			if err == nil {
+				c.(*tls.Conn).Handshake()
+				c.Close()
+			}
+		}()
+
+		c, err := net.Dial("tcp", l.Addr().String())
+		assert.Nil(t, err)
+		err = tls.Client(c, clientConf).Handshake()
+		assert.NotNil(t, err)
+		c.Close()
+	}
+}
@@ -0,0 +1,130 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package xcontext
+
+import (
+	"sync"
+	"time"
+
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// QueryProfile tuple, the execution statistics of one query on the backend.
+type QueryProfile struct {
+	Query   string
+	Backend string
+	Range   string
+	Rows    uint64
+	Bytes   uint64
+	Latency time.Duration
+	Error   string
+}
+
+// OperatorProfile tuple, the execution statistics of one operator.
+type OperatorProfile struct {
+	Name  string
+	Loops int
+	Rows  uint64
+	Time  time.Duration
+}
+
+// Profile tuple, the execution statistics of a plan node, used by the EXPLAIN ANALYZE.
+// All the methods are nil-safe, a nil profile records nothing.
+type Profile struct {
+	mu        sync.Mutex
+	Name      string
+	Loops     int
+	Rows      uint64
+	Time      time.Duration
+	Querys    []*QueryProfile
+	Operators []*OperatorProfile
+	Children  []*Profile
+}
+
+// NewProfile creates the root profile.
+func NewProfile(name string) *Profile {
+	return &Profile{Name: name}
+}
+
+// NewChild creates a child profile of p.
+func (p *Profile) NewChild(name string) *Profile {
+	if p == nil {
+		return nil
+	}
+	child := NewProfile(name)
+	p.mu.Lock()
+	p.Children = append(p.Children, child)
+	p.mu.Unlock()
+	return child
+}
+
+// Finish records one loop of the node which started at start.
+func (p *Profile) Finish(start time.Time, qr *sqltypes.Result) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.Loops++
+	p.Time += time.Since(start)
+	if qr != nil {
+		p.Rows += uint64(len(qr.Rows))
+	}
+}
+
+// AddQuery records the query executed on the backend.
+func (p *Profile) AddQuery(q *QueryProfile) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.Querys = append(p.Querys, q)
+	p.mu.Unlock()
+}
+
+// AddOperator records one loop of the operator which started at start,
+// the loops of the same operator are accumulated.
+func (p *Profile) AddOperator(name string, start time.Time, qr *sqltypes.Result) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var op *OperatorProfile
+	for _, o := range p.Operators {
+		if o.Name == name {
+			op = o
+			break
+		}
+	}
+	if op == nil {
+		op = &OperatorProfile{Name: name}
+		p.Operators = append(p.Operators, op)
+	}
+	op.Loops++
+	op.Time += time.Since(start)
+	if qr != nil {
+		op.Rows += uint64(len(qr.Rows))
+	}
+}
+
+// ResultBytes returns the raw bytes of the rows in qr.
+func ResultBytes(qr *sqltypes.Result) uint64 {
+	var n uint64
+	if qr == nil {
+		return n
+	}
+	for _, row := range qr.Rows {
+		for _, v := range row {
+			n += uint64(v.Len())
+		}
+	}
+	return n
+}
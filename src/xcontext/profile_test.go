@@ -0,0 +1,55 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package xcontext
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+func TestProfile(t *testing.T) {
+	qr := &sqltypes.Result{
+		Rows: [][]sqltypes.Value{
+			{sqltypes.NewInt64(1), sqltypes.NewVarChar("abc")},
+			{sqltypes.NewInt64(22), sqltypes.NULL},
+		},
+	}
+
+	root := NewProfile("Select")
+	child := root.NewChild("Merge")
+	child.AddQuery(&QueryProfile{Query: "select 1", Backend: "backend0", Rows: 2})
+	child.AddOperator("Limit", time.Now(), qr)
+	child.AddOperator("Limit", time.Now(), qr)
+	child.AddOperator("OrderBy", time.Now(), nil)
+	child.Finish(time.Now(), qr)
+	root.Finish(time.Now(), qr)
+
+	assert.Equal(t, 1, len(root.Children))
+	assert.Equal(t, 1, root.Loops)
+	assert.Equal(t, uint64(2), root.Rows)
+	assert.Equal(t, 1, len(child.Querys))
+	assert.Equal(t, 2, len(child.Operators))
+	assert.Equal(t, 2, child.Operators[0].Loops)
+	assert.Equal(t, uint64(4), child.Operators[0].Rows)
+	assert.Equal(t, uint64(0), child.Operators[1].Rows)
+	assert.Equal(t, uint64(6), ResultBytes(qr))
+	assert.Equal(t, uint64(0), ResultBytes(nil))
+}
+
+func TestProfileNil(t *testing.T) {
+	var p *Profile
+	child := p.NewChild("Merge")
+	assert.Nil(t, child)
+	child.AddQuery(&QueryProfile{})
+	child.AddOperator("Limit", time.Now(), nil)
+	child.Finish(time.Now(), nil)
+}
@@ -0,0 +1,132 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package xcontext
+
+import (
+	"sync"
+
+	"xbase/sync2"
+)
+
+// ExecStats tuple, the backend querys stats of one statement.
+// All the methods are safe on the nil ExecStats.
+type ExecStats struct {
+	querys sync2.AtomicInt64
+	rows   sync2.AtomicInt64
+
+	// The details are collected only if the detail is set by Reset, such as the slow log is enabled.
+	mu       sync.Mutex
+	detail   bool
+	planType string
+	profiles []*QueryProfile
+}
+
+// NewExecStats creates the ExecStats.
+func NewExecStats() *ExecStats {
+	return &ExecStats{}
+}
+
+// Record used to record one backend query and the rows it returned or affected.
+func (s *ExecStats) Record(rows uint64) {
+	if s == nil {
+		return
+	}
+	s.querys.Add(1)
+	s.rows.Add(int64(rows))
+}
+
+// Reset used to reset the stats before the statement executes,
+// if detail is true the plan type and the backend query profiles are collected.
+func (s *ExecStats) Reset(detail bool) {
+	if s == nil {
+		return
+	}
+	s.querys.Set(0)
+	s.rows.Set(0)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.detail = detail
+	s.planType = ""
+	s.profiles = nil
+}
+
+// Detailed returns true if the details should be collected.
+func (s *ExecStats) Detailed() bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.detail
+}
+
+// AddQuery used to add the profile of one backend query if the details are collected.
+func (s *ExecStats) AddQuery(prof *QueryProfile) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.detail {
+		s.profiles = append(s.profiles, prof)
+	}
+}
+
+// QueryProfiles returns the profiles of the backend querys.
+func (s *ExecStats) QueryProfiles() []*QueryProfile {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.profiles
+}
+
+// SetPlanType used to set the plan type of the statement, the types of the
+// multiple plans are joined by ','.
+func (s *ExecStats) SetPlanType(planType string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.detail {
+		if s.planType != "" {
+			planType = s.planType + "," + planType
+		}
+		s.planType = planType
+	}
+}
+
+// PlanType returns the plan type of the statement.
+func (s *ExecStats) PlanType() string {
+	if s == nil {
+		return ""
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.planType
+}
+
+// Querys returns the number of the querys sent to the backends.
+func (s *ExecStats) Querys() uint64 {
+	if s == nil {
+		return 0
+	}
+	return uint64(s.querys.Get())
+}
+
+// Rows returns the number of the rows examined by the backends.
+func (s *ExecStats) Rows() uint64 {
+	if s == nil {
+		return 0
+	}
+	return uint64(s.rows.Get())
+}
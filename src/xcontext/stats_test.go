@@ -0,0 +1,63 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package xcontext
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecStats(t *testing.T) {
+	stats := NewExecStats()
+	stats.Record(3)
+	stats.Record(0)
+	assert.Equal(t, uint64(2), stats.Querys())
+	assert.Equal(t, uint64(3), stats.Rows())
+
+	stats.Reset(false)
+	assert.Equal(t, uint64(0), stats.Querys())
+	assert.Equal(t, uint64(0), stats.Rows())
+
+	// Details are ignored.
+	stats.SetPlanType("SELECT")
+	stats.AddQuery(&QueryProfile{Query: "select 1"})
+	assert.False(t, stats.Detailed())
+	assert.Equal(t, "", stats.PlanType())
+	assert.Nil(t, stats.QueryProfiles())
+}
+
+func TestExecStatsDetail(t *testing.T) {
+	stats := NewExecStats()
+	stats.Reset(true)
+	assert.True(t, stats.Detailed())
+	stats.SetPlanType("SELECT")
+	stats.SetPlanType("UPDATE")
+	stats.AddQuery(&QueryProfile{Query: "select 1", Backend: "backend1"})
+	assert.Equal(t, "SELECT,UPDATE", stats.PlanType())
+	assert.Equal(t, 1, len(stats.QueryProfiles()))
+	assert.Equal(t, "backend1", stats.QueryProfiles()[0].Backend)
+
+	stats.Reset(true)
+	assert.Equal(t, "", stats.PlanType())
+	assert.Nil(t, stats.QueryProfiles())
+}
+
+func TestExecStatsNil(t *testing.T) {
+	var stats *ExecStats
+	stats.Record(1)
+	stats.Reset(true)
+	stats.SetPlanType("SELECT")
+	stats.AddQuery(&QueryProfile{})
+	assert.False(t, stats.Detailed())
+	assert.Equal(t, "", stats.PlanType())
+	assert.Nil(t, stats.QueryProfiles())
+	assert.Equal(t, uint64(0), stats.Querys())
+	assert.Equal(t, uint64(0), stats.Rows())
+}
@@ -43,6 +43,9 @@ const (
 // ResultContext tuple.
 type ResultContext struct {
 	Results *sqltypes.Result
+
+	// Profile collects the execution statistics if not nil.
+	Profile *Profile
 }
 
 // NewResultContext returns the result context.
@@ -56,6 +59,9 @@ type RequestContext struct {
 	Mode     RequestMode
 	TxnMode  TxnMode
 	Querys   []QueryTuple
+
+	// Profile collects the backend querys statistics if not nil.
+	Profile *Profile
 }
 
 // NewRequestContext creates RequestContext
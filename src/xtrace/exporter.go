@@ -0,0 +1,188 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package xtrace
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// exporter interface.
+type exporter interface {
+	export(service string, spans []*Span) error
+	close()
+}
+
+// The OTLP JSON encoding of the ExportTraceServiceRequest, the ids are hex and
+// the 64-bit integers are decimal strings.
+type otlpRequest struct {
+	ResourceSpans []*otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource      `json:"resource"`
+	ScopeSpans []*otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []*otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope   `json:"scope"`
+	Spans []*otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              SpanKind        `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []*otlpKeyValue `json:"attributes,omitempty"`
+	Status            *otlpStatus     `json:"status,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+type otlpStatus struct {
+	// 2 is STATUS_CODE_ERROR.
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+func otlpValue(value interface{}) map[string]interface{} {
+	switch v := value.(type) {
+	case string:
+		return map[string]interface{}{"stringValue": v}
+	case bool:
+		return map[string]interface{}{"boolValue": v}
+	case int, int32, int64, uint, uint32, uint64:
+		return map[string]interface{}{"intValue": fmt.Sprintf("%d", v)}
+	case float32, float64:
+		return map[string]interface{}{"doubleValue": v}
+	default:
+		return map[string]interface{}{"stringValue": fmt.Sprintf("%v", v)}
+	}
+}
+
+// encodeSpans returns the OTLP JSON request of the spans.
+func encodeSpans(service string, spans []*Span) ([]byte, error) {
+	scope := &otlpScopeSpans{Scope: otlpScope{Name: "radon"}}
+	for _, s := range spans {
+		s.mu.Lock()
+		span := &otlpSpan{
+			TraceID:           s.traceID.String(),
+			SpanID:            s.spanID.String(),
+			Name:              s.name,
+			Kind:              s.kind,
+			StartTimeUnixNano: fmt.Sprintf("%d", s.start.UnixNano()),
+			EndTimeUnixNano:   fmt.Sprintf("%d", s.end.UnixNano()),
+		}
+		if s.parentID.IsValid() {
+			span.ParentSpanID = s.parentID.String()
+		}
+		for _, attr := range s.attrs {
+			span.Attributes = append(span.Attributes, &otlpKeyValue{Key: attr.key, Value: otlpValue(attr.value)})
+		}
+		if s.err != "" {
+			span.Status = &otlpStatus{Code: 2, Message: s.err}
+		}
+		s.mu.Unlock()
+		scope.Spans = append(scope.Spans, span)
+	}
+
+	req := &otlpRequest{
+		ResourceSpans: []*otlpResourceSpans{
+			{
+				Resource: otlpResource{
+					Attributes: []*otlpKeyValue{{Key: "service.name", Value: otlpValue(service)}},
+				},
+				ScopeSpans: []*otlpScopeSpans{scope},
+			},
+		},
+	}
+	return json.Marshal(req)
+}
+
+// otlpExporter exports the spans to the OTLP/HTTP endpoint.
+type otlpExporter struct {
+	endpoint string
+	client   *http.Client
+}
+
+func newOTLPExporter(endpoint string) *otlpExporter {
+	return &otlpExporter{
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+func (e *otlpExporter) export(service string, spans []*Span) error {
+	body, err := encodeSpans(service, spans)
+	if err != nil {
+		return err
+	}
+	resp, err := e.client.Post(e.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return errors.Errorf("otlp.export.to[%s].status[%d]:%s", e.endpoint, resp.StatusCode, msg)
+	}
+	return nil
+}
+
+func (e *otlpExporter) close() {
+}
+
+// fileExporter exports the spans to the file, one request per line.
+type fileExporter struct {
+	file *os.File
+}
+
+func newFileExporter(path string) (*fileExporter, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &fileExporter{file: file}, nil
+}
+
+func (e *fileExporter) export(service string, spans []*Span) error {
+	body, err := encodeSpans(service, spans)
+	if err != nil {
+		return err
+	}
+	_, err = e.file.Write(append(body, '\n'))
+	return err
+}
+
+func (e *fileExporter) close() {
+	e.file.Sync()
+	e.file.Close()
+}
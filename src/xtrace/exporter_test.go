@@ -0,0 +1,96 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package xtrace
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+// collector is the OTLP/HTTP collector stub.
+type collector struct {
+	mu       sync.Mutex
+	status   int
+	requests []*otlpRequest
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	body, _ := ioutil.ReadAll(r.Body)
+	req := &otlpRequest{}
+	if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" || json.Unmarshal(body, req) != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if c.status != 0 {
+		w.WriteHeader(c.status)
+		return
+	}
+	c.requests = append(c.requests, req)
+}
+
+func TestOTLPExporter(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	stub := &collector{}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	conf := config.DefaultTraceConfig()
+	conf.Enable = true
+	conf.Endpoint = server.URL + "/v1/traces"
+	conf.ServiceName = "radon-test"
+	tracer := NewTracer(log, conf)
+	err := tracer.Init()
+	assert.Nil(t, err)
+
+	for i := 0; i < batchSize+1; i++ {
+		span := tracer.StartSpan("proxy.ComQuery", nil, time.Now())
+		span.SetAttribute("i", i)
+		span.End()
+	}
+	tracer.Close()
+
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+	count := 0
+	for _, req := range stub.requests {
+		assert.Equal(t, "radon-test", req.ResourceSpans[0].Resource.Attributes[0].Value["stringValue"])
+		count += len(req.ResourceSpans[0].ScopeSpans[0].Spans)
+	}
+	assert.True(t, len(stub.requests) >= 2)
+	assert.Equal(t, batchSize+1, count)
+}
+
+func TestOTLPExporterError(t *testing.T) {
+	stub := &collector{status: http.StatusServiceUnavailable}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	tracer := &Tracer{}
+	span := &Span{tracer: tracer, traceID: newTraceID(), spanID: newSpanID(), name: "x"}
+	exporter := newOTLPExporter(server.URL + "/v1/traces")
+	err := exporter.export("radon", []*Span{span})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "status[503]")
+
+	exporter = newOTLPExporter(server.URL + "/xx")
+	err = exporter.export("radon", []*Span{span})
+	assert.Contains(t, err.Error(), "status[400]")
+}
@@ -0,0 +1,202 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package xtrace
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// SpanKind type, same as the OTLP span kind.
+type SpanKind int
+
+const (
+	// SpanKindInternal enum.
+	SpanKindInternal SpanKind = 1
+
+	// SpanKindServer enum, the span of the client statement.
+	SpanKindServer SpanKind = 2
+
+	// SpanKindClient enum, the span of the backend query.
+	SpanKindClient SpanKind = 3
+)
+
+// TraceID type.
+type TraceID [16]byte
+
+// String returns the hex of the trace id.
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// SpanID type.
+type SpanID [8]byte
+
+// String returns the hex of the span id.
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// IsValid returns true if the span id isn't all zeros.
+func (id SpanID) IsValid() bool {
+	return id != SpanID{}
+}
+
+// SpanContext tuple, the W3C trace context.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// traceparentRegexp matches the traceparent in the SQL comment, such as:
+// /* traceparent='00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01' */
+var traceparentRegexp = regexp.MustCompile(`(?is)/\*.*?\btraceparent\s*=\s*'?([0-9a-f]{2})-([0-9a-f]{32})-([0-9a-f]{16})-([0-9a-f]{2})'?.*?\*/`)
+
+// ParseTraceparent returns the span context of the traceparent in the SQL comment,
+// nil if the query has no valid traceparent.
+func ParseTraceparent(query string) *SpanContext {
+	m := traceparentRegexp.FindStringSubmatch(query)
+	if m == nil || m[1] == "ff" {
+		return nil
+	}
+
+	sc := &SpanContext{}
+	hex.Decode(sc.TraceID[:], []byte(m[2]))
+	hex.Decode(sc.SpanID[:], []byte(m[3]))
+	if sc.TraceID == (TraceID{}) || !sc.SpanID.IsValid() {
+		return nil
+	}
+	flags, _ := hex.DecodeString(m[4])
+	sc.Sampled = flags[0]&0x01 == 0x01
+	return sc
+}
+
+// attribute tuple.
+type attribute struct {
+	key   string
+	value interface{}
+}
+
+// Span tuple, the timed operation of a trace.
+// All the methods are safe on the nil Span, which is the span not sampled.
+type Span struct {
+	tracer   *Tracer
+	traceID  TraceID
+	spanID   SpanID
+	parentID SpanID
+	name     string
+	kind     SpanKind
+	start    time.Time
+	end      time.Time
+
+	mu    sync.Mutex
+	ended bool
+	attrs []attribute
+	err   string
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		rand.Read(id[:])
+	}
+	return id
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for id == (TraceID{}) {
+		rand.Read(id[:])
+	}
+	return id
+}
+
+// Context returns the span context.
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return SpanContext{TraceID: s.traceID, SpanID: s.spanID, Sampled: true}
+}
+
+// StartChild used to start the child span.
+func (s *Span) StartChild(name string) *Span {
+	return s.StartChildAt(name, time.Now())
+}
+
+// StartChildAt used to start the child span at the time.
+func (s *Span) StartChildAt(name string, start time.Time) *Span {
+	if s == nil {
+		return nil
+	}
+	return &Span{
+		tracer:   s.tracer,
+		traceID:  s.traceID,
+		spanID:   newSpanID(),
+		parentID: s.spanID,
+		name:     name,
+		kind:     SpanKindInternal,
+		start:    start,
+	}
+}
+
+// SetKind used to set the kind of the span.
+func (s *Span) SetKind(kind SpanKind) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.kind = kind
+}
+
+// SetAttribute used to set the attribute, the value is string, bool, integer or float.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attrs = append(s.attrs, attribute{key: key, value: value})
+}
+
+// SetError used to set the error status of the span, nil error is ignored.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err.Error()
+}
+
+// End used to end the span and send it to the exporter.
+func (s *Span) End() {
+	s.EndAt(time.Now())
+}
+
+// EndAt used to end the span at the time, the span is exported only once.
+func (s *Span) EndAt(end time.Time) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = end
+	s.mu.Unlock()
+	s.tracer.export(s)
+}
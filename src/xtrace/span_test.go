@@ -0,0 +1,59 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package xtrace
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		query   string
+		traceID string
+		spanID  string
+		sampled bool
+	}{
+		{"/* traceparent='00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01' */ select 1", "0af7651916cd43dd8448eb211c80319c", "b7ad6b7169203331", true},
+		{"select 1 /*app='x',traceparent=00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-00*/", "0af7651916cd43dd8448eb211c80319c", "b7ad6b7169203331", false},
+		{"select /*\n TRACEPARENT = '00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-03' */ 1", "0af7651916cd43dd8448eb211c80319c", "b7ad6b7169203331", true},
+	}
+	for _, test := range tests {
+		sc := ParseTraceparent(test.query)
+		assert.NotNil(t, sc, test.query)
+		assert.Equal(t, test.traceID, sc.TraceID.String())
+		assert.Equal(t, test.spanID, sc.SpanID.String())
+		assert.Equal(t, test.sampled, sc.Sampled)
+	}
+
+	invalids := []string{
+		"select 1",
+		"select 'traceparent=00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01'",
+		"/* traceparent=ff-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01 */ select 1",
+		"/* traceparent=00-00000000000000000000000000000000-b7ad6b7169203331-01 */ select 1",
+		"/* traceparent=00-0af7651916cd43dd8448eb211c80319c-0000000000000000-01 */ select 1",
+		"/* traceparent=00-0af7651916cd43dd8448eb211c8031-b7ad6b7169203331-01 */ select 1",
+	}
+	for _, query := range invalids {
+		assert.Nil(t, ParseTraceparent(query), query)
+	}
+}
+
+func TestSpanNil(t *testing.T) {
+	var span *Span
+	child := span.StartChild("child")
+	assert.Nil(t, child)
+	span.SetKind(SpanKindClient)
+	span.SetAttribute("k", "v")
+	span.SetError(errors.New("mock.error"))
+	span.End()
+	assert.Equal(t, SpanContext{}, span.Context())
+}
@@ -0,0 +1,190 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package xtrace
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"config"
+	"xbase/sync2"
+
+	"github.com/pkg/errors"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+const (
+	// The max number of the spans in one export request.
+	batchSize = 512
+
+	// The spans are dropped if the queue is full, the tracing never blocks the statement.
+	queueSize = 4096
+
+	flushInterval = time.Second
+)
+
+const (
+	// ExporterOTLP enum, exports to the OTLP/HTTP endpoint with the JSON encoding.
+	ExporterOTLP = "otlp"
+
+	// ExporterFile enum, exports to the file with one OTLP JSON request per line.
+	ExporterFile = "file"
+)
+
+// Tracer tuple.
+type Tracer struct {
+	log      *xlog.Log
+	conf     *config.TraceConfig
+	exporter exporter
+	queue    chan *Span
+	done     chan bool
+	ticker   *time.Ticker
+	dropped  sync2.AtomicInt64
+	wg       sync.WaitGroup
+}
+
+// NewTracer creates the new tracer.
+func NewTracer(log *xlog.Log, conf *config.TraceConfig) *Tracer {
+	return &Tracer{
+		log:    log,
+		conf:   conf,
+		queue:  make(chan *Span, queueSize),
+		done:   make(chan bool),
+		ticker: time.NewTicker(flushInterval),
+	}
+}
+
+// Init used to create the exporter if the tracing is enabled.
+func (t *Tracer) Init() error {
+	log := t.log
+
+	log.Info("tracer.init.conf:%+v", t.conf)
+	if t.conf.Enable {
+		switch t.conf.Exporter {
+		case ExporterOTLP:
+			t.exporter = newOTLPExporter(t.conf.Endpoint)
+		case ExporterFile:
+			exporter, err := newFileExporter(t.conf.File)
+			if err != nil {
+				return err
+			}
+			t.exporter = exporter
+		default:
+			return errors.Errorf("tracer.unsupported.exporter[%s]", t.conf.Exporter)
+		}
+	}
+
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		t.spanConsumer()
+	}()
+	log.Info("tracer.init.done")
+	return nil
+}
+
+// Close used to flush the spans and close the exporter.
+func (t *Tracer) Close() {
+	close(t.done)
+	t.wg.Wait()
+	if t.exporter != nil {
+		t.exporter.close()
+	}
+	t.log.Info("tracer.closed")
+}
+
+// Enabled returns true if the tracing is enabled.
+func (t *Tracer) Enabled() bool {
+	return t.conf.Enable
+}
+
+// Dropped returns the number of the spans dropped as the queue is full.
+func (t *Tracer) Dropped() int64 {
+	return t.dropped.Get()
+}
+
+// StartSpan used to start the root span of the statement at the time.
+// If the parent is nil, the statement is sampled by the sample ratio, otherwise follows the parent sampled flag.
+// Returns nil if the tracing is disabled or the statement isn't sampled.
+func (t *Tracer) StartSpan(name string, parent *SpanContext, start time.Time) *Span {
+	if !t.conf.Enable {
+		return nil
+	}
+
+	span := &Span{
+		tracer: t,
+		spanID: newSpanID(),
+		name:   name,
+		kind:   SpanKindServer,
+		start:  start,
+	}
+	if parent != nil {
+		if !parent.Sampled {
+			return nil
+		}
+		span.traceID = parent.TraceID
+		span.parentID = parent.SpanID
+	} else {
+		if t.conf.SampleRatio <= 0 || rand.Float64() >= t.conf.SampleRatio {
+			return nil
+		}
+		span.traceID = newTraceID()
+	}
+	return span
+}
+
+func (t *Tracer) export(span *Span) {
+	select {
+	case t.queue <- span:
+	default:
+		t.dropped.Add(1)
+	}
+}
+
+func (t *Tracer) spanConsumer() {
+	defer t.ticker.Stop()
+
+	batch := make([]*Span, 0, batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := t.exporter.export(t.conf.ServiceName, batch); err != nil {
+			t.log.Error("tracer.export.spans[%d].error:%v", len(batch), err)
+		}
+		batch = make([]*Span, 0, batchSize)
+	}
+
+	for {
+		select {
+		case span := <-t.queue:
+			batch = append(batch, span)
+			if len(batch) >= batchSize {
+				flush()
+			}
+		case <-t.ticker.C:
+			flush()
+		case <-t.done:
+			// Drain the queue.
+			for {
+				select {
+				case span := <-t.queue:
+					batch = append(batch, span)
+					if len(batch) >= batchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,137 @@
+/*
+ * Radon
+ *
+ * Copyright 2018 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package xtrace
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"config"
+
+	"github.com/fortytw2/leaktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestTracerSampling(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	conf := config.DefaultTraceConfig()
+	tracer := NewTracer(log, conf)
+	err := tracer.Init()
+	assert.Nil(t, err)
+	defer tracer.Close()
+
+	// Disabled.
+	assert.False(t, tracer.Enabled())
+	assert.Nil(t, tracer.StartSpan("q", nil, time.Now()))
+
+	conf.Enable = true
+	sc := ParseTraceparent("/* traceparent=00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01 */")
+	span := tracer.StartSpan("q", sc, time.Now())
+	assert.NotNil(t, span)
+	assert.Equal(t, sc.TraceID, span.Context().TraceID)
+	assert.Equal(t, sc.SpanID, span.parentID)
+
+	// The parent isn't sampled.
+	sc.Sampled = false
+	assert.Nil(t, tracer.StartSpan("q", sc, time.Now()))
+
+	// Ratio.
+	span = tracer.StartSpan("q", nil, time.Now())
+	assert.NotNil(t, span)
+	assert.False(t, span.parentID.IsValid())
+	conf.SampleRatio = 0
+	assert.Nil(t, tracer.StartSpan("q", nil, time.Now()))
+}
+
+func TestTracerUnsupportedExporter(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	conf := config.DefaultTraceConfig()
+	conf.Enable = true
+	conf.Exporter = "xx"
+	tracer := NewTracer(log, conf)
+	err := tracer.Init()
+	assert.Equal(t, "tracer.unsupported.exporter[xx]", err.Error())
+}
+
+func TestTracerFileExporter(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	tmpDir, err := ioutil.TempDir("", "radon_trace_")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	conf := config.DefaultTraceConfig()
+	conf.Enable = true
+	conf.Exporter = ExporterFile
+	conf.File = filepath.Join(tmpDir, "trace.json")
+	tracer := NewTracer(log, conf)
+	err = tracer.Init()
+	assert.Nil(t, err)
+
+	root := tracer.StartSpan("proxy.ComQuery", nil, time.Now())
+	root.SetAttribute("db.statement", "select 1")
+	child := root.StartChild("backend.query")
+	child.SetKind(SpanKindClient)
+	child.SetAttribute("db.rows", uint64(3))
+	child.SetError(errors.New("mock.error"))
+	child.End()
+	root.End()
+	// Ended only once.
+	root.End()
+	tracer.Close()
+
+	data, err := ioutil.ReadFile(conf.File)
+	assert.Nil(t, err)
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	assert.Equal(t, 1, len(lines))
+
+	req := &otlpRequest{}
+	err = json.Unmarshal([]byte(lines[0]), req)
+	assert.Nil(t, err)
+	assert.Equal(t, "service.name", req.ResourceSpans[0].Resource.Attributes[0].Key)
+	assert.Equal(t, "radon", req.ResourceSpans[0].Resource.Attributes[0].Value["stringValue"])
+	spans := req.ResourceSpans[0].ScopeSpans[0].Spans
+	assert.Equal(t, 2, len(spans))
+
+	got, want := spans[0], child
+	assert.Equal(t, want.traceID.String(), got.TraceID)
+	assert.Equal(t, want.spanID.String(), got.SpanID)
+	assert.Equal(t, root.spanID.String(), got.ParentSpanID)
+	assert.Equal(t, "backend.query", got.Name)
+	assert.Equal(t, SpanKindClient, got.Kind)
+	assert.Equal(t, "db.rows", got.Attributes[0].Key)
+	assert.Equal(t, "3", got.Attributes[0].Value["intValue"])
+	assert.Equal(t, &otlpStatus{Code: 2, Message: "mock.error"}, got.Status)
+
+	got = spans[1]
+	assert.Equal(t, "proxy.ComQuery", got.Name)
+	assert.Equal(t, SpanKindServer, got.Kind)
+	assert.Equal(t, "", got.ParentSpanID)
+	assert.Nil(t, got.Status)
+	assert.Contains(t, lines[0], `"startTimeUnixNano":"`)
+}
+
+func TestTracerFileExporterError(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	conf := config.DefaultTraceConfig()
+	conf.Enable = true
+	conf.Exporter = ExporterFile
+	conf.File = "/proc/radon/xx/trace.json"
+	tracer := NewTracer(log, conf)
+	err := tracer.Init()
+	assert.NotNil(t, err)
+}